@@ -0,0 +1,34 @@
+// boltimport copies an existing json db file into a bbolt database
+//
+//	go run ./cmd/boltimport -from db.json -to db.bolt
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/Warren-Wang-OG/go-social-media-backend/database/bolt"
+)
+
+func main() {
+	from := flag.String("from", "db.json", "json db file to import")
+	to := flag.String("to", "db.bolt", "bolt file to import into, created if missing")
+	flag.Parse()
+
+	ctx := context.Background()
+	c, err := bolt.NewClient(*to)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.EnsureDB(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := c.ImportJSON(ctx, *from); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("imported %s into %s\n", *from, *to)
+}
@@ -0,0 +1,34 @@
+// sqliteimport copies an existing json db file into a SQLite database
+//
+//	go run ./cmd/sqliteimport -from db.json -to db.sqlite
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/Warren-Wang-OG/go-social-media-backend/database/sqlite"
+)
+
+func main() {
+	from := flag.String("from", "db.json", "json db file to import")
+	to := flag.String("to", "db.sqlite", "SQLite database to import into, created if missing")
+	flag.Parse()
+
+	ctx := context.Background()
+	c, err := sqlite.NewClient(*to)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.EnsureDB(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := c.ImportJSON(ctx, *from); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("imported %s into %s\n", *from, *to)
+}
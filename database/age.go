@@ -0,0 +1,24 @@
+package database
+
+import "fmt"
+
+// DefaultMinimumAge -
+// the youngest a user can be unless WithMinimumAge says otherwise
+const DefaultMinimumAge = 13
+
+// MaxAge -
+// the oldest a user can be, anything above is a typo
+const MaxAge = 150
+
+// CheckAge -
+// ErrInvalidAge naming the bound age is outside of, minimum up to MaxAge.
+// the backends call it with DefaultMinimumAge, the Client with WithMinimumAge's
+func CheckAge(age, minimum int) error {
+	if age < minimum {
+		return fmt.Errorf("%w: %d is under the minimum of %d", ErrInvalidAge, age, minimum)
+	}
+	if age > MaxAge {
+		return fmt.Errorf("%w: %d is over the maximum of %d", ErrInvalidAge, age, MaxAge)
+	}
+	return nil
+}
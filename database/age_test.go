@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMinimumAge(t *testing.T) {
+	var tests = []struct {
+		name     string
+		opts     []Option
+		age      int
+		expected error
+	}{
+		{name: "default below", age: DefaultMinimumAge - 1, expected: ErrInvalidAge},
+		{name: "default at", age: DefaultMinimumAge, expected: nil},
+		{name: "negative", age: -1, expected: ErrInvalidAge},
+		{name: "zero", age: 0, expected: ErrInvalidAge},
+		{name: "at the maximum", age: MaxAge, expected: nil},
+		{name: "absurdly large", age: 1000, expected: ErrInvalidAge},
+		{name: "configured below", opts: []Option{WithMinimumAge(18)}, age: 17, expected: ErrInvalidAge},
+		{name: "configured at", opts: []Option{WithMinimumAge(18)}, age: 18, expected: nil},
+		{name: "no minimum", opts: []Option{WithMinimumAge(0)}, age: 0, expected: nil},
+		// the hard bounds hold whatever the minimum
+		{name: "no minimum negative", opts: []Option{WithMinimumAge(0)}, age: -1, expected: ErrInvalidAge},
+		{name: "no minimum absurdly large", opts: []Option{WithMinimumAge(0)}, age: MaxAge + 1, expected: ErrInvalidAge},
+	}
+	for _, test := range tests {
+		c := NewMemoryClient(test.opts...)
+		if _, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", test.age); !errors.Is(err, test.expected) {
+			t.Errorf("%s: CreateUser() with age %d = %v, expected %v", test.name, test.age, err, test.expected)
+		}
+		if _, err := c.UpsertUser(ctx, "other@example.com", "correct horse", "john doe", 30); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.UpdateUser(ctx, "other@example.com", "correct horse", "john doe", test.age); !errors.Is(err, test.expected) {
+			t.Errorf("%s: UpdateUser() with age %d = %v, expected %v", test.name, test.age, err, test.expected)
+		}
+		err := c.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+			_, err := tx.CreateUser(ctx, "tx@example.com", "correct horse", "john doe", test.age)
+			return err
+		})
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s: Tx.CreateUser() with age %d = %v, expected %v", test.name, test.age, err, test.expected)
+		}
+	}
+}
+
+func TestMinimumAgeError(t *testing.T) {
+	c := NewMemoryClient(WithMinimumAge(18))
+	var tests = []struct {
+		age      int
+		expected string
+	}{
+		{age: 12, expected: "minimum of 18"},
+		{age: 151, expected: "maximum of 150"},
+	}
+	for _, test := range tests {
+		_, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", test.age)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("CreateUser() with age %d = %v, expected it to name the %q", test.age, err, test.expected)
+		}
+	}
+	// nothing was stored
+	if _, err := c.GetUser(ctx, "test@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUser() after refused ages = %v, expected ErrUserNotFound", err)
+	}
+}
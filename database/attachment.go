@@ -0,0 +1,174 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// most attachments a post can have and the longest AltText one can have, in characters (runes)
+const (
+	MaxAttachments   = 4
+	MaxAltTextLength = 1000
+)
+
+// schemes an attachment URL can have, like a profile's AvatarURL
+var attachmentURLSchemes = map[string]bool{"http": true, "https": true}
+
+// Attachment -
+// an image or other media a post links to. the file is uploaded somewhere else, the db only stores
+// where it is and what a client needs to show it
+type Attachment struct {
+	// URL is an absolute http or https URL
+	URL string `json:"url"`
+	// MimeType is a type/subtype media type like image/png, stored in lower case
+	MimeType string `json:"mimeType"`
+	// AltText describes the media for screen readers, at most MaxAltTextLength characters
+	AltText string `json:"altText,omitempty"`
+	// Width and Height are in pixels, 0 when not known
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+}
+
+// isMimeToken -
+// whether s is a type or subtype name as RFC 6838 allows them: a letter or digit and then
+// letters, digits and !#$&-^_.+
+func isMimeToken(s string) bool {
+	if s == "" || len(s) > 127 {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case i > 0 && strings.ContainsRune("!#$&-^_.+", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// normalize -
+// a with its URL and MimeType trimmed and the MimeType lower case, what a post stores.
+// ErrInvalidAttachment for the first field that can't be stored
+func (a Attachment) normalize() (Attachment, error) {
+	a.URL = strings.TrimSpace(a.URL)
+	a.MimeType = strings.ToLower(strings.TrimSpace(a.MimeType))
+	if a.URL == "" {
+		return Attachment{}, fmt.Errorf("%w: no URL", ErrInvalidAttachment)
+	}
+	u, err := url.Parse(a.URL)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("%w: URL %q: %v", ErrInvalidAttachment, a.URL, err)
+	}
+	if !attachmentURLSchemes[strings.ToLower(u.Scheme)] || u.Host == "" {
+		return Attachment{}, fmt.Errorf("%w: URL %q isn't an http or https URL", ErrInvalidAttachment, a.URL)
+	}
+	// no parameters, a ;charset=utf-8 says nothing about media
+	kind, sub, ok := strings.Cut(a.MimeType, "/")
+	if !ok || !isMimeToken(kind) || !isMimeToken(sub) {
+		return Attachment{}, fmt.Errorf("%w: mime type %q isn't type/subtype", ErrInvalidAttachment, a.MimeType)
+	}
+	if n := utf8.RuneCountInString(a.AltText); n > MaxAltTextLength {
+		return Attachment{}, fmt.Errorf("%w: alt text is longer than %d characters", ErrInvalidAttachment, MaxAltTextLength)
+	}
+	if a.Width < 0 || a.Height < 0 {
+		return Attachment{}, fmt.Errorf("%w: size %dx%d is negative", ErrInvalidAttachment, a.Width, a.Height)
+	}
+	return a, nil
+}
+
+// postAttachments -
+// attachments normalized into a new slice, what a post stores, nil for none so a post without
+// any looks the same as before attachments. ErrInvalidAttachment for more than MaxAttachments or
+// the first one that can't be stored, naming its position
+func postAttachments(attachments []Attachment) ([]Attachment, error) {
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+	if len(attachments) > MaxAttachments {
+		return nil, fmt.Errorf("%w: %d attachments is over the limit of %d", ErrInvalidAttachment, len(attachments), MaxAttachments)
+	}
+	stored := make([]Attachment, 0, len(attachments))
+	for i, a := range attachments {
+		a, err := a.normalize()
+		if err != nil {
+			return nil, fmt.Errorf("attachment %d: %w", i+1, err)
+		}
+		stored = append(stored, a)
+	}
+	return stored, nil
+}
+
+// UpdatePostAttachments -
+// same as Client.UpdatePostAttachments, inside the Tx
+func (tx *Tx) UpdatePostAttachments(ctx context.Context, id, requesterEmail string, attachments []Attachment) (Post, error) {
+	post, _, err := tx.updatePostAttachments(id, requesterEmail, attachments)
+	return post, err
+}
+
+// updatePostAttachments -
+// UpdatePostAttachments that also reports whether the post changed
+func (tx *Tx) updatePostAttachments(id, requesterEmail string, attachments []Attachment) (Post, bool, error) {
+	if id == "" {
+		return Post{}, false, ErrEmptyPostID
+	}
+	db, err := tx.schema()
+	if err != nil {
+		return Post{}, false, err
+	}
+	post, ok := db.livePost(id)
+	if !ok {
+		return Post{}, false, fmt.Errorf("%w: %s", ErrPostNotFound, id)
+	}
+	if err := tx.checkCanChangePost(db, post, requesterEmail); err != nil {
+		return Post{}, false, err
+	}
+	if post.RepostOf != "" {
+		return Post{}, false, fmt.Errorf("%w: %s", ErrRepostNotEditable, id)
+	}
+	attachments, err = postAttachments(attachments)
+	if err != nil {
+		return Post{}, false, err
+	}
+	if equalSlices(post.Attachments, attachments) {
+		return post, false, nil
+	}
+	// edited like UpdatePost, the history keeps what the post linked to before
+	if !post.unpublished(tx.now()) {
+		editedAt := tx.now()
+		tx.recordPostEdit(post, editedAt)
+		post.EditedAt = &editedAt
+	}
+	post.Attachments = attachments
+	db.putPost(post)
+	return post, true, nil
+}
+
+// UpdatePostAttachments -
+// replace all the attachments of the post with id with attachments on behalf of requesterEmail,
+// held to what UpdatePost checks. none removes them all and the text is left alone, like UpdatePost
+// leaves the attachments. the post gets EditedAt and its earlier text and attachments go to
+// GetPostEditHistory the same way, the same attachments again write nothing.
+// ErrEmptyPostID, ErrPostNotFound, ErrNotPostAuthor and ErrRepostNotEditable like UpdatePost, ErrInvalidAttachment like CreatePostWithOptions
+func (c *Client) UpdatePostAttachments(ctx context.Context, id, requesterEmail string, attachments []Attachment) (Post, error) {
+	if id == "" {
+		return Post{}, ErrEmptyPostID
+	}
+	post := Post{}
+	err := c.update(ctx, "UpdatePostAttachments", id, func(db *Schema) error {
+		var changed bool
+		var err error
+		post, changed, err = c.newTx(db).updatePostAttachments(id, requesterEmail, attachments)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+	if err != nil {
+		return Post{}, err
+	}
+	return post, nil
+}
@@ -0,0 +1,184 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var pngAttachment = Attachment{URL: "https://cdn.example.com/a.png", MimeType: "image/png", AltText: "a cat", Width: 640, Height: 480}
+
+// attachments is n copies of a
+func attachments(n int, a Attachment) []Attachment {
+	list := []Attachment{}
+	for i := 0; i < n; i++ {
+		list = append(list, a)
+	}
+	return list
+}
+
+func TestPostAttachments(t *testing.T) {
+	with := func(change func(a *Attachment)) []Attachment {
+		a := pngAttachment
+		change(&a)
+		return []Attachment{a}
+	}
+	var tests = []struct {
+		attachments []Attachment
+		stored      []Attachment
+		expected    error
+	}{
+		{attachments: nil, stored: nil, expected: nil},
+		{attachments: []Attachment{}, stored: nil, expected: nil},
+		{attachments: []Attachment{pngAttachment}, stored: []Attachment{pngAttachment}, expected: nil},
+		{attachments: []Attachment{{URL: " http://example.com/v.mp4 ", MimeType: " Video/MP4 "}}, stored: []Attachment{{URL: "http://example.com/v.mp4", MimeType: "video/mp4"}}, expected: nil},
+		{attachments: with(func(a *Attachment) { a.MimeType = "application/vnd.api+json" }), stored: with(func(a *Attachment) { a.MimeType = "application/vnd.api+json" }), expected: nil},
+		{attachments: attachments(MaxAttachments, pngAttachment), stored: attachments(MaxAttachments, pngAttachment), expected: nil},
+		{attachments: attachments(MaxAttachments+1, pngAttachment), expected: ErrInvalidAttachment},
+		{attachments: with(func(a *Attachment) { a.URL = "" }), expected: ErrInvalidAttachment},
+		{attachments: with(func(a *Attachment) { a.URL = "javascript:alert(1)" }), expected: ErrInvalidAttachment},
+		{attachments: with(func(a *Attachment) { a.URL = "ftp://example.com/a.png" }), expected: ErrInvalidAttachment},
+		{attachments: with(func(a *Attachment) { a.URL = "/a.png" }), expected: ErrInvalidAttachment},
+		{attachments: with(func(a *Attachment) { a.URL = "https://exa mple.com/%zz" }), expected: ErrInvalidAttachment},
+		{attachments: with(func(a *Attachment) { a.MimeType = "" }), expected: ErrInvalidAttachment},
+		{attachments: with(func(a *Attachment) { a.MimeType = "image" }), expected: ErrInvalidAttachment},
+		{attachments: with(func(a *Attachment) { a.MimeType = "image/" }), expected: ErrInvalidAttachment},
+		{attachments: with(func(a *Attachment) { a.MimeType = "/png" }), expected: ErrInvalidAttachment},
+		{attachments: with(func(a *Attachment) { a.MimeType = "image/png; q=1" }), expected: ErrInvalidAttachment},
+		{attachments: with(func(a *Attachment) { a.MimeType = "image/png/x" }), expected: ErrInvalidAttachment},
+		{attachments: with(func(a *Attachment) { a.MimeType = "image/+png" }), expected: ErrInvalidAttachment},
+		{attachments: with(func(a *Attachment) { a.AltText = strings.Repeat("é", MaxAltTextLength) }), stored: with(func(a *Attachment) { a.AltText = strings.Repeat("é", MaxAltTextLength) }), expected: nil},
+		{attachments: with(func(a *Attachment) { a.AltText = strings.Repeat("é", MaxAltTextLength+1) }), expected: ErrInvalidAttachment},
+		{attachments: with(func(a *Attachment) { a.Width = -1 }), expected: ErrInvalidAttachment},
+		{attachments: []Attachment{pngAttachment, {URL: pngAttachment.URL}}, expected: ErrInvalidAttachment},
+	}
+	for _, test := range tests {
+		stored, err := postAttachments(test.attachments)
+		if !errors.Is(err, test.expected) || !reflect.DeepEqual(stored, test.stored) {
+			t.Errorf("postAttachments(%+v) = %+v, %v, expected %+v, %v", test.attachments, stored, err, test.stored, test.expected)
+		}
+	}
+}
+
+func TestCreatePostWithAttachments(t *testing.T) {
+	c := newBlockClient(t)
+	given := []Attachment{pngAttachment, {URL: "https://cdn.example.com/b.gif", MimeType: "IMAGE/GIF"}}
+	post, err := c.CreatePostWithOptions(ctx, "a@example.com", "two pictures", CreatePostOptions{Attachments: given})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Attachment{pngAttachment, {URL: "https://cdn.example.com/b.gif", MimeType: "image/gif"}}
+	if !reflect.DeepEqual(post.Attachments, expected) {
+		t.Errorf("CreatePostWithOptions() attachments = %+v, expected %+v", post.Attachments, expected)
+	}
+	// the post has its own copy
+	given[0].URL = "https://elsewhere.example.com/"
+	if got, err := c.GetPost(ctx, post.ID); err != nil || !reflect.DeepEqual(got.Attachments, expected) {
+		t.Errorf("GetPost() after changing the given attachments = %+v, %v, expected %+v", got.Attachments, err, expected)
+	}
+
+	// nothing is written for attachments that can't be stored
+	before, err := c.GetPosts(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := CreatePostOptions{Attachments: attachments(MaxAttachments+1, pngAttachment)}
+	if _, err := c.CreatePostWithOptions(ctx, "a@example.com", "too many", opts); !errors.Is(err, ErrInvalidAttachment) {
+		t.Errorf("CreatePostWithOptions() with %d attachments = %v, expected ErrInvalidAttachment", MaxAttachments+1, err)
+	}
+	if after, err := c.GetPosts(ctx, "a@example.com"); err != nil || len(after) != len(before) {
+		t.Errorf("GetPosts() after a failed create = %d posts, %v, expected %d", len(after), err, len(before))
+	}
+
+	// attachments round-trip through the file, and posts without any are stored as before
+	reopened := NewClient(dbPath(c))
+	if got, err := reopened.GetPost(ctx, post.ID); err != nil || !got.equal(post) {
+		t.Errorf("GetPost() from the file = %+v, %v, expected %+v", got, err, post)
+	}
+	data, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw struct {
+		Posts map[string]map[string]json.RawMessage `json:"posts"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	for id, fields := range raw.Posts {
+		if _, ok := fields["attachments"]; ok != (id == post.ID) {
+			t.Errorf("post %s stored with attachments %v, expected %v", id, ok, id == post.ID)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := c.ExportUserData(ctx, "a@example.com", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"attachments":[{"url":"https://cdn.example.com/a.png","mimeType":"image/png","altText":"a cat","width":640,"height":480}`) {
+		t.Errorf("ExportUserData() left out the attachments:\n%s", buf.String())
+	}
+}
+
+func TestUpdatePostAttachments(t *testing.T) {
+	c := newBlockClient(t)
+	post, err := c.CreatePostWithOptions(ctx, "a@example.com", "a picture", CreatePostOptions{Attachments: []Attachment{pngAttachment}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the text changes and the attachments stay
+	if post, err = c.UpdatePost(ctx, post.ID, "a@example.com", "still a picture"); err != nil || !reflect.DeepEqual(post.Attachments, []Attachment{pngAttachment}) {
+		t.Errorf("UpdatePost() = %+v, %v, expected the attachments kept", post, err)
+	}
+
+	gif := Attachment{URL: "https://cdn.example.com/b.gif", MimeType: "image/gif"}
+	var tests = []struct {
+		requester   string
+		attachments []Attachment
+		stored      []Attachment
+		expected    error
+	}{
+		{requester: "a@example.com", attachments: []Attachment{gif, pngAttachment}, stored: []Attachment{gif, pngAttachment}, expected: nil},
+		{requester: "a@example.com", attachments: []Attachment{gif, pngAttachment}, stored: []Attachment{gif, pngAttachment}, expected: nil},
+		{requester: "a@example.com", attachments: []Attachment{{URL: "file:///etc/passwd", MimeType: "text/plain"}}, stored: []Attachment{gif, pngAttachment}, expected: ErrInvalidAttachment},
+		{requester: "b@example.com", attachments: nil, stored: []Attachment{gif, pngAttachment}, expected: ErrNotPostAuthor},
+		{requester: "a@example.com", attachments: nil, stored: nil, expected: nil},
+	}
+	for _, test := range tests {
+		updated, err := c.UpdatePostAttachments(ctx, post.ID, test.requester, test.attachments)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("UpdatePostAttachments(%q, %+v) = %v, expected %v", test.requester, test.attachments, err, test.expected)
+		}
+		if err == nil && (updated.Text != "still a picture" || updated.EditedAt == nil) {
+			t.Errorf("UpdatePostAttachments(%q, %+v) = %+v, expected the text kept and EditedAt set", test.requester, test.attachments, updated)
+		}
+		if got, err := c.GetPost(ctx, post.ID); err != nil || !reflect.DeepEqual(got.Attachments, test.stored) {
+			t.Errorf("GetPost() after UpdatePostAttachments(%q, %+v) = %+v, %v, expected %+v", test.requester, test.attachments, got.Attachments, err, test.stored)
+		}
+	}
+
+	// the history has what the post linked to before each edit, the same attachments again aren't one
+	edits, err := c.GetPostEditHistory(ctx, post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]Attachment{{pngAttachment}, {pngAttachment}, {gif, pngAttachment}}
+	got := [][]Attachment{}
+	for _, edit := range edits {
+		got = append(got, edit.Attachments)
+	}
+	if !reflect.DeepEqual(got, expected) || !reflect.DeepEqual(editTexts(edits), []string{"a picture", "still a picture", "still a picture"}) {
+		t.Errorf("GetPostEditHistory() = %+v, expected attachments %+v", edits, expected)
+	}
+
+	if _, err := c.UpdatePostAttachments(ctx, "", "a@example.com", nil); !errors.Is(err, ErrEmptyPostID) {
+		t.Errorf("UpdatePostAttachments() of an empty id = %v, expected ErrEmptyPostID", err)
+	}
+	if _, err := c.UpdatePostAttachments(ctx, "missing", "a@example.com", nil); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("UpdatePostAttachments() of a missing post = %v, expected ErrPostNotFound", err)
+	}
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// dummyHash -
+// a hash at the client's cost to check passwords of unknown users against,
+// so they take as long to reject as wrong passwords do
+type dummyHash struct {
+	once sync.Once
+	hash string
+}
+
+func (d *dummyHash) get(cost int) string {
+	d.once.Do(func() {
+		// can't fail, the password is short and the cost was validated
+		d.hash, _ = HashPassword("not anyone's password", cost)
+	})
+	return d.hash
+}
+
+// AuthenticateUser -
+// log in with email and password, ErrInvalidCredentials whether the email is unknown or the password wrong.
+// returns the user without PasswordHash on success, with LastLoginAt and LoginCount already counting
+// this login. a legacy password is upgraded like VerifyPassword does,
+// ErrAccountDeactivated if the password is right but the account is deactivated
+func (c *Client) AuthenticateUser(ctx context.Context, email, password string) (User, error) {
+	user, err := c.authenticate(ctx, email, password)
+	if err != nil {
+		return User{}, err
+	}
+	user = c.recordLogin(ctx, user)
+	user.PasswordHash = ""
+	return user, nil
+}
+
+// CheckCredentials -
+// AuthenticateUser without counting a login, for requests that carry an email and password
+// rather than logging in. same errors, and an unknown email takes as long as a wrong password
+func (c *Client) CheckCredentials(ctx context.Context, email, password string) (User, error) {
+	user, err := c.authenticate(ctx, email, password)
+	user.PasswordHash = ""
+	return user, err
+}
+
+// authenticate -
+// the checks shared by AuthenticateUser and CheckCredentials, returns the user as stored
+func (c *Client) authenticate(ctx context.Context, email, password string) (User, error) {
+	user, err := c.verifyPassword(ctx, email, password)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		// spend the same time as for a wrong password
+		CheckPassword(c.dummy.get(c.passwordCost), password)
+		return User{}, ErrInvalidCredentials
+	case errors.Is(err, ErrWrongPassword):
+		return User{}, ErrInvalidCredentials
+	case err != nil:
+		return User{}, err
+	}
+	// only checked once the password is right, so a deactivated account isn't revealed to anyone else
+	if !user.Active() {
+		return User{}, fmt.Errorf("%w: %s", ErrAccountDeactivated, user.Email)
+	}
+	// a future lockout check belongs here, after the password checked out
+	return user, nil
+}
+
+// recordLogin -
+// bump the user's LastLoginAt and LoginCount, returns the user as stored afterwards.
+// like a password upgrade a failed write is only logged, the login itself succeeded
+func (c *Client) recordLogin(ctx context.Context, user User) User {
+	if c.readOnly {
+		return user
+	}
+	recorded := User{}
+	err := c.update(ctx, "RecordLogin", user.Email, func(db *Schema) error {
+		current, ok := db.activeUser(user.Email)
+		// deleted meanwhile, nothing to record it on
+		if !ok {
+			return errNoop
+		}
+		// not an UpdatedAt change, the user didn't edit anything
+		current.LastLoginAt = c.newTx(db).now()
+		current.LoginCount++
+		db.putUser(current)
+		recorded = current
+		return nil
+	})
+	if err != nil {
+		c.logError("recording login failed", "key", user.Email, "error", err)
+		return user
+	}
+	if recorded.Email == "" {
+		return user
+	}
+	return recorded
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAuthenticateUser(t *testing.T) {
+	c := newTestClient(t)
+	created, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := c.AuthenticateUser(ctx, "test@example.com", "correct horse")
+	if err != nil {
+		t.Fatalf("AuthenticateUser() with the right password = %v, expected nil", err)
+	}
+	expected := created
+	expected.PasswordHash = ""
+	expected.LastLoginAt, expected.LoginCount = user.LastLoginAt, 1
+	if user != expected || user.LastLoginAt.IsZero() {
+		t.Errorf("AuthenticateUser() = %+v, expected %+v", user, expected)
+	}
+	// the stored hash isn't touched by zeroing the returned one
+	if err := c.VerifyPassword(ctx, "test@example.com", "correct horse"); err != nil {
+		t.Errorf("VerifyPassword() after AuthenticateUser() = %v, expected nil", err)
+	}
+
+	var tests = []struct {
+		name     string
+		email    string
+		password string
+	}{
+		{name: "wrong password", email: "test@example.com", password: "wrong horse"},
+		{name: "empty password", email: "test@example.com", password: ""},
+		{name: "unknown email", email: "missing@example.com", password: "correct horse"},
+	}
+	messages := map[string]bool{}
+	for _, test := range tests {
+		user, err := c.AuthenticateUser(ctx, test.email, test.password)
+		if !errors.Is(err, ErrInvalidCredentials) || user != (User{}) {
+			t.Errorf("%s: AuthenticateUser() = %+v, %v, expected ErrInvalidCredentials", test.name, user, err)
+			continue
+		}
+		messages[err.Error()] = true
+	}
+	// nothing in the error tells an unknown email from a wrong password
+	if len(messages) != 1 {
+		t.Errorf("AuthenticateUser() errors differ: %v, expected a single message", messages)
+	}
+}
+
+func TestAuthenticateUserLegacy(t *testing.T) {
+	c := newTestClient(t, WithCredentials())
+	if err := c.Load(ctx, Schema{Users: map[string]User{"test@example.com": {Email: "test@example.com", PasswordHash: "hunter22"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AuthenticateUser(ctx, "test@example.com", "hunter2"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("AuthenticateUser() of a legacy record with the wrong password = %v, expected ErrInvalidCredentials", err)
+	}
+	user, err := c.AuthenticateUser(ctx, "test@example.com", "hunter22")
+	if err != nil || user.PasswordHash != "" {
+		t.Fatalf("AuthenticateUser() of a legacy record = %+v, %v, expected the user without a password", user, err)
+	}
+	stored, err := c.GetUser(ctx, "test@example.com")
+	if err != nil || isLegacyPassword(stored.PasswordHash) {
+		t.Errorf("stored password after AuthenticateUser() = %q, %v, expected it upgraded", stored.PasswordHash, err)
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	user, err := c.CheckCredentials(ctx, "test@example.com", "correct horse")
+	if err != nil || user.PasswordHash != "" || user.LoginCount != 0 {
+		t.Errorf("CheckCredentials() = %+v, %v, expected the user without a password or a login", user, err)
+	}
+	if _, err := c.CheckCredentials(ctx, "missing@example.com", "correct horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("CheckCredentials() of an unknown email = %v, expected ErrInvalidCredentials", err)
+	}
+	if _, err := c.DeactivateUser(ctx, "test@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CheckCredentials(ctx, "test@example.com", "correct horse"); !errors.Is(err, ErrAccountDeactivated) {
+		t.Errorf("CheckCredentials() of a deactivated account = %v, expected ErrAccountDeactivated", err)
+	}
+}
@@ -0,0 +1,139 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// suffix of the copy of the db kept by Restore
+const preRestoreSuffix = ".pre-restore"
+
+// Backup -
+// copy the current db file to destPath atomically
+// holds the write lock so the copy is consistent with every write made before it
+func (c *Client) Backup(ctx context.Context, destPath string) error {
+	file, ok := c.store.(*fileStore)
+	if !ok {
+		return fmt.Errorf("%w: Backup needs a file-backed client", ErrNotSupported)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// writes still in the write-ahead log have to be in the copy,
+	// a read-only client can't fold them in so it copies both files
+	if c.readOnly {
+		return backupWithWAL(file, destPath)
+	}
+	if err := c.flushPending(ctx); err != nil {
+		return err
+	}
+	if err := file.Flush(ctx); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(file.path)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(destPath, data, file.fileMode)
+}
+
+// Restore -
+// replace the db with the backup at srcPath
+// the backup must parse as a database of a version this package can read, otherwise nothing changes
+// the previous db file is kept next to it with a .pre-restore suffix
+func (c *Client) Restore(ctx context.Context, srcPath string) error {
+	file, ok := c.store.(*fileStore)
+	if !ok {
+		return fmt.Errorf("%w: Restore needs a file-backed client", ErrNotSupported)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	data, err := os.ReadFile(srcPath)
+	if err != nil {
+		return err
+	}
+	db, _, err := file.decodeDB(data)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := c.flushPending(ctx); err != nil {
+		return err
+	}
+
+	// keep the current file around in case the restore was a mistake
+	current, err := os.ReadFile(file.path)
+	if err == nil {
+		err = writeFileAtomic(file.path+preRestoreSuffix, current, file.fileMode)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := file.replace(data, db); err != nil {
+		return err
+	}
+	c.mem = &db
+	return c.measure()
+}
+
+// replace -
+// overwrite the db file with data, which decodes to db, and drop the log
+// the log belongs to the db that was just replaced
+func (s *fileStore) replace(data []byte, db Schema) error {
+	if err := writeFileAtomic(s.path, data, s.fileMode); err != nil {
+		return err
+	}
+	if err := os.Remove(s.walPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	s.last, _ = os.Stat(s.path)
+	s.lastWAL = nil
+	s.base = &db
+	s.pending = 0
+	return nil
+}
+
+// backupWithWAL -
+// copy the db file and its write-ahead log as they are, for clients that can't flush the log
+func backupWithWAL(file *fileStore, destPath string) error {
+	data, err := os.ReadFile(file.path)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(destPath, data, file.fileMode); err != nil {
+		return err
+	}
+	wal, err := os.ReadFile(file.walPath())
+	if errors.Is(err, os.ErrNotExist) {
+		// don't leave the log of an older backup next to this one
+		if err := os.Remove(destPath + walSuffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(destPath+walSuffix, wal, file.fileMode)
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupRestore(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreatePost(ctx, "test@example.com", "my cat is way too fat"); err != nil {
+		t.Fatal(err)
+	}
+
+	backupPath := filepath.Join(t.TempDir(), "backup.json")
+	if err := c.Backup(ctx, backupPath); err != nil {
+		t.Fatalf("Backup() = %v, expected nil", err)
+	}
+	original, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertFileContents(t, backupPath, string(original))
+
+	// risky admin operation
+	if _, err := c.DeleteUser(ctx, "test@example.com", DeleteUserOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	afterDelete, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Restore(ctx, backupPath); err != nil {
+		t.Fatalf("Restore() = %v, expected nil", err)
+	}
+	assertFileContents(t, dbPath(c), string(original))
+	assertFileContents(t, dbPath(c)+preRestoreSuffix, string(afterDelete))
+
+	// in-memory copy follows the restore
+	posts, err := c.GetPosts(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 {
+		t.Errorf("len(GetPosts()) after restore = %d, expected 1", len(posts))
+	}
+}
+
+func TestRestoreRejectsBadBackups(t *testing.T) {
+	var tests = []struct {
+		contents    string
+		expectedErr error
+	}{
+		{contents: `{"users": `, expectedErr: ErrDBCorrupt},
+		{contents: `{"schemaVersion":99,"users":{},"posts":{}}`, expectedErr: ErrUnsupportedVersion},
+	}
+
+	for _, test := range tests {
+		c := newTestClient(t)
+		before, err := os.ReadFile(dbPath(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		backupPath := filepath.Join(t.TempDir(), "backup.json")
+		if err := os.WriteFile(backupPath, []byte(test.contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := c.Restore(ctx, backupPath); !errors.Is(err, test.expectedErr) {
+			t.Errorf("Restore(%s) = %v, expected %v", test.contents, err, test.expectedErr)
+		}
+		assertFileContents(t, dbPath(c), string(before))
+		if _, err := os.Stat(dbPath(c) + preRestoreSuffix); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("pre-restore file written for rejected backup %s", test.contents)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// batch -
+// the state of WithBatchedWrites, guarded by the client's write lock
+type batch struct {
+	interval   time.Duration
+	maxPending int
+	onError    func(error)
+
+	// writes in the client's mem that the store doesn't have yet
+	pending int
+	// the scheduled background save, nil when none is
+	timer *time.Timer
+	// from the last failed background save, returned by the next write or Flush
+	err error
+}
+
+// queue -
+// count a write that only went to memory and make sure a save is coming
+// caller must hold the write lock
+func (c *Client) queue() {
+	b := c.batch
+	b.pending++
+	if b.maxPending > 0 && b.pending >= b.maxPending {
+		// save now, unless the timer already fired and its save is waiting for the lock
+		if b.timer != nil && !b.timer.Stop() {
+			return
+		}
+		b.timer = time.AfterFunc(0, c.backgroundFlush)
+		return
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.interval, c.backgroundFlush)
+	}
+}
+
+// flushPending -
+// save the batched writes to the store right away
+// caller must hold the write lock, the pending writes are kept for a retry if the save fails
+func (c *Client) flushPending(ctx context.Context) error {
+	b := c.batch
+	if b == nil || b.pending == 0 {
+		return nil
+	}
+	start := time.Now()
+	if err := c.store.Save(ctx, *c.mem); err != nil {
+		return err
+	}
+	c.logDebug("flushed batched writes", "writes", b.pending, "duration", time.Since(start))
+	b.pending = 0
+	b.err = nil
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return nil
+}
+
+// takeFlushErr returns the error of the last failed background save once, caller must hold the write lock
+func (c *Client) takeFlushErr() error {
+	if c.batch == nil {
+		return nil
+	}
+	err := c.batch.err
+	c.batch.err = nil
+	return err
+}
+
+// backgroundFlush -
+// the timer's save, a failure is reported and retried after another interval
+func (c *Client) backgroundFlush() {
+	ctx := context.Background()
+	c.mu.Lock()
+	b := c.batch
+	b.timer = nil
+	err := func() error {
+		if b.pending == 0 {
+			return nil
+		}
+		release, err := c.lockStore(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return c.flushPending(ctx)
+	}()
+	if err != nil {
+		if b.onError == nil {
+			b.err = err
+		}
+		b.timer = time.AfterFunc(b.interval, c.backgroundFlush)
+	}
+	c.mu.Unlock()
+
+	// outside the lock so the handler can use the client
+	if err != nil && b.onError != nil {
+		b.onError(err)
+	}
+}
+
+// Flush -
+// save any batched writes (see WithBatchedWrites) and anything the store itself buffers now
+func (c *Client) Flush(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c.readOnly {
+		return nil
+	}
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := c.flushPending(ctx); err != nil {
+		// this caller got the error, no need for the next write to see it as well
+		c.takeFlushErr()
+		return err
+	}
+	if flusher, ok := c.store.(Flusher); ok {
+		return flusher.Flush(ctx)
+	}
+	return nil
+}
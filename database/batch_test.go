@@ -0,0 +1,182 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// newBatchedClient returns a client batching writes to a fakeStore and a func reading
+// the store's save count safely while background saves may be running
+func newBatchedClient(t *testing.T, opts ...Option) (*Client, *fakeStore, func() int) {
+	t.Helper()
+	store := &fakeStore{}
+	c := NewClientWithStore(store, opts...)
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	saves := func() int {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		return store.saves
+	}
+	return c, store, saves
+}
+
+// waitFor polls cond for up to a second, for things done in the background
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for a background change")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBatchedWritesCoalesce(t *testing.T) {
+	c, store, saves := newBatchedClient(t, WithBatchedWrites(time.Hour, 0))
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		if _, err := c.CreatePost(ctx, "test@example.com", "hello"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := saves(); got != 1 {
+		t.Errorf("store.saves = %d after 1001 batched writes, expected only the EnsureDB one", got)
+	}
+	// reads see the writes before they're saved
+	posts, err := c.GetPosts(ctx, "test@example.com")
+	if err != nil || len(posts) != 1000 {
+		t.Fatalf("GetPosts() = %d posts, %v, expected 1000", len(posts), err)
+	}
+
+	if err := c.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := saves(); got != 2 {
+		t.Errorf("store.saves = %d after Flush(), expected 2", got)
+	}
+	if len(store.db.Posts) != 1000 {
+		t.Errorf("store has %d posts after Flush(), expected 1000", len(store.db.Posts))
+	}
+}
+
+func TestBatchedWritesInterval(t *testing.T) {
+	c, _, saves := newBatchedClient(t, WithBatchedWrites(20*time.Millisecond, 0))
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	// posts, hashing a password per write could outlast the interval
+	for i := 0; i < 100; i++ {
+		if _, err := c.CreatePost(ctx, "test@example.com", "hello"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, func() bool { return saves() == 2 })
+	time.Sleep(50 * time.Millisecond)
+	if got := saves(); got != 2 {
+		t.Errorf("store.saves = %d, expected one background save for the whole burst", got)
+	}
+}
+
+func TestBatchedWritesMaxPending(t *testing.T) {
+	c, _, saves := newBatchedClient(t, WithBatchedWrites(time.Hour, 10))
+	for i := 0; i < 9; i++ {
+		if _, err := c.UpsertUser(ctx, "test@example.com", "123456", "john doe", 20+i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if got := saves(); got != 1 {
+		t.Fatalf("store.saves = %d with 9 of 10 writes pending, expected no save yet", got)
+	}
+	if _, err := c.UpsertUser(ctx, "test@example.com", "123456", "john doe", 29); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return saves() == 2 })
+}
+
+func TestBatchedWritesClose(t *testing.T) {
+	path := t.TempDir() + "/db.json"
+	c := NewClient(path, WithBatchedWrites(time.Hour, 0))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewClient(path).GetUser(ctx, "test@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("batched write was on disk before Close(): GetUser() = %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewClient(path).GetUser(ctx, "test@example.com"); err != nil {
+		t.Errorf("GetUser() after Close() = %v, expected the batched write on disk", err)
+	}
+}
+
+func TestBatchedWritesErrors(t *testing.T) {
+	saveErr := errors.New("disk full")
+
+	// without a handler the next write gets the error, the writes stay pending
+	c, store, _ := newBatchedClient(t, WithBatchedWrites(5*time.Millisecond, 0))
+	c.mu.Lock()
+	store.saveErr = saveErr
+	c.mu.Unlock()
+	if _, err := c.CreateUser(ctx, "a@example.com", "123456", "a", 18); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		return c.batch.err != nil
+	})
+	if _, err := c.CreateUser(ctx, "b@example.com", "123456", "b", 18); !errors.Is(err, saveErr) {
+		t.Fatalf("CreateUser() after a failed background save = %v, expected %v", err, saveErr)
+	}
+	c.mu.Lock()
+	store.saveErr = nil
+	c.mu.Unlock()
+	if err := c.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.db.Users["a@example.com"]; !ok {
+		t.Errorf("write from before the failed save was lost")
+	}
+
+	// with a handler it gets the error instead
+	errs := make(chan error, 10)
+	c, store, _ = newBatchedClient(t, WithBatchedWrites(5*time.Millisecond, 0),
+		WithFlushErrorHandler(func(err error) { errs <- err }))
+	c.mu.Lock()
+	store.saveErr = saveErr
+	c.mu.Unlock()
+	if _, err := c.CreateUser(ctx, "a@example.com", "123456", "a", 18); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if !errors.Is(err, saveErr) {
+			t.Errorf("handler got %v, expected %v", err, saveErr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler wasn't called")
+	}
+	if _, err := c.CreateUser(ctx, "b@example.com", "123456", "b", 18); err != nil {
+		t.Errorf("CreateUser() = %v, expected the handler to have taken the error", err)
+	}
+	c.mu.Lock()
+	store.saveErr = nil
+	c.mu.Unlock()
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// blockedEither -
+// whether either of a and b blocked the other, what hides one's content from the other
+func (db *Schema) blockedEither(a, b string) bool {
+	return db.Blocks.has(a, b) || db.Blocks.has(b, a)
+}
+
+// BlockUser -
+// same as Client.BlockUser, inside the Tx
+func (tx *Tx) BlockUser(ctx context.Context, blocker, email string) error {
+	_, err := tx.blockUser(blocker, email)
+	return err
+}
+
+// blockUser -
+// BlockUser that also says whether anything changed
+func (tx *Tx) blockUser(blocker, email string) (bool, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return false, err
+	}
+	blocker, email = EmailKey(blocker), EmailKey(email)
+	if blocker == email {
+		return false, fmt.Errorf("%w: %s", ErrSelfBlock, blocker)
+	}
+	for _, e := range []string{blocker, email} {
+		if _, ok := db.activeUser(e); !ok {
+			return false, fmt.Errorf("%w: %s", ErrUserNotFound, e)
+		}
+	}
+	if db.Blocks.has(blocker, email) {
+		return false, nil
+	}
+	db.Blocks.put(blocker, email, tx.now())
+	db.unrelate(blocker, email)
+	return true, nil
+}
+
+// unrelate -
+// what a block between a and b ends: they stop following each other, and any follow request,
+// friend request or friendship between them is dropped
+func (db *Schema) unrelate(a, b string) {
+	db.deleteFollow(a, b)
+	db.deleteFollow(b, a)
+	db.FollowRequests.delete(a, b)
+	db.FollowRequests.delete(b, a)
+	delete(db.FriendRequests, friendKey(a, b))
+}
+
+// UnblockUser -
+// same as Client.UnblockUser, inside the Tx
+func (tx *Tx) UnblockUser(ctx context.Context, blocker, email string) error {
+	_, err := tx.unblockUser(blocker, email)
+	return err
+}
+
+// unblockUser -
+// UnblockUser that also says whether anything changed
+func (tx *Tx) unblockUser(blocker, email string) (bool, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return false, err
+	}
+	blocker, email = EmailKey(blocker), EmailKey(email)
+	if _, ok := db.activeUser(blocker); !ok {
+		return false, fmt.Errorf("%w: %s", ErrUserNotFound, blocker)
+	}
+	if !db.Blocks.has(blocker, email) {
+		return false, nil
+	}
+	db.Blocks.delete(blocker, email)
+	return true, nil
+}
+
+// BlockUser -
+// have blocker block the user with email: neither sees the other's posts in GetPostsAs and
+// IteratePosts, or the other in SearchUsers, when passed as the viewer, and any follows, follow
+// requests, friend request or friendship between them are dropped. blocking twice is a no-op.
+// ErrSelfBlock if they're the same user, ErrUserNotFound if either doesn't exist
+func (c *Client) BlockUser(ctx context.Context, blocker, email string) error {
+	return c.update(ctx, "BlockUser", blocker, func(db *Schema) error {
+		changed, err := c.newTx(db).blockUser(blocker, email)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+}
+
+// UnblockUser -
+// undo BlockUser, a no-op if blocker hadn't blocked the user with email.
+// ErrUserNotFound if there's no blocker
+func (c *Client) UnblockUser(ctx context.Context, blocker, email string) error {
+	return c.deleteUpdate(ctx, "UnblockUser", blocker, func(db *Schema) error {
+		changed, err := c.newTx(db).unblockUser(blocker, email)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+}
+
+// IsBlocked -
+// whether blocker blocked the user with email, only that direction
+func (c *Client) IsBlocked(ctx context.Context, blocker, email string) (bool, error) {
+	blocked := false
+	err := c.view(ctx, "IsBlocked", blocker, func(db *Schema) error {
+		blocked = db.Blocks.has(EmailKey(blocker), EmailKey(email))
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return blocked, nil
+}
+
+// GetBlockedUsers -
+// the users blocker blocked, oldest first like GetUsers. soft-deleted ones are left out
+// until they're restored. ErrUserNotFound if there's no blocker
+func (c *Client) GetBlockedUsers(ctx context.Context, blocker string) ([]User, error) {
+	users := []User{}
+	err := c.view(ctx, "GetBlockedUsers", blocker, func(db *Schema) error {
+		blocker := EmailKey(blocker)
+		if _, ok := db.activeUser(blocker); !ok {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, blocker)
+		}
+		for email := range db.Blocks[blocker] {
+			if user, ok := db.activeUser(email); ok {
+				users = append(users, c.sanitize(user))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []User{}, err
+	}
+	sortUsers(users)
+	return users, nil
+}
+
+// GetPostsAs -
+// GetPosts of userEmail as seen by viewer, "" for someone logged out. none if either blocked
+// the other, or if userEmail is private and viewer isn't it or one of its followers, and only
+// the posts whose Visibility lets viewer read them
+func (c *Client) GetPostsAs(ctx context.Context, viewer, userEmail string) ([]Post, error) {
+	posts := []Post{}
+	err := c.view(ctx, "GetPostsAs", userEmail, func(db *Schema) error {
+		var err error
+		posts, err = c.newTx(db).GetPostsAs(ctx, viewer, userEmail)
+		return err
+	})
+	if err != nil {
+		return []Post{}, err
+	}
+	return posts, nil
+}
@@ -0,0 +1,242 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// newBlockClient has a@, b@ and c@example.com with a post each
+func newBlockClient(t *testing.T, opts ...Option) *Client {
+	t.Helper()
+	c := newTestClient(t, opts...)
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "123456", "name "+email[:1], 18); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.CreatePost(ctx, email, "hello"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+func TestBlockUser(t *testing.T) {
+	c := newBlockClient(t)
+	if _, err := c.SoftDeleteUser(ctx, "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		blocker  string
+		email    string
+		expected error
+	}{
+		{blocker: "a@example.com", email: "b@example.com", expected: nil},
+		// again is a no-op
+		{blocker: "A@Example.com", email: "B@example.com", expected: nil},
+		{blocker: "a@example.com", email: " A@example.com", expected: ErrSelfBlock},
+		{blocker: "a@example.com", email: "missing@example.com", expected: ErrUserNotFound},
+		{blocker: "missing@example.com", email: "a@example.com", expected: ErrUserNotFound},
+		{blocker: "a@example.com", email: "c@example.com", expected: ErrUserNotFound},
+		{blocker: "c@example.com", email: "a@example.com", expected: ErrUserNotFound},
+	}
+	for _, test := range tests {
+		if err := c.BlockUser(ctx, test.blocker, test.email); !errors.Is(err, test.expected) {
+			t.Errorf("BlockUser(%q, %q) = %v, expected %v", test.blocker, test.email, err, test.expected)
+		}
+	}
+
+	db, err := c.Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.Blocks) != 1 || len(db.Blocks["a@example.com"]) != 1 || !db.Blocks.has("a@example.com", "b@example.com") {
+		t.Errorf("blocks after BlockUser() = %v, expected a@ blocking b@", db.Blocks)
+	}
+
+	// only the one direction
+	var isBlocked = []struct {
+		blocker  string
+		email    string
+		expected bool
+	}{
+		{blocker: "a@example.com", email: "b@example.com", expected: true},
+		{blocker: "A@EXAMPLE.COM", email: "b@Example.com", expected: true},
+		{blocker: "b@example.com", email: "a@example.com", expected: false},
+		{blocker: "a@example.com", email: "c@example.com", expected: false},
+		{blocker: "missing@example.com", email: "a@example.com", expected: false},
+	}
+	for _, test := range isBlocked {
+		if got, err := c.IsBlocked(ctx, test.blocker, test.email); err != nil || got != test.expected {
+			t.Errorf("IsBlocked(%q, %q) = %v, %v, expected %v", test.blocker, test.email, got, err, test.expected)
+		}
+	}
+}
+
+func TestUnblockUser(t *testing.T) {
+	c := newBlockClient(t)
+	if err := c.BlockUser(ctx, "a@example.com", "b@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UnblockUser(ctx, "missing@example.com", "b@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("UnblockUser() by a missing user = %v, expected ErrUserNotFound", err)
+	}
+	// the other direction was never blocked
+	if err := c.UnblockUser(ctx, "b@example.com", "a@example.com"); err != nil {
+		t.Errorf("UnblockUser() of a user not blocked = %v, expected nil", err)
+	}
+	if blocked, _ := c.IsBlocked(ctx, "a@example.com", "b@example.com"); !blocked {
+		t.Error("UnblockUser() of the other direction unblocked a@ blocking b@")
+	}
+
+	if err := c.UnblockUser(ctx, "A@example.com", "B@example.com"); err != nil {
+		t.Fatalf("UnblockUser() = %v, expected nil", err)
+	}
+	if blocked, _ := c.IsBlocked(ctx, "a@example.com", "b@example.com"); blocked {
+		t.Error("IsBlocked() after UnblockUser() = true, expected false")
+	}
+	if db, _ := c.Dump(ctx); len(db.Blocks) != 0 {
+		t.Errorf("blocks after UnblockUser() = %v, expected none", db.Blocks)
+	}
+}
+
+func TestGetBlockedUsers(t *testing.T) {
+	c := newBlockClient(t)
+	for _, email := range []string{"c@example.com", "b@example.com"} {
+		if err := c.BlockUser(ctx, "a@example.com", email); err != nil {
+			t.Fatal(err)
+		}
+	}
+	users, err := c.GetBlockedUsers(ctx, "a@example.com")
+	if err != nil || !reflect.DeepEqual(emails(users), []string{"b@example.com", "c@example.com"}) {
+		t.Errorf("GetBlockedUsers() = %v, %v, expected b@ and c@", emails(users), err)
+	}
+	for _, user := range users {
+		if user.PasswordHash != "" {
+			t.Errorf("GetBlockedUsers() returned PasswordHash %q for %s, expected it empty", user.PasswordHash, user.Email)
+		}
+	}
+
+	// soft-deleted users are hidden until restored
+	if _, err := c.SoftDeleteUser(ctx, "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if users, err := c.GetBlockedUsers(ctx, "a@example.com"); err != nil || !reflect.DeepEqual(emails(users), []string{"b@example.com"}) {
+		t.Errorf("GetBlockedUsers() after SoftDeleteUser() = %v, %v, expected b@", emails(users), err)
+	}
+	if _, err := c.RestoreUser(ctx, "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if users, err := c.GetBlockedUsers(ctx, "a@example.com"); err != nil || len(users) != 2 {
+		t.Errorf("GetBlockedUsers() after RestoreUser() = %v, %v, expected b@ and c@", emails(users), err)
+	}
+
+	if users, err := c.GetBlockedUsers(ctx, "b@example.com"); err != nil || len(users) != 0 {
+		t.Errorf("GetBlockedUsers() of a user who blocked nobody = %v, %v, expected none", emails(users), err)
+	}
+	if _, err := c.GetBlockedUsers(ctx, "missing@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetBlockedUsers() of a missing user = %v, expected ErrUserNotFound", err)
+	}
+}
+
+func TestBlockFiltering(t *testing.T) {
+	c := newBlockClient(t)
+	if err := c.BlockUser(ctx, "a@example.com", "b@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// whoever blocked whom, a@ and b@ don't see each other, c@ sees both
+	var tests = []struct {
+		viewer   string
+		author   string
+		expected int
+	}{
+		{viewer: "a@example.com", author: "b@example.com", expected: 0},
+		{viewer: "b@example.com", author: "a@example.com", expected: 0},
+		{viewer: "B@Example.com", author: "a@example.com", expected: 0},
+		{viewer: "a@example.com", author: "c@example.com", expected: 1},
+		{viewer: "c@example.com", author: "a@example.com", expected: 1},
+		{viewer: "c@example.com", author: "b@example.com", expected: 1},
+		{viewer: "a@example.com", author: "a@example.com", expected: 1},
+		{viewer: "", author: "b@example.com", expected: 1},
+	}
+	for _, test := range tests {
+		posts, err := c.GetPostsAs(ctx, test.viewer, test.author)
+		if err != nil || len(posts) != test.expected {
+			t.Errorf("GetPostsAs(%q, %q) = %d posts, %v, expected %d", test.viewer, test.author, len(posts), err, test.expected)
+		}
+
+		n := 0
+		err = c.IteratePosts(ctx, IterateOptions{UserEmail: test.author, Viewer: test.viewer}, func(Post) bool {
+			n++
+			return true
+		})
+		if err != nil || n != test.expected {
+			t.Errorf("IteratePosts(%q, Viewer: %q) = %d posts, %v, expected %d", test.author, test.viewer, n, err, test.expected)
+		}
+
+		users, err := c.SearchUsers(ctx, test.author, SearchOptions{Viewer: test.viewer})
+		if err != nil || len(users) != test.expected {
+			t.Errorf("SearchUsers(%q, Viewer: %q) = %v, %v, expected %d users", test.author, test.viewer, emails(users), err, test.expected)
+		}
+	}
+}
+
+func TestDeleteUserDropsBlocks(t *testing.T) {
+	for name, opts := range map[string][]Option{"file": nil, "wal": {WithWAL(100)}} {
+		c := newBlockClient(t, opts...)
+		for _, block := range [][2]string{{"a@example.com", "b@example.com"}, {"b@example.com", "c@example.com"}, {"c@example.com", "a@example.com"}} {
+			if err := c.BlockUser(ctx, block[0], block[1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := c.DeleteUser(ctx, "a@example.com", DeleteUserOptions{}); err != nil {
+			t.Fatal(err)
+		}
+
+		// both directions went with a@, b@ blocking c@ stays
+		reopened := NewClient(dbPath(c), opts...)
+		db, err := reopened.Dump(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(db.Blocks) != 1 || len(db.Blocks["b@example.com"]) != 1 || !db.Blocks.has("b@example.com", "c@example.com") {
+			t.Errorf("%s: blocks after DeleteUser() = %v, expected only b@ blocking c@", name, db.Blocks)
+		}
+
+		// a new account under the same email starts with no blocks
+		if _, err := c.CreateUser(ctx, "a@example.com", "123456", "name a", 18); err != nil {
+			t.Fatal(err)
+		}
+		if blocked, _ := c.IsBlocked(ctx, "c@example.com", "a@example.com"); blocked {
+			t.Errorf("%s: IsBlocked() of a recreated user = true, expected false", name)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestChangeEmailMovesBlocks(t *testing.T) {
+	c := newBlockClient(t)
+	for _, block := range [][2]string{{"a@example.com", "b@example.com"}, {"c@example.com", "a@example.com"}} {
+		if err := c.BlockUser(ctx, block[0], block[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.ChangeEmail(ctx, "a@example.com", "new@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	db, err := c.Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]string{"new@example.com": {"b@example.com"}, "c@example.com": {"new@example.com"}}
+	got := map[string][]string{}
+	for blocker, blocked := range db.Blocks {
+		got[blocker] = sortedKeys(blocked)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("blocks after ChangeEmail() = %v, expected %v", got, expected)
+	}
+}
@@ -0,0 +1,438 @@
+// Package bolt is a bbolt backed implementation of database.Repository,
+// an embedded key/value store that needs no cgo and only writes what changed
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Warren-Wang-OG/go-social-media-backend/database"
+	"github.com/google/uuid"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// buckets, records are stored as json values
+// userPosts keys are userEmail + "\x00" + postID with empty values so a user's posts
+// are one prefix scan away
+var (
+	usersBucket     = []byte("users")
+	postsBucket     = []byte("posts")
+	userPostsBucket = []byte("user_posts")
+)
+
+// Client -
+// same operations as database.Client, stored in a bbolt file
+type Client struct {
+	db *bbolt.DB
+}
+
+var _ database.Repository = (*Client)(nil)
+
+// NewClient -
+// open (or create) the bolt file at path, call EnsureDB before using it
+// bbolt allows a single process at a time, waits up to 5 seconds for another one to let go
+func NewClient(path string) (*Client, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: 5 * time.Second})
+	if errors.Is(err, bbolt.ErrTimeout) {
+		return nil, fmt.Errorf("%w: %s", database.ErrDatabaseLocked, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Client{db: db}, nil
+}
+
+// Close -
+// close the bolt file and release its lock
+func (c *Client) Close() error {
+	return c.db.Close()
+}
+
+// EnsureDB -
+// create the buckets if they don't exist yet
+func (c *Client) EnsureDB(ctx context.Context) error {
+	return c.update(ctx, func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{usersBucket, postsBucket, userPostsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CreateUser -
+// email needs to be unique for each user, returns database.ErrUserExists if it's already taken
+// the password has to satisfy database.DefaultPasswordPolicy like the json Client's, a *database.PasswordError otherwise
+// and the age must be database.DefaultMinimumAge to database.MaxAge, database.ErrInvalidAge otherwise
+// the user is returned without PasswordHash, see VerifyPassword
+func (c *Client) CreateUser(ctx context.Context, email, password, name string, age int) (database.User, error) {
+	email, err := database.NormalizeEmail(email)
+	if err != nil {
+		return database.User{}, err
+	}
+	if err := database.CheckAge(age, database.DefaultMinimumAge); err != nil {
+		return database.User{}, err
+	}
+	if err := database.DefaultPasswordPolicy.Check(password); err != nil {
+		return database.User{}, err
+	}
+	hash, err := database.HashPassword(password, database.DefaultPasswordCost)
+	if err != nil {
+		return database.User{}, err
+	}
+	now := time.Now().UTC()
+	user := database.User{
+		CreatedAt:    now,
+		Email:        email,
+		PasswordHash: hash,
+		Name:         name,
+		Age:          age,
+		UpdatedAt:    now,
+	}
+	err = c.update(ctx, func(tx *bbolt.Tx) error {
+		if tx.Bucket(usersBucket).Get([]byte(email)) != nil {
+			return fmt.Errorf("%w: %s", database.ErrUserExists, email)
+		}
+		return putUser(tx, user)
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return sanitize(user), nil
+}
+
+// GetUser -
+// return user given the email, without PasswordHash
+func (c *Client) GetUser(ctx context.Context, email string) (database.User, error) {
+	email = database.EmailKey(email)
+	user := database.User{}
+	err := c.view(ctx, func(tx *bbolt.Tx) error {
+		var err error
+		user, err = getUser(tx, email)
+		return err
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return sanitize(user), nil
+}
+
+// VerifyPassword -
+// nil if password is the user's, database.ErrWrongPassword if not and database.ErrUserNotFound
+// if there's no such user, the stored hash is never returned so this is the way to check it
+func (c *Client) VerifyPassword(ctx context.Context, email, password string) error {
+	email = database.EmailKey(email)
+	user := database.User{}
+	err := c.view(ctx, func(tx *bbolt.Tx) error {
+		var err error
+		user, err = getUser(tx, email)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if !database.CheckPassword(user.PasswordHash, password) {
+		return fmt.Errorf("%w: %s", database.ErrWrongPassword, email)
+	}
+	return nil
+}
+
+// UpdateUser -
+// change password, name and age of an existing user, email and CreatedAt don't change
+// the new password and age are checked and the user returned like CreateUser's
+func (c *Client) UpdateUser(ctx context.Context, email, password, name string, age int) (database.User, error) {
+	email, err := database.NormalizeEmail(email)
+	if err != nil {
+		return database.User{}, err
+	}
+	if err := database.CheckAge(age, database.DefaultMinimumAge); err != nil {
+		return database.User{}, err
+	}
+	if err := database.DefaultPasswordPolicy.Check(password); err != nil {
+		return database.User{}, err
+	}
+	hash, err := database.HashPassword(password, database.DefaultPasswordCost)
+	if err != nil {
+		return database.User{}, err
+	}
+	user := database.User{}
+	err = c.update(ctx, func(tx *bbolt.Tx) error {
+		var err error
+		user, err = getUser(tx, email)
+		if err != nil {
+			return err
+		}
+		user.PasswordHash = hash
+		user.Name = name
+		user.Age = age
+		user.UpdatedAt = time.Now().UTC()
+		return putUser(tx, user)
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return sanitize(user), nil
+}
+
+// DeleteUser -
+// delete a user and handle their posts according to opts, in one transaction
+func (c *Client) DeleteUser(ctx context.Context, email string, opts database.DeleteUserOptions) (database.DeleteUserResult, error) {
+	email = database.EmailKey(email)
+	result := database.DeleteUserResult{}
+	err := c.update(ctx, func(tx *bbolt.Tx) error {
+		users := tx.Bucket(usersBucket)
+		if users.Get([]byte(email)) == nil {
+			if opts.IgnoreMissing {
+				return nil
+			}
+			return fmt.Errorf("%w: %s", database.ErrUserNotFound, email)
+		}
+		result.Deleted = true
+
+		ids := postIDs(tx, email)
+		result.Posts = len(ids)
+		for _, id := range ids {
+			var err error
+			switch opts.Posts {
+			case database.PostsCascade:
+				err = deletePost(tx, email, id)
+			case database.PostsAnonymize:
+				err = reassignPost(tx, email, database.DeletedUserEmail, id)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return users.Delete([]byte(email))
+	})
+	if err != nil {
+		return database.DeleteUserResult{}, err
+	}
+	return result, nil
+}
+
+// CreatePost -
+// create a post authored by an existing user
+// the text is trimmed and checked like the json Client's, database.ErrEmptyPost or database.ErrPostTooLong otherwise
+func (c *Client) CreatePost(ctx context.Context, userEmail, text string) (database.Post, error) {
+	userEmail = database.EmailKey(userEmail)
+	text, err := database.PostText(text, database.DefaultMaxPostLength)
+	if err != nil {
+		return database.Post{}, err
+	}
+	post := database.Post{
+		ID:        uuid.New().String(),
+		CreatedAt: time.Now().UTC(),
+		UserEmail: userEmail,
+		Text:      text,
+	}
+	err = c.update(ctx, func(tx *bbolt.Tx) error {
+		if _, err := getUser(tx, userEmail); err != nil {
+			return err
+		}
+		return putPost(tx, post)
+	})
+	if err != nil {
+		return database.Post{}, err
+	}
+	return post, nil
+}
+
+// GetPosts -
+// return all posts of a specific user identified by their userEmail, newest first like database.SortPosts
+func (c *Client) GetPosts(ctx context.Context, userEmail string) ([]database.Post, error) {
+	userEmail = database.EmailKey(userEmail)
+	posts := []database.Post{}
+	err := c.view(ctx, func(tx *bbolt.Tx) error {
+		for _, id := range postIDs(tx, userEmail) {
+			post, err := getPost(tx, id)
+			if err != nil {
+				return err
+			}
+			posts = append(posts, post)
+		}
+		return nil
+	})
+	if err != nil {
+		return []database.Post{}, err
+	}
+	database.SortPosts(posts, database.NewestFirst)
+	return posts, nil
+}
+
+// DeletePost -
+// delete a single post identified by the id, returns the removed post
+func (c *Client) DeletePost(ctx context.Context, id string) (database.Post, error) {
+	if id == "" {
+		return database.Post{}, database.ErrEmptyPostID
+	}
+	post := database.Post{}
+	err := c.update(ctx, func(tx *bbolt.Tx) error {
+		var err error
+		post, err = getPost(tx, id)
+		if err != nil {
+			return err
+		}
+		return deletePost(tx, post.UserEmail, id)
+	})
+	if err != nil {
+		return database.Post{}, err
+	}
+	return post, nil
+}
+
+// ImportJSON -
+// one-shot conversion of an existing json db file into this database
+// opts are the ones the json file was written with (compression, encryption key)
+func (c *Client) ImportJSON(ctx context.Context, path string, opts ...database.Option) error {
+	db, err := database.LoadFile(ctx, path, opts...)
+	if err != nil {
+		return err
+	}
+	return c.ImportSchema(ctx, db)
+}
+
+// ImportSchema -
+// copy the users and posts of a json database into this one in a single transaction
+// only database.PublicRecords are copied, GetPosts here has no drafts or visibility to check
+// fails without importing anything if a user already exists
+func (c *Client) ImportSchema(ctx context.Context, db database.Schema) error {
+	db = database.PublicRecords(db, time.Now().UTC())
+	return c.update(ctx, func(tx *bbolt.Tx) error {
+		for email, user := range db.Users {
+			if tx.Bucket(usersBucket).Get([]byte(email)) != nil {
+				return fmt.Errorf("%w: %s", database.ErrUserExists, email)
+			}
+			if err := putUser(tx, user); err != nil {
+				return err
+			}
+		}
+		for _, post := range db.Posts {
+			if err := putPost(tx, post); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// update runs fn in a read-write transaction, bbolt rolls back if fn returns an error
+// ctx is checked before starting and again before committing
+func (c *Client) update(ctx context.Context, fn func(tx *bbolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		return ctx.Err()
+	})
+}
+
+// view runs fn in a read-only transaction
+func (c *Client) view(ctx context.Context, fn func(tx *bbolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.db.View(fn)
+}
+
+func getUser(tx *bbolt.Tx, email string) (database.User, error) {
+	data := tx.Bucket(usersBucket).Get([]byte(email))
+	if data == nil {
+		return database.User{}, fmt.Errorf("%w: %s", database.ErrUserNotFound, email)
+	}
+	user := database.User{}
+	if err := json.Unmarshal(data, &user); err != nil {
+		return database.User{}, fmt.Errorf("%w: user %s: %v", database.ErrDBCorrupt, email, err)
+	}
+	// stored before UpdatedAt existed
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = user.CreatedAt
+	}
+	return user, nil
+}
+
+// sanitize is user as the reads return it, without PasswordHash like the json Client's
+func sanitize(user database.User) database.User {
+	user.PasswordHash = ""
+	return user
+}
+
+// storedUser is a database.User without its MarshalJSON, which leaves the password out
+type storedUser database.User
+
+func putUser(tx *bbolt.Tx, user database.User) error {
+	data, err := json.Marshal(storedUser(user))
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(usersBucket).Put([]byte(user.Email), data)
+}
+
+func getPost(tx *bbolt.Tx, id string) (database.Post, error) {
+	data := tx.Bucket(postsBucket).Get([]byte(id))
+	if data == nil {
+		return database.Post{}, fmt.Errorf("%w: %s", database.ErrPostNotFound, id)
+	}
+	post := database.Post{}
+	if err := json.Unmarshal(data, &post); err != nil {
+		return database.Post{}, fmt.Errorf("%w: post %s: %v", database.ErrDBCorrupt, id, err)
+	}
+	return post, nil
+}
+
+// putPost writes the post and its index entry
+func putPost(tx *bbolt.Tx, post database.Post) error {
+	data, err := json.Marshal(post)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(postsBucket).Put([]byte(post.ID), data); err != nil {
+		return err
+	}
+	return tx.Bucket(userPostsBucket).Put(indexKey(post.UserEmail, post.ID), nil)
+}
+
+// deletePost removes the post and its index entry
+func deletePost(tx *bbolt.Tx, userEmail, id string) error {
+	if err := tx.Bucket(postsBucket).Delete([]byte(id)); err != nil {
+		return err
+	}
+	return tx.Bucket(userPostsBucket).Delete(indexKey(userEmail, id))
+}
+
+// reassignPost moves a post to another author, index included
+func reassignPost(tx *bbolt.Tx, from, to, id string) error {
+	post, err := getPost(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(userPostsBucket).Delete(indexKey(from, id)); err != nil {
+		return err
+	}
+	post.UserEmail = to
+	return putPost(tx, post)
+}
+
+// postIDs returns the ids of every post by userEmail using the index
+func postIDs(tx *bbolt.Tx, userEmail string) []string {
+	prefix := indexKey(userEmail, "")
+	ids := []string{}
+	cursor := tx.Bucket(userPostsBucket).Cursor()
+	for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+		ids = append(ids, string(k[len(prefix):]))
+	}
+	return ids
+}
+
+// indexKey is the userPosts key for a post, the separator can't appear in an email
+func indexKey(userEmail, id string) []byte {
+	return []byte(userEmail + "\x00" + id)
+}
@@ -0,0 +1,140 @@
+package bolt
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Warren-Wang-OG/go-social-media-backend/database"
+	"github.com/Warren-Wang-OG/go-social-media-backend/database/databasetest"
+)
+
+var ctx = context.Background()
+
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	c, err := NewClient(filepath.Join(t.TempDir(), "db.bolt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestConformance(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T) database.Repository {
+		return newTestClient(t)
+	})
+}
+
+func TestImportJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	src := database.NewClient(path)
+	if err := src.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	user, err := src.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post, err := src.CreatePost(ctx, user.Email, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newTestClient(t)
+	if err := c.ImportJSON(ctx, path); err != nil {
+		t.Fatalf("ImportJSON() = %v, expected nil", err)
+	}
+
+	got, err := c.GetUser(ctx, user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != user {
+		t.Errorf("GetUser(%q) = %v, expected %v", user.Email, got, user)
+	}
+	// the hash is imported too, the reads just leave it out
+	if err := c.VerifyPassword(ctx, user.Email, "correct horse"); err != nil {
+		t.Errorf("VerifyPassword() of the imported user = %v, expected nil", err)
+	}
+	posts, err := c.GetPosts(ctx, user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || !reflect.DeepEqual(posts[0], post) {
+		t.Errorf("GetPosts(%q) = %v, expected [%v]", user.Email, posts, post)
+	}
+
+	// importing twice fails as a whole and leaves the first import alone
+	if err := c.ImportJSON(ctx, path); !errors.Is(err, database.ErrUserExists) {
+		t.Errorf("second ImportJSON() = %v, expected %v", err, database.ErrUserExists)
+	}
+	posts, err = c.GetPosts(ctx, user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 {
+		t.Errorf("GetPosts(%q) after failed import has %d posts, expected 1", user.Email, len(posts))
+	}
+}
+
+func TestImportJSONHiddenRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	src := database.NewClient(path)
+	if err := src.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{"test@example.com", "gone@example.com"} {
+		if _, err := src.CreateUser(ctx, email, "correct horse", "john doe", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	public, err := src.CreatePost(ctx, "test@example.com", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.CreateDraft(ctx, "test@example.com", "draft"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.SchedulePost(ctx, "test@example.com", "later", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.CreatePostWithOptions(ctx, "test@example.com", "private", database.CreatePostOptions{Visibility: database.VisibilityPrivate}); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := src.CreatePost(ctx, "test@example.com", "deleted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.SoftDeletePost(ctx, deleted.ID, "test@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.CreatePost(ctx, "gone@example.com", "gone"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.SoftDeleteUser(ctx, "gone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// only what the json client shows someone logged out comes across, the rest would be public here
+	c := newTestClient(t)
+	if err := c.ImportJSON(ctx, path); err != nil {
+		t.Fatalf("ImportJSON() = %v, expected nil", err)
+	}
+	if posts, err := c.GetPosts(ctx, "test@example.com"); err != nil || len(posts) != 1 || posts[0].ID != public.ID {
+		t.Errorf("GetPosts() after the import = %v, %v, expected only %q", posts, err, public.Text)
+	}
+	if _, err := c.GetUser(ctx, "gone@example.com"); !errors.Is(err, database.ErrUserNotFound) {
+		t.Errorf("GetUser() of a soft-deleted user = %v, expected %v", err, database.ErrUserNotFound)
+	}
+	if posts, err := c.GetPosts(ctx, "gone@example.com"); err != nil || len(posts) != 0 {
+		t.Errorf("GetPosts() of a soft-deleted user = %v, %v, expected none", posts, err)
+	}
+}
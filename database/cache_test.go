@@ -0,0 +1,121 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadsServedFromMemory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	reader := NewClient(path)
+	if err := reader.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	writer := NewClient(path)
+	if _, err := writer.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+
+	// reader still has its own copy until it reloads
+	if _, err := reader.GetUser(ctx, "test@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUser() before Reload = %v, expected %v", err, ErrUserNotFound)
+	}
+	if err := reader.Reload(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.GetUser(ctx, "test@example.com"); err != nil {
+		t.Errorf("GetUser() after Reload = %v, expected nil", err)
+	}
+}
+
+func TestWritePicksUpOtherProcessChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	first := NewClient(path)
+	second := NewClient(path)
+	if err := first.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// both clients have loaded the file
+	if _, err := second.GetUser(ctx, "nobody@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatal(err)
+	}
+
+	if _, err := first.CreateUser(ctx, "first@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	// second's write must start from the file first wrote, not its stale copy
+	if _, err := second.CreateUser(ctx, "second@example.com", "12345", "jane doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{"first@example.com", "second@example.com"} {
+		if _, err := NewClient(path).GetUser(ctx, email); err != nil {
+			t.Errorf("GetUser(%s) = %v, expected nil", email, err)
+		}
+	}
+}
+
+func TestFailedUpdateLeavesMemoryUntouched(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	err := c.update(ctx, "test", "", func(db *Schema) error {
+		delete(db.Users, "test@example.com")
+		return errors.New("changed my mind")
+	})
+	if err == nil {
+		t.Fatal("update() = nil, expected error")
+	}
+	if _, err := c.GetUser(ctx, "test@example.com"); err != nil {
+		t.Errorf("GetUser() after failed update = %v, expected nil", err)
+	}
+}
+
+// seedLargeDB writes a db with one user and n posts directly, without going through the client
+func seedLargeDB(b *testing.B, n int) *Client {
+	b.Helper()
+	c := NewClient(filepath.Join(b.TempDir(), "db.json"))
+	if err := c.EnsureDB(ctx); err != nil {
+		b.Fatal(err)
+	}
+	db := Schema{
+		Users: map[string]User{"test@example.com": {Email: "test@example.com"}},
+		Posts: make(map[string]Post, n),
+	}
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("post-%d", i)
+		db.Posts[id] = Post{ID: id, UserEmail: "test@example.com", Text: "my cat is way too fat"}
+	}
+	if err := c.store.Save(ctx, db); err != nil {
+		b.Fatal(err)
+	}
+	// fresh client so nothing is cached yet
+	return NewClient(dbPath(c))
+}
+
+// BenchmarkGetUser serves reads from the in-memory copy
+func BenchmarkGetUser(b *testing.B) {
+	c := seedLargeDB(b, 100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.GetUser(ctx, "test@example.com"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGetUserFromDisk re-reads the whole file for every call, like the client used to
+func BenchmarkGetUserFromDisk(b *testing.B) {
+	c := seedLargeDB(b, 100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.Reload(ctx); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := c.GetUser(ctx, "test@example.com"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
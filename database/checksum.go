@@ -0,0 +1,53 @@
+package database
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// checksums are written as the algorithm name followed by the hex digest
+const checksumPrefix = "sha256:"
+
+// checksumOf returns the checksum stored for data
+func checksumOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return checksumPrefix + hex.EncodeToString(sum[:])
+}
+
+// wrapChecksum -
+// put the db json in an envelope with the checksum of its exact bytes:
+// {"checksum":"sha256:...","data":{...}}
+// data has to be indented with indent as prefix for an indented envelope to line up
+func wrapChecksum(data []byte, indent string) []byte {
+	buf := bytes.Buffer{}
+	if indent == "" {
+		fmt.Fprintf(&buf, `{"checksum":%q,"data":`, checksumOf(data))
+		buf.Write(data)
+		buf.WriteString("}")
+		return buf.Bytes()
+	}
+	fmt.Fprintf(&buf, "{\n%s\"checksum\": %q,\n%s\"data\": ", indent, checksumOf(data), indent)
+	buf.Write(data)
+	buf.WriteString("\n}")
+	return buf.Bytes()
+}
+
+// unwrapChecksum -
+// verify the envelope's checksum against its data and return the data
+// ErrChecksumMismatch if they don't match, the file was damaged or edited by hand
+func unwrapChecksum(checksum, data json.RawMessage) ([]byte, error) {
+	var expected string
+	if err := json.Unmarshal(checksum, &expected); err != nil {
+		return nil, fmt.Errorf("%w: bad checksum: %v", ErrDBCorrupt, err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: checksum without data", ErrDBCorrupt)
+	}
+	if got := checksumOf(data); got != expected {
+		return nil, fmt.Errorf("%w: file says %s, data is %s", ErrChecksumMismatch, expected, got)
+	}
+	return data, nil
+}
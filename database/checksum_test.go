@@ -0,0 +1,78 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+// readPayload returns the db json inside the checksum envelope of the plain db file at path
+func readPayload(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envelope := struct {
+		Checksum string          `json:"checksum"`
+		Data     json.RawMessage `json:"data"`
+	}{}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		t.Fatalf("db file isn't a checksum envelope: %v", err)
+	}
+	if got := checksumOf(envelope.Data); got != envelope.Checksum {
+		t.Fatalf("checksum = %s, expected %s", envelope.Checksum, got)
+	}
+	return envelope.Data
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	for _, indent := range []string{"", "  "} {
+		c := NewClient(dbPath(newTestClient(t)), WithIndent(indent))
+		if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(dbPath(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// bit-rot in the data section: john doe becomes john doF
+		i := bytes.Index(data, []byte("john doe"))
+		if i < 0 {
+			t.Fatalf("user name not found in %s", data)
+		}
+		data[i+len("john do")]++
+		if err := os.WriteFile(dbPath(c), data, 0666); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = NewClient(dbPath(c)).GetUser(ctx, "test@example.com")
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("GetUser() with indent %q after a flipped byte = %v, expected %v", indent, err, ErrChecksumMismatch)
+		}
+	}
+}
+
+func TestChecksumLegacyFile(t *testing.T) {
+	legacy := `{"schemaVersion":1,"users":{"test@example.com":{"email":"test@example.com","name":"john doe","age":18}},"posts":{}}`
+	c := writeRawDB(t, legacy)
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatalf("EnsureDB() on a file without checksum = %v, expected nil", err)
+	}
+	// nothing to upgrade, the file is left alone until the next write
+	assertFileContents(t, dbPath(c), legacy)
+
+	if _, err := c.CreatePost(ctx, "test@example.com", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	db := Schema{}
+	if err := json.Unmarshal(readPayload(t, dbPath(c)), &db); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.Users) != 1 || len(db.Posts) != 1 {
+		t.Errorf("upgraded file has %d users and %d posts, expected 1 and 1", len(db.Users), len(db.Posts))
+	}
+}
@@ -0,0 +1,17 @@
+package database
+
+import "time"
+
+// Clock -
+// source of the timestamps stored in records, see WithClock
+// times are stored in UTC whatever location Now returns them in
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the system clock
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
@@ -0,0 +1,231 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock returns a fixed time that moves only when told to
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestClock(t *testing.T) {
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	clock := &fakeClock{now: start}
+	path := filepath.Join(t.TempDir(), "db.json")
+	c := NewClient(path, WithClock(clock))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	post, err := c.CreatePost(ctx, "test@example.com", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	var txUser User
+	err = c.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		txUser, err = tx.UpsertUser(ctx, "other@example.com", "123456", "other", 18)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// stored exactly, in UTC, and the same after a round trip through the file
+	stored, err := NewClient(path).Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		name     string
+		got      time.Time
+		expected time.Time
+	}{
+		{name: "CreateUser", got: user.CreatedAt, expected: start.UTC()},
+		{name: "stored user", got: stored.Users["test@example.com"].CreatedAt, expected: start.UTC()},
+		{name: "CreatePost", got: post.CreatedAt, expected: start.Add(time.Minute).UTC()},
+		{name: "stored post", got: stored.Posts[post.ID].CreatedAt, expected: start.Add(time.Minute).UTC()},
+		{name: "Tx.UpsertUser", got: txUser.CreatedAt, expected: start.Add(2 * time.Minute).UTC()},
+	}
+	for _, test := range tests {
+		if test.got != test.expected {
+			t.Errorf("%s CreatedAt = %v, expected %v", test.name, test.got, test.expected)
+		}
+	}
+}
+
+func TestCreateAt(t *testing.T) {
+	c := newTestClient(t)
+	at := time.Date(2010, 1, 2, 3, 4, 5, 6, time.FixedZone("EST", -5*60*60))
+	user, err := c.CreateUserAt(ctx, "test@example.com", "123456", "john doe", 18, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post, err := c.CreatePostAt(ctx, "test@example.com", "hello", at.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.CreatedAt != at.UTC() || post.CreatedAt != at.Add(time.Hour).UTC() {
+		t.Errorf("CreatedAt = %v and %v, expected %v and %v", user.CreatedAt, post.CreatedAt, at.UTC(), at.Add(time.Hour).UTC())
+	}
+	got, err := c.GetUser(ctx, "test@example.com")
+	if err != nil || got != user {
+		t.Errorf("GetUser() = %+v, %v, expected %+v", got, err, user)
+	}
+	if _, err := c.CreateUserAt(ctx, "test@example.com", "123456", "john doe", 18, at); !errors.Is(err, ErrUserExists) {
+		t.Errorf("CreateUserAt() with a taken email = %v, expected ErrUserExists", err)
+	}
+}
+
+func TestUpdatedAt(t *testing.T) {
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	c := NewClient(filepath.Join(t.TempDir(), "db.json"), WithClock(clock))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	user, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.UpdatedAt != start {
+		t.Errorf("CreateUser() UpdatedAt = %v, expected %v", user.UpdatedAt, start)
+	}
+
+	// none of these change the user
+	clock.Advance(time.Minute)
+	if _, err := c.GetUser(ctx, "test@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AuthenticateUser(ctx, "test@example.com", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreatePost(ctx, "test@example.com", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreatePasswordResetToken(ctx, "test@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	name := "john doe"
+	if _, err := c.UpdateUserFields(ctx, "test@example.com", UserUpdate{Name: &name}); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := c.GetUser(ctx, "test@example.com"); user.UpdatedAt != start {
+		t.Errorf("UpdatedAt after reads = %v, expected %v", user.UpdatedAt, start)
+	}
+
+	// each of these does, at the clock's time
+	age := 30
+	password := "battery staple"
+	writes := []struct {
+		name  string
+		write func() error
+	}{
+		{name: "UpdateUser", write: func() error {
+			_, err := c.UpdateUser(ctx, "test@example.com", "correct horse", "jane doe", 20)
+			return err
+		}},
+		{name: "UpdateUserFields", write: func() error {
+			_, err := c.UpdateUserFields(ctx, "test@example.com", UserUpdate{Age: &age})
+			return err
+		}},
+		{name: "UpdateProfile", write: func() error {
+			_, err := c.UpdateProfile(ctx, "test@example.com", Profile{Bio: &password})
+			return err
+		}},
+		{name: "SetUsername", write: func() error {
+			_, err := c.SetUsername(ctx, "test@example.com", "jane")
+			return err
+		}},
+		{name: "ChangePassword", write: func() error {
+			return c.ChangePassword(ctx, "test@example.com", "correct horse", password)
+		}},
+		{name: "RedeemPasswordResetToken", write: func() error {
+			token, err := c.CreatePasswordResetToken(ctx, "test@example.com")
+			if err != nil {
+				return err
+			}
+			return c.RedeemPasswordResetToken(ctx, token, "correct horse")
+		}},
+	}
+	for _, test := range writes {
+		clock.Advance(time.Minute)
+		if err := test.write(); err != nil {
+			t.Fatalf("%s() = %v", test.name, err)
+		}
+		user, err := c.GetUser(ctx, "test@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := clock.Now(); user.UpdatedAt != expected || user.CreatedAt != start {
+			t.Errorf("%s() UpdatedAt = %v, CreatedAt = %v, expected %v and %v", test.name, user.UpdatedAt, user.CreatedAt, expected, start)
+		}
+	}
+	clock.Advance(time.Minute)
+	moved, err := c.ChangeEmail(ctx, "test@example.com", "new@example.com")
+	if err != nil || moved.UpdatedAt != clock.Now() {
+		t.Errorf("ChangeEmail() UpdatedAt = %v, %v, expected %v", moved.UpdatedAt, err, clock.Now())
+	}
+}
+
+func TestUpdatedAtLegacy(t *testing.T) {
+	createdAt := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "db.json")
+	legacy := `{"schemaVersion": 1, "users": {"test@example.com": {"email": "test@example.com", "createdAt": "2022-01-02T03:04:05Z", "password": "hunter22"}}, "posts": {}}`
+	if err := os.WriteFile(path, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{now: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)}
+	c := NewClient(path, WithClock(clock))
+
+	// filled in on load
+	user, err := c.GetUser(ctx, "test@example.com")
+	if err != nil || user.UpdatedAt != createdAt {
+		t.Fatalf("GetUser() of a legacy record = %+v, %v, expected UpdatedAt %v", user, err, createdAt)
+	}
+	// and written with the next write, which for a password upgrade doesn't bump it
+	if err := c.VerifyPassword(ctx, "test@example.com", "hunter22"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"updatedAt":"2022-01-02T03:04:05Z"`) {
+		t.Errorf("db file after the first write = %s, expected updatedAt set to createdAt", data)
+	}
+
+	// a record loaded without it gets it too
+	if err := c.Load(ctx, Schema{Users: map[string]User{"test@example.com": {Email: "test@example.com", CreatedAt: createdAt}}}); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := c.GetUser(ctx, "test@example.com"); user.UpdatedAt != createdAt {
+		t.Errorf("GetUser() after Load() UpdatedAt = %v, expected %v", user.UpdatedAt, createdAt)
+	}
+}
@@ -0,0 +1,39 @@
+package database_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Warren-Wang-OG/go-social-media-backend/database"
+	"github.com/Warren-Wang-OG/go-social-media-backend/database/databasetest"
+)
+
+// the json clients are held to the policy the other backends have, the package's tests relax it
+var conformancePolicy = database.WithPasswordPolicy(database.DefaultPasswordPolicy)
+
+func TestConformance(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T) database.Repository {
+		return database.NewClient(filepath.Join(t.TempDir(), "db.json"), conformancePolicy)
+	})
+}
+
+func TestConformanceMemory(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T) database.Repository {
+		return database.NewMemoryClient(conformancePolicy)
+	})
+}
+
+func TestConformanceWAL(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T) database.Repository {
+		return database.NewClient(filepath.Join(t.TempDir(), "db.json"), database.WithWAL(3), conformancePolicy)
+	})
+}
+
+func TestConformanceBatched(t *testing.T) {
+	databasetest.Run(t, func(t *testing.T) database.Repository {
+		c := database.NewClient(filepath.Join(t.TempDir(), "db.json"), database.WithBatchedWrites(time.Millisecond, 5), conformancePolicy)
+		t.Cleanup(func() { c.Close() })
+		return c
+	})
+}
@@ -0,0 +1,43 @@
+package database
+
+import "encoding/json"
+
+// storedUser -
+// a User as stores write it, PasswordHash included. it has none of User's methods,
+// MarshalJSON in particular, so it encodes field by field
+type storedUser User
+
+// MarshalJSON -
+// the user without PasswordHash, so a User that ends up in an API response or a log never
+// carries it. the db file, the WAL and ExportNDJSON write storedUser instead and keep it
+func (u User) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		storedUser
+		// shallower than the embedded field so it wins, omitempty leaves the key out
+		PasswordHash string `json:"password,omitempty"`
+	}{storedUser: storedUser(u)})
+}
+
+// MarshalJSON -
+// the db as stored, with every user's PasswordHash, see User.MarshalJSON
+func (db Schema) MarshalJSON() ([]byte, error) {
+	// schema has Schema's fields without this method
+	type schema Schema
+	users := make(map[string]storedUser, len(db.Users))
+	for email, user := range db.Users {
+		users[email] = storedUser(user)
+	}
+	return json.Marshal(struct {
+		schema
+		Users map[string]storedUser `json:"users"`
+	}{schema: schema(db), Users: users})
+}
+
+// sanitize -
+// user as a read returns it, without PasswordHash unless the client has WithCredentials
+func (c *Client) sanitize(user User) User {
+	if !c.credentials {
+		user.PasswordHash = ""
+	}
+	return user
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadsLeaveOutPasswords(t *testing.T) {
+	c, clock := newSoftDeleteClient(t)
+	clock.Advance(time.Hour)
+
+	var tests = []struct {
+		name string
+		read func() ([]User, error)
+	}{
+		{name: "GetUser", read: func() ([]User, error) {
+			user, err := c.GetUser(ctx, "a@example.com")
+			return []User{user}, err
+		}},
+		{name: "GetUserByUsername", read: func() ([]User, error) {
+			user, err := c.GetUserByUsername(ctx, "alice")
+			return []User{user}, err
+		}},
+		{name: "GetUserIncludingDeleted", read: func() ([]User, error) {
+			user, err := c.GetUserIncludingDeleted(ctx, "b@example.com")
+			return []User{user}, err
+		}},
+		{name: "GetUsers", read: func() ([]User, error) {
+			page, err := c.GetUsers(ctx, ListOptions{})
+			return page.Users, err
+		}},
+		{name: "SearchUsers", read: func() ([]User, error) {
+			return c.SearchUsers(ctx, "example", SearchOptions{})
+		}},
+		{name: "GetInactiveUsers", read: func() ([]User, error) {
+			return c.GetInactiveUsers(ctx, clock.Now())
+		}},
+		{name: "AuthenticateUser", read: func() ([]User, error) {
+			user, err := c.AuthenticateUser(ctx, "a@example.com", "correct horse")
+			return []User{user}, err
+		}},
+		{name: "CreateUser", read: func() ([]User, error) {
+			user, err := c.CreateUser(ctx, "c@example.com", "correct horse", "name", 18)
+			return []User{user}, err
+		}},
+		{name: "UpdateUser", read: func() ([]User, error) {
+			user, err := c.UpdateUser(ctx, "c@example.com", "battery staple", "name", 18)
+			return []User{user}, err
+		}},
+		{name: "UpdateUserFields", read: func() ([]User, error) {
+			name := "renamed"
+			user, err := c.UpdateUserFields(ctx, "c@example.com", UserUpdate{Name: &name})
+			return []User{user}, err
+		}},
+		{name: "SoftDeleteUser", read: func() ([]User, error) {
+			user, err := c.SoftDeleteUser(ctx, "c@example.com")
+			return []User{user}, err
+		}},
+		{name: "RestoreUser", read: func() ([]User, error) {
+			user, err := c.RestoreUser(ctx, "c@example.com")
+			return []User{user}, err
+		}},
+		{name: "DeactivateUser", read: func() ([]User, error) {
+			user, err := c.DeactivateUser(ctx, "c@example.com")
+			return []User{user}, err
+		}},
+	}
+	for _, test := range tests {
+		users, err := test.read()
+		if err != nil {
+			t.Errorf("%s() = %v, expected nil", test.name, err)
+			continue
+		}
+		if len(users) == 0 {
+			t.Errorf("%s() returned no users, expected some", test.name)
+		}
+		for _, user := range users {
+			if user.PasswordHash != "" {
+				t.Errorf("%s() returned PasswordHash %q for %s, expected it empty", test.name, user.PasswordHash, user.Email)
+			}
+		}
+	}
+
+	// the store keeps them
+	data, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"password":"$2a$`) {
+		t.Errorf("db file lost the password hashes: %s", data)
+	}
+	buf := bytes.Buffer{}
+	if err := c.ExportNDJSON(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"password":"$2a$`) {
+		t.Errorf("ExportNDJSON() lost the password hashes:\n%s", buf.String())
+	}
+}
+
+func TestWithCredentials(t *testing.T) {
+	c := newTestClient(t, WithCredentials())
+	created, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword(created.PasswordHash, "correct horse") {
+		t.Errorf("CreateUser() with WithCredentials returned PasswordHash %q, expected a hash of the password", created.PasswordHash)
+	}
+	user, err := c.GetUser(ctx, "test@example.com")
+	if err != nil || user.PasswordHash != created.PasswordHash {
+		t.Errorf("GetUser() with WithCredentials = %+v, %v, expected PasswordHash %q", user, err, created.PasswordHash)
+	}
+	// the login still hands out the user without it
+	if user, err := c.AuthenticateUser(ctx, "test@example.com", "correct horse"); err != nil || user.PasswordHash != "" {
+		t.Errorf("AuthenticateUser() with WithCredentials = %+v, %v, expected the user without a password", user, err)
+	}
+}
+
+func TestUserMarshalJSON(t *testing.T) {
+	user := User{Email: "test@example.com", PasswordHash: "$2a$04$secret", Name: "john doe", Age: 18}
+	data, err := json.Marshal(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "password") || strings.Contains(string(data), "secret") {
+		t.Errorf("json.Marshal(user) = %s, expected no password", data)
+	}
+	// pointers and slices of users too
+	data, err = json.Marshal(map[string]interface{}{"user": &user, "users": []User{user}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("json.Marshal() = %s, expected no password", data)
+	}
+
+	var got struct{ User User }
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	user.PasswordHash = ""
+	if got.User != user {
+		t.Errorf("json round trip = %+v, expected %+v", got.User, user)
+	}
+
+	// while a whole db still encodes them
+	data, err = json.Marshal(Schema{Users: map[string]User{user.Email: {Email: user.Email, PasswordHash: "$2a$04$secret"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"password":"$2a$04$secret"`) {
+		t.Errorf("json.Marshal(Schema) = %s, expected the password kept", data)
+	}
+}
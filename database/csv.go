@@ -0,0 +1,104 @@
+package database
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// UsersCSVOptions -
+// controls ExportUsersCSV
+type UsersCSVOptions struct {
+	// IncludePasswords adds a password column with the hashes, left out by default so exports can be shared
+	IncludePasswords bool
+}
+
+// PostsCSVOptions -
+// controls ExportPostsCSV
+type PostsCSVOptions struct {
+	// UserEmail limits the export to that user's posts, all posts when empty
+	UserEmail string
+}
+
+// ExportUsersCSV -
+// write every user to w as csv with a header row, sorted by email
+// fields are quoted as RFC 4180 describes and timestamps are RFC 3339
+func (c *Client) ExportUsersCSV(ctx context.Context, w io.Writer, opts UsersCSVOptions) error {
+	users := []User{}
+	err := c.view(ctx, "ExportUsersCSV", "", func(db *Schema) error {
+		for _, user := range db.Users {
+			users = append(users, user)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Email < users[j].Email })
+
+	header := []string{"email", "name", "age", "createdAt"}
+	if opts.IncludePasswords {
+		header = append(header, "password")
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	// the records are a copy, writing to a slow w doesn't hold up the client
+	for i, user := range users {
+		if i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		record := []string{user.Email, user.Name, strconv.Itoa(user.Age), user.CreatedAt.Format(time.RFC3339Nano)}
+		if opts.IncludePasswords {
+			record = append(record, user.PasswordHash)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ExportPostsCSV -
+// write posts to w as csv with a header row, oldest first
+// fields are quoted as RFC 4180 describes and timestamps are RFC 3339
+func (c *Client) ExportPostsCSV(ctx context.Context, w io.Writer, opts PostsCSVOptions) error {
+	posts := []Post{}
+	userEmail := EmailKey(opts.UserEmail)
+	err := c.view(ctx, "ExportPostsCSV", "", func(db *Schema) error {
+		for _, post := range db.Posts {
+			if userEmail == "" || post.UserEmail == userEmail {
+				posts = append(posts, post)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	SortPosts(posts, OldestFirst)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "userEmail", "text", "createdAt"}); err != nil {
+		return err
+	}
+	for i, post := range posts {
+		if i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if err := cw.Write([]string{post.ID, post.UserEmail, post.Text, post.CreatedAt.Format(time.RFC3339Nano)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readCSV parses csv written by an export
+func readCSV(t *testing.T, data []byte) [][]string {
+	t.Helper()
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("export isn't valid csv: %v\n%s", err, data)
+	}
+	return records
+}
+
+func TestExportUsersCSV(t *testing.T) {
+	c := newTestClient(t, WithCredentials())
+	users := []struct{ email, name string }{
+		{"b@example.com", `Renée "the boss", Ünal`},
+		{"a@example.com", "王小明\nsecond line"},
+	}
+	created := map[string]User{}
+	for _, u := range users {
+		user, err := c.CreateUser(ctx, u.email, "hash,with\"quotes", u.name, 30)
+		if err != nil {
+			t.Fatal(err)
+		}
+		created[u.email] = user
+	}
+
+	var tests = []struct {
+		opts           UsersCSVOptions
+		expectedHeader []string
+	}{
+		{opts: UsersCSVOptions{}, expectedHeader: []string{"email", "name", "age", "createdAt"}},
+		{opts: UsersCSVOptions{IncludePasswords: true}, expectedHeader: []string{"email", "name", "age", "createdAt", "password"}},
+	}
+	for _, test := range tests {
+		buf := bytes.Buffer{}
+		if err := c.ExportUsersCSV(ctx, &buf, test.opts); err != nil {
+			t.Fatalf("ExportUsersCSV(%+v) = %v, expected nil", test.opts, err)
+		}
+		if !test.opts.IncludePasswords && strings.Contains(buf.String(), "hash") {
+			t.Errorf("ExportUsersCSV() without IncludePasswords leaked a password:\n%s", buf.String())
+		}
+
+		records := readCSV(t, buf.Bytes())
+		if !reflect.DeepEqual(records[0], test.expectedHeader) {
+			t.Errorf("header = %q, expected %q", records[0], test.expectedHeader)
+		}
+		if len(records) != 3 {
+			t.Fatalf("got %d records, expected header and 2 users", len(records))
+		}
+		// sorted by email
+		for i, email := range []string{"a@example.com", "b@example.com"} {
+			user := created[email]
+			record := records[i+1]
+			if record[0] != user.Email || record[1] != user.Name || record[2] != "30" {
+				t.Errorf("record %d = %q, expected %v", i+1, record, user)
+			}
+			createdAt, err := time.Parse(time.RFC3339, record[3])
+			if err != nil || !createdAt.Equal(user.CreatedAt) {
+				t.Errorf("createdAt = %s (%v), expected %s", record[3], err, user.CreatedAt.Format(time.RFC3339Nano))
+			}
+			if test.opts.IncludePasswords && record[4] != user.PasswordHash {
+				t.Errorf("password = %q, expected %q", record[4], user.PasswordHash)
+			}
+		}
+	}
+}
+
+func TestExportPostsCSV(t *testing.T) {
+	c := newTestClient(t)
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "12345", "john doe", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	texts := []string{"commas, everywhere", `she said "hi"`, "line one\nline two\n\nline four", "emoji 🐈 ok"}
+	expected := []Post{}
+	for _, text := range texts {
+		post, err := c.CreatePost(ctx, "a@example.com", text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, post)
+		// keep creation times distinct so the order is known
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := c.CreatePost(ctx, "b@example.com", "someone else"); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.Buffer{}
+	if err := c.ExportPostsCSV(ctx, &buf, PostsCSVOptions{UserEmail: "a@example.com"}); err != nil {
+		t.Fatalf("ExportPostsCSV() = %v, expected nil", err)
+	}
+	records := readCSV(t, buf.Bytes())
+	if len(records) != len(expected)+1 {
+		t.Fatalf("got %d records, expected header and %d posts", len(records), len(expected))
+	}
+	for i, post := range expected {
+		record := records[i+1]
+		if record[0] != post.ID || record[1] != post.UserEmail || record[2] != post.Text {
+			t.Errorf("record %d = %q, expected %v", i+1, record, post)
+		}
+	}
+
+	buf.Reset()
+	if err := c.ExportPostsCSV(ctx, &buf, PostsCSVOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if records := readCSV(t, buf.Bytes()); len(records) != len(texts)+2 {
+		t.Errorf("unfiltered export has %d records, expected %d", len(records), len(texts)+2)
+	}
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// encodeCursor -
+// the opaque cursor for resuming a listing after post: its CreatedAt and its ID,
+// base64 so callers don't come to rely on what's inside
+func encodeCursor(post Post) string {
+	raw := post.CreatedAt.UTC().Format(time.RFC3339Nano) + " " + post.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor -
+// the CreatedAt and ID of the post encodeCursor made cursor for, only those two are set.
+// ErrInvalidCursor for anything encodeCursor couldn't have made
+func decodeCursor(cursor string) (Post, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return Post{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	// RFC 3339 has no spaces, IDs might
+	at, id, ok := strings.Cut(string(raw), " ")
+	if !ok || id == "" {
+		return Post{}, fmt.Errorf("%w: no post ID", ErrInvalidCursor)
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return Post{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return Post{ID: id, CreatedAt: createdAt.UTC()}, nil
+}
@@ -1,37 +1,235 @@
 package database
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
-	"os"
+	"sync"
 	"time"
-
-	"github.com/google/uuid"
 )
 
 // exported
+// safe for concurrent use, writes are serialized and reads can run in parallel
+// the db is loaded into memory on first use, reads are served from memory and
+// mutations are written through to the store, or batched with WithBatchedWrites
 type Client struct {
-	path string
+	store       Store
+	mu          sync.RWMutex
+	readOnly    bool
+	backupDir   string
+	autoRecover bool
+
+	// in-memory copy of the db, nil until loaded
+	mem *Schema
+	// writes in mem that aren't in the store yet, nil unless WithBatchedWrites
+	batch   *batch
+	hooks   hooks
+	metrics metrics
+	logger  Logger
+	clock   Clock
+	ids     IDGenerator
+	// bcrypt cost for new password hashes
+	passwordCost   int
+	passwordPolicy PasswordPolicy
+	resetTokenTTL  time.Duration
+	// reads return PasswordHash, see WithCredentials
+	credentials bool
+	// email verification
+	verifyTokenTTL time.Duration
+	verifiedOnly   bool
+	dummy          dummyHash
+	quota          quota
+	// how long soft-deleted users can be restored
+	retention  time.Duration
+	minimumAge int
+	// how many name changes are kept per user
+	nameHistory int
+	// the most characters a post can have
+	maxPostLength int
+	// how many edits are kept per post
+	postEdits int
+	// how long soft-deleted posts can be restored
+	postRetention time.Duration
+	// GetPosts puts the pinned post first
+	pinnedFirst bool
+	// closed by Close to stop WithFileWatch's polling and WithScheduledPublishing's ticker, nil without them
+	stop      chan struct{}
+	closeOnce sync.Once
 }
 
 // NewClient -
-// construct a client
-func NewClient(path string) Client {
-	return Client{path}
+// construct a client backed by the json file at path, opts override the defaults
+func NewClient(path string, opts ...Option) *Client {
+	o := newOptions(opts)
+	return newClient(newFileStore(path, o), o)
+}
+
+// NewClientE -
+// NewClient that checks opts first, ErrInvalidOption for values it can't work with
+// instead of failing (or quietly misbehaving) on first use
+func NewClientE(path string, opts ...Option) (*Client, error) {
+	o := newOptions(opts)
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	return newClient(newFileStore(path, o), o), nil
+}
+
+// NewClientWithStore -
+// construct a client backed by a custom Store, file specific options are ignored
+func NewClientWithStore(store Store, opts ...Option) *Client {
+	return newClient(store, newOptions(opts))
+}
+
+// NewReadOnlyClient -
+// construct a client that can only read the json file at path, see WithReadOnly
+func NewReadOnlyClient(path string, opts ...Option) *Client {
+	return NewClient(path, append(opts, WithReadOnly())...)
+}
+
+// newClient applies the client level options
+func newClient(store Store, o options) *Client {
+	c := &Client{
+		store:       store,
+		readOnly:    o.readOnly,
+		backupDir:   o.backupDir,
+		autoRecover: o.autoRecover,
+	}
+	c.metrics.sink = o.metricsSink
+	c.logger = o.logger
+	c.clock = o.clock
+	c.ids = o.ids
+	c.passwordCost = o.passwordCost
+	c.passwordPolicy = o.passwordPolicy
+	c.resetTokenTTL = o.resetTokenTTL
+	c.credentials = o.credentials
+	c.verifyTokenTTL = o.verifyTokenTTL
+	c.verifiedOnly = o.verifiedOnly
+	c.retention = o.retention
+	c.minimumAge = o.minimumAge
+	c.nameHistory = o.nameHistory
+	c.maxPostLength = o.maxPostLength
+	c.postEdits = o.postEdits
+	c.postRetention = o.postRetention
+	c.pinnedFirst = o.pinnedFirst
+	c.quota.max = o.maxSize
+	if o.hookQueue > 0 {
+		c.hooks.queue = make(chan func(), o.hookQueue)
+	}
+	if o.flushInterval > 0 && !o.readOnly {
+		c.batch = &batch{interval: o.flushInterval, maxPending: o.maxPending, onError: o.onFlushError}
+	}
+	if _, ok := store.(ChangeDetector); ok && o.watchInterval > 0 {
+		c.stop = make(chan struct{})
+		go c.watch(o.watchInterval)
+	}
+	if o.publishInterval > 0 && !o.readOnly {
+		if c.stop == nil {
+			c.stop = make(chan struct{})
+		}
+		go c.publishEvery(o.publishInterval)
+	}
+	return c
 }
 
-type databaseSchema struct {
-	Users map[string]User `json:"users"` // key,value = email,user
-	Posts map[string]Post `json:"posts"` // key,value = id, post
+// how many records a full scan walks between checks for a cancelled context
+const cancelCheckInterval = 1000
+
+// errNoop can be returned from an update fn to skip the write without failing the call
+var errNoop = errors.New("nothing to write")
+
+// Schema -
+// the full contents of a database, what a Store loads and saves
+// SchemaVersion is the version the data was stored with, a Store may upgrade older
+// data on Load but leaves the field alone so the client knows to save the upgrade
+type Schema struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Users         map[string]User `json:"users"` // key,value = email,user
+	Posts         map[string]Post `json:"posts"` // key,value = id, post
+	// key,value = username,email. derived from Users when the db is read so it's never stored,
+	// kept up to date by every write, see username.go
+	Usernames map[string]string `json:"-"`
+	// key,value = sha256 of the token,token. outstanding and recently used password resets, see reset.go
+	ResetTokens map[string]ResetToken `json:"resetTokens,omitempty"`
+	// key,value = sha256 of the token,token. outstanding and recently used email verifications, see verify.go
+	VerificationTokens map[string]VerificationToken `json:"verificationTokens,omitempty"`
+	// key,value = blocker email,blocked emails and when they were blocked, see block.go
+	Blocks emailEdges `json:"blocks,omitempty"`
+	// key,value = muter email,muted emails and when they were muted, see mute.go
+	Mutes emailEdges `json:"mutes,omitempty"`
+	// key,value = follower email,the emails they follow and since when, see follow.go
+	Follows emailEdges `json:"follows,omitempty"`
+	// Follows the other way round, followee to followers. derived like Usernames, never stored
+	Followers emailEdges `json:"-"`
+	// key,value = requester email,the private users it asked to follow and when, see private.go
+	FollowRequests emailEdges `json:"followRequests,omitempty"`
+	// key,value = friendKey of the two emails,the latest request between them, see friend.go
+	FriendRequests map[string]FriendRequest `json:"friendRequests,omitempty"`
+	// key,value = email,the user's name and username changes oldest first, see namehistory.go
+	NameHistory map[string][]NameChange `json:"nameHistory,omitempty"`
+	// key,value = post id,the texts the post had before it was edited oldest first, see postedits.go
+	PostEdits map[string][]PostEdit `json:"postEdits,omitempty"`
+	// key,value = post id,the emails of the users who reacted to it and with what, see reaction.go
+	Reactions postVotes[ReactionKind] `json:"reactions,omitempty"`
+	// key,value = post id,the emails of the users who voted in its poll and the index of their option, see poll.go
+	PollVotes postVotes[int] `json:"pollVotes,omitempty"`
+	// the words of the posts, derived from Posts like Usernames and never stored, see postsearch.go
+	PostIndex postIndex `json:"-"`
+	// key,value = hashtag,the IDs of the posts with it in their Tags. derived the same way, see hashtag.go
+	Hashtags postIndex `json:"-"`
+	// key,value = email,the IDs of the posts with it in their Mentions. derived the same way, see mention.go
+	Mentioned postIndex `json:"-"`
+	// key,value = post id,the IDs of the reposts of it. derived the same way, see repost.go
+	Reposts postIndex `json:"-"`
+	// key,value = post id,the IDs of the replies to it. derived the same way, see reply.go
+	Replies postIndex `json:"-"`
+	// key,value = email,the IDs of the user's posts. derived the same way, see postcount.go
+	Authored postIndex `json:"-"`
+	// the keys of the indexes above whose maps this copy of the db made for itself
+	// since clone, indexPost changes those in place
+	ownTerms, ownTags, ownMentions, ownReposts, ownReplies, ownAuthored map[string]bool
 }
 
 // User -
 type User struct {
 	CreatedAt time.Time `json:"createdAt"`
 	Email     string    `json:"email"`
-	Password  string    `json:"password"`
-	Name      string    `json:"name"`
-	Age       int       `json:"age"`
+	// PasswordHash is the bcrypt hash of the password, see HashPassword. records from before
+	// hashing have the plaintext here until the user's next successful VerifyPassword
+	PasswordHash string `json:"password"`
+	Name         string `json:"name"`
+	Age          int    `json:"age"`
+	// Username is the public handle shown instead of the email, unique when set, see SetUsername
+	Username string `json:"username,omitempty"`
+	// UpdatedAt is when the user was last changed by a write meant to change it, CreatedAt until then.
+	// a password upgraded by VerifyPassword doesn't count, the user didn't change it
+	UpdatedAt time.Time `json:"updatedAt"`
+	// DeletedAt is set by SoftDeleteUser, nil for users that weren't. it's replaced and never modified
+	// through the pointer, so copies of a User can share it
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	// Status is UserActive unless DeactivateUser was called, records from before it have it empty
+	// which counts as active too. see Active
+	Status UserStatus `json:"status,omitempty"`
+	// the public profile, all optional and set through UpdateProfile, see Profile
+	Bio       string `json:"bio,omitempty"`
+	AvatarURL string `json:"avatarUrl,omitempty"`
+	Location  string `json:"location,omitempty"`
+	Website   string `json:"website,omitempty"`
+	// Role is what the user may do beyond their own records, changed only by SetUserRole and
+	// BootstrapAdmin. empty for records from before roles, which are RoleUser, see EffectiveRole
+	Role Role `json:"role,omitempty"`
+	// LastLoginAt is when AuthenticateUser last succeeded, zero if it never has.
+	// LoginCount is how many times it has, neither counts as a change for UpdatedAt
+	LastLoginAt time.Time `json:"lastLoginAt"`
+	LoginCount  int       `json:"loginCount,omitempty"`
+	// Verified is set by VerifyEmail once the user proved the email is theirs, cleared by ChangeEmail
+	Verified bool `json:"verified,omitempty"`
+	// Settings are the user's preferences, set through SetUserSetting, see UserSettings
+	Settings UserSettings `json:"settings,omitempty"`
+	// IsPrivate limits the user's posts to its followers and makes following it a request, see SetPrivate
+	IsPrivate bool `json:"private,omitempty"`
+	// PinnedPostID is the post PinPost stuck to the top of the user's profile, empty if there's none.
+	// it's cleared when that post is deleted or soft-deleted
+	PinnedPostID string `json:"pinnedPostId,omitempty"`
 }
 
 // Post -
@@ -40,28 +238,85 @@ type Post struct {
 	CreatedAt time.Time `json:"createdAt"`
 	UserEmail string    `json:"userEmail"`
 	Text      string    `json:"text"`
+	// EditedAt is when UpdatePost last changed the text, nil if it never has
+	EditedAt *time.Time `json:"editedAt,omitempty"`
+	// DeletedAt is when SoftDeletePost removed the post, nil unless it did
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+	// Visibility is who can read the post, see visibility.go. empty is VisibilityPublic,
+	// only the other levels are stored so public posts look like they did before there was a choice
+	Visibility Visibility `json:"visibility,omitempty"`
+	// IsDraft is set on posts from CreateDraft until PublishDraft, no listing shows them, see draft.go
+	IsDraft bool `json:"draft,omitempty"`
+	// PublishAt is set on posts from SchedulePost until PublishDue, they're hidden until then, see schedule.go
+	PublishAt *time.Time `json:"publishAt,omitempty"`
+	// Pinned is set on the post GetPosts puts first with WithPinnedPostsFirst, it's never stored,
+	// see User.PinnedPostID
+	Pinned bool `json:"pinned,omitempty"`
+	// Tags are the hashtags in Text folded to lower case, in the order they first come up, set by
+	// CreatePost and UpdatePost, see hashtag.go. the slice is replaced and never modified in place,
+	// so copies of a Post can share it
+	Tags []string `json:"tags,omitempty"`
+	// Mentions are the emails of the users the text mentions with @username or @email, set like Tags
+	// and shared the same way, see mention.go. mentions of users that don't exist are left out
+	Mentions []string `json:"mentions,omitempty"`
+	// Attachments are the media the post links to, in the order they were given, see attachment.go.
+	// replaced as a whole like Tags, never modified in place
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// RepostOf is the ID of the post this one shares, set by Repost. a repost has no text of its own
+	RepostOf string `json:"repostOf,omitempty"`
+	// Original is the post RepostOf points to, set by the listings asked to with ListOptions.IncludeOriginals
+	// and never stored. nil on a repost when the original is unavailable, see repost.go
+	Original *Post `json:"original,omitempty"`
+	// ParentID is the ID of the post this one replies to, set by CreateReply, see reply.go
+	ParentID string `json:"parentId,omitempty"`
+	// Poll is set on the posts from CreatePoll, their text is the question, see poll.go
+	Poll *Poll `json:"poll,omitempty"`
+	// PollResults are a poll's votes, set by GetPostWithOptions when asked to and never stored
+	PollResults *PollResults `json:"pollResults,omitempty"`
 }
 
-func (c Client) CreatePost(userEmail, text string) (Post, error) {
-	// read db, ensure user exists
-	db, err := c.readDB()
-	if err != nil {
-		return Post{}, err
-	}
-	if _, ok := db.Users[userEmail]; !ok {
-		return Post{}, errors.New("user doesn't exist")
-	}
+// CreatePostOptions -
+// controls Client.CreatePostWithOptions, the zero value is what CreatePost does
+type CreatePostOptions struct {
+	// CreatedAt is used instead of the clock's time when set, like CreatePostAt
+	CreatedAt time.Time
+	// Visibility is who can read the post, VisibilityPublic when empty
+	Visibility Visibility
+	// Draft stores the post as a draft, see CreateDraft
+	Draft bool
+	// PublishAt, when in the future, schedules the post for then instead of CreatedAt, see SchedulePost.
+	// a draft isn't scheduled
+	PublishAt time.Time
+	// Attachments are stored with the post, at most MaxAttachments of them, see UpdatePostAttachments
+	Attachments []Attachment
+	// ParentID makes the post a reply to that one, see CreateReply
+	ParentID string
+}
 
-	// create new post and add to db
-	post := Post{
-		ID:        uuid.New().String(),
-		CreatedAt: time.Now().UTC(),
-		UserEmail: userEmail,
-		Text:      text,
-	}
+// CreatePost -
+// create a post authored by an existing user, its text trimmed of surrounding whitespace and its
+// hashtags in Tags and the users it mentions in Mentions. ErrEmptyPost if there's no text left, ErrPostTooLong if it's over WithMaxPostLength
+func (c *Client) CreatePost(ctx context.Context, userEmail, text string) (Post, error) {
+	return c.CreatePostWithOptions(ctx, userEmail, text, CreatePostOptions{})
+}
+
+// CreatePostAt -
+// CreatePost with the given CreatedAt instead of the clock's time, for importing old records
+func (c *Client) CreatePostAt(ctx context.Context, userEmail, text string, createdAt time.Time) (Post, error) {
+	return c.CreatePostWithOptions(ctx, userEmail, text, CreatePostOptions{CreatedAt: createdAt})
+}
 
-	db.Posts[post.ID] = post // add post to Posts
-	err = c.updateDB(db)     // save to disk
+// CreatePostWithOptions -
+// CreatePost with the choices opts makes. ErrInvalidVisibility for a Visibility that isn't one of the levels,
+// ErrInvalidAttachment for Attachments that can't be stored, ErrPostNotFound and ErrPostNotPublished for a
+// ParentID CreateReply wouldn't take
+func (c *Client) CreatePostWithOptions(ctx context.Context, userEmail, text string, opts CreatePostOptions) (Post, error) {
+	post := Post{}
+	err := c.update(ctx, "CreatePost", userEmail, func(db *Schema) error {
+		var err error
+		post, err = c.newTx(db).CreatePostWithOptions(ctx, userEmail, text, opts)
+		return err
+	})
 	if err != nil {
 		return Post{}, err
 	}
@@ -70,182 +325,202 @@ func (c Client) CreatePost(userEmail, text string) (Post, error) {
 }
 
 // GetPosts -
-// return all posts of a specific user identified by their userEmail
-func (c Client) GetPosts(userEmail string) ([]Post, error) {
-	// read db, ensure user exists
-	db, err := c.readDB()
+// return all posts of a specific user identified by their userEmail, newest first like SortPosts,
+// see GetPostsPage for OldestFirst
+// none while the user is deactivated, they're kept and come back with ReactivateUser.
+// soft-deleted posts, drafts and posts scheduled for later are left out. see WithPinnedPostsFirst.
+// whoever asks is taken to be logged out like GetPostsAs with no viewer: there are none of a private
+// user and only public posts, the author and their followers need GetPostsAs to see the rest
+func (c *Client) GetPosts(ctx context.Context, userEmail string) ([]Post, error) {
+	allPosts := []Post{}
+	err := c.view(ctx, "GetPosts", userEmail, func(db *Schema) error {
+		var err error
+		allPosts, err = c.newTx(db).GetPosts(ctx, userEmail)
+		return err
+	})
 	if err != nil {
 		return []Post{}, err
 	}
 
-	allPosts := []Post{}
-	for _, post := range db.Posts {
-		if post.UserEmail == userEmail {
-			allPosts = append(allPosts, post)
-		}
-	}
-
 	return allPosts, nil
 }
 
 // DeletePost -
-// delete a single post identified by the id
-func (c Client) DeletePost(id string) error {
-	// get db from database
-	db, err := c.readDB()
-	if err != nil {
-		return err
+// delete a single post identified by the id, returns the removed post
+// ErrPostNotFound if there's no post with that id
+func (c *Client) DeletePost(ctx context.Context, id string) (Post, error) {
+	if id == "" {
+		return Post{}, ErrEmptyPostID
 	}
-	delete(db.Posts, id) // delete post, if doesn't exist noop
-	err = c.updateDB(db) // write to disk
-	if err != nil {
+
+	post := Post{}
+	err := c.deleteUpdate(ctx, "DeletePost", id, func(db *Schema) error {
+		var err error
+		post, err = c.newTx(db).DeletePost(ctx, id)
 		return err
+	})
+	if err != nil {
+		return Post{}, err
 	}
-	return nil
+	return post, nil
 }
 
-// create new db file (json) at path specified by the client
-// empty databaseSchema
-// overwrite any previous data in file if existed previously
-func (c Client) createDB() error {
-	db := databaseSchema{
-		Users: make(map[string]User),
-		Posts: make(map[string]Post),
-	}
-	payload, err := json.Marshal(db)
-	if err != nil {
-		return err
-	}
-	err = os.WriteFile(c.path, payload, 0666)
-	return err
+// CreateUser -
+// email needs to be unique for each user, returns ErrUserExists if it's already taken
+// surrounding whitespace is trimmed, the rest lowercased and it must be a valid address, ErrInvalidEmail otherwise.
+// every method taking an email looks it up the same way, so "Bob@Example.com" is bob@example.com.
+// the password has to satisfy the client's PasswordPolicy, a *PasswordError otherwise,
+// and the age must be WithMinimumAge to MaxAge, ErrInvalidAge otherwise
+func (c *Client) CreateUser(ctx context.Context, email, password, name string, age int) (User, error) {
+	return c.putUser(ctx, email, password, name, age, time.Time{}, false)
 }
 
-// EnsureDB -
-// check if db exists already, if good do nothing, otherwise create it using createDB
-func (c Client) EnsureDB() error {
-	_, err := os.ReadFile(c.path)
-	// create new db if doesn't exist
-	if err != nil {
-		return c.createDB()
-	}
-	// already exists, do nothing
-	return nil
+// CreateUserAt -
+// CreateUser with the given CreatedAt instead of the clock's time, for importing old records
+func (c *Client) CreateUserAt(ctx context.Context, email, password, name string, age int, createdAt time.Time) (User, error) {
+	return c.putUser(ctx, email, password, name, age, createdAt, false)
 }
 
-// overwrite db file with the data in given databaseSchema
-// databaseSchema has JSON tags, can marshal to json format byte slice
-func (c Client) updateDB(db databaseSchema) error {
-	payload, err := json.Marshal(db)
-	if err != nil {
-		return err
-	}
-	err = os.WriteFile(c.path, payload, 0666)
-	return err
+// UpsertUser -
+// like CreateUser but overwrites the password, name and age of any existing user with the same email,
+// the rest of the record (CreatedAt, username, role, settings and so on) stays as it was.
+// a soft-deleted or deactivated user is active again afterwards.
+// meant for admin tooling that intentionally overwrites records
+func (c *Client) UpsertUser(ctx context.Context, email, password, name string, age int) (User, error) {
+	return c.putUser(ctx, email, password, name, age, time.Time{}, true)
 }
 
-// return data read from db at path in client as a databaseSchema
-func (c Client) readDB() (databaseSchema, error) {
-	data, err := os.ReadFile(c.path)
-	if err != nil {
-		return databaseSchema{}, err
+// putUser -
+// store a new user created at createdAt (now if zero), only replacing an existing one when overwrite is set
+func (c *Client) putUser(ctx context.Context, email, password, name string, age int, createdAt time.Time, overwrite bool) (User, error) {
+	op := "CreateUser"
+	if overwrite {
+		op = "UpsertUser"
 	}
-
-	// convert data from json byte slice to databaseSchema
-	db := databaseSchema{}
-	err = json.Unmarshal(data, &db)
-	if err != nil {
-		return databaseSchema{}, err
+	// bcrypt is slow on purpose, don't spend it on an age that's no good and hash before taking the lock
+	if err := CheckAge(age, c.minimumAge); err != nil {
+		return User{}, err
 	}
-
-	return db, nil
-}
-
-// CreateUser -
-// email needs to be unique for each user
-func (c Client) CreateUser(email, password, name string, age int) (User, error) {
-	// read current status of db
-	db, err := c.readDB()
+	hash, err := newPasswordHash(password, c.passwordPolicy, c.passwordCost)
 	if err != nil {
 		return User{}, err
 	}
-
-	// create new user
-	newUser := User{
-		CreatedAt: time.Now().UTC(),
-		Email:     email,
-		Password:  password,
-		Name:      name,
-		Age:       age,
-	}
-
-	// add newUser and write to disk
-	db.Users[email] = newUser
-	err = c.updateDB(db)
+	newUser := User{}
+	err = c.update(ctx, op, email, func(db *Schema) error {
+		var err error
+		newUser, err = c.newTx(db).putUser(email, hash, name, age, createdAt, overwrite)
+		return err
+	})
 	if err != nil {
 		return User{}, err
 	}
 
-	return newUser, nil
+	return c.sanitize(newUser), nil
 }
 
 // UddateUser -
 // similar to CreateUser but return an error if user doesn't already exist
+// the email, password and age are checked the same way
 // do not update CreatedAt timestamp
-func (c Client) UpdateUser(email, password, name string, age int) (User, error) {
-	// read from db to see if user already exists
-	db, err := c.readDB()
-	if err != nil {
+func (c *Client) UpdateUser(ctx context.Context, email, password, name string, age int) (User, error) {
+	if err := CheckAge(age, c.minimumAge); err != nil {
 		return User{}, err
 	}
-
-	// check if email is a key in db.Users
-	if _, ok := db.Users[email]; !ok {
-		return User{}, errors.New("user doesn't exist")
+	hash, err := newPasswordHash(password, c.passwordPolicy, c.passwordCost)
+	if err != nil {
+		return User{}, err
 	}
-	// user does exist, we will update (email and CreatedAt fields won't change)
-	user := db.Users[email]
-
-	user.Password = password
-	user.Name = name
-	user.Age = age
-
-	db.Users[email] = user
-	err = c.updateDB(db)
+	user := User{}
+	err = c.update(ctx, "UpdateUser", email, func(db *Schema) error {
+		var err error
+		user, err = c.newTx(db).updateUser(email, hash, name, age)
+		return err
+	})
 	if err != nil {
 		return User{}, err
 	}
 
-	return user, nil
+	return c.sanitize(user), nil
 }
 
 // GetUser -
 // return user given the email from the db
-func (c Client) GetUser(email string) (User, error) {
-	db, err := c.readDB()
+// PasswordHash is left empty unless the client has WithCredentials
+func (c *Client) GetUser(ctx context.Context, email string) (User, error) {
+	user, err := c.getUser(ctx, email)
 	if err != nil {
 		return User{}, err
 	}
+	return c.sanitize(user), nil
+}
 
-	if user, ok := db.Users[email]; ok {
-		return user, nil
-	} else {
-		return User{}, errors.New("user doesn't exist")
+// getUser -
+// GetUser with PasswordHash whatever the options, for checking passwords
+func (c *Client) getUser(ctx context.Context, email string) (User, error) {
+	user := User{}
+	err := c.view(ctx, "GetUser", email, func(db *Schema) error {
+		var err error
+		user, err = c.newTx(db).GetUser(ctx, email)
+		return err
+	})
+	if err != nil {
+		return User{}, err
 	}
+	return user, nil
+}
+
+// PostPolicy -
+// what DeleteUser does with the posts of the deleted user
+type PostPolicy int
+
+const (
+	// PostsCascade deletes the user's posts along with the user, the default.
+	// drafts and posts still scheduled are deleted whatever the policy
+	PostsCascade PostPolicy = iota
+	// PostsAnonymize keeps the posts but rewrites their UserEmail to DeletedUserEmail
+	PostsAnonymize
+	// PostsKeep leaves the posts untouched, pointing at an email that no longer exists
+	PostsKeep
+)
+
+// DeletedUserEmail -
+// tombstone UserEmail given to posts anonymized by DeleteUser
+const DeletedUserEmail = "[deleted]"
+
+// DeleteUserOptions -
+// controls how DeleteUser behaves
+type DeleteUserOptions struct {
+	// IgnoreMissing makes deleting a user that doesn't exist a no-op instead of ErrUserNotFound
+	IgnoreMissing bool
+	// Posts picks what happens to the user's posts, cascade delete by default
+	Posts PostPolicy
+}
+
+// DeleteUserResult -
+// summary of what DeleteUser did, for logging
+type DeleteUserResult struct {
+	// Deleted is false when the user didn't exist and IgnoreMissing was set
+	Deleted bool
+	// Posts is the number of posts that referenced the user, handled according to the PostPolicy
+	Posts int
 }
 
 // DeleteUser -
 // delete a user (via email key) from db
-func (c Client) DeleteUser(email string) error {
-	db, err := c.readDB()
-	if err != nil {
+// returns ErrUserNotFound if the user doesn't exist, unless opts.IgnoreMissing is set
+func (c *Client) DeleteUser(ctx context.Context, email string, opts DeleteUserOptions) (DeleteUserResult, error) {
+	result := DeleteUserResult{}
+	err := c.deleteUpdate(ctx, "DeleteUser", email, func(db *Schema) error {
+		var err error
+		result, err = c.newTx(db).DeleteUser(ctx, email, opts)
+		if err == nil && !result.Deleted {
+			return errNoop
+		}
 		return err
-	}
-
-	delete(db.Users, email) // if key doesn't exist, this is no-op
-	err = c.updateDB(db)    // save changes to disk
+	})
 	if err != nil {
-		return err
+		return DeleteUserResult{}, err
 	}
 
-	return nil
+	return result, nil
 }
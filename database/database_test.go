@@ -0,0 +1,474 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// ctx is used by tests that don't care about cancellation
+var ctx = context.Background()
+
+// dbPath is the file behind a client made with NewClient
+func dbPath(c *Client) string {
+	return c.store.(*fileStore).path
+}
+
+// newTestClient creates a client backed by a fresh db file in a temp dir
+func newTestClient(t *testing.T, opts ...Option) *Client {
+	t.Helper()
+	c := NewClient(filepath.Join(t.TempDir(), "db.json"), opts...)
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatalf("EnsureDB() = %v, expected nil", err)
+	}
+	return c
+}
+
+// testClients are the implementations the behavior tables below run against
+var testClients = []struct {
+	name string
+	new  func(t *testing.T) *Client
+}{
+	{name: "file", new: func(t *testing.T) *Client { return newTestClient(t) }},
+	{name: "memory", new: func(t *testing.T) *Client { return NewMemoryClient() }},
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	c := newTestClient(t)
+	const workers = 50
+
+	// every worker creates its own user and two posts, then deletes one of the posts
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	kept := make(chan string, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			email := fmt.Sprintf("user%d@example.com", i)
+			if _, err := c.CreateUser(ctx, email, "password", "user", 18); err != nil {
+				errs <- err
+				return
+			}
+			first, err := c.CreatePost(ctx, email, "first")
+			if err != nil {
+				errs <- err
+				return
+			}
+			second, err := c.CreatePost(ctx, email, "second")
+			if err != nil {
+				errs <- err
+				return
+			}
+			if _, err := c.DeletePost(ctx, first.ID); err != nil {
+				errs <- err
+				return
+			}
+			kept <- second.ID
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	close(kept)
+	for err := range errs {
+		t.Errorf("concurrent write failed: %v", err)
+	}
+
+	// the file on disk must parse cleanly and contain every surviving record
+	data := readPayload(t, dbPath(c))
+	db := Schema{}
+	if err := json.Unmarshal(data, &db); err != nil {
+		t.Fatalf("final db file doesn't parse: %v", err)
+	}
+	if len(db.Users) != workers {
+		t.Errorf("len(db.Users) = %d, expected %d", len(db.Users), workers)
+	}
+	if len(db.Posts) != workers {
+		t.Errorf("len(db.Posts) = %d, expected %d", len(db.Posts), workers)
+	}
+	for id := range kept {
+		if _, ok := db.Posts[id]; !ok {
+			t.Errorf("post %s was lost", id)
+		}
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	for _, client := range testClients {
+		t.Run(client.name, func(t *testing.T) {
+			testSentinelErrors(t, client.new(t))
+		})
+	}
+}
+
+func testSentinelErrors(t *testing.T, c *Client) {
+	const email = "missing@example.com"
+
+	_, getErr := c.GetUser(ctx, email)
+	_, updateErr := c.UpdateUser(ctx, email, "12345", "john doe", 18)
+	_, postErr := c.CreatePost(ctx, email, "hello")
+
+	var tests = []struct {
+		name        string
+		err         error
+		expectedErr error
+	}{
+		{name: "GetUser", err: getErr, expectedErr: ErrUserNotFound},
+		{name: "UpdateUser", err: updateErr, expectedErr: ErrUserNotFound},
+		{name: "CreatePost", err: postErr, expectedErr: ErrUserNotFound},
+	}
+
+	for _, test := range tests {
+		if !errors.Is(test.err, test.expectedErr) {
+			t.Errorf("%s() = %v, expected errors.Is(err, %v)", test.name, test.err, test.expectedErr)
+		}
+		// context is kept in the message
+		if test.err != nil && !strings.Contains(test.err.Error(), email) {
+			t.Errorf("%s() = %v, expected message to contain %s", test.name, test.err, email)
+		}
+	}
+}
+
+func TestCorruptFile(t *testing.T) {
+	c := newTestClient(t)
+	if err := os.WriteFile(dbPath(c), []byte(`{"users": {`), 0666); err != nil {
+		t.Fatal(err)
+	}
+	// surfaces when the file is next loaded
+	_, err := NewClient(dbPath(c)).GetUser(ctx, "test@example.com")
+	if !errors.Is(err, ErrDBCorrupt) {
+		t.Errorf("GetUser() on corrupt file = %v, expected %v", err, ErrDBCorrupt)
+	}
+	if err := c.Reload(ctx); !errors.Is(err, ErrDBCorrupt) {
+		t.Errorf("Reload() on corrupt file = %v, expected %v", err, ErrDBCorrupt)
+	}
+}
+
+func TestCreateUserDuplicate(t *testing.T) {
+	c := newTestClient(t)
+	original, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.CreateUser(ctx, "test@example.com", "hijacked", "jane doe", 30)
+	if !errors.Is(err, ErrUserExists) {
+		t.Errorf("CreateUser() with taken email = %v, expected %v", err, ErrUserExists)
+	}
+
+	// stored record must be untouched
+	got, err := c.GetUser(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != original {
+		t.Errorf("GetUser() = %v, expected %v", got, original)
+	}
+}
+
+func TestCreateUserDuplicateRace(t *testing.T) {
+	c := newTestClient(t)
+
+	// two signups for the same email at the same time, exactly one may win
+	var wg sync.WaitGroup
+	results := make(chan error, 2)
+	for _, name := range []string{"john doe", "jane doe"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			_, err := c.CreateUser(ctx, "test@example.com", "12345", name, 18)
+			results <- err
+		}(name)
+	}
+	wg.Wait()
+	close(results)
+
+	created, rejected := 0, 0
+	for err := range results {
+		switch {
+		case err == nil:
+			created++
+		case errors.Is(err, ErrUserExists):
+			rejected++
+		default:
+			t.Errorf("CreateUser() = %v, expected nil or %v", err, ErrUserExists)
+		}
+	}
+	if created != 1 || rejected != 1 {
+		t.Errorf("got %d created and %d rejected, expected 1 and 1", created, rejected)
+	}
+}
+
+func TestUpsertUser(t *testing.T) {
+	c := newTestClient(t, WithCredentials())
+	if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UpsertUser(ctx, "test@example.com", "54321", "jane doe", 30); err != nil {
+		t.Fatalf("UpsertUser() = %v, expected nil", err)
+	}
+	got, err := c.GetUser(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "jane doe" || !CheckPassword(got.PasswordHash, "54321") || got.Age != 30 {
+		t.Errorf("GetUser() after upsert = %v, expected overwritten record", got)
+	}
+}
+
+func TestUpsertUserKeepsTheRest(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetUsername(ctx, "test@example.com", "johndoe"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetUserSetting(ctx, "test@example.com", "ui.darkMode", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetPrivate(ctx, "test@example.com", true); err != nil {
+		t.Fatal(err)
+	}
+	before, err := c.GetUser(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UpsertUser(ctx, "test@example.com", "54321", "jane doe", 30); err != nil {
+		t.Fatalf("UpsertUser() = %v, expected nil", err)
+	}
+	got, err := c.GetUser(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Username != "johndoe" || !reflect.DeepEqual(got.Settings, before.Settings) || !got.IsPrivate {
+		t.Errorf("GetUser() after upsert = %+v, expected the username, settings and privacy of %+v", got, before)
+	}
+	if user, err := c.GetUserByUsername(ctx, "johndoe"); err != nil || user.Name != "jane doe" {
+		t.Errorf("GetUserByUsername() after upsert = %+v, %v, expected the upserted user", user, err)
+	}
+}
+
+func TestUpsertUserRevives(t *testing.T) {
+	c := newTestClient(t)
+	for _, email := range []string{"deleted@example.com", "deactivated@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "12345", "john doe", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	created, err := c.GetUser(ctx, "deleted@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SoftDeleteUser(ctx, "deleted@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DeactivateUser(ctx, "deactivated@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{"deleted@example.com", "deactivated@example.com"} {
+		if _, err := c.UpsertUser(ctx, email, "54321", "jane doe", 30); err != nil {
+			t.Fatalf("UpsertUser(%s) = %v, expected nil", email, err)
+		}
+		got, err := c.GetUser(ctx, email)
+		if err != nil || got.Name != "jane doe" || !got.Active() {
+			t.Errorf("GetUser(%s) after upsert = %+v, %v, expected the active upserted user", email, got, err)
+		}
+		if _, err := c.AuthenticateUser(ctx, email, "54321"); err != nil {
+			t.Errorf("AuthenticateUser(%s) after upsert = %v, expected nil", email, err)
+		}
+	}
+	// the record is overwritten, not new
+	got, err := c.GetUser(ctx, "deleted@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.CreatedAt.Equal(created.CreatedAt) || !got.UpdatedAt.After(created.UpdatedAt) {
+		t.Errorf("upserted CreatedAt %v and UpdatedAt %v, expected CreatedAt %v and a later UpdatedAt", got.CreatedAt, got.UpdatedAt, created.CreatedAt)
+	}
+}
+
+func TestDeletePost(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	post, err := c.CreatePost(ctx, "test@example.com", "my cat is way too fat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		id           string
+		expectedPost Post
+		expectedErr  error
+	}{
+		{id: "", expectedErr: ErrEmptyPostID},
+		{id: "not-a-real-id", expectedErr: ErrPostNotFound},
+		{id: post.ID, expectedPost: post},
+		// second delete of the same post is now a miss
+		{id: post.ID, expectedErr: ErrPostNotFound},
+	}
+
+	for _, test := range tests {
+		got, err := c.DeletePost(ctx, test.id)
+		if !errors.Is(err, test.expectedErr) {
+			t.Errorf("DeletePost(%q) = %v, expected %v", test.id, err, test.expectedErr)
+		}
+		if !got.equal(test.expectedPost) {
+			t.Errorf("DeletePost(%q) = %v, expected %v", test.id, got, test.expectedPost)
+		}
+	}
+
+	after, err := os.Stat(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() >= before.Size() {
+		t.Errorf("db file is %d bytes after delete, expected less than %d", after.Size(), before.Size())
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	c := newTestClient(t)
+	for _, email := range []string{"noposts@example.com", "poster@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "12345", "john doe", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := c.CreatePost(ctx, "poster@example.com", fmt.Sprintf("post %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		email          string
+		opts           DeleteUserOptions
+		expectedResult DeleteUserResult
+		expectedErr    error
+	}{
+		{email: "missing@example.com", expectedErr: ErrUserNotFound},
+		{email: "missing@example.com", opts: DeleteUserOptions{IgnoreMissing: true}},
+		{email: "noposts@example.com", expectedResult: DeleteUserResult{Deleted: true}},
+		{email: "poster@example.com", expectedResult: DeleteUserResult{Deleted: true, Posts: 5}},
+	}
+
+	for _, test := range tests {
+		got, err := c.DeleteUser(ctx, test.email, test.opts)
+		if !errors.Is(err, test.expectedErr) {
+			t.Errorf("DeleteUser(%s, %+v) = %v, expected %v", test.email, test.opts, err, test.expectedErr)
+		}
+		if got != test.expectedResult {
+			t.Errorf("DeleteUser(%s, %+v) = %+v, expected %+v", test.email, test.opts, got, test.expectedResult)
+		}
+		if _, err := c.GetUser(ctx, test.email); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("GetUser(%s) after delete = %v, expected %v", test.email, err, ErrUserNotFound)
+		}
+	}
+}
+
+func TestDeleteUserPosts(t *testing.T) {
+	for _, client := range testClients {
+		t.Run(client.name, func(t *testing.T) {
+			testDeleteUserPosts(t, client.new)
+		})
+	}
+}
+
+func testDeleteUserPosts(t *testing.T, newClient func(t *testing.T) *Client) {
+	var tests = []struct {
+		policy         PostPolicy
+		expectedPosts  int    // posts left in the db for the deleted user's content
+		expectedAuthor string // author of those posts if they're kept
+	}{
+		{policy: PostsCascade, expectedPosts: 0},
+		{policy: PostsAnonymize, expectedPosts: 3, expectedAuthor: DeletedUserEmail},
+		{policy: PostsKeep, expectedPosts: 3, expectedAuthor: "deleted@example.com"},
+	}
+
+	for _, test := range tests {
+		c := newClient(t)
+		for _, email := range []string{"deleted@example.com", "other@example.com"} {
+			if _, err := c.CreateUser(ctx, email, "12345", "john doe", 18); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i := 0; i < 3; i++ {
+			if _, err := c.CreatePost(ctx, "deleted@example.com", "bye"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		unrelated, err := c.CreatePost(ctx, "other@example.com", "still here")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := c.DeleteUser(ctx, "deleted@example.com", DeleteUserOptions{Posts: test.policy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Posts != 3 {
+			t.Errorf("DeleteUser() with policy %d reported %d posts, expected 3", test.policy, result.Posts)
+		}
+
+		db, err := c.store.Load(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := db.Posts[unrelated.ID]; !got.equal(unrelated) {
+			t.Errorf("unrelated post = %v, expected %v", got, unrelated)
+		}
+		left := 0
+		for _, post := range db.Posts {
+			if post.ID == unrelated.ID {
+				continue
+			}
+			left++
+			if post.UserEmail != test.expectedAuthor {
+				t.Errorf("post author with policy %d = %s, expected %s", test.policy, post.UserEmail, test.expectedAuthor)
+			}
+		}
+		if left != test.expectedPosts {
+			t.Errorf("got %d posts left with policy %d, expected %d", left, test.policy, test.expectedPosts)
+		}
+	}
+}
+
+func TestCancelledContext(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, createErr := c.CreateUser(cancelled, "other@example.com", "12345", "jane doe", 18)
+	_, getErr := c.GetUser(cancelled, "test@example.com")
+	_, postsErr := c.GetPosts(cancelled, "test@example.com")
+	_, deleteErr := c.DeleteUser(cancelled, "test@example.com", DeleteUserOptions{})
+	for _, err := range []error{createErr, getErr, postsErr, deleteErr, c.EnsureDB(cancelled)} {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("call with cancelled ctx = %v, expected %v", err, context.Canceled)
+		}
+	}
+
+	// nothing was written
+	if _, err := c.GetUser(ctx, "other@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUser() = %v, expected %v", err, ErrUserNotFound)
+	}
+	if _, err := c.GetUser(ctx, "test@example.com"); err != nil {
+		t.Errorf("GetUser() = %v, expected nil", err)
+	}
+}
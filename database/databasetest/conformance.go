@@ -0,0 +1,449 @@
+// Package databasetest has a conformance suite every database.Repository implementation
+// must pass, so the backends behave the same for callers
+package databasetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Warren-Wang-OG/go-social-media-backend/database"
+)
+
+// Run -
+// exercise the repository returned by newRepo against the behavior of the json Client
+// newRepo must return a fresh, empty repository every time it's called, EnsureDB is called on it here
+// the suite checks that DefaultPasswordPolicy is enforced, so a json Client needs that policy
+func Run(t *testing.T, newRepo func(t *testing.T) database.Repository) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, ctx context.Context, repo database.Repository)
+	}{
+		{"EnsureDBIdempotent", testEnsureDBIdempotent},
+		{"CreateGetUser", testCreateGetUser},
+		{"CreateUserDuplicate", testCreateUserDuplicate},
+		{"InvalidEmail", testInvalidEmail},
+		{"WeakPassword", testWeakPassword},
+		{"InvalidAge", testInvalidAge},
+		{"MixedCaseEmail", testMixedCaseEmail},
+		{"UpdateUser", testUpdateUser},
+		{"DeleteUser", testDeleteUser},
+		{"DeleteUserPostPolicies", testDeleteUserPostPolicies},
+		{"CreatePostUnknownUser", testCreatePostUnknownUser},
+		{"PostText", testPostText},
+		{"GetPosts", testGetPosts},
+		{"DeletePost", testDeletePost},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+			if err := repo.EnsureDB(ctx); err != nil {
+				t.Fatalf("EnsureDB() = %v, expected nil", err)
+			}
+			test.run(t, ctx, repo)
+		})
+	}
+}
+
+// mustCreateUser creates a user or fails the test
+func mustCreateUser(t *testing.T, ctx context.Context, repo database.Repository, email string) database.User {
+	t.Helper()
+	user, err := repo.CreateUser(ctx, email, "correct horse", "john doe", 18)
+	if err != nil {
+		t.Fatalf("CreateUser(%s) = %v, expected nil", email, err)
+	}
+	return user
+}
+
+// mustCreatePost creates a post or fails the test
+func mustCreatePost(t *testing.T, ctx context.Context, repo database.Repository, email, text string) database.Post {
+	t.Helper()
+	post, err := repo.CreatePost(ctx, email, text)
+	if err != nil {
+		t.Fatalf("CreatePost(%s) = %v, expected nil", email, err)
+	}
+	return post
+}
+
+// recent fails the test if ts isn't a UTC timestamp from around now
+func recent(t *testing.T, name string, ts time.Time) {
+	t.Helper()
+	if ts.Location() != time.UTC {
+		t.Errorf("%s location = %v, expected UTC", name, ts.Location())
+	}
+	if time.Since(ts) > time.Minute || time.Until(ts) > time.Minute {
+		t.Errorf("%s = %v, expected about now", name, ts)
+	}
+}
+
+// verifies fails the test if user came back with a PasswordHash or password isn't the one stored for them
+func verifies(t *testing.T, ctx context.Context, repo database.Repository, user database.User, password string) {
+	t.Helper()
+	if user.PasswordHash != "" {
+		t.Errorf("PasswordHash = %q, expected it left out", user.PasswordHash)
+	}
+	if err := repo.VerifyPassword(ctx, user.Email, password); err != nil {
+		t.Errorf("VerifyPassword(%q) = %v, expected nil", password, err)
+	}
+	if err := repo.VerifyPassword(ctx, user.Email, password+"x"); !errors.Is(err, database.ErrWrongPassword) {
+		t.Errorf("VerifyPassword() of the wrong password = %v, expected %v", err, database.ErrWrongPassword)
+	}
+}
+
+func testEnsureDBIdempotent(t *testing.T, ctx context.Context, repo database.Repository) {
+	mustCreateUser(t, ctx, repo, "test@example.com")
+	if err := repo.EnsureDB(ctx); err != nil {
+		t.Fatalf("second EnsureDB() = %v, expected nil", err)
+	}
+	if _, err := repo.GetUser(ctx, "test@example.com"); err != nil {
+		t.Errorf("GetUser() after second EnsureDB() = %v, expected nil", err)
+	}
+}
+
+func testCreateGetUser(t *testing.T, ctx context.Context, repo database.Repository) {
+	created := mustCreateUser(t, ctx, repo, "test@example.com")
+	recent(t, "CreatedAt", created.CreatedAt)
+	verifies(t, ctx, repo, created, "correct horse")
+	expected := database.User{
+		CreatedAt: created.CreatedAt,
+		Email:     "test@example.com",
+		Name:      "john doe",
+		Age:       18,
+		UpdatedAt: created.CreatedAt,
+	}
+	if created != expected {
+		t.Errorf("CreateUser() = %+v, expected %+v", created, expected)
+	}
+
+	got, err := repo.GetUser(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != created {
+		t.Errorf("GetUser() = %+v, expected %+v", got, created)
+	}
+	if _, err := repo.GetUser(ctx, "missing@example.com"); !errors.Is(err, database.ErrUserNotFound) {
+		t.Errorf("GetUser() of missing user = %v, expected %v", err, database.ErrUserNotFound)
+	}
+	if err := repo.VerifyPassword(ctx, "missing@example.com", "correct horse"); !errors.Is(err, database.ErrUserNotFound) {
+		t.Errorf("VerifyPassword() of missing user = %v, expected %v", err, database.ErrUserNotFound)
+	}
+}
+
+func testCreateUserDuplicate(t *testing.T, ctx context.Context, repo database.Repository) {
+	original := mustCreateUser(t, ctx, repo, "test@example.com")
+	_, err := repo.CreateUser(ctx, "test@example.com", "hijacked account", "jane doe", 30)
+	if !errors.Is(err, database.ErrUserExists) {
+		t.Errorf("CreateUser() with taken email = %v, expected %v", err, database.ErrUserExists)
+	}
+	got, err := repo.GetUser(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != original {
+		t.Errorf("GetUser() after duplicate = %+v, expected %+v", got, original)
+	}
+	verifies(t, ctx, repo, got, "correct horse")
+}
+
+func testInvalidEmail(t *testing.T, ctx context.Context, repo database.Repository) {
+	if _, err := repo.CreateUser(ctx, "not an email", "correct horse", "john doe", 18); !errors.Is(err, database.ErrInvalidEmail) {
+		t.Errorf("CreateUser() with invalid email = %v, expected %v", err, database.ErrInvalidEmail)
+	}
+	if _, err := repo.UpdateUser(ctx, "John <test@example.com>", "correct horse", "john doe", 18); !errors.Is(err, database.ErrInvalidEmail) {
+		t.Errorf("UpdateUser() with invalid email = %v, expected %v", err, database.ErrInvalidEmail)
+	}
+
+	// surrounding whitespace is trimmed, so this is the same user as without it
+	created, err := repo.CreateUser(ctx, " test@example.com\n", "correct horse", "john doe", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Email != "test@example.com" {
+		t.Errorf("CreateUser() stored email %q, expected it trimmed", created.Email)
+	}
+	if _, err := repo.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18); !errors.Is(err, database.ErrUserExists) {
+		t.Errorf("CreateUser() without the whitespace = %v, expected %v", err, database.ErrUserExists)
+	}
+	if _, err := repo.UpdateUser(ctx, "  test@example.com", "battery staple", "jane doe", 30); err != nil {
+		t.Errorf("UpdateUser() with whitespace = %v, expected nil", err)
+	}
+}
+
+func testWeakPassword(t *testing.T, ctx context.Context, repo database.Repository) {
+	for _, password := range []string{"", "short", "password"} {
+		if _, err := repo.CreateUser(ctx, "test@example.com", password, "john doe", 18); !errors.Is(err, database.ErrWeakPassword) {
+			t.Errorf("CreateUser() with password %q = %v, expected %v", password, err, database.ErrWeakPassword)
+		}
+	}
+	if _, err := repo.GetUser(ctx, "test@example.com"); !errors.Is(err, database.ErrUserNotFound) {
+		t.Errorf("GetUser() after weak passwords = %v, expected %v", err, database.ErrUserNotFound)
+	}
+	original := mustCreateUser(t, ctx, repo, "test@example.com")
+	if _, err := repo.UpdateUser(ctx, "test@example.com", "123456", "jane doe", 30); !errors.Is(err, database.ErrWeakPassword) {
+		t.Errorf("UpdateUser() with a weak password = %v, expected %v", err, database.ErrWeakPassword)
+	}
+	if got, err := repo.GetUser(ctx, "test@example.com"); err != nil || got != original {
+		t.Errorf("GetUser() after a weak UpdateUser() = %+v, %v, expected %+v", got, err, original)
+	}
+	verifies(t, ctx, repo, original, "correct horse")
+}
+
+func testInvalidAge(t *testing.T, ctx context.Context, repo database.Repository) {
+	for _, age := range []int{-5, database.DefaultMinimumAge - 1, database.MaxAge + 1} {
+		if _, err := repo.CreateUser(ctx, "test@example.com", "correct horse", "john doe", age); !errors.Is(err, database.ErrInvalidAge) {
+			t.Errorf("CreateUser() with age %d = %v, expected %v", age, err, database.ErrInvalidAge)
+		}
+	}
+	if _, err := repo.GetUser(ctx, "test@example.com"); !errors.Is(err, database.ErrUserNotFound) {
+		t.Errorf("GetUser() after invalid ages = %v, expected %v", err, database.ErrUserNotFound)
+	}
+	original := mustCreateUser(t, ctx, repo, "test@example.com")
+	if _, err := repo.UpdateUser(ctx, "test@example.com", "battery staple", "jane doe", -5); !errors.Is(err, database.ErrInvalidAge) {
+		t.Errorf("UpdateUser() with age -5 = %v, expected %v", err, database.ErrInvalidAge)
+	}
+	if got, err := repo.GetUser(ctx, "test@example.com"); err != nil || got != original {
+		t.Errorf("GetUser() after an invalid UpdateUser() = %+v, %v, expected %+v", got, err, original)
+	}
+}
+
+func testMixedCaseEmail(t *testing.T, ctx context.Context, repo database.Repository) {
+	created := mustCreateUser(t, ctx, repo, "Bob@Example.com")
+	if created.Email != "bob@example.com" {
+		t.Errorf("CreateUser() stored email %q, expected it lowercased", created.Email)
+	}
+	if _, err := repo.CreateUser(ctx, "bob@example.com", "correct horse", "john doe", 18); !errors.Is(err, database.ErrUserExists) {
+		t.Errorf("CreateUser() of another case = %v, expected %v", err, database.ErrUserExists)
+	}
+	if got, err := repo.GetUser(ctx, "BOB@example.COM"); err != nil || got.Email != created.Email {
+		t.Errorf("GetUser() of another case = %+v, %v, expected %s", got, err, created.Email)
+	}
+	if _, err := repo.UpdateUser(ctx, "bob@EXAMPLE.com", "battery staple", "jane doe", 30); err != nil {
+		t.Errorf("UpdateUser() of another case = %v, expected nil", err)
+	}
+	post := mustCreatePost(t, ctx, repo, "BOB@EXAMPLE.COM", "hello")
+	if post.UserEmail != created.Email {
+		t.Errorf("CreatePost() UserEmail = %q, expected %q", post.UserEmail, created.Email)
+	}
+	if posts, err := repo.GetPosts(ctx, "Bob@example.com"); err != nil || len(posts) != 1 {
+		t.Errorf("GetPosts() of another case = %d posts, %v, expected 1", len(posts), err)
+	}
+	if result, err := repo.DeleteUser(ctx, "bOB@example.com", database.DeleteUserOptions{}); err != nil || !result.Deleted || result.Posts != 1 {
+		t.Errorf("DeleteUser() of another case = %+v, %v, expected the user and its post deleted", result, err)
+	}
+	if _, err := repo.GetUser(ctx, "bob@example.com"); !errors.Is(err, database.ErrUserNotFound) {
+		t.Errorf("GetUser() after DeleteUser() = %v, expected %v", err, database.ErrUserNotFound)
+	}
+}
+
+func testUpdateUser(t *testing.T, ctx context.Context, repo database.Repository) {
+	created := mustCreateUser(t, ctx, repo, "test@example.com")
+	updated, err := repo.UpdateUser(ctx, "test@example.com", "battery staple", "jane doe", 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifies(t, ctx, repo, updated, "battery staple")
+	recent(t, "UpdatedAt", updated.UpdatedAt)
+	if updated.UpdatedAt.Before(created.UpdatedAt) {
+		t.Errorf("UpdateUser() UpdatedAt = %v, expected no earlier than %v", updated.UpdatedAt, created.UpdatedAt)
+	}
+	expected := database.User{
+		CreatedAt: created.CreatedAt,
+		Email:     "test@example.com",
+		Name:      "jane doe",
+		Age:       30,
+		UpdatedAt: updated.UpdatedAt,
+	}
+	if updated != expected {
+		t.Errorf("UpdateUser() = %+v, expected %+v", updated, expected)
+	}
+	got, err := repo.GetUser(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != expected {
+		t.Errorf("GetUser() after update = %+v, expected %+v", got, expected)
+	}
+	if _, err := repo.UpdateUser(ctx, "missing@example.com", "battery staple", "x", 18); !errors.Is(err, database.ErrUserNotFound) {
+		t.Errorf("UpdateUser() of missing user = %v, expected %v", err, database.ErrUserNotFound)
+	}
+}
+
+func testDeleteUser(t *testing.T, ctx context.Context, repo database.Repository) {
+	mustCreateUser(t, ctx, repo, "test@example.com")
+	mustCreatePost(t, ctx, repo, "test@example.com", "bye")
+
+	if _, err := repo.DeleteUser(ctx, "missing@example.com", database.DeleteUserOptions{}); !errors.Is(err, database.ErrUserNotFound) {
+		t.Errorf("DeleteUser() of missing user = %v, expected %v", err, database.ErrUserNotFound)
+	}
+	result, err := repo.DeleteUser(ctx, "missing@example.com", database.DeleteUserOptions{IgnoreMissing: true})
+	if err != nil || result != (database.DeleteUserResult{}) {
+		t.Errorf("DeleteUser() of missing user with IgnoreMissing = %+v, %v, expected zero result and nil", result, err)
+	}
+
+	result, err = repo.DeleteUser(ctx, "test@example.com", database.DeleteUserOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (database.DeleteUserResult{Deleted: true, Posts: 1}); result != expected {
+		t.Errorf("DeleteUser() = %+v, expected %+v", result, expected)
+	}
+	if _, err := repo.GetUser(ctx, "test@example.com"); !errors.Is(err, database.ErrUserNotFound) {
+		t.Errorf("GetUser() after delete = %v, expected %v", err, database.ErrUserNotFound)
+	}
+}
+
+func testDeleteUserPostPolicies(t *testing.T, ctx context.Context, repo database.Repository) {
+	var tests = []struct {
+		policy         database.PostPolicy
+		expectedAuthor string // "" means the posts are gone
+	}{
+		{policy: database.PostsCascade, expectedAuthor: ""},
+		{policy: database.PostsAnonymize, expectedAuthor: database.DeletedUserEmail},
+		{policy: database.PostsKeep},
+	}
+
+	for i, test := range tests {
+		email := fmt.Sprintf("user%d@example.com", i)
+		if test.policy == database.PostsKeep {
+			test.expectedAuthor = email
+		}
+		mustCreateUser(t, ctx, repo, email)
+		mustCreatePost(t, ctx, repo, email, "first")
+		mustCreatePost(t, ctx, repo, email, "second")
+
+		result, err := repo.DeleteUser(ctx, email, database.DeleteUserOptions{Posts: test.policy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Posts != 2 {
+			t.Errorf("DeleteUser() with policy %d reported %d posts, expected 2", test.policy, result.Posts)
+		}
+
+		if test.expectedAuthor == "" {
+			posts, err := repo.GetPosts(ctx, email)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(posts) != 0 {
+				t.Errorf("got %d posts after cascade, expected 0", len(posts))
+			}
+			continue
+		}
+		posts, err := repo.GetPosts(ctx, test.expectedAuthor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kept := 0
+		for _, post := range posts {
+			if post.Text == "first" || post.Text == "second" {
+				kept++
+			}
+		}
+		if kept < 2 {
+			t.Errorf("got %d posts authored by %s with policy %d, expected at least 2", kept, test.expectedAuthor, test.policy)
+		}
+	}
+}
+
+func testCreatePostUnknownUser(t *testing.T, ctx context.Context, repo database.Repository) {
+	if _, err := repo.CreatePost(ctx, "missing@example.com", "hello"); !errors.Is(err, database.ErrUserNotFound) {
+		t.Errorf("CreatePost() for missing user = %v, expected %v", err, database.ErrUserNotFound)
+	}
+}
+
+func testPostText(t *testing.T, ctx context.Context, repo database.Repository) {
+	mustCreateUser(t, ctx, repo, "test@example.com")
+	if post := mustCreatePost(t, ctx, repo, "test@example.com", "  hello\n"); post.Text != "hello" {
+		t.Errorf("CreatePost() text = %q, expected it trimmed to %q", post.Text, "hello")
+	}
+	var tests = []struct {
+		text     string
+		expected error
+	}{
+		{text: "", expected: database.ErrEmptyPost},
+		{text: " \t\n", expected: database.ErrEmptyPost},
+		{text: strings.Repeat("x", database.DefaultMaxPostLength+1), expected: database.ErrPostTooLong},
+	}
+	for _, test := range tests {
+		if _, err := repo.CreatePost(ctx, "test@example.com", test.text); !errors.Is(err, test.expected) {
+			t.Errorf("CreatePost() with %d characters = %v, expected %v", len(test.text), err, test.expected)
+		}
+	}
+	// a long post in runes that are several bytes each is still within the limit
+	if _, err := repo.CreatePost(ctx, "test@example.com", strings.Repeat("é", database.DefaultMaxPostLength)); err != nil {
+		t.Errorf("CreatePost() with %d runes = %v, expected nil", database.DefaultMaxPostLength, err)
+	}
+	posts, err := repo.GetPosts(ctx, "test@example.com")
+	if err != nil || len(posts) != 2 {
+		t.Errorf("GetPosts() = %d posts, %v, expected the 2 valid ones", len(posts), err)
+	}
+}
+
+func testGetPosts(t *testing.T, ctx context.Context, repo database.Repository) {
+	mustCreateUser(t, ctx, repo, "test@example.com")
+	mustCreateUser(t, ctx, repo, "other@example.com")
+	first := mustCreatePost(t, ctx, repo, "test@example.com", "my cat is way too fat")
+	second := mustCreatePost(t, ctx, repo, "test@example.com", "my cat is getting skinny now")
+	mustCreatePost(t, ctx, repo, "other@example.com", "not mine")
+	recent(t, "CreatedAt", first.CreatedAt)
+	if first.ID == "" || first.ID == second.ID {
+		t.Errorf("post IDs %q and %q, expected unique and non-empty", first.ID, second.ID)
+	}
+
+	posts, err := repo.GetPosts(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// newest first is part of the contract, ties broken by ID
+	expected := []database.Post{first, second}
+	database.SortPosts(expected, database.NewestFirst)
+	if len(posts) != len(expected) {
+		t.Fatalf("GetPosts() = %+v, expected %+v", posts, expected)
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(posts[i], expected[i]) {
+			t.Errorf("GetPosts()[%d] = %+v, expected %+v", i, posts[i], expected[i])
+		}
+	}
+
+	none, err := repo.GetPosts(ctx, "nobody@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if none == nil || len(none) != 0 {
+		t.Errorf("GetPosts() of user without posts = %#v, expected empty non-nil slice", none)
+	}
+}
+
+func testDeletePost(t *testing.T, ctx context.Context, repo database.Repository) {
+	mustCreateUser(t, ctx, repo, "test@example.com")
+	post := mustCreatePost(t, ctx, repo, "test@example.com", "hello")
+
+	if _, err := repo.DeletePost(ctx, ""); !errors.Is(err, database.ErrEmptyPostID) {
+		t.Errorf("DeletePost(\"\") = %v, expected %v", err, database.ErrEmptyPostID)
+	}
+	if _, err := repo.DeletePost(ctx, "not-a-real-id"); !errors.Is(err, database.ErrPostNotFound) {
+		t.Errorf("DeletePost() of missing post = %v, expected %v", err, database.ErrPostNotFound)
+	}
+	deleted, err := repo.DeletePost(ctx, post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(deleted, post) {
+		t.Errorf("DeletePost() = %+v, expected %+v", deleted, post)
+	}
+	posts, err := repo.GetPosts(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 0 {
+		t.Errorf("got %d posts after delete, expected 0", len(posts))
+	}
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// UserStatus -
+// whether an account is in use, see DeactivateUser
+type UserStatus string
+
+const (
+	UserActive      UserStatus = "active"
+	UserDeactivated UserStatus = "deactivated"
+)
+
+// Active -
+// false only for a user that DeactivateUser was called for and ReactivateUser wasn't since.
+// says nothing about soft deletes, check DeletedAt for those
+func (u User) Active() bool {
+	return u.Status != UserDeactivated
+}
+
+// setStatus -
+// set the status of the user with email, the user and whether it changed.
+// soft-deleted users are ErrUserNotFound like for every other write
+func (tx *Tx) setStatus(email string, status UserStatus) (User, bool, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return User{}, false, err
+	}
+	email = EmailKey(email)
+	user, ok := db.activeUser(email)
+	if !ok {
+		return User{}, false, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+	if (status == UserActive) == user.Active() {
+		return user, false, nil
+	}
+	user.Status = status
+	user.UpdatedAt = tx.now()
+	db.putUser(user)
+	return user, true, nil
+}
+
+// DeactivateUser -
+// same as Client.DeactivateUser, inside the Tx
+func (tx *Tx) DeactivateUser(ctx context.Context, email string) (User, error) {
+	user, _, err := tx.setStatus(email, UserDeactivated)
+	return user, err
+}
+
+// ReactivateUser -
+// same as Client.ReactivateUser, inside the Tx
+func (tx *Tx) ReactivateUser(ctx context.Context, email string) (User, error) {
+	user, _, err := tx.setStatus(email, UserActive)
+	return user, err
+}
+
+// DeactivateUser -
+// let the user with email step away without losing anything. the account can't log in or post
+// (ErrAccountDeactivated) and its posts are left out of GetPosts, GetPublicPosts and IteratePosts,
+// but GetUser still finds it and nothing is deleted or expires. ReactivateUser undoes it at any time.
+// deactivating a deactivated user changes nothing
+func (c *Client) DeactivateUser(ctx context.Context, email string) (User, error) {
+	return c.setStatus(ctx, "DeactivateUser", email, UserDeactivated)
+}
+
+// ReactivateUser -
+// undo DeactivateUser, the account and all its posts are back as they were.
+// reactivating an active user changes nothing
+func (c *Client) ReactivateUser(ctx context.Context, email string) (User, error) {
+	return c.setStatus(ctx, "ReactivateUser", email, UserActive)
+}
+
+func (c *Client) setStatus(ctx context.Context, op, email string, status UserStatus) (User, error) {
+	user := User{}
+	err := c.update(ctx, op, email, func(db *Schema) error {
+		var changed bool
+		var err error
+		user, changed, err = c.newTx(db).setStatus(email, status)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return c.sanitize(user), nil
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// visiblePosts counts a@example.com's posts through each listing method
+func visiblePosts(t *testing.T, c *Client, opts IterateOptions) (posts, public, iterated int) {
+	t.Helper()
+	all, err := c.GetPosts(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shown, err := c.GetPublicPosts(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.IteratePosts(ctx, opts, func(post Post) bool {
+		if post.UserEmail == "a@example.com" {
+			iterated++
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(all), len(shown), iterated
+}
+
+func TestDeactivateUser(t *testing.T) {
+	c, clock := newSoftDeleteClient(t)
+	created, err := c.GetUser(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for round := 0; round < 2; round++ {
+		clock.Advance(time.Minute)
+		deactivated, err := c.DeactivateUser(ctx, "a@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deactivated.Active() || deactivated.Status != UserDeactivated || deactivated.UpdatedAt != clock.Now() {
+			t.Errorf("DeactivateUser() = %+v, expected a deactivated user updated at %v", deactivated, clock.Now())
+		}
+		if posts, public, iterated := visiblePosts(t, c, IterateOptions{}); posts != 0 || public != 0 || iterated != 0 {
+			t.Errorf("round %d: deactivated user's posts = %d, %d, %d, expected them hidden", round, posts, public, iterated)
+		}
+		if _, _, iterated := visiblePosts(t, c, IterateOptions{IncludeDeactivated: true}); iterated != 1+round {
+			t.Errorf("round %d: IteratePosts() with IncludeDeactivated = %d posts, expected %d", round, iterated, 1+round)
+		}
+		// still there, unlike a soft-deleted user
+		if got, err := c.GetUser(ctx, "a@example.com"); err != nil || got.Active() {
+			t.Errorf("round %d: GetUser() of a deactivated user = %+v, %v, expected it found and deactivated", round, got, err)
+		}
+		if _, err := c.CreatePost(ctx, "a@example.com", "not now"); !errors.Is(err, ErrAccountDeactivated) {
+			t.Errorf("round %d: CreatePost() of a deactivated user = %v, expected ErrAccountDeactivated", round, err)
+		}
+		if _, err := c.AuthenticateUser(ctx, "a@example.com", "correct horse"); !errors.Is(err, ErrAccountDeactivated) {
+			t.Errorf("round %d: AuthenticateUser() of a deactivated user = %v, expected ErrAccountDeactivated", round, err)
+		}
+		// the wrong password says nothing about the account
+		if _, err := c.AuthenticateUser(ctx, "a@example.com", "wrong horse"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("round %d: AuthenticateUser() with the wrong password = %v, expected ErrInvalidCredentials", round, err)
+		}
+		if again, err := c.DeactivateUser(ctx, "a@example.com"); err != nil || again != deactivated {
+			t.Errorf("round %d: second DeactivateUser() = %+v, %v, expected %+v", round, again, err, deactivated)
+		}
+
+		clock.Advance(time.Minute)
+		reactivated, err := c.ReactivateUser(ctx, "a@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reactivated.Active() || reactivated.UpdatedAt != clock.Now() {
+			t.Errorf("ReactivateUser() = %+v, expected an active user updated at %v", reactivated, clock.Now())
+		}
+		if posts, public, iterated := visiblePosts(t, c, IterateOptions{}); posts != 1+round || public != 1+round || iterated != 1+round {
+			t.Errorf("round %d: reactivated user's posts = %d, %d, %d, expected %d", round, posts, public, iterated, 1+round)
+		}
+		if _, err := c.AuthenticateUser(ctx, "a@example.com", "correct horse"); err != nil {
+			t.Errorf("round %d: AuthenticateUser() after ReactivateUser() = %v, expected nil", round, err)
+		}
+		if _, err := c.CreatePost(ctx, "a@example.com", "back"); err != nil {
+			t.Errorf("round %d: CreatePost() after ReactivateUser() = %v, expected nil", round, err)
+		}
+		// the login in between is all that changed
+		if again, err := c.ReactivateUser(ctx, "a@example.com"); err != nil || !again.Active() || again.UpdatedAt != reactivated.UpdatedAt {
+			t.Errorf("round %d: second ReactivateUser() = %+v, %v, expected %+v", round, again, err, reactivated)
+		}
+	}
+	// nothing but the status, UpdatedAt and the logins changed along the way
+	user, err := c.GetUser(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.Status, user.UpdatedAt = created.Status, created.UpdatedAt
+	user.LastLoginAt, user.LoginCount = created.LastLoginAt, created.LoginCount
+	if user != created {
+		t.Errorf("user after deactivating and reactivating = %+v, expected %+v", user, created)
+	}
+	// other users' posts are never affected
+	if posts, err := c.GetPosts(ctx, "b@example.com"); err != nil || len(posts) != 0 {
+		t.Errorf("GetPosts() of another user = %d posts, %v, expected 0", len(posts), err)
+	}
+}
+
+func TestDeactivateUserErrors(t *testing.T) {
+	c, _ := newSoftDeleteClient(t)
+	if _, err := c.SoftDeleteUser(ctx, "b@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		email    string
+		expected error
+	}{
+		{email: "missing@example.com", expected: ErrUserNotFound},
+		// soft-deleted users need RestoreUser, not ReactivateUser
+		{email: "b@example.com", expected: ErrUserNotFound},
+	}
+	for _, test := range tests {
+		if _, err := c.DeactivateUser(ctx, test.email); !errors.Is(err, test.expected) {
+			t.Errorf("DeactivateUser(%q) = %v, expected %v", test.email, err, test.expected)
+		}
+		if _, err := c.ReactivateUser(ctx, test.email); !errors.Is(err, test.expected) {
+			t.Errorf("ReactivateUser(%q) = %v, expected %v", test.email, err, test.expected)
+		}
+	}
+}
+
+func TestDeactivateUserPersists(t *testing.T) {
+	for name, opts := range map[string][]Option{"file": nil, "wal": {WithWAL(100)}} {
+		c, clock := newSoftDeleteClient(t, opts...)
+		if _, err := c.DeactivateUser(ctx, "a@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		reopened := NewClient(dbPath(c), append([]Option{WithClock(clock)}, opts...)...)
+		if user, err := reopened.GetUser(ctx, "a@example.com"); err != nil || user.Active() {
+			t.Errorf("%s: GetUser() after reopening = %+v, %v, expected a deactivated user", name, user, err)
+		}
+		if posts, err := reopened.GetPosts(ctx, "a@example.com"); err != nil || len(posts) != 0 {
+			t.Errorf("%s: GetPosts() after reopening = %d posts, %v, expected them hidden", name, len(posts), err)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
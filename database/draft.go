@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// CreateDraft -
+// same as Client.CreateDraft, inside the Tx
+func (tx *Tx) CreateDraft(ctx context.Context, authorEmail, text string) (Post, error) {
+	return tx.CreatePostWithOptions(ctx, authorEmail, text, CreatePostOptions{Draft: true})
+}
+
+// PublishDraft -
+// same as Client.PublishDraft, inside the Tx
+func (tx *Tx) PublishDraft(ctx context.Context, id, requesterEmail string) (Post, error) {
+	post, _, err := tx.publishDraft(id, requesterEmail)
+	return post, err
+}
+
+// publishDraft -
+// PublishDraft that also reports whether the post changed
+func (tx *Tx) publishDraft(id, requesterEmail string) (Post, bool, error) {
+	if id == "" {
+		return Post{}, false, ErrEmptyPostID
+	}
+	db, err := tx.schema()
+	if err != nil {
+		return Post{}, false, err
+	}
+	post, ok := db.livePost(id)
+	if !ok {
+		return Post{}, false, fmt.Errorf("%w: %s", ErrPostNotFound, id)
+	}
+	if err := tx.checkCanChangePost(db, post, requesterEmail); err != nil {
+		return Post{}, false, err
+	}
+	if !post.IsDraft {
+		return post, false, nil
+	}
+	// it's posted now, however long it was being written
+	post.IsDraft = false
+	post.CreatedAt = tx.now()
+	db.putPost(post)
+	return post, true, nil
+}
+
+// GetDrafts -
+// same as Client.GetDrafts, sees drafts created earlier in the Tx
+func (tx *Tx) GetDrafts(ctx context.Context, authorEmail, requesterEmail string) ([]Post, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return []Post{}, err
+	}
+	authorEmail = EmailKey(authorEmail)
+	if EmailKey(requesterEmail) != authorEmail {
+		return []Post{}, fmt.Errorf("%w: only %s can read its drafts", ErrPermissionDenied, authorEmail)
+	}
+	drafts := []Post{}
+	i := 0
+	for _, post := range db.Posts {
+		// full scan, bail out if the caller gave up
+		if i++; i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return []Post{}, err
+			}
+		}
+		if post.UserEmail == authorEmail && post.IsDraft && post.DeletedAt == nil {
+			drafts = append(drafts, post)
+		}
+	}
+	SortPosts(drafts, NewestFirst)
+	return drafts, nil
+}
+
+// CreateDraft -
+// CreatePost of a draft: it's stored and can be changed with UpdatePost, without an edit history,
+// but no listing or feed shows it. PublishDraft posts it, GetDrafts lists the author's drafts
+func (c *Client) CreateDraft(ctx context.Context, authorEmail, text string) (Post, error) {
+	return c.CreatePostWithOptions(ctx, authorEmail, text, CreatePostOptions{Draft: true})
+}
+
+// PublishDraft -
+// post the draft with id on behalf of requesterEmail, who has to be its author. its CreatedAt
+// becomes the time it's published, so it shows up in the listings as a new post. publishing a
+// post that isn't a draft changes nothing. ErrEmptyPostID for an empty id, ErrPostNotFound if
+// there's no such post, ErrNotPostAuthor for anyone but the author
+func (c *Client) PublishDraft(ctx context.Context, id, requesterEmail string) (Post, error) {
+	post := Post{}
+	err := c.update(ctx, "PublishDraft", id, func(db *Schema) error {
+		var changed bool
+		var err error
+		post, changed, err = c.newTx(db).publishDraft(id, requesterEmail)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+	if err != nil {
+		return Post{}, err
+	}
+	return post, nil
+}
+
+// GetDrafts -
+// the drafts of authorEmail newest first, for requesterEmail who has to be that author,
+// ErrPermissionDenied for anyone else. soft-deleted drafts are left out
+func (c *Client) GetDrafts(ctx context.Context, authorEmail, requesterEmail string) ([]Post, error) {
+	drafts := []Post{}
+	err := c.view(ctx, "GetDrafts", authorEmail, func(db *Schema) error {
+		var err error
+		drafts, err = c.newTx(db).GetDrafts(ctx, authorEmail, requesterEmail)
+		return err
+	})
+	if err != nil {
+		return []Post{}, err
+	}
+	return drafts, nil
+}
@@ -0,0 +1,170 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDrafts(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newBlockClient(t, WithClock(clock), WithIDGenerator(&SequenceIDGenerator{}))
+	if _, err := c.BootstrapAdmin(ctx, "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.FollowUser(ctx, "b@example.com", "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	draft, err := c.CreateDraft(ctx, "a@example.com", "work in progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !draft.IsDraft || !draft.CreatedAt.Equal(clock.Now()) {
+		t.Errorf("CreateDraft() = %+v, expected a draft created now", draft)
+	}
+
+	// no listing or feed shows it
+	feed := []Post{}
+	if err := c.IteratePosts(ctx, IterateOptions{Viewer: "b@example.com"}, func(post Post) bool {
+		feed = append(feed, post)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	all, err := c.GetAllPosts(ctx, ListOptions{IncludeDeleted: true, IncludeDeactivated: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	own, err := c.GetPosts(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, posts := range map[string][]Post{"IteratePosts": feed, "GetAllPosts": all, "GetPosts": own} {
+		for _, post := range posts {
+			if post.ID == draft.ID {
+				t.Errorf("%s listed the draft", name)
+			}
+		}
+	}
+	for _, viewer := range []string{"b@example.com", "c@example.com", ""} {
+		if _, err := c.GetPostAs(ctx, viewer, draft.ID); !errors.Is(err, ErrPostNotFound) {
+			t.Errorf("GetPostAs(%q) of a draft = %v, expected ErrPostNotFound", viewer, err)
+		}
+	}
+	if _, err := c.GetPostAs(ctx, "a@example.com", draft.ID); err != nil {
+		t.Errorf("GetPostAs() of a draft by its author = %v", err)
+	}
+
+	// only the author reads and changes drafts, admins included
+	var tests = []struct {
+		requester string
+		expected  error
+	}{
+		{requester: "b@example.com", expected: ErrPermissionDenied},
+		{requester: "c@example.com", expected: ErrPermissionDenied},
+		{requester: " A@example.com", expected: nil},
+	}
+	for _, test := range tests {
+		drafts, err := c.GetDrafts(ctx, "a@example.com", test.requester)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("GetDrafts() by %q = %v, expected %v", test.requester, err, test.expected)
+		}
+		if err == nil && !reflect.DeepEqual(postIDs(drafts), []string{draft.ID}) {
+			t.Errorf("GetDrafts() by %q = %v, expected %v", test.requester, postIDs(drafts), []string{draft.ID})
+		}
+	}
+	for _, requester := range []string{"b@example.com", "c@example.com"} {
+		if _, err := c.UpdatePost(ctx, draft.ID, requester, "hijacked"); !errors.Is(err, ErrNotPostAuthor) {
+			t.Errorf("UpdatePost() of a draft by %q = %v, expected ErrNotPostAuthor", requester, err)
+		}
+		if _, err := c.PublishDraft(ctx, draft.ID, requester); !errors.Is(err, ErrNotPostAuthor) {
+			t.Errorf("PublishDraft() by %q = %v, expected ErrNotPostAuthor", requester, err)
+		}
+	}
+
+	// editing it keeps no history
+	clock.Advance(time.Hour)
+	edited, err := c.UpdatePost(ctx, draft.ID, "a@example.com", "almost done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.EditedAt != nil {
+		t.Errorf("UpdatePost() of a draft set EditedAt")
+	}
+	if edits, err := c.GetPostEditHistory(ctx, draft.ID); err != nil || len(edits) != 0 {
+		t.Errorf("GetPostEditHistory() of a draft = %+v, %v, expected none", edits, err)
+	}
+
+	// publishing stamps the time it's posted
+	clock.Advance(time.Hour)
+	published, err := c.PublishDraft(ctx, draft.ID, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published.IsDraft || !published.CreatedAt.Equal(clock.Now()) || published.Text != "almost done" {
+		t.Errorf("PublishDraft() = %+v, expected a post created at %v", published, clock.Now())
+	}
+	// again changes nothing
+	clock.Advance(time.Hour)
+	if again, err := c.PublishDraft(ctx, draft.ID, "a@example.com"); err != nil || !again.equal(published) {
+		t.Errorf("PublishDraft() again = %+v, %v, expected %+v", again, err, published)
+	}
+	if posts, err := c.GetPosts(ctx, "a@example.com"); err != nil || len(posts) != 2 || !posts[0].equal(published) {
+		t.Errorf("GetPosts() after PublishDraft() = %+v, %v, expected the post first", posts, err)
+	}
+	if drafts, err := c.GetDrafts(ctx, "a@example.com", "a@example.com"); err != nil || len(drafts) != 0 {
+		t.Errorf("GetDrafts() after PublishDraft() = %+v, %v, expected none", drafts, err)
+	}
+	// from now on it's edited like any post
+	if _, err := c.UpdatePost(ctx, draft.ID, "a@example.com", "done"); err != nil {
+		t.Fatal(err)
+	}
+	if edits, err := c.GetPostEditHistory(ctx, draft.ID); err != nil || !reflect.DeepEqual(editTexts(edits), []string{"almost done"}) {
+		t.Errorf("GetPostEditHistory() after publishing = %v, %v, expected [almost done]", editTexts(edits), err)
+	}
+}
+
+func TestDeleteUserDrafts(t *testing.T) {
+	for _, policy := range []PostPolicy{PostsCascade, PostsAnonymize, PostsKeep} {
+		c := newBlockClient(t)
+		if _, err := c.CreateDraft(ctx, "a@example.com", "unfinished"); err != nil {
+			t.Fatal(err)
+		}
+		result, err := c.DeleteUser(ctx, "a@example.com", DeleteUserOptions{Posts: policy})
+		if err != nil || result.Posts != 2 {
+			t.Errorf("policy %v: DeleteUser() = %+v, %v, expected 2 posts", policy, result, err)
+		}
+		db, err := c.Dump(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, post := range db.Posts {
+			if post.IsDraft {
+				t.Errorf("policy %v: draft left after DeleteUser(): %+v", policy, post)
+			}
+		}
+	}
+
+	// and purging a soft-deleted user
+	c := newBlockClient(t)
+	if _, err := c.CreateDraft(ctx, "a@example.com", "unfinished"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SoftDeleteUser(ctx, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.PurgeDeletedUsers(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	db, err := c.Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, post := range db.Posts {
+		if post.IsDraft {
+			t.Errorf("draft left after PurgeDeletedUsers(): %+v", post)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package database
+
+import "time"
+
+// emailEdges -
+// key,value = email,emails it points at and since when. the shape of blocks and follows,
+// one direction only, a nil emailEdges has no edges
+type emailEdges map[string]map[string]time.Time
+
+// has -
+// whether from points at to
+func (e emailEdges) has(from, to string) bool {
+	_, ok := e[from][to]
+	return ok
+}
+
+// put -
+// make from point at to since at
+func (e *emailEdges) put(from, to string, at time.Time) {
+	if *e == nil {
+		*e = make(emailEdges)
+	}
+	if (*e)[from] == nil {
+		(*e)[from] = make(map[string]time.Time)
+	}
+	(*e)[from][to] = at
+}
+
+// delete -
+// drop the edge from from to to, if there's one
+func (e emailEdges) delete(from, to string) {
+	delete(e[from], to)
+	if len(e[from]) == 0 {
+		delete(e, from)
+	}
+}
+
+// drop -
+// drop every edge from or to email
+func (e emailEdges) drop(email string) {
+	delete(e, email)
+	for from := range e {
+		e.delete(from, email)
+	}
+}
+
+// move -
+// rekey every edge from or to oldEmail to newEmail
+func (e *emailEdges) move(oldEmail, newEmail string) {
+	for from, to := range *e {
+		if at, ok := to[oldEmail]; ok {
+			e.delete(from, oldEmail)
+			e.put(from, newEmail, at)
+		}
+	}
+	if to, ok := (*e)[oldEmail]; ok {
+		delete(*e, oldEmail)
+		for email, at := range to {
+			e.put(newEmail, email, at)
+		}
+	}
+}
+
+// mergeInto -
+// like move, but into may have edges of its own: where both had the same edge the older time
+// is kept, and edges between the two are dropped. the number of edges into didn't have before
+func (e *emailEdges) mergeInto(from, into string) int {
+	moved := 0
+	keep := func(a, b string, at time.Time) {
+		if a == b {
+			return
+		}
+		if prev, ok := (*e)[a][b]; !ok {
+			moved++
+		} else if prev.Before(at) {
+			return
+		}
+		e.put(a, b, at)
+	}
+	for other, to := range *e {
+		if at, ok := to[from]; ok && other != from {
+			e.delete(other, from)
+			keep(other, into, at)
+		}
+	}
+	for other, at := range (*e)[from] {
+		keep(into, other, at)
+	}
+	delete(*e, from)
+	return moved
+}
+
+// clone -
+// copy of the edges that can be modified without touching the original, nil stays nil
+func (e emailEdges) clone() emailEdges {
+	if e == nil {
+		return nil
+	}
+	copied := make(emailEdges, len(e))
+	for from, to := range e {
+		copied[from] = make(map[string]time.Time, len(to))
+		for email, at := range to {
+			copied[from][email] = at
+		}
+	}
+	return copied
+}
+
+// reversed -
+// the same edges pointing the other way
+func (e emailEdges) reversed() emailEdges {
+	var reversed emailEdges
+	for from, to := range e {
+		for email, at := range to {
+			reversed.put(email, from, at)
+		}
+	}
+	return reversed
+}
+
+// diffEdges -
+// the log entries that turn old into e, putOp and deleteOp name the kind of edge
+func diffEdges(old, e emailEdges, putOp, deleteOp string) []walEntry {
+	entries := []walEntry{}
+	for from, to := range e {
+		for email, at := range to {
+			if prev, ok := old[from][email]; !ok || !prev.Equal(at) {
+				at := at
+				entries = append(entries, walEntry{Op: putOp, Email: from, ID: email, At: &at})
+			}
+		}
+	}
+	for from, to := range old {
+		for email := range to {
+			if !e.has(from, email) {
+				entries = append(entries, walEntry{Op: deleteOp, Email: from, ID: email})
+			}
+		}
+	}
+	return entries
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// NormalizeEmail -
+// trim surrounding whitespace from email and check what's left is a plain address,
+// no display name or angle brackets, with a non-empty local part and domain.
+// returns the trimmed and lowercased email or ErrInvalidEmail. every backend runs user emails
+// through it before storing them so " A@b.com " and "a@b.com" are the same user everywhere
+func NormalizeEmail(email string) (string, error) {
+	trimmed := strings.TrimSpace(email)
+	if trimmed == "" {
+		return "", fmt.Errorf("%w: empty", ErrInvalidEmail)
+	}
+	addr, err := mail.ParseAddress(trimmed)
+	if err != nil || addr.Name != "" || addr.Address != trimmed {
+		return "", fmt.Errorf("%w: %q", ErrInvalidEmail, email)
+	}
+	at := strings.LastIndexByte(trimmed, '@')
+	if at <= 0 || at == len(trimmed)-1 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidEmail, email)
+	}
+	return strings.ToLower(trimmed), nil
+}
+
+// EmailKey -
+// the key a user with email is stored under, for lookups. an email NormalizeEmail rejects
+// can't belong to a user and is returned as is so the lookup finds nothing
+func EmailKey(email string) string {
+	if normalized, err := NormalizeEmail(email); err == nil {
+		return normalized
+	}
+	return email
+}
+
+// ChangeEmail -
+// same as Client.ChangeEmail, inside the Tx
+func (tx *Tx) ChangeEmail(ctx context.Context, oldEmail, newEmail string) (User, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return User{}, err
+	}
+	if newEmail, err = NormalizeEmail(newEmail); err != nil {
+		return User{}, err
+	}
+	oldEmail = EmailKey(oldEmail)
+	user, ok := db.activeUser(oldEmail)
+	if !ok {
+		return User{}, fmt.Errorf("%w: %s", ErrUserNotFound, oldEmail)
+	}
+	if newEmail == oldEmail {
+		return user, nil
+	}
+	if _, ok := db.Users[newEmail]; ok {
+		return User{}, fmt.Errorf("%w: %s", ErrUserExists, newEmail)
+	}
+
+	// verifying the old address says nothing about the new one
+	db.dropVerificationTokens(oldEmail)
+	if err := db.moveUser(ctx, oldEmail, newEmail); err != nil {
+		return User{}, err
+	}
+	user = db.Users[newEmail]
+	user.Verified = false
+	user.UpdatedAt = tx.now()
+	db.putUser(user)
+	return user, nil
+}
+
+// moveUser -
+// rekey the user stored under oldEmail to newEmail, which must be free, along with
+// everything that points at the user by email. new kinds of records referencing users
+// need rewriting here too, and in mergeUser
+func (db *Schema) moveUser(ctx context.Context, oldEmail, newEmail string) error {
+	user := db.Users[oldEmail]
+	if err := db.movePosts(ctx, oldEmail, newEmail); err != nil {
+		return err
+	}
+	for key, reset := range db.ResetTokens {
+		if reset.UserEmail == oldEmail {
+			reset.UserEmail = newEmail
+			db.ResetTokens[key] = reset
+		}
+	}
+	for key, verification := range db.VerificationTokens {
+		if verification.UserEmail == oldEmail {
+			verification.UserEmail = newEmail
+			db.VerificationTokens[key] = verification
+		}
+	}
+	db.Blocks.move(oldEmail, newEmail)
+	db.Mutes.move(oldEmail, newEmail)
+	db.Follows.move(oldEmail, newEmail)
+	db.Followers.move(oldEmail, newEmail)
+	db.FollowRequests.move(oldEmail, newEmail)
+	db.moveFriendRequests(oldEmail, newEmail)
+	db.moveMentions(oldEmail, newEmail)
+	db.Reactions.move(oldEmail, newEmail)
+	db.PollVotes.move(oldEmail, newEmail)
+	if history, ok := db.NameHistory[oldEmail]; ok {
+		delete(db.NameHistory, oldEmail)
+		db.NameHistory[newEmail] = history
+	}
+
+	// deleteUser would drop the tokens just moved, only the user and its username go
+	if db.Usernames[user.Username] == oldEmail {
+		delete(db.Usernames, user.Username)
+	}
+	delete(db.Users, oldEmail)
+	user.Email = newEmail
+	db.putUser(user)
+	return nil
+}
+
+// movePosts -
+// give every post of oldEmail to newEmail
+func (db *Schema) movePosts(ctx context.Context, oldEmail, newEmail string) error {
+	i := 0
+	for _, post := range db.Posts {
+		// full scan, bail out if the caller gave up
+		if i++; i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if post.UserEmail == oldEmail {
+			post.UserEmail = newEmail
+			db.putPost(post)
+		}
+	}
+	return nil
+}
+
+// ChangeEmail -
+// move the user with oldEmail to newEmail, which is checked like CreateUser checks emails.
+// the user keeps everything else, CreatedAt included, and their posts follow them,
+// except Verified which is cleared until the new email is verified,
+// all in one write so a failure leaves the db as it was. emails are compared lowercased,
+// so a change of case alone changes nothing.
+// ErrUserNotFound if there's no user with oldEmail, ErrUserExists if newEmail is taken
+func (c *Client) ChangeEmail(ctx context.Context, oldEmail, newEmail string) (User, error) {
+	user := User{}
+	err := c.update(ctx, "ChangeEmail", oldEmail, func(db *Schema) error {
+		var err error
+		user, err = c.newTx(db).ChangeEmail(ctx, oldEmail, newEmail)
+		if err == nil && user.Email == EmailKey(oldEmail) {
+			return errNoop
+		}
+		return err
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return c.sanitize(user), nil
+}
@@ -0,0 +1,316 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	var tests = []struct {
+		email    string
+		expected string // "" means ErrInvalidEmail
+	}{
+		{email: "test@example.com", expected: "test@example.com"},
+		{email: "first.last+tag@sub.example.co.uk", expected: "first.last+tag@sub.example.co.uk"},
+		{email: "a@b", expected: "a@b"},
+		// case
+		{email: "Bob@Example.com", expected: "bob@example.com"},
+		{email: " TEST@EXAMPLE.COM ", expected: "test@example.com"},
+		{email: "User@Exämple.com", expected: "user@exämple.com"},
+		// unicode
+		{email: "user@exämple.com", expected: "user@exämple.com"},
+		{email: "测试@例子.中国", expected: "测试@例子.中国"},
+		// whitespace
+		{email: " test@example.com ", expected: "test@example.com"},
+		{email: "\ttest@example.com\n", expected: "test@example.com"},
+		{email: "test @example.com"},
+		{email: "   "},
+		// invalid
+		{email: ""},
+		{email: "not an email"},
+		{email: "test"},
+		{email: "@example.com"},
+		{email: "test@"},
+		{email: "test@@example.com"},
+		{email: "john doe <test@example.com>"},
+		{email: "<test@example.com>"},
+		{email: "test.@example.com"},
+		{email: "a@b.com, c@d.com"},
+	}
+
+	for _, test := range tests {
+		got, err := NormalizeEmail(test.email)
+		if test.expected == "" {
+			if !errors.Is(err, ErrInvalidEmail) {
+				t.Errorf("NormalizeEmail(%q) = %q, %v, expected ErrInvalidEmail", test.email, got, err)
+			}
+			continue
+		}
+		if err != nil || got != test.expected {
+			t.Errorf("NormalizeEmail(%q) = %q, %v, expected %q", test.email, got, err, test.expected)
+		}
+	}
+}
+
+func TestEmailKey(t *testing.T) {
+	var tests = []struct {
+		email    string
+		expected string
+	}{
+		{email: "test@example.com", expected: "test@example.com"},
+		{email: " Test@Example.COM\n", expected: "test@example.com"},
+		// not an email, nothing is stored under it either way
+		{email: "Not An Email", expected: "Not An Email"},
+		{email: DeletedUserEmail, expected: DeletedUserEmail},
+		{email: "", expected: ""},
+	}
+	for _, test := range tests {
+		if got := EmailKey(test.email); got != test.expected {
+			t.Errorf("EmailKey(%q) = %q, expected %q", test.email, got, test.expected)
+		}
+	}
+}
+
+func TestMixedCaseEmails(t *testing.T) {
+	c := newTestClient(t)
+	created, err := c.CreateUser(ctx, "Bob@Example.com", "123456", "bob", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Email != "bob@example.com" {
+		t.Errorf("CreateUser() stored email %q, expected bob@example.com", created.Email)
+	}
+	for _, email := range []string{"bob@example.com", "BOB@EXAMPLE.COM", " bob@Example.com "} {
+		if _, err := c.CreateUser(ctx, email, "123456", "bob", 18); !errors.Is(err, ErrUserExists) {
+			t.Errorf("CreateUser(%q) = %v, expected ErrUserExists", email, err)
+		}
+	}
+
+	if _, err := c.CreatePost(ctx, "BOB@example.com", "hello"); err != nil {
+		t.Fatalf("CreatePost() of another case = %v, expected nil", err)
+	}
+	var tests = []struct {
+		name string
+		call func(email string) error
+	}{
+		{name: "GetUser", call: func(email string) error {
+			_, err := c.GetUser(ctx, email)
+			return err
+		}},
+		{name: "GetUserIncludingDeleted", call: func(email string) error {
+			_, err := c.GetUserIncludingDeleted(ctx, email)
+			return err
+		}},
+		{name: "UpdateUser", call: func(email string) error {
+			_, err := c.UpdateUser(ctx, email, "123456", "bob", 19)
+			return err
+		}},
+		{name: "VerifyPassword", call: func(email string) error {
+			return c.VerifyPassword(ctx, email, "123456")
+		}},
+		{name: "GetPosts", call: func(email string) error {
+			posts, err := c.GetPosts(ctx, email)
+			if err == nil && len(posts) != 1 {
+				return fmt.Errorf("%d posts, expected 1", len(posts))
+			}
+			return err
+		}},
+		{name: "IteratePosts", call: func(email string) error {
+			n := 0
+			err := c.IteratePosts(ctx, IterateOptions{UserEmail: email}, func(Post) bool {
+				n++
+				return true
+			})
+			if err == nil && n != 1 {
+				return fmt.Errorf("%d posts, expected 1", n)
+			}
+			return err
+		}},
+	}
+	for _, test := range tests {
+		for _, email := range []string{"bob@example.com", "Bob@Example.com", "BOB@EXAMPLE.COM"} {
+			if err := test.call(email); err != nil {
+				t.Errorf("%s(%q) = %v, expected nil", test.name, email, err)
+			}
+		}
+	}
+
+	result, err := c.DeleteUser(ctx, "BoB@eXaMpLe.CoM", DeleteUserOptions{})
+	if err != nil || !result.Deleted || result.Posts != 1 {
+		t.Errorf("DeleteUser() of another case = %+v, %v, expected the user and its post deleted", result, err)
+	}
+	if users, _ := c.Dump(ctx); len(users.Users) != 0 || len(users.Posts) != 0 {
+		t.Errorf("DeleteUser() left %d users and %d posts, expected none", len(users.Users), len(users.Posts))
+	}
+}
+
+func TestCreateUserInvalidEmail(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "not an email", "123456", "john doe", 18); !errors.Is(err, ErrInvalidEmail) {
+		t.Errorf("CreateUser() = %v, expected ErrInvalidEmail", err)
+	}
+	if _, err := c.UpsertUser(ctx, "", "123456", "john doe", 18); !errors.Is(err, ErrInvalidEmail) {
+		t.Errorf("UpsertUser() = %v, expected ErrInvalidEmail", err)
+	}
+	err := c.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		_, err := tx.CreateUser(ctx, "@example.com", "123456", "john doe", 18)
+		return err
+	})
+	if !errors.Is(err, ErrInvalidEmail) {
+		t.Errorf("Tx CreateUser() = %v, expected ErrInvalidEmail", err)
+	}
+	if users, _ := c.Dump(ctx); len(users.Users) != 0 {
+		t.Errorf("invalid emails stored %d users, expected none", len(users.Users))
+	}
+}
+
+// newChangeEmailClient has a@example.com with the username alice and posts posts, and b@example.com with one post
+func newChangeEmailClient(t *testing.T, posts int, opts ...Option) *Client {
+	t.Helper()
+	c := newUsernameClient(t, opts...)
+	if _, err := c.SetUsername(ctx, "a@example.com", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	err := c.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		for i := 0; i < posts; i++ {
+			if _, err := tx.CreatePost(ctx, "a@example.com", fmt.Sprintf("post %d", i)); err != nil {
+				return err
+			}
+		}
+		_, err := tx.CreatePost(ctx, "b@example.com", "not alice's")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestChangeEmail(t *testing.T) {
+	// more posts than a cancel check interval, so the rewrite goes past one
+	const posts = cancelCheckInterval + 10
+	c := newChangeEmailClient(t, posts)
+	before, err := c.GetUser(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := c.CreatePasswordResetToken(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := c.ChangeEmail(ctx, "a@example.com", " new@example.com ")
+	if err != nil {
+		t.Fatalf("ChangeEmail() = %v, expected nil", err)
+	}
+	expected := before
+	expected.Email = "new@example.com"
+	expected.UpdatedAt = user.UpdatedAt
+	if user != expected || user.UpdatedAt.Before(before.UpdatedAt) {
+		t.Errorf("ChangeEmail() = %+v, expected %+v", user, expected)
+	}
+	if _, err := c.GetUser(ctx, "a@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUser() of the old email = %v, expected ErrUserNotFound", err)
+	}
+	if got, err := c.GetUserByUsername(ctx, "alice"); err != nil || got != expected {
+		t.Errorf("GetUserByUsername() = %+v, %v, expected %+v", got, err, expected)
+	}
+	if moved, err := c.GetPosts(ctx, "new@example.com"); err != nil || len(moved) != posts {
+		t.Errorf("GetPosts() of the new email = %d posts, %v, expected %d", len(moved), err, posts)
+	}
+	if left, err := c.GetPosts(ctx, "a@example.com"); err != nil || len(left) != 0 {
+		t.Errorf("GetPosts() of the old email = %d posts, %v, expected none", len(left), err)
+	}
+	if others, err := c.GetPosts(ctx, "b@example.com"); err != nil || len(others) != 1 {
+		t.Errorf("GetPosts() of another user = %d posts, %v, expected 1", len(others), err)
+	}
+	// an outstanding reset token still works for the user
+	if err := c.RedeemPasswordResetToken(ctx, token, "battery staple"); err != nil {
+		t.Errorf("RedeemPasswordResetToken() after ChangeEmail() = %v, expected nil", err)
+	}
+	if err := c.VerifyPassword(ctx, "new@example.com", "battery staple"); err != nil {
+		t.Errorf("VerifyPassword() of the new email = %v, expected nil", err)
+	}
+	if issues, err := c.Validate(ctx); err != nil || len(issues) != 0 {
+		t.Errorf("Validate() after ChangeEmail() = %v, %v, expected no issues", issues, err)
+	}
+}
+
+func TestChangeEmailErrors(t *testing.T) {
+	c := newChangeEmailClient(t, 3)
+	data, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		oldEmail string
+		newEmail string
+		expected error
+	}{
+		{oldEmail: "a@example.com", newEmail: "b@example.com", expected: ErrUserExists},
+		{oldEmail: "a@example.com", newEmail: " b@example.com", expected: ErrUserExists},
+		{oldEmail: "a@example.com", newEmail: "not an email", expected: ErrInvalidEmail},
+		{oldEmail: "a@example.com", newEmail: DeletedUserEmail, expected: ErrInvalidEmail},
+		{oldEmail: "missing@example.com", newEmail: "new@example.com", expected: ErrUserNotFound},
+	}
+	for _, test := range tests {
+		if _, err := c.ChangeEmail(ctx, test.oldEmail, test.newEmail); !errors.Is(err, test.expected) {
+			t.Errorf("ChangeEmail(%q, %q) = %v, expected %v", test.oldEmail, test.newEmail, err, test.expected)
+		}
+	}
+	// changing to the same email changes nothing
+	if user, err := c.ChangeEmail(ctx, "a@example.com", "a@example.com"); err != nil || user.Email != "a@example.com" {
+		t.Errorf("ChangeEmail() to the same email = %+v, %v, expected the user unchanged", user, err)
+	}
+	if after, err := os.ReadFile(dbPath(c)); err != nil || !bytes.Equal(after, data) {
+		t.Errorf("failed ChangeEmail() calls changed the db file: %v", err)
+	}
+}
+
+func TestChangeEmailCase(t *testing.T) {
+	c := newChangeEmailClient(t, 2)
+	before, err := c.GetUser(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// emails are lowercased, a change of case is no change
+	user, err := c.ChangeEmail(ctx, "a@example.com", "A@Example.com")
+	if err != nil || user != before {
+		t.Fatalf("ChangeEmail() of the case only = %+v, %v, expected %+v unchanged", user, err, before)
+	}
+	if posts, err := c.GetPosts(ctx, "A@Example.com"); err != nil || len(posts) != 2 {
+		t.Errorf("GetPosts() of the new case = %d posts, %v, expected 2", len(posts), err)
+	}
+	// and the old email can be given in any case
+	user, err = c.ChangeEmail(ctx, "A@EXAMPLE.COM", "New@Example.com")
+	if err != nil || user.Email != "new@example.com" {
+		t.Errorf("ChangeEmail() from another case = %+v, %v, expected new@example.com", user, err)
+	}
+}
+
+func TestChangeEmailPersists(t *testing.T) {
+	for name, opts := range map[string][]Option{"file": nil, "wal": {WithWAL(100)}} {
+		c := newChangeEmailClient(t, 3, opts...)
+		if _, err := c.ChangeEmail(ctx, "a@example.com", "new@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		reopened := NewClient(dbPath(c), opts...)
+		if got, err := reopened.GetUserByUsername(ctx, "alice"); err != nil || got.Email != "new@example.com" {
+			t.Errorf("%s: GetUserByUsername() after reopening = %+v, %v, expected new@example.com", name, got, err)
+		}
+		if posts, err := reopened.GetPosts(ctx, "new@example.com"); err != nil || len(posts) != 3 {
+			t.Errorf("%s: GetPosts() after reopening = %d posts, %v, expected 3", name, len(posts), err)
+		}
+		if _, err := reopened.GetUser(ctx, "a@example.com"); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("%s: GetUser() of the old email after reopening = %v, expected ErrUserNotFound", name, err)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
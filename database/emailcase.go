@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"sort"
+)
+
+// EmailRepairOptions -
+// controls Client.RepairEmailCase
+type EmailRepairOptions struct {
+	// DryRun reports what would change without writing anything
+	DryRun bool
+	// Merge folds users whose emails differ only by case into one, see RepairEmailCase.
+	// without it they're left as they are and only reported
+	Merge bool
+}
+
+// EmailRepairReport -
+// what RepairEmailCase changed, or would have with DryRun
+type EmailRepairReport struct {
+	// Renamed is the number of users moved to their NormalizeEmail form
+	Renamed int
+	// Collisions are the stored emails that differ only by case, one sorted group per address,
+	// in order of the address
+	Collisions [][]string
+	// Merged is the number of users folded into another by Merge
+	Merged int
+}
+
+// RepairEmailCase -
+// one-time fix for a db written before emails were lowercased. every user stored under an email
+// NormalizeEmail would change moves to the normalized one, posts and tokens with it, in a single write.
+// users whose emails collide once lowercased are reported and left alone unless opts.Merge,
+// then the oldest of them that isn't soft-deleted keeps its account under the normalized email,
+// the others' posts, follows and the rest move to it like MergeUsers and the others are deleted with their tokens.
+// until it runs the lookups, which lowercase, don't find users stored under another case.
+// nothing is written if there's nothing to change
+func (c *Client) RepairEmailCase(ctx context.Context, opts EmailRepairOptions) (EmailRepairReport, error) {
+	report := EmailRepairReport{Collisions: [][]string{}}
+	err := c.update(ctx, "RepairEmailCase", "", func(db *Schema) error {
+		groups := map[string][]string{}
+		for email := range db.Users {
+			key := EmailKey(email)
+			groups[key] = append(groups[key], email)
+		}
+		for _, key := range sortedKeys(groups) {
+			emails := groups[key]
+			if len(emails) == 1 && emails[0] == key {
+				continue
+			}
+			keeper := emails[0]
+			if len(emails) > 1 {
+				sort.Strings(emails)
+				report.Collisions = append(report.Collisions, emails)
+				if !opts.Merge {
+					continue
+				}
+				keeper = mergeKeeper(*db, emails)
+				for _, email := range emails {
+					if email == keeper {
+						continue
+					}
+					if _, err := c.newTx(db).mergeUser(ctx, keeper, email, MergeKeepPrimary); err != nil {
+						return err
+					}
+					report.Merged++
+				}
+			}
+			if keeper != key {
+				if err := db.moveUser(ctx, keeper, key); err != nil {
+					return err
+				}
+				report.Renamed++
+			}
+		}
+		if opts.DryRun || (report.Renamed == 0 && report.Merged == 0) {
+			return errNoop
+		}
+		return nil
+	})
+	if err != nil {
+		return EmailRepairReport{}, err
+	}
+	return report, nil
+}
+
+// mergeKeeper -
+// the user of emails whose account a merge keeps: the oldest one that isn't soft-deleted,
+// the oldest of all if they all are. ties go to the first email in order
+func mergeKeeper(db Schema, emails []string) string {
+	keeper := ""
+	for _, email := range emails {
+		user := db.Users[email]
+		if keeper == "" {
+			keeper = email
+			continue
+		}
+		best := db.Users[keeper]
+		if (user.DeletedAt == nil) != (best.DeletedAt == nil) {
+			if user.DeletedAt == nil {
+				keeper = email
+			}
+			continue
+		}
+		if user.CreatedAt.Before(best.CreatedAt) {
+			keeper = email
+		}
+	}
+	return keeper
+}
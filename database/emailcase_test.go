@@ -0,0 +1,173 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newEmailCaseClient has a db from before lowercasing: Alice@Example.com with the username alice,
+// a post and a reset token, three case variants of bob@example.com with a post each,
+// the oldest soft-deleted, and carol@example.com
+func newEmailCaseClient(t *testing.T) *Client {
+	t.Helper()
+	c := newTestClient(t)
+	t0 := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	deleted := t0.Add(time.Hour)
+	legacy := Schema{
+		Users: map[string]User{
+			"Alice@Example.com": {Email: "Alice@Example.com", Name: "alice", Age: 18, Username: "alice", CreatedAt: t0},
+			"BOB@example.com":   {Email: "BOB@example.com", Name: "bob 1", Age: 18, CreatedAt: t0, DeletedAt: &deleted},
+			"Bob@Example.com":   {Email: "Bob@Example.com", Name: "bob 2", Age: 18, CreatedAt: t0.Add(time.Minute)},
+			"bob@example.com":   {Email: "bob@example.com", Name: "bob 3", Age: 18, CreatedAt: t0.Add(2 * time.Minute)},
+			"carol@example.com": {Email: "carol@example.com", Name: "carol", Age: 18, CreatedAt: t0},
+		},
+		Posts: map[string]Post{
+			"1": {ID: "1", UserEmail: "Alice@Example.com", Text: "a", CreatedAt: t0},
+			"2": {ID: "2", UserEmail: "BOB@example.com", Text: "b1", CreatedAt: t0},
+			"3": {ID: "3", UserEmail: "Bob@Example.com", Text: "b2", CreatedAt: t0.Add(time.Minute)},
+			"4": {ID: "4", UserEmail: "bob@example.com", Text: "b3", CreatedAt: t0.Add(2 * time.Minute)},
+		},
+		ResetTokens: map[string]ResetToken{
+			"hash": {UserEmail: "Alice@Example.com", CreatedAt: t0, ExpiresAt: t0.Add(time.Hour)},
+		},
+	}
+	if err := c.Load(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestRepairEmailCaseDryRun(t *testing.T) {
+	c := newEmailCaseClient(t)
+	before, err := c.Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, merge := range []bool{false, true} {
+		report, err := c.RepairEmailCase(ctx, EmailRepairOptions{DryRun: true, Merge: merge})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := EmailRepairReport{
+			Renamed:    1,
+			Collisions: [][]string{{"BOB@example.com", "Bob@Example.com", "bob@example.com"}},
+		}
+		if merge {
+			expected.Renamed, expected.Merged = 2, 2
+		}
+		if !reflect.DeepEqual(report, expected) {
+			t.Errorf("RepairEmailCase(DryRun, Merge: %v) = %+v, expected %+v", merge, report, expected)
+		}
+	}
+	if after, _ := c.Dump(ctx); !reflect.DeepEqual(after, before) {
+		t.Errorf("RepairEmailCase() with DryRun changed the db to %+v", after)
+	}
+}
+
+func TestRepairEmailCase(t *testing.T) {
+	c := newEmailCaseClient(t)
+	report, err := c.RepairEmailCase(ctx, EmailRepairOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := EmailRepairReport{
+		Renamed:    1,
+		Collisions: [][]string{{"BOB@example.com", "Bob@Example.com", "bob@example.com"}},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("RepairEmailCase() = %+v, expected %+v", report, expected)
+	}
+
+	// alice moved with everything pointing at her
+	user, err := c.GetUserByUsername(ctx, "alice")
+	if err != nil || user.Email != "alice@example.com" || user.Name != "alice" {
+		t.Errorf("GetUserByUsername() after the repair = %+v, %v, expected alice@example.com", user, err)
+	}
+	if posts, err := c.GetPosts(ctx, "Alice@Example.com"); err != nil || len(posts) != 1 || posts[0].UserEmail != "alice@example.com" {
+		t.Errorf("GetPosts() after the repair = %+v, %v, expected post 1 of alice@example.com", posts, err)
+	}
+	db, err := c.Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reset := db.ResetTokens["hash"]; reset.UserEmail != "alice@example.com" {
+		t.Errorf("reset token after the repair is for %q, expected alice@example.com", reset.UserEmail)
+	}
+	// the collisions are only reported
+	for _, email := range []string{"BOB@example.com", "Bob@Example.com", "bob@example.com", "carol@example.com"} {
+		if _, ok := db.Users[email]; !ok {
+			t.Errorf("RepairEmailCase() without Merge dropped %s", email)
+		}
+	}
+	if len(db.Users) != 5 || len(db.Posts) != 4 {
+		t.Errorf("RepairEmailCase() left %d users and %d posts, expected 5 and 4", len(db.Users), len(db.Posts))
+	}
+}
+
+func TestRepairEmailCaseMerge(t *testing.T) {
+	c := newEmailCaseClient(t)
+	report, err := c.RepairEmailCase(ctx, EmailRepairOptions{Merge: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := EmailRepairReport{
+		Renamed:    2,
+		Collisions: [][]string{{"BOB@example.com", "Bob@Example.com", "bob@example.com"}},
+		Merged:     2,
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("RepairEmailCase(Merge) = %+v, expected %+v", report, expected)
+	}
+
+	// the oldest that isn't soft-deleted keeps the account, with the posts of all three
+	user, err := c.GetUser(ctx, "bob@example.com")
+	if err != nil || user.Name != "bob 2" || user.Email != "bob@example.com" {
+		t.Errorf("GetUser() after the merge = %+v, %v, expected bob 2 under bob@example.com", user, err)
+	}
+	posts, err := c.GetPosts(ctx, "bob@example.com")
+	if err != nil || len(posts) != 3 {
+		t.Errorf("GetPosts() after the merge = %d posts, %v, expected 3", len(posts), err)
+	}
+	db, err := c.Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emails := sortedKeys(db.Users)
+	if !reflect.DeepEqual(emails, []string{"alice@example.com", "bob@example.com", "carol@example.com"}) {
+		t.Errorf("users after the merge = %q, expected one per address", emails)
+	}
+
+	// once repaired there's nothing left to do
+	report, err = c.RepairEmailCase(ctx, EmailRepairOptions{Merge: true})
+	if err != nil || !reflect.DeepEqual(report, EmailRepairReport{Collisions: [][]string{}}) {
+		t.Errorf("second RepairEmailCase() = %+v, %v, expected an empty report", report, err)
+	}
+}
+
+func TestMergeKeeper(t *testing.T) {
+	t0 := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	deleted := t0
+	var tests = []struct {
+		users    []User
+		expected string
+	}{
+		{users: []User{{Email: "A@x", CreatedAt: t0.Add(time.Minute)}, {Email: "a@x", CreatedAt: t0}}, expected: "a@x"},
+		// ties go to the first email in order
+		{users: []User{{Email: "A@x", CreatedAt: t0}, {Email: "a@x", CreatedAt: t0}}, expected: "A@x"},
+		// soft-deleted users lose to any other
+		{users: []User{{Email: "A@x", CreatedAt: t0, DeletedAt: &deleted}, {Email: "a@x", CreatedAt: t0.Add(time.Hour)}}, expected: "a@x"},
+		{users: []User{{Email: "A@x", CreatedAt: t0.Add(time.Hour), DeletedAt: &deleted}, {Email: "a@x", CreatedAt: t0, DeletedAt: &deleted}}, expected: "a@x"},
+	}
+	for _, test := range tests {
+		db := Schema{Users: map[string]User{}}
+		emails := []string{}
+		for _, user := range test.users {
+			db.Users[user.Email] = user
+			emails = append(emails, user.Email)
+		}
+		if got := mergeKeeper(db, emails); got != test.expected {
+			t.Errorf("mergeKeeper(%q) = %q, expected %q", emails, got, test.expected)
+		}
+	}
+}
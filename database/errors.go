@@ -0,0 +1,210 @@
+package database
+
+import "errors"
+
+// sentinel errors returned by the Client, possibly wrapped with context like the email or post ID
+// compare with errors.Is
+var (
+	// ErrUserNotFound -
+	// no user with the given email
+	ErrUserNotFound = errors.New("user doesn't exist")
+	// ErrUserDeleted -
+	// the user was soft-deleted, it can't post until RestoreUser brings it back
+	ErrUserDeleted = errors.New("user has been deleted")
+	// ErrRestoreExpired -
+	// the user or post was soft-deleted longer ago than WithDeletedUserRetention or
+	// WithDeletedPostRetention allows restoring
+	ErrRestoreExpired = errors.New("deleted too long ago to restore")
+	// ErrAccountDeactivated -
+	// the user deactivated their account, it can't log in or post until ReactivateUser.
+	// unlike ErrUserDeleted the account is still shown and needs no restore
+	ErrAccountDeactivated = errors.New("account is deactivated")
+	// ErrUserExists -
+	// a user with the given email is already stored
+	ErrUserExists = errors.New("user already exists")
+	// ErrInvalidEmail -
+	// the email given for a user isn't a plain address like name@example.com
+	ErrInvalidEmail = errors.New("invalid email")
+	// ErrInvalidUsername -
+	// the username breaks the character or length policy, see SetUsername
+	ErrInvalidUsername = errors.New("invalid username")
+	// ErrUsernameTaken -
+	// another user already has the username
+	ErrUsernameTaken = errors.New("username is already taken")
+	// ErrVerificationTokenInvalid -
+	// no outstanding email verification for the token, it was never issued or has been removed
+	ErrVerificationTokenInvalid = errors.New("invalid email verification token")
+	// ErrVerificationTokenExpired -
+	// the email verification token is past its expiry, see WithVerificationTokenTTL
+	ErrVerificationTokenExpired = errors.New("email verification token has expired")
+	// ErrVerificationTokenUsed -
+	// the email verification token was already redeemed
+	ErrVerificationTokenUsed = errors.New("email verification token has already been used")
+	// ErrEmailNotVerified -
+	// the user hasn't verified their email and the client requires it, see WithRequireVerifiedEmail
+	ErrEmailNotVerified = errors.New("email address is not verified")
+	// ErrInvalidRole -
+	// a role other than RoleUser, RoleModerator and RoleAdmin
+	ErrInvalidRole = errors.New("invalid role")
+	// ErrPermissionDenied -
+	// the acting user's role doesn't allow the operation
+	ErrPermissionDenied = errors.New("permission denied")
+	// ErrSelfBlock -
+	// a user tried to block themselves
+	ErrSelfBlock = errors.New("users can't block themselves")
+	// ErrSelfFollow -
+	// a user tried to follow themselves
+	ErrSelfFollow = errors.New("users can't follow themselves")
+	// ErrSelfMute -
+	// a user tried to mute themselves
+	ErrSelfMute = errors.New("users can't mute themselves")
+	// ErrInvalidMerge -
+	// MergeUsers was asked to merge a user into itself, or with a conflict policy it doesn't know
+	ErrInvalidMerge = errors.New("invalid user merge")
+	// ErrSelfFriendRequest -
+	// a user tried to send themselves a friend request
+	ErrSelfFriendRequest = errors.New("users can't befriend themselves")
+	// ErrFriendRequestNotFound -
+	// no pending friend request between the users to accept, decline or cancel,
+	// or no friendship to remove
+	ErrFriendRequestNotFound = errors.New("friend request doesn't exist")
+	// ErrFollowRequestNotFound -
+	// no pending request to follow the private user to approve or deny
+	ErrFollowRequestNotFound = errors.New("follow request doesn't exist")
+	// ErrInvalidAge -
+	// an age under the client's WithMinimumAge or over MaxAge
+	ErrInvalidAge = errors.New("invalid age")
+	// ErrInvalidUserField -
+	// a value given to UpdateUserFields or UpdateProfile can't be stored,
+	// like a blank name or a website that isn't a URL
+	ErrInvalidUserField = errors.New("invalid user field")
+	// ErrInvalidSetting -
+	// a setting key that isn't namespaced, a value that can't be encoded or settings over the size limits,
+	// see SetUserSetting
+	ErrInvalidSetting = errors.New("invalid user setting")
+	// ErrWeakPassword -
+	// a new password breaks the client's PasswordPolicy, the error is a *PasswordError saying which rules
+	ErrWeakPassword = errors.New("password doesn't meet the policy")
+	// ErrWrongPassword -
+	// the password doesn't match the user's
+	ErrWrongPassword = errors.New("wrong password")
+	// ErrPasswordReused -
+	// ChangePassword was given the current password as the new one
+	ErrPasswordReused = errors.New("new password is the same as the current one")
+	// ErrInvalidCredentials -
+	// returned by AuthenticateUser for an unknown email and a wrong password alike,
+	// so callers can't tell which accounts exist
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrResetTokenInvalid -
+	// the password reset token was never issued, or has been purged or superseded
+	ErrResetTokenInvalid = errors.New("invalid password reset token")
+	// ErrResetTokenExpired -
+	// the password reset token is past its expiry, see WithResetTokenTTL
+	ErrResetTokenExpired = errors.New("password reset token has expired")
+	// ErrResetTokenUsed -
+	// the password reset token was already redeemed, each one works once
+	ErrResetTokenUsed = errors.New("password reset token has already been used")
+	// ErrInvalidListOptions -
+	// GetUsers or SearchUsers was given a negative offset or limit
+	ErrInvalidListOptions = errors.New("invalid list options")
+	// ErrInvalidCursor -
+	// a ListOptions.Cursor that no listing returned, or one that was changed
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrEmptySearchQuery -
+	// SearchUsers was given nothing but whitespace to look for
+	ErrEmptySearchQuery = errors.New("empty search query")
+	// ErrPostNotFound -
+	// no post with the given ID
+	ErrPostNotFound = errors.New("post doesn't exist")
+	// ErrEmptyPost -
+	// a post's text is empty or only whitespace
+	ErrEmptyPost = errors.New("post text can't be empty")
+	// ErrPostTooLong -
+	// a post's text has more characters than WithMaxPostLength allows
+	ErrPostTooLong = errors.New("post text is too long")
+	// ErrInvalidAttachment -
+	// a post attachment that can't be stored, or more of them than MaxAttachments
+	ErrInvalidAttachment = errors.New("invalid post attachment")
+	// ErrInvalidVisibility -
+	// a post visibility other than VisibilityPublic, VisibilityFollowers and VisibilityPrivate
+	ErrInvalidVisibility = errors.New("invalid post visibility")
+	// ErrNotScheduled -
+	// the post isn't waiting to be published, it was never scheduled or it's already out
+	ErrNotScheduled = errors.New("post is not scheduled")
+	// ErrPostNotPublished -
+	// a draft or a post scheduled for later where only a published post will do, like PinPost
+	ErrPostNotPublished = errors.New("post is not published")
+	// ErrAlreadyReposted -
+	// the user already has a repost of the post, a post is shared once per user
+	ErrAlreadyReposted = errors.New("post is already reposted")
+	// ErrRepostNotEditable -
+	// UpdatePost or UpdatePostAttachments of a repost, it only points at the original
+	ErrRepostNotEditable = errors.New("a repost can't be edited")
+	// ErrInvalidReaction -
+	// a reaction kind AddReaction can't store, empty, too long or with spaces in it
+	ErrInvalidReaction = errors.New("invalid reaction")
+	// ErrReactionNotFound -
+	// GetUserReaction of a user who didn't react to the post
+	ErrReactionNotFound = errors.New("reaction not found")
+	// ErrInvalidPoll -
+	// CreatePoll with too few or too many options, an empty, long or repeated one, or a duration out of range
+	ErrInvalidPoll = errors.New("invalid poll")
+	// ErrInvalidPollOption -
+	// a Vote for an option index the poll doesn't have
+	ErrInvalidPollOption = errors.New("invalid poll option")
+	// ErrNotPoll -
+	// a Vote or GetPollResults of a post that isn't a poll
+	ErrNotPoll = errors.New("post is not a poll")
+	// ErrPollClosed -
+	// a Vote from the poll's ClosesAt on
+	ErrPollClosed = errors.New("poll is closed")
+	// ErrInvalidHashtag -
+	// a tag GetPostsByHashtag was asked for that no post could have, see hashtags
+	ErrInvalidHashtag = errors.New("invalid hashtag")
+	// ErrNotPostAuthor -
+	// someone other than the author or an admin tried to change a post
+	ErrNotPostAuthor = errors.New("not the author of the post")
+	// ErrPostExists -
+	// a different post with the same ID is already stored
+	ErrPostExists = errors.New("post already exists")
+	// ErrEmptyPostID -
+	// a post ID is required but an empty string was given
+	ErrEmptyPostID = errors.New("post id can't be empty")
+	// ErrDBCorrupt -
+	// the db file couldn't be parsed as a database
+	ErrDBCorrupt = errors.New("database file is corrupt")
+	// ErrNotDatabase -
+	// the file at the db path exists but doesn't look like a database at all,
+	// unlike ErrDBCorrupt Recover leaves it alone
+	ErrNotDatabase = errors.New("file is not a database")
+	// ErrChecksumMismatch -
+	// the db file's contents don't match the checksum stored with them
+	ErrChecksumMismatch = errors.New("database checksum mismatch")
+	// ErrDecryptFailed -
+	// the db file couldn't be decrypted, the key is wrong or the file was tampered with
+	ErrDecryptFailed = errors.New("failed to decrypt database file")
+	// ErrUnsupportedVersion -
+	// the db file was written by a newer version of this package
+	ErrUnsupportedVersion = errors.New("unsupported database schema version")
+	// ErrNotSupported -
+	// the operation isn't available for the client's Store
+	ErrNotSupported = errors.New("not supported by this store")
+	// ErrDatabaseLocked -
+	// another process held the db lock for longer than the lock timeout
+	ErrDatabaseLocked = errors.New("database is locked by another process")
+	// ErrReadOnly -
+	// a mutating method was called on a read-only client
+	ErrReadOnly = errors.New("client is read-only")
+	// ErrNestedTx -
+	// the client was called from inside one of its own Tx callbacks
+	ErrNestedTx = errors.New("nested transactions are not supported")
+	// ErrTxDone -
+	// a Tx was used after its callback returned
+	ErrTxDone = errors.New("transaction has already finished")
+	// ErrDatabaseFull -
+	// the write would grow the db past WithMaxSizeBytes, nothing was written
+	ErrDatabaseFull = errors.New("database is full")
+	// ErrInvalidOption -
+	// an Option was given a value it can't work with, returned by NewClientE
+	ErrInvalidOption = errors.New("invalid client option")
+)
@@ -0,0 +1,75 @@
+package database
+
+import "context"
+
+// ExistsOptions -
+// controls Client.UserExists, UsersExist and PostExists
+type ExistsOptions struct {
+	// IncludeDeactivated and IncludeDeleted count deactivated and soft-deleted users as existing,
+	// and posts by them, IncludeDeleted soft-deleted posts too. both are left out by default,
+	// so a yes means an account that's in use
+	IncludeDeactivated bool
+	IncludeDeleted     bool
+}
+
+// counts -
+// whether user exists as far as opts are concerned
+func (opts ExistsOptions) counts(user User) bool {
+	if user.DeletedAt != nil && !opts.IncludeDeleted {
+		return false
+	}
+	return user.Active() || opts.IncludeDeactivated
+}
+
+// UserExists -
+// whether there's a user with email, without copying the user like GetUser does.
+// the email is looked up like everywhere else, trimmed and lowercased
+func (c *Client) UserExists(ctx context.Context, email string, opts ExistsOptions) (bool, error) {
+	exists := false
+	err := c.view(ctx, "UserExists", email, func(db *Schema) error {
+		user, ok := db.Users[EmailKey(email)]
+		exists = ok && opts.counts(user)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// UsersExist -
+// UserExists for each of emails in one read, keyed by the emails as given
+func (c *Client) UsersExist(ctx context.Context, emails []string, opts ExistsOptions) (map[string]bool, error) {
+	exists := make(map[string]bool, len(emails))
+	err := c.view(ctx, "UsersExist", "", func(db *Schema) error {
+		for _, email := range emails {
+			user, ok := db.Users[EmailKey(email)]
+			exists[email] = ok && opts.counts(user)
+		}
+		return nil
+	})
+	if err != nil {
+		return map[string]bool{}, err
+	}
+	return exists, nil
+}
+
+// PostExists -
+// whether there's a post with id. soft-deleted posts and posts of a deactivated or soft-deleted author only count
+// with the matching option, anonymized posts and posts whose author is gone always do
+func (c *Client) PostExists(ctx context.Context, id string, opts ExistsOptions) (bool, error) {
+	exists := false
+	err := c.view(ctx, "PostExists", id, func(db *Schema) error {
+		post, ok := db.Posts[id]
+		if !ok || (post.DeletedAt != nil && !opts.IncludeDeleted) {
+			return nil
+		}
+		author, ok := db.Users[post.UserEmail]
+		exists = !ok || opts.counts(author)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return exists, nil
+}
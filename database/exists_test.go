@@ -0,0 +1,120 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+// newExistsClient has active@, deactivated@ and deleted@example.com with a post each,
+// and an anonymized post of a user deleted for good. returns the post ids by author
+func newExistsClient(t *testing.T) (*Client, map[string]string) {
+	t.Helper()
+	c := newTestClient(t)
+	posts := map[string]string{}
+	for _, name := range []string{"active", "deactivated", "deleted", "gone"} {
+		email := name + "@example.com"
+		if _, err := c.CreateUser(ctx, email, "123456", name, 18); err != nil {
+			t.Fatal(err)
+		}
+		post, err := c.CreatePost(ctx, email, "hello")
+		if err != nil {
+			t.Fatal(err)
+		}
+		posts[name] = post.ID
+	}
+	if _, err := c.DeactivateUser(ctx, "deactivated@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SoftDeleteUser(ctx, "deleted@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DeleteUser(ctx, "gone@example.com", DeleteUserOptions{Posts: PostsAnonymize}); err != nil {
+		t.Fatal(err)
+	}
+	return c, posts
+}
+
+func TestUserExists(t *testing.T) {
+	c, _ := newExistsClient(t)
+	var tests = []struct {
+		email    string
+		opts     ExistsOptions
+		expected bool
+	}{
+		{email: "active@example.com", expected: true},
+		{email: " Active@Example.com ", expected: true},
+		{email: "missing@example.com", expected: false},
+		{email: "not an email", expected: false},
+		{email: "gone@example.com", opts: ExistsOptions{IncludeDeactivated: true, IncludeDeleted: true}, expected: false},
+		{email: "deactivated@example.com", expected: false},
+		{email: "deactivated@example.com", opts: ExistsOptions{IncludeDeactivated: true}, expected: true},
+		{email: "deleted@example.com", expected: false},
+		{email: "deleted@example.com", opts: ExistsOptions{IncludeDeactivated: true}, expected: false},
+		{email: "deleted@example.com", opts: ExistsOptions{IncludeDeleted: true}, expected: true},
+	}
+	for _, test := range tests {
+		if got, err := c.UserExists(ctx, test.email, test.opts); err != nil || got != test.expected {
+			t.Errorf("UserExists(%q, %+v) = %v, %v, expected %v", test.email, test.opts, got, err, test.expected)
+		}
+	}
+}
+
+func TestUsersExist(t *testing.T) {
+	c, _ := newExistsClient(t)
+	emails := []string{"active@example.com", "ACTIVE@example.com", "deactivated@example.com", "deleted@example.com", "missing@example.com"}
+	var tests = []struct {
+		opts     ExistsOptions
+		expected map[string]bool
+	}{
+		{expected: map[string]bool{
+			"active@example.com": true, "ACTIVE@example.com": true,
+			"deactivated@example.com": false, "deleted@example.com": false, "missing@example.com": false,
+		}},
+		{opts: ExistsOptions{IncludeDeactivated: true, IncludeDeleted: true}, expected: map[string]bool{
+			"active@example.com": true, "ACTIVE@example.com": true,
+			"deactivated@example.com": true, "deleted@example.com": true, "missing@example.com": false,
+		}},
+	}
+	for _, test := range tests {
+		if got, err := c.UsersExist(ctx, emails, test.opts); err != nil || !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("UsersExist(%+v) = %v, %v, expected %v", test.opts, got, err, test.expected)
+		}
+	}
+	if got, err := c.UsersExist(ctx, nil, ExistsOptions{}); err != nil || len(got) != 0 {
+		t.Errorf("UsersExist(nil) = %v, %v, expected an empty map", got, err)
+	}
+}
+
+func TestPostExists(t *testing.T) {
+	c, posts := newExistsClient(t)
+	var tests = []struct {
+		id       string
+		opts     ExistsOptions
+		expected bool
+	}{
+		{id: posts["active"], expected: true},
+		{id: "missing", expected: false},
+		{id: "", expected: false},
+		// anonymized, no author to hide it
+		{id: posts["gone"], expected: true},
+		{id: posts["deactivated"], expected: false},
+		{id: posts["deactivated"], opts: ExistsOptions{IncludeDeactivated: true}, expected: true},
+		{id: posts["deleted"], expected: false},
+		{id: posts["deleted"], opts: ExistsOptions{IncludeDeleted: true}, expected: true},
+	}
+	for _, test := range tests {
+		if got, err := c.PostExists(ctx, test.id, test.opts); err != nil || got != test.expected {
+			t.Errorf("PostExists(%q, %+v) = %v, %v, expected %v", test.id, test.opts, got, err, test.expected)
+		}
+	}
+}
+
+func TestExistsUnloaded(t *testing.T) {
+	c := NewClient(dbPath(newTestClient(t)))
+	if ok, err := c.UserExists(ctx, "test@example.com", ExistsOptions{}); err != nil || ok {
+		t.Errorf("UserExists() on an empty db = %v, %v, expected false", ok, err)
+	}
+	if ok, err := c.PostExists(ctx, "1", ExistsOptions{}); err != nil || ok {
+		t.Errorf("PostExists() on an empty db = %v, %v, expected false", ok, err)
+	}
+}
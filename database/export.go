@@ -0,0 +1,161 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// exportEdge -
+// one entry of a follow, block or mute list in ExportUserData, the other user and since when
+type exportEdge struct {
+	Email string    `json:"email"`
+	Since time.Time `json:"since"`
+}
+
+// exportEdges -
+// the edges of e from email, sorted by email so the export is the same every time
+func exportEdges(e emailEdges, email string) []exportEdge {
+	edges := []exportEdge{}
+	for _, other := range sortedKeys(e[email]) {
+		edges = append(edges, exportEdge{Email: other, Since: e[email][other]})
+	}
+	return edges
+}
+
+// exportEdgesTo -
+// the edges of e pointing at email, sorted like exportEdges
+func exportEdgesTo(e emailEdges, email string) []exportEdge {
+	edges := []exportEdge{}
+	for _, from := range sortedKeys(e) {
+		if at, ok := e[from][email]; ok {
+			edges = append(edges, exportEdge{Email: from, Since: at})
+		}
+	}
+	return edges
+}
+
+// jsonStreamWriter -
+// writes a JSON object piece by piece, keeping the first error so the calls can be chained
+type jsonStreamWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+// raw writes s as is
+func (s *jsonStreamWriter) raw(text string) {
+	if s.err == nil {
+		_, s.err = s.w.WriteString(text)
+	}
+}
+
+// value writes v encoded
+func (s *jsonStreamWriter) value(v any) {
+	if s.err != nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		s.err = err
+		return
+	}
+	_, s.err = s.w.Write(data)
+}
+
+// field writes ,"key":v, without the comma for the first field of the object
+func (s *jsonStreamWriter) field(first bool, key string, v any) {
+	if !first {
+		s.raw(",")
+	}
+	s.value(key)
+	s.raw(":")
+	s.value(v)
+}
+
+// ExportUserData -
+// write everything stored about the user with email to w as a single JSON object, for a
+// "send me my data" request: the user as GetUser returns it (settings included, never the
+// password hash), every post oldest first, deactivated or not, the users it follows, is followed
+// by, asked to follow, blocked and muted, its friend requests and friendships and its past names.
+// the fields are
+//
+//	exportedAt, user, posts, following, followers, followRequestsSent, followRequestsReceived,
+//	blocked, muted, friendRequests, nameHistory
+//
+// always all of them, lists empty when there's nothing, and timestamps are RFC 3339.
+// posts are written one at a time from a snapshot of the db, so a big account isn't built up
+// in memory first and the client isn't held up by a slow w. ErrUserNotFound if there's no such user
+func (c *Client) ExportUserData(ctx context.Context, email string, w io.Writer) error {
+	var snapshot *Schema
+	var user User
+	err := c.view(ctx, "ExportUserData", email, func(db *Schema) error {
+		var ok bool
+		if user, ok = db.activeUser(EmailKey(email)); !ok {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, EmailKey(email))
+		}
+		// like IteratePosts, writes replace c.mem and never touch db's maps in place
+		snapshot = db
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	email = user.Email
+	user.PasswordHash = ""
+
+	// only what sorting needs is gathered, each post is looked up again as it is written
+	posts := []Post{}
+	i := 0
+	for _, post := range snapshot.Posts {
+		if i++; i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if post.UserEmail == email {
+			posts = append(posts, Post{ID: post.ID, CreatedAt: post.CreatedAt})
+		}
+	}
+	SortPosts(posts, OldestFirst)
+
+	friendRequests := []FriendRequest{}
+	for _, key := range sortedKeys(snapshot.FriendRequests) {
+		if request := snapshot.FriendRequests[key]; request.From == email || request.To == email {
+			friendRequests = append(friendRequests, request)
+		}
+	}
+
+	s := &jsonStreamWriter{w: bufio.NewWriter(w)}
+	s.raw("{")
+	s.field(true, "exportedAt", c.clock.Now().UTC())
+	s.field(false, "user", user)
+	s.raw(`,"posts":[`)
+	for i, post := range posts {
+		if i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if i > 0 {
+			s.raw(",")
+		}
+		s.value(snapshot.Posts[post.ID])
+	}
+	s.raw("]")
+	s.field(false, "following", exportEdges(snapshot.Follows, email))
+	s.field(false, "followers", exportEdgesTo(snapshot.Follows, email))
+	s.field(false, "followRequestsSent", exportEdges(snapshot.FollowRequests, email))
+	s.field(false, "followRequestsReceived", exportEdgesTo(snapshot.FollowRequests, email))
+	s.field(false, "blocked", exportEdges(snapshot.Blocks, email))
+	s.field(false, "muted", exportEdges(snapshot.Mutes, email))
+	s.field(false, "friendRequests", friendRequests)
+	s.field(false, "nameHistory", append([]NameChange{}, snapshot.NameHistory[email]...))
+	s.raw("}\n")
+	if s.err != nil {
+		return s.err
+	}
+	return s.w.Flush()
+}
@@ -0,0 +1,156 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// what ExportUserData writes for a@ of newExportClient
+const exportGolden = `{
+	"exportedAt": "2024-01-01T00:05:00Z",
+	"user": {
+		"createdAt": "2024-01-01T00:00:00Z",
+		"email": "a@example.com",
+		"username": "alice",
+		"name": "name a",
+		"age": 18,
+		"updatedAt": "2024-01-01T00:04:00Z",
+		"lastLoginAt": "0001-01-01T00:00:00Z",
+		"settings": {"ui.darkMode": true}
+	},
+	"posts": [
+		{"id": "00000000000000000001", "createdAt": "2024-01-01T00:01:00Z", "userEmail": "a@example.com", "text": "first"},
+		{"id": "00000000000000000002", "createdAt": "2024-01-01T00:02:00Z", "userEmail": "a@example.com", "text": "second"}
+	],
+	"following": [],
+	"followers": [{"email": "c@example.com", "since": "2024-01-01T00:04:00Z"}],
+	"followRequestsSent": [{"email": "b@example.com", "since": "2024-01-01T00:04:00Z"}],
+	"followRequestsReceived": [],
+	"blocked": [{"email": "d@example.com", "since": "2024-01-01T00:04:00Z"}],
+	"muted": [{"email": "b@example.com", "since": "2024-01-01T00:04:00Z"}],
+	"friendRequests": [
+		{"from": "c@example.com", "to": "a@example.com", "status": "pending", "createdAt": "2024-01-01T00:04:00Z", "respondedAt": "0001-01-01T00:00:00Z"}
+	],
+	"nameHistory": [
+		{"field": "username", "old": "", "new": "alice", "changedAt": "2024-01-01T00:04:00Z"}
+	]
+}`
+
+// newExportClient has a@ with a username, a setting, two posts and a bit of everything else,
+// the rest of the users are what it points at
+func newExportClient(t *testing.T) *Client {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newTestClient(t, WithClock(clock), WithIDGenerator(&SequenceIDGenerator{}))
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "123456", "name "+email[:1], 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, text := range []string{"first", "second"} {
+		clock.Advance(time.Minute)
+		if _, err := c.CreatePost(ctx, "a@example.com", text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock.Advance(time.Minute)
+	if _, err := c.CreatePost(ctx, "b@example.com", "not a@'s"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetPrivate(ctx, "b@example.com", true); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	steps := []error{
+		c.FollowUser(ctx, "a@example.com", "b@example.com"),
+		c.FollowUser(ctx, "c@example.com", "a@example.com"),
+		c.BlockUser(ctx, "a@example.com", "d@example.com"),
+		c.MuteUser(ctx, "a@example.com", "b@example.com"),
+	}
+	_, err := c.SendFriendRequest(ctx, "c@example.com", "a@example.com")
+	steps = append(steps, err)
+	for _, err := range steps {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.SetUsername(ctx, "a@example.com", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	// the setting comes last so UpdatedAt isn't CreatedAt
+	if _, err := c.SetUserSetting(ctx, "a@example.com", "ui.darkMode", true); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	return c
+}
+
+func TestExportUserData(t *testing.T) {
+	c := newExportClient(t)
+	var buf bytes.Buffer
+	if err := c.ExportUserData(ctx, " A@example.com", &buf); err != nil {
+		t.Fatal(err)
+	}
+	var got, expected any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("ExportUserData() wrote invalid JSON: %v\n%s", err, buf.String())
+	}
+	if err := json.Unmarshal([]byte(exportGolden), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		gotIndented, _ := json.MarshalIndent(got, "", "\t")
+		expectedIndented, _ := json.MarshalIndent(expected, "", "\t")
+		t.Errorf("ExportUserData() =\n%s\nexpected\n%s", gotIndented, expectedIndented)
+	}
+	if strings.Contains(buf.String(), "password") {
+		t.Errorf("ExportUserData() = %s, expected no password", buf.String())
+	}
+}
+
+func TestExportUserDataEmpty(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "name", 18); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.ExportUserData(ctx, "test@example.com", &buf); err != nil {
+		t.Fatal(err)
+	}
+	export := map[string]json.RawMessage{}
+	if err := json.Unmarshal(buf.Bytes(), &export); err != nil {
+		t.Fatal(err)
+	}
+	// every list is there, and empty rather than null
+	for _, field := range []string{"posts", "following", "followers", "followRequestsSent", "followRequestsReceived", "blocked", "muted", "friendRequests", "nameHistory"} {
+		if string(export[field]) != "[]" {
+			t.Errorf("ExportUserData() field %s = %s, expected []", field, export[field])
+		}
+	}
+}
+
+func TestExportUserDataErrors(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "name", 18); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.ExportUserData(ctx, "missing@example.com", &buf); !errors.Is(err, ErrUserNotFound) || buf.Len() != 0 {
+		t.Errorf("ExportUserData() of a missing user = %v and %d bytes, expected ErrUserNotFound and nothing written", err, buf.Len())
+	}
+	if err := c.ExportUserData(ctx, "test@example.com", failingWriter{}); err == nil {
+		t.Error("ExportUserData() to a failing writer = nil, expected its error")
+	}
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
@@ -0,0 +1,230 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// fileStore -
+// the default Store, the whole db as one json file
+// every save replaces the file atomically and a separate lock file guards
+// read-modify-write cycles across processes
+type fileStore struct {
+	path          string
+	lockTimeout   time.Duration
+	indent        string
+	compress      bool
+	encryptionKey []byte
+	snapshotEvery int // write-ahead log mode when > 0
+	fileMode      os.FileMode
+	fixFileMode   bool
+
+	// the file and log as they were last loaded or saved, used to notice other processes' writes
+	last    os.FileInfo
+	lastWAL os.FileInfo
+	// the db as of the last load or save, what the next log entries are relative to
+	base *Schema
+	// log appends since the db file was last rewritten
+	pending int
+}
+
+func newFileStore(path string, o options) *fileStore {
+	return &fileStore{
+		path:          path,
+		lockTimeout:   o.lockTimeout,
+		indent:        o.indent,
+		compress:      o.compress,
+		encryptionKey: o.encryptionKey,
+		snapshotEvery: o.snapshotEvery,
+		fileMode:      o.fileMode,
+		fixFileMode:   o.fixFileMode && !o.readOnly,
+	}
+}
+
+// LoadFile -
+// read the json db file at path without going through a Client, for tools that
+// convert it to another backend. opts are the ones the file was written with
+func LoadFile(ctx context.Context, path string, opts ...Option) (Schema, error) {
+	return newFileStore(path, newOptions(opts)).Load(ctx)
+}
+
+// Load -
+// return data read from the db file, older schema versions are upgraded in memory
+// writes still in the write-ahead log are replayed on top, whether or not the log is enabled
+func (s *fileStore) Load(ctx context.Context) (Schema, error) {
+	// stat before reading, if the file is replaced in between the next write just reloads again
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return Schema{}, err
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return Schema{}, err
+	}
+	// an empty file is a db that was never written, EnsureDB fills it in
+	if len(bytes.TrimSpace(data)) == 0 {
+		return Schema{}, fmt.Errorf("%s is empty: %w", s.path, fs.ErrNotExist)
+	}
+	if s.fixFileMode && s.last == nil {
+		if err := s.fixModes(info); err != nil {
+			return Schema{}, err
+		}
+	}
+
+	db, version, err := s.decodeDB(data)
+	if err != nil {
+		return Schema{}, err
+	}
+	pending, err := s.replayWAL(&db)
+	if err != nil {
+		return Schema{}, err
+	}
+	db.SchemaVersion = version
+	s.last = info
+	s.base = &db
+	s.pending = pending
+	return db, nil
+}
+
+// Save -
+// overwrite db file with the data in given Schema
+// in write-ahead log mode only the changes are appended to the log, see WithWAL
+func (s *fileStore) Save(ctx context.Context, db Schema) error {
+	if s.snapshotEvery > 0 && s.base != nil {
+		return s.appendWAL(db)
+	}
+	return s.snapshot(db)
+}
+
+// Size -
+// how big the db file is with db in it, indent, checksum, compression and encryption included.
+// in write-ahead log mode that's the file once the log is folded into it
+func (s *fileStore) Size(db Schema) (int64, error) {
+	data, err := s.encodeDB(db)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+// Changed -
+// true if the file or its log were written since they were last loaded or saved by this store
+func (s *fileStore) Changed() bool {
+	info, err := os.Stat(s.path)
+	if err != nil || s.last == nil || !sameFileInfo(info, s.last) {
+		return true
+	}
+	wal, err := os.Stat(s.walPath())
+	if err != nil {
+		return s.lastWAL != nil
+	}
+	return s.lastWAL == nil || !sameFileInfo(wal, s.lastWAL)
+}
+
+// fixModes -
+// chmod the db file (described by info) and its log to s.fileMode if they allow more than that
+// windows only has a read-only flag so there's nothing to tighten there
+func (s *fileStore) fixModes(info os.FileInfo) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	if info.Mode().Perm()&^s.fileMode != 0 {
+		if err := os.Chmod(s.path, s.fileMode); err != nil {
+			return err
+		}
+	}
+	wal, err := os.Stat(s.walPath())
+	if err == nil && wal.Mode().Perm()&^s.fileMode != 0 {
+		return os.Chmod(s.walPath(), s.fileMode)
+	}
+	return nil
+}
+
+// sameFileInfo reports whether a and b describe the same unmodified file
+func sameFileInfo(a, b os.FileInfo) bool {
+	return os.SameFile(a, b) && a.ModTime().Equal(b.ModTime()) && a.Size() == b.Size()
+}
+
+// tempFile -
+// the parts of *os.File used while writing the db, lets tests inject failing writers
+type tempFile interface {
+	io.Writer
+	Sync() error
+	Close() error
+	Name() string
+}
+
+// createTemp opens the temp file that a new version of the db is written to
+// swapped out in tests to simulate partial writes
+var createTemp = func(dir, pattern string) (tempFile, error) {
+	return os.CreateTemp(dir, pattern)
+}
+
+// writeFileAtomic -
+// write data to a temp file in the same directory as path, fsync it, rename it over path
+// and fsync the directory so the rename survives a crash too.
+// readers see either the old contents or the new ones, never a truncated file.
+// there's deliberately no fallback to writing path in place when the rename fails,
+// that's the truncated file this exists to avoid, so the error is returned instead
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := createTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// clean up the temp file on any failure, the original file is left untouched
+	tmpName := tmp.Name()
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+
+	if _, err = tmp.Write(data); err != nil {
+		return fail(err)
+	}
+	if err = tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err = os.Chmod(tmpName, perm); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	// no plain write if the rename fails, a crash halfway through it would truncate the db
+	if err = os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir -
+// fsync a directory so the renames in it are on disk. windows can't open one for that
+// and some filesystems don't support it, neither is an error
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) && !errors.Is(err, syscall.ENOTSUP) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// partialFile writes only the first half of whatever it's given, then fails
+type partialFile struct {
+	*os.File
+}
+
+func (f partialFile) Write(p []byte) (int, error) {
+	n, _ := f.File.Write(p[:len(p)/2])
+	return n, errors.New("disk full")
+}
+
+func TestWriteFileAtomicPartialWrite(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// inject a writer that dies halfway through
+	orig := createTemp
+	createTemp = func(dir, pattern string) (tempFile, error) {
+		f, err := os.CreateTemp(dir, pattern)
+		return partialFile{f}, err
+	}
+	defer func() { createTemp = orig }()
+
+	if _, err := c.CreateUser(ctx, "other@example.com", "12345", "jane doe", 20); err == nil {
+		t.Fatal("CreateUser() = nil, expected write error")
+	}
+
+	// original file must be intact and no temp files left behind
+	after, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("db file changed after failed write:\n%s\nexpected\n%s", after, before)
+	}
+	leftovers, err := filepath.Glob(dbPath(c) + ".tmp-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leftovers) != 0 {
+		t.Errorf("temp files left behind after failed write: %v", leftovers)
+	}
+	if _, err := c.GetUser(ctx, "test@example.com"); err != nil {
+		t.Errorf("GetUser() after failed write = %v, expected nil", err)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	for _, payload := range []string{`{"a":1}`, `{}`} {
+		if err := writeFileAtomic(path, []byte(payload), 0600); err != nil {
+			t.Fatalf("writeFileAtomic() = %v, expected nil", err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != payload {
+			t.Errorf("file contents = %s, expected %s", got, payload)
+		}
+	}
+}
+
+func TestWriteFileAtomicRenameFails(t *testing.T) {
+	// a directory with something in it can't be renamed over
+	path := filepath.Join(t.TempDir(), "db.json")
+	if err := os.MkdirAll(filepath.Join(path, "keep"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(path, []byte(`{}`), 0600); err == nil {
+		t.Fatal("writeFileAtomic() over a directory = nil, expected the rename error")
+	}
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		t.Errorf("%s after a failed rename = %v, %v, expected the directory untouched", path, info, err)
+	}
+	leftovers, err := filepath.Glob(path + ".tmp-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leftovers) != 0 {
+		t.Errorf("temp files left behind after a failed rename: %v", leftovers)
+	}
+}
+
+func TestSyncDir(t *testing.T) {
+	if err := syncDir(t.TempDir()); err != nil {
+		t.Errorf("syncDir() = %v, expected nil", err)
+	}
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if err := syncDir(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("syncDir() of a missing directory = %v, expected os.ErrNotExist", err)
+	}
+}
+
+// assertMode fails the test unless the file at path has exactly the permissions mode
+func assertMode(t *testing.T, path string, mode os.FileMode) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != mode {
+		t.Errorf("%s has mode %v, expected %v", filepath.Base(path), got, mode)
+	}
+}
+
+func TestFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows doesn't have unix permissions")
+	}
+	var tests = []struct {
+		opts     []Option
+		expected os.FileMode
+	}{
+		{expected: 0600},
+		{opts: []Option{WithFileMode(0640)}, expected: 0640},
+		{opts: []Option{WithFileMode(0640), WithWAL(100)}, expected: 0640},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "db.json")
+		c := NewClient(path, test.opts...)
+		if err := c.EnsureDB(ctx); err != nil {
+			t.Fatal(err)
+		}
+		assertMode(t, path, test.expected)
+		if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); err != nil {
+			t.Fatal(err)
+		}
+		assertMode(t, path, test.expected)
+		assertMode(t, path+".lock", test.expected)
+		if _, err := os.Stat(path + walSuffix); err == nil {
+			assertMode(t, path+walSuffix, test.expected)
+		}
+		if err := c.Backup(ctx, path+".bak"); err != nil {
+			t.Fatal(err)
+		}
+		assertMode(t, path+".bak", test.expected)
+	}
+}
+
+func TestFixFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("windows doesn't have unix permissions")
+	}
+	for _, fix := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "db.json")
+		if err := NewClient(path).EnsureDB(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, 0666); err != nil {
+			t.Fatal(err)
+		}
+
+		opts := []Option{}
+		if fix {
+			opts = append(opts, WithFixFileMode())
+		}
+		if _, err := NewClient(path, opts...).GetUser(ctx, "test@example.com"); !errors.Is(err, ErrUserNotFound) {
+			t.Fatal(err)
+		}
+		expected := os.FileMode(0666)
+		if fix {
+			expected = 0600
+		}
+		assertMode(t, path, expected)
+	}
+
+	// a read-only client leaves the file alone
+	path := filepath.Join(t.TempDir(), "db.json")
+	if err := NewClient(path).EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewReadOnlyClient(path, WithFixFileMode()).EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertMode(t, path, 0644)
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// putFollow -
+// record that follower follows followee since at, in both directions
+func (db *Schema) putFollow(follower, followee string, at time.Time) {
+	db.Follows.put(follower, followee, at)
+	db.Followers.put(followee, follower, at)
+}
+
+// deleteFollow -
+// forget that follower follows followee, if they do
+func (db *Schema) deleteFollow(follower, followee string) {
+	db.Follows.delete(follower, followee)
+	db.Followers.delete(followee, follower)
+}
+
+// dropFollows -
+// remove the user with email from every follow list, theirs and everyone else's.
+// the reverse index makes it a lookup in each direction instead of a scan
+func (db *Schema) dropFollows(email string) {
+	for followee := range db.Follows[email] {
+		db.Followers.delete(followee, email)
+	}
+	for follower := range db.Followers[email] {
+		db.Follows.delete(follower, email)
+	}
+	delete(db.Follows, email)
+	delete(db.Followers, email)
+}
+
+// indexFollowers -
+// rebuild the Followers index from Follows, for a db that was just read
+func (db *Schema) indexFollowers() {
+	db.Followers = db.Follows.reversed()
+}
+
+// FollowUser -
+// same as Client.FollowUser, inside the Tx
+func (tx *Tx) FollowUser(ctx context.Context, follower, followee string) error {
+	_, err := tx.followUser(follower, followee)
+	return err
+}
+
+// followUser -
+// FollowUser that also says whether anything changed
+func (tx *Tx) followUser(follower, followee string) (bool, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return false, err
+	}
+	follower, followee = EmailKey(follower), EmailKey(followee)
+	if follower == followee {
+		return false, fmt.Errorf("%w: %s", ErrSelfFollow, follower)
+	}
+	for _, email := range []string{follower, followee} {
+		if _, ok := db.activeUser(email); !ok {
+			return false, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+		}
+	}
+	if db.blockedEither(follower, followee) {
+		return false, fmt.Errorf("%w: %s and %s blocked one another", ErrPermissionDenied, follower, followee)
+	}
+	if db.Follows.has(follower, followee) {
+		return false, nil
+	}
+	if user, _ := db.activeUser(followee); user.IsPrivate {
+		if db.FollowRequests.has(follower, followee) {
+			return false, nil
+		}
+		db.FollowRequests.put(follower, followee, tx.now())
+		return true, nil
+	}
+	// a request left from when followee was private is moot
+	db.FollowRequests.delete(follower, followee)
+	db.putFollow(follower, followee, tx.now())
+	return true, nil
+}
+
+// UnfollowUser -
+// same as Client.UnfollowUser, inside the Tx
+func (tx *Tx) UnfollowUser(ctx context.Context, follower, followee string) error {
+	_, err := tx.unfollowUser(follower, followee)
+	return err
+}
+
+// unfollowUser -
+// UnfollowUser that also says whether anything changed
+func (tx *Tx) unfollowUser(follower, followee string) (bool, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return false, err
+	}
+	follower, followee = EmailKey(follower), EmailKey(followee)
+	if _, ok := db.activeUser(follower); !ok {
+		return false, fmt.Errorf("%w: %s", ErrUserNotFound, follower)
+	}
+	if !db.Follows.has(follower, followee) && !db.FollowRequests.has(follower, followee) {
+		return false, nil
+	}
+	db.deleteFollow(follower, followee)
+	db.FollowRequests.delete(follower, followee)
+	return true, nil
+}
+
+// FollowUser -
+// have follower follow followee. following someone already followed is a no-op, not an error,
+// so a retried request is harmless. when followee is private it's only a request until followee
+// answers it, see ApproveFollowRequest. ErrSelfFollow if they're the same user, ErrUserNotFound
+// if either doesn't exist and ErrPermissionDenied if either blocked the other
+func (c *Client) FollowUser(ctx context.Context, follower, followee string) error {
+	return c.update(ctx, "FollowUser", follower, func(db *Schema) error {
+		changed, err := c.newTx(db).followUser(follower, followee)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+}
+
+// UnfollowUser -
+// undo FollowUser, withdrawing the request if it's still pending. a no-op if follower doesn't
+// follow followee. ErrUserNotFound if there's no follower
+func (c *Client) UnfollowUser(ctx context.Context, follower, followee string) error {
+	return c.deleteUpdate(ctx, "UnfollowUser", follower, func(db *Schema) error {
+		changed, err := c.newTx(db).unfollowUser(follower, followee)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+}
+
+// GetFollowers -
+// the users following the user with email, oldest first like GetUsers
+// soft-deleted ones are left out until they're restored. ErrUserNotFound if there's no such user
+func (c *Client) GetFollowers(ctx context.Context, email string) ([]User, error) {
+	return c.followList(ctx, "GetFollowers", email, func(db *Schema) emailEdges { return db.Followers })
+}
+
+// GetFollowing -
+// the users the user with email follows, like GetFollowers
+func (c *Client) GetFollowing(ctx context.Context, email string) ([]User, error) {
+	return c.followList(ctx, "GetFollowing", email, func(db *Schema) emailEdges { return db.Follows })
+}
+
+// followList -
+// the users edges of db point at from email, see GetFollowers
+func (c *Client) followList(ctx context.Context, op, email string, edges func(db *Schema) emailEdges) ([]User, error) {
+	users := []User{}
+	err := c.view(ctx, op, email, func(db *Schema) error {
+		email := EmailKey(email)
+		if _, ok := db.activeUser(email); !ok {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, email)
+		}
+		for other := range edges(db)[email] {
+			if user, ok := db.activeUser(other); ok {
+				users = append(users, c.sanitize(user))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []User{}, err
+	}
+	sortUsers(users)
+	return users, nil
+}
+
+// FollowCounts -
+// how many users follow a user and how many it follows
+type FollowCounts struct {
+	Followers int
+	Following int
+}
+
+// GetFollowCounts -
+// the lengths of GetFollowers and GetFollowing without building them
+func (c *Client) GetFollowCounts(ctx context.Context, email string) (FollowCounts, error) {
+	counts := FollowCounts{}
+	err := c.view(ctx, "GetFollowCounts", email, func(db *Schema) error {
+		email := EmailKey(email)
+		if _, ok := db.activeUser(email); !ok {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, email)
+		}
+		for follower := range db.Followers[email] {
+			if _, ok := db.activeUser(follower); ok {
+				counts.Followers++
+			}
+		}
+		for followee := range db.Follows[email] {
+			if _, ok := db.activeUser(followee); ok {
+				counts.Following++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return FollowCounts{}, err
+	}
+	return counts, nil
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// newFollowClient has a@ to d@example.com following each other like so:
+// a@ follows b@ and c@, b@ follows c@, c@ follows a@ and d@ follows c@
+func newFollowClient(t *testing.T, opts ...Option) *Client {
+	t.Helper()
+	c := newTestClient(t, opts...)
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, follow := range [][2]string{{"a", "b"}, {"a", "c"}, {"b", "c"}, {"c", "a"}, {"d", "c"}} {
+		if err := c.FollowUser(ctx, follow[0]+"@example.com", follow[1]+"@example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+// checkFollowGraph compares both directions of every user's lists and counts with expected,
+// email to the emails it follows
+func checkFollowGraph(t *testing.T, c *Client, expected map[string][]string) {
+	t.Helper()
+	followers := map[string][]string{}
+	for email, following := range expected {
+		for _, followee := range following {
+			followers[followee] = append(followers[followee], email)
+		}
+	}
+	for email := range expected {
+		following, err := c.GetFollowing(ctx, email)
+		if err != nil || !reflect.DeepEqual(emails(following), append([]string{}, expected[email]...)) {
+			t.Errorf("GetFollowing(%s) = %v, %v, expected %v", email, emails(following), err, expected[email])
+		}
+		got, err := c.GetFollowers(ctx, email)
+		want := append([]string{}, followers[email]...)
+		// created in email order
+		sort.Strings(want)
+		if err != nil || !reflect.DeepEqual(emails(got), want) {
+			t.Errorf("GetFollowers(%s) = %v, %v, expected %v", email, emails(got), err, want)
+		}
+		counts, err := c.GetFollowCounts(ctx, email)
+		if expectedCounts := (FollowCounts{Followers: len(want), Following: len(expected[email])}); err != nil || counts != expectedCounts {
+			t.Errorf("GetFollowCounts(%s) = %+v, %v, expected %+v", email, counts, err, expectedCounts)
+		}
+	}
+}
+
+func TestFollowGraph(t *testing.T) {
+	c := newFollowClient(t)
+	checkFollowGraph(t, c, map[string][]string{
+		"a@example.com": {"b@example.com", "c@example.com"},
+		"b@example.com": {"c@example.com"},
+		"c@example.com": {"a@example.com"},
+		"d@example.com": {"c@example.com"},
+	})
+}
+
+func TestFollowUser(t *testing.T) {
+	c := newFollowClient(t)
+	if _, err := c.SoftDeleteUser(ctx, "d@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		follower string
+		followee string
+		expected error
+	}{
+		// following twice is a no-op
+		{follower: "a@example.com", followee: "b@example.com", expected: nil},
+		{follower: "A@Example.com", followee: " B@example.com", expected: nil},
+		{follower: "a@example.com", followee: "A@example.com", expected: ErrSelfFollow},
+		{follower: "a@example.com", followee: "missing@example.com", expected: ErrUserNotFound},
+		{follower: "missing@example.com", followee: "a@example.com", expected: ErrUserNotFound},
+		{follower: "a@example.com", followee: "d@example.com", expected: ErrUserNotFound},
+		{follower: "d@example.com", followee: "a@example.com", expected: ErrUserNotFound},
+	}
+	for _, test := range tests {
+		if err := c.FollowUser(ctx, test.follower, test.followee); !errors.Is(err, test.expected) {
+			t.Errorf("FollowUser(%q, %q) = %v, expected %v", test.follower, test.followee, err, test.expected)
+		}
+	}
+	// soft-deleted users drop out of the lists while they're deleted
+	checkFollowGraph(t, c, map[string][]string{
+		"a@example.com": {"b@example.com", "c@example.com"},
+		"b@example.com": {"c@example.com"},
+		"c@example.com": {"a@example.com"},
+	})
+	if _, err := c.GetFollowers(ctx, "d@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetFollowers() of a soft-deleted user = %v, expected ErrUserNotFound", err)
+	}
+}
+
+func TestUnfollowUser(t *testing.T) {
+	c := newFollowClient(t)
+	if err := c.UnfollowUser(ctx, "missing@example.com", "a@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("UnfollowUser() by a missing user = %v, expected ErrUserNotFound", err)
+	}
+	// d@ never followed a@
+	if err := c.UnfollowUser(ctx, "d@example.com", "a@example.com"); err != nil {
+		t.Errorf("UnfollowUser() of a user not followed = %v, expected nil", err)
+	}
+	if err := c.UnfollowUser(ctx, "A@example.com", "C@example.com"); err != nil {
+		t.Fatalf("UnfollowUser() = %v, expected nil", err)
+	}
+	checkFollowGraph(t, c, map[string][]string{
+		"a@example.com": {"b@example.com"},
+		"b@example.com": {"c@example.com"},
+		"c@example.com": {"a@example.com"},
+		"d@example.com": {"c@example.com"},
+	})
+}
+
+func TestBlockStopsFollowing(t *testing.T) {
+	c := newFollowClient(t)
+	// a@ and c@ follow each other
+	if err := c.BlockUser(ctx, "c@example.com", "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	checkFollowGraph(t, c, map[string][]string{
+		"a@example.com": {"b@example.com"},
+		"b@example.com": {"c@example.com"},
+		"c@example.com": {},
+		"d@example.com": {"c@example.com"},
+	})
+	for _, follow := range [][2]string{{"a@example.com", "c@example.com"}, {"c@example.com", "a@example.com"}} {
+		if err := c.FollowUser(ctx, follow[0], follow[1]); !errors.Is(err, ErrPermissionDenied) {
+			t.Errorf("FollowUser(%q, %q) after a block = %v, expected ErrPermissionDenied", follow[0], follow[1], err)
+		}
+	}
+}
+
+func TestDeleteUserDropsFollows(t *testing.T) {
+	for name, opts := range map[string][]Option{"file": nil, "wal": {WithWAL(100)}} {
+		c := newFollowClient(t, opts...)
+		if _, err := c.DeleteUser(ctx, "c@example.com", DeleteUserOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		expected := map[string][]string{
+			"a@example.com": {"b@example.com"},
+			"b@example.com": {},
+			"d@example.com": {},
+		}
+		checkFollowGraph(t, c, expected)
+
+		// the same after reading it back, Followers is rebuilt from what's stored
+		reopened := NewClient(dbPath(c), opts...)
+		checkFollowGraph(t, reopened, expected)
+		db, err := reopened.Dump(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(db.Followers, db.Follows.reversed()) || len(db.Follows) != 1 {
+			t.Errorf("%s: follows after DeleteUser() = %v and %v, expected only a@ following b@", name, db.Follows, db.Followers)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestChangeEmailMovesFollows(t *testing.T) {
+	c := newFollowClient(t)
+	if _, err := c.ChangeEmail(ctx, "c@example.com", "e@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	checkFollowGraph(t, c, map[string][]string{
+		"a@example.com": {"b@example.com", "e@example.com"},
+		"b@example.com": {"e@example.com"},
+		"e@example.com": {"a@example.com"},
+		"d@example.com": {"e@example.com"},
+	})
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// first bytes of every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// header in front of encrypted files, followed by the nonce and the AES-GCM sealed payload
+var encryptedMagic = []byte("SMDBAESGCM1\n")
+
+// encodeDB -
+// turn db into the bytes stored on disk
+// compact json unless an indent was configured, wrapped with its checksum,
+// gzipped if compression is on, then encrypted if there's an encryption key
+func (s *fileStore) encodeDB(db Schema) ([]byte, error) {
+	var payload []byte
+	var err error
+	if s.indent != "" {
+		payload, err = json.MarshalIndent(db, s.indent, s.indent)
+	} else {
+		payload, err = json.Marshal(db)
+	}
+	if err != nil {
+		return nil, err
+	}
+	payload = wrapChecksum(payload, s.indent)
+
+	if s.compress {
+		buf := bytes.Buffer{}
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(payload); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		payload = buf.Bytes()
+	}
+
+	if s.encryptionKey != nil {
+		return encrypt(s.encryptionKey, payload)
+	}
+	return payload, nil
+}
+
+// decodeDB -
+// reverse of encodeDB, encrypted and compressed files are recognized by their headers
+// so plain files can still be read by a client with those options set
+// also returns the schema version found in the file
+func (s *fileStore) decodeDB(data []byte) (Schema, int, error) {
+	if bytes.HasPrefix(data, encryptedMagic) {
+		if s.encryptionKey == nil {
+			return Schema{}, 0, fmt.Errorf("%w: file is encrypted and no key is configured", ErrDecryptFailed)
+		}
+		var err error
+		data, err = decrypt(s.encryptionKey, data)
+		if err != nil {
+			return Schema{}, 0, err
+		}
+	}
+	if bytes.HasPrefix(data, gzipMagic) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return Schema{}, 0, fmt.Errorf("%w: %v", ErrDBCorrupt, err)
+		}
+		data, err = io.ReadAll(zr)
+		if err != nil {
+			return Schema{}, 0, fmt.Errorf("%w: %v", ErrDBCorrupt, err)
+		}
+	}
+	return parseDB(data)
+}
+
+// encrypt -
+// seal payload with AES-GCM under key using a fresh random nonce
+func encrypt(key, payload []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte{}, encryptedMagic...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, payload, encryptedMagic), nil
+}
+
+// decrypt -
+// open data written by encrypt, wrong keys and tampering both give ErrDecryptFailed
+func decrypt(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	data = data[len(encryptedMagic):]
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("%w: file too short", ErrDecryptFailed)
+	}
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	payload, err := gcm.Open(nil, nonce, sealed, encryptedMagic)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryptFailed, err)
+	}
+	return payload, nil
+}
+
+// newGCM builds the AES-GCM cipher for key, which must be 16, 24 or 32 bytes
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptInPlace -
+// rewrite the db file using the client's encryption key, for migrating an existing plaintext file
+func (c *Client) EncryptInPlace(ctx context.Context) error {
+	file, ok := c.store.(*fileStore)
+	if !ok {
+		return fmt.Errorf("%w: EncryptInPlace needs a file-backed client", ErrNotSupported)
+	}
+	if file.encryptionKey == nil {
+		return errors.New("no encryption key configured")
+	}
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := c.flushPending(ctx); err != nil {
+		return err
+	}
+	if err := c.load(ctx); err != nil {
+		return err
+	}
+	// snapshot rather than save so a write-ahead log gets folded in and encrypted too
+	db := *c.mem
+	db.SchemaVersion = currentSchemaVersion
+	if err := file.snapshot(db); err != nil {
+		return err
+	}
+	c.mem = &db
+	return c.measure()
+}
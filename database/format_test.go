@@ -0,0 +1,172 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fillDB adds a user with n posts through the client
+func fillDB(t *testing.T, c *Client, n int) {
+	t.Helper()
+	if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		if _, err := c.CreatePost(ctx, "test@example.com", fmt.Sprintf("my cat is way too fat, day %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWithCompression(t *testing.T) {
+	dir := t.TempDir()
+	plain := NewClient(filepath.Join(dir, "plain.json"))
+	compressed := NewClient(filepath.Join(dir, "compressed.json"), WithCompression())
+	for _, c := range []*Client{plain, compressed} {
+		if err := c.EnsureDB(ctx); err != nil {
+			t.Fatal(err)
+		}
+		fillDB(t, c, 50)
+	}
+
+	plainData, err := os.ReadFile(dbPath(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressedData, err := os.ReadFile(dbPath(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(compressedData, gzipMagic) {
+		t.Errorf("compressed file doesn't start with gzip magic bytes")
+	}
+	if len(compressedData)*2 > len(plainData) {
+		t.Errorf("compressed file is %d bytes, expected well under half of %d", len(compressedData), len(plainData))
+	}
+
+	// readable without the option too
+	posts, err := NewClient(dbPath(compressed)).GetPosts(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 50 {
+		t.Errorf("len(GetPosts()) = %d, expected 50", len(posts))
+	}
+}
+
+func TestWithCompressionMigratesPlainFile(t *testing.T) {
+	c := newTestClient(t)
+	fillDB(t, c, 1)
+
+	// reopen with compression on, the first write converts the file
+	compressed := NewClient(dbPath(c), WithCompression())
+	if _, err := compressed.GetUser(ctx, "test@example.com"); err != nil {
+		t.Fatalf("GetUser() on plain file = %v, expected nil", err)
+	}
+	if _, err := compressed.CreatePost(ctx, "test@example.com", "now compressed"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, gzipMagic) {
+		t.Errorf("file not compressed after first write")
+	}
+}
+
+func TestTruncatedGzip(t *testing.T) {
+	c := NewClient(filepath.Join(t.TempDir(), "db.json"), WithCompression())
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	fillDB(t, c, 10)
+	data, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dbPath(c), data[:len(data)/2], 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reload(ctx); !errors.Is(err, ErrDBCorrupt) {
+		t.Errorf("Reload() on truncated gzip = %v, expected %v", err, ErrDBCorrupt)
+	}
+}
+
+func TestWithEncryptionKey(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	c := NewClient(filepath.Join(t.TempDir(), "db.json"), WithEncryptionKey(key))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// even the empty db is encrypted
+	data, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, encryptedMagic) || bytes.Contains(data, []byte("users")) {
+		t.Errorf("empty db isn't encrypted: %q", data)
+	}
+
+	fillDB(t, c, 1)
+	data, err = os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("test@example.com")) {
+		t.Errorf("email found in plaintext in encrypted file")
+	}
+	if _, err := NewClient(dbPath(c), WithEncryptionKey(key)).GetUser(ctx, "test@example.com"); err != nil {
+		t.Errorf("GetUser() with right key = %v, expected nil", err)
+	}
+
+	// wrong key, no key and a flipped byte all fail the same way
+	tampered := append([]byte{}, data...)
+	tampered[len(tampered)-1] ^= 0xff
+	tamperedPath := filepath.Join(t.TempDir(), "tampered.json")
+	if err := os.WriteFile(tamperedPath, tampered, 0666); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		name   string
+		client *Client
+	}{
+		{"wrong key", NewClient(dbPath(c), WithEncryptionKey(bytes.Repeat([]byte{8}, 32)))},
+		{"no key", NewClient(dbPath(c))},
+		{"tampered", NewClient(tamperedPath, WithEncryptionKey(key))},
+	}
+	for _, test := range tests {
+		if _, err := test.client.GetUser(ctx, "test@example.com"); !errors.Is(err, ErrDecryptFailed) {
+			t.Errorf("GetUser() with %s = %v, expected %v", test.name, err, ErrDecryptFailed)
+		}
+	}
+}
+
+func TestEncryptInPlace(t *testing.T) {
+	c := newTestClient(t)
+	fillDB(t, c, 3)
+
+	key := bytes.Repeat([]byte{7}, 16)
+	encrypted := NewClient(dbPath(c), WithEncryptionKey(key), WithCompression())
+	if err := encrypted.EncryptInPlace(ctx); err != nil {
+		t.Fatalf("EncryptInPlace() = %v, expected nil", err)
+	}
+	data, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		t.Errorf("file not encrypted after EncryptInPlace()")
+	}
+	posts, err := NewClient(dbPath(c), WithEncryptionKey(key)).GetPosts(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 3 {
+		t.Errorf("len(GetPosts()) after EncryptInPlace() = %d, expected 3", len(posts))
+	}
+}
@@ -0,0 +1,318 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// FriendRequestStatus -
+// where a FriendRequest is in its life: pending until the recipient accepts or declines it
+// or the sender cancels it
+type FriendRequestStatus string
+
+const (
+	FriendRequestPending   FriendRequestStatus = "pending"
+	FriendRequestAccepted  FriendRequestStatus = "accepted"
+	FriendRequestDeclined  FriendRequestStatus = "declined"
+	FriendRequestCancelled FriendRequestStatus = "cancelled"
+)
+
+// FriendRequest -
+// a friend request as stored, one per pair of users whichever of them sent it, see friendKey.
+// an accepted request is the friendship itself
+type FriendRequest struct {
+	From      string              `json:"from"`
+	To        string              `json:"to"`
+	Status    FriendRequestStatus `json:"status"`
+	CreatedAt time.Time           `json:"createdAt"`
+	// RespondedAt is when it was accepted, declined or cancelled, zero while pending
+	RespondedAt time.Time `json:"respondedAt"`
+}
+
+// friendKey -
+// the FriendRequests key of the pair a and b, the same both ways round.
+// the space can't appear in a normalized email
+func friendKey(a, b string) string {
+	if b < a {
+		a, b = b, a
+	}
+	return a + " " + b
+}
+
+// other -
+// the user of the request that isn't email
+func (r FriendRequest) other(email string) string {
+	if r.From == email {
+		return r.To
+	}
+	return r.From
+}
+
+// putFriendRequest -
+// store request under the key of its pair, making the map on first use
+func (db *Schema) putFriendRequest(request FriendRequest) {
+	if db.FriendRequests == nil {
+		db.FriendRequests = make(map[string]FriendRequest)
+	}
+	db.FriendRequests[friendKey(request.From, request.To)] = request
+}
+
+// dropFriendRequests -
+// remove every friend request and friendship of the user with email
+func (db *Schema) dropFriendRequests(email string) {
+	for key, request := range db.FriendRequests {
+		if request.From == email || request.To == email {
+			delete(db.FriendRequests, key)
+		}
+	}
+}
+
+// moveFriendRequests -
+// rekey the friend requests and friendships of oldEmail to newEmail
+func (db *Schema) moveFriendRequests(oldEmail, newEmail string) {
+	for key, request := range db.FriendRequests {
+		if request.From != oldEmail && request.To != oldEmail {
+			continue
+		}
+		delete(db.FriendRequests, key)
+		if request.From == oldEmail {
+			request.From = newEmail
+		} else {
+			request.To = newEmail
+		}
+		db.putFriendRequest(request)
+	}
+}
+
+// SendFriendRequest -
+// same as Client.SendFriendRequest, inside the Tx
+func (tx *Tx) SendFriendRequest(ctx context.Context, from, to string) (FriendRequest, error) {
+	request, _, err := tx.sendFriendRequest(from, to)
+	return request, err
+}
+
+// sendFriendRequest -
+// SendFriendRequest that also says whether anything changed
+func (tx *Tx) sendFriendRequest(from, to string) (FriendRequest, bool, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return FriendRequest{}, false, err
+	}
+	from, to = EmailKey(from), EmailKey(to)
+	if from == to {
+		return FriendRequest{}, false, fmt.Errorf("%w: %s", ErrSelfFriendRequest, from)
+	}
+	for _, email := range []string{from, to} {
+		if _, ok := db.activeUser(email); !ok {
+			return FriendRequest{}, false, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+		}
+	}
+	if db.blockedEither(from, to) {
+		return FriendRequest{}, false, fmt.Errorf("%w: %s and %s blocked one another", ErrPermissionDenied, from, to)
+	}
+
+	now := tx.now()
+	existing, ok := db.FriendRequests[friendKey(from, to)]
+	switch {
+	case ok && existing.Status == FriendRequestAccepted:
+		return existing, false, nil
+	case ok && existing.Status == FriendRequestPending && existing.From == from:
+		return existing, false, nil
+	case ok && existing.Status == FriendRequestPending:
+		// they asked first, asking back is saying yes
+		existing.Status = FriendRequestAccepted
+		existing.RespondedAt = now
+		db.putFriendRequest(existing)
+		return existing, true, nil
+	}
+	// a declined or cancelled request gives way to the new one
+	request := FriendRequest{From: from, To: to, Status: FriendRequestPending, CreatedAt: now}
+	db.putFriendRequest(request)
+	return request, true, nil
+}
+
+// respondFriendRequest -
+// move the pending request from from to to into status, acting as actor, one of the two
+func (tx *Tx) respondFriendRequest(actor, from, to string, status FriendRequestStatus) (FriendRequest, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return FriendRequest{}, err
+	}
+	actor, from, to = EmailKey(actor), EmailKey(from), EmailKey(to)
+	if _, ok := db.activeUser(actor); !ok {
+		return FriendRequest{}, fmt.Errorf("%w: %s", ErrUserNotFound, actor)
+	}
+	request, ok := db.FriendRequests[friendKey(from, to)]
+	if !ok || request.Status != FriendRequestPending || request.From != from {
+		return FriendRequest{}, fmt.Errorf("%w: from %s to %s", ErrFriendRequestNotFound, from, to)
+	}
+	request.Status = status
+	request.RespondedAt = tx.now()
+	db.putFriendRequest(request)
+	return request, nil
+}
+
+// AcceptFriendRequest -
+// same as Client.AcceptFriendRequest, inside the Tx
+func (tx *Tx) AcceptFriendRequest(ctx context.Context, email, from string) (FriendRequest, error) {
+	return tx.respondFriendRequest(email, from, email, FriendRequestAccepted)
+}
+
+// DeclineFriendRequest -
+// same as Client.DeclineFriendRequest, inside the Tx
+func (tx *Tx) DeclineFriendRequest(ctx context.Context, email, from string) (FriendRequest, error) {
+	return tx.respondFriendRequest(email, from, email, FriendRequestDeclined)
+}
+
+// CancelFriendRequest -
+// same as Client.CancelFriendRequest, inside the Tx
+func (tx *Tx) CancelFriendRequest(ctx context.Context, from, to string) (FriendRequest, error) {
+	return tx.respondFriendRequest(from, from, to, FriendRequestCancelled)
+}
+
+// RemoveFriend -
+// same as Client.RemoveFriend, inside the Tx
+func (tx *Tx) RemoveFriend(ctx context.Context, email, friend string) error {
+	db, err := tx.schema()
+	if err != nil {
+		return err
+	}
+	email, friend = EmailKey(email), EmailKey(friend)
+	key := friendKey(email, friend)
+	if request, ok := db.FriendRequests[key]; !ok || request.Status != FriendRequestAccepted {
+		return fmt.Errorf("%w: %s and %s aren't friends", ErrFriendRequestNotFound, email, friend)
+	}
+	delete(db.FriendRequests, key)
+	return nil
+}
+
+// SendFriendRequest -
+// ask to to be friends with from. sending it again while it's pending, or to a friend, is a no-op
+// returning the request or friendship as it is. sending one back to someone whose request is pending
+// accepts theirs, both asked so they're friends. a declined or cancelled request can be sent again.
+// ErrSelfFriendRequest if they're the same user, ErrUserNotFound if either doesn't exist
+// and ErrPermissionDenied if either blocked the other
+func (c *Client) SendFriendRequest(ctx context.Context, from, to string) (FriendRequest, error) {
+	request := FriendRequest{}
+	err := c.update(ctx, "SendFriendRequest", from, func(db *Schema) error {
+		var changed bool
+		var err error
+		request, changed, err = c.newTx(db).sendFriendRequest(from, to)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+	if err != nil {
+		return FriendRequest{}, err
+	}
+	return request, nil
+}
+
+// AcceptFriendRequest -
+// have the user with email accept the pending request from, in one write so both see the friendship.
+// ErrFriendRequestNotFound if from has no pending request to them
+func (c *Client) AcceptFriendRequest(ctx context.Context, email, from string) (FriendRequest, error) {
+	return c.respondFriendRequest(ctx, "AcceptFriendRequest", email, func(tx *Tx) (FriendRequest, error) {
+		return tx.AcceptFriendRequest(ctx, email, from)
+	})
+}
+
+// DeclineFriendRequest -
+// have the user with email turn down the pending request from, like AcceptFriendRequest.
+// from can ask again later, BlockUser stops that
+func (c *Client) DeclineFriendRequest(ctx context.Context, email, from string) (FriendRequest, error) {
+	return c.respondFriendRequest(ctx, "DeclineFriendRequest", email, func(tx *Tx) (FriendRequest, error) {
+		return tx.DeclineFriendRequest(ctx, email, from)
+	})
+}
+
+// CancelFriendRequest -
+// have from withdraw its pending request to to. ErrFriendRequestNotFound if there's none
+func (c *Client) CancelFriendRequest(ctx context.Context, from, to string) (FriendRequest, error) {
+	return c.respondFriendRequest(ctx, "CancelFriendRequest", from, func(tx *Tx) (FriendRequest, error) {
+		return tx.CancelFriendRequest(ctx, from, to)
+	})
+}
+
+// respondFriendRequest -
+// run one of the transitions of a pending request in a write
+func (c *Client) respondFriendRequest(ctx context.Context, op, key string, fn func(tx *Tx) (FriendRequest, error)) (FriendRequest, error) {
+	request := FriendRequest{}
+	err := c.update(ctx, op, key, func(db *Schema) error {
+		var err error
+		request, err = fn(c.newTx(db))
+		return err
+	})
+	if err != nil {
+		return FriendRequest{}, err
+	}
+	return request, nil
+}
+
+// RemoveFriend -
+// end the friendship of the user with email and friend, either of them can.
+// ErrFriendRequestNotFound if they aren't friends
+func (c *Client) RemoveFriend(ctx context.Context, email, friend string) error {
+	return c.deleteUpdate(ctx, "RemoveFriend", email, func(db *Schema) error {
+		return c.newTx(db).RemoveFriend(ctx, email, friend)
+	})
+}
+
+// GetPendingRequests -
+// the pending friend requests the user with email sent or was sent, oldest first.
+// ErrUserNotFound if there's no such user
+func (c *Client) GetPendingRequests(ctx context.Context, email string) ([]FriendRequest, error) {
+	requests := []FriendRequest{}
+	err := c.view(ctx, "GetPendingRequests", email, func(db *Schema) error {
+		email := EmailKey(email)
+		if _, ok := db.activeUser(email); !ok {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, email)
+		}
+		for _, request := range db.FriendRequests {
+			if request.Status == FriendRequestPending && (request.From == email || request.To == email) {
+				requests = append(requests, request)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []FriendRequest{}, err
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		if !requests[i].CreatedAt.Equal(requests[j].CreatedAt) {
+			return requests[i].CreatedAt.Before(requests[j].CreatedAt)
+		}
+		return friendKey(requests[i].From, requests[i].To) < friendKey(requests[j].From, requests[j].To)
+	})
+	return requests, nil
+}
+
+// GetFriends -
+// the friends of the user with email, oldest first like GetUsers. soft-deleted ones are left out
+// until they're restored. ErrUserNotFound if there's no such user
+func (c *Client) GetFriends(ctx context.Context, email string) ([]User, error) {
+	users := []User{}
+	err := c.view(ctx, "GetFriends", email, func(db *Schema) error {
+		email := EmailKey(email)
+		if _, ok := db.activeUser(email); !ok {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, email)
+		}
+		for _, request := range db.FriendRequests {
+			if request.Status != FriendRequestAccepted || (request.From != email && request.To != email) {
+				continue
+			}
+			if user, ok := db.activeUser(request.other(email)); ok {
+				users = append(users, c.sanitize(user))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []User{}, err
+	}
+	sortUsers(users)
+	return users, nil
+}
@@ -0,0 +1,319 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newFriendClient has a@, b@ and c@example.com and a clock the test moves
+func newFriendClient(t *testing.T, opts ...Option) (*Client, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newTestClient(t, append([]Option{WithClock(clock)}, opts...)...)
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c, clock
+}
+
+// checkFriends compares every user's GetFriends with expected, email to friends' emails
+func checkFriends(t *testing.T, c *Client, expected map[string][]string) {
+	t.Helper()
+	for email, friends := range expected {
+		got, err := c.GetFriends(ctx, email)
+		if err != nil || !reflect.DeepEqual(emails(got), append([]string{}, friends...)) {
+			t.Errorf("GetFriends(%s) = %v, %v, expected %v", email, emails(got), err, friends)
+		}
+	}
+}
+
+// pendingPairs is each request as from->to
+func pendingPairs(requests []FriendRequest) []string {
+	pairs := []string{}
+	for _, request := range requests {
+		pairs = append(pairs, request.From+"->"+request.To)
+	}
+	return pairs
+}
+
+func TestFriendRequestStateMachine(t *testing.T) {
+	c, clock := newFriendClient(t)
+	sent, err := c.SendFriendRequest(ctx, "A@example.com", "b@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.From != "a@example.com" || sent.To != "b@example.com" || sent.Status != FriendRequestPending ||
+		sent.CreatedAt.IsZero() || !sent.RespondedAt.IsZero() {
+		t.Errorf("SendFriendRequest() = %+v, expected pending from a@ to b@", sent)
+	}
+
+	// both ends see it as pending, neither as a friend
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		requests, err := c.GetPendingRequests(ctx, email)
+		if err != nil || !reflect.DeepEqual(pendingPairs(requests), []string{"a@example.com->b@example.com"}) {
+			t.Errorf("GetPendingRequests(%s) = %v, %v, expected a@->b@", email, pendingPairs(requests), err)
+		}
+	}
+	checkFriends(t, c, map[string][]string{"a@example.com": {}, "b@example.com": {}})
+
+	// sending it again changes nothing
+	clock.Advance(time.Hour)
+	if again, err := c.SendFriendRequest(ctx, "a@example.com", "b@example.com"); err != nil || again != sent {
+		t.Errorf("SendFriendRequest() again = %+v, %v, expected %+v", again, err, sent)
+	}
+
+	// only the recipient accepts and only the sender cancels
+	if _, err := c.AcceptFriendRequest(ctx, "a@example.com", "b@example.com"); !errors.Is(err, ErrFriendRequestNotFound) {
+		t.Errorf("AcceptFriendRequest() by the sender = %v, expected ErrFriendRequestNotFound", err)
+	}
+	if _, err := c.CancelFriendRequest(ctx, "b@example.com", "a@example.com"); !errors.Is(err, ErrFriendRequestNotFound) {
+		t.Errorf("CancelFriendRequest() by the recipient = %v, expected ErrFriendRequestNotFound", err)
+	}
+
+	accepted, err := c.AcceptFriendRequest(ctx, "b@example.com", "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accepted.Status != FriendRequestAccepted || !accepted.CreatedAt.Equal(sent.CreatedAt) || !accepted.RespondedAt.After(sent.CreatedAt) {
+		t.Errorf("AcceptFriendRequest() = %+v, expected accepted after it was sent", accepted)
+	}
+	checkFriends(t, c, map[string][]string{"a@example.com": {"b@example.com"}, "b@example.com": {"a@example.com"}, "c@example.com": {}})
+	if requests, err := c.GetPendingRequests(ctx, "b@example.com"); err != nil || len(requests) != 0 {
+		t.Errorf("GetPendingRequests() after AcceptFriendRequest() = %v, %v, expected none", pendingPairs(requests), err)
+	}
+
+	// accepted is final, only RemoveFriend ends it
+	if _, err := c.AcceptFriendRequest(ctx, "b@example.com", "a@example.com"); !errors.Is(err, ErrFriendRequestNotFound) {
+		t.Errorf("AcceptFriendRequest() twice = %v, expected ErrFriendRequestNotFound", err)
+	}
+	if _, err := c.DeclineFriendRequest(ctx, "b@example.com", "a@example.com"); !errors.Is(err, ErrFriendRequestNotFound) {
+		t.Errorf("DeclineFriendRequest() of a friendship = %v, expected ErrFriendRequestNotFound", err)
+	}
+	if again, err := c.SendFriendRequest(ctx, "b@example.com", "a@example.com"); err != nil || again != accepted {
+		t.Errorf("SendFriendRequest() to a friend = %+v, %v, expected the friendship %+v", again, err, accepted)
+	}
+	if err := c.RemoveFriend(ctx, "b@example.com", "c@example.com"); !errors.Is(err, ErrFriendRequestNotFound) {
+		t.Errorf("RemoveFriend() of a stranger = %v, expected ErrFriendRequestNotFound", err)
+	}
+	if err := c.RemoveFriend(ctx, "B@example.com", "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	checkFriends(t, c, map[string][]string{"a@example.com": {}, "b@example.com": {}})
+}
+
+func TestDeclineAndCancelFriendRequest(t *testing.T) {
+	c, _ := newFriendClient(t)
+	for _, to := range []string{"b@example.com", "c@example.com"} {
+		if _, err := c.SendFriendRequest(ctx, "a@example.com", to); err != nil {
+			t.Fatal(err)
+		}
+	}
+	declined, err := c.DeclineFriendRequest(ctx, "b@example.com", "a@example.com")
+	if err != nil || declined.Status != FriendRequestDeclined || declined.RespondedAt.IsZero() {
+		t.Errorf("DeclineFriendRequest() = %+v, %v, expected declined", declined, err)
+	}
+	cancelled, err := c.CancelFriendRequest(ctx, "a@example.com", "C@example.com")
+	if err != nil || cancelled.Status != FriendRequestCancelled || cancelled.RespondedAt.IsZero() {
+		t.Errorf("CancelFriendRequest() = %+v, %v, expected cancelled", cancelled, err)
+	}
+	if requests, err := c.GetPendingRequests(ctx, "a@example.com"); err != nil || len(requests) != 0 {
+		t.Errorf("GetPendingRequests() after declining and cancelling = %v, %v, expected none", pendingPairs(requests), err)
+	}
+	checkFriends(t, c, map[string][]string{"a@example.com": {}, "b@example.com": {}, "c@example.com": {}})
+
+	// neither can be responded to again
+	if _, err := c.AcceptFriendRequest(ctx, "b@example.com", "a@example.com"); !errors.Is(err, ErrFriendRequestNotFound) {
+		t.Errorf("AcceptFriendRequest() of a declined request = %v, expected ErrFriendRequestNotFound", err)
+	}
+	if _, err := c.AcceptFriendRequest(ctx, "c@example.com", "a@example.com"); !errors.Is(err, ErrFriendRequestNotFound) {
+		t.Errorf("AcceptFriendRequest() of a cancelled request = %v, expected ErrFriendRequestNotFound", err)
+	}
+
+	// but a new one can be sent, by either side
+	if again, err := c.SendFriendRequest(ctx, "a@example.com", "b@example.com"); err != nil || again.Status != FriendRequestPending {
+		t.Errorf("SendFriendRequest() after a decline = %+v, %v, expected pending", again, err)
+	}
+	if again, err := c.SendFriendRequest(ctx, "c@example.com", "a@example.com"); err != nil || again.Status != FriendRequestPending || again.From != "c@example.com" {
+		t.Errorf("SendFriendRequest() back after a cancel = %+v, %v, expected pending from c@", again, err)
+	}
+	requests, err := c.GetPendingRequests(ctx, "a@example.com")
+	if expected := []string{"a@example.com->b@example.com", "c@example.com->a@example.com"}; err != nil || !reflect.DeepEqual(pendingPairs(requests), expected) {
+		t.Errorf("GetPendingRequests() = %v, %v, expected %v", pendingPairs(requests), err, expected)
+	}
+}
+
+func TestCrossingFriendRequests(t *testing.T) {
+	c, _ := newFriendClient(t)
+	sent, err := c.SendFriendRequest(ctx, "a@example.com", "b@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// b@ asking a@ back accepts a@'s request rather than making a second one
+	crossed, err := c.SendFriendRequest(ctx, "b@example.com", "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crossed.From != "a@example.com" || crossed.Status != FriendRequestAccepted || !crossed.CreatedAt.Equal(sent.CreatedAt) {
+		t.Errorf("SendFriendRequest() back = %+v, expected a@'s request accepted", crossed)
+	}
+	checkFriends(t, c, map[string][]string{"a@example.com": {"b@example.com"}, "b@example.com": {"a@example.com"}})
+	db, err := c.Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.FriendRequests) != 1 {
+		t.Errorf("friend requests after crossing = %v, expected one", db.FriendRequests)
+	}
+}
+
+func TestSendFriendRequestErrors(t *testing.T) {
+	c, _ := newFriendClient(t)
+	if err := c.BlockUser(ctx, "c@example.com", "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, "d@example.com", "123456", "name", 18); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SoftDeleteUser(ctx, "d@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		from     string
+		to       string
+		expected error
+	}{
+		{from: "a@example.com", to: " A@example.com", expected: ErrSelfFriendRequest},
+		{from: "a@example.com", to: "missing@example.com", expected: ErrUserNotFound},
+		{from: "missing@example.com", to: "a@example.com", expected: ErrUserNotFound},
+		{from: "a@example.com", to: "d@example.com", expected: ErrUserNotFound},
+		// whichever of them blocked the other
+		{from: "a@example.com", to: "c@example.com", expected: ErrPermissionDenied},
+		{from: "c@example.com", to: "a@example.com", expected: ErrPermissionDenied},
+		{from: "a@example.com", to: "b@example.com", expected: nil},
+	}
+	for _, test := range tests {
+		if _, err := c.SendFriendRequest(ctx, test.from, test.to); !errors.Is(err, test.expected) {
+			t.Errorf("SendFriendRequest(%q, %q) = %v, expected %v", test.from, test.to, err, test.expected)
+		}
+	}
+	if _, err := c.GetPendingRequests(ctx, "missing@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetPendingRequests() of a missing user = %v, expected ErrUserNotFound", err)
+	}
+	if _, err := c.GetFriends(ctx, "missing@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetFriends() of a missing user = %v, expected ErrUserNotFound", err)
+	}
+}
+
+func TestBlockEndsFriendship(t *testing.T) {
+	c, _ := newFriendClient(t)
+	for _, to := range []string{"b@example.com", "c@example.com"} {
+		if _, err := c.SendFriendRequest(ctx, "a@example.com", to); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.AcceptFriendRequest(ctx, "b@example.com", "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	for _, blocked := range []string{"b@example.com", "c@example.com"} {
+		if err := c.BlockUser(ctx, blocked, "a@example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkFriends(t, c, map[string][]string{"a@example.com": {}, "b@example.com": {}})
+	if requests, err := c.GetPendingRequests(ctx, "c@example.com"); err != nil || len(requests) != 0 {
+		t.Errorf("GetPendingRequests() after BlockUser() = %v, %v, expected none", pendingPairs(requests), err)
+	}
+	// unblocking doesn't bring it back
+	if err := c.UnblockUser(ctx, "b@example.com", "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	checkFriends(t, c, map[string][]string{"a@example.com": {}, "b@example.com": {}})
+}
+
+func TestGetFriendsHidesSoftDeleted(t *testing.T) {
+	c, _ := newFriendClient(t)
+	for _, to := range []string{"c@example.com", "b@example.com"} {
+		if _, err := c.SendFriendRequest(ctx, "a@example.com", to); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.AcceptFriendRequest(ctx, to, "a@example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	friends, err := c.GetFriends(ctx, "a@example.com")
+	if err != nil || !reflect.DeepEqual(emails(friends), []string{"b@example.com", "c@example.com"}) {
+		t.Errorf("GetFriends() = %v, %v, expected b@ and c@", emails(friends), err)
+	}
+	for _, user := range friends {
+		if user.PasswordHash != "" {
+			t.Errorf("GetFriends() returned PasswordHash %q for %s, expected it empty", user.PasswordHash, user.Email)
+		}
+	}
+	if _, err := c.SoftDeleteUser(ctx, "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	checkFriends(t, c, map[string][]string{"a@example.com": {"b@example.com"}})
+	if _, err := c.RestoreUser(ctx, "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	checkFriends(t, c, map[string][]string{"a@example.com": {"b@example.com", "c@example.com"}})
+}
+
+func TestDeleteUserDropsFriendRequests(t *testing.T) {
+	for name, opts := range map[string][]Option{"file": nil, "wal": {WithWAL(100)}} {
+		c, _ := newFriendClient(t, opts...)
+		for _, request := range [][2]string{{"a@example.com", "b@example.com"}, {"c@example.com", "a@example.com"}, {"b@example.com", "c@example.com"}} {
+			if _, err := c.SendFriendRequest(ctx, request[0], request[1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := c.AcceptFriendRequest(ctx, "c@example.com", "b@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.DeleteUser(ctx, "a@example.com", DeleteUserOptions{}); err != nil {
+			t.Fatal(err)
+		}
+
+		// read back from what's stored, only b@ and c@'s friendship is left
+		reopened := NewClient(dbPath(c), opts...)
+		db, err := reopened.Dump(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request, ok := db.FriendRequests[friendKey("b@example.com", "c@example.com")]
+		if len(db.FriendRequests) != 1 || !ok || request.Status != FriendRequestAccepted {
+			t.Errorf("%s: friend requests after DeleteUser() = %v, expected only b@ and c@ as friends", name, db.FriendRequests)
+		}
+		checkFriends(t, reopened, map[string][]string{"b@example.com": {"c@example.com"}, "c@example.com": {"b@example.com"}})
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestChangeEmailMovesFriendRequests(t *testing.T) {
+	c, _ := newFriendClient(t)
+	for _, request := range [][2]string{{"a@example.com", "b@example.com"}, {"c@example.com", "a@example.com"}} {
+		if _, err := c.SendFriendRequest(ctx, request[0], request[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.AcceptFriendRequest(ctx, "b@example.com", "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ChangeEmail(ctx, "a@example.com", "new@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	checkFriends(t, c, map[string][]string{"new@example.com": {"b@example.com"}, "b@example.com": {"new@example.com"}})
+	requests, err := c.GetPendingRequests(ctx, "c@example.com")
+	if expected := []string{"c@example.com->new@example.com"}; err != nil || !reflect.DeepEqual(pendingPairs(requests), expected) {
+		t.Errorf("GetPendingRequests() after ChangeEmail() = %v, %v, expected %v", pendingPairs(requests), err, expected)
+	}
+	if _, err := c.AcceptFriendRequest(ctx, "new@example.com", "c@example.com"); err != nil {
+		t.Errorf("AcceptFriendRequest() under the new email = %v, expected nil", err)
+	}
+}
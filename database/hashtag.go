@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// tagRune is whether r can be part of a hashtag: letters, digits, their marks and underscores
+func tagRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.M, r) || r == '_'
+}
+
+// tagKey -
+// tag folded to lower case like searchTerms does, accents kept, or "" if it isn't a hashtag:
+// it has to be tagRunes only with a letter among them, so "#1" in "issue #1" isn't one
+func tagKey(tag string) string {
+	letter := false
+	for _, r := range tag {
+		if !tagRune(r) {
+			return ""
+		}
+		letter = letter || unicode.IsLetter(r)
+	}
+	if !letter {
+		return ""
+	}
+	return strings.Map(func(r rune) rune { return unicode.ToLower(foldRune(r)) }, tag)
+}
+
+// hashtags -
+// the tags of text in the order they first come up, each once, nil if there are none. a tag is a #
+// that doesn't follow a tagRune, so "a#b" has none, and the tagRunes right after it: "#go!" is go
+func hashtags(text string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '#' || (i > 0 && tagRune(runes[i-1])) {
+			continue
+		}
+		end := i + 1
+		for end < len(runes) && tagRune(runes[end]) {
+			end++
+		}
+		if tag := tagKey(string(runes[i+1 : end])); tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+		i = end - 1
+	}
+	return tags
+}
+
+// keyCounts is keys, the tags or mentions of a post, as the counts of a postIndex, 1 each
+func keyCounts(keys []string) map[string]int {
+	counts := make(map[string]int, len(keys))
+	for _, key := range keys {
+		counts[key] = 1
+	}
+	return counts
+}
+
+// idKeys -
+// id as the one key of a post in Reposts, Replies or Authored, none when it's empty
+func idKeys(id string) []string {
+	if id == "" {
+		return nil
+	}
+	return []string{id}
+}
+
+// fillPosts -
+// posts written before there were tags and mentions get theirs, the file keeps them without until
+// the next write. the mentions need the username index
+func (db *Schema) fillPosts() {
+	for id, post := range db.Posts {
+		changed := false
+		if post.Tags == nil && strings.ContainsRune(post.Text, '#') {
+			post.Tags = hashtags(post.Text)
+			changed = true
+		}
+		if post.Mentions == nil && strings.ContainsRune(post.Text, '@') {
+			post.Mentions = db.mentions(post.Text, post.UserEmail)
+			changed = true
+		}
+		if changed {
+			db.Posts[id] = post
+		}
+	}
+}
+
+// GetPostsByHashtag -
+// the posts tagged with tag, with or without its #, newest first (or in opts.Order) one page at a time,
+// with the filters and cursors of GetAllPostsPage and a Limit of 0 being DefaultPostPageSize posts like
+// GetPostsPage. "#Go" and "#go" are the same tag. the posts are looked up in an index of the tags rather
+// than by scanning. ErrInvalidHashtag for a tag that couldn't be in a post, ErrInvalidListOptions
+// and ErrInvalidCursor like GetAllPostsPage
+func (c *Client) GetPostsByHashtag(ctx context.Context, tag string, opts ListOptions) (PostPage, error) {
+	key := tagKey(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if key == "" {
+		return PostPage{Posts: []Post{}}, fmt.Errorf("%w: %q", ErrInvalidHashtag, tag)
+	}
+	if err := opts.validate(); err != nil {
+		return PostPage{Posts: []Post{}}, err
+	}
+	opts.Viewer = EmailKey(opts.Viewer)
+	return c.indexedPosts(ctx, "GetPostsByHashtag", key, opts, func(db *Schema) (map[string]int, error) {
+		return db.Hashtags[key], nil
+	})
+}
+
+// indexedPosts -
+// the page of the posts with the IDs lookup finds in one of the indexes that pass opts' filters,
+// sorted in opts.Order. a Limit of 0 is DefaultPostPageSize posts
+func (c *Client) indexedPosts(ctx context.Context, op, key string, opts ListOptions, lookup func(db *Schema) (map[string]int, error)) (PostPage, error) {
+	if opts.Limit == 0 {
+		opts.Limit = DefaultPostPageSize
+	}
+	authors := opts.authorSet()
+	posts := []Post{}
+	now := c.clock.Now()
+	err := c.view(ctx, op, key, func(db *Schema) error {
+		ids, err := lookup(db)
+		if err != nil {
+			return err
+		}
+		i := 0
+		for id := range ids {
+			// a popular key can still be a lot of posts
+			if i++; i%cancelCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			if post, ok := db.Posts[id]; ok && db.listedPost(post, opts, authors, now) {
+				posts = append(posts, post)
+			}
+		}
+		if opts.IncludeOriginals {
+			db.withOriginals(posts, opts, now)
+		}
+		return nil
+	})
+	if err != nil {
+		return PostPage{Posts: []Post{}}, err
+	}
+	// sorted outside the lock, the slice is a copy
+	SortPosts(posts, opts.Order)
+	return postPage(posts, opts)
+}
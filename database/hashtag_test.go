@@ -0,0 +1,175 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestHashtags(t *testing.T) {
+	var tests = []struct {
+		text     string
+		expected []string
+	}{
+		{text: "learning #go!", expected: []string{"go"}},
+		{text: "#Go and #go and #GO", expected: []string{"go"}},
+		{text: "#one #two,#three.", expected: []string{"one", "two", "three"}},
+		{text: "(#tag) #go-lang #snake_case", expected: []string{"tag", "go", "snake_case"}},
+		{text: "#über #ÜBER #uber", expected: []string{"über", "uber"}},
+		{text: "#日本 #1st ##twice", expected: []string{"日本", "1st", "twice"}},
+		// no tag: in the middle of a word, digits only, nothing after the #
+		{text: "a#b issue #1 # #!", expected: nil},
+		{text: "no tags here", expected: nil},
+	}
+	for _, test := range tests {
+		if tags := hashtags(test.text); !reflect.DeepEqual(tags, test.expected) {
+			t.Errorf("hashtags(%q) = %q, expected %q", test.text, tags, test.expected)
+		}
+	}
+}
+
+func TestGetPostsByHashtag(t *testing.T) {
+	c, _ := newPostSearchClient(t,
+		"starting with #Go",
+		"#rust and #go, both",
+		"nothing tagged",
+		"more #GO!",
+		"#rust only",
+	)
+	if posts, err := c.GetPosts(ctx, "a@example.com"); err != nil || !reflect.DeepEqual(posts[3].Tags, []string{"rust", "go"}) {
+		t.Errorf("GetPosts() = %+v, %v, expected the second post tagged rust and go", posts, err)
+	}
+
+	var tests = []struct {
+		tag      string
+		opts     ListOptions
+		expected []string
+		total    int
+	}{
+		{tag: "go", expected: sequence(4, 2, 1), total: 3},
+		{tag: "#Go", expected: sequence(4, 2, 1), total: 3},
+		{tag: " #GO ", expected: sequence(4, 2, 1), total: 3},
+		{tag: "rust", expected: sequence(5, 2), total: 2},
+		{tag: "go", opts: ListOptions{Order: OldestFirst}, expected: sequence(1, 2, 4), total: 3},
+		{tag: "go", opts: ListOptions{Limit: 2}, expected: sequence(4, 2), total: 3},
+		{tag: "go", opts: ListOptions{Limit: 2, Offset: 2}, expected: sequence(1), total: 3},
+		{tag: "tagged", expected: []string{}},
+		{tag: "python", expected: []string{}},
+	}
+	for _, test := range tests {
+		page, err := c.GetPostsByHashtag(ctx, test.tag, test.opts)
+		if err != nil || !reflect.DeepEqual(postIDs(page.Posts), test.expected) || page.Total != test.total {
+			t.Errorf("GetPostsByHashtag(%q, %+v) = %v of %d, %v, expected %v of %d", test.tag, test.opts, postIDs(page.Posts), page.Total, err, test.expected, test.total)
+		}
+	}
+
+	// a cursor walks the same posts
+	walked := []string{}
+	opts := ListOptions{Limit: 1}
+	for {
+		page, err := c.GetPostsByHashtag(ctx, "go", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		walked = append(walked, postIDs(page.Posts)...)
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	if !reflect.DeepEqual(walked, sequence(4, 2, 1)) {
+		t.Errorf("GetPostsByHashtag() pages = %v, expected %v", walked, sequence(4, 2, 1))
+	}
+
+	var invalid = []struct {
+		tag      string
+		opts     ListOptions
+		expected error
+	}{
+		{tag: "", expected: ErrInvalidHashtag},
+		{tag: "#", expected: ErrInvalidHashtag},
+		{tag: "#1", expected: ErrInvalidHashtag},
+		{tag: "go lang", expected: ErrInvalidHashtag},
+		{tag: "##go", expected: ErrInvalidHashtag},
+		{tag: "go", opts: ListOptions{Limit: -1}, expected: ErrInvalidListOptions},
+		{tag: "go", opts: ListOptions{Cursor: "??"}, expected: ErrInvalidCursor},
+	}
+	for _, test := range invalid {
+		if _, err := c.GetPostsByHashtag(ctx, test.tag, test.opts); !errors.Is(err, test.expected) {
+			t.Errorf("GetPostsByHashtag(%q, %+v) = %v, expected %v", test.tag, test.opts, err, test.expected)
+		}
+	}
+}
+
+func TestHashtagIndex(t *testing.T) {
+	c, _ := newPostSearchClient(t, "#red #blue", "#blue")
+	tagged := func(tag string) []string {
+		t.Helper()
+		page, err := c.GetPostsByHashtag(ctx, tag, ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return postIDs(page.Posts)
+	}
+
+	var tests = []struct {
+		name     string
+		write    func() error
+		tag      string
+		expected []string
+	}{
+		{name: "edit dropping a tag", write: func() error {
+			_, err := c.UpdatePost(ctx, sequence(1)[0], "a@example.com", "just #red now")
+			return err
+		}, tag: "blue", expected: sequence(2)},
+		{name: "edit keeping a tag", tag: "red", expected: sequence(1)},
+		{name: "edit adding a tag", write: func() error {
+			_, err := c.UpdatePost(ctx, sequence(2)[0], "a@example.com", "#blue and #Red")
+			return err
+		}, tag: "red", expected: sequence(2, 1)},
+		{name: "SoftDeletePost", write: func() error {
+			_, err := c.SoftDeletePost(ctx, sequence(2)[0], "a@example.com")
+			return err
+		}, tag: "red", expected: sequence(1)},
+		{name: "DeletePost", write: func() error {
+			_, err := c.DeletePost(ctx, sequence(1)[0])
+			return err
+		}, tag: "red", expected: []string{}},
+		{name: "RestorePost", write: func() error {
+			_, err := c.RestorePost(ctx, sequence(2)[0])
+			return err
+		}, tag: "red", expected: sequence(2)},
+	}
+	for _, test := range tests {
+		if test.write != nil {
+			if err := test.write(); err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+		}
+		checkPostIndex(t, c, test.name)
+		if ids := tagged(test.tag); !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("%s: GetPostsByHashtag(%q) = %v, expected %v", test.name, test.tag, ids, test.expected)
+		}
+	}
+	if _, ok := c.mem.Hashtags["blue"][sequence(1)[0]]; ok {
+		t.Errorf("hashtags = %v, expected the deleted post gone from blue", c.mem.Hashtags)
+	}
+}
+
+func TestFillPosts(t *testing.T) {
+	// posts from before tags get them when they're read
+	c := NewMemoryClient()
+	if err := c.Load(ctx, Schema{
+		Users: map[string]User{"a@example.com": {Email: "a@example.com"}},
+		Posts: map[string]Post{"old": {ID: "old", UserEmail: "a@example.com", Text: "from before #Tags"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	post, err := c.GetPost(ctx, "old")
+	if err != nil || !reflect.DeepEqual(post.Tags, []string{"tags"}) {
+		t.Errorf("GetPost() = %+v, %v, expected it tagged", post, err)
+	}
+	if page, err := c.GetPostsByHashtag(ctx, "tags", ListOptions{}); err != nil || !reflect.DeepEqual(postIDs(page.Posts), []string{"old"}) {
+		t.Errorf("GetPostsByHashtag() = %v, %v, expected [old]", postIDs(page.Posts), err)
+	}
+}
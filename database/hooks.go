@@ -0,0 +1,217 @@
+package database
+
+import (
+	"sort"
+	"sync"
+)
+
+// hooks -
+// the functions registered with OnUserCreated and friends
+type hooks struct {
+	mu          sync.RWMutex
+	userCreated []func(User)
+	userUpdated []func(User)
+	userDeleted []func(User)
+	postCreated []func(Post)
+	postUpdated []func(Post)
+	postDeleted []func(Post)
+	mentioned   []func(string, Post)
+	// WithFileWatch reloads, not part of events
+	externalChange []func()
+
+	// calls waiting for the worker, nil unless WithAsyncHooks
+	queue chan func()
+	start sync.Once
+}
+
+// OnUserCreated -
+// call fn with every new user once it's saved, see WithAsyncHooks to run it in the background
+// hooks see every write made through the client's methods and Tx, not the ones made by other
+// processes or Restore and Recover. a hook that panics is skipped, the write stands either way
+func (c *Client) OnUserCreated(fn func(User)) {
+	c.hooks.mu.Lock()
+	defer c.hooks.mu.Unlock()
+	c.hooks.userCreated = append(c.hooks.userCreated, fn)
+}
+
+// OnUserUpdated -
+// call fn with the new version of every user that changed, see OnUserCreated
+func (c *Client) OnUserUpdated(fn func(User)) {
+	c.hooks.mu.Lock()
+	defer c.hooks.mu.Unlock()
+	c.hooks.userUpdated = append(c.hooks.userUpdated, fn)
+}
+
+// OnUserDeleted -
+// call fn with every deleted user as it was before the delete, see OnUserCreated
+func (c *Client) OnUserDeleted(fn func(User)) {
+	c.hooks.mu.Lock()
+	defer c.hooks.mu.Unlock()
+	c.hooks.userDeleted = append(c.hooks.userDeleted, fn)
+}
+
+// OnPostCreated -
+// call fn with every new post, see OnUserCreated
+func (c *Client) OnPostCreated(fn func(Post)) {
+	c.hooks.mu.Lock()
+	defer c.hooks.mu.Unlock()
+	c.hooks.postCreated = append(c.hooks.postCreated, fn)
+}
+
+// OnPostUpdated -
+// call fn with the new version of every post that changed, anonymized ones included
+func (c *Client) OnPostUpdated(fn func(Post)) {
+	c.hooks.mu.Lock()
+	defer c.hooks.mu.Unlock()
+	c.hooks.postUpdated = append(c.hooks.postUpdated, fn)
+}
+
+// OnPostDeleted -
+// call fn with every deleted post, the ones removed with their author included
+func (c *Client) OnPostDeleted(fn func(Post)) {
+	c.hooks.mu.Lock()
+	defer c.hooks.mu.Unlock()
+	c.hooks.postDeleted = append(c.hooks.postDeleted, fn)
+}
+
+// OnMention -
+// call fn with the email of every user a post newly mentions and the post, for notifications.
+// a post mentions its Mentions once it's out: when it's created, a draft when it's published and a
+// scheduled post when PublishDue marks it, and an edit only for the users it didn't mention before.
+// a user's mentions moving to its new email isn't a mention, see OnUserCreated for the rest
+func (c *Client) OnMention(fn func(email string, post Post)) {
+	c.hooks.mu.Lock()
+	defer c.hooks.mu.Unlock()
+	c.hooks.mentioned = append(c.hooks.mentioned, fn)
+}
+
+// newMentions -
+// the Mentions of post that prev, the version of it before the write if it existed, didn't have.
+// a post that isn't out or is soft-deleted has none, and without an edit nothing is new
+func newMentions(prev Post, existed bool, post Post) []string {
+	out := func(p Post) bool { return !p.IsDraft && p.PublishAt == nil }
+	if !out(post) || post.DeletedAt != nil {
+		return nil
+	}
+	if !existed || !out(prev) {
+		return post.Mentions
+	}
+	if prev.Text == post.Text {
+		return nil
+	}
+	mentions := []string{}
+	for _, email := range post.Mentions {
+		if !containsString(prev.Mentions, email) {
+			mentions = append(mentions, email)
+		}
+	}
+	return mentions
+}
+
+// events -
+// the hook calls for the changes from old to db, a record changed several times
+// in one write only gets a call for its final version
+// users are created before their posts and deleted after them
+func (h *hooks) events(old, db Schema) []func() {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.userCreated)+len(h.userUpdated)+len(h.userDeleted)+
+		len(h.postCreated)+len(h.postUpdated)+len(h.postDeleted)+len(h.mentioned) == 0 {
+		return nil
+	}
+
+	events := []func(){}
+	userCalls := func(fns []func(User), user User) {
+		for _, fn := range fns {
+			fn := fn
+			events = append(events, func() { fn(user) })
+		}
+	}
+	postCalls := func(fns []func(Post), post Post) {
+		for _, fn := range fns {
+			fn := fn
+			events = append(events, func() { fn(post) })
+		}
+	}
+
+	for _, email := range sortedKeys(db.Users) {
+		user := db.Users[email]
+		prev, ok := old.Users[email]
+		switch {
+		case !ok:
+			userCalls(h.userCreated, user)
+		case !prev.equal(user):
+			userCalls(h.userUpdated, user)
+		}
+	}
+	for _, id := range sortedKeys(db.Posts) {
+		post := db.Posts[id]
+		prev, ok := old.Posts[id]
+		switch {
+		case !ok:
+			postCalls(h.postCreated, post)
+		case !prev.equal(post):
+			postCalls(h.postUpdated, post)
+		}
+		if len(h.mentioned) > 0 {
+			for _, email := range newMentions(prev, ok, post) {
+				for _, fn := range h.mentioned {
+					fn, email := fn, email
+					events = append(events, func() { fn(email, post) })
+				}
+			}
+		}
+	}
+	for _, id := range sortedKeys(old.Posts) {
+		if _, ok := db.Posts[id]; !ok {
+			postCalls(h.postDeleted, old.Posts[id])
+		}
+	}
+	for _, email := range sortedKeys(old.Users) {
+		if _, ok := db.Users[email]; !ok {
+			userCalls(h.userDeleted, old.Users[email])
+		}
+	}
+	return events
+}
+
+// dispatch -
+// run the hook calls now, or hand them to the worker with WithAsyncHooks
+// called without the client's locks so hooks can use the client
+func (h *hooks) dispatch(events []func()) {
+	if len(events) == 0 {
+		return
+	}
+	if h.queue == nil {
+		for _, event := range events {
+			runHook(event)
+		}
+		return
+	}
+	h.start.Do(func() {
+		go func() {
+			for event := range h.queue {
+				runHook(event)
+			}
+		}()
+	})
+	for _, event := range events {
+		h.queue <- event
+	}
+}
+
+// runHook calls a hook, a panic in it doesn't reach the caller or the worker
+func runHook(event func()) {
+	defer func() { recover() }()
+	event()
+}
+
+// sortedKeys returns the keys of m in order, so hooks run in the same order every time
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
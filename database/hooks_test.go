@@ -0,0 +1,161 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHooksRunAfterTheWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	c := NewClient(path)
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var onDisk error = errors.New("hook not called")
+	c.OnUserCreated(func(user User) {
+		// a second client only sees what's in the file
+		_, onDisk = NewClient(path).GetUser(ctx, user.Email)
+	})
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	if onDisk != nil {
+		t.Errorf("user wasn't on disk when the hook ran: %v", onDisk)
+	}
+}
+
+func TestHooksSeeFinalRecords(t *testing.T) {
+	c := newTestClient(t)
+	calls := []string{}
+	users := map[string]User{}
+	record := func(name string) func(User) {
+		return func(user User) {
+			calls = append(calls, name+" "+user.Email)
+			users[name] = user
+		}
+	}
+	c.OnUserCreated(record("userCreated"))
+	c.OnUserUpdated(record("userUpdated"))
+	c.OnUserDeleted(record("userDeleted"))
+	c.OnPostCreated(func(post Post) { calls = append(calls, "postCreated "+post.Text) })
+	c.OnPostDeleted(func(post Post) { calls = append(calls, "postDeleted "+post.Text) })
+
+	// created and changed in one Tx is a single created call with the final values
+	err := c.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		if _, err := tx.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); err != nil {
+			return err
+		}
+		if _, err := tx.CreatePost(ctx, "test@example.com", "hello"); err != nil {
+			return err
+		}
+		_, err := tx.UpdateUser(ctx, "test@example.com", "123456", "jane doe", 19)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users["userCreated"].Name != "jane doe" {
+		t.Errorf("OnUserCreated got %+v, expected the updated user", users["userCreated"])
+	}
+	if _, err := c.UpdateUser(ctx, "test@example.com", "123456", "john doe", 20); err != nil {
+		t.Fatal(err)
+	}
+	if users["userUpdated"].Age != 20 {
+		t.Errorf("OnUserUpdated got %+v, expected age 20", users["userUpdated"])
+	}
+	// failed writes don't call anything
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); !errors.Is(err, ErrUserExists) {
+		t.Fatal(err)
+	}
+	if _, err := c.DeleteUser(ctx, "test@example.com", DeleteUserOptions{Posts: PostsCascade}); err != nil {
+		t.Fatal(err)
+	}
+	if users["userDeleted"].Age != 20 {
+		t.Errorf("OnUserDeleted got %+v, expected the user as it was", users["userDeleted"])
+	}
+
+	expected := []string{
+		"userCreated test@example.com",
+		"postCreated hello",
+		"userUpdated test@example.com",
+		"postDeleted hello",
+		"userDeleted test@example.com",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("hook calls = %q, expected %q", calls, expected)
+	}
+}
+
+func TestHooksPanicAndReentry(t *testing.T) {
+	c := newTestClient(t)
+	c.OnUserCreated(func(User) { panic("boom") })
+	var fromHook User
+	c.OnUserCreated(func(user User) {
+		// the client's locks are released by the time hooks run
+		fromHook, _ = c.GetUser(ctx, user.Email)
+		c.CreatePost(ctx, user.Email, "welcome")
+	})
+
+	user, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18)
+	if err != nil {
+		t.Fatalf("CreateUser() with a panicking hook = %v, expected nil", err)
+	}
+	if fromHook != user {
+		t.Errorf("hook read %+v, expected %+v", fromHook, user)
+	}
+	posts, err := c.GetPosts(ctx, "test@example.com")
+	if err != nil || len(posts) != 1 {
+		t.Errorf("GetPosts() = %v, %v, expected the post made by the hook", posts, err)
+	}
+}
+
+func TestAsyncHooksDontBlock(t *testing.T) {
+	// hooks get the stored user, with the password
+	c := NewClient(filepath.Join(t.TempDir(), "db.json"), WithAsyncHooks(10), WithCredentials())
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	done := make(chan User, 1)
+	c.OnUserCreated(func(user User) {
+		<-release
+		done <- user
+	})
+
+	user, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the hook is stuck, writes and reads still go through
+	finished := make(chan error, 1)
+	go func() {
+		if _, err := c.CreatePost(ctx, "test@example.com", "hello"); err != nil {
+			finished <- err
+			return
+		}
+		_, err := c.GetUser(ctx, "test@example.com")
+		finished <- err
+	}()
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("client blocked behind a slow hook")
+	}
+
+	close(release)
+	select {
+	case got := <-done:
+		if got != user {
+			t.Errorf("hook got %+v, expected %+v", got, user)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("hook never finished")
+	}
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// how many IDs CreatePost tries before giving up on a generator that keeps hitting taken ones
+const maxIDAttempts = 10
+
+// IDGenerator -
+// makes new post IDs, see WithIDGenerator
+// safe for concurrent use, it's only called with the client's write lock held
+// but it may be shared between clients
+type IDGenerator interface {
+	NewID() string
+}
+
+// IDGeneratorFunc -
+// a func used as an IDGenerator
+type IDGeneratorFunc func() string
+
+// NewID calls f
+func (f IDGeneratorFunc) NewID() string {
+	return f()
+}
+
+// uuidGenerator is the default, random version 4 UUIDs
+type uuidGenerator struct{}
+
+func (uuidGenerator) NewID() string {
+	return uuid.New().String()
+}
+
+// SequenceIDGenerator -
+// IDs made of prefix and a counter starting at 1, zero padded so they sort in the
+// order they were made. meant for tests and other deterministic setups
+type SequenceIDGenerator struct {
+	Prefix string
+
+	mu   sync.Mutex
+	next uint64
+}
+
+// NewID returns the next ID in the sequence
+func (g *SequenceIDGenerator) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.next++
+	return fmt.Sprintf("%s%020d", g.Prefix, g.next)
+}
+
+// newPostID -
+// an ID from the Tx's generator that no post in db has yet
+func (tx *Tx) newPostID(db *Schema) (string, error) {
+	for i := 0; i < maxIDAttempts; i++ {
+		id := tx.ids.NewID()
+		if id == "" {
+			return "", fmt.Errorf("%w: from the ID generator", ErrEmptyPostID)
+		}
+		if _, ok := db.Posts[id]; !ok {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("%w: ID generator returned %d taken IDs in a row", ErrPostExists, maxIDAttempts)
+}
+
+// SortOrder -
+// the order posts are listed in, see SortPosts
+type SortOrder int
+
+const (
+	// NewestFirst lists the latest posts first, the default of every listing
+	NewestFirst SortOrder = iota
+	// OldestFirst lists them from the beginning, for reading an account from its first post
+	OldestFirst
+)
+
+// SortPosts -
+// the one order every method returning a list of posts uses, the backends' included: by CreatedAt,
+// posts created at the same time by ID the same way round. with a sortable IDGenerator that's
+// the order they were created in, and the same posts always come out the same
+func SortPosts(posts []Post, order SortOrder) {
+	sort.Slice(posts, func(i, j int) bool {
+		if order == OldestFirst {
+			return postLess(posts[i], posts[j])
+		}
+		return postLess(posts[j], posts[i])
+	})
+}
+
+// postLess reports whether a comes before b in OldestFirst order
+func postLess(a, b Post) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
@@ -0,0 +1,158 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSequenceIDGenerator(t *testing.T) {
+	ids := &SequenceIDGenerator{Prefix: "post-"}
+	c := NewClient(filepath.Join(t.TempDir(), "db.json"), WithIDGenerator(ids))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	post, err := c.CreatePost(ctx, "test@example.com", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "post-00000000000000000001"; post.ID != expected {
+		t.Errorf("CreatePost() ID = %q, expected %q", post.ID, expected)
+	}
+}
+
+func TestIDCollisions(t *testing.T) {
+	var tests = []struct {
+		name        string
+		ids         []string // returned in turn, the last one forever
+		expectedID  string
+		expectedErr error
+	}{
+		{name: "taken then free", ids: []string{"taken", "taken", "free"}, expectedID: "free"},
+		{name: "always taken", ids: []string{"taken"}, expectedErr: ErrPostExists},
+		{name: "empty", ids: []string{""}, expectedErr: ErrEmptyPostID},
+	}
+
+	for _, test := range tests {
+		calls := 0
+		gen := IDGeneratorFunc(func() string {
+			calls++
+			if calls > len(test.ids) {
+				return test.ids[len(test.ids)-1]
+			}
+			return test.ids[calls-1]
+		})
+		c := NewMemoryClient(WithIDGenerator(gen))
+		if err := c.Load(ctx, Schema{
+			Users: map[string]User{"test@example.com": {Email: "test@example.com"}},
+			Posts: map[string]Post{"taken": {ID: "taken", UserEmail: "test@example.com", Text: "first"}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		post, err := c.CreatePost(ctx, "test@example.com", "second")
+		if !errors.Is(err, test.expectedErr) || post.ID != test.expectedID {
+			t.Errorf("%s: CreatePost() = %q, %v, expected %q, %v", test.name, post.ID, err, test.expectedID, test.expectedErr)
+		}
+		if errors.Is(test.expectedErr, ErrPostExists) && calls != maxIDAttempts {
+			t.Errorf("%s: generator called %d times, expected %d", test.name, calls, maxIDAttempts)
+		}
+		// the existing post is never overwritten
+		db, _ := c.Dump(ctx)
+		if db.Posts["taken"].Text != "first" {
+			t.Errorf("%s: existing post was replaced: %+v", test.name, db.Posts["taken"])
+		}
+	}
+}
+
+func TestGetPostsOrder(t *testing.T) {
+	// every post at the same instant, only sortable IDs keep them in creation order
+	clock := &fakeClock{now: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := NewClient(filepath.Join(t.TempDir(), "db.json"), WithClock(clock), WithIDGenerator(&SequenceIDGenerator{}))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	texts := []string{"one", "two", "three", "four", "five", "six", "seven", "eight", "nine", "ten", "eleven"}
+	for _, text := range texts {
+		if _, err := c.CreatePost(ctx, "test@example.com", text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// and an older one created later still comes last, or first from the beginning
+	if _, err := c.CreatePostAt(ctx, "test@example.com", "zero", clock.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	oldest := append([]string{"zero"}, texts...)
+	newest := []string{}
+	for i := len(oldest) - 1; i >= 0; i-- {
+		newest = append(newest, oldest[i])
+	}
+
+	var tests = []struct {
+		name     string
+		get      func() ([]Post, error)
+		expected []string
+	}{
+		{name: "GetPosts", get: func() ([]Post, error) {
+			return c.GetPosts(ctx, "test@example.com")
+		}, expected: newest},
+		{name: "GetPostsPage", get: func() ([]Post, error) {
+			page, err := c.GetPostsPage(ctx, "test@example.com", ListOptions{})
+			return page.Posts, err
+		}, expected: newest},
+		{name: "GetPostsPage OldestFirst", get: func() ([]Post, error) {
+			page, err := c.GetPostsPage(ctx, "test@example.com", ListOptions{Order: OldestFirst})
+			return page.Posts, err
+		}, expected: oldest},
+		{name: "GetAllPosts OldestFirst", get: func() ([]Post, error) {
+			return c.GetAllPosts(ctx, ListOptions{Order: OldestFirst})
+		}, expected: oldest},
+		{name: "GetPostsPage OldestFirst by cursor", get: func() ([]Post, error) {
+			posts := []Post{}
+			opts := ListOptions{Limit: 5, Order: OldestFirst}
+			for {
+				page, err := c.GetPostsPage(ctx, "test@example.com", opts)
+				if err != nil || page.NextCursor == "" {
+					return append(posts, page.Posts...), err
+				}
+				posts, opts.Cursor = append(posts, page.Posts...), page.NextCursor
+			}
+		}, expected: oldest},
+	}
+	for _, test := range tests {
+		var first []byte
+		// map iteration differs every time, the output mustn't
+		for call := 0; call < 5; call++ {
+			posts, err := test.get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, err := json.Marshal(posts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if call == 0 {
+				first = raw
+				got := []string{}
+				for _, post := range posts {
+					got = append(got, post.Text)
+				}
+				if !reflect.DeepEqual(got, test.expected) {
+					t.Errorf("%s: %v, expected %v", test.name, got, test.expected)
+				}
+			} else if !bytes.Equal(raw, first) {
+				t.Errorf("%s: call %d = %s, expected %s", test.name, call, raw, first)
+			}
+		}
+	}
+}
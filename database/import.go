@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// ConflictPolicy -
+// what Import does with a record whose email or ID is already taken by a different record
+type ConflictPolicy int
+
+const (
+	// ConflictError fails the whole import, the default
+	ConflictError ConflictPolicy = iota
+	// ConflictSkip keeps the existing record
+	ConflictSkip
+	// ConflictOverwrite replaces the existing record with the imported one
+	ConflictOverwrite
+)
+
+// ImportOptions -
+// controls Import
+type ImportOptions struct {
+	// OnConflict picks what happens to duplicate emails and post IDs, error by default
+	OnConflict ConflictPolicy
+	// DryRun reports what the import would change without writing anything
+	DryRun bool
+}
+
+// ImportResult -
+// what Import changed, or would have changed for a dry run
+// records that are identical in both databases are only counted in Unchanged
+type ImportResult struct {
+	UsersAdded       int
+	UsersOverwritten int
+	UsersSkipped     int
+	PostsAdded       int
+	PostsOverwritten int
+	PostsSkipped     int
+	Unchanged        int
+}
+
+// Import -
+// merge the database dump read from r (a db file, older versions and compressed ones included)
+// into this one in a single write. nothing is written if r can't be parsed, if there's a conflict
+// under ConflictError or for a dry run
+func (c *Client) Import(ctx context.Context, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	// read it like this client's own file so an encrypted dump works with the same key
+	decoder, ok := c.store.(*fileStore)
+	if !ok {
+		decoder = &fileStore{}
+	}
+	incoming, _, err := decoder.decodeDB(data)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	result := ImportResult{}
+	err = c.update(ctx, "Import", "", func(db *Schema) error {
+		for _, user := range incoming.Users {
+			if err := result.mergeUser(db, user, opts.OnConflict); err != nil {
+				return err
+			}
+		}
+		for _, post := range incoming.Posts {
+			if err := result.mergePost(db, post, opts.OnConflict); err != nil {
+				return err
+			}
+		}
+		if opts.DryRun {
+			return errNoop
+		}
+		return nil
+	})
+	if err != nil {
+		return ImportResult{}, err
+	}
+	return result, nil
+}
+
+// mergeUser -
+// add user to db unless its email is taken by a different user and policy says otherwise
+func (r *ImportResult) mergeUser(db *Schema, user User, policy ConflictPolicy) error {
+	// a dump from before UpdatedAt is the same user as the one putUser filled it in for
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = user.CreatedAt
+	}
+	existing, ok := db.Users[user.Email]
+	switch {
+	case !ok:
+		r.UsersAdded++
+	case existing.equal(user):
+		r.Unchanged++
+		return nil
+	case policy == ConflictSkip:
+		r.UsersSkipped++
+		return nil
+	case policy == ConflictOverwrite:
+		r.UsersOverwritten++
+	default:
+		return fmt.Errorf("%w: %s", ErrUserExists, user.Email)
+	}
+	if user.Username != "" {
+		if err := validateUsername(user.Username); err != nil {
+			return err
+		}
+		if owner, taken := db.Usernames[user.Username]; taken && owner != user.Email {
+			return fmt.Errorf("%w: %s", ErrUsernameTaken, user.Username)
+		}
+	}
+	db.putUser(user)
+	return nil
+}
+
+// mergePost -
+// same as mergeUser for a post and its ID
+func (r *ImportResult) mergePost(db *Schema, post Post, policy ConflictPolicy) error {
+	// from a GetPosts with WithPinnedPostsFirst, a listing with IncludeOriginals or a GetPostWithOptions
+	// with IncludePollResults, none of them is stored
+	post.Pinned = false
+	post.Original = nil
+	post.PollResults = nil
+	// a dump from before tags and mentions gets them like fillPosts would
+	if post.Tags == nil {
+		post.Tags = hashtags(post.Text)
+	}
+	if post.Mentions == nil {
+		post.Mentions = db.mentions(post.Text, post.UserEmail)
+	}
+	existing, ok := db.Posts[post.ID]
+	switch {
+	case !ok:
+		r.PostsAdded++
+	case existing.equal(post):
+		r.Unchanged++
+		return nil
+	case policy == ConflictSkip:
+		r.PostsSkipped++
+		return nil
+	case policy == ConflictOverwrite:
+		r.PostsOverwritten++
+	default:
+		return fmt.Errorf("%w: %s", ErrPostExists, post.ID)
+	}
+	db.putPost(post)
+	return nil
+}
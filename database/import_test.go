@@ -0,0 +1,139 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// importFixtures returns a target db and a dump that overlaps with it:
+// shared@example.com and post-shared differ, same@example.com is identical, the rest is new
+func importFixtures(t *testing.T) (Schema, []byte) {
+	t.Helper()
+	at := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	target := Schema{
+		Users: map[string]User{
+			"shared@example.com": {CreatedAt: at, Email: "shared@example.com", PasswordHash: "1", Name: "target", Age: 18},
+			"same@example.com":   {CreatedAt: at, Email: "same@example.com", PasswordHash: "1", Name: "same", Age: 18},
+		},
+		Posts: map[string]Post{
+			"post-shared": {ID: "post-shared", CreatedAt: at, UserEmail: "shared@example.com", Text: "target text"},
+		},
+	}
+	dump := Schema{
+		SchemaVersion: currentSchemaVersion,
+		Users: map[string]User{
+			"shared@example.com": {CreatedAt: at, Email: "shared@example.com", PasswordHash: "2", Name: "dump", Age: 30},
+			"same@example.com":   target.Users["same@example.com"],
+			"new@example.com":    {CreatedAt: at, Email: "new@example.com", PasswordHash: "2", Name: "new", Age: 40},
+		},
+		Posts: map[string]Post{
+			"post-shared": {ID: "post-shared", CreatedAt: at, UserEmail: "shared@example.com", Text: "dump text"},
+			"post-new":    {ID: "post-new", CreatedAt: at, UserEmail: "new@example.com", Text: "new text"},
+		},
+	}
+	data, err := json.Marshal(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return target, data
+}
+
+func TestImport(t *testing.T) {
+	var tests = []struct {
+		policy       ConflictPolicy
+		expectedErr  error
+		expected     ImportResult
+		expectedName string // of shared@example.com afterwards
+		expectedText string // of post-shared afterwards
+	}{
+		{policy: ConflictError, expectedErr: ErrUserExists, expectedName: "target", expectedText: "target text"},
+		{
+			policy:       ConflictSkip,
+			expected:     ImportResult{UsersAdded: 1, UsersSkipped: 1, PostsAdded: 1, PostsSkipped: 1, Unchanged: 1},
+			expectedName: "target", expectedText: "target text",
+		},
+		{
+			policy:       ConflictOverwrite,
+			expected:     ImportResult{UsersAdded: 1, UsersOverwritten: 1, PostsAdded: 1, PostsOverwritten: 1, Unchanged: 1},
+			expectedName: "dump", expectedText: "dump text",
+		},
+	}
+
+	for _, test := range tests {
+		for _, dryRun := range []bool{false, true} {
+			target, dump := importFixtures(t)
+			c := newTestClient(t)
+			if err := c.Load(ctx, target); err != nil {
+				t.Fatal(err)
+			}
+			before, err := os.ReadFile(dbPath(c))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := c.Import(ctx, strings.NewReader(string(dump)), ImportOptions{OnConflict: test.policy, DryRun: dryRun})
+			if !errors.Is(err, test.expectedErr) {
+				t.Errorf("Import() with policy %d = %v, expected %v", test.policy, err, test.expectedErr)
+			}
+			if result != test.expected {
+				t.Errorf("Import() with policy %d, dry run %t = %+v, expected %+v", test.policy, dryRun, result, test.expected)
+			}
+			if dryRun || test.expectedErr != nil {
+				assertFileContents(t, dbPath(c), string(before))
+				continue
+			}
+
+			db, err := NewClient(dbPath(c)).Dump(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := db.Users["shared@example.com"].Name; got != test.expectedName {
+				t.Errorf("policy %d: shared user name = %s, expected %s", test.policy, got, test.expectedName)
+			}
+			if got := db.Posts["post-shared"].Text; got != test.expectedText {
+				t.Errorf("policy %d: shared post text = %s, expected %s", test.policy, got, test.expectedText)
+			}
+			if _, ok := db.Users["new@example.com"]; !ok {
+				t.Errorf("policy %d: new user wasn't imported", test.policy)
+			}
+			if _, ok := db.Posts["post-new"]; !ok {
+				t.Errorf("policy %d: new post wasn't imported", test.policy)
+			}
+		}
+	}
+}
+
+func TestImportPostConflict(t *testing.T) {
+	target, dump := importFixtures(t)
+	// only the post collides
+	delete(target.Users, "shared@example.com")
+	c := NewMemoryClient()
+	if err := c.Load(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Import(ctx, strings.NewReader(string(dump)), ImportOptions{}); !errors.Is(err, ErrPostExists) {
+		t.Errorf("Import() with a clashing post ID = %v, expected %v", err, ErrPostExists)
+	}
+}
+
+func TestImportMalformed(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range []string{`{"users":{"a@example.com":`, "not json", ""} {
+		if _, err := c.Import(ctx, strings.NewReader(input), ImportOptions{OnConflict: ConflictOverwrite}); err == nil {
+			t.Errorf("Import(%q) = nil, expected an error", input)
+		}
+	}
+	assertFileContents(t, dbPath(c), string(before))
+}
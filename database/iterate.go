@@ -0,0 +1,72 @@
+package database
+
+import "context"
+
+// IterateOptions -
+// controls Client.IteratePosts
+type IterateOptions struct {
+	// UserEmail only visits the posts of that user, every post if empty
+	UserEmail string
+	// IncludeDeactivated also visits the posts of deactivated users, left out otherwise
+	IncludeDeactivated bool
+	// IncludeDeleted also visits soft-deleted posts, left out otherwise
+	IncludeDeleted bool
+	// Viewer, when set, leaves out the posts of users the viewer blocked or who blocked the viewer
+	// and those of private users the viewer doesn't follow, and the posts whose Visibility doesn't let
+	// the viewer read them. without a UserEmail it's the viewer's
+	// feed and the posts of users the viewer muted are left out too, see MuteUser
+	Viewer string
+	// Anonymous reads as someone logged out, leaving out the posts of every private user and every post
+	// that isn't public. Viewer is ignored
+	Anonymous bool
+}
+
+// IteratePosts -
+// call fn with each post in no particular order until it returns false, without building a slice.
+// drafts and posts scheduled for later are never visited
+// the posts are those of the db when the call started, writes made meanwhile (fn's included) aren't seen.
+// no lock is held while fn runs so it can use the client. returns ctx's error if it's cancelled midway
+func (c *Client) IteratePosts(ctx context.Context, opts IterateOptions, fn func(Post) bool) error {
+	var snapshot *Schema
+	err := c.view(ctx, "IteratePosts", opts.UserEmail, func(db *Schema) error {
+		// writes replace c.mem with a modified copy and never touch its maps in place,
+		// so holding on to db is a free snapshot
+		snapshot = db
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	userEmail, viewer, now := EmailKey(opts.UserEmail), EmailKey(opts.Viewer), c.clock.Now()
+	if opts.Anonymous {
+		viewer = ""
+	}
+	i := 0
+	for _, post := range snapshot.Posts {
+		if i++; i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if userEmail != "" && post.UserEmail != userEmail {
+			continue
+		}
+		if post.unpublished(now) || (post.DeletedAt != nil && !opts.IncludeDeleted) {
+			continue
+		}
+		if author, ok := snapshot.Users[post.UserEmail]; ok && !opts.IncludeDeactivated && !author.Active() {
+			continue
+		}
+		if (viewer != "" || opts.Anonymous) && !snapshot.canSeePost(viewer, post) {
+			continue
+		}
+		if userEmail == "" && snapshot.Mutes.has(viewer, post.UserEmail) {
+			continue
+		}
+		if !fn(post) {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+)
+
+func seedIterate(t *testing.T) *Client {
+	t.Helper()
+	c := newTestClient(t)
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, post := range []struct{ email, text string }{
+		{"a@example.com", "a1"}, {"a@example.com", "a2"}, {"b@example.com", "b1"},
+	} {
+		if _, err := c.CreatePost(ctx, post.email, post.text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+func TestIteratePosts(t *testing.T) {
+	c := seedIterate(t)
+	tests := []struct {
+		opts     IterateOptions
+		expected []string
+	}{
+		{opts: IterateOptions{}, expected: []string{"a1", "a2", "b1"}},
+		{opts: IterateOptions{UserEmail: "a@example.com"}, expected: []string{"a1", "a2"}},
+		{opts: IterateOptions{UserEmail: "nobody@example.com"}, expected: []string{}},
+	}
+
+	for _, test := range tests {
+		texts := []string{}
+		err := c.IteratePosts(ctx, test.opts, func(post Post) bool {
+			texts = append(texts, post.Text)
+			return true
+		})
+		if err != nil {
+			t.Fatalf("IteratePosts(%+v) = %v", test.opts, err)
+		}
+		sort.Strings(texts)
+		if len(texts) != len(test.expected) {
+			t.Errorf("IteratePosts(%+v) visited %v, expected %v", test.opts, texts, test.expected)
+			continue
+		}
+		for i := range texts {
+			if texts[i] != test.expected[i] {
+				t.Errorf("IteratePosts(%+v) visited %v, expected %v", test.opts, texts, test.expected)
+				break
+			}
+		}
+	}
+}
+
+func TestIteratePostsEarlyStop(t *testing.T) {
+	c := seedIterate(t)
+	calls := 0
+	err := c.IteratePosts(ctx, IterateOptions{}, func(post Post) bool {
+		calls++
+		return false
+	})
+	if err != nil || calls != 1 {
+		t.Errorf("IteratePosts() stopping at once = %d calls, %v, expected 1 call", calls, err)
+	}
+}
+
+func TestIteratePostsSnapshot(t *testing.T) {
+	c := seedIterate(t)
+	seen := 0
+	// writing from fn neither deadlocks nor shows up in the iteration
+	err := c.IteratePosts(ctx, IterateOptions{UserEmail: "a@example.com"}, func(post Post) bool {
+		seen++
+		if _, err := c.CreatePost(ctx, "a@example.com", "new"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.DeletePost(ctx, post.ID); err != nil {
+			t.Fatal(err)
+		}
+		return true
+	})
+	if err != nil || seen != 2 {
+		t.Errorf("IteratePosts() with writes in fn = %d posts, %v, expected the 2 from the start", seen, err)
+	}
+	posts, err := c.GetPosts(ctx, "a@example.com")
+	if err != nil || len(posts) != 2 || posts[0].Text != "new" || posts[1].Text != "new" {
+		t.Errorf("GetPosts() after the writes = %+v, %v", posts, err)
+	}
+}
+
+func TestIteratePostsCancelled(t *testing.T) {
+	c := seedIterate(t)
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := c.IteratePosts(cctx, IterateOptions{}, func(Post) bool { return true }); !errors.Is(err, context.Canceled) {
+		t.Errorf("IteratePosts() with a cancelled context = %v, expected context.Canceled", err)
+	}
+}
+
+// BenchmarkGetPosts builds the whole slice, compare with BenchmarkIteratePosts
+func BenchmarkGetPosts(b *testing.B) {
+	c := seedLargeDB(b, 100000)
+	// load it up front so only the reads are measured
+	if err := c.EnsureDB(ctx); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.GetPosts(ctx, "test@example.com"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkIteratePosts visits the same posts without allocating per post
+func BenchmarkIteratePosts(b *testing.B) {
+	c := seedLargeDB(b, 100000)
+	// load it up front so only the reads are measured
+	if err := c.EnsureDB(ctx); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := c.IteratePosts(ctx, IterateOptions{UserEmail: "test@example.com"}, func(Post) bool { return true })
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ListOptions -
+// controls Client.GetUsers and the post listings, GetAllPostsPage, GetPostsPage and GetPostsByHashtag
+type ListOptions struct {
+	// Offset skips that many records of the sorted list, past the end gives an empty page
+	Offset int
+	// Limit caps how many records are returned, 0 means all of them from Offset on
+	// except for GetPostsPage, where it's DefaultPostPageSize
+	Limit int
+	// StripPasswords blanks every user's PasswordHash in the results even with WithCredentials,
+	// without it they're blank anyway
+	StripPasswords bool
+	// IncludeDeleted lists soft-deleted users too, or soft-deleted posts and the posts of
+	// soft-deleted users, they're left out by default
+	IncludeDeleted bool
+
+	// the rest only filter posts
+	// IncludeDeactivated lists the posts of deactivated users too, left out by default
+	IncludeDeactivated bool
+	// Since and Until, when set, only list posts created at Since or later and at Until or earlier,
+	// either can be left zero
+	Since, Until time.Time
+	// Authors, when set, only lists the posts of those emails
+	Authors []string
+	// Viewer only lists the posts the viewer may read, like IterateOptions'. without one that's
+	// what someone logged out may read, and Anonymous reads as them whatever Viewer is
+	Viewer    string
+	Anonymous bool
+	// IgnoreVisibility lists posts whatever their Visibility and however private their author,
+	// with no viewer checks at all. for admin tooling, Viewer and Anonymous are ignored
+	IgnoreVisibility bool
+	// IncludeOriginals sets Original on the reposts listed, see repost.go
+	IncludeOriginals bool
+	// Order is the order posts are listed in, NewestFirst by default
+	Order SortOrder
+	// Cursor is the NextCursor of the page before, to continue right after its last post
+	// even if posts were added or removed in between. it can't be used with an Offset
+	Cursor string
+}
+
+// validate -
+// ErrInvalidListOptions for a negative Offset or Limit, an Until before Since or a Cursor with an Offset
+func (opts ListOptions) validate() error {
+	if opts.Offset < 0 || opts.Limit < 0 {
+		return fmt.Errorf("%w: offset %d, limit %d", ErrInvalidListOptions, opts.Offset, opts.Limit)
+	}
+	if !opts.Since.IsZero() && !opts.Until.IsZero() && opts.Until.Before(opts.Since) {
+		return fmt.Errorf("%w: until %v is before since %v", ErrInvalidListOptions, opts.Until, opts.Since)
+	}
+	if opts.Cursor != "" && opts.Offset != 0 {
+		return fmt.Errorf("%w: both a cursor and offset %d", ErrInvalidListOptions, opts.Offset)
+	}
+	return nil
+}
+
+// pageBounds -
+// the start and end of the page opts asks for in a sorted list of n records
+func (opts ListOptions) pageBounds(n int) (int, int) {
+	start, end := opts.Offset, n
+	if start > end {
+		start = end
+	}
+	if opts.Limit > 0 && opts.Limit < end-start {
+		end = start + opts.Limit
+	}
+	return start, end
+}
+
+// UserPage -
+// one page of users from GetUsers
+type UserPage struct {
+	Users []User
+	// Total is how many users there are in all (soft-deleted ones only with IncludeDeleted),
+	// for working out the number of pages
+	Total int
+}
+
+// sortUsers orders users oldest first, the email breaks ties so the order is the same on every call
+func sortUsers(users []User) {
+	sort.Slice(users, func(i, j int) bool {
+		return userLess(users[i], users[j])
+	})
+}
+
+// userLess reports whether a comes before b in sortUsers order
+func userLess(a, b User) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.Email < b.Email
+}
+
+// GetUsers -
+// list users oldest first, by CreatedAt then email, one page at a time as opts says.
+// the order only changes when users are added or removed, so paging with Offset doesn't skip
+// or repeat anyone in between. ErrInvalidListOptions for a negative Offset or Limit
+func (c *Client) GetUsers(ctx context.Context, opts ListOptions) (UserPage, error) {
+	if opts.Offset < 0 || opts.Limit < 0 {
+		return UserPage{Users: []User{}}, fmt.Errorf("%w: offset %d, limit %d", ErrInvalidListOptions, opts.Offset, opts.Limit)
+	}
+	users := []User{}
+	err := c.view(ctx, "GetUsers", "", func(db *Schema) error {
+		users = make([]User, 0, len(db.Users))
+		i := 0
+		for _, user := range db.Users {
+			// full scan, bail out if the caller gave up
+			if i++; i%cancelCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			if user.DeletedAt == nil || opts.IncludeDeleted {
+				users = append(users, user)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return UserPage{Users: []User{}}, err
+	}
+
+	// sorted outside the lock, the slice is a copy
+	sortUsers(users)
+	page := UserPage{Total: len(users)}
+	start, end := opts.pageBounds(len(users))
+	// copied so a small page doesn't keep every user alive
+	page.Users = append(make([]User, 0, end-start), users[start:end]...)
+	for i := range page.Users {
+		if opts.StripPasswords {
+			page.Users[i].PasswordHash = ""
+		}
+		page.Users[i] = c.sanitize(page.Users[i])
+	}
+	return page, nil
+}
+
+// GetInactiveUsers -
+// the users who haven't logged in since the cutoff, oldest first like GetUsers.
+// a user who never logged in counts from CreatedAt, so accounts from before LastLoginAt
+// and ones made after since aren't mixed up. soft-deleted users are left out
+func (c *Client) GetInactiveUsers(ctx context.Context, since time.Time) ([]User, error) {
+	users := []User{}
+	err := c.view(ctx, "GetInactiveUsers", "", func(db *Schema) error {
+		i := 0
+		for _, user := range db.Users {
+			// full scan, bail out if the caller gave up
+			if i++; i%cancelCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			last := user.LastLoginAt
+			if last.IsZero() {
+				last = user.CreatedAt
+			}
+			if user.DeletedAt == nil && last.Before(since) {
+				users = append(users, user)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []User{}, err
+	}
+	sortUsers(users)
+	for i := range users {
+		users[i] = c.sanitize(users[i])
+	}
+	return users, nil
+}
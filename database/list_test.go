@@ -0,0 +1,125 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newListClient has users created a minute apart, except the last two which share a CreatedAt
+func newListClient(t *testing.T, users int) *Client {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)}
+	c := NewClient(filepath.Join(t.TempDir(), "db.json"), WithClock(clock))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// emails in the opposite order of creation, so sorting by email alone would be wrong
+	for i := users - 1; i >= 0; i-- {
+		if i != 0 {
+			clock.Advance(time.Minute)
+		}
+		if _, err := c.CreateUser(ctx, fmt.Sprintf("user%02d@example.com", i), "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+// emails of the users in order
+func emails(users []User) []string {
+	result := []string{}
+	for _, user := range users {
+		result = append(result, user.Email)
+	}
+	return result
+}
+
+func TestGetUsers(t *testing.T) {
+	c := newListClient(t, 5)
+	// oldest first, user00 and user01 were created at the same time so the email decides
+	all := []string{"user04@example.com", "user03@example.com", "user02@example.com", "user00@example.com", "user01@example.com"}
+
+	var tests = []struct {
+		opts     ListOptions
+		expected []string
+	}{
+		{opts: ListOptions{}, expected: all},
+		{opts: ListOptions{Limit: 2}, expected: all[:2]},
+		{opts: ListOptions{Offset: 2, Limit: 2}, expected: all[2:4]},
+		// the last page has what's left
+		{opts: ListOptions{Offset: 4, Limit: 2}, expected: all[4:]},
+		{opts: ListOptions{Offset: 5, Limit: 2}, expected: []string{}},
+		{opts: ListOptions{Offset: 100}, expected: []string{}},
+		// limit 0 is no limit
+		{opts: ListOptions{Offset: 3}, expected: all[3:]},
+		{opts: ListOptions{Limit: 100}, expected: all},
+	}
+	for _, test := range tests {
+		page, err := c.GetUsers(ctx, test.opts)
+		if err != nil {
+			t.Errorf("GetUsers(%+v) = %v, expected nil", test.opts, err)
+			continue
+		}
+		if got := emails(page.Users); !reflect.DeepEqual(got, test.expected) || page.Total != len(all) {
+			t.Errorf("GetUsers(%+v) = %v of %d, expected %v of %d", test.opts, got, page.Total, test.expected, len(all))
+		}
+	}
+
+	for _, opts := range []ListOptions{{Offset: -1}, {Limit: -1}} {
+		if _, err := c.GetUsers(ctx, opts); !errors.Is(err, ErrInvalidListOptions) {
+			t.Errorf("GetUsers(%+v) = %v, expected ErrInvalidListOptions", opts, err)
+		}
+	}
+}
+
+func TestGetUsersStableOrder(t *testing.T) {
+	c := newListClient(t, 50)
+	first, err := c.GetUsers(ctx, ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// map order changes between iterations, the result mustn't
+	for i := 0; i < 10; i++ {
+		again, err := c.GetUsers(ctx, ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(again, first) {
+			t.Fatalf("GetUsers() call %d = %v, expected %v", i, emails(again.Users), emails(first.Users))
+		}
+	}
+
+	// pages put together are the whole list
+	paged := []User{}
+	for offset := 0; offset < first.Total; offset += 7 {
+		page, err := c.GetUsers(ctx, ListOptions{Offset: offset, Limit: 7})
+		if err != nil {
+			t.Fatal(err)
+		}
+		paged = append(paged, page.Users...)
+	}
+	if !reflect.DeepEqual(paged, first.Users) {
+		t.Errorf("pages = %v, expected %v", emails(paged), emails(first.Users))
+	}
+}
+
+func TestGetUsersStripPasswords(t *testing.T) {
+	c := newListClient(t, 3)
+	page, err := c.GetUsers(ctx, ListOptions{StripPasswords: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range page.Users {
+		if user.PasswordHash != "" {
+			t.Errorf("GetUsers() with StripPasswords returned PasswordHash %q for %s", user.PasswordHash, user.Email)
+		}
+	}
+	// only the copy
+	if user, _ := NewClient(dbPath(c), WithCredentials()).GetUser(ctx, page.Users[0].Email); user.PasswordHash == "" {
+		t.Errorf("StripPasswords blanked the stored password of %s", user.Email)
+	}
+}
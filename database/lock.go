@@ -0,0 +1,43 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// how often to retry a held lock while waiting for it
+const lockRetryInterval = 10 * time.Millisecond
+
+// lockPath is the file used for cross-process locking
+// it's separate from the db file because atomic writes replace that file
+func (s *fileStore) lockPath() string {
+	return s.path + ".lock"
+}
+
+// Lock -
+// keep trying to take the file lock until it's acquired, the lock timeout runs out or ctx is done
+func (s *fileStore) Lock(ctx context.Context) (func(), error) {
+	deadline := time.Now().Add(s.lockTimeout)
+	for {
+		release, err := tryLockFile(s.lockPath(), s.fileMode)
+		if err == nil {
+			return release, nil
+		}
+		if !errors.Is(err, errLockHeld) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: %s", ErrDatabaseLocked, s.path)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// errLockHeld is returned by tryLockFile when someone else has the lock
+var errLockHeld = errors.New("lock held")
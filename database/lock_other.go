@@ -0,0 +1,25 @@
+//go:build !unix
+
+package database
+
+import (
+	"errors"
+	"os"
+)
+
+// tryLockFile -
+// lockfile fallback for platforms without flock: the lock is held while the file exists
+// a process that crashes while holding it leaves the file behind and it has to be removed by hand
+func tryLockFile(path string, perm os.FileMode) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return nil, errLockHeld
+		}
+		return nil, err
+	}
+	f.Close()
+	return func() {
+		os.Remove(path)
+	}, nil
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTwoClientsShareOneFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	first := NewClient(path)
+	second := NewClient(path)
+	if err := first.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// each client writes its own users in parallel, no write may clobber another
+	const perClient = 20
+	var wg sync.WaitGroup
+	for i, c := range []*Client{first, second} {
+		wg.Add(1)
+		go func(i int, c *Client) {
+			defer wg.Done()
+			for j := 0; j < perClient; j++ {
+				email := fmt.Sprintf("client%d-user%d@example.com", i, j)
+				if _, err := c.CreateUser(ctx, email, "12345", "user", 18); err != nil {
+					t.Errorf("CreateUser(%s) = %v, expected nil", email, err)
+				}
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	db, err := NewClient(path).store.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.Users) != 2*perClient {
+		t.Errorf("len(db.Users) = %d, expected %d", len(db.Users), 2*perClient)
+	}
+}
+
+func TestLockTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	holder := NewClient(path)
+	if err := holder.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waiter := NewClient(path, WithLockTimeout(50*time.Millisecond))
+
+	// hold the file lock as if another process were mid-write
+	release, err := holder.store.(Locker).Lock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = waiter.CreateUser(ctx, "test@example.com", "12345", "john doe", 18)
+	if !errors.Is(err, ErrDatabaseLocked) {
+		t.Errorf("CreateUser() while locked = %v, expected %v", err, ErrDatabaseLocked)
+	}
+
+	// once released the waiter gets through
+	release()
+	if _, err := waiter.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Errorf("CreateUser() after release = %v, expected nil", err)
+	}
+}
+
+func TestCancelWhileWaitingForLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	holder := NewClient(path)
+	if err := holder.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release, err := holder.store.(Locker).Lock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	// the call blocks on the lock, cancel it from the outside
+	waiter := NewClient(path)
+	cancelCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		_, err := waiter.CreateUser(cancelCtx, "test@example.com", "12345", "john doe", 18)
+		done <- err
+	}()
+	time.Sleep(3 * lockRetryInterval)
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("CreateUser() with cancelled ctx = %v, expected %v", err, context.Canceled)
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("db file changed after cancelled call")
+	}
+}
@@ -0,0 +1,65 @@
+package database
+
+import "time"
+
+// Logger -
+// where the client logs to, args are alternating keys and values
+// *slog.Logger satisfies it, so does a thin wrapper around any other structured logger
+//
+// writes are logged at info and reads at debug with the operation, its key (email or post ID),
+// duration and the record counts afterwards, failures at error with the error.
+// loading and saving the db is logged at debug. passwords and post text are never logged
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// finished -
+// record an update or view that just returned in the metrics and the log
+// called without the client's locks
+func (c *Client) finished(op, key string, write bool, d time.Duration, err error) {
+	c.metrics.observe(op, write, d, err)
+	if c.logger == nil {
+		return
+	}
+
+	args := []interface{}{"op", op}
+	if key != "" {
+		args = append(args, "key", key)
+	}
+	args = append(args, "duration", d)
+	if err != nil {
+		c.logger.Error("database operation failed", append(args, "error", err)...)
+		return
+	}
+	c.mu.RLock()
+	if c.mem != nil {
+		args = append(args, "users", len(c.mem.Users), "posts", len(c.mem.Posts))
+	}
+	c.mu.RUnlock()
+	if write {
+		c.logger.Info("database write", args...)
+	} else {
+		c.logger.Debug("database read", args...)
+	}
+}
+
+// logDebug, logInfo and logError log to the client's logger if it has one
+func (c *Client) logDebug(msg string, args ...interface{}) {
+	if c.logger != nil {
+		c.logger.Debug(msg, args...)
+	}
+}
+
+func (c *Client) logInfo(msg string, args ...interface{}) {
+	if c.logger != nil {
+		c.logger.Info(msg, args...)
+	}
+}
+
+func (c *Client) logError(msg string, args ...interface{}) {
+	if c.logger != nil {
+		c.logger.Error(msg, args...)
+	}
+}
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// logEntry is one call to a captureLogger
+type logEntry struct {
+	level string
+	msg   string
+	attrs map[string]interface{}
+}
+
+// captureLogger keeps everything logged to it
+type captureLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *captureLogger) log(level, msg string, args []interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	attrs := map[string]interface{}{}
+	for i := 0; i+1 < len(args); i += 2 {
+		attrs[fmt.Sprint(args[i])] = args[i+1]
+	}
+	l.entries = append(l.entries, logEntry{level: level, msg: msg, attrs: attrs})
+}
+
+func (l *captureLogger) Debug(msg string, args ...interface{}) { l.log("debug", msg, args) }
+func (l *captureLogger) Info(msg string, args ...interface{})  { l.log("info", msg, args) }
+func (l *captureLogger) Error(msg string, args ...interface{}) { l.log("error", msg, args) }
+
+// find returns the first entry at level for op
+func (l *captureLogger) find(level, op string) (logEntry, bool) {
+	for _, entry := range l.entries {
+		if entry.level == level && entry.attrs["op"] == op {
+			return entry, true
+		}
+	}
+	return logEntry{}, false
+}
+
+func TestLogger(t *testing.T) {
+	logger := &captureLogger{}
+	c := NewClient(t.TempDir()+"/db.json", WithLogger(logger))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	const password, text = "hunter2-secret", "my very private post"
+	if _, err := c.CreateUser(ctx, "test@example.com", password, "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreatePost(ctx, "test@example.com", text); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetUser(ctx, "test@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetUser(ctx, "missing@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		level string
+		op    string
+		key   string
+		users int
+		posts int
+	}{
+		{level: "info", op: "CreateUser", key: "test@example.com", users: 1, posts: 0},
+		{level: "info", op: "CreatePost", key: "test@example.com", users: 1, posts: 1},
+		{level: "debug", op: "GetUser", key: "test@example.com", users: 1, posts: 1},
+	}
+	for _, test := range tests {
+		entry, ok := logger.find(test.level, test.op)
+		if !ok {
+			t.Errorf("no %s log for %s in %+v", test.level, test.op, logger.entries)
+			continue
+		}
+		if entry.attrs["key"] != test.key || entry.attrs["users"] != test.users || entry.attrs["posts"] != test.posts {
+			t.Errorf("%s logged %v, expected key %s, %d users and %d posts", test.op, entry.attrs, test.key, test.users, test.posts)
+		}
+		if d, ok := entry.attrs["duration"].(time.Duration); !ok || d <= 0 {
+			t.Errorf("%s logged duration %v", test.op, entry.attrs["duration"])
+		}
+	}
+
+	failed, ok := logger.find("error", "GetUser")
+	if !ok || !errors.Is(failed.attrs["error"].(error), ErrUserNotFound) || failed.attrs["key"] != "missing@example.com" {
+		t.Errorf("failed GetUser logged %+v, expected an error entry", failed)
+	}
+	saved := false
+	for _, entry := range logger.entries {
+		saved = saved || (entry.level == "debug" && entry.msg == "saved db")
+	}
+	if !saved {
+		t.Errorf("no debug log for saving the db in %+v", logger.entries)
+	}
+
+	for _, entry := range logger.entries {
+		logged := fmt.Sprint(entry.msg, entry.attrs)
+		if strings.Contains(logged, password) || strings.Contains(logged, text) {
+			t.Errorf("%s log leaked a password or post text: %s", entry.level, logged)
+		}
+	}
+}
+
+func TestNoLoggerByDefault(t *testing.T) {
+	c := newTestClient(t)
+	if c.logger != nil {
+		t.Errorf("NewClient() has logger %v, expected none", c.logger)
+	}
+	// nothing to assert beyond not panicking without one
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+}
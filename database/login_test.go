@@ -0,0 +1,143 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLastLoginAt(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)}
+	c := NewClient(filepath.Join(t.TempDir(), "db.json"), WithClock(clock))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	created, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created.LastLoginAt.IsZero() || created.LoginCount != 0 {
+		t.Errorf("CreateUser() LastLoginAt = %v, LoginCount = %d, expected no logins", created.LastLoginAt, created.LoginCount)
+	}
+
+	for count := 1; count <= 3; count++ {
+		clock.Advance(time.Hour)
+		user, err := c.AuthenticateUser(ctx, "test@example.com", "correct horse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.LastLoginAt != clock.Now() || user.LoginCount != count {
+			t.Errorf("AuthenticateUser() LastLoginAt = %v, LoginCount = %d, expected %v and %d", user.LastLoginAt, user.LoginCount, clock.Now(), count)
+		}
+		stored, err := c.GetUser(ctx, "test@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.LastLoginAt != clock.Now() || stored.LoginCount != count || stored.UpdatedAt != created.UpdatedAt {
+			t.Errorf("stored after login %d = %+v, expected LastLoginAt %v and UpdatedAt unchanged", count, stored, clock.Now())
+		}
+	}
+	lastLogin := clock.Now()
+
+	// failures leave both alone
+	clock.Advance(time.Hour)
+	if _, err := c.AuthenticateUser(ctx, "test@example.com", "wrong horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatal(err)
+	}
+	if _, err := c.DeactivateUser(ctx, "test@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AuthenticateUser(ctx, "test@example.com", "correct horse"); !errors.Is(err, ErrAccountDeactivated) {
+		t.Fatal(err)
+	}
+	if err := c.VerifyPassword(ctx, "test@example.com", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := c.GetUser(ctx, "test@example.com")
+	if err != nil || stored.LastLoginAt != lastLogin || stored.LoginCount != 3 {
+		t.Errorf("after failed logins = %+v, %v, expected LastLoginAt %v and LoginCount 3", stored, err, lastLogin)
+	}
+
+	// and the file has them
+	reopened := NewClient(dbPath(c))
+	if user, err := reopened.GetUser(ctx, "test@example.com"); err != nil || user.LastLoginAt != lastLogin || user.LoginCount != 3 {
+		t.Errorf("GetUser() after reopening = %+v, %v, expected LastLoginAt %v and LoginCount 3", user, err, lastLogin)
+	}
+}
+
+func TestLastLoginAtReadOnly(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	ro := NewReadOnlyClient(dbPath(c))
+	if user, err := ro.AuthenticateUser(ctx, "test@example.com", "correct horse"); err != nil || user.LoginCount != 0 {
+		t.Errorf("AuthenticateUser() on a read-only client = %+v, %v, expected it to succeed without recording", user, err)
+	}
+}
+
+func TestGetInactiveUsers(t *testing.T) {
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+	c := NewClient(filepath.Join(t.TempDir(), "db.json"), WithClock(clock))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// a legacy account from before logins were recorded, created at start
+	if err := c.Load(ctx, Schema{Users: map[string]User{"legacy@example.com": {Email: "legacy@example.com", CreatedAt: start, PasswordHash: "hunter22"}}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{"a@example.com", "b@example.com", "deleted@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "correct horse", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.SoftDeleteUser(ctx, "deleted@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(24 * time.Hour)
+	if _, err := c.AuthenticateUser(ctx, "a@example.com", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(24 * time.Hour)
+	if _, err := c.AuthenticateUser(ctx, "b@example.com", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(24 * time.Hour)
+	// signed up late and never logged in, but not inactive for long
+	if _, err := c.CreateUser(ctx, "new@example.com", "correct horse", "name", 18); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		since    time.Time
+		expected []string
+	}{
+		// ordered by CreatedAt, then email
+		{since: start, expected: []string{}},
+		{since: start.Add(time.Hour), expected: []string{"legacy@example.com"}},
+		{since: start.Add(24*time.Hour + time.Second), expected: []string{"a@example.com", "legacy@example.com"}},
+		{since: start.Add(72*time.Hour + time.Second), expected: []string{"a@example.com", "b@example.com", "legacy@example.com", "new@example.com"}},
+	}
+	for _, test := range tests {
+		users, err := c.GetInactiveUsers(ctx, test.since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		emails := []string{}
+		for _, user := range users {
+			emails = append(emails, user.Email)
+		}
+		if len(emails) != len(test.expected) {
+			t.Errorf("GetInactiveUsers(%v) = %v, expected %v", test.since, emails, test.expected)
+			continue
+		}
+		for i := range emails {
+			if emails[i] != test.expected[i] {
+				t.Errorf("GetInactiveUsers(%v) = %v, expected %v", test.since, emails, test.expected)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package database
+
+import (
+	"context"
+)
+
+// memoryStore -
+// keeps the db in a field, the client's lock is what guards it
+type memoryStore struct {
+	db Schema
+}
+
+func (s *memoryStore) Load(ctx context.Context) (Schema, error) {
+	return s.db.clone(), nil
+}
+
+func (s *memoryStore) Save(ctx context.Context, db Schema) error {
+	s.db = db.clone()
+	return nil
+}
+
+// NewMemoryClient -
+// construct a client that keeps everything in memory and never touches disk, meant for tests
+// it starts out with an empty db so EnsureDB is optional, seed it with Load
+func NewMemoryClient(opts ...Option) *Client {
+	return NewClientWithStore(&memoryStore{db: newSchema()}, opts...)
+}
+
+// Dump -
+// return a copy of everything in the db, changing it doesn't affect the client
+func (c *Client) Dump(ctx context.Context) (Schema, error) {
+	var dumped Schema
+	err := c.view(ctx, "Dump", "", func(db *Schema) error {
+		dumped = db.clone()
+		// derived from Posts, Load builds them again
+		dumped.PostIndex, dumped.Hashtags, dumped.Mentioned = nil, nil, nil
+		dumped.Reposts, dumped.Replies, dumped.Authored = nil, nil, nil
+		return nil
+	})
+	if err != nil {
+		return Schema{}, err
+	}
+	return dumped, nil
+}
+
+// Load -
+// replace everything in the db with a copy of db, nil maps are treated as empty
+// meant for seeding a test client from a struct literal
+func (c *Client) Load(ctx context.Context, db Schema) error {
+	db = db.clone()
+	db.fillUpdatedAt()
+	db.indexUsernames()
+	db.indexFollowers()
+	db.fillPosts()
+	db.indexPosts()
+	return c.update(ctx, "Load", "", func(current *Schema) error {
+		*current = db
+		return nil
+	})
+}
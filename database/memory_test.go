@@ -0,0 +1,66 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryClientDumpLoad(t *testing.T) {
+	c := NewMemoryClient()
+	createdAt := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	seed := Schema{
+		Users: map[string]User{
+			"test@example.com": {CreatedAt: createdAt, Email: "test@example.com", PasswordHash: "12345", Name: "john doe", Age: 18},
+		},
+	}
+	if err := c.Load(ctx, seed); err != nil {
+		t.Fatalf("Load() = %v, expected nil", err)
+	}
+	// the client keeps its own copy
+	delete(seed.Users, "test@example.com")
+
+	post, err := c.CreatePost(ctx, "test@example.com", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.Dump(ctx)
+	if err != nil {
+		t.Fatalf("Dump() = %v, expected nil", err)
+	}
+	expected := Schema{
+		SchemaVersion: currentSchemaVersion,
+		Users: map[string]User{
+			// UpdatedAt is filled in for records without one
+			"test@example.com": {CreatedAt: createdAt, Email: "test@example.com", PasswordHash: "12345", Name: "john doe", Age: 18, UpdatedAt: createdAt},
+		},
+		Posts: map[string]Post{post.ID: post},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Dump() = %+v, expected %+v", got, expected)
+	}
+
+	// and so does the caller
+	delete(got.Posts, post.ID)
+	posts, err := c.GetPosts(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 {
+		t.Errorf("GetPosts() after changing the dump = %v, expected [%v]", posts, post)
+	}
+}
+
+func TestMemoryClientWithoutEnsureDB(t *testing.T) {
+	c := NewMemoryClient()
+	if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Errorf("CreateUser() on a new memory client = %v, expected nil", err)
+	}
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetUser(ctx, "test@example.com"); err != nil {
+		t.Errorf("GetUser() after EnsureDB = %v, expected the user to still be there", err)
+	}
+}
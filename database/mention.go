@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// mentionRune is whether r can be part of what's after the @ of a mention, a username or an email
+func mentionRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') ||
+		r == '.' || r == '_' || r == '-' || r == '+' || r == '@'
+}
+
+// mentionNames -
+// what follows each @ of text that starts a mention, in order: an @ that doesn't follow a mentionRune,
+// so the @ of a plain email is not one, and the mentionRunes after it without the punctuation that
+// ends a sentence. "@alice." is alice, "@bob@example.com" is bob@example.com
+func mentionNames(text string) []string {
+	names := []string{}
+	for i := 0; i < len(text); i++ {
+		if text[i] != '@' || (i > 0 && mentionRune(rune(text[i-1]))) {
+			continue
+		}
+		end := i + 1
+		for end < len(text) && mentionRune(rune(text[end])) {
+			end++
+		}
+		if name := strings.TrimRight(text[i+1:end], ".-+@"); name != "" {
+			names = append(names, name)
+		}
+		i = end - 1
+	}
+	return names
+}
+
+// mentions -
+// the emails of the users author mentions in text, in the order they first come up, each once,
+// nil if there are none. a mention is of a username or an email, of a user that exists and isn't
+// soft-deleted at the time, the others are left out. so is author mentioning itself
+func (db *Schema) mentions(text, author string) []string {
+	var emails []string
+	seen := map[string]bool{author: true}
+	for _, name := range mentionNames(text) {
+		email := ""
+		if strings.Contains(name, "@") {
+			if user, ok := db.activeUser(EmailKey(name)); ok {
+				email = user.Email
+			}
+		} else {
+			email = db.Usernames[strings.ToLower(name)]
+			if _, ok := db.activeUser(email); !ok {
+				email = ""
+			}
+		}
+		if email != "" && !seen[email] {
+			seen[email] = true
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+// moveMentions -
+// mention into instead of from in every post mentioning from, or no one with an empty into,
+// for a user whose email changed, who was merged into another or who is gone
+func (db *Schema) moveMentions(from, into string) {
+	ids := make([]string, 0, len(db.Mentioned[from]))
+	for id := range db.Mentioned[from] {
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		post, ok := db.Posts[id]
+		if !ok {
+			continue
+		}
+		var mentions []string
+		for _, email := range post.Mentions {
+			if email == from {
+				email = into
+			}
+			if email != "" && email != post.UserEmail && !containsString(mentions, email) {
+				mentions = append(mentions, email)
+			}
+		}
+		post.Mentions = mentions
+		db.putPost(post)
+	}
+}
+
+// containsString is whether s is in list
+func containsString(list []string, s string) bool {
+	for _, other := range list {
+		if other == s {
+			return true
+		}
+	}
+	return false
+}
+
+// GetMentionsOf -
+// the posts mentioning the user with email, newest first (or in opts.Order) one page at a time like
+// GetPostsByHashtag, as the user sees them: the ones it may not read and those of users it blocked
+// or who blocked it are left out, opts.Viewer and Anonymous aside. they're looked up in an index of
+// the mentions rather than by scanning. ErrUserNotFound if there's no such user, ErrInvalidListOptions
+// and ErrInvalidCursor like GetAllPostsPage
+func (c *Client) GetMentionsOf(ctx context.Context, email string, opts ListOptions) (PostPage, error) {
+	if err := opts.validate(); err != nil {
+		return PostPage{Posts: []Post{}}, err
+	}
+	email = EmailKey(email)
+	opts.Viewer, opts.Anonymous = email, false
+	return c.indexedPosts(ctx, "GetMentionsOf", email, opts, func(db *Schema) (map[string]int, error) {
+		if _, ok := db.activeUser(email); !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+		}
+		return db.Mentioned[email], nil
+	})
+}
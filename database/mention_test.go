@@ -0,0 +1,239 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newMentionClient is newBlockClient with alice@ and bob@ as the usernames of a@ and b@,
+// and d@ who has the username dave and is soft-deleted
+func newMentionClient(t *testing.T, opts ...Option) *Client {
+	t.Helper()
+	c := newBlockClient(t, append([]Option{WithIDGenerator(&SequenceIDGenerator{})}, opts...)...)
+	if _, err := c.CreateUser(ctx, "d@example.com", "123456", "name d", 18); err != nil {
+		t.Fatal(err)
+	}
+	for email, username := range map[string]string{"a@example.com": "alice", "b@example.com": "bob", "d@example.com": "dave"} {
+		if _, err := c.SetUsername(ctx, email, username); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.SoftDeleteUser(ctx, "d@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestMentionNames(t *testing.T) {
+	var tests = []struct {
+		text     string
+		expected []string
+	}{
+		{text: "@alice first", expected: []string{"alice"}},
+		{text: "thanks @alice.", expected: []string{"alice"}},
+		{text: "(@alice), @bob! @carol's @dan: @eve?", expected: []string{"alice", "bob", "carol", "dan", "eve"}},
+		{text: "cc @bob@example.com.", expected: []string{"bob@example.com"}},
+		{text: "@first.last_1 @x-y+z", expected: []string{"first.last_1", "x-y+z"}},
+		// the @ of an email isn't a mention and neither is one with nothing after it
+		{text: "mail bob@example.com or me@", expected: []string{}},
+		{text: "@ @. @!", expected: []string{}},
+	}
+	for _, test := range tests {
+		if names := mentionNames(test.text); !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("mentionNames(%q) = %q, expected %q", test.text, names, test.expected)
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	c := newMentionClient(t)
+	var tests = []struct {
+		author   string
+		text     string
+		expected []string
+	}{
+		{author: "c@example.com", text: "hi @alice and @BOB", expected: []string{"a@example.com", "b@example.com"}},
+		{author: "c@example.com", text: "@b@example.com @Alice @bob @alice", expected: []string{"b@example.com", "a@example.com"}},
+		// unknown, soft-deleted and the author itself are left out
+		{author: "a@example.com", text: "@nobody @dave @d@example.com @alice @a@example.com", expected: nil},
+		{author: "a@example.com", text: "@C@Example.com", expected: []string{"c@example.com"}},
+		{author: "a@example.com", text: "write to c@example.com", expected: nil},
+	}
+	for _, test := range tests {
+		post, err := c.CreatePost(ctx, test.author, test.text)
+		if err != nil || !reflect.DeepEqual(post.Mentions, test.expected) {
+			t.Errorf("CreatePost(%q, %q) = %+v, %v, expected mentions %q", test.author, test.text, post, err, test.expected)
+		}
+	}
+
+	// an edit mentions what the new text does
+	post, err := c.CreatePost(ctx, "c@example.com", "@alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post, err = c.UpdatePost(ctx, post.ID, "c@example.com", "@bob instead"); err != nil || !reflect.DeepEqual(post.Mentions, []string{"b@example.com"}) {
+		t.Errorf("UpdatePost() = %+v, %v, expected b@ mentioned", post, err)
+	}
+	checkPostIndex(t, c, "after the edit")
+}
+
+func TestGetMentionsOf(t *testing.T) {
+	c := newMentionClient(t)
+	for _, email := range []string{"e@example.com", "f@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.FollowUser(ctx, "a@example.com", "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.BlockUser(ctx, "a@example.com", "e@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	create := func(author, text string, visibility Visibility) Post {
+		t.Helper()
+		post, err := c.CreatePostWithOptions(ctx, author, text, CreatePostOptions{Visibility: visibility})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return post
+	}
+	public := create("b@example.com", "hey @alice", VisibilityPublic)
+	followers := create("c@example.com", "for followers, @alice", VisibilityFollowers)
+	create("f@example.com", "@alice can't see this", VisibilityFollowers)
+	create("c@example.com", "@alice private", VisibilityPrivate)
+	create("e@example.com", "@alice from someone blocked", VisibilityPublic)
+	create("b@example.com", "@bob talking to itself", VisibilityPublic)
+	byEmail := create("f@example.com", "@A@example.com by email", VisibilityPublic)
+
+	var tests = []struct {
+		email    string
+		opts     ListOptions
+		expected []string
+	}{
+		{email: "a@example.com", expected: []string{byEmail.ID, followers.ID, public.ID}},
+		{email: "A@Example.com", opts: ListOptions{Order: OldestFirst, Limit: 2}, expected: []string{public.ID, followers.ID}},
+		// the user's view, whoever's asking
+		{email: "a@example.com", opts: ListOptions{Viewer: "c@example.com"}, expected: []string{byEmail.ID, followers.ID, public.ID}},
+		{email: "b@example.com", expected: []string{}},
+		{email: "c@example.com", expected: []string{}},
+	}
+	for _, test := range tests {
+		page, err := c.GetMentionsOf(ctx, test.email, test.opts)
+		if err != nil || !reflect.DeepEqual(postIDs(page.Posts), test.expected) {
+			t.Errorf("GetMentionsOf(%q, %+v) = %v, %v, expected %v", test.email, test.opts, postIDs(page.Posts), err, test.expected)
+		}
+	}
+	for _, email := range []string{"missing@example.com", "d@example.com"} {
+		if _, err := c.GetMentionsOf(ctx, email, ListOptions{}); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("GetMentionsOf(%q) = %v, expected ErrUserNotFound", email, err)
+		}
+	}
+
+	// edits and deletes drop out
+	if _, err := c.UpdatePost(ctx, public.ID, "b@example.com", "hey nobody"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DeletePost(ctx, byEmail.ID); err != nil {
+		t.Fatal(err)
+	}
+	if page, err := c.GetMentionsOf(ctx, "a@example.com", ListOptions{}); err != nil || !reflect.DeepEqual(postIDs(page.Posts), []string{followers.ID}) {
+		t.Errorf("GetMentionsOf() after an edit and a delete = %v, %v, expected %v", postIDs(page.Posts), err, []string{followers.ID})
+	}
+	checkPostIndex(t, c, "after the edit and delete")
+}
+
+func TestMentionsFollowTheUser(t *testing.T) {
+	c := newMentionClient(t)
+	post, err := c.CreatePost(ctx, "c@example.com", "@alice and @bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ChangeEmail(ctx, "a@example.com", "new@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.GetPost(ctx, post.ID); err != nil || !reflect.DeepEqual(got.Mentions, []string{"new@example.com", "b@example.com"}) {
+		t.Errorf("GetPost() after ChangeEmail() = %+v, %v, expected new@ mentioned", got, err)
+	}
+	if page, err := c.GetMentionsOf(ctx, "new@example.com", ListOptions{}); err != nil || !reflect.DeepEqual(postIDs(page.Posts), []string{post.ID}) {
+		t.Errorf("GetMentionsOf() of the new email = %v, %v, expected %v", postIDs(page.Posts), err, []string{post.ID})
+	}
+	if _, err := c.DeleteUser(ctx, "b@example.com", DeleteUserOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.GetPost(ctx, post.ID); err != nil || !reflect.DeepEqual(got.Mentions, []string{"new@example.com"}) {
+		t.Errorf("GetPost() after DeleteUser() = %+v, %v, expected only new@ mentioned", got, err)
+	}
+	checkPostIndex(t, c, "after the moves")
+}
+
+func TestOnMention(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newMentionClient(t, WithClock(clock))
+	mentioned := []string{}
+	c.OnMention(func(email string, post Post) {
+		mentioned = append(mentioned, email+" "+post.Text)
+	})
+	expect := func(when string, expected ...string) {
+		t.Helper()
+		if expected == nil {
+			expected = []string{}
+		}
+		if !reflect.DeepEqual(mentioned, expected) {
+			t.Errorf("%s: OnMention() got %q, expected %q", when, mentioned, expected)
+		}
+		mentioned = []string{}
+	}
+
+	post, err := c.CreatePost(ctx, "c@example.com", "@alice @bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("CreatePost", "a@example.com @alice @bob", "b@example.com @alice @bob")
+	if _, err := c.UpdatePost(ctx, post.ID, "c@example.com", "@bob and @c and @alice"); err != nil {
+		t.Fatal(err)
+	}
+	expect("an edit mentioning no one new")
+	if _, err := c.UpdatePost(ctx, post.ID, "c@example.com", "just @bob"); err != nil {
+		t.Fatal(err)
+	}
+	expect("an edit dropping a mention")
+	if _, err := c.UpdatePost(ctx, post.ID, "c@example.com", "@alice again"); err != nil {
+		t.Fatal(err)
+	}
+	expect("an edit adding one back", "a@example.com @alice again")
+	if _, err := c.ChangeEmail(ctx, "a@example.com", "new@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	expect("ChangeEmail")
+
+	draft, err := c.CreateDraft(ctx, "c@example.com", "draft for @bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect("CreateDraft")
+	if _, err := c.PublishDraft(ctx, draft.ID, "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	expect("PublishDraft", "b@example.com draft for @bob")
+
+	if _, err := c.SchedulePost(ctx, "c@example.com", "later @bob", clock.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	expect("SchedulePost")
+	clock.Advance(time.Hour)
+	if _, err := c.PublishDue(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expect("PublishDue", "b@example.com later @bob")
+
+	if _, err := c.SoftDeletePost(ctx, draft.ID, "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.RestorePost(ctx, draft.ID); err != nil {
+		t.Fatal(err)
+	}
+	expect("SoftDeletePost and RestorePost")
+}
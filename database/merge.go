@@ -0,0 +1,243 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// MergeConflictPolicy -
+// which of two merged users a profile field comes from when both have it set, see MergeUsers
+type MergeConflictPolicy string
+
+const (
+	// MergeKeepPrimary keeps the primary's fields, the duplicate only fills in the ones it left blank
+	MergeKeepPrimary MergeConflictPolicy = "keep-primary"
+	// MergeKeepNewest takes the fields of whichever user was updated last, the other fills in its blanks
+	MergeKeepNewest MergeConflictPolicy = "keep-newest"
+)
+
+// MergeOptions -
+// controls Client.MergeUsers
+type MergeOptions struct {
+	// Conflicts picks how profile fields set on both users are resolved, MergeKeepPrimary if empty
+	Conflicts MergeConflictPolicy
+}
+
+// MergeReport -
+// how many records MergeUsers moved from the duplicate to the primary, by kind. edges count once
+// whichever way they point, those between the two users are dropped rather than moved and
+// those collapsing into one the primary already had aren't counted
+type MergeReport struct {
+	Posts          int
+	Follows        int
+	FollowRequests int
+	Blocks         int
+	Mutes          int
+	FriendRequests int
+}
+
+// storedEmail -
+// the key email is stored under: as given if a user has it exactly, for records from before
+// emails were lowercased, otherwise its EmailKey
+func (db *Schema) storedEmail(email string) string {
+	if trimmed := strings.TrimSpace(email); trimmed != "" {
+		if _, ok := db.Users[trimmed]; ok {
+			return trimmed
+		}
+	}
+	return EmailKey(email)
+}
+
+// mergeFriendRequests -
+// give every friend request and friendship of from to into. where into has one with the same user
+// already an accepted one wins, the older of the two otherwise, and those between the two are dropped.
+// the number of requests into didn't have with that user before
+func (db *Schema) mergeFriendRequests(from, into string) int {
+	moved := 0
+	for key, request := range db.FriendRequests {
+		if request.From != from && request.To != from {
+			continue
+		}
+		delete(db.FriendRequests, key)
+		if request.From == from {
+			request.From = into
+		} else {
+			request.To = into
+		}
+		if request.From == request.To {
+			continue
+		}
+		if existing, ok := db.FriendRequests[friendKey(request.From, request.To)]; !ok {
+			moved++
+		} else if existing.Status == FriendRequestAccepted ||
+			(request.Status != FriendRequestAccepted && existing.CreatedAt.Before(request.CreatedAt)) {
+			// collapsed into the one into already had
+			continue
+		}
+		db.putFriendRequest(request)
+	}
+	return moved
+}
+
+// mergeProfile -
+// primary with its profile merged with duplicate's under policy, see MergeUsers
+func mergeProfile(primary, duplicate User, policy MergeConflictPolicy) User {
+	merged := primary
+	// winner is the user whose fields win conflicts, the other fills in blanks
+	winner, other := primary, duplicate
+	if policy == MergeKeepNewest && duplicate.UpdatedAt.After(primary.UpdatedAt) {
+		winner, other = duplicate, primary
+	}
+	for _, field := range []struct {
+		to               *string
+		winner, fallback string
+	}{
+		{&merged.Name, winner.Name, other.Name},
+		{&merged.Username, winner.Username, other.Username},
+		{&merged.Bio, winner.Bio, other.Bio},
+		{&merged.AvatarURL, winner.AvatarURL, other.AvatarURL},
+		{&merged.Location, winner.Location, other.Location},
+		{&merged.Website, winner.Website, other.Website},
+	} {
+		*field.to = field.winner
+		if *field.to == "" {
+			*field.to = field.fallback
+		}
+	}
+	merged.Age = winner.Age
+	if merged.Age == 0 {
+		merged.Age = other.Age
+	}
+
+	// settings are merged key by key the same way, the winner's value for a key both set
+	values, _ := other.Settings.values()
+	winning, _ := winner.Settings.values()
+	for key, value := range winning {
+		values[key] = value
+	}
+	if settings, err := encodeSettings(values); err == nil && len(settings) <= MaxSettingsSize {
+		merged.Settings = settings
+	} else {
+		merged.Settings = winner.Settings
+	}
+
+	// the account is as old as the older of the two and has both's logins
+	if duplicate.CreatedAt.Before(merged.CreatedAt) {
+		merged.CreatedAt = duplicate.CreatedAt
+	}
+	if duplicate.LastLoginAt.After(merged.LastLoginAt) {
+		merged.LastLoginAt = duplicate.LastLoginAt
+	}
+	merged.LoginCount += duplicate.LoginCount
+	return merged
+}
+
+// mergeUser -
+// fold the user stored under duplicate into the one under primary, both of which must exist,
+// and delete duplicate. new kinds of records referencing users need moving here too, like moveUser
+func (tx *Tx) mergeUser(ctx context.Context, primary, duplicate string, policy MergeConflictPolicy) (MergeReport, error) {
+	db := tx.db
+	report := MergeReport{}
+	i := 0
+	for _, post := range db.Posts {
+		if i++; i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return MergeReport{}, err
+			}
+		}
+		if post.UserEmail == duplicate {
+			post.UserEmail = primary
+			db.putPost(post)
+			report.Posts++
+		}
+	}
+	report.Follows = db.Follows.mergeInto(duplicate, primary)
+	db.indexFollowers()
+	report.FollowRequests = db.FollowRequests.mergeInto(duplicate, primary)
+	// a request for what's now a follow is moot
+	for requester, to := range db.FollowRequests {
+		for email := range to {
+			if db.Follows.has(requester, email) {
+				db.FollowRequests.delete(requester, email)
+			}
+		}
+	}
+	report.Blocks = db.Blocks.mergeInto(duplicate, primary)
+	report.Mutes = db.Mutes.mergeInto(duplicate, primary)
+	report.FriendRequests = db.mergeFriendRequests(duplicate, primary)
+	// a block the duplicate made or got now ends what the primary had with that user, like BlockUser
+	for other := range db.Blocks[primary] {
+		db.unrelate(primary, other)
+	}
+	for blocker, blocked := range db.Blocks {
+		if _, ok := blocked[primary]; ok {
+			db.unrelate(blocker, primary)
+		}
+	}
+	db.moveMentions(duplicate, primary)
+	db.Reactions.move(duplicate, primary)
+	db.PollVotes.move(duplicate, primary)
+
+	old := db.Users[primary]
+	merged := mergeProfile(old, db.Users[duplicate], policy)
+	merged.UpdatedAt = tx.now()
+	// the duplicate's names are the primary's past too
+	tx.mergeNameHistory(duplicate, primary)
+	// the duplicate's tokens go with it, they were for its email. deleted first so
+	// its username is free for merged
+	db.deleteUser(duplicate)
+	db.putUser(merged)
+	tx.recordNameChanges(old, merged)
+	return report, nil
+}
+
+// MergeUsers -
+// same as Client.MergeUsers, inside the Tx
+func (tx *Tx) MergeUsers(ctx context.Context, primaryEmail, duplicateEmail string, opts MergeOptions) (MergeReport, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return MergeReport{}, err
+	}
+	if opts.Conflicts == "" {
+		opts.Conflicts = MergeKeepPrimary
+	}
+	if opts.Conflicts != MergeKeepPrimary && opts.Conflicts != MergeKeepNewest {
+		return MergeReport{}, fmt.Errorf("%w: unknown conflict policy %q", ErrInvalidMerge, opts.Conflicts)
+	}
+	primary, duplicate := db.storedEmail(primaryEmail), db.storedEmail(duplicateEmail)
+	if primary == duplicate {
+		return MergeReport{}, fmt.Errorf("%w: %s into itself", ErrInvalidMerge, primary)
+	}
+	if _, ok := db.activeUser(primary); !ok {
+		return MergeReport{}, fmt.Errorf("%w: %s", ErrUserNotFound, primary)
+	}
+	if _, ok := db.Users[duplicate]; !ok {
+		return MergeReport{}, fmt.Errorf("%w: %s", ErrUserNotFound, duplicate)
+	}
+	return tx.mergeUser(ctx, primary, duplicate, opts.Conflicts)
+}
+
+// MergeUsers -
+// combine two accounts of the same person into primaryEmail, in one write: the duplicate's posts,
+// follows both ways, follow requests, blocks, mutes, friend requests and name history move to the primary,
+// its profile fields are merged as opts.Conflicts says (blanks are always filled from the other)
+// and the duplicate is deleted. edges between the two are dropped, and where both had one
+// with the same user they become one. a block that moves ends the primary's follows, follow
+// requests and friendship with that user, like BlockUser. the account keeps the older CreatedAt and both's logins.
+// emails are looked up as stored first so accounts differing only by case from before emails were
+// lowercased can be named exactly. the duplicate may be soft-deleted, the primary can't.
+// ErrInvalidMerge for a user merged into itself or an unknown policy,
+// ErrUserNotFound if either doesn't exist
+func (c *Client) MergeUsers(ctx context.Context, primaryEmail, duplicateEmail string, opts MergeOptions) (MergeReport, error) {
+	report := MergeReport{}
+	err := c.update(ctx, "MergeUsers", primaryEmail, func(db *Schema) error {
+		var err error
+		report, err = c.newTx(db).MergeUsers(ctx, primaryEmail, duplicateEmail, opts)
+		return err
+	})
+	if err != nil {
+		return MergeReport{}, err
+	}
+	return report, nil
+}
@@ -0,0 +1,282 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newMergeClient has p@ and its duplicate d@, made later and updated last, with d@ holding
+// a bit of everything: posts, follows both ways and with p@, a follow request to the private y@,
+// a block, a mute and a friend request. x@ also follows p@, so that follow becomes one
+func newMergeClient(t *testing.T, opts ...Option) *Client {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newTestClient(t, append([]Option{WithClock(clock)}, opts...)...)
+	for _, email := range []string{"p@example.com", "d@example.com", "x@example.com", "y@example.com", "z@example.com"} {
+		clock.Advance(time.Minute)
+		if _, err := c.CreateUser(ctx, email, "123456", "name "+email[:1], 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, post := range [][2]string{{"p@example.com", "primary"}, {"d@example.com", "one"}, {"d@example.com", "two"}} {
+		if _, err := c.CreatePost(ctx, post[0], post[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.SetPrivate(ctx, "y@example.com", true); err != nil {
+		t.Fatal(err)
+	}
+	steps := []error{
+		c.FollowUser(ctx, "d@example.com", "x@example.com"),
+		c.FollowUser(ctx, "x@example.com", "d@example.com"),
+		c.FollowUser(ctx, "x@example.com", "p@example.com"),
+		c.FollowUser(ctx, "d@example.com", "p@example.com"),
+		c.FollowUser(ctx, "d@example.com", "y@example.com"),
+		c.BlockUser(ctx, "d@example.com", "z@example.com"),
+		c.MuteUser(ctx, "d@example.com", "x@example.com"),
+	}
+	_, err := c.SendFriendRequest(ctx, "d@example.com", "x@example.com")
+	steps = append(steps, err)
+	bio, otherBio, location := "primary bio", "duplicate bio", "Paris"
+	_, err = c.UpdateProfile(ctx, "p@example.com", Profile{Bio: &bio})
+	steps = append(steps, err)
+	_, err = c.SetUserSetting(ctx, "p@example.com", "ui.theme", "light")
+	steps = append(steps, err)
+	clock.Advance(time.Minute)
+	_, err = c.UpdateProfile(ctx, "d@example.com", Profile{Bio: &otherBio, Location: &location})
+	steps = append(steps, err)
+	_, err = c.SetUsername(ctx, "d@example.com", "dupe")
+	steps = append(steps, err)
+	_, err = c.SetUserSetting(ctx, "d@example.com", "ui.theme", "dark")
+	steps = append(steps, err)
+	_, err = c.SetUserSetting(ctx, "d@example.com", "ui.darkMode", true)
+	steps = append(steps, err)
+	for _, err := range steps {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	clock.Advance(time.Minute)
+	return c
+}
+
+// checkNoReferences fails if anything in the dump of c still has email in it
+func checkNoReferences(t *testing.T, c *Client, email string) {
+	t.Helper()
+	db, err := c.Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), email) {
+		t.Errorf("db after MergeUsers() = %s, expected no %s", data, email)
+	}
+}
+
+func TestMergeUsers(t *testing.T) {
+	for name, opts := range map[string][]Option{"file": nil, "wal": {WithWAL(100)}} {
+		c := newMergeClient(t, opts...)
+		report, err := c.MergeUsers(ctx, "P@example.com", " d@example.com", MergeOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// d@->p@ is dropped, x@->d@ joins x@->p@ so only d@->x@ counts
+		expected := MergeReport{Posts: 2, Follows: 1, FollowRequests: 1, Blocks: 1, Mutes: 1, FriendRequests: 1}
+		if report != expected {
+			t.Errorf("%s: MergeUsers() = %+v, expected %+v", name, report, expected)
+		}
+
+		// and it all holds once read back
+		reopened := NewClient(dbPath(c), opts...)
+		for _, client := range []*Client{c, reopened} {
+			checkNoReferences(t, client, "d@example.com")
+			if _, err := client.GetUser(ctx, "d@example.com"); !errors.Is(err, ErrUserNotFound) {
+				t.Errorf("%s: GetUser() of the duplicate = %v, expected ErrUserNotFound", name, err)
+			}
+			if posts, err := client.GetPosts(ctx, "p@example.com"); err != nil || len(posts) != 3 {
+				t.Errorf("%s: GetPosts() of the primary = %d posts, %v, expected 3", name, len(posts), err)
+			}
+			checkFollowGraph(t, client, map[string][]string{
+				"p@example.com": {"x@example.com"},
+				"x@example.com": {"p@example.com"},
+			})
+			if requests, err := client.GetFollowRequests(ctx, "y@example.com"); err != nil || !reflect.DeepEqual(emails(requests), []string{"p@example.com"}) {
+				t.Errorf("%s: GetFollowRequests() = %v, %v, expected p@", name, emails(requests), err)
+			}
+			if blocked, _ := client.IsBlocked(ctx, "p@example.com", "z@example.com"); !blocked {
+				t.Errorf("%s: IsBlocked() of the duplicate's block = false, expected true", name)
+			}
+			if muted, _ := client.IsMuted(ctx, "p@example.com", "x@example.com"); !muted {
+				t.Errorf("%s: IsMuted() of the duplicate's mute = false, expected true", name)
+			}
+			requests, err := client.GetPendingRequests(ctx, "x@example.com")
+			if err != nil || len(requests) != 1 || requests[0].From != "p@example.com" {
+				t.Errorf("%s: GetPendingRequests() = %+v, %v, expected one from p@", name, requests, err)
+			}
+			if user, err := client.GetUserByUsername(ctx, "dupe"); err != nil || user.Email != "p@example.com" {
+				t.Errorf("%s: GetUserByUsername() of the duplicate's username = %s, %v, expected p@", name, user.Email, err)
+			}
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the duplicate's blocks end what the primary had with those users, as BlockUser would
+	c := newMergeClient(t)
+	for _, email := range []string{"blocked@example.com", "blocker@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+		steps := []error{c.FollowUser(ctx, email, "p@example.com"), c.FollowUser(ctx, "p@example.com", email)}
+		_, err := c.SendFriendRequest(ctx, email, "p@example.com")
+		for _, err := range append(steps, err) {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := c.BlockUser(ctx, "d@example.com", "blocked@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.BlockUser(ctx, "blocker@example.com", "d@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.MergeUsers(ctx, "p@example.com", "d@example.com", MergeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if blocked, _ := c.IsBlocked(ctx, "p@example.com", "blocked@example.com"); !blocked {
+		t.Error("IsBlocked() of the duplicate's block = false, expected true")
+	}
+	if blocked, _ := c.IsBlocked(ctx, "blocker@example.com", "p@example.com"); !blocked {
+		t.Error("IsBlocked() of the block against the duplicate = false, expected true")
+	}
+	checkFollowGraph(t, c, map[string][]string{
+		"p@example.com": {"x@example.com"},
+		"x@example.com": {"p@example.com"},
+	})
+	if requests, err := c.GetPendingRequests(ctx, "p@example.com"); err != nil || len(requests) != 1 || requests[0].To != "x@example.com" {
+		t.Errorf("GetPendingRequests() after the merged blocks = %+v, %v, expected only the one to x@", requests, err)
+	}
+
+	// a friend request to someone the primary already asked collapses into it and isn't counted
+	c = newMergeClient(t)
+	if _, err := c.SendFriendRequest(ctx, "p@example.com", "x@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if report, err := c.MergeUsers(ctx, "p@example.com", "d@example.com", MergeOptions{}); err != nil || report.FriendRequests != 0 || report.Follows != 1 {
+		t.Errorf("MergeUsers() with overlapping edges = %+v, %v, expected no friend requests and 1 follow counted", report, err)
+	}
+}
+
+func TestMergeUsersProfile(t *testing.T) {
+	var tests = []struct {
+		conflicts MergeConflictPolicy
+		name      string
+		bio       string
+		theme     string
+	}{
+		{conflicts: "", name: "name p", bio: "primary bio", theme: "light"},
+		{conflicts: MergeKeepPrimary, name: "name p", bio: "primary bio", theme: "light"},
+		// d@ was updated last
+		{conflicts: MergeKeepNewest, name: "name d", bio: "duplicate bio", theme: "dark"},
+	}
+	for _, test := range tests {
+		c := newMergeClient(t)
+		if _, err := c.AuthenticateUser(ctx, "d@example.com", "123456"); err != nil {
+			t.Fatal(err)
+		}
+		before, err := c.GetUser(ctx, "p@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.MergeUsers(ctx, "p@example.com", "d@example.com", MergeOptions{Conflicts: test.conflicts}); err != nil {
+			t.Fatal(err)
+		}
+		user, err := c.GetUser(ctx, "p@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		theme := ""
+		if _, err := user.Settings.Get("ui.theme", &theme); err != nil {
+			t.Fatal(err)
+		}
+		darkMode := false
+		if _, err := user.Settings.Get("ui.darkMode", &darkMode); err != nil {
+			t.Fatal(err)
+		}
+		if user.Name != test.name || user.Bio != test.bio || theme != test.theme {
+			t.Errorf("%q: merged name, bio and theme = %q, %q, %q, expected %q, %q, %q", test.conflicts, user.Name, user.Bio, theme, test.name, test.bio, test.theme)
+		}
+		// blanks are filled either way
+		if user.Location != "Paris" || user.Username != "dupe" || !darkMode {
+			t.Errorf("%q: merged location, username and dark mode = %q, %q, %v, expected the duplicate's", test.conflicts, user.Location, user.Username, darkMode)
+		}
+		// the account is the primary's whichever fields won
+		if !user.CreatedAt.Equal(before.CreatedAt) || user.LoginCount != 1 || user.Email != "p@example.com" {
+			t.Errorf("%q: merged CreatedAt, LoginCount and email = %v, %d, %s, expected the primary's with the duplicate's login", test.conflicts, user.CreatedAt, user.LoginCount, user.Email)
+		}
+	}
+}
+
+func TestMergeUsersErrors(t *testing.T) {
+	c := newMergeClient(t)
+	if _, err := c.SoftDeleteUser(ctx, "z@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		primary   string
+		duplicate string
+		opts      MergeOptions
+		expected  error
+	}{
+		{primary: "p@example.com", duplicate: "p@example.com", expected: ErrInvalidMerge},
+		{primary: "p@example.com", duplicate: " P@Example.com", expected: ErrInvalidMerge},
+		{primary: "missing@example.com", duplicate: "d@example.com", expected: ErrUserNotFound},
+		{primary: "z@example.com", duplicate: "d@example.com", expected: ErrUserNotFound},
+		{primary: "p@example.com", duplicate: "missing@example.com", expected: ErrUserNotFound},
+		{primary: "p@example.com", duplicate: "d@example.com", opts: MergeOptions{Conflicts: "keep-oldest"}, expected: ErrInvalidMerge},
+	}
+	before, err := c.Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		if _, err := c.MergeUsers(ctx, test.primary, test.duplicate, test.opts); !errors.Is(err, test.expected) {
+			t.Errorf("MergeUsers(%q, %q) = %v, expected %v", test.primary, test.duplicate, err, test.expected)
+		}
+	}
+	if after, _ := c.Dump(ctx); !reflect.DeepEqual(after, before) {
+		t.Error("db after failed MergeUsers() calls changed, expected it left as it was")
+	}
+
+	// a soft-deleted duplicate can be merged away
+	if report, err := c.MergeUsers(ctx, "p@example.com", "z@example.com", MergeOptions{}); err != nil || report.Blocks != 1 {
+		t.Errorf("MergeUsers() of a soft-deleted duplicate = %+v, %v, expected its block moved", report, err)
+	}
+	checkNoReferences(t, c, "z@example.com")
+}
+
+func TestMergeUsersLegacyCase(t *testing.T) {
+	c := newTestClient(t)
+	db := Schema{Users: map[string]User{}}
+	for _, email := range []string{"bob@example.com", "Bob@example.com"} {
+		db.Users[email] = User{Email: email, Name: "bob", Age: 18, CreatedAt: time.Now().UTC()}
+	}
+	db.Posts = map[string]Post{"1": {ID: "1", UserEmail: "Bob@example.com", Text: "hello"}}
+	if err := c.Load(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	report, err := c.MergeUsers(ctx, "bob@example.com", "Bob@example.com", MergeOptions{})
+	if err != nil || report.Posts != 1 {
+		t.Fatalf("MergeUsers() of emails differing by case = %+v, %v, expected the post moved", report, err)
+	}
+	checkNoReferences(t, c, "Bob@example.com")
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// how many recent durations per operation the percentiles are computed from
+const latencySamples = 1024
+
+// MetricsSink -
+// receives every operation as it finishes, see WithMetricsSink
+// called from the goroutine that made the call, after the client's locks are released
+type MetricsSink interface {
+	Observe(op string, write bool, d time.Duration, err error)
+}
+
+// Metrics -
+// snapshot of the client's counters since it was created, see Client.Metrics
+type Metrics struct {
+	// Reads and Writes count calls by kind, Errors the ones of either kind that failed
+	Reads  int64
+	Writes int64
+	Errors int64
+	// Ops is keyed by method name, e.g. "CreatePost"
+	Ops map[string]OpMetrics
+	// FileSize is the size in bytes of the db file and its write-ahead log, 0 without a file
+	FileSize int64
+	// DataSize is the size of the db after the last write as WithMaxSizeBytes measures it
+	// and MaxSize that limit, both 0 without one
+	DataSize int64
+	MaxSize  int64
+	// Users and Posts are the record counts in memory, 0 until the db is loaded
+	Users int
+	Posts int
+}
+
+// OpMetrics -
+// counters for one operation, the percentiles cover the last 1024 calls
+type OpMetrics struct {
+	Calls  int64
+	Errors int64
+	Total  time.Duration
+	P50    time.Duration
+	P95    time.Duration
+}
+
+// metrics -
+// what Metrics reports, collected by update and view
+type metrics struct {
+	mu   sync.Mutex
+	ops  map[string]*opMetrics
+	sink MetricsSink
+
+	reads, writes, errors int64
+}
+
+// opMetrics -
+// counters for one operation plus a ring of its most recent durations
+type opMetrics struct {
+	calls, errors int64
+	total         time.Duration
+	samples       []time.Duration
+	next          int
+}
+
+// observe -
+// count one finished call
+func (m *metrics) observe(op string, write bool, d time.Duration, err error) {
+	m.mu.Lock()
+	if m.ops == nil {
+		m.ops = map[string]*opMetrics{}
+	}
+	o, ok := m.ops[op]
+	if !ok {
+		o = &opMetrics{}
+		m.ops[op] = o
+	}
+	o.calls++
+	o.total += d
+	if len(o.samples) < latencySamples {
+		o.samples = append(o.samples, d)
+	} else {
+		o.samples[o.next] = d
+		o.next = (o.next + 1) % latencySamples
+	}
+	if write {
+		m.writes++
+	} else {
+		m.reads++
+	}
+	if err != nil {
+		o.errors++
+		m.errors++
+	}
+	m.mu.Unlock()
+
+	if m.sink != nil {
+		m.sink.Observe(op, write, d, err)
+	}
+}
+
+// Metrics -
+// counts, latencies and sizes of the client so far, cheap enough to poll
+func (c *Client) Metrics() Metrics {
+	c.metrics.mu.Lock()
+	snapshot := Metrics{
+		Reads:  c.metrics.reads,
+		Writes: c.metrics.writes,
+		Errors: c.metrics.errors,
+		Ops:    make(map[string]OpMetrics, len(c.metrics.ops)),
+	}
+	for op, o := range c.metrics.ops {
+		sorted := append([]time.Duration{}, o.samples...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		snapshot.Ops[op] = OpMetrics{
+			Calls:  o.calls,
+			Errors: o.errors,
+			Total:  o.total,
+			P50:    percentile(sorted, 50),
+			P95:    percentile(sorted, 95),
+		}
+	}
+	c.metrics.mu.Unlock()
+
+	c.mu.RLock()
+	if c.mem != nil {
+		snapshot.Users = len(c.mem.Users)
+		snapshot.Posts = len(c.mem.Posts)
+	}
+	c.mu.RUnlock()
+
+	snapshot.DataSize = c.quota.currentSize()
+	snapshot.MaxSize = c.quota.max
+
+	snapshot.FileSize = c.fileSize()
+	return snapshot
+}
+
+// percentile returns the p-th percentile of sorted by the nearest-rank method
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink keeps every observation it's given
+type recordingSink struct {
+	mu  sync.Mutex
+	ops []string
+}
+
+func (s *recordingSink) Observe(op string, write bool, d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ops = append(s.ops, op)
+}
+
+func TestMetrics(t *testing.T) {
+	sink := &recordingSink{}
+	c := NewClient(t.TempDir()+"/db.json", WithMetricsSink(sink))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, email := range []string{"a@example.com", "b@example.com", "a@example.com"} {
+		c.CreateUser(ctx, email, "123456", "john doe", 18)
+	}
+	if _, err := c.CreatePost(ctx, "a@example.com", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetUser(ctx, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetUser(ctx, "missing@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatal(err)
+	}
+	if _, err := c.GetPosts(ctx, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	m := c.Metrics()
+	if m.Writes != 4 || m.Reads != 3 || m.Errors != 2 {
+		t.Errorf("Metrics() = %d writes, %d reads, %d errors, expected 4, 3 and 2", m.Writes, m.Reads, m.Errors)
+	}
+	expected := map[string][2]int64{ // calls, errors
+		"CreateUser": {3, 1},
+		"CreatePost": {1, 0},
+		"GetUser":    {2, 1},
+		"GetPosts":   {1, 0},
+	}
+	if len(m.Ops) != len(expected) {
+		t.Errorf("Metrics().Ops = %v, expected %d operations", m.Ops, len(expected))
+	}
+	for op, counts := range expected {
+		got := m.Ops[op]
+		if got.Calls != counts[0] || got.Errors != counts[1] {
+			t.Errorf("%s: %d calls and %d errors, expected %d and %d", op, got.Calls, got.Errors, counts[0], counts[1])
+		}
+		if got.Total <= 0 || got.P50 <= 0 || got.P50 > got.P95 || got.P95 > got.Total {
+			t.Errorf("%s: inconsistent latencies %+v", op, got)
+		}
+	}
+	if m.Users != 2 || m.Posts != 1 {
+		t.Errorf("Metrics() = %d users and %d posts, expected 2 and 1", m.Users, m.Posts)
+	}
+	info, err := os.Stat(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.FileSize != info.Size() {
+		t.Errorf("Metrics().FileSize = %d, expected %d", m.FileSize, info.Size())
+	}
+	if len(sink.ops) != 7 {
+		t.Errorf("sink got %q, expected all 7 operations", sink.ops)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{}
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i))
+	}
+	var tests = []struct {
+		samples  []time.Duration
+		p        int
+		expected time.Duration
+	}{
+		{samples: nil, p: 50, expected: 0},
+		{samples: sorted[:1], p: 95, expected: 1},
+		{samples: sorted, p: 50, expected: 50},
+		{samples: sorted, p: 95, expected: 95},
+		{samples: sorted[:10], p: 95, expected: 10},
+	}
+	for _, test := range tests {
+		if got := percentile(test.samples, test.p); got != test.expected {
+			t.Errorf("percentile(%d samples, %d) = %v, expected %v", len(test.samples), test.p, got, test.expected)
+		}
+	}
+}
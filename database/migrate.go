@@ -0,0 +1,139 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// currentSchemaVersion is the version of the db format written by this package
+const currentSchemaVersion = 1
+
+// migration -
+// upgrades a raw db from one schema version to the next by editing the top level keys in place
+type migration func(raw map[string]json.RawMessage) error
+
+// migrations[i] upgrades a db from version i to version i+1, run in order
+var migrations = []migration{
+	migrateV0ToV1,
+}
+
+// migrateV0ToV1 -
+// version 0 files predate schemaVersion, make sure both maps exist so later code can rely on them
+func migrateV0ToV1(raw map[string]json.RawMessage) error {
+	for _, key := range []string{"users", "posts"} {
+		if v, ok := raw[key]; !ok || string(v) == "null" {
+			raw[key] = json.RawMessage("{}")
+		}
+	}
+	return nil
+}
+
+// parseDB -
+// parse json into a Schema, running any migrations needed to get it
+// to currentSchemaVersion, also returns the version that was on disk
+// the checksum envelope is verified and unwrapped, files from before checksums are read as is
+func parseDB(data []byte) (Schema, int, error) {
+	// a damaged db still starts like json, anything else is some other file
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return Schema{}, 0, fmt.Errorf("%w: content isn't a json object", ErrNotDatabase)
+	}
+	// json.Unmarshal would quietly replace invalid bytes, that's damage worth reporting
+	if !utf8.Valid(data) {
+		return Schema{}, 0, fmt.Errorf("%w: invalid UTF-8", ErrDBCorrupt)
+	}
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Schema{}, 0, fmt.Errorf("%w: %v", ErrDBCorrupt, err)
+	}
+	if checksum, ok := raw["checksum"]; ok {
+		data, err := unwrapChecksum(checksum, raw["data"])
+		if err != nil {
+			return Schema{}, 0, err
+		}
+		return parseDB(data)
+	}
+	if !hasDBKeys(raw) {
+		return Schema{}, 0, fmt.Errorf("%w: json object has none of the schemaVersion, users and posts keys", ErrNotDatabase)
+	}
+	version := 0
+	if v, ok := raw["schemaVersion"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return Schema{}, 0, fmt.Errorf("%w: bad schemaVersion: %v", ErrDBCorrupt, err)
+		}
+	}
+	if version > currentSchemaVersion {
+		return Schema{}, version, fmt.Errorf("%w: file is version %d, newest known is %d",
+			ErrUnsupportedVersion, version, currentSchemaVersion)
+	}
+
+	// already current, no need to go through the raw form again
+	if version == currentSchemaVersion {
+		db := Schema{}
+		if err := json.Unmarshal(data, &db); err != nil {
+			return Schema{}, version, fmt.Errorf("%w: %v", ErrDBCorrupt, err)
+		}
+		// a hand-written file may leave out a map, writes need both
+		if db.Users == nil {
+			db.Users = make(map[string]User)
+		}
+		if db.Posts == nil {
+			db.Posts = make(map[string]Post)
+		}
+		db.fillUpdatedAt()
+		db.indexUsernames()
+		db.indexFollowers()
+		db.fillPosts()
+		db.indexPosts()
+		return db, version, nil
+	}
+
+	for v := version; v < currentSchemaVersion; v++ {
+		if err := migrations[v](raw); err != nil {
+			return Schema{}, version, fmt.Errorf("migrating schema version %d to %d: %w", v, v+1, err)
+		}
+		raw["schemaVersion"] = json.RawMessage(fmt.Sprint(v + 1))
+	}
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return Schema{}, version, err
+	}
+	db := Schema{}
+	if err := json.Unmarshal(migrated, &db); err != nil {
+		return Schema{}, version, fmt.Errorf("%w: %v", ErrDBCorrupt, err)
+	}
+	db.fillUpdatedAt()
+	db.indexUsernames()
+	db.indexFollowers()
+	db.fillPosts()
+	db.indexPosts()
+	return db, version, nil
+}
+
+// fillUpdatedAt -
+// users written before UpdatedAt existed get their CreatedAt, the file keeps the zero until the next write
+func (db *Schema) fillUpdatedAt() {
+	for email, user := range db.Users {
+		if user.UpdatedAt.IsZero() {
+			user.UpdatedAt = user.CreatedAt
+			db.Users[email] = user
+		}
+	}
+}
+
+// hasDBKeys -
+// true if raw is empty or has at least one top level key a db file has
+// some other program's json config shouldn't be mistaken for an empty db
+func hasDBKeys(raw map[string]json.RawMessage) bool {
+	if len(raw) == 0 {
+		return true
+	}
+	for _, key := range []string{"schemaVersion", "users", "posts"} {
+		if _, ok := raw[key]; ok {
+			return true
+		}
+	}
+	return false
+}
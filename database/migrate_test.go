@@ -0,0 +1,91 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeRawDB puts contents at a fresh db path and returns a client for it
+func writeRawDB(t *testing.T, contents string) *Client {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "db.json")
+	if err := os.WriteFile(path, []byte(contents), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return NewClient(path)
+}
+
+func TestMigrateV0(t *testing.T) {
+	// version 0: no schemaVersion and no posts map yet
+	c := writeRawDB(t, `{"users":{"test@example.com":{"email":"test@example.com","name":"john doe","age":18}}}`)
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatalf("EnsureDB() = %v, expected nil", err)
+	}
+
+	data := readPayload(t, dbPath(c))
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if string(raw["schemaVersion"]) != "1" {
+		t.Errorf("schemaVersion = %s, expected 1", raw["schemaVersion"])
+	}
+	if string(raw["posts"]) != "{}" {
+		t.Errorf("posts = %s, expected {}", raw["posts"])
+	}
+
+	// data survived and the new map is usable
+	if _, err := c.GetUser(ctx, "test@example.com"); err != nil {
+		t.Errorf("GetUser() after migration = %v, expected nil", err)
+	}
+	if _, err := c.CreatePost(ctx, "test@example.com", "hello"); err != nil {
+		t.Errorf("CreatePost() after migration = %v, expected nil", err)
+	}
+}
+
+func TestMigrateFutureVersion(t *testing.T) {
+	const contents = `{"schemaVersion":99,"users":{},"posts":{}}`
+	c := writeRawDB(t, contents)
+	if err := c.EnsureDB(ctx); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("EnsureDB() = %v, expected %v", err, ErrUnsupportedVersion)
+	}
+	if _, err := c.GetUser(ctx, "test@example.com"); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("GetUser() = %v, expected %v", err, ErrUnsupportedVersion)
+	}
+	assertFileContents(t, dbPath(c), contents)
+}
+
+func TestMigrateFailureLeavesFileUntouched(t *testing.T) {
+	const contents = `{"users":{},"posts":{}}`
+	c := writeRawDB(t, contents)
+
+	orig := migrations
+	migrations = []migration{
+		func(raw map[string]json.RawMessage) error {
+			raw["users"] = json.RawMessage("null")
+			return errors.New("migration blew up")
+		},
+	}
+	defer func() { migrations = orig }()
+
+	if err := c.EnsureDB(ctx); err == nil {
+		t.Fatal("EnsureDB() = nil, expected migration error")
+	}
+	assertFileContents(t, dbPath(c), contents)
+}
+
+// assertFileContents fails the test if the file at path doesn't hold exactly expected
+func assertFileContents(t *testing.T, path, expected string) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, []byte(expected)) {
+		t.Errorf("file contents = %s, expected %s", got, expected)
+	}
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// MuteUser -
+// same as Client.MuteUser, inside the Tx
+func (tx *Tx) MuteUser(ctx context.Context, muter, email string) error {
+	_, err := tx.muteUser(muter, email)
+	return err
+}
+
+// muteUser -
+// MuteUser that also says whether anything changed
+func (tx *Tx) muteUser(muter, email string) (bool, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return false, err
+	}
+	muter, email = EmailKey(muter), EmailKey(email)
+	if muter == email {
+		return false, fmt.Errorf("%w: %s", ErrSelfMute, muter)
+	}
+	for _, e := range []string{muter, email} {
+		if _, ok := db.activeUser(e); !ok {
+			return false, fmt.Errorf("%w: %s", ErrUserNotFound, e)
+		}
+	}
+	if db.Mutes.has(muter, email) {
+		return false, nil
+	}
+	db.Mutes.put(muter, email, tx.now())
+	return true, nil
+}
+
+// UnmuteUser -
+// same as Client.UnmuteUser, inside the Tx
+func (tx *Tx) UnmuteUser(ctx context.Context, muter, email string) error {
+	_, err := tx.unmuteUser(muter, email)
+	return err
+}
+
+// unmuteUser -
+// UnmuteUser that also says whether anything changed
+func (tx *Tx) unmuteUser(muter, email string) (bool, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return false, err
+	}
+	muter, email = EmailKey(muter), EmailKey(email)
+	if _, ok := db.activeUser(muter); !ok {
+		return false, fmt.Errorf("%w: %s", ErrUserNotFound, muter)
+	}
+	if !db.Mutes.has(muter, email) {
+		return false, nil
+	}
+	db.Mutes.delete(muter, email)
+	return true, nil
+}
+
+// MuteUser -
+// have muter stop seeing the posts of the user with email in its feed, IteratePosts across every
+// user with muter as the viewer, without unfollowing or telling them. unlike BlockUser it's one way
+// and only about what muter is shown: the muted user still sees muter's posts and can follow it,
+// and muter can still open the muted user's posts with GetPostsAs. muting someone muter blocked is
+// allowed and kept, so the mute still holds after UnblockUser. muting twice is a no-op.
+// ErrSelfMute if they're the same user, ErrUserNotFound if either doesn't exist
+func (c *Client) MuteUser(ctx context.Context, muter, email string) error {
+	return c.update(ctx, "MuteUser", muter, func(db *Schema) error {
+		changed, err := c.newTx(db).muteUser(muter, email)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+}
+
+// UnmuteUser -
+// undo MuteUser, a no-op if muter hadn't muted the user with email.
+// ErrUserNotFound if there's no muter
+func (c *Client) UnmuteUser(ctx context.Context, muter, email string) error {
+	return c.deleteUpdate(ctx, "UnmuteUser", muter, func(db *Schema) error {
+		changed, err := c.newTx(db).unmuteUser(muter, email)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+}
+
+// IsMuted -
+// whether muter muted the user with email
+func (c *Client) IsMuted(ctx context.Context, muter, email string) (bool, error) {
+	muted := false
+	err := c.view(ctx, "IsMuted", muter, func(db *Schema) error {
+		muted = db.Mutes.has(EmailKey(muter), EmailKey(email))
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return muted, nil
+}
+
+// GetMutedUsers -
+// the users muter muted, oldest first like GetUsers. soft-deleted ones are left out
+// until they're restored. ErrUserNotFound if there's no muter
+func (c *Client) GetMutedUsers(ctx context.Context, muter string) ([]User, error) {
+	users := []User{}
+	err := c.view(ctx, "GetMutedUsers", muter, func(db *Schema) error {
+		muter := EmailKey(muter)
+		if _, ok := db.activeUser(muter); !ok {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, muter)
+		}
+		for email := range db.Mutes[muter] {
+			if user, ok := db.activeUser(email); ok {
+				users = append(users, c.sanitize(user))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []User{}, err
+	}
+	sortUsers(users)
+	return users, nil
+}
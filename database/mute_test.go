@@ -0,0 +1,185 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// feedAuthors is the authors of the posts in viewer's feed, sorted
+func feedAuthors(t *testing.T, c *Client, viewer string) []string {
+	t.Helper()
+	authors := []string{}
+	if err := c.IteratePosts(ctx, IterateOptions{Viewer: viewer}, func(post Post) bool {
+		authors = append(authors, post.UserEmail)
+		return true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(authors)
+	return authors
+}
+
+func TestMuteUser(t *testing.T) {
+	c := newBlockClient(t)
+	if err := c.BlockUser(ctx, "a@example.com", "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		muter    string
+		email    string
+		expected error
+	}{
+		{muter: "a@example.com", email: "b@example.com", expected: nil},
+		// again is a no-op
+		{muter: "A@Example.com", email: " b@example.com", expected: nil},
+		{muter: "a@example.com", email: "A@example.com", expected: ErrSelfMute},
+		{muter: "a@example.com", email: "missing@example.com", expected: ErrUserNotFound},
+		{muter: "missing@example.com", email: "a@example.com", expected: ErrUserNotFound},
+		// kept for after an unblock
+		{muter: "a@example.com", email: "c@example.com", expected: nil},
+	}
+	for _, test := range tests {
+		if err := c.MuteUser(ctx, test.muter, test.email); !errors.Is(err, test.expected) {
+			t.Errorf("MuteUser(%q, %q) = %v, expected %v", test.muter, test.email, err, test.expected)
+		}
+	}
+
+	users, err := c.GetMutedUsers(ctx, "a@example.com")
+	if err != nil || !reflect.DeepEqual(emails(users), []string{"b@example.com", "c@example.com"}) {
+		t.Errorf("GetMutedUsers() = %v, %v, expected b@ and c@", emails(users), err)
+	}
+	if users, err := c.GetMutedUsers(ctx, "b@example.com"); err != nil || len(users) != 0 {
+		t.Errorf("GetMutedUsers() of a user who muted nobody = %v, %v, expected none", emails(users), err)
+	}
+	if _, err := c.GetMutedUsers(ctx, "missing@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetMutedUsers() of a missing user = %v, expected ErrUserNotFound", err)
+	}
+	// only the one direction, and not a block
+	for _, check := range []struct {
+		muter, email string
+		expected     bool
+	}{
+		{"a@example.com", "b@example.com", true},
+		{"b@example.com", "a@example.com", false},
+	} {
+		if got, err := c.IsMuted(ctx, check.muter, check.email); err != nil || got != check.expected {
+			t.Errorf("IsMuted(%q, %q) = %v, %v, expected %v", check.muter, check.email, got, err, check.expected)
+		}
+	}
+	if blocked, _ := c.IsBlocked(ctx, "a@example.com", "b@example.com"); blocked {
+		t.Error("IsBlocked() of a muted user = true, expected false")
+	}
+
+	if err := c.UnblockUser(ctx, "a@example.com", "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if muted, _ := c.IsMuted(ctx, "a@example.com", "c@example.com"); !muted {
+		t.Error("IsMuted() after UnblockUser() = false, expected the mute kept")
+	}
+}
+
+func TestUnmuteUser(t *testing.T) {
+	c := newBlockClient(t)
+	if err := c.MuteUser(ctx, "a@example.com", "b@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UnmuteUser(ctx, "missing@example.com", "b@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("UnmuteUser() by a missing user = %v, expected ErrUserNotFound", err)
+	}
+	if err := c.UnmuteUser(ctx, "b@example.com", "a@example.com"); err != nil {
+		t.Errorf("UnmuteUser() of a user not muted = %v, expected nil", err)
+	}
+	if err := c.UnmuteUser(ctx, "A@example.com", "B@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if db, _ := c.Dump(ctx); len(db.Mutes) != 0 {
+		t.Errorf("mutes after UnmuteUser() = %v, expected none", db.Mutes)
+	}
+	if got := feedAuthors(t, c, "a@example.com"); len(got) != 3 {
+		t.Errorf("feed after UnmuteUser() = %v, expected every author", got)
+	}
+}
+
+func TestMuteFeedFiltering(t *testing.T) {
+	c := newBlockClient(t)
+	if err := c.FollowUser(ctx, "a@example.com", "b@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MuteUser(ctx, "a@example.com", "b@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		viewer   string
+		expected []string
+	}{
+		// b@ is out of a@'s feed though a@ still follows it
+		{viewer: "a@example.com", expected: []string{"a@example.com", "c@example.com"}},
+		// the muted user sees the muter as before
+		{viewer: "b@example.com", expected: []string{"a@example.com", "b@example.com", "c@example.com"}},
+		{viewer: "c@example.com", expected: []string{"a@example.com", "b@example.com", "c@example.com"}},
+		{viewer: "", expected: []string{"a@example.com", "b@example.com", "c@example.com"}},
+	}
+	for _, test := range tests {
+		if got := feedAuthors(t, c, test.viewer); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("feed of %q = %v, expected %v", test.viewer, got, test.expected)
+		}
+	}
+	if following, _ := c.GetFollowing(ctx, "a@example.com"); !reflect.DeepEqual(emails(following), []string{"b@example.com"}) {
+		t.Errorf("GetFollowing() after MuteUser() = %v, expected b@ still followed", emails(following))
+	}
+
+	// asking for b@'s posts by name still shows them
+	if posts, err := c.GetPostsAs(ctx, "a@example.com", "b@example.com"); err != nil || len(posts) != 1 {
+		t.Errorf("GetPostsAs() of a muted user = %d posts, %v, expected 1", len(posts), err)
+	}
+	n := 0
+	if err := c.IteratePosts(ctx, IterateOptions{UserEmail: "b@example.com", Viewer: "a@example.com"}, func(Post) bool { n++; return true }); err != nil || n != 1 {
+		t.Errorf("IteratePosts() of a muted user's posts = %d, %v, expected 1", n, err)
+	}
+	// and b@ can still follow a@
+	if err := c.FollowUser(ctx, "b@example.com", "a@example.com"); err != nil {
+		t.Errorf("FollowUser() of the muter = %v, expected nil", err)
+	}
+}
+
+func TestDeleteUserDropsMutes(t *testing.T) {
+	for name, opts := range map[string][]Option{"file": nil, "wal": {WithWAL(100)}} {
+		c := newBlockClient(t, opts...)
+		for _, mute := range [][2]string{{"a@example.com", "b@example.com"}, {"b@example.com", "c@example.com"}, {"c@example.com", "a@example.com"}} {
+			if err := c.MuteUser(ctx, mute[0], mute[1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := c.DeleteUser(ctx, "a@example.com", DeleteUserOptions{}); err != nil {
+			t.Fatal(err)
+		}
+
+		reopened := NewClient(dbPath(c), opts...)
+		db, err := reopened.Dump(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(db.Mutes) != 1 || len(db.Mutes["b@example.com"]) != 1 || !db.Mutes.has("b@example.com", "c@example.com") {
+			t.Errorf("%s: mutes after DeleteUser() = %v, expected only b@ muting c@", name, db.Mutes)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestChangeEmailMovesMutes(t *testing.T) {
+	c := newBlockClient(t)
+	if err := c.MuteUser(ctx, "a@example.com", "b@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ChangeEmail(ctx, "b@example.com", "new@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got := feedAuthors(t, c, "a@example.com"); !reflect.DeepEqual(got, []string{"a@example.com", "c@example.com"}) {
+		t.Errorf("feed after ChangeEmail() of the muted user = %v, expected it still muted", got)
+	}
+}
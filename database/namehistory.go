@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultNameHistoryLimit is how many name changes are kept per user without WithNameHistoryLimit
+const DefaultNameHistoryLimit = 20
+
+// the fields a NameChange can be about
+const (
+	NameFieldName     = "name"
+	NameFieldUsername = "username"
+)
+
+// NameChange -
+// one change of a user's Name or Username, recorded by the write that made it so moderators
+// can see what a user used to call themselves. there's no way to write one directly
+type NameChange struct {
+	// Field is NameFieldName or NameFieldUsername
+	Field     string    `json:"field"`
+	Old       string    `json:"old"`
+	New       string    `json:"new"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// equalSlices reports whether a and b hold the same values in the same order, like two histories
+func equalSlices[T comparable](a, b []T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// putNameHistory -
+// store history for email, keeping the newest limit changes. the slice is always a new one,
+// clones of the db share the old one
+func (db *Schema) putNameHistory(email string, history []NameChange, limit int) {
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	if len(history) == 0 {
+		delete(db.NameHistory, email)
+		return
+	}
+	if db.NameHistory == nil {
+		db.NameHistory = make(map[string][]NameChange)
+	}
+	db.NameHistory[email] = append([]NameChange{}, history...)
+}
+
+// recordNameChanges -
+// add the changes of Name and Username from old to user to user's history
+func (tx *Tx) recordNameChanges(old, user User) {
+	changes := []NameChange{}
+	if old.Name != user.Name {
+		changes = append(changes, NameChange{Field: NameFieldName, Old: old.Name, New: user.Name, ChangedAt: tx.now()})
+	}
+	if old.Username != user.Username {
+		changes = append(changes, NameChange{Field: NameFieldUsername, Old: old.Username, New: user.Username, ChangedAt: tx.now()})
+	}
+	if len(changes) == 0 {
+		return
+	}
+	history := append(append([]NameChange{}, tx.db.NameHistory[user.Email]...), changes...)
+	tx.db.putNameHistory(user.Email, history, tx.nameHistory)
+}
+
+// mergeNameHistory -
+// give the name history of from to into, the two interleaved by ChangedAt
+func (tx *Tx) mergeNameHistory(from, into string) {
+	if len(tx.db.NameHistory[from]) == 0 {
+		return
+	}
+	history := append(append([]NameChange{}, tx.db.NameHistory[into]...), tx.db.NameHistory[from]...)
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].ChangedAt.Before(history[j].ChangedAt)
+	})
+	tx.db.putNameHistory(into, history, tx.nameHistory)
+	delete(tx.db.NameHistory, from)
+}
+
+// GetNameHistory -
+// the changes of the user's name and username, oldest first, at most WithNameHistoryLimit of them.
+// each change is recorded by the write that made it: UpdateUser, UpdateUserFields, UpsertUser,
+// SetUsername and MergeUsers. ErrUserNotFound if there's no such user
+func (c *Client) GetNameHistory(ctx context.Context, email string) ([]NameChange, error) {
+	history := []NameChange{}
+	err := c.view(ctx, "GetNameHistory", email, func(db *Schema) error {
+		email := EmailKey(email)
+		if _, ok := db.activeUser(email); !ok {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, email)
+		}
+		history = append(history, db.NameHistory[email]...)
+		return nil
+	})
+	if err != nil {
+		return []NameChange{}, err
+	}
+	return history, nil
+}
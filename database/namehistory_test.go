@@ -0,0 +1,143 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// names is the Old and New of each change, in order
+func names(history []NameChange) [][2]string {
+	got := [][2]string{}
+	for _, change := range history {
+		got = append(got, [2]string{change.Old, change.New})
+	}
+	return got
+}
+
+func TestNameHistory(t *testing.T) {
+	for name, opts := range map[string][]Option{"file": nil, "wal": {WithWAL(100)}} {
+		clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		c := newTestClient(t, append([]Option{WithClock(clock)}, opts...)...)
+		if _, err := c.CreateUser(ctx, "test@example.com", "123456", "first", 18); err != nil {
+			t.Fatal(err)
+		}
+		newName, age, bio := "third", 30, "bio"
+		steps := []func() error{
+			func() error { _, err := c.UpdateUser(ctx, "test@example.com", "123456", "second", 18); return err },
+			// only the age and password, not a rename
+			func() error { _, err := c.UpdateUser(ctx, "test@example.com", "654321", "second", 19); return err },
+			func() error { _, err := c.UpdateUserFields(ctx, "test@example.com", UserUpdate{Age: &age}); return err },
+			func() error { _, err := c.UpdateProfile(ctx, "test@example.com", Profile{Bio: &bio}); return err },
+			func() error { _, err := c.SetUserSetting(ctx, "test@example.com", "ui.darkMode", true); return err },
+			func() error {
+				_, err := c.UpdateUserFields(ctx, "Test@example.com", UserUpdate{Name: &newName})
+				return err
+			},
+			func() error { _, err := c.SetUsername(ctx, "test@example.com", "tester"); return err },
+			// the same username again changes nothing
+			func() error { _, err := c.SetUsername(ctx, "test@example.com", "tester"); return err },
+		}
+		for _, step := range steps {
+			clock.Advance(time.Minute)
+			if err := step(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		expected := []NameChange{
+			{Field: NameFieldName, Old: "first", New: "second", ChangedAt: time.Date(2024, 1, 1, 0, 1, 0, 0, time.UTC)},
+			{Field: NameFieldName, Old: "second", New: "third", ChangedAt: time.Date(2024, 1, 1, 0, 6, 0, 0, time.UTC)},
+			{Field: NameFieldUsername, Old: "", New: "tester", ChangedAt: time.Date(2024, 1, 1, 0, 7, 0, 0, time.UTC)},
+		}
+		reopened := NewClient(dbPath(c), opts...)
+		for _, client := range []*Client{c, reopened} {
+			history, err := client.GetNameHistory(ctx, " TEST@example.com")
+			if err != nil || !reflect.DeepEqual(history, expected) {
+				t.Errorf("%s: GetNameHistory() = %+v, %v, expected %+v", name, history, err, expected)
+			}
+		}
+
+		// what's returned is a copy
+		history, _ := c.GetNameHistory(ctx, "test@example.com")
+		history[0].Old = "forged"
+		if again, _ := c.GetNameHistory(ctx, "test@example.com"); again[0].Old != "first" {
+			t.Errorf("%s: GetNameHistory() after changing what it returned = %+v, expected it unchanged", name, again)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNameHistoryLimit(t *testing.T) {
+	var tests = []struct {
+		limit    int
+		expected [][2]string
+	}{
+		{limit: 2, expected: [][2]string{{"name 3", "name 4"}, {"name 4", "name 5"}}},
+		{limit: 10, expected: [][2]string{{"name 0", "name 1"}, {"name 1", "name 2"}, {"name 2", "name 3"}, {"name 3", "name 4"}, {"name 4", "name 5"}}},
+		{limit: 0, expected: [][2]string{}},
+	}
+	for _, test := range tests {
+		c := newTestClient(t, WithNameHistoryLimit(test.limit))
+		if _, err := c.CreateUser(ctx, "test@example.com", "123456", "name 0", 18); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"name 1", "name 2", "name 3", "name 4", "name 5"} {
+			if _, err := c.UpdateUser(ctx, "test@example.com", "123456", name, 18); err != nil {
+				t.Fatal(err)
+			}
+		}
+		history, err := c.GetNameHistory(ctx, "test@example.com")
+		if err != nil || !reflect.DeepEqual(names(history), test.expected) {
+			t.Errorf("limit %d: GetNameHistory() = %v, %v, expected %v", test.limit, names(history), err, test.expected)
+		}
+	}
+}
+
+func TestNameHistoryFollowsUser(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newTestClient(t, WithClock(clock))
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		clock.Advance(time.Minute)
+		if _, err := c.CreateUser(ctx, email, "123456", "name "+email[:1], 18); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.UpdateUser(ctx, email, "123456", "renamed "+email[:1], 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.GetNameHistory(ctx, "missing@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetNameHistory() of a missing user = %v, expected ErrUserNotFound", err)
+	}
+
+	if _, err := c.ChangeEmail(ctx, "a@example.com", "new@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if history, err := c.GetNameHistory(ctx, "new@example.com"); err != nil || len(history) != 1 {
+		t.Errorf("GetNameHistory() after ChangeEmail() = %v, %v, expected the rename moved along", names(history), err)
+	}
+
+	// the duplicate's past names are the primary's now, and so is taking its name under keep-newest
+	age := 30
+	clock.Advance(time.Minute)
+	if _, err := c.UpdateUserFields(ctx, "b@example.com", UserUpdate{Age: &age}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.MergeUsers(ctx, "new@example.com", "b@example.com", MergeOptions{Conflicts: MergeKeepNewest}); err != nil {
+		t.Fatal(err)
+	}
+	expected := [][2]string{{"name a", "renamed a"}, {"name b", "renamed b"}, {"renamed a", "renamed b"}}
+	if history, err := c.GetNameHistory(ctx, "new@example.com"); err != nil || !reflect.DeepEqual(names(history), expected) {
+		t.Errorf("GetNameHistory() after MergeUsers() = %v, %v, expected %v", names(history), err, expected)
+	}
+
+	if _, err := c.DeleteUser(ctx, "new@example.com", DeleteUserOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if db, _ := c.Dump(ctx); len(db.NameHistory) != 0 {
+		t.Errorf("name history after DeleteUser() = %v, expected none", db.NameHistory)
+	}
+}
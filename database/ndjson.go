@@ -0,0 +1,206 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// record types in an ndjson stream
+const (
+	ndjsonUser = "user"
+	ndjsonPost = "post"
+)
+
+// longest line ImportNDJSON accepts, records are small so anything bigger is garbage
+const maxNDJSONLine = 1 << 20
+
+// ndjsonUserRecord and ndjsonPostRecord -
+// one line of an ndjson stream, the record's fields next to its type
+type ndjsonUserRecord struct {
+	Type string `json:"type"`
+	// storedUser and not User, the export is a backup and keeps the password
+	storedUser
+}
+
+type ndjsonPostRecord struct {
+	Type string `json:"type"`
+	Post
+}
+
+// LineError -
+// an ImportNDJSON failure and the 1-based line of the stream it happened on
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// NDJSONImportOptions -
+// controls ImportNDJSON
+type NDJSONImportOptions struct {
+	// OnConflict picks what happens to duplicate emails and post IDs, error by default
+	OnConflict ConflictPolicy
+	// CommitPartial saves the lines before a failing one instead of rolling the whole import back
+	CommitPartial bool
+}
+
+// ExportNDJSON -
+// write every user and then every post to w, one json object per line tagged with its type:
+// {"type":"user","email":...} or {"type":"post","id":...}, ImportNDJSON reads it back
+func (c *Client) ExportNDJSON(ctx context.Context, w io.Writer) error {
+	users := []User{}
+	posts := []Post{}
+	err := c.view(ctx, "ExportNDJSON", "", func(db *Schema) error {
+		for _, user := range db.Users {
+			users = append(users, user)
+		}
+		for _, post := range db.Posts {
+			posts = append(posts, post)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Email < users[j].Email })
+	sort.Slice(posts, func(i, j int) bool { return posts[i].ID < posts[j].ID })
+
+	// the records are a copy, writing to a slow w doesn't hold up the client
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for i, user := range users {
+		if i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if err := enc.Encode(ndjsonUserRecord{Type: ndjsonUser, storedUser: storedUser(user)}); err != nil {
+			return err
+		}
+	}
+	for i, post := range posts {
+		if i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if err := enc.Encode(ndjsonPostRecord{Type: ndjsonPost, Post: post}); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ImportNDJSON -
+// merge the records in an ndjson stream like the one ExportNDJSON writes, reading it line by line
+// every line is validated, the first bad one stops the import with a *LineError. the lines before
+// it are rolled back unless opts.CommitPartial is set, either way it's a single write.
+// the client's write lock is held while r is read
+func (c *Client) ImportNDJSON(ctx context.Context, r io.Reader, opts NDJSONImportOptions) (ImportResult, error) {
+	result := ImportResult{}
+	var lineErr error
+	err := c.update(ctx, "ImportNDJSON", "", func(db *Schema) error {
+		br := bufio.NewReaderSize(r, 64*1024)
+		for line := 1; ; line++ {
+			if line%cancelCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			data, err := readNDJSONLine(br)
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err == nil && len(data) == 0 {
+				// blank lines, a trailing one in particular, carry nothing
+				continue
+			}
+			if err == nil {
+				err = importNDJSONLine(db, data, opts.OnConflict, &result)
+			}
+			if err == nil {
+				continue
+			}
+
+			lineErr = &LineError{Line: line, Err: err}
+			if opts.CommitPartial {
+				return nil
+			}
+			return lineErr
+		}
+	})
+	if err != nil {
+		return ImportResult{}, err
+	}
+	return result, lineErr
+}
+
+// readNDJSONLine returns the next line without its newline, io.EOF when there are none left
+func readNDJSONLine(br *bufio.Reader) ([]byte, error) {
+	line := []byte{}
+	for {
+		chunk, isPrefix, err := br.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxNDJSONLine {
+			return nil, fmt.Errorf("line longer than %d bytes", maxNDJSONLine)
+		}
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+// importNDJSONLine -
+// validate one line and merge its record into db
+func importNDJSONLine(db *Schema, data []byte, policy ConflictPolicy, result *ImportResult) error {
+	tagged := struct {
+		Type string `json:"type"`
+	}{}
+	if err := json.Unmarshal(data, &tagged); err != nil {
+		return fmt.Errorf("%w: %v", ErrDBCorrupt, err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	switch tagged.Type {
+	case ndjsonUser:
+		record := ndjsonUserRecord{}
+		if err := dec.Decode(&record); err != nil {
+			return fmt.Errorf("%w: %v", ErrDBCorrupt, err)
+		}
+		if record.Email == "" {
+			return fmt.Errorf("%w: user without an email", ErrDBCorrupt)
+		}
+		return result.mergeUser(db, User(record.storedUser), policy)
+	case ndjsonPost:
+		record := ndjsonPostRecord{}
+		if err := dec.Decode(&record); err != nil {
+			return fmt.Errorf("%w: %v", ErrDBCorrupt, err)
+		}
+		if record.ID == "" {
+			return ErrEmptyPostID
+		}
+		if record.UserEmail == "" {
+			return fmt.Errorf("%w: post %s without an author", ErrDBCorrupt, record.ID)
+		}
+		return result.mergePost(db, record.Post, policy)
+	default:
+		return fmt.Errorf("%w: unknown record type %q", ErrDBCorrupt, tagged.Type)
+	}
+}
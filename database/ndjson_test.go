@@ -0,0 +1,192 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeNDJSON streams users user lines followed by one post line per user through a pipe,
+// badLine (1-based, 0 for none) is replaced with garbage. returns the reader and a func
+// giving the number of bytes written once the reader is drained
+func writeNDJSON(t *testing.T, users, badLine int) (io.Reader, func() int64) {
+	t.Helper()
+	pr, pw := io.Pipe()
+	var written int64
+	go func() {
+		at := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		text := strings.Repeat("lorem ipsum ", 20)
+		enc := json.NewEncoder(countingWriter{pw, &written})
+		line := 0
+		write := func(record interface{}) error {
+			line++
+			if line == badLine {
+				_, err := io.WriteString(countingWriter{pw, &written}, "{not json\n")
+				return err
+			}
+			return enc.Encode(record)
+		}
+		for i := 0; i < users; i++ {
+			email := fmt.Sprintf("user%d@example.com", i)
+			user := User{CreatedAt: at, Email: email, PasswordHash: "123456", Name: "user", Age: 18}
+			if err := write(ndjsonUserRecord{Type: ndjsonUser, storedUser: storedUser(user)}); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		for i := 0; i < users; i++ {
+			post := Post{ID: fmt.Sprintf("post-%d", i), CreatedAt: at, UserEmail: fmt.Sprintf("user%d@example.com", i), Text: text}
+			if err := write(ndjsonPostRecord{Type: ndjsonPost, Post: post}); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.Close()
+	}()
+	return pr, func() int64 { return written }
+}
+
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+func TestNDJSONRoundTrip(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "a@example.com", "123456", "a", 18); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreatePost(ctx, "a@example.com", "line one\nline two"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.ExportNDJSON(ctx, &buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], `{"type":"user",`) || !strings.HasPrefix(lines[1], `{"type":"post",`) {
+		t.Fatalf("ExportNDJSON() wrote %q, expected a user line and a post line", buf.String())
+	}
+
+	other := newTestClient(t)
+	result, err := other.ImportNDJSON(ctx, &buf, NDJSONImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (ImportResult{UsersAdded: 1, PostsAdded: 1}); result != expected {
+		t.Errorf("ImportNDJSON() = %+v, expected %+v", result, expected)
+	}
+	want, _ := c.Dump(ctx)
+	got, _ := other.Dump(ctx)
+	if !reflect.DeepEqual(got.Users, want.Users) || !reflect.DeepEqual(got.Posts, want.Posts) {
+		t.Errorf("imported db = %+v, expected %+v", got, want)
+	}
+}
+
+func TestImportNDJSONLarge(t *testing.T) {
+	const users = 20000
+	c := newTestClient(t)
+	r, written := writeNDJSON(t, users, 0)
+	result, err := c.ImportNDJSON(ctx, r, NDJSONImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written() < 4<<20 {
+		t.Fatalf("stream was %d bytes, expected several megabytes", written())
+	}
+	if expected := (ImportResult{UsersAdded: users, PostsAdded: users}); result != expected {
+		t.Errorf("ImportNDJSON() = %+v, expected %+v", result, expected)
+	}
+
+	// a second pass changes nothing
+	r, _ = writeNDJSON(t, users, 0)
+	result, err = c.ImportNDJSON(ctx, r, NDJSONImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (ImportResult{Unchanged: 2 * users}); result != expected {
+		t.Errorf("second ImportNDJSON() = %+v, expected %+v", result, expected)
+	}
+}
+
+func TestImportNDJSONFailure(t *testing.T) {
+	const users = 20000
+	const badLine = users + 5000 // in the middle of the posts
+	for _, partial := range []bool{false, true} {
+		c := newTestClient(t)
+		r, _ := writeNDJSON(t, users, badLine)
+		result, err := c.ImportNDJSON(ctx, r, NDJSONImportOptions{CommitPartial: partial})
+		// let the writer finish, the import stops reading at the bad line
+		io.Copy(io.Discard, r)
+
+		lineErr := &LineError{}
+		if !errors.As(err, &lineErr) || lineErr.Line != badLine || !errors.Is(err, ErrDBCorrupt) {
+			t.Fatalf("ImportNDJSON() with CommitPartial %v = %v, expected a corrupt line %d", partial, err, badLine)
+		}
+
+		db, err := c.Dump(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedUsers, expectedPosts, expected := 0, 0, ImportResult{}
+		if partial {
+			expectedUsers, expectedPosts = users, badLine-users-1
+			expected = ImportResult{UsersAdded: expectedUsers, PostsAdded: expectedPosts}
+		}
+		if len(db.Users) != expectedUsers || len(db.Posts) != expectedPosts {
+			t.Errorf("CommitPartial %v left %d users and %d posts, expected %d and %d",
+				partial, len(db.Users), len(db.Posts), expectedUsers, expectedPosts)
+		}
+		if result != expected {
+			t.Errorf("ImportNDJSON() with CommitPartial %v = %+v, expected %+v", partial, result, expected)
+		}
+	}
+}
+
+func TestImportNDJSONInvalidLines(t *testing.T) {
+	user := `{"type":"user","email":"a@example.com","password":"1","name":"a","age":1,"createdAt":"2023-01-01T00:00:00Z"}`
+	var tests = []struct {
+		name        string
+		line        string
+		expectedErr error
+	}{
+		{name: "not json", line: `{"type":`, expectedErr: ErrDBCorrupt},
+		{name: "unknown type", line: `{"type":"comment"}`, expectedErr: ErrDBCorrupt},
+		{name: "unknown field", line: `{"type":"user","email":"b@example.com","nickname":"b"}`, expectedErr: ErrDBCorrupt},
+		{name: "user without email", line: `{"type":"user","name":"b"}`, expectedErr: ErrDBCorrupt},
+		{name: "post without id", line: `{"type":"post","userEmail":"a@example.com"}`, expectedErr: ErrEmptyPostID},
+		{name: "post without author", line: `{"type":"post","id":"p"}`, expectedErr: ErrDBCorrupt},
+		{name: "duplicate email", line: strings.Replace(user, `"name":"a"`, `"name":"b"`, 1), expectedErr: ErrUserExists},
+		{name: "line too long", line: `{"type":"user","name":"` + strings.Repeat("a", maxNDJSONLine) + `"}`},
+	}
+
+	for _, test := range tests {
+		c := newTestClient(t)
+		stream := user + "\n\n" + test.line + "\n"
+		_, err := c.ImportNDJSON(ctx, strings.NewReader(stream), NDJSONImportOptions{})
+		lineErr := &LineError{}
+		if !errors.As(err, &lineErr) || lineErr.Line != 3 {
+			t.Errorf("%s: ImportNDJSON() = %v, expected an error on line 3", test.name, err)
+			continue
+		}
+		if test.expectedErr != nil && !errors.Is(err, test.expectedErr) {
+			t.Errorf("%s: ImportNDJSON() = %v, expected %v", test.name, err, test.expectedErr)
+		}
+		if _, err := c.GetUser(ctx, "a@example.com"); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("%s: line 1 was committed without CommitPartial", test.name)
+		}
+	}
+}
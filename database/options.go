@@ -0,0 +1,463 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Option -
+// configures a Client, passed to NewClient
+type Option func(*options)
+
+// options -
+// everything an Option can set, the file ones only apply to clients made with NewClient
+type options struct {
+	// file store
+	lockTimeout   time.Duration
+	indent        string
+	compress      bool
+	encryptionKey []byte
+	snapshotEvery int
+	fileMode      os.FileMode
+	fixFileMode   bool
+
+	// client
+	readOnly      bool
+	backupDir     string
+	autoRecover   bool
+	flushInterval time.Duration
+	maxPending    int
+	onFlushError  func(error)
+	hookQueue     int
+	metricsSink   MetricsSink
+	logger        Logger
+	watchInterval time.Duration
+	clock         Clock
+	ids           IDGenerator
+	maxSize       int64
+	retention     time.Duration
+	minimumAge    int
+	nameHistory   int
+	maxPostLength int
+	postEdits     int
+	postRetention time.Duration
+	pinnedFirst   bool
+	// scheduled posts
+	publishInterval time.Duration
+
+	// passwords
+	passwordCost   int
+	passwordPolicy PasswordPolicy
+	resetTokenTTL  time.Duration
+	credentials    bool
+
+	// email verification
+	verifyTokenTTL time.Duration
+	verifiedOnly   bool
+}
+
+// default values for client options
+const (
+	defaultLockTimeout = 5 * time.Second
+	// the file holds emails and passwords, only its owner gets to read it
+	defaultFileMode os.FileMode = 0600
+)
+
+// newOptions applies opts on top of the defaults
+func newOptions(opts []Option) options {
+	o := options{
+		lockTimeout:    defaultLockTimeout,
+		fileMode:       defaultFileMode,
+		clock:          realClock{},
+		ids:            uuidGenerator{},
+		passwordCost:   defaultPasswordCost,
+		passwordPolicy: defaultPasswordPolicy,
+		resetTokenTTL:  DefaultResetTokenTTL,
+		verifyTokenTTL: DefaultVerificationTokenTTL,
+		retention:      DefaultDeletedUserRetention,
+		minimumAge:     DefaultMinimumAge,
+		nameHistory:    DefaultNameHistoryLimit,
+		maxPostLength:  DefaultMaxPostLength,
+		postEdits:      DefaultPostEditHistoryLimit,
+		postRetention:  DefaultDeletedPostRetention,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// validate -
+// ErrInvalidOption for values that would only fail (or silently misbehave) on first use
+func (o options) validate() error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s", ErrInvalidOption, fmt.Sprintf(format, args...))
+	}
+	switch {
+	case o.lockTimeout < 0:
+		return invalid("negative lock timeout %v", o.lockTimeout)
+	case strings.Trim(o.indent, " \t\r\n") != "":
+		// anything but json whitespace in the indent makes the file invalid json
+		return invalid("indent %q must be whitespace", o.indent)
+	case o.encryptionKey != nil && len(o.encryptionKey) != 16 && len(o.encryptionKey) != 24 && len(o.encryptionKey) != 32:
+		return invalid("encryption key is %d bytes, must be 16, 24 or 32", len(o.encryptionKey))
+	case o.snapshotEvery < 0:
+		return invalid("negative WAL snapshot interval %d", o.snapshotEvery)
+	case o.fileMode&0600 != 0600:
+		// the client couldn't read back or replace its own file
+		return invalid("file mode %v must let the owner read and write", o.fileMode)
+	case o.flushInterval < 0 || o.maxPending < 0:
+		return invalid("negative batched writes interval %v or limit %d", o.flushInterval, o.maxPending)
+	case o.maxPending > 0 && o.flushInterval == 0:
+		return invalid("batched writes need an interval")
+	case o.readOnly && o.flushInterval > 0:
+		return invalid("a read-only client has no writes to batch")
+	case o.hookQueue < 0:
+		return invalid("negative hook queue size %d", o.hookQueue)
+	case o.passwordCost < bcrypt.MinCost || o.passwordCost > bcrypt.MaxCost:
+		return invalid("password cost %d must be %d to %d", o.passwordCost, bcrypt.MinCost, bcrypt.MaxCost)
+	case o.passwordPolicy.MinLength < 0:
+		return invalid("negative minimum password length %d", o.passwordPolicy.MinLength)
+	case o.retention < 0:
+		return invalid("negative deleted user retention %v", o.retention)
+	case o.postRetention < 0:
+		return invalid("negative deleted post retention %v", o.postRetention)
+	case o.minimumAge < 0 || o.minimumAge > MaxAge:
+		return invalid("minimum age %d must be 0 to %d", o.minimumAge, MaxAge)
+	case o.nameHistory < 0:
+		return invalid("negative name history limit %d", o.nameHistory)
+	case o.postEdits < 0:
+		return invalid("negative post edit history limit %d", o.postEdits)
+	case o.maxPostLength <= 0:
+		return invalid("maximum post length %d must be positive", o.maxPostLength)
+	case o.resetTokenTTL <= 0:
+		return invalid("reset token lifetime %v must be positive", o.resetTokenTTL)
+	case o.verifyTokenTTL <= 0:
+		return invalid("verification token lifetime %v must be positive", o.verifyTokenTTL)
+	case o.maxSize < 0:
+		return invalid("negative size limit %d", o.maxSize)
+	case o.watchInterval < 0:
+		return invalid("negative file watch interval %v", o.watchInterval)
+	case o.publishInterval < 0:
+		return invalid("negative scheduled publishing interval %v", o.publishInterval)
+	case o.readOnly && o.publishInterval > 0:
+		return invalid("a read-only client can't publish scheduled posts")
+	}
+	return nil
+}
+
+// WithLockTimeout -
+// how long to wait for the cross-process file lock before giving up with ErrDatabaseLocked
+func WithLockTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.lockTimeout = d
+	}
+}
+
+// WithIndent -
+// pretty-print the db file with the given indent so it's readable by humans
+// the file is compact json by default to keep it small
+func WithIndent(indent string) Option {
+	return func(o *options) {
+		o.indent = indent
+	}
+}
+
+// WithCompression -
+// gzip the db file, it's mostly repeated json keys so it shrinks a lot
+// compressed files are detected on read whether or not this option is set,
+// an uncompressed file gets compressed on the next write
+func WithCompression() Option {
+	return func(o *options) {
+		o.compress = true
+	}
+}
+
+// WithEncryptionKey -
+// encrypt the db file with AES-GCM, key must be 16, 24 or 32 bytes (AES-128/192/256)
+// an existing plaintext file is still readable and gets encrypted on the next write,
+// or right away with EncryptInPlace
+func WithEncryptionKey(key []byte) Option {
+	return func(o *options) {
+		o.encryptionKey = append([]byte{}, key...)
+	}
+}
+
+// WithWAL -
+// append every write to a log next to the db file (path + ".wal") and fsync it,
+// instead of rewriting the whole file each time. the file is rewritten every snapshotEvery
+// writes and on Close, and the log is replayed on load so nothing acknowledged is lost in a crash
+func WithWAL(snapshotEvery int) Option {
+	return func(o *options) {
+		o.snapshotEvery = snapshotEvery
+	}
+}
+
+// WithFileMode -
+// permissions for the db file and everything written next to it (log, lock, backups),
+// 0600 by default. mostly ignored on windows
+func WithFileMode(mode os.FileMode) Option {
+	return func(o *options) {
+		o.fileMode = mode.Perm()
+	}
+}
+
+// WithFixFileMode -
+// when the db file is first loaded, chmod it (and its log) to the WithFileMode permissions
+// if it's readable or writable by more than those allow. files written by older versions
+// were left open to everyone. does nothing for a read-only client or on windows
+func WithFixFileMode() Option {
+	return func(o *options) {
+		o.fixFileMode = true
+	}
+}
+
+// WithReadOnly -
+// make every mutating method return ErrReadOnly without touching the file,
+// reads work as usual. the file is never created and the lock file is never taken
+func WithReadOnly() Option {
+	return func(o *options) {
+		o.readOnly = true
+	}
+}
+
+// WithBackupDir -
+// directory Recover looks in for backups to restore a corrupt db file from, newest first
+func WithBackupDir(dir string) Option {
+	return func(o *options) {
+		o.backupDir = dir
+	}
+}
+
+// WithAutoRecover -
+// make EnsureDB call Recover when the db file is corrupt instead of failing
+func WithAutoRecover() Option {
+	return func(o *options) {
+		o.autoRecover = true
+	}
+}
+
+// WithBatchedWrites -
+// keep writes in memory and save them to the store together, at most every interval
+// or as soon as maxPending writes are waiting (0 for no limit), instead of one save per write.
+// reads always see every write. a crash loses whatever wasn't saved yet, Flush and Close save it now.
+// errors from a background save are passed to WithFlushErrorHandler if set, otherwise the next
+// write or Flush returns them. meant for a single writing process, the file is only checked
+// for changes by others while nothing is waiting to be saved
+func WithBatchedWrites(interval time.Duration, maxPending int) Option {
+	return func(o *options) {
+		o.flushInterval = interval
+		o.maxPending = maxPending
+	}
+}
+
+// WithFlushErrorHandler -
+// called with the error whenever a background save from WithBatchedWrites fails,
+// the writes stay pending and the save is retried after the next interval
+func WithFlushErrorHandler(fn func(error)) Option {
+	return func(o *options) {
+		o.onFlushError = fn
+	}
+}
+
+// WithAsyncHooks -
+// run hooks (see OnUserCreated) one at a time on a background goroutine instead of in the
+// writing call, so a slow hook doesn't hold up the write that triggered it. up to queueSize
+// calls wait to run, after that writes block until the hooks catch up
+func WithAsyncHooks(queueSize int) Option {
+	return func(o *options) {
+		o.hookQueue = queueSize
+	}
+}
+
+// WithMetricsSink -
+// also report every operation to sink as it finishes, for exporting to prometheus, expvar and such
+// see Client.Metrics for the built-in counters
+func WithMetricsSink(sink MetricsSink) Option {
+	return func(o *options) {
+		o.metricsSink = sink
+	}
+}
+
+// WithLogger -
+// log every operation to l, nothing is logged by default. see Logger for what goes where
+func WithLogger(l Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithFileWatch -
+// check every interval whether another process changed the db file and reload it if so,
+// so reads don't serve stale data between this client's own writes. see OnExternalChange.
+// writes always start from the latest file either way. stopped by Close
+func WithFileWatch(interval time.Duration) Option {
+	return func(o *options) {
+		o.watchInterval = interval
+	}
+}
+
+// WithScheduledPublishing -
+// call PublishDue every interval in the background so scheduled posts are marked published without
+// the caller driving it. they show up at their PublishAt either way. stopped by Close
+func WithScheduledPublishing(interval time.Duration) Option {
+	return func(o *options) {
+		o.publishInterval = interval
+	}
+}
+
+// WithClock -
+// where CreatedAt and every other timestamp stored in records comes from, the system clock by default
+func WithClock(clock Clock) Option {
+	return func(o *options) {
+		if clock == nil {
+			clock = realClock{}
+		}
+		o.clock = clock
+	}
+}
+
+// WithIDGenerator -
+// where new post IDs come from, random UUIDs by default. an ID that's already taken is
+// generated again a few times before CreatePost gives up with ErrPostExists
+func WithIDGenerator(ids IDGenerator) Option {
+	return func(o *options) {
+		if ids == nil {
+			ids = uuidGenerator{}
+		}
+		o.ids = ids
+	}
+}
+
+// WithMaxSizeBytes -
+// reject writes that would grow the db past n bytes with ErrDatabaseFull, leaving it untouched.
+// the size is that of the db file as NewClient writes it, after indenting, compression and encryption,
+// and for other stores compact json unless they're a Sizer. measuring it encodes the db once more per write.
+// deletes, soft deletes included, and writes that don't grow it always go through, so there's a way back
+// with WithWAL it's the size of the next snapshot, the log next to it isn't counted
+func WithMaxSizeBytes(n int64) Option {
+	return func(o *options) {
+		o.maxSize = n
+	}
+}
+
+// WithPasswordCost -
+// bcrypt cost for hashing passwords, DefaultPasswordCost by default. every step up doubles
+// the time it takes to hash and to check a password, existing hashes are upgraded by VerifyPassword
+func WithPasswordCost(cost int) Option {
+	return func(o *options) {
+		o.passwordCost = cost
+	}
+}
+
+// WithPasswordPolicy -
+// what new passwords have to look like instead of DefaultPasswordPolicy,
+// PasswordPolicy{} only refuses passwords bcrypt can't hash
+func WithPasswordPolicy(policy PasswordPolicy) Option {
+	return func(o *options) {
+		o.passwordPolicy = policy
+	}
+}
+
+// WithResetTokenTTL -
+// how long a token from CreatePasswordResetToken can be redeemed, DefaultResetTokenTTL by default
+func WithResetTokenTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.resetTokenTTL = d
+	}
+}
+
+// WithDeletedUserRetention -
+// how long after SoftDeleteUser a user can still be restored, DefaultDeletedUserRetention by default.
+// 0 means soft deletes can't be undone. purging is separate, see PurgeDeletedUsers
+func WithDeletedUserRetention(d time.Duration) Option {
+	return func(o *options) {
+		o.retention = d
+	}
+}
+
+// WithDeletedPostRetention -
+// how long after SoftDeletePost a post can still be restored, DefaultDeletedPostRetention by default.
+// after that RestorePost fails with ErrRestoreExpired, PurgeDeletedPosts decides when it's gone
+func WithDeletedPostRetention(d time.Duration) Option {
+	return func(o *options) {
+		o.postRetention = d
+	}
+}
+
+// WithMinimumAge -
+// the youngest a user can be, DefaultMinimumAge by default. CreateUser, UpdateUser and the like
+// refuse younger ones with ErrInvalidAge, users already stored aren't affected until they're changed
+func WithMinimumAge(age int) Option {
+	return func(o *options) {
+		o.minimumAge = age
+	}
+}
+
+// WithNameHistoryLimit -
+// how many of a user's name and username changes GetNameHistory keeps, DefaultNameHistoryLimit
+// by default. the oldest go first once there are more, 0 keeps none
+func WithNameHistoryLimit(n int) Option {
+	return func(o *options) {
+		o.nameHistory = n
+	}
+}
+
+// WithPinnedPostsFirst -
+// have GetPosts return the post the user pinned with PinPost first, with Pinned set, whenever it
+// was created. without it the pinned post is in its place like any other
+func WithPinnedPostsFirst() Option {
+	return func(o *options) {
+		o.pinnedFirst = true
+	}
+}
+
+// WithMaxPostLength -
+// the most characters, runes not bytes, CreatePost and UpdatePost accept in a post's text,
+// DefaultMaxPostLength by default. longer ones get ErrPostTooLong, posts already stored aren't affected
+func WithMaxPostLength(n int) Option {
+	return func(o *options) {
+		o.maxPostLength = n
+	}
+}
+
+// WithPostEditHistoryLimit -
+// how many earlier texts of a post GetPostEditHistory keeps, DefaultPostEditHistoryLimit by default.
+// the oldest go first once there are more, 0 keeps none
+func WithPostEditHistoryLimit(n int) Option {
+	return func(o *options) {
+		o.postEdits = n
+	}
+}
+
+// WithVerificationTokenTTL -
+// how long a token from CreateEmailVerificationToken can be redeemed, DefaultVerificationTokenTTL by default
+func WithVerificationTokenTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.verifyTokenTTL = d
+	}
+}
+
+// WithRequireVerifiedEmail -
+// refuse posts by users who haven't verified their email with ErrEmailNotVerified,
+// anyone can post by default
+func WithRequireVerifiedEmail() Option {
+	return func(o *options) {
+		o.verifiedOnly = true
+	}
+}
+
+// WithCredentials -
+// return PasswordHash from GetUser, GetUsers and the other client methods that return users,
+// they leave it empty by default so it doesn't end up in logs and API responses. for the rare
+// caller that checks hashes itself, VerifyPassword and AuthenticateUser don't need it.
+// Tx methods and hooks always see the stored user
+func WithCredentials() Option {
+	return func(o *options) {
+		o.credentials = true
+	}
+}
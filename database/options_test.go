@@ -0,0 +1,131 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWithIndent(t *testing.T) {
+	var tests = []struct {
+		opts           []Option
+		expectedIndent bool
+	}{
+		{opts: nil, expectedIndent: false},
+		{opts: []Option{WithIndent("  ")}, expectedIndent: true},
+	}
+
+	for _, test := range tests {
+		c := NewClient(filepath.Join(t.TempDir(), "db.json"), test.opts...)
+
+		// every write path has to keep the chosen format
+		checks := []struct {
+			name string
+			run  func() error
+		}{
+			{"EnsureDB", func() error { return c.EnsureDB(ctx) }},
+			{"CreateUser", func() error {
+				_, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18)
+				return err
+			}},
+			{"UpdateUser", func() error {
+				_, err := c.UpdateUser(ctx, "test@example.com", "54321", "john doe", 19)
+				return err
+			}},
+			{"CreatePost", func() error {
+				post, err := c.CreatePost(ctx, "test@example.com", "hello")
+				if err != nil {
+					return err
+				}
+				_, err = c.DeletePost(ctx, post.ID)
+				return err
+			}},
+			{"DeleteUser", func() error {
+				_, err := c.DeleteUser(ctx, "test@example.com", DeleteUserOptions{})
+				return err
+			}},
+		}
+		for _, check := range checks {
+			if err := check.run(); err != nil {
+				t.Fatalf("%s() = %v, expected nil", check.name, err)
+			}
+			data, err := os.ReadFile(dbPath(c))
+			if err != nil {
+				t.Fatal(err)
+			}
+			indented := bytes.Contains(data, []byte("\n  \""))
+			if indented != test.expectedIndent {
+				t.Errorf("after %s file indented = %v, expected %v:\n%s", check.name, indented, test.expectedIndent, data)
+			}
+		}
+	}
+}
+
+func TestNewClientE(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	var tests = []struct {
+		name  string
+		opts  []Option
+		valid bool
+	}{
+		{name: "no options", valid: true},
+		{name: "everything", valid: true, opts: []Option{
+			WithIndent("\t"), WithCompression(), WithEncryptionKey(key), WithWAL(10), WithFileMode(0640),
+			WithLockTimeout(time.Second), WithBatchedWrites(time.Millisecond, 10), WithAsyncHooks(10),
+			WithFileWatch(time.Second), WithBackupDir(t.TempDir()), WithAutoRecover(),
+		}},
+		{name: "read-only with a key", valid: true, opts: []Option{WithReadOnly(), WithEncryptionKey(key)}},
+		{name: "negative lock timeout", opts: []Option{WithLockTimeout(-time.Second)}},
+		{name: "indent that isn't whitespace", opts: []Option{WithIndent("--")}},
+		{name: "short key", opts: []Option{WithEncryptionKey([]byte("secret"))}},
+		{name: "negative WAL interval", opts: []Option{WithWAL(-1)}},
+		{name: "unreadable file mode", opts: []Option{WithFileMode(0200)}},
+		{name: "negative flush interval", opts: []Option{WithBatchedWrites(-time.Second, 0)}},
+		{name: "batch limit without interval", opts: []Option{WithBatchedWrites(0, 10)}},
+		{name: "batched read-only", opts: []Option{WithReadOnly(), WithBatchedWrites(time.Second, 0)}},
+		{name: "negative hook queue", opts: []Option{WithAsyncHooks(-1)}},
+		{name: "negative watch interval", opts: []Option{WithFileWatch(-time.Second)}},
+		{name: "negative max size", opts: []Option{WithMaxSizeBytes(-1)}},
+		{name: "password cost too low", opts: []Option{WithPasswordCost(1)}},
+		{name: "negative password length", opts: []Option{WithPasswordPolicy(PasswordPolicy{MinLength: -1})}},
+		{name: "zero reset token lifetime", opts: []Option{WithResetTokenTTL(0)}},
+		{name: "zero verification token lifetime", opts: []Option{WithVerificationTokenTTL(0)}},
+		{name: "negative minimum age", opts: []Option{WithMinimumAge(-1)}},
+		{name: "minimum age over the maximum", opts: []Option{WithMinimumAge(MaxAge + 1)}},
+		{name: "negative name history limit", opts: []Option{WithNameHistoryLimit(-1)}},
+		{name: "zero maximum post length", opts: []Option{WithMaxPostLength(0)}},
+		{name: "negative post edit history limit", opts: []Option{WithPostEditHistoryLimit(-1)}},
+		{name: "negative deleted post retention", opts: []Option{WithDeletedPostRetention(-time.Hour)}},
+		{name: "negative publish interval", opts: []Option{WithScheduledPublishing(-time.Second)}},
+		{name: "read-only scheduled publishing", opts: []Option{WithReadOnly(), WithScheduledPublishing(time.Second)}},
+	}
+
+	for _, test := range tests {
+		c, err := NewClientE(filepath.Join(t.TempDir(), "db.json"), test.opts...)
+		if !test.valid {
+			if !errors.Is(err, ErrInvalidOption) || c != nil {
+				t.Errorf("%s: NewClientE() = %v, %v, expected ErrInvalidOption", test.name, c, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: NewClientE() = %v, expected nil", test.name, err)
+			continue
+		}
+		if c.readOnly {
+			continue
+		}
+		if err := c.EnsureDB(ctx); err != nil {
+			t.Errorf("%s: EnsureDB() = %v, expected nil", test.name, err)
+		}
+		if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); err != nil {
+			t.Errorf("%s: CreateUser() = %v, expected nil", test.name, err)
+		}
+		if err := c.Close(); err != nil {
+			t.Errorf("%s: Close() = %v, expected nil", test.name, err)
+		}
+	}
+}
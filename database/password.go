@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultPasswordCost -
+// bcrypt cost used for new password hashes unless WithPasswordCost says otherwise
+const DefaultPasswordCost = bcrypt.DefaultCost
+
+// defaultPasswordCost is what newOptions starts from, tests lower it to keep the suite fast
+var defaultPasswordCost = DefaultPasswordCost
+
+// HashPassword -
+// the bcrypt hash of password at cost, what's stored in User.PasswordHash
+// every backend hashes with it so a hash made by one can be checked by any other
+func HashPassword(password string, cost int) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		return "", fmt.Errorf("hashing password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// CheckPassword -
+// whether password is the one hash was made from
+// records from before hashing hold the plaintext, those are compared in constant time
+func CheckPassword(hash, password string) bool {
+	if isLegacyPassword(hash) {
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(password)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// isLegacyPassword reports whether a stored password is plaintext from before hashing
+func isLegacyPassword(hash string) bool {
+	_, err := bcrypt.Cost([]byte(hash))
+	return err != nil
+}
+
+// needsRehash reports whether a stored password should be hashed again at cost,
+// because it's legacy plaintext or was hashed at a lower cost
+func needsRehash(hash string, cost int) bool {
+	current, err := bcrypt.Cost([]byte(hash))
+	return err != nil || current < cost
+}
+
+// VerifyPassword -
+// nil if password is the user's, ErrWrongPassword if not and ErrUserNotFound if there's no such user.
+// a legacy plaintext password (or one hashed at a lower cost than the client's) is rehashed on success,
+// if that write fails the check still succeeds and the upgrade is retried next time
+func (c *Client) VerifyPassword(ctx context.Context, email, password string) error {
+	_, err := c.verifyPassword(ctx, email, password)
+	return err
+}
+
+// verifyPassword -
+// VerifyPassword that also returns the user as it was before any upgrade
+func (c *Client) verifyPassword(ctx context.Context, email, password string) (User, error) {
+	user, err := c.getUser(ctx, email)
+	if err != nil {
+		return User{}, err
+	}
+	// bcrypt is slow on purpose, compare outside the lock
+	if !CheckPassword(user.PasswordHash, password) {
+		return User{}, fmt.Errorf("%w: %s", ErrWrongPassword, email)
+	}
+	if c.readOnly || !needsRehash(user.PasswordHash, c.passwordCost) {
+		return user, nil
+	}
+
+	hash, err := HashPassword(password, c.passwordCost)
+	if err == nil {
+		err = c.update(ctx, "UpgradePassword", user.Email, func(db *Schema) error {
+			current, ok := db.Users[user.Email]
+			// changed or deleted meanwhile, the new password wins
+			if !ok || current.PasswordHash != user.PasswordHash {
+				return errNoop
+			}
+			current.PasswordHash = hash
+			db.putUser(current)
+			return nil
+		})
+	}
+	if err != nil {
+		c.logError("upgrading password hash failed", "key", user.Email, "error", err)
+	}
+	return user, nil
+}
+
+// ChangePassword -
+// replace the user's password after checking the current one, nothing else about the user changes.
+// ErrWrongPassword if oldPassword isn't the user's, ErrPasswordReused if newPassword is the same
+// and a *PasswordError if it breaks the policy. works the same for legacy plaintext passwords,
+// which end up hashed like any new password
+func (c *Client) ChangePassword(ctx context.Context, email, oldPassword, newPassword string) error {
+	user, err := c.getUser(ctx, email)
+	if err != nil {
+		return err
+	}
+	// both bcrypt calls are slow on purpose, make them outside the lock
+	if !CheckPassword(user.PasswordHash, oldPassword) {
+		return fmt.Errorf("%w: %s", ErrWrongPassword, email)
+	}
+	if newPassword == oldPassword {
+		return fmt.Errorf("%w: %s", ErrPasswordReused, email)
+	}
+	hash, err := newPasswordHash(newPassword, c.passwordPolicy, c.passwordCost)
+	if err != nil {
+		return err
+	}
+	return c.update(ctx, "ChangePassword", user.Email, func(db *Schema) error {
+		current, ok := db.activeUser(user.Email)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, user.Email)
+		}
+		// changed meanwhile, oldPassword was checked against a password that's gone
+		if current.PasswordHash != user.PasswordHash {
+			return fmt.Errorf("%w: %s changed meanwhile", ErrWrongPassword, user.Email)
+		}
+		current.PasswordHash = hash
+		current.UpdatedAt = c.newTx(db).now()
+		db.putUser(current)
+		return nil
+	})
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func init() {
+	// the suite creates hundreds of users, hashing them at the real cost takes ages
+	defaultPasswordCost = bcrypt.MinCost
+	// and most of them have passwords like "123456", policy_test.go checks the default on its own
+	defaultPasswordPolicy = PasswordPolicy{}
+}
+
+func TestPasswordIsHashed(t *testing.T) {
+	c := newTestClient(t, WithCredentials())
+	created, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.PasswordHash == "correct horse" || !CheckPassword(created.PasswordHash, "correct horse") {
+		t.Errorf("CreateUser() returned PasswordHash %q, expected a hash of the password", created.PasswordHash)
+	}
+	if CheckPassword(created.PasswordHash, "wrong horse") || CheckPassword(created.PasswordHash, "") {
+		t.Errorf("PasswordHash %q matches other passwords", created.PasswordHash)
+	}
+	data, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "correct horse") {
+		t.Errorf("db file has the plaintext password: %s", data)
+	}
+
+	updated, err := c.UpdateUser(ctx, "test@example.com", "battery staple", "john doe", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword(updated.PasswordHash, "battery staple") || CheckPassword(updated.PasswordHash, "correct horse") {
+		t.Errorf("UpdateUser() returned PasswordHash %q, expected a hash of the new password", updated.PasswordHash)
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		email    string
+		password string
+		expected error
+	}{
+		{email: "test@example.com", password: "correct horse", expected: nil},
+		{email: "test@example.com", password: "wrong horse", expected: ErrWrongPassword},
+		{email: "test@example.com", password: "", expected: ErrWrongPassword},
+		{email: "missing@example.com", password: "correct horse", expected: ErrUserNotFound},
+	}
+	for _, test := range tests {
+		if err := c.VerifyPassword(ctx, test.email, test.password); !errors.Is(err, test.expected) {
+			t.Errorf("VerifyPassword(%q, %q) = %v, expected %v", test.email, test.password, err, test.expected)
+		}
+	}
+}
+
+func TestVerifyPasswordUpgradesLegacy(t *testing.T) {
+	c := newTestClient(t, WithCredentials())
+	// a record from before hashing, with the plaintext in the password field
+	legacy := Schema{
+		Users: map[string]User{"test@example.com": {Email: "test@example.com", PasswordHash: "hunter22", Name: "john doe", Age: 18}},
+		Posts: map[string]Post{},
+	}
+	if err := c.Load(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.VerifyPassword(ctx, "test@example.com", "wrong"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("VerifyPassword() of a legacy record with the wrong password = %v, expected ErrWrongPassword", err)
+	}
+	if user, _ := c.GetUser(ctx, "test@example.com"); user.PasswordHash != "hunter22" {
+		t.Errorf("failed VerifyPassword() changed the legacy password to %q", user.PasswordHash)
+	}
+
+	if err := c.VerifyPassword(ctx, "test@example.com", "hunter22"); err != nil {
+		t.Fatalf("VerifyPassword() of a legacy record = %v, expected nil", err)
+	}
+	user, err := c.GetUser(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isLegacyPassword(user.PasswordHash) || !CheckPassword(user.PasswordHash, "hunter22") {
+		t.Errorf("PasswordHash after VerifyPassword() = %q, expected it upgraded to a hash", user.PasswordHash)
+	}
+	// and it still works afterwards
+	if err := c.VerifyPassword(ctx, "test@example.com", "hunter22"); err != nil {
+		t.Errorf("VerifyPassword() after the upgrade = %v, expected nil", err)
+	}
+}
+
+func TestVerifyPasswordUpgradesCost(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	stronger := NewClient(dbPath(c), WithPasswordCost(bcrypt.MinCost+1), WithCredentials())
+	if err := stronger.VerifyPassword(ctx, "test@example.com", "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	user, err := stronger.GetUser(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost, err := bcrypt.Cost([]byte(user.PasswordHash)); err != nil || cost != bcrypt.MinCost+1 {
+		t.Errorf("cost after VerifyPassword() = %d, %v, expected %d", cost, err, bcrypt.MinCost+1)
+	}
+}
+
+func TestVerifyPasswordReadOnly(t *testing.T) {
+	c := newTestClient(t)
+	if err := c.Load(ctx, Schema{Users: map[string]User{"test@example.com": {Email: "test@example.com", PasswordHash: "hunter22"}}}); err != nil {
+		t.Fatal(err)
+	}
+	// no upgrade on a read-only client, the check itself still works
+	ro := NewClient(dbPath(c), WithReadOnly(), WithCredentials())
+	if err := ro.VerifyPassword(ctx, "test@example.com", "hunter22"); err != nil {
+		t.Errorf("VerifyPassword() on a read-only client = %v, expected nil", err)
+	}
+	if user, _ := ro.GetUser(ctx, "test@example.com"); user.PasswordHash != "hunter22" {
+		t.Errorf("read-only VerifyPassword() changed the password to %q", user.PasswordHash)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	c := newTestClient(t, WithCredentials())
+	created, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = NewClient(dbPath(c), WithPasswordPolicy(PasswordPolicy{MinLength: 8}), WithCredentials())
+
+	var tests = []struct {
+		email       string
+		oldPassword string
+		newPassword string
+		expected    error
+	}{
+		{email: "test@example.com", oldPassword: "wrong horse", newPassword: "battery staple", expected: ErrWrongPassword},
+		{email: "test@example.com", oldPassword: "correct horse", newPassword: "short", expected: ErrWeakPassword},
+		{email: "test@example.com", oldPassword: "correct horse", newPassword: "correct horse", expected: ErrPasswordReused},
+		{email: "missing@example.com", oldPassword: "correct horse", newPassword: "battery staple", expected: ErrUserNotFound},
+	}
+	for _, test := range tests {
+		if err := c.ChangePassword(ctx, test.email, test.oldPassword, test.newPassword); !errors.Is(err, test.expected) {
+			t.Errorf("ChangePassword(%q, %q, %q) = %v, expected %v", test.email, test.oldPassword, test.newPassword, err, test.expected)
+		}
+	}
+	if user, _ := c.GetUser(ctx, "test@example.com"); user != created {
+		t.Fatalf("failed ChangePassword() calls changed the user to %+v", user)
+	}
+
+	if err := c.ChangePassword(ctx, "test@example.com", "correct horse", "battery staple"); err != nil {
+		t.Fatalf("ChangePassword() = %v, expected nil", err)
+	}
+	user, err := c.GetUser(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword(user.PasswordHash, "battery staple") || CheckPassword(user.PasswordHash, "correct horse") {
+		t.Errorf("PasswordHash after ChangePassword() = %q, expected a hash of the new password", user.PasswordHash)
+	}
+	// only the password changes, and UpdatedAt with it
+	if user.UpdatedAt.Before(created.UpdatedAt) {
+		t.Errorf("UpdatedAt after ChangePassword() = %v, expected no earlier than %v", user.UpdatedAt, created.UpdatedAt)
+	}
+	user.PasswordHash, user.UpdatedAt = created.PasswordHash, created.UpdatedAt
+	if user != created {
+		t.Errorf("ChangePassword() changed the user to %+v, expected only the password of %+v to change", user, created)
+	}
+}
+
+func TestChangePasswordLegacy(t *testing.T) {
+	c := newTestClient(t, WithCredentials())
+	legacy := Schema{
+		Users: map[string]User{"test@example.com": {Email: "test@example.com", PasswordHash: "hunter22", Name: "john doe", Age: 18}},
+		Posts: map[string]Post{},
+	}
+	if err := c.Load(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ChangePassword(ctx, "test@example.com", "hunter2", "battery staple"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("ChangePassword() of a legacy record with the wrong password = %v, expected ErrWrongPassword", err)
+	}
+	if err := c.ChangePassword(ctx, "test@example.com", "hunter22", "battery staple"); err != nil {
+		t.Fatalf("ChangePassword() of a legacy record = %v, expected nil", err)
+	}
+	user, err := c.GetUser(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isLegacyPassword(user.PasswordHash) || !CheckPassword(user.PasswordHash, "battery staple") {
+		t.Errorf("PasswordHash after ChangePassword() = %q, expected a hash of the new password", user.PasswordHash)
+	}
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// unpin -
+// clear the pin of post's author if it's post, for when post goes away
+func (db *Schema) unpin(post Post) {
+	if user, ok := db.Users[post.UserEmail]; ok && user.PinnedPostID == post.ID {
+		user.PinnedPostID = ""
+		db.putUser(user)
+	}
+}
+
+// pinnedFirst -
+// posts with the one with id moved to the front and marked Pinned, the rest in the order they were
+func pinnedFirst(posts []Post, id string) []Post {
+	if id == "" {
+		return posts
+	}
+	for i, post := range posts {
+		if post.ID == id {
+			post.Pinned = true
+			copy(posts[1:i+1], posts[:i])
+			posts[0] = post
+			break
+		}
+	}
+	return posts
+}
+
+// PinPost -
+// same as Client.PinPost, inside the Tx
+func (tx *Tx) PinPost(ctx context.Context, email, postID string) (User, error) {
+	user, _, err := tx.pinPost(email, postID)
+	return user, err
+}
+
+// pinPost -
+// PinPost that also reports whether the user changed
+func (tx *Tx) pinPost(email, postID string) (User, bool, error) {
+	if postID == "" {
+		return User{}, false, ErrEmptyPostID
+	}
+	db, err := tx.schema()
+	if err != nil {
+		return User{}, false, err
+	}
+	email = EmailKey(email)
+	user, ok := db.activeUser(email)
+	if !ok {
+		return User{}, false, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+	post, ok := db.livePost(postID)
+	if !ok {
+		return User{}, false, fmt.Errorf("%w: %s", ErrPostNotFound, postID)
+	}
+	// admins moderate posts, they don't get to arrange other people's profiles
+	if post.UserEmail != email {
+		return User{}, false, fmt.Errorf("%w: %s didn't write post %s", ErrNotPostAuthor, email, postID)
+	}
+	if post.unpublished(tx.now()) {
+		return User{}, false, fmt.Errorf("%w: %s", ErrPostNotPublished, postID)
+	}
+	if user.PinnedPostID == postID {
+		return user, false, nil
+	}
+	user.PinnedPostID = postID
+	user.UpdatedAt = tx.now()
+	db.putUser(user)
+	return user, true, nil
+}
+
+// UnpinPost -
+// same as Client.UnpinPost, inside the Tx
+func (tx *Tx) UnpinPost(ctx context.Context, email string) (User, error) {
+	user, _, err := tx.unpinPost(email)
+	return user, err
+}
+
+// unpinPost -
+// UnpinPost that also reports whether the user changed
+func (tx *Tx) unpinPost(email string) (User, bool, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return User{}, false, err
+	}
+	email = EmailKey(email)
+	user, ok := db.activeUser(email)
+	if !ok {
+		return User{}, false, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+	if user.PinnedPostID == "" {
+		return user, false, nil
+	}
+	user.PinnedPostID = ""
+	user.UpdatedAt = tx.now()
+	db.putUser(user)
+	return user, true, nil
+}
+
+// PinPost -
+// stick the post with postID to the top of the profile of the user with email, who has to be
+// its author, replacing the post pinned before. it has to be published, drafts and posts scheduled
+// for later can't be pinned, and deleting or soft-deleting it unpins it in the same write. pinning
+// the pinned post again changes nothing. see WithPinnedPostsFirst for GetPosts.
+// ErrEmptyPostID for an empty postID, ErrUserNotFound if there's no such user, ErrPostNotFound if
+// there's no such post or it was soft-deleted, ErrNotPostAuthor if the user didn't write it,
+// ErrPostNotPublished for a draft or a scheduled post
+func (c *Client) PinPost(ctx context.Context, email, postID string) (User, error) {
+	user := User{}
+	err := c.update(ctx, "PinPost", email, func(db *Schema) error {
+		var changed bool
+		var err error
+		user, changed, err = c.newTx(db).pinPost(email, postID)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return c.sanitize(user), nil
+}
+
+// UnpinPost -
+// take the pinned post off the profile of the user with email, the post itself stays.
+// a user with no pinned post is left as it is. ErrUserNotFound if there's no such user
+func (c *Client) UnpinPost(ctx context.Context, email string) (User, error) {
+	user := User{}
+	err := c.deleteUpdate(ctx, "UnpinPost", email, func(db *Schema) error {
+		var changed bool
+		var err error
+		user, changed, err = c.newTx(db).unpinPost(email)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return c.sanitize(user), nil
+}
@@ -0,0 +1,200 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newPinClient is newBlockClient with a@ posting twice more an hour apart, its posts are
+// sequence(1, 4, 5) oldest first
+func newPinClient(t *testing.T, opts ...Option) (*Client, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newBlockClient(t, append([]Option{WithClock(clock), WithIDGenerator(&SequenceIDGenerator{})}, opts...)...)
+	for _, text := range []string{"second", "third"} {
+		clock.Advance(time.Hour)
+		if _, err := c.CreatePost(ctx, "a@example.com", text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c, clock
+}
+
+func TestPinPost(t *testing.T) {
+	c, clock := newPinClient(t)
+	if _, err := c.BootstrapAdmin(ctx, "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	draft, err := c.CreateDraft(ctx, "a@example.com", "draft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduled, err := c.SchedulePost(ctx, "a@example.com", "later", clock.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SoftDeletePost(ctx, sequence(4)[0], "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		email    string
+		postID   string
+		expected error
+		pinned   string
+	}{
+		{email: "a@example.com", postID: sequence(1)[0], expected: nil, pinned: sequence(1)[0]},
+		// pinning it again is a no-op
+		{email: "A@example.com", postID: sequence(1)[0], expected: nil, pinned: sequence(1)[0]},
+		// someone else's post, admin or not
+		{email: "b@example.com", postID: sequence(1)[0], expected: ErrNotPostAuthor},
+		{email: "c@example.com", postID: sequence(1)[0], expected: ErrNotPostAuthor},
+		{email: "a@example.com", postID: sequence(2)[0], expected: ErrNotPostAuthor},
+		{email: "a@example.com", postID: draft.ID, expected: ErrPostNotPublished},
+		{email: "a@example.com", postID: scheduled.ID, expected: ErrPostNotPublished},
+		{email: "a@example.com", postID: sequence(4)[0], expected: ErrPostNotFound},
+		{email: "a@example.com", postID: "missing", expected: ErrPostNotFound},
+		{email: "a@example.com", postID: "", expected: ErrEmptyPostID},
+		{email: "missing@example.com", postID: sequence(1)[0], expected: ErrUserNotFound},
+		// a different post replaces the pin
+		{email: "a@example.com", postID: sequence(5)[0], expected: nil, pinned: sequence(5)[0]},
+	}
+	for _, test := range tests {
+		user, err := c.PinPost(ctx, test.email, test.postID)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("PinPost(%q, %q) = %v, expected %v", test.email, test.postID, err, test.expected)
+		}
+		if err == nil && user.PinnedPostID != test.pinned {
+			t.Errorf("PinPost(%q, %q) pinned %q, expected %q", test.email, test.postID, user.PinnedPostID, test.pinned)
+		}
+	}
+
+	// the scheduled post can be pinned once it's out
+	clock.Advance(time.Hour)
+	if user, err := c.PinPost(ctx, "a@example.com", scheduled.ID); err != nil || user.PinnedPostID != scheduled.ID {
+		t.Errorf("PinPost() of a published scheduled post = %+v, %v, expected it pinned", user, err)
+	}
+	for i := 0; i < 2; i++ {
+		user, err := c.UnpinPost(ctx, "a@example.com")
+		if err != nil || user.PinnedPostID != "" {
+			t.Errorf("UnpinPost() #%d = %+v, %v, expected no pin", i+1, user, err)
+		}
+	}
+	if _, err := c.UnpinPost(ctx, "missing@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("UnpinPost() of a missing user = %v, expected ErrUserNotFound", err)
+	}
+}
+
+func TestPinnedPostsFirst(t *testing.T) {
+	var tests = []struct {
+		name     string
+		opts     []Option
+		pin      string
+		expected []string
+	}{
+		{name: "no pin", opts: []Option{WithPinnedPostsFirst()}, expected: sequence(5, 4, 1)},
+		{name: "oldest pinned", opts: []Option{WithPinnedPostsFirst()}, pin: sequence(1)[0], expected: sequence(1, 5, 4)},
+		{name: "middle pinned", opts: []Option{WithPinnedPostsFirst()}, pin: sequence(4)[0], expected: sequence(4, 5, 1)},
+		{name: "newest pinned", opts: []Option{WithPinnedPostsFirst()}, pin: sequence(5)[0], expected: sequence(5, 4, 1)},
+		{name: "without the option", pin: sequence(1)[0], expected: sequence(5, 4, 1)},
+	}
+	for _, test := range tests {
+		c, _ := newPinClient(t, test.opts...)
+		if test.pin != "" {
+			if _, err := c.PinPost(ctx, "a@example.com", test.pin); err != nil {
+				t.Fatal(err)
+			}
+		}
+		posts, err := c.GetPosts(ctx, "a@example.com")
+		if err != nil || !reflect.DeepEqual(postIDs(posts), test.expected) {
+			t.Errorf("%s: GetPosts() = %v, %v, expected %v", test.name, postIDs(posts), err, test.expected)
+			continue
+		}
+		for i, post := range posts {
+			flagged := i == 0 && test.pin != "" && test.opts != nil
+			if post.Pinned != flagged {
+				t.Errorf("%s: GetPosts()[%d].Pinned = %v, expected %v", test.name, i, post.Pinned, flagged)
+			}
+		}
+		// the flag is only on what GetPosts returns
+		if post, err := c.GetPost(ctx, posts[0].ID); err != nil || post.Pinned {
+			t.Errorf("%s: GetPost() = %+v, %v, expected it not flagged", test.name, post, err)
+		}
+	}
+}
+
+func TestDeletePinnedPost(t *testing.T) {
+	var tests = []struct {
+		name   string
+		delete func(c *Client, id string) error
+	}{
+		{name: "DeletePost", delete: func(c *Client, id string) error {
+			_, err := c.DeletePost(ctx, id)
+			return err
+		}},
+		{name: "SoftDeletePost", delete: func(c *Client, id string) error {
+			_, err := c.SoftDeletePost(ctx, id, "a@example.com")
+			return err
+		}},
+		{name: "SoftDeletePost by an admin", delete: func(c *Client, id string) error {
+			if _, err := c.BootstrapAdmin(ctx, "c@example.com"); err != nil {
+				return err
+			}
+			_, err := c.SoftDeletePost(ctx, id, "c@example.com")
+			return err
+		}},
+		{name: "DeletePost in a Tx", delete: func(c *Client, id string) error {
+			return c.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+				_, err := tx.DeletePost(ctx, id)
+				return err
+			})
+		}},
+	}
+	for _, test := range tests {
+		c, _ := newPinClient(t, WithPinnedPostsFirst())
+		pinned := sequence(1)[0]
+		if _, err := c.PinPost(ctx, "a@example.com", pinned); err != nil {
+			t.Fatal(err)
+		}
+		if err := test.delete(c, pinned); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		// on disk too, the pin went in the same write as the delete
+		for _, client := range []*Client{c, NewClient(dbPath(c))} {
+			if user, err := client.GetUser(ctx, "a@example.com"); err != nil || user.PinnedPostID != "" {
+				t.Errorf("%s: GetUser() = %+v, %v, expected no pin", test.name, user, err)
+			}
+		}
+		if posts, err := c.GetPosts(ctx, "a@example.com"); err != nil || !reflect.DeepEqual(postIDs(posts), sequence(5, 4)) {
+			t.Errorf("%s: GetPosts() = %v, %v, expected %v", test.name, postIDs(posts), err, sequence(5, 4))
+		}
+	}
+
+	// restoring the post doesn't pin it again
+	c, _ := newPinClient(t)
+	if _, err := c.PinPost(ctx, "a@example.com", sequence(4)[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SoftDeletePost(ctx, sequence(4)[0], "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.RestorePost(ctx, sequence(4)[0]); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := c.GetUser(ctx, "a@example.com"); err != nil || user.PinnedPostID != "" {
+		t.Errorf("GetUser() after RestorePost() = %+v, %v, expected no pin", user, err)
+	}
+	// deleting another post leaves the pin
+	if _, err := c.PinPost(ctx, "a@example.com", sequence(5)[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DeletePost(ctx, sequence(1)[0]); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := c.GetUser(ctx, "a@example.com"); err != nil || user.PinnedPostID != sequence(5)[0] {
+		t.Errorf("GetUser() after deleting another post = %+v, %v, expected %s pinned", user, err, sequence(5)[0])
+	}
+}
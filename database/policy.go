@@ -0,0 +1,140 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bcrypt ignores everything past 72 bytes, longer passwords are refused whatever the policy
+const maxPasswordBytes = 72
+
+// PasswordRule -
+// one requirement of a PasswordPolicy, what a PasswordError reports
+type PasswordRule string
+
+const (
+	PasswordTooShort    PasswordRule = "too short"
+	PasswordTooLong     PasswordRule = "too long"
+	PasswordNeedsUpper  PasswordRule = "needs an uppercase letter"
+	PasswordNeedsLower  PasswordRule = "needs a lowercase letter"
+	PasswordNeedsDigit  PasswordRule = "needs a digit"
+	PasswordNeedsSymbol PasswordRule = "needs a symbol"
+	PasswordTooCommon   PasswordRule = "too common"
+)
+
+// PasswordPolicy -
+// what a new password has to look like, checked by CreateUser, UpdateUser and the like
+// passwords already stored aren't affected until they're changed
+type PasswordPolicy struct {
+	// MinLength counts characters (runes), not bytes
+	MinLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// DenyList is refused regardless of case
+	DenyList []string
+}
+
+// CommonPasswords -
+// some of the most used passwords, the deny-list of DefaultPasswordPolicy
+var CommonPasswords = []string{
+	"123456", "12345678", "123456789", "1234567890", "12345", "1234567", "111111", "000000",
+	"password", "password1", "password123", "qwerty", "qwerty123", "qwertyuiop", "abc123",
+	"iloveyou", "admin", "welcome", "letmein", "monkey", "dragon", "football", "baseball",
+	"sunshine", "princess", "master", "shadow", "superman", "trustno1", "1q2w3e4r", "123123",
+}
+
+// DefaultPasswordPolicy -
+// what a client enforces unless WithPasswordPolicy says otherwise
+var DefaultPasswordPolicy = PasswordPolicy{MinLength: 8, DenyList: CommonPasswords}
+
+// defaultPasswordPolicy is what newOptions starts from, tests relax it like defaultPasswordCost
+var defaultPasswordPolicy = DefaultPasswordPolicy
+
+// PasswordError -
+// a password broke one or more rules of the policy, errors.Is(err, ErrWeakPassword) holds for it
+type PasswordError struct {
+	// Rules are all the rules that failed, in the order of PasswordPolicy's fields
+	Rules  []PasswordRule
+	policy PasswordPolicy
+}
+
+func (e *PasswordError) Error() string {
+	reasons := make([]string, 0, len(e.Rules))
+	for _, rule := range e.Rules {
+		switch rule {
+		case PasswordTooShort:
+			reasons = append(reasons, fmt.Sprintf("%s, needs at least %d characters", rule, e.policy.MinLength))
+		case PasswordTooLong:
+			reasons = append(reasons, fmt.Sprintf("%s, can be at most %d bytes", rule, maxPasswordBytes))
+		default:
+			reasons = append(reasons, string(rule))
+		}
+	}
+	return fmt.Sprintf("%v: %s", ErrWeakPassword, strings.Join(reasons, "; "))
+}
+
+func (e *PasswordError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+// Check -
+// nil if password satisfies the policy, a *PasswordError listing every rule it breaks otherwise
+func (p PasswordPolicy) Check(password string) error {
+	failed := []PasswordRule{}
+	if utf8.RuneCountInString(password) < p.MinLength {
+		failed = append(failed, PasswordTooShort)
+	}
+	if len(password) > maxPasswordBytes {
+		failed = append(failed, PasswordTooLong)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	for _, class := range []struct {
+		required, found bool
+		rule            PasswordRule
+	}{
+		{p.RequireUpper, upper, PasswordNeedsUpper},
+		{p.RequireLower, lower, PasswordNeedsLower},
+		{p.RequireDigit, digit, PasswordNeedsDigit},
+		{p.RequireSymbol, symbol, PasswordNeedsSymbol},
+	} {
+		if class.required && !class.found {
+			failed = append(failed, class.rule)
+		}
+	}
+
+	for _, denied := range p.DenyList {
+		if strings.EqualFold(password, denied) {
+			failed = append(failed, PasswordTooCommon)
+			break
+		}
+	}
+	if len(failed) > 0 {
+		return &PasswordError{Rules: failed, policy: p}
+	}
+	return nil
+}
+
+// newPasswordHash checks a password being set against policy and hashes it at cost
+func newPasswordHash(password string, policy PasswordPolicy, cost int) (string, error) {
+	if err := policy.Check(password); err != nil {
+		return "", err
+	}
+	return HashPassword(password, cost)
+}
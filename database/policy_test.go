@@ -0,0 +1,114 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	var tests = []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		expected []PasswordRule // nil means it passes
+	}{
+		{name: "no rules", policy: PasswordPolicy{}, password: ""},
+		{name: "long enough", policy: PasswordPolicy{MinLength: 8}, password: "abcdefgh"},
+		{name: "too short", policy: PasswordPolicy{MinLength: 8}, password: "abcdefg", expected: []PasswordRule{PasswordTooShort}},
+		// 8 characters but 16 bytes, length is in runes
+		{name: "unicode long enough", policy: PasswordPolicy{MinLength: 8}, password: "пароль12"},
+		{name: "unicode too short", policy: PasswordPolicy{MinLength: 8}, password: "密码密码密码", expected: []PasswordRule{PasswordTooShort}},
+		{name: "too long for bcrypt", policy: PasswordPolicy{}, password: strings.Repeat("a", 73), expected: []PasswordRule{PasswordTooLong}},
+		{name: "upper", policy: PasswordPolicy{RequireUpper: true}, password: "abc", expected: []PasswordRule{PasswordNeedsUpper}},
+		{name: "unicode upper", policy: PasswordPolicy{RequireUpper: true}, password: "Ёж"},
+		{name: "lower", policy: PasswordPolicy{RequireLower: true}, password: "ABC", expected: []PasswordRule{PasswordNeedsLower}},
+		{name: "digit", policy: PasswordPolicy{RequireDigit: true}, password: "abc", expected: []PasswordRule{PasswordNeedsDigit}},
+		{name: "symbol", policy: PasswordPolicy{RequireSymbol: true}, password: "abc1", expected: []PasswordRule{PasswordNeedsSymbol}},
+		{name: "has symbol", policy: PasswordPolicy{RequireSymbol: true}, password: "abc€"},
+		{name: "deny-list", policy: PasswordPolicy{DenyList: []string{"password"}}, password: "PassWord", expected: []PasswordRule{PasswordTooCommon}},
+		{name: "not on deny-list", policy: PasswordPolicy{DenyList: []string{"password"}}, password: "password!"},
+		{
+			name:     "combination",
+			policy:   PasswordPolicy{MinLength: 10, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true, DenyList: CommonPasswords},
+			password: "abc123",
+			expected: []PasswordRule{PasswordTooShort, PasswordNeedsUpper, PasswordNeedsSymbol, PasswordTooCommon},
+		},
+		{
+			name:     "combination passes",
+			policy:   PasswordPolicy{MinLength: 10, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true, DenyList: CommonPasswords},
+			password: "Tr0ub4dor&3x",
+		},
+		{name: "default too short", policy: DefaultPasswordPolicy, password: "1234", expected: []PasswordRule{PasswordTooShort}},
+		{name: "default common", policy: DefaultPasswordPolicy, password: "password123", expected: []PasswordRule{PasswordTooCommon}},
+		{name: "default", policy: DefaultPasswordPolicy, password: "correct horse"},
+	}
+
+	for _, test := range tests {
+		err := test.policy.Check(test.password)
+		if test.expected == nil {
+			if err != nil {
+				t.Errorf("%s: Check(%q) = %v, expected nil", test.name, test.password, err)
+			}
+			continue
+		}
+		var perr *PasswordError
+		if !errors.As(err, &perr) || !errors.Is(err, ErrWeakPassword) {
+			t.Errorf("%s: Check(%q) = %v, expected a *PasswordError", test.name, test.password, err)
+			continue
+		}
+		if !reflect.DeepEqual(perr.Rules, test.expected) {
+			t.Errorf("%s: Check(%q) failed %v, expected %v", test.name, test.password, perr.Rules, test.expected)
+		}
+	}
+}
+
+func TestPasswordErrorMessage(t *testing.T) {
+	err := PasswordPolicy{MinLength: 8, RequireDigit: true}.Check("abc")
+	expected := "password doesn't meet the policy: too short, needs at least 8 characters; needs a digit"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Check() = %v, expected %q", err, expected)
+	}
+}
+
+func TestPasswordPolicyEnforced(t *testing.T) {
+	c := newTestClient(t)
+	c = NewClient(dbPath(c), WithPasswordPolicy(DefaultPasswordPolicy))
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("CreateUser() with a weak password = %v, expected ErrWeakPassword", err)
+	}
+	if _, err := c.UpsertUser(ctx, "test@example.com", "short", "john doe", 18); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("UpsertUser() with a weak password = %v, expected ErrWeakPassword", err)
+	}
+	if _, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UpdateUser(ctx, "test@example.com", "qwerty", "john doe", 18); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("UpdateUser() with a weak password = %v, expected ErrWeakPassword", err)
+	}
+	err := c.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		_, err := tx.CreateUser(ctx, "other@example.com", "letmein", "other", 18)
+		return err
+	})
+	if !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("Tx CreateUser() with a weak password = %v, expected ErrWeakPassword", err)
+	}
+	// the rejected update left the password alone
+	if err := c.VerifyPassword(ctx, "test@example.com", "correct horse"); err != nil {
+		t.Errorf("VerifyPassword() after the rejected update = %v, expected nil", err)
+	}
+}
+
+func TestDefaultPasswordPolicyApplies(t *testing.T) {
+	// the other tests relax the default, newOptions without them uses the real one
+	saved := defaultPasswordPolicy
+	defaultPasswordPolicy = DefaultPasswordPolicy
+	defer func() { defaultPasswordPolicy = saved }()
+
+	c := NewMemoryClient()
+	if _, err := c.CreateUser(ctx, "test@example.com", "", "john doe", 18); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("CreateUser() with an empty password = %v, expected ErrWeakPassword", err)
+	}
+}
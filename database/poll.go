@@ -0,0 +1,258 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// limits of a poll, see CreatePoll
+const (
+	MinPollOptions      = 2
+	MaxPollOptions      = 10
+	MaxPollOptionLength = 100
+	MaxPollDuration     = 7 * 24 * time.Hour
+)
+
+// Poll -
+// the choices of a post from CreatePoll, the post's text is the question. replaced as a whole like
+// Tags and never modified in place, so copies of a Post can share it
+type Poll struct {
+	Options []string `json:"options"`
+	// ClosesAt is when voting ends, Vote fails from then on
+	ClosesAt time.Time `json:"closesAt"`
+}
+
+// PollResults -
+// how a poll stands, see GetPollResults
+type PollResults struct {
+	Options []string `json:"options"`
+	// Counts are the votes each of Options has, in the same order
+	Counts   []int     `json:"counts"`
+	Total    int       `json:"total"`
+	ClosesAt time.Time `json:"closesAt"`
+	Closed   bool      `json:"closed"`
+	// Voted is whether the viewer voted, and Vote the index in Options of what for
+	Voted bool `json:"voted"`
+	Vote  int  `json:"vote"`
+}
+
+// GetPostOptions -
+// controls GetPostWithOptions, the zero value is what GetPost does
+type GetPostOptions struct {
+	// IncludePollResults sets PollResults on a poll, left out by default since a poll with many
+	// votes takes a while to count
+	IncludePollResults bool
+	// Viewer is whose vote PollResults shows, none when empty
+	Viewer string
+}
+
+// equalPolls reports whether a and b are both nil or the same poll
+func equalPolls(a, b *Poll) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return equalSlices(a.Options, b.Options) && a.ClosesAt.Equal(b.ClosesAt)
+}
+
+// pollOptions -
+// options trimmed, ErrInvalidPoll unless there are MinPollOptions to MaxPollOptions of them,
+// none empty, longer than MaxPollOptionLength or the same as another one but for case
+func pollOptions(options []string) ([]string, error) {
+	if len(options) < MinPollOptions || len(options) > MaxPollOptions {
+		return nil, fmt.Errorf("%w: %d options, %d to %d allowed", ErrInvalidPoll, len(options), MinPollOptions, MaxPollOptions)
+	}
+	trimmed := make([]string, 0, len(options))
+	seen := map[string]bool{}
+	for i, option := range options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			return nil, fmt.Errorf("%w: option %d is empty", ErrInvalidPoll, i+1)
+		}
+		if n := utf8.RuneCountInString(option); n > MaxPollOptionLength {
+			return nil, fmt.Errorf("%w: option %d has %d characters, at most %d", ErrInvalidPoll, i+1, n, MaxPollOptionLength)
+		}
+		folded := strings.ToLower(option)
+		if seen[folded] {
+			return nil, fmt.Errorf("%w: option %d %q is there twice", ErrInvalidPoll, i+1, option)
+		}
+		seen[folded] = true
+		trimmed = append(trimmed, option)
+	}
+	return trimmed, nil
+}
+
+// pollResults -
+// the results of post's poll at now with the vote of viewer, nil if it isn't a poll
+func (db *Schema) pollResults(post Post, viewer string, now time.Time) *PollResults {
+	if post.Poll == nil {
+		return nil
+	}
+	results := &PollResults{
+		Options:  append([]string{}, post.Poll.Options...),
+		Counts:   make([]int, len(post.Poll.Options)),
+		ClosesAt: post.Poll.ClosesAt,
+		Closed:   !now.Before(post.Poll.ClosesAt),
+	}
+	for _, vote := range db.PollVotes[post.ID] {
+		// a vote is always for one of the options, but the db can be edited by hand
+		if vote >= 0 && vote < len(results.Counts) {
+			results.Counts[vote]++
+			results.Total++
+		}
+	}
+	if viewer != "" {
+		results.Vote, results.Voted = db.PollVotes.get(post.ID, EmailKey(viewer))
+	}
+	return results
+}
+
+// CreatePoll -
+// same as Client.CreatePoll, inside the Tx
+func (tx *Tx) CreatePoll(ctx context.Context, authorEmail, question string, options []string, duration time.Duration) (Post, error) {
+	options, err := pollOptions(options)
+	if err != nil {
+		return Post{}, err
+	}
+	if duration <= 0 || duration > MaxPollDuration {
+		return Post{}, fmt.Errorf("%w: open for %v, at most %v", ErrInvalidPoll, duration, MaxPollDuration)
+	}
+	db, err := tx.schema()
+	if err != nil {
+		return Post{}, err
+	}
+	post, err := tx.CreatePostWithOptions(ctx, authorEmail, question, CreatePostOptions{})
+	if err != nil {
+		return Post{}, err
+	}
+	post.Poll = &Poll{Options: options, ClosesAt: post.CreatedAt.Add(duration)}
+	db.putPost(post)
+	return post, nil
+}
+
+// Vote -
+// same as Client.Vote, inside the Tx
+func (tx *Tx) Vote(ctx context.Context, postID, voterEmail string, optionIndex int) error {
+	_, err := tx.vote(postID, voterEmail, optionIndex)
+	return err
+}
+
+// vote -
+// Vote that also says whether anything changed
+func (tx *Tx) vote(postID, voterEmail string, optionIndex int) (bool, error) {
+	if postID == "" {
+		return false, ErrEmptyPostID
+	}
+	db, err := tx.schema()
+	if err != nil {
+		return false, err
+	}
+	voterEmail = EmailKey(voterEmail)
+	if err := tx.checkCanPost(db, voterEmail); err != nil {
+		return false, err
+	}
+	now := tx.now()
+	post, err := db.sharedPost(postID, voterEmail, now)
+	if err != nil {
+		return false, err
+	}
+	if post.Poll == nil {
+		return false, fmt.Errorf("%w: %s", ErrNotPoll, post.ID)
+	}
+	if !now.Before(post.Poll.ClosesAt) {
+		return false, fmt.Errorf("%w: %s closed at %s", ErrPollClosed, post.ID, post.Poll.ClosesAt.Format(time.RFC3339))
+	}
+	if optionIndex < 0 || optionIndex >= len(post.Poll.Options) {
+		return false, fmt.Errorf("%w: %d of %d options", ErrInvalidPollOption, optionIndex, len(post.Poll.Options))
+	}
+	if prev, ok := db.PollVotes.get(post.ID, voterEmail); ok && prev == optionIndex {
+		return false, nil
+	}
+	db.PollVotes.put(post.ID, voterEmail, optionIndex)
+	return true, nil
+}
+
+// CreatePoll -
+// create a post by the user with authorEmail asking question, trimmed like CreatePost's text, with
+// options to vote for that stay open for duration from now. the options are trimmed and there have to
+// be MinPollOptions to MaxPollOptions of them, none empty, longer than MaxPollOptionLength or given twice,
+// and duration has to be more than 0 and at most MaxPollDuration, ErrInvalidPoll if not. the user is
+// held to what CreatePost checks
+func (c *Client) CreatePoll(ctx context.Context, authorEmail, question string, options []string, duration time.Duration) (Post, error) {
+	post := Post{}
+	err := c.update(ctx, "CreatePoll", authorEmail, func(db *Schema) error {
+		var err error
+		post, err = c.newTx(db).CreatePoll(ctx, authorEmail, question, options, duration)
+		return err
+	})
+	if err != nil {
+		return Post{}, err
+	}
+	return post, nil
+}
+
+// Vote -
+// vote for the option at optionIndex, 0-based, in the poll of the post with postID as the user with
+// voterEmail. a user has one vote per poll: voting again moves it until the poll closes, voting for
+// the same option again is a no-op. voting through a repost votes in the poll it shares. the user is
+// held to what CreatePost checks and can only vote on what a listing with them as the Viewer would
+// show them. ErrEmptyPostID for an empty id, ErrPostNotFound if there's no such post, it's soft-deleted
+// or the user can't see it, ErrNotPoll if it isn't a poll, ErrPollClosed from its ClosesAt on and
+// ErrInvalidPollOption if there's no option at optionIndex
+func (c *Client) Vote(ctx context.Context, postID, voterEmail string, optionIndex int) error {
+	return c.update(ctx, "Vote", postID, func(db *Schema) error {
+		changed, err := c.newTx(db).vote(postID, voterEmail, optionIndex)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+}
+
+// GetPollResults -
+// the votes each option of the poll of the post with postID has and, with a viewerEmail, which one
+// that user voted for. a repost gives the results of the poll it shares. ErrEmptyPostID for an empty id,
+// ErrPostNotFound if there's no such post or it's soft-deleted, ErrNotPoll if it isn't a poll
+func (c *Client) GetPollResults(ctx context.Context, postID, viewerEmail string) (PollResults, error) {
+	if postID == "" {
+		return PollResults{}, ErrEmptyPostID
+	}
+	results := PollResults{}
+	err := c.view(ctx, "GetPollResults", postID, func(db *Schema) error {
+		post, err := db.reactedPost(postID)
+		if err != nil {
+			return err
+		}
+		if post.Poll == nil {
+			return fmt.Errorf("%w: %s", ErrNotPoll, post.ID)
+		}
+		results = *db.pollResults(post, viewerEmail, c.clock.Now())
+		return nil
+	})
+	if err != nil {
+		return PollResults{}, err
+	}
+	return results, nil
+}
+
+// GetPostWithOptions -
+// GetPost, with the poll results of a poll when opts asks for them
+func (c *Client) GetPostWithOptions(ctx context.Context, id string, opts GetPostOptions) (Post, error) {
+	post := Post{}
+	err := c.view(ctx, "GetPost", id, func(db *Schema) error {
+		var err error
+		if post, err = c.newTx(db).GetPost(ctx, id); err != nil {
+			return err
+		}
+		if opts.IncludePollResults {
+			post.PollResults = db.pollResults(post, opts.Viewer, c.clock.Now())
+		}
+		return nil
+	})
+	if err != nil {
+		return Post{}, err
+	}
+	return post, nil
+}
@@ -0,0 +1,208 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newPollClient is newBlockClient on a fakeClock with a@'s poll on "yes", "no" and "maybe", open for a day
+func newPollClient(t *testing.T, opts ...Option) (*Client, *fakeClock, Post) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newBlockClient(t, append([]Option{WithClock(clock)}, opts...)...)
+	poll, err := c.CreatePoll(ctx, "a@example.com", " tabs? ", []string{"yes", " no ", "maybe"}, 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, clock, poll
+}
+
+func TestCreatePoll(t *testing.T) {
+	c, clock, poll := newPollClient(t)
+	expected := &Poll{Options: []string{"yes", "no", "maybe"}, ClosesAt: clock.Now().Add(24 * time.Hour)}
+	if poll.Text != "tabs?" || !equalPolls(poll.Poll, expected) {
+		t.Errorf("CreatePoll() = %q with %+v, expected %q with %+v", poll.Text, poll.Poll, "tabs?", expected)
+	}
+	if got, err := c.GetPost(ctx, poll.ID); err != nil || !equalPolls(got.Poll, expected) {
+		t.Errorf("GetPost() of a poll = %+v, %v, expected %+v", got.Poll, err, expected)
+	}
+
+	many := strings.Split(strings.Repeat("x,", MaxPollOptions), ",")
+	for i := range many {
+		many[i] += strings.Repeat("y", i)
+	}
+	var tests = []struct {
+		question string
+		options  []string
+		duration time.Duration
+		expected error
+	}{
+		{question: "ok", options: []string{"a", "b"}, duration: MaxPollDuration, expected: nil},
+		{question: "ok", options: many[:MaxPollOptions], duration: time.Minute, expected: nil},
+		{question: "ok", options: many, duration: time.Minute, expected: ErrInvalidPoll},
+		{question: "ok", options: []string{"a"}, duration: time.Minute, expected: ErrInvalidPoll},
+		{question: "ok", options: []string{"a", " "}, duration: time.Minute, expected: ErrInvalidPoll},
+		{question: "ok", options: []string{"Yes", "yes "}, duration: time.Minute, expected: ErrInvalidPoll},
+		{question: "ok", options: []string{"a", strings.Repeat("b", MaxPollOptionLength+1)}, duration: time.Minute, expected: ErrInvalidPoll},
+		{question: "ok", options: []string{"a", "b"}, duration: 0, expected: ErrInvalidPoll},
+		{question: "ok", options: []string{"a", "b"}, duration: MaxPollDuration + time.Second, expected: ErrInvalidPoll},
+		{question: " ", options: []string{"a", "b"}, duration: time.Minute, expected: ErrEmptyPost},
+	}
+	for _, test := range tests {
+		if _, err := c.CreatePoll(ctx, "b@example.com", test.question, test.options, test.duration); !errors.Is(err, test.expected) {
+			t.Errorf("CreatePoll(%q, %q, %v) = %v, expected %v", test.question, test.options, test.duration, err, test.expected)
+		}
+	}
+	if _, err := c.CreatePoll(ctx, "missing@example.com", "ok", []string{"a", "b"}, time.Minute); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("CreatePoll() by a missing user = %v, expected ErrUserNotFound", err)
+	}
+}
+
+func TestVote(t *testing.T) {
+	c, clock, poll := newPollClient(t)
+	posts, err := c.GetPosts(ctx, "b@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		id       string
+		email    string
+		option   int
+		expected error
+	}{
+		{id: poll.ID, email: "b@example.com", option: 0, expected: nil},
+		// voting twice moves the one vote
+		{id: poll.ID, email: "b@example.com", option: 2, expected: nil},
+		{id: poll.ID, email: "b@example.com", option: 2, expected: nil},
+		{id: poll.ID, email: "a@example.com", option: 2, expected: nil},
+		{id: poll.ID, email: "c@example.com", option: 3, expected: ErrInvalidPollOption},
+		{id: poll.ID, email: "c@example.com", option: -1, expected: ErrInvalidPollOption},
+		{id: posts[0].ID, email: "c@example.com", option: 0, expected: ErrNotPoll},
+		{id: "missing", email: "c@example.com", option: 0, expected: ErrPostNotFound},
+		{id: "", email: "c@example.com", option: 0, expected: ErrEmptyPostID},
+		{id: poll.ID, email: "missing@example.com", option: 0, expected: ErrUserNotFound},
+	}
+	for _, test := range tests {
+		if err := c.Vote(ctx, test.id, test.email, test.option); !errors.Is(err, test.expected) {
+			t.Errorf("Vote(%q, %q, %d) = %v, expected %v", test.id, test.email, test.option, err, test.expected)
+		}
+	}
+	results, err := c.GetPollResults(ctx, poll.ID, "B@example.com")
+	if expected := []int{0, 0, 2}; err != nil || !reflect.DeepEqual(results.Counts, expected) || results.Total != 2 {
+		t.Errorf("GetPollResults() = %+v, %v, expected counts %v", results, err, expected)
+	}
+	if !results.Voted || results.Vote != 2 || results.Closed {
+		t.Errorf("GetPollResults() for b@ = %+v, expected their vote for 2 in an open poll", results)
+	}
+
+	// closed from ClosesAt on, the votes stay
+	clock.Advance(24 * time.Hour)
+	if err := c.Vote(ctx, poll.ID, "c@example.com", 1); !errors.Is(err, ErrPollClosed) {
+		t.Errorf("Vote() in a closed poll = %v, expected ErrPollClosed", err)
+	}
+	if err := c.Vote(ctx, poll.ID, "b@example.com", 0); !errors.Is(err, ErrPollClosed) {
+		t.Errorf("Vote() changed in a closed poll = %v, expected ErrPollClosed", err)
+	}
+	results, err = c.GetPollResults(ctx, poll.ID, "c@example.com")
+	if err != nil || !results.Closed || results.Voted || results.Total != 2 {
+		t.Errorf("GetPollResults() of a closed poll for c@ = %+v, %v, expected closed with 2 votes and none of theirs", results, err)
+	}
+	if _, err := c.GetPollResults(ctx, posts[0].ID, ""); !errors.Is(err, ErrNotPoll) {
+		t.Errorf("GetPollResults() of a post = %v, expected ErrNotPoll", err)
+	}
+}
+
+func TestPollResultsTally(t *testing.T) {
+	c, _, poll := newPollClient(t)
+	for _, email := range []string{"d@example.com", "e@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repost, err := c.Repost(ctx, "b@example.com", poll.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	votes := map[string]int{"a@example.com": 1, "b@example.com": 0, "c@example.com": 1, "d@example.com": 1, "e@example.com": 2}
+	for email, option := range votes {
+		if err := c.Vote(ctx, repost.ID, email, option); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var tests = []struct {
+		id       string
+		viewer   string
+		expected PollResults
+	}{
+		{id: poll.ID, viewer: "", expected: PollResults{Options: []string{"yes", "no", "maybe"}, Counts: []int{1, 3, 1}, Total: 5, ClosesAt: poll.Poll.ClosesAt}},
+		{id: poll.ID, viewer: "e@example.com", expected: PollResults{Options: []string{"yes", "no", "maybe"}, Counts: []int{1, 3, 1}, Total: 5, ClosesAt: poll.Poll.ClosesAt, Voted: true, Vote: 2}},
+		// the repost's votes were the original's
+		{id: repost.ID, viewer: "b@example.com", expected: PollResults{Options: []string{"yes", "no", "maybe"}, Counts: []int{1, 3, 1}, Total: 5, ClosesAt: poll.Poll.ClosesAt, Voted: true, Vote: 0}},
+	}
+	for _, test := range tests {
+		if results, err := c.GetPollResults(ctx, test.id, test.viewer); err != nil || !reflect.DeepEqual(results, test.expected) {
+			t.Errorf("GetPollResults(%q, %q) = %+v, %v, expected %+v", test.id, test.viewer, results, err, test.expected)
+		}
+	}
+
+	// GetPost leaves the results out unless asked
+	if post, err := c.GetPost(ctx, poll.ID); err != nil || post.PollResults != nil {
+		t.Errorf("GetPost() = %+v, %v, expected no results", post.PollResults, err)
+	}
+	if post, err := c.GetPostWithOptions(ctx, poll.ID, GetPostOptions{}); err != nil || post.PollResults != nil {
+		t.Errorf("GetPostWithOptions() by default = %+v, %v, expected no results", post.PollResults, err)
+	}
+	post, err := c.GetPostWithOptions(ctx, poll.ID, GetPostOptions{IncludePollResults: true, Viewer: "e@example.com"})
+	if err != nil || post.PollResults == nil || !reflect.DeepEqual(*post.PollResults, tests[1].expected) {
+		t.Errorf("GetPostWithOptions() with the results = %+v, %v, expected %+v", post.PollResults, err, tests[1].expected)
+	}
+
+	// the votes are saved with the db and replayed from the log, and a deleted user's are gone
+	if _, err := c.DeleteUser(ctx, "d@example.com", DeleteUserOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range [][]Option{nil, {WithWAL(100)}} {
+		if results, err := NewClient(dbPath(c), opts...).GetPollResults(ctx, poll.ID, ""); err != nil || !reflect.DeepEqual(results.Counts, []int{1, 2, 1}) {
+			t.Errorf("GetPollResults() from the file = %+v, %v, expected counts [1 2 1]", results, err)
+		}
+	}
+}
+
+func TestPollVotesCleanup(t *testing.T) {
+	c, _, poll := newPollClient(t, WithWAL(100))
+	for _, email := range []string{"b@example.com", "c@example.com"} {
+		if err := c.Vote(ctx, poll.ID, email, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Vote(ctx, poll.ID, "a@example.com", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new email takes its vote along, a merge keeps the one the primary had
+	if _, err := c.ChangeEmail(ctx, "c@example.com", "new@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if results, err := c.GetPollResults(ctx, poll.ID, "new@example.com"); err != nil || !results.Voted || results.Vote != 1 {
+		t.Errorf("GetPollResults() after ChangeEmail() = %+v, %v, expected their vote for 1", results, err)
+	}
+	if _, err := c.MergeUsers(ctx, "a@example.com", "new@example.com", MergeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if results, err := c.GetPollResults(ctx, poll.ID, "a@example.com"); err != nil || !reflect.DeepEqual(results.Counts, []int{1, 1, 0}) || results.Vote != 0 {
+		t.Errorf("GetPollResults() after MergeUsers() = %+v, %v, expected a@'s vote for 0 and b@'s for 1", results, err)
+	}
+
+	// a deleted poll takes its votes with it, in the log too
+	if _, err := c.DeletePost(ctx, poll.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, client := range []*Client{c, NewClient(dbPath(c), WithWAL(100))} {
+		if db, err := client.Dump(ctx); err != nil || len(db.PollVotes) != 0 {
+			t.Errorf("poll votes after DeletePost() = %v, %v, expected none", db.PollVotes, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// NewPost -
+// one post for CreatePostsBatch: who writes it, its text and the options CreatePostWithOptions
+// would take for it, CreatedAt for a post imported with its own time
+type NewPost struct {
+	UserEmail string
+	Text      string
+	CreatePostOptions
+}
+
+// BatchError -
+// a CreatePosts or CreatePostsBatch failure and the 0-based index of the entry that caused it
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("post %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// CreatePosts -
+// same as Client.CreatePosts, inside the Tx
+func (tx *Tx) CreatePosts(ctx context.Context, userEmail string, texts []string) ([]Post, error) {
+	posts := make([]NewPost, 0, len(texts))
+	for _, text := range texts {
+		posts = append(posts, NewPost{UserEmail: userEmail, Text: text})
+	}
+	return tx.CreatePostsBatch(ctx, posts)
+}
+
+// CreatePostsBatch -
+// same as Client.CreatePostsBatch, inside the Tx. the posts before a failure stay in the Tx,
+// it's up to the caller to roll it back
+func (tx *Tx) CreatePostsBatch(ctx context.Context, posts []NewPost) ([]Post, error) {
+	created := make([]Post, 0, len(posts))
+	for i, p := range posts {
+		// a big batch is a long write, bail out if the caller gave up
+		if i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return []Post{}, err
+			}
+		}
+		post, err := tx.CreatePostWithOptions(ctx, p.UserEmail, p.Text, p.CreatePostOptions)
+		if err != nil {
+			return []Post{}, &BatchError{Index: i, Err: err}
+		}
+		created = append(created, post)
+	}
+	return created, nil
+}
+
+// CreatePosts -
+// CreatePost of every one of texts by the user with userEmail in a single write, for an import
+// that would otherwise save the db once per post. the posts are returned in the order of texts
+func (c *Client) CreatePosts(ctx context.Context, userEmail string, texts []string) ([]Post, error) {
+	posts := make([]NewPost, 0, len(texts))
+	for _, text := range texts {
+		posts = append(posts, NewPost{UserEmail: userEmail, Text: text})
+	}
+	return c.CreatePostsBatch(ctx, posts)
+}
+
+// CreatePostsBatch -
+// CreatePostWithOptions of every one of posts in a single write, each checked like the one call
+// would and with its own ID, returned in the order of posts. all or nothing: the first entry that
+// fails leaves the db as it was, with a *BatchError naming its index that wraps the error
+// CreatePostWithOptions would have returned. no posts write nothing
+func (c *Client) CreatePostsBatch(ctx context.Context, posts []NewPost) ([]Post, error) {
+	if len(posts) == 0 {
+		return []Post{}, nil
+	}
+	created := []Post{}
+	err := c.update(ctx, "CreatePostsBatch", "", func(db *Schema) error {
+		var err error
+		created, err = c.newTx(db).CreatePostsBatch(ctx, posts)
+		return err
+	})
+	if err != nil {
+		return []Post{}, err
+	}
+	return created, nil
+}
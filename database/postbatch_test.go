@@ -0,0 +1,109 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newBatchStoreClient is a client on a fakeStore with users a@ and b@ and no posts
+func newBatchStoreClient(t *testing.T) (*Client, *fakeStore) {
+	t.Helper()
+	store := &fakeStore{}
+	c := NewClientWithStore(store, WithPasswordCost(4))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c, store
+}
+
+func TestCreatePosts(t *testing.T) {
+	c, store := newBatchStoreClient(t)
+	texts := []string{}
+	for i := 0; i < 10000; i++ {
+		texts = append(texts, fmt.Sprintf("post %d #batch", i))
+	}
+	saves := store.saves
+	posts, err := c.CreatePosts(ctx, "a@example.com", texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.saves != saves+1 {
+		t.Errorf("CreatePosts() saved %d times, expected once", store.saves-saves)
+	}
+	ids := map[string]bool{}
+	for i, post := range posts {
+		if post.Text != texts[i] || post.UserEmail != "a@example.com" || !reflect.DeepEqual(post.Tags, []string{"batch"}) {
+			t.Fatalf("CreatePosts()[%d] = %+v, expected %q by a@", i, post, texts[i])
+		}
+		ids[post.ID] = true
+	}
+	if len(posts) != len(texts) || len(ids) != len(texts) {
+		t.Errorf("CreatePosts() = %d posts with %d IDs, expected %d of each", len(posts), len(ids), len(texts))
+	}
+	if n, err := c.GetPostCount(ctx, "a@example.com", PostCountOptions{}); err != nil || n != len(texts) {
+		t.Errorf("GetPostCount() = %d, %v, expected %d", n, err, len(texts))
+	}
+	if page, err := c.GetPostsByHashtag(ctx, "batch", ListOptions{}); err != nil || page.Total != len(texts) {
+		t.Errorf("GetPostsByHashtag() total = %d, %v, expected %d", page.Total, err, len(texts))
+	}
+
+	// nothing at all writes nothing
+	saves = store.saves
+	if posts, err := c.CreatePosts(ctx, "a@example.com", nil); err != nil || len(posts) != 0 || store.saves != saves {
+		t.Errorf("CreatePosts(nil) = %v, %v with %d saves, expected no posts and no save", posts, err, store.saves-saves)
+	}
+}
+
+func TestCreatePostsBatchAllOrNothing(t *testing.T) {
+	c, store := newBatchStoreClient(t)
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		posts    []NewPost
+		index    int
+		expected error
+	}{
+		{posts: []NewPost{{UserEmail: "a@example.com", Text: "1"}, {UserEmail: "a@example.com", Text: "2"}, {UserEmail: "a@example.com", Text: "  "}}, index: 2, expected: ErrEmptyPost},
+		{posts: []NewPost{{UserEmail: "missing@example.com", Text: "1"}, {UserEmail: "a@example.com", Text: "2"}}, index: 0, expected: ErrUserNotFound},
+		{posts: []NewPost{{UserEmail: "a@example.com", Text: "1"}, {UserEmail: "b@example.com", Text: "2", CreatePostOptions: CreatePostOptions{Visibility: "friends"}}}, index: 1, expected: ErrInvalidVisibility},
+		{posts: []NewPost{{UserEmail: "a@example.com", Text: "1", CreatePostOptions: CreatePostOptions{Attachments: []Attachment{{URL: "nope"}}}}}, index: 0, expected: ErrInvalidAttachment},
+		{posts: []NewPost{{UserEmail: "a@example.com", Text: "1"}, {UserEmail: "b@example.com", Text: "a reply", CreatePostOptions: CreatePostOptions{ParentID: "missing"}}}, index: 1, expected: ErrPostNotFound},
+	}
+	saves := store.saves
+	for _, test := range tests {
+		posts, err := c.CreatePostsBatch(ctx, test.posts)
+		var batchErr *BatchError
+		if !errors.As(err, &batchErr) || batchErr.Index != test.index || !errors.Is(err, test.expected) || len(posts) != 0 {
+			t.Errorf("CreatePostsBatch(%+v) = %v, %v, expected a BatchError at %d wrapping %v", test.posts, posts, err, test.index, test.expected)
+		}
+	}
+	if all, err := c.GetAllPosts(ctx, ListOptions{}); err != nil || len(all) != 0 || store.saves != saves {
+		t.Errorf("after the failed batches = %d posts, %v, %d saves, expected none", len(all), err, store.saves-saves)
+	}
+
+	// a good batch keeps each entry's author, time and options
+	posts, err := c.CreatePostsBatch(ctx, []NewPost{
+		{UserEmail: "a@example.com", Text: "old", CreatePostOptions: CreatePostOptions{CreatedAt: at}},
+		{UserEmail: "B@example.com", Text: "followers", CreatePostOptions: CreatePostOptions{Visibility: VisibilityFollowers}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !posts[0].CreatedAt.Equal(at) || posts[1].UserEmail != "b@example.com" || posts[1].Visibility != VisibilityFollowers {
+		t.Errorf("CreatePostsBatch() = %+v, expected the entries' times, authors and options", posts)
+	}
+	reply, err := c.CreatePostsBatch(ctx, []NewPost{{UserEmail: "b@example.com", Text: "reply", CreatePostOptions: CreatePostOptions{ParentID: posts[0].ID}}})
+	if err != nil || reply[0].ParentID != posts[0].ID {
+		t.Errorf("CreatePostsBatch() of a reply = %+v, %v, expected a reply to %s", reply, err, posts[0].ID)
+	}
+	if store.saves != saves+2 {
+		t.Errorf("two good batches saved %d times, expected 2", store.saves-saves)
+	}
+}
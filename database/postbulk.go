@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DeletePostsOptions -
+// controls DeletePostsByUser, the zero value deletes every post of the user
+type DeletePostsOptions struct {
+	// Before, when set, only deletes the posts created before it
+	Before time.Time
+	// Match, when set, only deletes the posts it returns true for. it's called while the client
+	// is locked for the write, so it mustn't call the client
+	Match func(Post) bool
+}
+
+// DeletePostsByUser -
+// same as Client.DeletePostsByUser, inside the Tx
+func (tx *Tx) DeletePostsByUser(ctx context.Context, email string, opts DeletePostsOptions) (int, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return 0, err
+	}
+	email = EmailKey(email)
+	if _, ok := db.Users[email]; !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+	// picked first, deletePost changes Authored
+	ids := []string{}
+	for id := range db.Authored[email] {
+		post := db.Posts[id]
+		if !opts.Before.IsZero() && !post.CreatedAt.Before(opts.Before) {
+			continue
+		}
+		if opts.Match != nil && !opts.Match(post) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		db.deletePost(id)
+	}
+	return len(ids), nil
+}
+
+// DeletePostsByUser -
+// delete the posts of the user with email for good in a single write, how many there were. drafts,
+// scheduled and soft-deleted posts go too, opts narrows it down. like DeletePost each post leaves
+// the indexes and takes its edit history with it, and its reposts and replies stay behind.
+// the user can be soft-deleted or deactivated, ErrUserNotFound if there's no such user at all.
+// a user with no posts to delete gets 0 and nothing is written
+func (c *Client) DeletePostsByUser(ctx context.Context, email string, opts DeletePostsOptions) (int, error) {
+	count := 0
+	err := c.deleteUpdate(ctx, "DeletePostsByUser", email, func(db *Schema) error {
+		var err error
+		count, err = c.newTx(db).DeletePostsByUser(ctx, email, opts)
+		if err == nil && count == 0 {
+			return errNoop
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
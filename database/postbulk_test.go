@@ -0,0 +1,127 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newBulkClient is newBlockClient where a@ has an edited #go post, a draft and a soft-deleted
+// post on top of "hello", the posts two hours apart from CreatedAt 2024-01-01, and b@ has a #go post too
+func newBulkClient(t *testing.T) (*Client, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newBlockClient(t, WithClock(clock))
+	clock.Advance(2 * time.Hour)
+	edited, err := c.CreatePost(ctx, "a@example.com", "learning #go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UpdatePost(ctx, edited.ID, "a@example.com", "still learning #go"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Hour)
+	if _, err := c.CreateDraft(ctx, "a@example.com", "draft"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Hour)
+	deleted, err := c.CreatePost(ctx, "a@example.com", "oops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SoftDeletePost(ctx, deleted.ID, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreatePost(ctx, "b@example.com", "#go from b"); err != nil {
+		t.Fatal(err)
+	}
+	return c, clock
+}
+
+func TestDeletePostsByUser(t *testing.T) {
+	c, _ := newBulkClient(t)
+	deleted := []string{}
+	c.OnPostDeleted(func(post Post) { deleted = append(deleted, post.ID) })
+	writes := c.Metrics().Writes
+
+	if n, err := c.DeletePostsByUser(ctx, "A@example.com", DeletePostsOptions{}); err != nil || n != 4 {
+		t.Fatalf("DeletePostsByUser() = %d, %v, expected 4", n, err)
+	}
+	if got := c.Metrics().Writes - writes; got != 1 {
+		t.Errorf("DeletePostsByUser() took %d writes, expected 1", got)
+	}
+	if len(deleted) != 4 {
+		t.Errorf("OnPostDeleted() got %d posts, expected 4", len(deleted))
+	}
+	if n, err := c.GetPostCount(ctx, "a@example.com", PostCountOptions{IncludeDrafts: true, IncludeDeleted: true}); err != nil || n != 0 {
+		t.Errorf("GetPostCount() after DeletePostsByUser() = %d, %v, expected 0", n, err)
+	}
+	if n, err := c.GetPostCount(ctx, "b@example.com", PostCountOptions{}); err != nil || n != 2 {
+		t.Errorf("GetPostCount() of someone else = %d, %v, expected 2", n, err)
+	}
+
+	// nothing of a@'s is left in the indexes or the edit history
+	if posts, err := c.SearchPosts(ctx, "learning", SearchOptions{}); err != nil || len(posts) != 0 {
+		t.Errorf("SearchPosts() of a deleted post = %v, %v, expected none", postIDs(posts), err)
+	}
+	if page, err := c.GetPostsByHashtag(ctx, "go", ListOptions{}); err != nil || len(page.Posts) != 1 || page.Posts[0].UserEmail != "b@example.com" {
+		t.Errorf("GetPostsByHashtag() = %+v, %v, expected only b@'s post", page.Posts, err)
+	}
+	if db, err := c.Dump(ctx); err != nil || len(db.PostEdits) != 0 {
+		t.Errorf("edit histories after DeletePostsByUser() = %v, %v, expected none", db.PostEdits, err)
+	}
+	checkPostIndex(t, c, "after DeletePostsByUser")
+
+	// again there's nothing to delete, which isn't an error
+	if n, err := c.DeletePostsByUser(ctx, "a@example.com", DeletePostsOptions{}); err != nil || n != 0 {
+		t.Errorf("DeletePostsByUser() with no posts = %d, %v, expected 0", n, err)
+	}
+	if _, err := c.DeletePostsByUser(ctx, "missing@example.com", DeletePostsOptions{}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("DeletePostsByUser() of a missing user = %v, expected ErrUserNotFound", err)
+	}
+}
+
+func TestDeletePostsByUserFiltered(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		opts     DeletePostsOptions
+		expected int
+		left     []string
+	}{
+		{opts: DeletePostsOptions{Before: start.Add(3 * time.Hour)}, expected: 2, left: []string{"draft", "oops"}},
+		{opts: DeletePostsOptions{Before: start}, expected: 0, left: []string{"hello", "still learning #go", "draft", "oops"}},
+		{opts: DeletePostsOptions{Match: func(post Post) bool { return post.DeletedAt != nil || post.IsDraft }}, expected: 2, left: []string{"hello", "still learning #go"}},
+		{opts: DeletePostsOptions{Before: start.Add(3 * time.Hour), Match: func(post Post) bool { return strings.Contains(post.Text, "#go") }}, expected: 1, left: []string{"hello", "draft", "oops"}},
+	}
+	for _, test := range tests {
+		c, _ := newBulkClient(t)
+		if n, err := c.DeletePostsByUser(ctx, "a@example.com", test.opts); err != nil || n != test.expected {
+			t.Errorf("DeletePostsByUser(%+v) = %d, %v, expected %d", test.opts, n, err, test.expected)
+		}
+		page, err := c.GetAllPostsPage(ctx, ListOptions{Authors: []string{"a@example.com"}, IncludeDeleted: true, Order: OldestFirst})
+		if err != nil {
+			t.Fatal(err)
+		}
+		left := []string{}
+		for _, post := range page.Posts {
+			left = append(left, post.Text)
+		}
+		drafts, err := c.GetDrafts(ctx, "a@example.com", "a@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, post := range drafts {
+			left = append(left, post.Text)
+		}
+		expected := append([]string{}, test.left...)
+		sort.Strings(left)
+		sort.Strings(expected)
+		if !reflect.DeepEqual(left, expected) {
+			t.Errorf("DeletePostsByUser(%+v) left %q, expected %q", test.opts, left, test.left)
+		}
+		checkPostIndex(t, c, "after a filtered DeletePostsByUser")
+	}
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PostCountOptions -
+// controls GetPostCount and GetPostCounts, the zero value counts what GetPosts lists
+type PostCountOptions struct {
+	// IncludeDrafts counts drafts and posts scheduled for later too
+	IncludeDrafts bool
+	// IncludeDeleted counts soft-deleted posts too
+	IncludeDeleted bool
+}
+
+// postCount -
+// how many posts the user with email has at now that opts counts, from Authored rather than
+// by scanning every post. ErrUserNotFound if there's no such user or it was soft-deleted
+func (db *Schema) postCount(email string, opts PostCountOptions, now time.Time) (int, error) {
+	if _, ok := db.activeUser(email); !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+	count := 0
+	for id := range db.Authored[email] {
+		post := db.Posts[id]
+		if (post.DeletedAt == nil || opts.IncludeDeleted) && (!post.unpublished(now) || opts.IncludeDrafts) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetPostCount -
+// how many posts the user with email has, for a profile header, without reading them. reposts and
+// replies count, drafts, scheduled posts and soft-deleted posts only when opts says. a deactivated
+// user's posts are counted though GetPosts hides them. ErrUserNotFound if there's no such user or it was soft-deleted
+func (c *Client) GetPostCount(ctx context.Context, email string, opts PostCountOptions) (int, error) {
+	email = EmailKey(email)
+	count := 0
+	now := c.clock.Now()
+	err := c.view(ctx, "GetPostCount", email, func(db *Schema) error {
+		var err error
+		count, err = db.postCount(email, opts, now)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetPostCounts -
+// GetPostCount for many users at once, read together so the counts match, keyed by EmailKey of
+// each email. ErrUserNotFound for the first email with no user, nothing is returned then
+func (c *Client) GetPostCounts(ctx context.Context, emails []string, opts PostCountOptions) (map[string]int, error) {
+	counts := make(map[string]int, len(emails))
+	now := c.clock.Now()
+	err := c.view(ctx, "GetPostCounts", "", func(db *Schema) error {
+		for _, email := range emails {
+			email = EmailKey(email)
+			count, err := db.postCount(email, opts, now)
+			if err != nil {
+				return err
+			}
+			counts[email] = count
+		}
+		return nil
+	})
+	if err != nil {
+		return map[string]int{}, err
+	}
+	return counts, nil
+}
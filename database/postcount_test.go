@@ -0,0 +1,129 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetPostCount(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newBlockClient(t, WithClock(clock))
+	post, err := c.CreatePost(ctx, "a@example.com", "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all := PostCountOptions{IncludeDrafts: true, IncludeDeleted: true}
+	var tests = []struct {
+		name     string
+		change   func() error
+		expected [3]int
+	}{
+		{name: "created", change: func() error { return nil }, expected: [3]int{2, 2, 2}},
+		{name: "draft", change: func() error {
+			_, err := c.CreateDraft(ctx, "a@example.com", "draft")
+			return err
+		}, expected: [3]int{2, 3, 3}},
+		{name: "scheduled", change: func() error {
+			_, err := c.SchedulePost(ctx, "a@example.com", "later", clock.Now().Add(time.Hour))
+			return err
+		}, expected: [3]int{2, 4, 4}},
+		{name: "due", change: func() error {
+			clock.Advance(time.Hour)
+			return nil
+		}, expected: [3]int{3, 4, 4}},
+		{name: "soft-deleted", change: func() error {
+			_, err := c.SoftDeletePost(ctx, post.ID, "a@example.com")
+			return err
+		}, expected: [3]int{2, 3, 4}},
+		{name: "restored", change: func() error {
+			_, err := c.RestorePost(ctx, post.ID)
+			return err
+		}, expected: [3]int{3, 4, 4}},
+		{name: "deleted", change: func() error {
+			_, err := c.DeletePost(ctx, post.ID)
+			return err
+		}, expected: [3]int{2, 3, 3}},
+		{name: "reposted", change: func() error {
+			posts, err := c.GetPosts(ctx, "b@example.com")
+			if err != nil {
+				return err
+			}
+			_, err = c.Repost(ctx, "a@example.com", posts[0].ID)
+			return err
+		}, expected: [3]int{3, 4, 4}},
+	}
+	for _, test := range tests {
+		if err := test.change(); err != nil {
+			t.Fatal(err)
+		}
+		got := [3]int{}
+		for i, opts := range []PostCountOptions{{}, {IncludeDrafts: true}, all} {
+			if got[i], err = c.GetPostCount(ctx, "A@example.com", opts); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got != test.expected {
+			t.Errorf("%s: GetPostCount() = %v, expected %v", test.name, got, test.expected)
+		}
+	}
+	if posts, err := c.GetPosts(ctx, "a@example.com"); err != nil || len(posts) != 3 {
+		t.Errorf("GetPosts() = %d posts, %v, expected as many as GetPostCount", len(posts), err)
+	}
+	// the index is built again from the file
+	if n, err := NewClient(dbPath(c), WithClock(clock)).GetPostCount(ctx, "a@example.com", all); err != nil || n != 4 {
+		t.Errorf("GetPostCount() from the file = %d, %v, expected 4", n, err)
+	}
+
+	if _, err := c.SoftDeleteUser(ctx, "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{"missing@example.com", "c@example.com"} {
+		if _, err := c.GetPostCount(ctx, email, PostCountOptions{}); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("GetPostCount(%q) = %v, expected ErrUserNotFound", email, err)
+		}
+	}
+	checkPostIndex(t, c, "after the changes")
+}
+
+func TestGetPostCounts(t *testing.T) {
+	c := newBlockClient(t)
+	if _, err := c.CreatePost(ctx, "b@example.com", "again"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, "d@example.com", "123456", "name d", 18); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		emails   []string
+		expected map[string]int
+		err      error
+	}{
+		{emails: []string{"a@example.com", "B@example.com", "d@example.com"}, expected: map[string]int{"a@example.com": 1, "b@example.com": 2, "d@example.com": 0}},
+		{emails: []string{"b@example.com", "b@example.com"}, expected: map[string]int{"b@example.com": 2}},
+		{emails: nil, expected: map[string]int{}},
+		{emails: []string{"a@example.com", "missing@example.com"}, expected: map[string]int{}, err: ErrUserNotFound},
+	}
+	for _, test := range tests {
+		counts, err := c.GetPostCounts(ctx, test.emails, PostCountOptions{})
+		if !errors.Is(err, test.err) || !reflect.DeepEqual(counts, test.expected) {
+			t.Errorf("GetPostCounts(%v) = %v, %v, expected %v, %v", test.emails, counts, err, test.expected, test.err)
+		}
+	}
+
+	// the counts go with the posts
+	if _, err := c.ChangeEmail(ctx, "b@example.com", "new@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DeleteUser(ctx, "a@example.com", DeleteUserOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	counts, err := c.GetPostCounts(ctx, []string{"new@example.com", "c@example.com"}, PostCountOptions{})
+	if expected := map[string]int{"new@example.com": 2, "c@example.com": 1}; err != nil || !reflect.DeepEqual(counts, expected) {
+		t.Errorf("GetPostCounts() after ChangeEmail() and DeleteUser() = %v, %v, expected %v", counts, err, expected)
+	}
+	checkPostIndex(t, c, "after the moves")
+}
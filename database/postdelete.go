@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultDeletedPostRetention -
+// how long a soft-deleted post can be restored unless WithDeletedPostRetention says otherwise
+const DefaultDeletedPostRetention = 30 * 24 * time.Hour
+
+// livePost -
+// the post with id unless there's none or it was soft-deleted, what GetPost and the
+// other lookups by ID go through
+func (db *Schema) livePost(id string) (Post, bool) {
+	post, ok := db.Posts[id]
+	if !ok || post.DeletedAt != nil {
+		return Post{}, false
+	}
+	return post, true
+}
+
+// checkCanChangePost -
+// nil if requesterEmail may change post: its author, held to what CreatePost checks, or an active admin.
+// a draft only by its author
+func (tx *Tx) checkCanChangePost(db *Schema, post Post, requesterEmail string) error {
+	requesterEmail = EmailKey(requesterEmail)
+	if requesterEmail == post.UserEmail {
+		return tx.checkCanPost(db, requesterEmail)
+	}
+	if post.IsDraft {
+		return fmt.Errorf("%w: %s didn't write draft %s", ErrNotPostAuthor, requesterEmail, post.ID)
+	}
+	// an unknown or deactivated requester is refused the same as any other non-author
+	if requester, ok := db.activeUser(requesterEmail); !ok || !requester.Active() || !requester.IsAdmin() {
+		return fmt.Errorf("%w: %s didn't write post %s", ErrNotPostAuthor, requesterEmail, post.ID)
+	}
+	return nil
+}
+
+// SoftDeletePost -
+// same as Client.SoftDeletePost, inside the Tx
+func (tx *Tx) SoftDeletePost(ctx context.Context, id, requesterEmail string) (Post, error) {
+	if id == "" {
+		return Post{}, ErrEmptyPostID
+	}
+	db, err := tx.schema()
+	if err != nil {
+		return Post{}, err
+	}
+	post, ok := db.livePost(id)
+	if !ok {
+		return Post{}, fmt.Errorf("%w: %s", ErrPostNotFound, id)
+	}
+	if err := tx.checkCanChangePost(db, post, requesterEmail); err != nil {
+		return Post{}, err
+	}
+	now := tx.now()
+	post.DeletedAt = &now
+	db.putPost(post)
+	// restoring it doesn't pin it again
+	db.unpin(post)
+	return post, nil
+}
+
+// RestorePost -
+// same as Client.RestorePost, inside the Tx
+func (tx *Tx) RestorePost(ctx context.Context, id string) (Post, error) {
+	if id == "" {
+		return Post{}, ErrEmptyPostID
+	}
+	db, err := tx.schema()
+	if err != nil {
+		return Post{}, err
+	}
+	post, ok := db.Posts[id]
+	if !ok {
+		return Post{}, fmt.Errorf("%w: %s", ErrPostNotFound, id)
+	}
+	if post.DeletedAt == nil {
+		return post, nil
+	}
+	if tx.now().Sub(*post.DeletedAt) > tx.postRetention {
+		return Post{}, fmt.Errorf("%w: post %s was deleted at %v", ErrRestoreExpired, id, *post.DeletedAt)
+	}
+	post.DeletedAt = nil
+	db.putPost(post)
+	return post, nil
+}
+
+// SoftDeletePost -
+// mark the post with id deleted on behalf of requesterEmail, who has to be its author or an admin
+// like UpdatePost, and keep the record. it's left out of GetPost, GetPosts, the listings and feeds
+// unless they're asked to include deleted posts. RestorePost undoes it within WithDeletedPostRetention,
+// PurgeDeletedPosts removes it for good and DeletePost right away. ErrEmptyPostID for an empty id,
+// ErrPostNotFound if there's no such post or it already was, ErrNotPostAuthor if the requester may not delete it
+func (c *Client) SoftDeletePost(ctx context.Context, id, requesterEmail string) (Post, error) {
+	post := Post{}
+	err := c.deleteUpdate(ctx, "SoftDeletePost", id, func(db *Schema) error {
+		var err error
+		post, err = c.newTx(db).SoftDeletePost(ctx, id, requesterEmail)
+		return err
+	})
+	if err != nil {
+		return Post{}, err
+	}
+	return post, nil
+}
+
+// RestorePost -
+// bring back a post removed by SoftDeletePost, ErrRestoreExpired if that was longer ago than
+// WithDeletedPostRetention. restoring a post that isn't deleted changes nothing
+func (c *Client) RestorePost(ctx context.Context, id string) (Post, error) {
+	post := Post{}
+	err := c.update(ctx, "RestorePost", id, func(db *Schema) error {
+		deleted := db.Posts[id].DeletedAt != nil
+		var err error
+		post, err = c.newTx(db).RestorePost(ctx, id)
+		if err == nil && !deleted {
+			return errNoop
+		}
+		return err
+	})
+	if err != nil {
+		return Post{}, err
+	}
+	return post, nil
+}
+
+// GetPostIncludingDeleted -
+// GetPost that also finds soft-deleted posts, check DeletedAt to tell them apart
+func (c *Client) GetPostIncludingDeleted(ctx context.Context, id string) (Post, error) {
+	if id == "" {
+		return Post{}, ErrEmptyPostID
+	}
+	post := Post{}
+	err := c.view(ctx, "GetPostIncludingDeleted", id, func(db *Schema) error {
+		var ok bool
+		if post, ok = db.Posts[id]; !ok {
+			return fmt.Errorf("%w: %s", ErrPostNotFound, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return Post{}, err
+	}
+	return post, nil
+}
+
+// PurgeDeletedPosts -
+// permanently delete every post soft-deleted at least olderThan ago, 0 purges them all, along with
+// their edit history. returns how many posts were purged, all in one write and nothing written if there are none
+func (c *Client) PurgeDeletedPosts(ctx context.Context, olderThan time.Duration) (int, error) {
+	purged := 0
+	err := c.deleteUpdate(ctx, "PurgeDeletedPosts", "", func(db *Schema) error {
+		cutoff := c.newTx(db).now().Add(-olderThan)
+		i := 0
+		for id, post := range db.Posts {
+			// full scan, bail out if the caller gave up
+			if i++; i%cancelCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			if post.DeletedAt != nil && !post.DeletedAt.After(cutoff) {
+				db.deletePost(id)
+				purged++
+			}
+		}
+		if purged == 0 {
+			return errNoop
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
@@ -0,0 +1,254 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSoftDeletePost(t *testing.T) {
+	c := newBlockClient(t)
+	if _, err := c.BootstrapAdmin(ctx, "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]string{}
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		posts, err := c.GetPosts(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[email] = posts[0].ID
+	}
+
+	var tests = []struct {
+		id        string
+		requester string
+		expected  error
+	}{
+		{id: ids["a@example.com"], requester: "b@example.com", expected: ErrNotPostAuthor},
+		{id: "missing", requester: "a@example.com", expected: ErrPostNotFound},
+		{id: "", requester: "a@example.com", expected: ErrEmptyPostID},
+		{id: ids["a@example.com"], requester: " A@example.com", expected: nil},
+		// deleting it again finds nothing to delete
+		{id: ids["a@example.com"], requester: "a@example.com", expected: ErrPostNotFound},
+		// admins can delete anyone's post
+		{id: ids["b@example.com"], requester: "c@example.com", expected: nil},
+	}
+	for _, test := range tests {
+		post, err := c.SoftDeletePost(ctx, test.id, test.requester)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("SoftDeletePost(%q, %q) = %v, expected %v", test.id, test.requester, err, test.expected)
+		}
+		if err == nil && (post.ID != test.id || post.DeletedAt == nil) {
+			t.Errorf("SoftDeletePost(%q, %q) = %+v, expected it with DeletedAt set", test.id, test.requester, post)
+		}
+	}
+
+	// the record is kept, the file included
+	for _, client := range []*Client{c, NewClient(dbPath(c))} {
+		post, err := client.GetPostIncludingDeleted(ctx, ids["a@example.com"])
+		if err != nil || post.DeletedAt == nil || post.Text != "hello" {
+			t.Errorf("GetPostIncludingDeleted() = %+v, %v, expected the deleted post", post, err)
+		}
+	}
+	if _, err := c.UpdatePost(ctx, ids["a@example.com"], "a@example.com", "edited"); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("UpdatePost() of a deleted post = %v, expected ErrPostNotFound", err)
+	}
+}
+
+func TestSoftDeletedPostVisibility(t *testing.T) {
+	c := newBlockClient(t)
+	kept, err := c.CreatePost(ctx, "a@example.com", "kept")
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts, err := c.GetPosts(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted := posts[0]
+	if deleted.equal(kept) {
+		deleted = posts[1]
+	}
+	if _, err := c.SoftDeletePost(ctx, deleted.ID, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	page := func(opts ListOptions) func() ([]Post, error) {
+		return func() ([]Post, error) {
+			page, err := c.GetPostsPage(ctx, "a@example.com", opts)
+			return page.Posts, err
+		}
+	}
+	iterate := func(opts IterateOptions) func() ([]Post, error) {
+		return func() ([]Post, error) {
+			posts := []Post{}
+			err := c.IteratePosts(ctx, opts, func(post Post) bool {
+				posts = append(posts, post)
+				return true
+			})
+			return posts, err
+		}
+	}
+	byIDs := func() ([]Post, error) {
+		posts, _, err := c.GetPostsByIDs(ctx, []string{kept.ID, deleted.ID})
+		return posts, err
+	}
+	var tests = []struct {
+		name     string
+		get      func() ([]Post, error)
+		expected []string
+	}{
+		{name: "GetPosts", get: func() ([]Post, error) {
+			return c.GetPosts(ctx, "a@example.com")
+		}, expected: []string{kept.ID}},
+		{name: "GetPostsAs", get: func() ([]Post, error) {
+			return c.GetPostsAs(ctx, "b@example.com", "a@example.com")
+		}, expected: []string{kept.ID}},
+		{name: "GetPostsBetween", get: func() ([]Post, error) {
+			return c.GetPostsBetween(ctx, "a@example.com", time.Time{}, time.Time{})
+		}, expected: []string{kept.ID}},
+		{name: "GetPostsPage", get: page(ListOptions{}), expected: []string{kept.ID}},
+		{name: "GetPostsPage IncludeDeleted", get: page(ListOptions{IncludeDeleted: true}), expected: []string{kept.ID, deleted.ID}},
+		{name: "GetAllPosts", get: func() ([]Post, error) {
+			return c.GetAllPosts(ctx, ListOptions{Authors: []string{"a@example.com"}})
+		}, expected: []string{kept.ID}},
+		{name: "IteratePosts", get: iterate(IterateOptions{UserEmail: "a@example.com"}), expected: []string{kept.ID}},
+		{name: "IteratePosts IncludeDeleted", get: iterate(IterateOptions{UserEmail: "a@example.com", IncludeDeleted: true}), expected: []string{kept.ID, deleted.ID}},
+		{name: "GetPostsByIDs", get: byIDs, expected: []string{kept.ID}},
+	}
+	for _, test := range tests {
+		posts, err := test.get()
+		got := postIDs(posts)
+		// IteratePosts has no order
+		if len(got) == 2 && got[0] != test.expected[0] {
+			got[0], got[1] = got[1], got[0]
+		}
+		if err != nil || !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s = %v, %v, expected %v", test.name, got, err, test.expected)
+		}
+	}
+
+	if public, err := c.GetPublicPosts(ctx, "a@example.com"); err != nil || len(public) != 1 || public[0].ID != kept.ID {
+		t.Errorf("GetPublicPosts() = %+v, %v, expected only %s", public, err, kept.ID)
+	}
+	if _, err := c.GetPost(ctx, deleted.ID); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("GetPost() of a deleted post = %v, expected ErrPostNotFound", err)
+	}
+	if _, err := c.GetPostWithAuthor(ctx, deleted.ID); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("GetPostWithAuthor() of a deleted post = %v, expected ErrPostNotFound", err)
+	}
+	for _, test := range []struct {
+		opts     ExistsOptions
+		expected bool
+	}{
+		{opts: ExistsOptions{}, expected: false},
+		{opts: ExistsOptions{IncludeDeleted: true}, expected: true},
+	} {
+		if exists, err := c.PostExists(ctx, deleted.ID, test.opts); err != nil || exists != test.expected {
+			t.Errorf("PostExists(%+v) of a deleted post = %v, %v, expected %v", test.opts, exists, err, test.expected)
+		}
+	}
+}
+
+func TestRestorePost(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newBlockClient(t, WithClock(clock), WithDeletedPostRetention(24*time.Hour))
+	posts, err := c.GetPosts(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := posts[0].ID
+
+	// restoring a post that isn't deleted changes nothing
+	if post, err := c.RestorePost(ctx, id); err != nil || !post.equal(posts[0]) {
+		t.Errorf("RestorePost() of a live post = %+v, %v, expected %+v", post, err, posts[0])
+	}
+	if _, err := c.RestorePost(ctx, "missing"); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("RestorePost() of a missing post = %v, expected ErrPostNotFound", err)
+	}
+
+	var tests = []struct {
+		after    time.Duration
+		expected error
+	}{
+		{after: time.Hour, expected: nil},
+		{after: 24 * time.Hour, expected: nil},
+		{after: 24*time.Hour + time.Second, expected: ErrRestoreExpired},
+	}
+	for _, test := range tests {
+		if _, err := c.SoftDeletePost(ctx, id, "a@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(test.after)
+		post, err := c.RestorePost(ctx, id)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("RestorePost() %v after = %v, expected %v", test.after, err, test.expected)
+		}
+		if err == nil && !post.equal(posts[0]) {
+			t.Errorf("RestorePost() %v after = %+v, expected %+v", test.after, post, posts[0])
+		}
+		// and GetPosts sees it again, or still doesn't
+		got, _ := c.GetPosts(ctx, "a@example.com")
+		if (len(got) == 1) != (test.expected == nil) {
+			t.Errorf("GetPosts() after RestorePost() %v after = %+v", test.after, got)
+		}
+	}
+}
+
+func TestPurgeDeletedPosts(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newBlockClient(t, WithClock(clock))
+	ids := map[string]string{}
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		posts, err := c.GetPosts(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[email] = posts[0].ID
+	}
+	// a@'s post was edited, the history goes with it
+	if _, err := c.UpdatePost(ctx, ids["a@example.com"], "a@example.com", "edited"); err != nil {
+		t.Fatal(err)
+	}
+	// a@'s deleted 48h ago, b@'s exactly 24h ago, c@'s is live
+	if _, err := c.SoftDeletePost(ctx, ids["a@example.com"], "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(24 * time.Hour)
+	if _, err := c.SoftDeletePost(ctx, ids["b@example.com"], "b@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(24 * time.Hour)
+
+	var tests = []struct {
+		olderThan time.Duration
+		expected  int
+		left      []string
+	}{
+		{olderThan: 72 * time.Hour, expected: 0, left: []string{"a@example.com", "b@example.com", "c@example.com"}},
+		{olderThan: 24*time.Hour + time.Second, expected: 1, left: []string{"b@example.com", "c@example.com"}},
+		// the cutoff itself is included
+		{olderThan: 24 * time.Hour, expected: 1, left: []string{"c@example.com"}},
+		{olderThan: 0, expected: 0, left: []string{"c@example.com"}},
+	}
+	for _, test := range tests {
+		purged, err := c.PurgeDeletedPosts(ctx, test.olderThan)
+		if err != nil || purged != test.expected {
+			t.Errorf("PurgeDeletedPosts(%v) = %d, %v, expected %d", test.olderThan, purged, err, test.expected)
+		}
+		for _, email := range test.left {
+			if _, err := c.GetPostIncludingDeleted(ctx, ids[email]); err != nil {
+				t.Errorf("PurgeDeletedPosts(%v) removed the post of %s: %v", test.olderThan, email, err)
+			}
+		}
+	}
+	db, err := c.Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.Posts) != 1 || len(db.PostEdits) != 0 {
+		t.Errorf("after purging = %d posts and %d edit histories, expected 1 and 0", len(db.Posts), len(db.PostEdits))
+	}
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultPostEditHistoryLimit is how many edits are kept per post without WithPostEditHistoryLimit
+const DefaultPostEditHistoryLimit = 10
+
+// PostEdit -
+// a text a post had before UpdatePost replaced it, so moderators can see what it used to say, and
+// the attachments it had then. the current ones stay in the Post, there's no way to write one directly
+type PostEdit struct {
+	Text        string       `json:"text"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// EditedAt is when the text was replaced
+	EditedAt time.Time `json:"editedAt"`
+}
+
+// equalEdits -
+// whether a and b are the same history, comparing the Attachments of each edit by what's in them
+func equalEdits(a, b []PostEdit) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Text != b[i].Text || !a[i].EditedAt.Equal(b[i].EditedAt) || !equalSlices(a[i].Attachments, b[i].Attachments) {
+			return false
+		}
+	}
+	return true
+}
+
+// PostWithEdits -
+// a post and its edit history, see GetPostWithEdits
+type PostWithEdits struct {
+	Post
+	// Edits are the post's earlier texts oldest first, empty if it was never edited
+	Edits []PostEdit `json:"edits"`
+}
+
+// putPostEdits -
+// store edits for the post with id, keeping the newest limit of them. the slice is always a new one
+// like putNameHistory's
+func (db *Schema) putPostEdits(id string, edits []PostEdit, limit int) {
+	if len(edits) > limit {
+		edits = edits[len(edits)-limit:]
+	}
+	if len(edits) == 0 {
+		delete(db.PostEdits, id)
+		return
+	}
+	if db.PostEdits == nil {
+		db.PostEdits = make(map[string][]PostEdit)
+	}
+	db.PostEdits[id] = append([]PostEdit{}, edits...)
+}
+
+// deletePost -
+// remove the post with id along with its edit history and its words and tags in the indexes, and unpin it
+func (db *Schema) deletePost(id string) {
+	if post, ok := db.Posts[id]; ok {
+		db.unpin(post)
+		db.indexPost(post, true)
+	}
+	delete(db.Posts, id)
+	delete(db.PostEdits, id)
+	delete(db.Reactions, id)
+	delete(db.PollVotes, id)
+}
+
+// recordPostEdit -
+// add the text and attachments old had before the edit at editedAt to its history
+func (tx *Tx) recordPostEdit(old Post, editedAt time.Time) {
+	edits := append(append([]PostEdit{}, tx.db.PostEdits[old.ID]...), PostEdit{Text: old.Text, Attachments: old.Attachments, EditedAt: editedAt})
+	tx.db.putPostEdits(old.ID, edits, tx.postEdits)
+}
+
+// GetPostEditHistory -
+// the texts the post with id had before each UpdatePost, oldest first, at most
+// WithPostEditHistoryLimit of them. ErrEmptyPostID for an empty id, ErrPostNotFound if there's no such post
+func (c *Client) GetPostEditHistory(ctx context.Context, id string) ([]PostEdit, error) {
+	post, err := c.GetPostWithEdits(ctx, id)
+	if err != nil {
+		return []PostEdit{}, err
+	}
+	return post.Edits, nil
+}
+
+// GetPostWithEdits -
+// GetPost along with the post's edit history, read together so they match. GetPost leaves the
+// history out since it can be a lot bigger than the post
+func (c *Client) GetPostWithEdits(ctx context.Context, id string) (PostWithEdits, error) {
+	post := PostWithEdits{}
+	err := c.view(ctx, "GetPostWithEdits", id, func(db *Schema) error {
+		found, err := c.newTx(db).GetPost(ctx, id)
+		if err != nil {
+			return err
+		}
+		post = PostWithEdits{Post: found, Edits: append([]PostEdit{}, db.PostEdits[id]...)}
+		return nil
+	})
+	if err != nil {
+		return PostWithEdits{}, err
+	}
+	return post, nil
+}
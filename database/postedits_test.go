@@ -0,0 +1,114 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// editTexts is the Text of each edit, in order
+func editTexts(edits []PostEdit) []string {
+	texts := []string{}
+	for _, edit := range edits {
+		texts = append(texts, edit.Text)
+	}
+	return texts
+}
+
+func TestPostEditHistory(t *testing.T) {
+	for name, opts := range map[string][]Option{"file": nil, "wal": {WithWAL(100)}} {
+		clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		c := newTestClient(t, append([]Option{WithClock(clock)}, opts...)...)
+		if _, err := c.CreateUser(ctx, "test@example.com", "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+		post, err := c.CreatePost(ctx, "test@example.com", "first")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the same text again isn't an edit
+		for _, text := range []string{"second", "second", "third", "fourth"} {
+			clock.Advance(time.Minute)
+			if _, err := c.UpdatePost(ctx, post.ID, "test@example.com", text); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		expected := []PostEdit{
+			{Text: "first", EditedAt: time.Date(2024, 1, 1, 0, 1, 0, 0, time.UTC)},
+			{Text: "second", EditedAt: time.Date(2024, 1, 1, 0, 3, 0, 0, time.UTC)},
+			{Text: "third", EditedAt: time.Date(2024, 1, 1, 0, 4, 0, 0, time.UTC)},
+		}
+		reopened := NewClient(dbPath(c), opts...)
+		for _, client := range []*Client{c, reopened} {
+			edits, err := client.GetPostEditHistory(ctx, post.ID)
+			if err != nil || !reflect.DeepEqual(edits, expected) {
+				t.Errorf("%s: GetPostEditHistory() = %+v, %v, expected %+v", name, edits, err, expected)
+			}
+			withEdits, err := client.GetPostWithEdits(ctx, post.ID)
+			if err != nil || withEdits.Text != "fourth" || !reflect.DeepEqual(withEdits.Edits, expected) {
+				t.Errorf("%s: GetPostWithEdits() = %+v, %v, expected fourth and %+v", name, withEdits, err, expected)
+			}
+		}
+
+		// what's returned is a copy
+		edits, _ := c.GetPostEditHistory(ctx, post.ID)
+		edits[0].Text = "forged"
+		if again, _ := c.GetPostEditHistory(ctx, post.ID); again[0].Text != "first" {
+			t.Errorf("%s: GetPostEditHistory() after changing what it returned = %+v, expected it unchanged", name, again)
+		}
+
+		// and it goes with the post
+		if _, err := c.DeletePost(ctx, post.ID); err != nil {
+			t.Fatal(err)
+		}
+		reopened = NewClient(dbPath(c), opts...)
+		for _, client := range []*Client{c, reopened} {
+			if _, err := client.GetPostEditHistory(ctx, post.ID); !errors.Is(err, ErrPostNotFound) {
+				t.Errorf("%s: GetPostEditHistory() of a deleted post = %v, expected ErrPostNotFound", name, err)
+			}
+			if db, _ := client.Dump(ctx); len(db.PostEdits) != 0 {
+				t.Errorf("%s: edits left after DeletePost: %+v", name, db.PostEdits)
+			}
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPostEditHistoryLimit(t *testing.T) {
+	var tests = []struct {
+		limit    int
+		expected []string
+	}{
+		{limit: 2, expected: []string{"text 3", "text 4"}},
+		{limit: 10, expected: []string{"text 0", "text 1", "text 2", "text 3", "text 4"}},
+		{limit: 0, expected: []string{}},
+	}
+	for _, test := range tests {
+		c := newTestClient(t, WithPostEditHistoryLimit(test.limit))
+		if _, err := c.CreateUser(ctx, "test@example.com", "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+		post, err := c.CreatePost(ctx, "test@example.com", "text 0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i <= 5; i++ {
+			if _, err := c.UpdatePost(ctx, post.ID, "test@example.com", fmt.Sprintf("text %d", i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		edits, err := c.GetPostEditHistory(ctx, post.ID)
+		if err != nil || !reflect.DeepEqual(editTexts(edits), test.expected) {
+			t.Errorf("limit %d: GetPostEditHistory() = %v, %v, expected %v", test.limit, editTexts(edits), err, test.expected)
+		}
+		// GetPost leaves them out
+		if got, err := c.GetPost(ctx, post.ID); err != nil || got.Text != "text 5" {
+			t.Errorf("limit %d: GetPost() = %+v, %v, expected text 5", test.limit, got, err)
+		}
+	}
+}
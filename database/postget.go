@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// AuthorProfile -
+// the public part of a post's author, what a permalink page shows next to the post. no email
+type AuthorProfile struct {
+	Username  string `json:"username,omitempty"`
+	Name      string `json:"name"`
+	Bio       string `json:"bio,omitempty"`
+	AvatarURL string `json:"avatarUrl,omitempty"`
+	Location  string `json:"location,omitempty"`
+	Website   string `json:"website,omitempty"`
+}
+
+// PostWithAuthor -
+// a post and its author's profile, see GetPostWithAuthor
+type PostWithAuthor struct {
+	Post
+	// Author is nil if the author was deleted, soft-deleted or deactivated
+	Author *AuthorProfile `json:"author"`
+}
+
+// GetPost -
+// same as Client.GetPost, sees posts created earlier in the Tx
+func (tx *Tx) GetPost(ctx context.Context, id string) (Post, error) {
+	if id == "" {
+		return Post{}, ErrEmptyPostID
+	}
+	db, err := tx.schema()
+	if err != nil {
+		return Post{}, err
+	}
+	post, ok := db.livePost(id)
+	if !ok {
+		return Post{}, fmt.Errorf("%w: %s", ErrPostNotFound, id)
+	}
+	return post, nil
+}
+
+// authorProfile -
+// the profile of the author of post, nil if they can't be shown
+func (db *Schema) authorProfile(post Post) *AuthorProfile {
+	user, ok := db.activeUser(post.UserEmail)
+	if !ok || !user.Active() {
+		return nil
+	}
+	return &AuthorProfile{
+		Username:  user.Username,
+		Name:      user.Name,
+		Bio:       user.Bio,
+		AvatarURL: user.AvatarURL,
+		Location:  user.Location,
+		Website:   user.Website,
+	}
+}
+
+// GetPost -
+// the post with id, looked up directly rather than by scanning. like DeletePost it's found whoever
+// wrote it, GetPostsAs is the one that checks what a viewer may see. ErrEmptyPostID for an empty id,
+// ErrPostNotFound if there's no such post or it was soft-deleted, see GetPostIncludingDeleted
+func (c *Client) GetPost(ctx context.Context, id string) (Post, error) {
+	post := Post{}
+	err := c.view(ctx, "GetPost", id, func(db *Schema) error {
+		var err error
+		post, err = c.newTx(db).GetPost(ctx, id)
+		return err
+	})
+	if err != nil {
+		return Post{}, err
+	}
+	return post, nil
+}
+
+// GetPostWithAuthor -
+// GetPost along with the author's public profile, read together so they match. a post whose
+// author was deleted, soft-deleted or deactivated is still returned, with a nil Author
+func (c *Client) GetPostWithAuthor(ctx context.Context, id string) (PostWithAuthor, error) {
+	post := PostWithAuthor{}
+	err := c.view(ctx, "GetPostWithAuthor", id, func(db *Schema) error {
+		found, err := c.newTx(db).GetPost(ctx, id)
+		if err != nil {
+			return err
+		}
+		post = PostWithAuthor{Post: found, Author: db.authorProfile(found)}
+		return nil
+	})
+	if err != nil {
+		return PostWithAuthor{}, err
+	}
+	return post, nil
+}
+
+// GetPostsByIDs -
+// GetPost for many ids at once, the posts found in the order of ids and the ids that weren't,
+// in order too. an id given twice is looked up twice and an empty or soft-deleted one is missing, so the call
+// only fails if the db can't be read
+func (c *Client) GetPostsByIDs(ctx context.Context, ids []string) ([]Post, []string, error) {
+	posts, missing := []Post{}, []string{}
+	err := c.view(ctx, "GetPostsByIDs", "", func(db *Schema) error {
+		for _, id := range ids {
+			if post, ok := db.livePost(id); ok && id != "" {
+				posts = append(posts, post)
+			} else {
+				missing = append(missing, id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []Post{}, []string{}, err
+	}
+	return posts, missing, nil
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// postIDs is the ID of every post in posts, in order
+func postIDs(posts []Post) []string {
+	ids := []string{}
+	for _, post := range posts {
+		ids = append(ids, post.ID)
+	}
+	return ids
+}
+
+func TestGetPost(t *testing.T) {
+	c := newBlockClient(t)
+	posts, err := c.GetPosts(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		id       string
+		expected error
+	}{
+		{id: posts[0].ID, expected: nil},
+		{id: "missing", expected: ErrPostNotFound},
+		{id: "", expected: ErrEmptyPostID},
+	}
+	for _, test := range tests {
+		post, err := c.GetPost(ctx, test.id)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("GetPost(%q) = %v, expected %v", test.id, err, test.expected)
+		}
+		if err == nil && !post.equal(posts[0]) {
+			t.Errorf("GetPost(%q) = %+v, expected %+v", test.id, post, posts[0])
+		}
+	}
+}
+
+func TestGetPostWithAuthor(t *testing.T) {
+	c := newBlockClient(t)
+	if _, err := c.SetUsername(ctx, "a@example.com", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	bio := "hi there"
+	if _, err := c.UpdateProfile(ctx, "a@example.com", Profile{Bio: &bio}); err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]string{}
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		posts, err := c.GetPosts(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[email] = posts[0].ID
+	}
+	// b@ goes but leaves its posts behind, c@ is deactivated
+	if _, err := c.DeleteUser(ctx, "b@example.com", DeleteUserOptions{Posts: PostsKeep}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DeactivateUser(ctx, "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		id       string
+		expected *AuthorProfile
+	}{
+		{id: ids["a@example.com"], expected: &AuthorProfile{Username: "alice", Name: "name a", Bio: "hi there"}},
+		{id: ids["b@example.com"], expected: nil},
+		{id: ids["c@example.com"], expected: nil},
+	}
+	for _, test := range tests {
+		post, err := c.GetPostWithAuthor(ctx, test.id)
+		if err != nil || post.ID != test.id || !reflect.DeepEqual(post.Author, test.expected) {
+			t.Errorf("GetPostWithAuthor(%q) = %+v, %v, expected author %+v", test.id, post, err, test.expected)
+		}
+	}
+	if _, err := c.GetPostWithAuthor(ctx, "missing"); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("GetPostWithAuthor() of a missing post = %v, expected ErrPostNotFound", err)
+	}
+}
+
+func TestGetPostsByIDs(t *testing.T) {
+	c := newBlockClient(t)
+	ids := []string{}
+	for _, email := range []string{"c@example.com", "a@example.com", "b@example.com"} {
+		posts, err := c.GetPosts(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, posts[0].ID)
+	}
+
+	var tests = []struct {
+		ids      []string
+		found    []string
+		expected []string
+	}{
+		{ids: ids, found: ids, expected: []string{}},
+		{ids: []string{ids[1], "missing", ids[0], "", ids[1]}, found: []string{ids[1], ids[0], ids[1]}, expected: []string{"missing", ""}},
+		{ids: []string{"x", "y"}, found: []string{}, expected: []string{"x", "y"}},
+		{ids: nil, found: []string{}, expected: []string{}},
+	}
+	for _, test := range tests {
+		posts, missing, err := c.GetPostsByIDs(ctx, test.ids)
+		if err != nil || !reflect.DeepEqual(postIDs(posts), test.found) || !reflect.DeepEqual(missing, test.expected) {
+			t.Errorf("GetPostsByIDs(%v) = %v, %v, %v, expected %v and %v missing", test.ids, postIDs(posts), missing, err, test.found, test.expected)
+		}
+	}
+}
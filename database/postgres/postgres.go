@@ -0,0 +1,364 @@
+// Package postgres is a PostgreSQL backed implementation of database.Repository
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Warren-Wang-OG/go-social-media-backend/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// postgres error codes translated into the package's sentinel errors
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// Client -
+// same operations as database.Client, stored in PostgreSQL
+type Client struct {
+	db *sql.DB
+}
+
+var _ database.Repository = (*Client)(nil)
+
+// NewPostgresClient -
+// connect to the database described by dsn (a postgres:// url or key=value string)
+// the connection is checked by EnsureDB, not here
+func NewPostgresClient(dsn string) (*Client, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{db: db}, nil
+}
+
+// Close -
+// close every connection in the pool
+func (c *Client) Close() error {
+	return c.db.Close()
+}
+
+// migrations are idempotent so EnsureDB can run them on every start
+// posts.author_email is the foreign key that makes CreatePost fail for unknown users,
+// it's cleared when the user is deleted so PostsKeep and PostsAnonymize can outlive the author.
+// posts.user_email is the author callers see and never references anything.
+// users.updated_at came later, rows from before it are NULL and read as created_at
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		email      TEXT PRIMARY KEY,
+		password   TEXT NOT NULL,
+		name       TEXT NOT NULL,
+		age        INTEGER NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS posts (
+		id           TEXT PRIMARY KEY,
+		user_email   TEXT NOT NULL,
+		author_email TEXT REFERENCES users (email) ON DELETE SET NULL,
+		text         TEXT NOT NULL,
+		created_at   TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS posts_user_email ON posts (user_email)`,
+	`CREATE INDEX IF NOT EXISTS posts_author_email ON posts (author_email)`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ`,
+}
+
+// EnsureDB -
+// create the tables and indexes if they don't exist yet
+func (c *Client) EnsureDB(ctx context.Context) error {
+	return c.tx(ctx, func(tx *sql.Tx) error {
+		for _, migration := range migrations {
+			if _, err := tx.ExecContext(ctx, migration); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CreateUser -
+// email needs to be unique for each user, returns database.ErrUserExists if it's already taken
+// the password has to satisfy database.DefaultPasswordPolicy like the json Client's, a *database.PasswordError otherwise
+// and the age must be database.DefaultMinimumAge to database.MaxAge, database.ErrInvalidAge otherwise
+// the user is returned without PasswordHash, see VerifyPassword
+func (c *Client) CreateUser(ctx context.Context, email, password, name string, age int) (database.User, error) {
+	email, err := database.NormalizeEmail(email)
+	if err != nil {
+		return database.User{}, err
+	}
+	if err := database.CheckAge(age, database.DefaultMinimumAge); err != nil {
+		return database.User{}, err
+	}
+	if err := database.DefaultPasswordPolicy.Check(password); err != nil {
+		return database.User{}, err
+	}
+	hash, err := database.HashPassword(password, database.DefaultPasswordCost)
+	if err != nil {
+		return database.User{}, err
+	}
+	createdAt := now()
+	user := database.User{
+		CreatedAt:    createdAt,
+		Email:        email,
+		PasswordHash: hash,
+		Name:         name,
+		Age:          age,
+		UpdatedAt:    createdAt,
+	}
+	_, err = c.db.ExecContext(ctx,
+		`INSERT INTO users (email, password, name, age, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		user.Email, user.PasswordHash, user.Name, user.Age, user.CreatedAt, user.UpdatedAt)
+	if isCode(err, uniqueViolation) {
+		return database.User{}, fmt.Errorf("%w: %s", database.ErrUserExists, email)
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+	return sanitize(user), nil
+}
+
+// GetUser -
+// return user given the email, without PasswordHash
+func (c *Client) GetUser(ctx context.Context, email string) (database.User, error) {
+	email = database.EmailKey(email)
+	user, err := c.getUser(ctx, email)
+	if err != nil {
+		return database.User{}, err
+	}
+	return sanitize(user), nil
+}
+
+// VerifyPassword -
+// nil if password is the user's, database.ErrWrongPassword if not and database.ErrUserNotFound
+// if there's no such user, the stored hash is never returned so this is the way to check it
+func (c *Client) VerifyPassword(ctx context.Context, email, password string) error {
+	email = database.EmailKey(email)
+	user, err := c.getUser(ctx, email)
+	if err != nil {
+		return err
+	}
+	if !database.CheckPassword(user.PasswordHash, password) {
+		return fmt.Errorf("%w: %s", database.ErrWrongPassword, email)
+	}
+	return nil
+}
+
+// getUser is the user with the email key as stored, PasswordHash included
+func (c *Client) getUser(ctx context.Context, email string) (database.User, error) {
+	return scanUser(c.db.QueryRowContext(ctx,
+		`SELECT email, password, name, age, created_at, COALESCE(updated_at, created_at) FROM users WHERE email = $1`, email), email)
+}
+
+// UpdateUser -
+// change password, name and age of an existing user, email and CreatedAt don't change
+// the new password and age are checked and the user returned like CreateUser's
+func (c *Client) UpdateUser(ctx context.Context, email, password, name string, age int) (database.User, error) {
+	email, err := database.NormalizeEmail(email)
+	if err != nil {
+		return database.User{}, err
+	}
+	if err := database.CheckAge(age, database.DefaultMinimumAge); err != nil {
+		return database.User{}, err
+	}
+	if err := database.DefaultPasswordPolicy.Check(password); err != nil {
+		return database.User{}, err
+	}
+	hash, err := database.HashPassword(password, database.DefaultPasswordCost)
+	if err != nil {
+		return database.User{}, err
+	}
+	user, err := scanUser(c.db.QueryRowContext(ctx,
+		`UPDATE users SET password = $2, name = $3, age = $4, updated_at = $5 WHERE email = $1
+		RETURNING email, password, name, age, created_at, updated_at`,
+		email, hash, name, age, now()), email)
+	if err != nil {
+		return database.User{}, err
+	}
+	return sanitize(user), nil
+}
+
+// DeleteUser -
+// delete a user and handle their posts according to opts, in one transaction
+func (c *Client) DeleteUser(ctx context.Context, email string, opts database.DeleteUserOptions) (database.DeleteUserResult, error) {
+	email = database.EmailKey(email)
+	result := database.DeleteUserResult{}
+	err := c.tx(ctx, func(tx *sql.Tx) error {
+		// lock the user row so no post can be added between counting and deleting
+		var found string
+		err := tx.QueryRowContext(ctx, `SELECT email FROM users WHERE email = $1 FOR UPDATE`, email).Scan(&found)
+		if errors.Is(err, sql.ErrNoRows) {
+			if opts.IgnoreMissing {
+				return nil
+			}
+			return fmt.Errorf("%w: %s", database.ErrUserNotFound, email)
+		}
+		if err != nil {
+			return err
+		}
+		result.Deleted = true
+
+		var res sql.Result
+		switch opts.Posts {
+		case database.PostsCascade:
+			res, err = tx.ExecContext(ctx, `DELETE FROM posts WHERE user_email = $1`, email)
+		case database.PostsAnonymize:
+			res, err = tx.ExecContext(ctx, `UPDATE posts SET user_email = $2 WHERE user_email = $1`,
+				email, database.DeletedUserEmail)
+		default:
+			err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM posts WHERE user_email = $1`, email).Scan(&result.Posts)
+		}
+		if err != nil {
+			return err
+		}
+		if res != nil {
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			result.Posts = int(n)
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM users WHERE email = $1`, email)
+		return err
+	})
+	if err != nil {
+		return database.DeleteUserResult{}, err
+	}
+	return result, nil
+}
+
+// CreatePost -
+// create a post authored by an existing user, the foreign key rejects unknown authors
+// the text is trimmed and checked like the json Client's, database.ErrEmptyPost or database.ErrPostTooLong otherwise
+func (c *Client) CreatePost(ctx context.Context, userEmail, text string) (database.Post, error) {
+	userEmail = database.EmailKey(userEmail)
+	text, err := database.PostText(text, database.DefaultMaxPostLength)
+	if err != nil {
+		return database.Post{}, err
+	}
+	post := database.Post{
+		ID:        uuid.New().String(),
+		CreatedAt: now(),
+		UserEmail: userEmail,
+		Text:      text,
+	}
+	_, err = c.db.ExecContext(ctx,
+		`INSERT INTO posts (id, user_email, author_email, text, created_at) VALUES ($1, $2, $2, $3, $4)`,
+		post.ID, post.UserEmail, post.Text, post.CreatedAt)
+	if isCode(err, foreignKeyViolation) {
+		return database.Post{}, fmt.Errorf("%w: %s", database.ErrUserNotFound, userEmail)
+	}
+	if err != nil {
+		return database.Post{}, err
+	}
+	return post, nil
+}
+
+// GetPosts -
+// return all posts of a specific user identified by their userEmail, newest first like database.SortPosts
+func (c *Client) GetPosts(ctx context.Context, userEmail string) ([]database.Post, error) {
+	userEmail = database.EmailKey(userEmail)
+	rows, err := c.db.QueryContext(ctx,
+		`SELECT id, user_email, text, created_at FROM posts WHERE user_email = $1`, userEmail)
+	if err != nil {
+		return []database.Post{}, err
+	}
+	defer rows.Close()
+
+	posts := []database.Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return []database.Post{}, err
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return []database.Post{}, err
+	}
+	// sorted here rather than with ORDER BY so ties come out the same as every other backend
+	database.SortPosts(posts, database.NewestFirst)
+	return posts, nil
+}
+
+// DeletePost -
+// delete a single post identified by the id, returns the removed post
+func (c *Client) DeletePost(ctx context.Context, id string) (database.Post, error) {
+	if id == "" {
+		return database.Post{}, database.ErrEmptyPostID
+	}
+	post, err := scanPost(c.db.QueryRowContext(ctx,
+		`DELETE FROM posts WHERE id = $1 RETURNING id, user_email, text, created_at`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Post{}, fmt.Errorf("%w: %s", database.ErrPostNotFound, id)
+	}
+	if err != nil {
+		return database.Post{}, err
+	}
+	return post, nil
+}
+
+// tx runs fn in a transaction, committing only if it returns nil
+func (c *Client) tx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// now is the creation time for new records, postgres keeps microseconds
+// so anything finer is dropped up front and the returned record matches what's stored
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// sanitize is user as the reads return it, without PasswordHash like the json Client's
+func sanitize(user database.User) database.User {
+	user.PasswordHash = ""
+	return user
+}
+
+// scanner is a *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row scanner, email string) (database.User, error) {
+	user := database.User{}
+	err := row.Scan(&user.Email, &user.PasswordHash, &user.Name, &user.Age, &user.CreatedAt, &user.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("%w: %s", database.ErrUserNotFound, email)
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+	user.CreatedAt = user.CreatedAt.UTC()
+	user.UpdatedAt = user.UpdatedAt.UTC()
+	return user, nil
+}
+
+func scanPost(row scanner) (database.Post, error) {
+	post := database.Post{}
+	if err := row.Scan(&post.ID, &post.UserEmail, &post.Text, &post.CreatedAt); err != nil {
+		return database.Post{}, err
+	}
+	post.CreatedAt = post.CreatedAt.UTC()
+	return post, nil
+}
+
+// isCode reports whether err is a postgres error with the given SQLSTATE code
+func isCode(err error, code pq.ErrorCode) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == code
+}
@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/Warren-Wang-OG/go-social-media-backend/database"
+	"github.com/Warren-Wang-OG/go-social-media-backend/database/databasetest"
+)
+
+var ctx = context.Background()
+
+// newTestClient connects to POSTGRES_DSN and empties the tables,
+// the database there is wiped so don't point it at anything you care about
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_DSN not set")
+	}
+	c, err := NewPostgresClient(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.db.ExecContext(ctx, `TRUNCATE posts, users`); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestConformance(t *testing.T) {
+	if os.Getenv("POSTGRES_DSN") == "" {
+		t.Skip("POSTGRES_DSN not set")
+	}
+	databasetest.Run(t, func(t *testing.T) database.Repository {
+		return newTestClient(t)
+	})
+}
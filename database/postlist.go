@@ -0,0 +1,196 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// DefaultPostPageSize is how many posts GetPostsPage returns when ListOptions.Limit is 0
+const DefaultPostPageSize = 20
+
+// PostPage -
+// one page of posts from GetPostsPage or GetAllPostsPage
+type PostPage struct {
+	Posts []Post
+	// Total is how many posts there are on all pages, for working out the number of pages
+	Total int
+	// NextCursor is the ListOptions.Cursor for the page after this one, empty on the last page
+	NextCursor string
+}
+
+// listedPost -
+// whether post passes the filters of opts, in db at now
+func (db *Schema) listedPost(post Post, opts ListOptions, authors map[string]bool, now time.Time) bool {
+	if authors != nil && !authors[post.UserEmail] {
+		return false
+	}
+	// drafts and scheduled posts are only for GetDrafts and GetScheduledPosts
+	if post.unpublished(now) || (post.DeletedAt != nil && !opts.IncludeDeleted) {
+		return false
+	}
+	if !opts.IgnoreVisibility && !db.canSeePost(opts.viewer(), post) {
+		return false
+	}
+	if !opts.Since.IsZero() && post.CreatedAt.Before(opts.Since) {
+		return false
+	}
+	if !opts.Until.IsZero() && post.CreatedAt.After(opts.Until) {
+		return false
+	}
+	// posts left behind by a deleted user have no author to check
+	if author, ok := db.Users[post.UserEmail]; ok {
+		if author.DeletedAt != nil && !opts.IncludeDeleted {
+			return false
+		}
+		if !author.Active() && !opts.IncludeDeactivated {
+			return false
+		}
+	}
+	return true
+}
+
+// viewer -
+// who opts lists posts for, "" for someone logged out
+func (opts ListOptions) viewer() string {
+	if opts.Anonymous {
+		return ""
+	}
+	return opts.Viewer
+}
+
+// authorSet -
+// the emails of opts.Authors for listedPost, nil without Authors
+func (opts ListOptions) authorSet() map[string]bool {
+	if opts.Authors == nil {
+		return nil
+	}
+	authors := make(map[string]bool, len(opts.Authors))
+	for _, email := range opts.Authors {
+		authors[EmailKey(email)] = true
+	}
+	return authors
+}
+
+// listPosts -
+// the posts passing opts' filters, and only those of authors if it isn't nil, in opts.Order
+func (c *Client) listPosts(ctx context.Context, op, key string, opts ListOptions, authors map[string]bool) ([]Post, error) {
+	posts := []Post{}
+	now := c.clock.Now()
+	err := c.view(ctx, op, key, func(db *Schema) error {
+		i := 0
+		for _, post := range db.Posts {
+			// full scan, bail out if the caller gave up
+			if i++; i%cancelCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			if db.listedPost(post, opts, authors, now) {
+				posts = append(posts, post)
+			}
+		}
+		if opts.IncludeOriginals {
+			db.withOriginals(posts, opts, now)
+		}
+		return nil
+	})
+	if err != nil {
+		return []Post{}, err
+	}
+	// sorted outside the lock, the slice is a copy
+	SortPosts(posts, opts.Order)
+	return posts, nil
+}
+
+// postPage -
+// the page of posts, sorted in opts.Order, that opts asks for
+func postPage(posts []Post, opts ListOptions) (PostPage, error) {
+	start, end := opts.pageBounds(len(posts))
+	if opts.Cursor != "" {
+		after, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return PostPage{Posts: []Post{}}, err
+		}
+		// the cursor's post may be gone, what matters is where it would be
+		start = sort.Search(len(posts), func(i int) bool {
+			if opts.Order == OldestFirst {
+				return postLess(after, posts[i])
+			}
+			return postLess(posts[i], after)
+		})
+		end = len(posts)
+		if opts.Limit > 0 && opts.Limit < end-start {
+			end = start + opts.Limit
+		}
+	}
+	// copied so a small page doesn't keep every post alive
+	page := PostPage{Posts: append(make([]Post, 0, end-start), posts[start:end]...), Total: len(posts)}
+	if end > start && end < len(posts) {
+		page.NextCursor = encodeCursor(posts[end-1])
+	}
+	return page, nil
+}
+
+// GetAllPosts -
+// GetAllPostsPage without the total and cursor
+func (c *Client) GetAllPosts(ctx context.Context, opts ListOptions) ([]Post, error) {
+	page, err := c.GetAllPostsPage(ctx, opts)
+	if err != nil {
+		return []Post{}, err
+	}
+	return page.Posts, nil
+}
+
+// GetAllPostsPage -
+// every user's published posts for an explore page, one page at a time as opts says.
+// newest first, or in opts.Order like SortPosts. Since, Until and Authors narrow it down.
+// soft-deleted posts and those of soft-deleted or deactivated users are left out unless opts asks for them.
+// only what opts' Viewer may read is listed, without one that's what someone logged out may read.
+// the order only changes when posts are added or removed, like GetUsers'.
+// page with Cursor rather than Offset so nothing shifts when they are, see IteratePosts for a feed.
+// ErrInvalidListOptions for a negative Offset or Limit, an Until before Since or a Cursor with an Offset,
+// ErrInvalidCursor for a Cursor that isn't a NextCursor
+func (c *Client) GetAllPostsPage(ctx context.Context, opts ListOptions) (PostPage, error) {
+	if err := opts.validate(); err != nil {
+		return PostPage{Posts: []Post{}}, err
+	}
+	opts.Viewer = EmailKey(opts.Viewer)
+	posts, err := c.listPosts(ctx, "GetAllPosts", "", opts, opts.authorSet())
+	if err != nil {
+		return PostPage{Posts: []Post{}}, err
+	}
+	return postPage(posts, opts)
+}
+
+// GetPostsBetween -
+// GetPosts of only the posts created from since to until, both included and either may be zero
+// for no bound, like ListOptions' Since and Until. GetPostsPage pages through the same range.
+// ErrInvalidListOptions for an until before since
+func (c *Client) GetPostsBetween(ctx context.Context, userEmail string, since, until time.Time) ([]Post, error) {
+	opts := ListOptions{Since: since, Until: until}
+	if err := opts.validate(); err != nil {
+		return []Post{}, err
+	}
+	return c.listPosts(ctx, "GetPostsBetween", userEmail, opts, map[string]bool{EmailKey(userEmail): true})
+}
+
+// GetPostsPage -
+// GetPosts one page at a time for a profile, in order like GetAllPostsPage with the same filters
+// and cursors, Authors aside. a Limit of 0 is DefaultPostPageSize posts, an Offset past the end an
+// empty page. none while the user is deactivated or soft-deleted and no soft-deleted posts, unless opts asks.
+// ErrInvalidListOptions and ErrInvalidCursor like GetAllPostsPage
+func (c *Client) GetPostsPage(ctx context.Context, userEmail string, opts ListOptions) (PostPage, error) {
+	if err := opts.validate(); err != nil {
+		return PostPage{Posts: []Post{}}, err
+	}
+	if opts.Limit == 0 {
+		opts.Limit = DefaultPostPageSize
+	}
+	opts.Viewer = EmailKey(opts.Viewer)
+	posts, err := c.listPosts(ctx, "GetPostsPage", userEmail, opts, map[string]bool{EmailKey(userEmail): true})
+	if err != nil {
+		return PostPage{Posts: []Post{}}, err
+	}
+	return postPage(posts, opts)
+}
@@ -0,0 +1,353 @@
+package database
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newPostListClient has a@, b@ and c@ with posts 1 to 6 interleaved between them in ID order,
+// 3 and 4 made at the same time
+func newPostListClient(t *testing.T) *Client {
+	t.Helper()
+	c := newTestClient(t, WithIDGenerator(&SequenceIDGenerator{}))
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, post := range []struct {
+		email   string
+		minutes int
+	}{
+		{"a@example.com", 1}, {"b@example.com", 2}, {"c@example.com", 3},
+		{"a@example.com", 3}, {"b@example.com", 5}, {"a@example.com", 6},
+	} {
+		if _, err := c.CreatePostAt(ctx, post.email, "post", start.Add(time.Duration(post.minutes)*time.Minute)); err != nil {
+			t.Fatalf("post %d: %v", i+1, err)
+		}
+	}
+	return c
+}
+
+// sequence is the SequenceIDGenerator IDs of the numbers
+func sequence(numbers ...int) []string {
+	ids := []string{}
+	for _, n := range numbers {
+		ids = append(ids, fmt.Sprintf("%020d", n))
+	}
+	return ids
+}
+
+func TestGetAllPosts(t *testing.T) {
+	c := newPostListClient(t)
+	at := func(minutes int) time.Time {
+		return time.Date(2024, 1, 1, 0, minutes, 0, 0, time.UTC)
+	}
+	var tests = []struct {
+		name     string
+		opts     ListOptions
+		expected []string
+	}{
+		// 4 was made after 3 at the same time, so it's newer
+		{name: "all", opts: ListOptions{}, expected: sequence(6, 5, 4, 3, 2, 1)},
+		{name: "first page", opts: ListOptions{Limit: 2}, expected: sequence(6, 5)},
+		{name: "middle page", opts: ListOptions{Offset: 2, Limit: 2}, expected: sequence(4, 3)},
+		{name: "last page", opts: ListOptions{Offset: 4, Limit: 4}, expected: sequence(2, 1)},
+		{name: "past the end", opts: ListOptions{Offset: 6, Limit: 2}, expected: []string{}},
+		{name: "authors", opts: ListOptions{Authors: []string{"A@example.com", "c@example.com"}}, expected: sequence(6, 4, 3, 1)},
+		{name: "no authors", opts: ListOptions{Authors: []string{}}, expected: []string{}},
+		{name: "since", opts: ListOptions{Since: at(3)}, expected: sequence(6, 5, 4, 3)},
+		{name: "until", opts: ListOptions{Until: at(3)}, expected: sequence(4, 3, 2, 1)},
+		{name: "since and until", opts: ListOptions{Since: at(2), Until: at(5), Limit: 2}, expected: sequence(5, 4)},
+	}
+	for _, test := range tests {
+		posts, err := c.GetAllPosts(ctx, test.opts)
+		if err != nil || !reflect.DeepEqual(postIDs(posts), test.expected) {
+			t.Errorf("%s: GetAllPosts() = %v, %v, expected %v", test.name, postIDs(posts), err, test.expected)
+		}
+	}
+
+	for _, opts := range []ListOptions{{Offset: -1}, {Limit: -1}, {Since: at(3), Until: at(2)}} {
+		if _, err := c.GetAllPosts(ctx, opts); !errors.Is(err, ErrInvalidListOptions) {
+			t.Errorf("GetAllPosts(%+v) = %v, expected ErrInvalidListOptions", opts, err)
+		}
+	}
+}
+
+func TestGetAllPostsHiddenAuthors(t *testing.T) {
+	c := newPostListClient(t)
+	if _, err := c.SoftDeleteUser(ctx, "b@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DeactivateUser(ctx, "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		opts     ListOptions
+		expected []string
+	}{
+		{opts: ListOptions{}, expected: sequence(6, 4, 1)},
+		{opts: ListOptions{IncludeDeleted: true}, expected: sequence(6, 5, 4, 2, 1)},
+		{opts: ListOptions{IncludeDeactivated: true}, expected: sequence(6, 4, 3, 1)},
+		{opts: ListOptions{IncludeDeleted: true, IncludeDeactivated: true, Limit: 3}, expected: sequence(6, 5, 4)},
+	}
+	for _, test := range tests {
+		posts, err := c.GetAllPosts(ctx, test.opts)
+		if err != nil || !reflect.DeepEqual(postIDs(posts), test.expected) {
+			t.Errorf("GetAllPosts(%+v) = %v, %v, expected %v", test.opts, postIDs(posts), err, test.expected)
+		}
+	}
+}
+
+func TestGetPostsPage(t *testing.T) {
+	c := newTestClient(t)
+	for _, email := range []string{"test@example.com", "other@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// all at the same time, only the IDs tell them apart
+	createdAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	const posts = 25
+	for i := 0; i < posts; i++ {
+		for _, email := range []string{"test@example.com", "other@example.com"} {
+			if _, err := c.CreatePostAt(ctx, email, "post", createdAt); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	paged := []Post{}
+	for offset := 0; ; offset += 7 {
+		page, err := c.GetPostsPage(ctx, "Test@example.com", ListOptions{Offset: offset, Limit: 7})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != posts {
+			t.Errorf("GetPostsPage() at offset %d total = %d, expected %d", offset, page.Total, posts)
+		}
+		if len(page.Posts) == 0 {
+			break
+		}
+		for _, post := range page.Posts {
+			if seen[post.ID] || post.UserEmail != "test@example.com" {
+				t.Errorf("GetPostsPage() at offset %d = %s again or of another user", offset, post.ID)
+			}
+			seen[post.ID] = true
+		}
+		paged = append(paged, page.Posts...)
+	}
+	if len(paged) != posts {
+		t.Errorf("pages held %d posts, expected %d", len(paged), posts)
+	}
+	for i := 1; i < len(paged); i++ {
+		if paged[i-1].ID < paged[i].ID {
+			t.Errorf("pages order %s before %s, expected newest first by ID", paged[i-1].ID, paged[i].ID)
+		}
+	}
+
+	var tests = []struct {
+		name     string
+		opts     ListOptions
+		expected int
+	}{
+		{name: "default size", opts: ListOptions{}, expected: DefaultPostPageSize},
+		{name: "last partial page", opts: ListOptions{Offset: 20, Limit: 10}, expected: 5},
+		{name: "past the end", opts: ListOptions{Offset: 100}, expected: 0},
+	}
+	for _, test := range tests {
+		page, err := c.GetPostsPage(ctx, "test@example.com", test.opts)
+		if err != nil || len(page.Posts) != test.expected || page.Total != posts {
+			t.Errorf("%s: GetPostsPage() = %d posts of %d, %v, expected %d of %d", test.name, len(page.Posts), page.Total, err, test.expected, posts)
+		}
+	}
+	if _, err := c.GetPostsPage(ctx, "test@example.com", ListOptions{Offset: -1}); !errors.Is(err, ErrInvalidListOptions) {
+		t.Errorf("GetPostsPage() with a negative offset = %v, expected ErrInvalidListOptions", err)
+	}
+
+	if _, err := c.DeactivateUser(ctx, "test@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if page, err := c.GetPostsPage(ctx, "test@example.com", ListOptions{}); err != nil || len(page.Posts) != 0 || page.Total != 0 {
+		t.Errorf("GetPostsPage() of a deactivated user = %d posts of %d, %v, expected none", len(page.Posts), page.Total, err)
+	}
+}
+
+func TestGetPostsBetween(t *testing.T) {
+	// a post at noon every day of June
+	clock := &fakeClock{now: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	c := newTestClient(t, WithClock(clock), WithIDGenerator(&SequenceIDGenerator{}))
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "name", 18); err != nil {
+		t.Fatal(err)
+	}
+	for day := 1; day <= 30; day++ {
+		if _, err := c.CreatePost(ctx, "test@example.com", "post"); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(24 * time.Hour)
+	}
+	day := func(n int) time.Time {
+		return time.Date(2024, 6, n, 12, 0, 0, 0, time.UTC)
+	}
+	days := func(from, to int) []string {
+		ids := []string{}
+		for n := to; n >= from; n-- {
+			ids = append(ids, fmt.Sprintf("%020d", n))
+		}
+		return ids
+	}
+
+	var tests = []struct {
+		name         string
+		since, until time.Time
+		expected     []string
+	}{
+		{name: "no bounds", expected: days(1, 30)},
+		{name: "since", since: day(25), expected: days(25, 30)},
+		{name: "until", until: day(5), expected: days(1, 5)},
+		{name: "a week", since: day(10), until: day(16), expected: days(10, 16)},
+		{name: "just inside", since: day(10).Add(-time.Nanosecond), until: day(16).Add(time.Nanosecond), expected: days(10, 16)},
+		{name: "just outside", since: day(10).Add(time.Nanosecond), until: day(16).Add(-time.Nanosecond), expected: days(11, 15)},
+		{name: "one instant", since: day(20), until: day(20), expected: days(20, 20)},
+		{name: "between posts", since: day(3).Add(time.Hour), until: day(4).Add(-time.Hour), expected: []string{}},
+		{name: "after the last", since: day(30).Add(time.Nanosecond), expected: []string{}},
+	}
+	for _, test := range tests {
+		posts, err := c.GetPostsBetween(ctx, "test@example.com", test.since, test.until)
+		if err != nil || !reflect.DeepEqual(postIDs(posts), test.expected) {
+			t.Errorf("%s: GetPostsBetween() = %v, %v, expected %v", test.name, postIDs(posts), err, test.expected)
+		}
+		// and the same range a page at a time
+		got := []Post{}
+		opts := ListOptions{Since: test.since, Until: test.until, Limit: 4}
+		for {
+			page, err := c.GetPostsPage(ctx, "test@example.com", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Total != len(test.expected) {
+				t.Errorf("%s: GetPostsPage() total %d, expected %d", test.name, page.Total, len(test.expected))
+			}
+			got = append(got, page.Posts...)
+			if page.NextCursor == "" {
+				break
+			}
+			opts.Cursor = page.NextCursor
+		}
+		if !reflect.DeepEqual(postIDs(got), test.expected) {
+			t.Errorf("%s: GetPostsPage() pages = %v, expected %v", test.name, postIDs(got), test.expected)
+		}
+	}
+
+	if _, err := c.GetPostsBetween(ctx, "test@example.com", day(10), day(9)); !errors.Is(err, ErrInvalidListOptions) {
+		t.Errorf("GetPostsBetween() with until before since = %v, expected ErrInvalidListOptions", err)
+	}
+}
+
+func TestPostCursors(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newTestClient(t, WithClock(clock), WithIDGenerator(&SequenceIDGenerator{}))
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// pairs made at the same time
+	for i := 0; i < 10; i++ {
+		if i%2 == 0 {
+			clock.Advance(time.Minute)
+		}
+		if _, err := c.CreatePost(ctx, []string{"a@example.com", "b@example.com"}[i%3%2], "post"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		name     string
+		page     func(cursor string) (PostPage, error)
+		expected func() (PostPage, error)
+	}{
+		{name: "GetAllPostsPage", page: func(cursor string) (PostPage, error) {
+			return c.GetAllPostsPage(ctx, ListOptions{Limit: 3, Cursor: cursor})
+		}, expected: func() (PostPage, error) {
+			return c.GetAllPostsPage(ctx, ListOptions{})
+		}},
+		{name: "GetPostsPage", page: func(cursor string) (PostPage, error) {
+			return c.GetPostsPage(ctx, "a@example.com", ListOptions{Limit: 2, Cursor: cursor})
+		}, expected: func() (PostPage, error) {
+			return c.GetPostsPage(ctx, "a@example.com", ListOptions{Limit: 100})
+		}},
+	}
+	for _, test := range tests {
+		// everything there is when paging starts
+		expected, err := test.expected()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []Post{}
+		cursor := ""
+		for pages := 0; ; pages++ {
+			if pages > len(expected.Posts) {
+				t.Fatalf("%s: still paging after %d pages", test.name, pages)
+			}
+			page, err := test.page(cursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, page.Posts...)
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+
+			// newer posts and the loss of the last one seen don't move the rest
+			clock.Advance(time.Minute)
+			if _, err := c.CreatePost(ctx, "a@example.com", "newer"); err != nil {
+				t.Fatal(err)
+			}
+			if pages == 1 {
+				last := page.Posts[len(page.Posts)-1]
+				if _, err := c.DeletePost(ctx, last.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if !reflect.DeepEqual(postIDs(got), postIDs(expected.Posts)) {
+			t.Errorf("%s: pages = %v, expected %v with no gaps or repeats", test.name, postIDs(got), postIDs(expected.Posts))
+		}
+	}
+}
+
+func TestInvalidCursor(t *testing.T) {
+	c := newPostListClient(t)
+	page, err := c.GetAllPostsPage(ctx, ListOptions{Limit: 2})
+	if err != nil || page.NextCursor == "" {
+		t.Fatalf("GetAllPostsPage() = %+v, %v, expected a next cursor", page, err)
+	}
+	var tests = []struct {
+		name     string
+		opts     ListOptions
+		expected error
+	}{
+		{name: "not base64", opts: ListOptions{Cursor: "not a cursor!"}, expected: ErrInvalidCursor},
+		{name: "no ID", opts: ListOptions{Cursor: base64.RawURLEncoding.EncodeToString([]byte("2024-01-01T00:00:00Z"))}, expected: ErrInvalidCursor},
+		{name: "bad time", opts: ListOptions{Cursor: base64.RawURLEncoding.EncodeToString([]byte("yesterday 1"))}, expected: ErrInvalidCursor},
+		{name: "bad month", opts: ListOptions{Cursor: base64.RawURLEncoding.EncodeToString([]byte("2024-13-01T00:00:00Z 1"))}, expected: ErrInvalidCursor},
+		{name: "with an offset", opts: ListOptions{Cursor: page.NextCursor, Offset: 1}, expected: ErrInvalidListOptions},
+		{name: "valid", opts: ListOptions{Cursor: page.NextCursor}, expected: nil},
+	}
+	for _, test := range tests {
+		if _, err := c.GetAllPostsPage(ctx, test.opts); !errors.Is(err, test.expected) {
+			t.Errorf("%s: GetAllPostsPage() = %v, expected %v", test.name, err, test.expected)
+		}
+		if _, err := c.GetPostsPage(ctx, "a@example.com", test.opts); !errors.Is(err, test.expected) {
+			t.Errorf("%s: GetPostsPage() = %v, expected %v", test.name, err, test.expected)
+		}
+	}
+}
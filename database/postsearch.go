@@ -0,0 +1,365 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// postIndex -
+// the inverted index SearchPosts looks terms up in: key,value = search term,the IDs of the posts
+// whose text has it and how many times. clones of the db share the maps of IDs, a write copies
+// the ones it changes the first time it does, see indexPost
+type postIndex map[string]map[string]int
+
+// the letters searchTerms folds to what they'd be without their accents
+var unaccented = func() map[rune]string {
+	bases := map[string]string{
+		"a": "àáâãäåāăą", "c": "çćĉċč", "d": "ďđð", "e": "èéêëēĕėęě", "g": "ĝğġģ", "h": "ĥħ",
+		"i": "ìíîïĩīĭįı", "j": "ĵ", "k": "ķ", "l": "ĺļľŀł", "n": "ñńņňŉ", "o": "òóôõöøōŏő",
+		"r": "ŕŗř", "s": "śŝşšș", "t": "ţťŧț", "u": "ùúûüũūŭůűų", "w": "ŵ", "y": "ýÿŷ", "z": "źżž",
+		"ss": "ß", "ae": "æ", "oe": "œ", "th": "þ",
+	}
+	folded := map[rune]string{}
+	for base, letters := range bases {
+		for _, r := range letters {
+			folded[r] = base
+		}
+	}
+	return folded
+}()
+
+// searchTerms -
+// the words of text in order, for indexing a post or reading a query: runs of letters and digits,
+// folded so case and accents don't matter ("Łódź" and "lodz" are the same term). combining marks
+// are dropped too, so a decomposed "é" folds like a composed one
+func searchTerms(text string) []string {
+	terms := []string{}
+	var term strings.Builder
+	flush := func() {
+		if term.Len() > 0 {
+			terms = append(terms, term.String())
+			term.Reset()
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			// foldRune first so the likes of the Kelvin sign end up as their letter
+			r = unicode.ToLower(foldRune(r))
+			if base, ok := unaccented[r]; ok {
+				term.WriteString(base)
+			} else {
+				term.WriteRune(r)
+			}
+		default:
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
+
+// termCounts is how many times each of the searchTerms of text is in it
+func termCounts(text string) map[string]int {
+	counts := map[string]int{}
+	for _, term := range searchTerms(text) {
+		counts[term]++
+	}
+	return counts
+}
+
+// update -
+// add the post with id to the maps of the terms in counts, or take it out with remove. own has
+// the terms whose maps this copy of the db made for itself, the others are copied first
+func (ix postIndex) update(own map[string]bool, id string, counts map[string]int, remove bool) {
+	for term, count := range counts {
+		ids := ix[term]
+		// the map may be the original's, copied once so the write doesn't show through.
+		// one this write emptied is gone and has to be made again
+		if !own[term] || ids == nil {
+			ids = make(map[string]int, len(ix[term])+1)
+			for other, n := range ix[term] {
+				ids[other] = n
+			}
+			own[term] = true
+		}
+		if remove {
+			delete(ids, id)
+		} else {
+			ids[id] = count
+		}
+		if len(ids) == 0 {
+			delete(ix, term)
+		} else {
+			ix[term] = ids
+		}
+	}
+}
+
+// indexPost -
+// add post to PostIndex, Hashtags, Mentioned, Reposts, Replies and Authored, or take it out with remove
+func (db *Schema) indexPost(post Post, remove bool) {
+	if db.PostIndex == nil {
+		db.PostIndex, db.ownTerms = postIndex{}, nil
+	}
+	if db.Hashtags == nil {
+		db.Hashtags, db.ownTags = postIndex{}, nil
+	}
+	if db.Mentioned == nil {
+		db.Mentioned, db.ownMentions = postIndex{}, nil
+	}
+	if db.Reposts == nil {
+		db.Reposts, db.ownReposts = postIndex{}, nil
+	}
+	if db.Replies == nil {
+		db.Replies, db.ownReplies = postIndex{}, nil
+	}
+	if db.Authored == nil {
+		db.Authored, db.ownAuthored = postIndex{}, nil
+	}
+	if db.ownTerms == nil {
+		db.ownTerms = map[string]bool{}
+	}
+	if db.ownTags == nil {
+		db.ownTags = map[string]bool{}
+	}
+	if db.ownMentions == nil {
+		db.ownMentions = map[string]bool{}
+	}
+	if db.ownReposts == nil {
+		db.ownReposts = map[string]bool{}
+	}
+	if db.ownReplies == nil {
+		db.ownReplies = map[string]bool{}
+	}
+	if db.ownAuthored == nil {
+		db.ownAuthored = map[string]bool{}
+	}
+	db.PostIndex.update(db.ownTerms, post.ID, termCounts(post.Text), remove)
+	db.Hashtags.update(db.ownTags, post.ID, keyCounts(post.Tags), remove)
+	db.Mentioned.update(db.ownMentions, post.ID, keyCounts(post.Mentions), remove)
+	db.Reposts.update(db.ownReposts, post.ID, keyCounts(idKeys(post.RepostOf)), remove)
+	db.Replies.update(db.ownReplies, post.ID, keyCounts(idKeys(post.ParentID)), remove)
+	db.Authored.update(db.ownAuthored, post.ID, keyCounts(idKeys(post.UserEmail)), remove)
+}
+
+// putPost -
+// store post, replacing the one with its ID, and keep the indexes in step with its text, tags, mentions, RepostOf, ParentID and author
+func (db *Schema) putPost(post Post) {
+	old, ok := db.Posts[post.ID]
+	if !ok || old.Text != post.Text || !equalSlices(old.Tags, post.Tags) || !equalSlices(old.Mentions, post.Mentions) ||
+		old.RepostOf != post.RepostOf || old.ParentID != post.ParentID || old.UserEmail != post.UserEmail {
+		if ok {
+			db.indexPost(old, true)
+		}
+		db.indexPost(post, false)
+	}
+	db.Posts[post.ID] = post
+}
+
+// indexPosts -
+// rebuild PostIndex, Hashtags, Mentioned, Reposts, Replies and Authored from Posts, for a db that was just read
+func (db *Schema) indexPosts() {
+	db.PostIndex, db.Hashtags, db.Mentioned = postIndex{}, postIndex{}, postIndex{}
+	db.Reposts, db.Replies, db.Authored = postIndex{}, postIndex{}, postIndex{}
+	// every map is new, this copy owns them all
+	db.ownTerms, db.ownTags, db.ownMentions = map[string]bool{}, map[string]bool{}, map[string]bool{}
+	db.ownReposts, db.ownReplies, db.ownAuthored = map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for id, post := range db.Posts {
+		db.PostIndex.update(db.ownTerms, id, termCounts(post.Text), false)
+		db.Hashtags.update(db.ownTags, id, keyCounts(post.Tags), false)
+		db.Mentioned.update(db.ownMentions, id, keyCounts(post.Mentions), false)
+		db.Reposts.update(db.ownReposts, id, keyCounts(idKeys(post.RepostOf)), false)
+		db.Replies.update(db.ownReplies, id, keyCounts(idKeys(post.ParentID)), false)
+		db.Authored.update(db.ownAuthored, id, keyCounts(idKeys(post.UserEmail)), false)
+	}
+}
+
+// clone -
+// copy of the index that terms can be added to and removed from without touching the original,
+// the maps of IDs are shared until indexPost changes them
+func (ix postIndex) clone() postIndex {
+	if ix == nil {
+		return nil
+	}
+	copied := make(postIndex, len(ix))
+	for term, ids := range ix {
+		copied[term] = ids
+	}
+	return copied
+}
+
+// postQuery -
+// a SearchPosts query split into the single words and the quoted phrases every match has to have
+type postQuery struct {
+	terms   []string
+	phrases [][]string
+}
+
+// parsePostQuery -
+// the words and phrases of query, a phrase is in double quotes and a quote left open runs to the end
+func parsePostQuery(query string) postQuery {
+	q := postQuery{terms: []string{}, phrases: [][]string{}}
+	for i, part := range strings.Split(query, `"`) {
+		terms := searchTerms(part)
+		// a quoted word is just a word
+		if i%2 == 0 || len(terms) < 2 {
+			q.terms = append(q.terms, terms...)
+		} else {
+			q.phrases = append(q.phrases, terms)
+		}
+	}
+	return q
+}
+
+// words is every term of q, those of the phrases too, once each
+func (q postQuery) words() []string {
+	seen := map[string]bool{}
+	words := []string{}
+	for _, terms := range append([][]string{q.terms}, q.phrases...) {
+		for _, term := range terms {
+			if !seen[term] {
+				seen[term] = true
+				words = append(words, term)
+			}
+		}
+	}
+	return words
+}
+
+// phraseCount is how many times phrase is in terms, the searchTerms of a post
+func phraseCount(terms, phrase []string) int {
+	n := 0
+	for i := 0; i+len(phrase) <= len(terms); i++ {
+		match := true
+		for j, term := range phrase {
+			if terms[i+j] != term {
+				match = false
+				break
+			}
+		}
+		if match {
+			n++
+		}
+	}
+	return n
+}
+
+// score -
+// how well the post with id and text matches q, the times its words and phrases come up in it,
+// 0 if it doesn't have every one of them. the index only knows the words, the phrases are
+// checked against the text
+func (q postQuery) score(ix postIndex, id, text string) int {
+	score := 0
+	for _, term := range q.terms {
+		n := ix[term][id]
+		if n == 0 {
+			return 0
+		}
+		score += n
+	}
+	if len(q.phrases) == 0 {
+		return score
+	}
+	terms := searchTerms(text)
+	for _, phrase := range q.phrases {
+		n := phraseCount(terms, phrase)
+		if n == 0 {
+			return 0
+		}
+		score += n * len(phrase)
+	}
+	return score
+}
+
+// candidates -
+// the IDs of the posts that have every word of q, looked up in the index starting from the rarest word
+func (q postQuery) candidates(ix postIndex) []string {
+	words := q.words()
+	sort.Slice(words, func(i, j int) bool { return len(ix[words[i]]) < len(ix[words[j]]) })
+	ids := []string{}
+	for id := range ix[words[0]] {
+		found := true
+		for _, word := range words[1:] {
+			if _, ok := ix[word][id]; !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// SearchPosts -
+// the posts whose text has every word of query, ignoring case and accents, and every phrase in
+// double quotes as it's written, word for word. posts with more of them come first, ties newest
+// first like SortPosts, then opts.Offset and opts.Limit page through them. the words are looked up
+// in an index kept with the posts, so only the posts that have them are read. posts are left out
+// like in GetAllPosts: drafts, scheduled posts, soft-deleted posts and the posts of deactivated and
+// soft-deleted users unless opts asks for those, and the posts the Viewer may not see.
+// ErrEmptySearchQuery for a query with no words, ErrInvalidListOptions for a negative Limit or Offset
+func (c *Client) SearchPosts(ctx context.Context, query string, opts SearchOptions) ([]Post, error) {
+	q := parsePostQuery(query)
+	if len(q.words()) == 0 {
+		return []Post{}, ErrEmptySearchQuery
+	}
+	if err := opts.validate(); err != nil {
+		return []Post{}, err
+	}
+
+	type result struct {
+		post  Post
+		score int
+	}
+	results := []result{}
+	list := ListOptions{
+		Limit:              opts.Limit,
+		Offset:             opts.Offset,
+		IncludeDeactivated: opts.IncludeDeactivated,
+		IncludeDeleted:     opts.IncludeDeleted,
+		Viewer:             EmailKey(opts.Viewer),
+		IgnoreVisibility:   opts.IgnoreVisibility,
+	}
+	now := c.clock.Now()
+	err := c.view(ctx, "SearchPosts", "", func(db *Schema) error {
+		for i, id := range q.candidates(db.PostIndex) {
+			// a common word can still be a lot of posts
+			if (i+1)%cancelCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			post, ok := db.Posts[id]
+			if !ok || !db.listedPost(post, list, nil, now) {
+				continue
+			}
+			if score := q.score(db.PostIndex, id, post.Text); score > 0 {
+				results = append(results, result{post: post, score: score})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []Post{}, err
+	}
+
+	// sorted outside the lock, the slice is a copy
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return postLess(results[j].post, results[i].post)
+	})
+	start, end := list.pageBounds(len(results))
+	posts := make([]Post, 0, end-start)
+	for _, r := range results[start:end] {
+		posts = append(posts, r.post)
+	}
+	return posts, nil
+}
@@ -0,0 +1,284 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newPostSearchClient has a@ write texts an hour apart, their IDs are sequence(1, 2, ...)
+func newPostSearchClient(t *testing.T, texts ...string) (*Client, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newTestClient(t, WithClock(clock), WithIDGenerator(&SequenceIDGenerator{}))
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, text := range texts {
+		clock.Advance(time.Hour)
+		if _, err := c.CreatePost(ctx, "a@example.com", text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c, clock
+}
+
+// checkPostIndex fails the test unless the client's indexes are the ones its posts would build
+func checkPostIndex(t *testing.T, c *Client, when string) {
+	t.Helper()
+	if err := c.ensureLoaded(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rebuilt := Schema{Posts: c.mem.Posts}
+	rebuilt.indexPosts()
+	for name, ix := range map[string][2]postIndex{
+		"index":    {c.mem.PostIndex, rebuilt.PostIndex},
+		"hashtags": {c.mem.Hashtags, rebuilt.Hashtags},
+		"mentions": {c.mem.Mentioned, rebuilt.Mentioned},
+		"reposts":  {c.mem.Reposts, rebuilt.Reposts},
+		"replies":  {c.mem.Replies, rebuilt.Replies},
+		"authored": {c.mem.Authored, rebuilt.Authored},
+	} {
+		if len(ix[0]) != len(ix[1]) || (len(ix[1]) > 0 && !reflect.DeepEqual(ix[0], ix[1])) {
+			t.Errorf("%s: %s = %v, expected %v", when, name, ix[0], ix[1])
+		}
+	}
+}
+
+func TestSearchTerms(t *testing.T) {
+	var tests = []struct {
+		text     string
+		expected []string
+	}{
+		{text: "Hello, World!", expected: []string{"hello", "world"}},
+		{text: "  spaced\tout\n", expected: []string{"spaced", "out"}},
+		{text: "Łódź CAFÉ", expected: []string{"lodz", "cafe"}},
+		// a decomposed é, e and a combining acute accent
+		{text: "cafe\u0301", expected: []string{"cafe"}},
+		{text: "Straße Æsir", expected: []string{"strasse", "aesir"}},
+		{text: "\u212Aelvin", expected: []string{"kelvin"}},
+		{text: "naïve 123 x2", expected: []string{"naive", "123", "x2"}},
+		{text: "Ελλάδα", expected: []string{"ελλάδα"}},
+		{text: "...", expected: []string{}},
+	}
+	for _, test := range tests {
+		if terms := searchTerms(test.text); !reflect.DeepEqual(terms, test.expected) {
+			t.Errorf("searchTerms(%q) = %q, expected %q", test.text, terms, test.expected)
+		}
+	}
+}
+
+func TestSearchPosts(t *testing.T) {
+	c, _ := newPostSearchClient(t,
+		"Coffee in Kraków",
+		"coffee, coffee and more COFFEE",
+		"The quick brown fox",
+		"brown, quick fox",
+		"Crème brûlée for dessert",
+	)
+	var tests = []struct {
+		query    string
+		opts     SearchOptions
+		expected []string
+	}{
+		// more matches first, then newest first
+		{query: "coffee", expected: sequence(2, 1)},
+		{query: "KRAKOW", expected: sequence(1)},
+		{query: "creme brulee", expected: sequence(5)},
+		{query: "crème BRÛLÉE", expected: sequence(5)},
+		{query: "quick fox", expected: sequence(4, 3)},
+		{query: "fox quick", expected: sequence(4, 3)},
+		// every word has to be there
+		{query: "coffee fox", expected: []string{}},
+		{query: "quick", expected: sequence(4, 3)},
+		{query: "qui", expected: []string{}},
+		// phrases are in order, word for word, punctuation aside
+		{query: `"quick brown"`, expected: sequence(3)},
+		{query: `"brown quick" fox`, expected: sequence(4)},
+		{query: `"fox quick"`, expected: []string{}},
+		{query: `"in krakow" coffee`, expected: sequence(1)},
+		// a quoted word is a word and an open quote runs to the end
+		{query: `"coffee"`, expected: sequence(2, 1)},
+		{query: `fox "the quick`, expected: sequence(3)},
+		{query: "nothing", expected: []string{}},
+		// pages
+		{query: "coffee", opts: SearchOptions{Limit: 1}, expected: sequence(2)},
+		{query: "coffee", opts: SearchOptions{Limit: 1, Offset: 1}, expected: sequence(1)},
+		{query: "coffee", opts: SearchOptions{Offset: 2}, expected: []string{}},
+	}
+	for _, test := range tests {
+		posts, err := c.SearchPosts(ctx, test.query, test.opts)
+		if err != nil || !reflect.DeepEqual(postIDs(posts), test.expected) {
+			t.Errorf("SearchPosts(%q, %+v) = %v, %v, expected %v", test.query, test.opts, postIDs(posts), err, test.expected)
+		}
+	}
+
+	var invalid = []struct {
+		query    string
+		opts     SearchOptions
+		expected error
+	}{
+		{query: "", expected: ErrEmptySearchQuery},
+		{query: "  ", expected: ErrEmptySearchQuery},
+		{query: `"" !?`, expected: ErrEmptySearchQuery},
+		{query: "coffee", opts: SearchOptions{Limit: -1}, expected: ErrInvalidListOptions},
+		{query: "coffee", opts: SearchOptions{Offset: -1}, expected: ErrInvalidListOptions},
+	}
+	for _, test := range invalid {
+		if _, err := c.SearchPosts(ctx, test.query, test.opts); !errors.Is(err, test.expected) {
+			t.Errorf("SearchPosts(%q, %+v) = %v, expected %v", test.query, test.opts, err, test.expected)
+		}
+	}
+}
+
+func TestSearchPostsFilters(t *testing.T) {
+	c, clock := newPostSearchClient(t, "news one", "news two", "news three")
+	if _, err := c.CreateDraft(ctx, "a@example.com", "news draft"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SchedulePost(ctx, "a@example.com", "news later", clock.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SoftDeletePost(ctx, sequence(1)[0], "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetPostVisibility(ctx, sequence(2)[0], "a@example.com", VisibilityPrivate); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		opts     SearchOptions
+		expected []string
+	}{
+		// without a Viewer it's what someone logged out may read
+		{expected: sequence(3)},
+		{opts: SearchOptions{IncludeDeleted: true}, expected: sequence(3, 1)},
+		{opts: SearchOptions{IncludeDeleted: true, IgnoreVisibility: true}, expected: sequence(3, 2, 1)},
+		{opts: SearchOptions{Viewer: "b@example.com"}, expected: sequence(3)},
+		{opts: SearchOptions{Viewer: "A@example.com"}, expected: sequence(3, 2)},
+	}
+	for _, test := range tests {
+		posts, err := c.SearchPosts(ctx, "news", test.opts)
+		if err != nil || !reflect.DeepEqual(postIDs(posts), test.expected) {
+			t.Errorf("SearchPosts(%+v) = %v, %v, expected %v", test.opts, postIDs(posts), err, test.expected)
+		}
+	}
+
+	// the scheduled post is found once it's out, the deactivated author's posts aren't
+	clock.Advance(time.Hour)
+	if posts, err := c.SearchPosts(ctx, "news later", SearchOptions{}); err != nil || !reflect.DeepEqual(postIDs(posts), sequence(5)) {
+		t.Errorf("SearchPosts() of a published scheduled post = %v, %v, expected %v", postIDs(posts), err, sequence(5))
+	}
+	if _, err := c.DeactivateUser(ctx, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if posts, err := c.SearchPosts(ctx, "news", SearchOptions{}); err != nil || len(posts) != 0 {
+		t.Errorf("SearchPosts() of a deactivated author = %v, %v, expected none", postIDs(posts), err)
+	}
+	if posts, err := c.SearchPosts(ctx, "news", SearchOptions{IncludeDeactivated: true, Viewer: "a@example.com"}); err != nil || len(posts) != 3 {
+		t.Errorf("SearchPosts() with IncludeDeactivated = %v, %v, expected 3 posts", postIDs(posts), err)
+	}
+}
+
+func TestPostIndex(t *testing.T) {
+	c, _ := newPostSearchClient(t, "red apple", "green apple", "red car")
+	checkPostIndex(t, c, "after CreatePost")
+	search := func(query string) []string {
+		t.Helper()
+		posts, err := c.SearchPosts(ctx, query, SearchOptions{IncludeDeleted: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return postIDs(posts)
+	}
+
+	var tests = []struct {
+		name     string
+		write    func() error
+		query    string
+		expected []string
+	}{
+		{name: "UpdatePost", write: func() error {
+			_, err := c.UpdatePost(ctx, sequence(1)[0], "a@example.com", "yellow banana")
+			return err
+		}, query: "apple", expected: sequence(2)},
+		{name: "UpdatePost new words", query: "banana", expected: sequence(1)},
+		{name: "DeletePost", write: func() error {
+			_, err := c.DeletePost(ctx, sequence(3)[0])
+			return err
+		}, query: "red", expected: []string{}},
+		// the last post with the word took it out of the index, a new one puts it back
+		{name: "CreatePost after the word was gone", write: func() error {
+			_, err := c.CreatePost(ctx, "a@example.com", "red again")
+			return err
+		}, query: "red", expected: sequence(4)},
+		{name: "SoftDeletePost keeps the words", write: func() error {
+			_, err := c.SoftDeletePost(ctx, sequence(4)[0], "a@example.com")
+			return err
+		}, query: "red", expected: sequence(4)},
+		// a write that fails leaves the index as it was, though it changed its copy
+		{name: "failed Tx", write: func() error {
+			err := c.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+				if _, err := tx.CreatePost(ctx, "a@example.com", "apple pie"); err != nil {
+					return err
+				}
+				if _, err := tx.DeletePost(ctx, sequence(2)[0]); err != nil {
+					return err
+				}
+				return errors.New("rolled back")
+			})
+			if err == nil {
+				return errors.New("Tx() succeeded")
+			}
+			return nil
+		}, query: "apple", expected: sequence(2)},
+		{name: "DeleteUser", write: func() error {
+			_, err := c.DeleteUser(ctx, "a@example.com", DeleteUserOptions{})
+			return err
+		}, query: "apple", expected: []string{}},
+	}
+	for _, test := range tests {
+		if test.write != nil {
+			if err := test.write(); err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+		}
+		checkPostIndex(t, c, test.name)
+		if ids := search(test.query); !reflect.DeepEqual(ids, test.expected) {
+			t.Errorf("%s: SearchPosts(%q) = %v, expected %v", test.name, test.query, ids, test.expected)
+		}
+	}
+	if len(c.mem.PostIndex) != 0 {
+		t.Errorf("index with no posts left = %v, expected it empty", c.mem.PostIndex)
+	}
+}
+
+func TestPostIndexReload(t *testing.T) {
+	c, _ := newPostSearchClient(t, "saved to disk")
+	// built again when the file is read
+	reopened := NewClient(dbPath(c))
+	if posts, err := reopened.SearchPosts(ctx, "DISK", SearchOptions{}); err != nil || !reflect.DeepEqual(postIDs(posts), sequence(1)) {
+		t.Errorf("SearchPosts() after reopening = %v, %v, expected %v", postIDs(posts), err, sequence(1))
+	}
+	checkPostIndex(t, reopened, "reopened")
+
+	// and by Load, Dump leaves it out
+	db, err := c.Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.PostIndex != nil {
+		t.Errorf("Dump().PostIndex = %v, expected nil", db.PostIndex)
+	}
+	memory := NewMemoryClient()
+	if err := memory.Load(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	if posts, err := memory.SearchPosts(ctx, "saved", SearchOptions{}); err != nil || !reflect.DeepEqual(postIDs(posts), sequence(1)) {
+		t.Errorf("SearchPosts() after Load() = %v, %v, expected %v", postIDs(posts), err, sequence(1))
+	}
+}
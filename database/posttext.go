@@ -0,0 +1,26 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxPostLength -
+// the most characters a post can have unless WithMaxPostLength says otherwise
+const DefaultMaxPostLength = 5000
+
+// PostText -
+// text with the whitespace around it trimmed, what a post stores. ErrEmptyPost if nothing is left,
+// ErrPostTooLong naming the limit if it's over max characters, counted in runes rather than bytes.
+// the backends call it with DefaultMaxPostLength, the Client with WithMaxPostLength's
+func PostText(text string, max int) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", ErrEmptyPost
+	}
+	if n := utf8.RuneCountInString(text); n > max {
+		return "", fmt.Errorf("%w: %d characters is over the limit of %d", ErrPostTooLong, n, max)
+	}
+	return text, nil
+}
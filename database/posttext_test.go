@@ -0,0 +1,64 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPostText(t *testing.T) {
+	var tests = []struct {
+		name     string
+		opts     []Option
+		text     string
+		stored   string
+		expected error
+	}{
+		{name: "empty", text: "", expected: ErrEmptyPost},
+		{name: "whitespace only", text: " \t\n\r ", expected: ErrEmptyPost},
+		{name: "trimmed", text: "  hello world\n", stored: "hello world"},
+		{name: "inner whitespace kept", text: "hello\n\nworld", stored: "hello\n\nworld"},
+		{name: "default at the limit", text: strings.Repeat("a", DefaultMaxPostLength), stored: strings.Repeat("a", DefaultMaxPostLength)},
+		{name: "default over the limit", text: strings.Repeat("a", DefaultMaxPostLength+1), expected: ErrPostTooLong},
+		{name: "at the limit", opts: []Option{WithMaxPostLength(10)}, text: "0123456789", stored: "0123456789"},
+		{name: "one over the limit", opts: []Option{WithMaxPostLength(10)}, text: "0123456789a", expected: ErrPostTooLong},
+		// the whitespace trimmed off doesn't count
+		{name: "at the limit once trimmed", opts: []Option{WithMaxPostLength(10)}, text: "   0123456789   ", stored: "0123456789"},
+		// 3 bytes a rune, 30 bytes in all
+		{name: "multi-byte at the limit", opts: []Option{WithMaxPostLength(10)}, text: "日本語日本語日本語日", stored: "日本語日本語日本語日"},
+		{name: "multi-byte one over the limit", opts: []Option{WithMaxPostLength(10)}, text: "日本語日本語日本語日本", expected: ErrPostTooLong},
+		// 4 bytes a rune, more bytes than the limit but only 3 characters
+		{name: "emoji", opts: []Option{WithMaxPostLength(3)}, text: "🐱🐱🐱", stored: "🐱🐱🐱"},
+	}
+	for _, test := range tests {
+		c := NewMemoryClient(test.opts...)
+		if _, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18); err != nil {
+			t.Fatal(err)
+		}
+		post, err := c.CreatePost(ctx, "test@example.com", test.text)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s: CreatePost() = %v, expected %v", test.name, err, test.expected)
+		}
+		if err == nil && post.Text != test.stored {
+			t.Errorf("%s: CreatePost() stored %q, expected %q", test.name, post.Text, test.stored)
+		}
+
+		// and the same text as an edit
+		post, err = c.CreatePost(ctx, "test@example.com", "b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		updated, err := c.UpdatePost(ctx, post.ID, "test@example.com", test.text)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s: UpdatePost() = %v, expected %v", test.name, err, test.expected)
+		}
+		if err == nil && updated.Text != test.stored {
+			t.Errorf("%s: UpdatePost() stored %q, expected %q", test.name, updated.Text, test.stored)
+		}
+		if err != nil {
+			if stored, _ := c.GetPost(ctx, post.ID); stored.Text != "b" {
+				t.Errorf("%s: refused UpdatePost() changed the text to %q", test.name, stored.Text)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// equal -
+// whether p and other are the same post, comparing EditedAt, DeletedAt and PublishAt by the time they
+// point to like User.equal, Tags, Mentions and Attachments by what's in them and Original by the post it points to
+func (p Post) equal(other Post) bool {
+	a, b := p, other
+	a.EditedAt, b.EditedAt = nil, nil
+	a.DeletedAt, b.DeletedAt = nil, nil
+	a.PublishAt, b.PublishAt = nil, nil
+	a.Tags, b.Tags = nil, nil
+	a.Mentions, b.Mentions = nil, nil
+	a.Attachments, b.Attachments = nil, nil
+	a.Original, b.Original = nil, nil
+	a.Poll, b.Poll = nil, nil
+	a.PollResults, b.PollResults = nil, nil
+	if (p.Original == nil) != (other.Original == nil) || (p.Original != nil && !p.Original.equal(*other.Original)) {
+		return false
+	}
+	return reflect.DeepEqual(a, b) && equalTimes(p.EditedAt, other.EditedAt) && equalTimes(p.DeletedAt, other.DeletedAt) &&
+		equalTimes(p.PublishAt, other.PublishAt) && equalSlices(p.Tags, other.Tags) && equalSlices(p.Mentions, other.Mentions) &&
+		equalSlices(p.Attachments, other.Attachments) && equalPolls(p.Poll, other.Poll)
+}
+
+// equalTimes reports whether a and b are both nil or point to the same instant
+func equalTimes(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// UpdatePost -
+// same as Client.UpdatePost, inside the Tx
+func (tx *Tx) UpdatePost(ctx context.Context, id, requesterEmail, newText string) (Post, error) {
+	post, _, err := tx.updatePost(id, requesterEmail, newText)
+	return post, err
+}
+
+// updatePost -
+// UpdatePost that also reports whether the post changed
+func (tx *Tx) updatePost(id, requesterEmail, newText string) (Post, bool, error) {
+	if id == "" {
+		return Post{}, false, ErrEmptyPostID
+	}
+	db, err := tx.schema()
+	if err != nil {
+		return Post{}, false, err
+	}
+	post, ok := db.livePost(id)
+	if !ok {
+		return Post{}, false, fmt.Errorf("%w: %s", ErrPostNotFound, id)
+	}
+	if err := tx.checkCanChangePost(db, post, requesterEmail); err != nil {
+		return Post{}, false, err
+	}
+	if post.RepostOf != "" {
+		return Post{}, false, fmt.Errorf("%w: %s", ErrRepostNotEditable, id)
+	}
+	newText, err = PostText(newText, tx.maxPostLength)
+	if err != nil {
+		return Post{}, false, err
+	}
+	if post.Text == newText {
+		return post, false, nil
+	}
+	// a draft or scheduled post isn't out yet, nobody saw what it said before
+	if !post.unpublished(tx.now()) {
+		editedAt := tx.now()
+		tx.recordPostEdit(post, editedAt)
+		post.EditedAt = &editedAt
+	}
+	post.Text = newText
+	post.Tags = hashtags(newText)
+	post.Mentions = db.mentions(newText, post.UserEmail)
+	db.putPost(post)
+	return post, true, nil
+}
+
+// UpdatePost -
+// replace the text of the post with id on behalf of requesterEmail, who has to be its author or
+// an admin. the author is held to what CreatePost checks, so a deactivated or soft-deleted author
+// can't edit. the post keeps its ID, author and CreatedAt and gets EditedAt set, the old text goes to
+// GetPostEditHistory and Tags and Mentions become the new text's, nothing else in the db changes and the same text again writes nothing. a draft
+// or a post scheduled for later is changed without either, a draft only by its author. the text is trimmed and checked like CreatePost's.
+// ErrEmptyPostID for an empty id, ErrPostNotFound if there's no such post or it was soft-deleted, ErrNotPostAuthor if the
+// requester may not edit it, ErrRepostNotEditable for a repost, ErrEmptyPost and ErrPostTooLong for the text
+func (c *Client) UpdatePost(ctx context.Context, id, requesterEmail, newText string) (Post, error) {
+	if id == "" {
+		return Post{}, ErrEmptyPostID
+	}
+	post := Post{}
+	err := c.update(ctx, "UpdatePost", id, func(db *Schema) error {
+		var changed bool
+		var err error
+		post, changed, err = c.newTx(db).updatePost(id, requesterEmail, newText)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+	if err != nil {
+		return Post{}, err
+	}
+	return post, nil
+}
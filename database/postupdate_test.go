@@ -0,0 +1,147 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// storedPosts is the posts of the db file of c as written, by ID
+func storedPosts(t *testing.T, c *Client) map[string]json.RawMessage {
+	t.Helper()
+	data, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := struct {
+		Posts map[string]json.RawMessage `json:"posts"`
+	}{}
+	if err := json.Unmarshal(data, &db); err != nil {
+		t.Fatal(err)
+	}
+	return db.Posts
+}
+
+func TestUpdatePost(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newBlockClient(t, WithClock(clock))
+	posts, err := c.GetPosts(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := posts[0]
+	before := storedPosts(t, c)
+
+	clock.Advance(time.Hour)
+	edited, err := c.UpdatePost(ctx, post.ID, " A@example.com", "hello, fixed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	editedAt := clock.Now().UTC()
+	expected := Post{ID: post.ID, CreatedAt: post.CreatedAt, UserEmail: "a@example.com", Text: "hello, fixed", EditedAt: &editedAt}
+	if !edited.equal(expected) {
+		t.Errorf("UpdatePost() = %+v, expected %+v", edited, expected)
+	}
+	reopened := NewClient(dbPath(c))
+	if posts, err := reopened.GetPosts(ctx, "a@example.com"); err != nil || len(posts) != 1 || !posts[0].equal(expected) {
+		t.Errorf("GetPosts() after UpdatePost() = %+v, %v, expected %+v", posts, err, expected)
+	}
+
+	// the other posts are written exactly as they were
+	after := storedPosts(t, c)
+	if len(after) != len(before) {
+		t.Fatalf("posts after UpdatePost() = %d, expected %d", len(after), len(before))
+	}
+	for id, data := range before {
+		if id != post.ID && !bytes.Equal(after[id], data) {
+			t.Errorf("post %s after UpdatePost() = %s, expected %s", id, after[id], data)
+		}
+	}
+
+	// the same text again is a no-op and keeps EditedAt
+	clock.Advance(time.Hour)
+	if again, err := c.UpdatePost(ctx, post.ID, "a@example.com", "hello, fixed"); err != nil || !again.equal(expected) {
+		t.Errorf("UpdatePost() with the same text = %+v, %v, expected %+v", again, err, expected)
+	}
+}
+
+func TestUpdatePostPermissions(t *testing.T) {
+	c := newBlockClient(t)
+	if _, err := c.BootstrapAdmin(ctx, "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, "gone@example.com", "123456", "gone", 18); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := c.GetPosts(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := posts[0].ID
+
+	var tests = []struct {
+		id        string
+		requester string
+		expected  error
+	}{
+		{id: id, requester: "b@example.com", expected: ErrNotPostAuthor},
+		{id: id, requester: "missing@example.com", expected: ErrNotPostAuthor},
+		{id: "missing", requester: "a@example.com", expected: ErrPostNotFound},
+		{id: "", requester: "a@example.com", expected: ErrEmptyPostID},
+		// admins can fix anyone's post
+		{id: id, requester: "c@example.com", expected: nil},
+	}
+	for _, test := range tests {
+		_, err := c.UpdatePost(ctx, test.id, test.requester, "edited by "+test.requester)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("UpdatePost(%q, %q) = %v, expected %v", test.id, test.requester, err, test.expected)
+		}
+		// telling a missing post from someone else's takes no string matching
+		if errors.Is(err, ErrNotPostAuthor) && errors.Is(err, ErrPostNotFound) {
+			t.Errorf("UpdatePost(%q, %q) = %v, expected only one of ErrNotPostAuthor and ErrPostNotFound", test.id, test.requester, err)
+		}
+	}
+	if posts, _ := c.GetPosts(ctx, "a@example.com"); posts[0].Text != "edited by c@example.com" {
+		t.Errorf("post after the refused edits = %q, expected only the admin's edit", posts[0].Text)
+	}
+
+	// an author who can't post can't edit either
+	if _, err := c.DeactivateUser(ctx, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UpdatePost(ctx, id, "a@example.com", "while deactivated"); !errors.Is(err, ErrAccountDeactivated) {
+		t.Errorf("UpdatePost() by a deactivated author = %v, expected ErrAccountDeactivated", err)
+	}
+	// and neither can an admin who is deactivated
+	if _, err := c.DeactivateUser(ctx, "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	other, err := c.GetPosts(ctx, "b@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UpdatePost(ctx, other[0].ID, "c@example.com", "by a deactivated admin"); !errors.Is(err, ErrNotPostAuthor) {
+		t.Errorf("UpdatePost() by a deactivated admin = %v, expected ErrNotPostAuthor", err)
+	}
+}
+
+func TestUpdatePostHook(t *testing.T) {
+	c := newBlockClient(t)
+	updated := []Post{}
+	c.OnPostUpdated(func(post Post) { updated = append(updated, post) })
+	posts, err := c.GetPosts(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"edited", "edited"} {
+		if _, err := c.UpdatePost(ctx, posts[0].ID, "a@example.com", text); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(updated) != 1 || updated[0].Text != "edited" || updated[0].EditedAt == nil {
+		t.Errorf("OnPostUpdated() calls = %+v, expected one for the edit", updated)
+	}
+}
@@ -0,0 +1,89 @@
+package database
+
+// postVotes -
+// key,value = post id,the emails of the users who picked something on it and what they picked.
+// the shape of reactions and poll votes, a nil postVotes has none
+type postVotes[V comparable] map[string]map[string]V
+
+// get -
+// what the user with email picked on the post with id, if anything
+func (v postVotes[V]) get(id, email string) (V, bool) {
+	value, ok := v[id][email]
+	return value, ok
+}
+
+// put -
+// record that the user with email picked value on the post with id, replacing what they had
+func (v *postVotes[V]) put(id, email string, value V) {
+	if *v == nil {
+		*v = make(postVotes[V])
+	}
+	if (*v)[id] == nil {
+		(*v)[id] = make(map[string]V)
+	}
+	(*v)[id][email] = value
+}
+
+// delete -
+// drop what the user with email picked on the post with id, if anything
+func (v postVotes[V]) delete(id, email string) {
+	delete(v[id], email)
+	if len(v[id]) == 0 {
+		delete(v, id)
+	}
+}
+
+// replace -
+// make values everything picked on the post with id, none drops the post. values is copied
+func (v *postVotes[V]) replace(id string, values map[string]V) {
+	delete(*v, id)
+	for email, value := range values {
+		v.put(id, email, value)
+	}
+}
+
+// move -
+// give what from picked to into, or drop it with an empty into, for a user whose email changed,
+// who was merged into another or who is gone. where into picked something on the same post too
+// its own pick is kept
+func (v *postVotes[V]) move(from, into string) {
+	for id, values := range *v {
+		value, ok := values[from]
+		if !ok {
+			continue
+		}
+		v.delete(id, from)
+		if _, ok := (*v).get(id, into); into != "" && !ok {
+			v.put(id, into, value)
+		}
+	}
+}
+
+// clone -
+// copy of the votes that can be modified without touching the original, nil stays nil
+func (v postVotes[V]) clone() postVotes[V] {
+	if v == nil {
+		return nil
+	}
+	copied := make(postVotes[V], len(v))
+	for id, values := range v {
+		copied[id] = make(map[string]V, len(values))
+		for email, value := range values {
+			copied[id][email] = value
+		}
+	}
+	return copied
+}
+
+// equalMaps reports whether a and b hold the same keys with the same values, like two posts' votes
+func equalMaps[K comparable, V comparable](a, b map[K]V) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
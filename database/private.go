@@ -0,0 +1,149 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// canSeePosts -
+// whether viewer, "" for someone logged out, may read the posts of author: not when either blocked
+// the other, and of a private author only the author and its followers may. worked out on every
+// read from the flag and the follows as they are, so SetPrivate needs no backfill
+func (db *Schema) canSeePosts(viewer, author string) bool {
+	if db.blockedEither(viewer, author) {
+		return false
+	}
+	if user, ok := db.Users[author]; ok && user.IsPrivate {
+		return viewer == author || db.Follows.has(viewer, author)
+	}
+	return true
+}
+
+// SetPrivate -
+// same as Client.SetPrivate, inside the Tx
+func (tx *Tx) SetPrivate(ctx context.Context, email string, private bool) (User, error) {
+	user, _, err := tx.setPrivate(email, private)
+	return user, err
+}
+
+// setPrivate -
+// SetPrivate that also reports whether the user changed
+func (tx *Tx) setPrivate(email string, private bool) (User, bool, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return User{}, false, err
+	}
+	email = EmailKey(email)
+	user, ok := db.activeUser(email)
+	if !ok {
+		return User{}, false, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+	if user.IsPrivate == private {
+		return user, false, nil
+	}
+	user.IsPrivate = private
+	user.UpdatedAt = tx.now()
+	db.putUser(user)
+	return user, true, nil
+}
+
+// SetPrivate -
+// lock or unlock the account of the user with email. while it's private only its followers see
+// its posts in GetPostsAs and IteratePosts, and FollowUser of it is a request the user approves
+// with ApproveFollowRequest. the account itself is still found by SearchUsers.
+// followers from before stay and requests still pending when it's unlocked can still be approved.
+// setting the flag it has changes nothing. ErrUserNotFound if there's no such user
+func (c *Client) SetPrivate(ctx context.Context, email string, private bool) (User, error) {
+	user := User{}
+	err := c.update(ctx, "SetPrivate", email, func(db *Schema) error {
+		var changed bool
+		var err error
+		user, changed, err = c.newTx(db).setPrivate(email, private)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return c.sanitize(user), nil
+}
+
+// ApproveFollowRequest -
+// same as Client.ApproveFollowRequest, inside the Tx
+func (tx *Tx) ApproveFollowRequest(ctx context.Context, email, follower string) error {
+	return tx.answerFollowRequest(email, follower, true)
+}
+
+// DenyFollowRequest -
+// same as Client.DenyFollowRequest, inside the Tx
+func (tx *Tx) DenyFollowRequest(ctx context.Context, email, follower string) error {
+	return tx.answerFollowRequest(email, follower, false)
+}
+
+// answerFollowRequest -
+// settle the pending request of follower to follow the user with email, following if approved
+func (tx *Tx) answerFollowRequest(email, follower string, approved bool) error {
+	db, err := tx.schema()
+	if err != nil {
+		return err
+	}
+	email, follower = EmailKey(email), EmailKey(follower)
+	if _, ok := db.activeUser(email); !ok {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+	if !db.FollowRequests.has(follower, email) {
+		return fmt.Errorf("%w: from %s to %s", ErrFollowRequestNotFound, follower, email)
+	}
+	db.FollowRequests.delete(follower, email)
+	if approved {
+		db.putFollow(follower, email, tx.now())
+	}
+	return nil
+}
+
+// ApproveFollowRequest -
+// have the user with email accept the pending FollowUser of follower, who follows it from then on.
+// ErrFollowRequestNotFound if follower has no pending request, ErrUserNotFound if there's no such user
+func (c *Client) ApproveFollowRequest(ctx context.Context, email, follower string) error {
+	return c.update(ctx, "ApproveFollowRequest", email, func(db *Schema) error {
+		return c.newTx(db).ApproveFollowRequest(ctx, email, follower)
+	})
+}
+
+// DenyFollowRequest -
+// have the user with email turn down the pending FollowUser of follower, like ApproveFollowRequest.
+// follower can ask again
+func (c *Client) DenyFollowRequest(ctx context.Context, email, follower string) error {
+	return c.update(ctx, "DenyFollowRequest", email, func(db *Schema) error {
+		return c.newTx(db).DenyFollowRequest(ctx, email, follower)
+	})
+}
+
+// GetFollowRequests -
+// the users waiting for the user with email to approve them following it, oldest first like GetUsers.
+// soft-deleted ones are left out until they're restored. ErrUserNotFound if there's no such user
+func (c *Client) GetFollowRequests(ctx context.Context, email string) ([]User, error) {
+	users := []User{}
+	err := c.view(ctx, "GetFollowRequests", email, func(db *Schema) error {
+		email := EmailKey(email)
+		if _, ok := db.activeUser(email); !ok {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, email)
+		}
+		for follower := range db.FollowRequests {
+			if !db.FollowRequests.has(follower, email) {
+				continue
+			}
+			if user, ok := db.activeUser(follower); ok {
+				users = append(users, c.sanitize(user))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []User{}, err
+	}
+	sortUsers(users)
+	return users, nil
+}
@@ -0,0 +1,268 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// newPrivateClient has owner@, follower@ and stranger@example.com with a post each,
+// follower@ following owner@
+func newPrivateClient(t *testing.T, opts ...Option) *Client {
+	t.Helper()
+	c := newTestClient(t, opts...)
+	for _, email := range []string{"owner@example.com", "follower@example.com", "stranger@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.CreatePost(ctx, email, "hello"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.FollowUser(ctx, "follower@example.com", "owner@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// ownerPostsSeen counts the posts of owner@ viewer sees through GetPostsAs and IteratePosts,
+// failing the test if they disagree. "" is someone logged out
+func ownerPostsSeen(t *testing.T, c *Client, viewer string) int {
+	t.Helper()
+	posts, err := c.GetPostsAs(ctx, viewer, "owner@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	opts := IterateOptions{UserEmail: "owner@example.com", Viewer: viewer, Anonymous: viewer == ""}
+	if err := c.IteratePosts(ctx, opts, func(Post) bool { n++; return true }); err != nil {
+		t.Fatal(err)
+	}
+	if n != len(posts) {
+		t.Errorf("IteratePosts(Viewer: %q) = %d posts, GetPostsAs() = %d, expected the same", viewer, n, len(posts))
+	}
+	return len(posts)
+}
+
+func TestPrivatePostVisibility(t *testing.T) {
+	c := newPrivateClient(t)
+	var tests = []struct {
+		viewer  string
+		private int
+		public  int
+	}{
+		{viewer: "owner@example.com", private: 1, public: 1},
+		{viewer: "Owner@Example.com", private: 1, public: 1},
+		{viewer: "follower@example.com", private: 1, public: 1},
+		{viewer: "stranger@example.com", private: 0, public: 1},
+		{viewer: "", private: 0, public: 1},
+	}
+	for _, private := range []bool{true, false} {
+		// takes effect on the next read, both ways
+		if _, err := c.SetPrivate(ctx, "owner@example.com", private); err != nil {
+			t.Fatal(err)
+		}
+		for _, test := range tests {
+			expected := test.public
+			if private {
+				expected = test.private
+			}
+			if got := ownerPostsSeen(t, c, test.viewer); got != expected {
+				t.Errorf("posts of owner@ seen by %q with the account private %v = %d, expected %d", test.viewer, private, got, expected)
+			}
+		}
+		// GetPublicPosts reads as someone logged out
+		expected := tests[4].public
+		if private {
+			expected = tests[4].private
+		}
+		if public, err := c.GetPublicPosts(ctx, "owner@example.com"); err != nil || len(public) != expected {
+			t.Errorf("GetPublicPosts() with the account private %v = %d posts, %v, expected %d", private, len(public), err, expected)
+		}
+	}
+
+	// the zero IterateOptions still visit everything, exports rely on it
+	if _, err := c.SetPrivate(ctx, "owner@example.com", true); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	if err := c.IteratePosts(ctx, IterateOptions{}, func(Post) bool { n++; return true }); err != nil || n != 3 {
+		t.Errorf("IteratePosts() without a viewer = %d posts, %v, expected all 3", n, err)
+	}
+	// GetPosts reads as someone logged out too
+	if posts, err := c.GetPosts(ctx, "owner@example.com"); err != nil || len(posts) != 0 {
+		t.Errorf("GetPosts() of a private user = %d posts, %v, expected none", len(posts), err)
+	}
+	// the account is still found
+	if users, err := c.SearchUsers(ctx, "owner", SearchOptions{Viewer: "stranger@example.com"}); err != nil || len(users) != 1 {
+		t.Errorf("SearchUsers() of a private user = %v, %v, expected it found", emails(users), err)
+	}
+}
+
+func TestSetPrivate(t *testing.T) {
+	store := &fakeStore{}
+	c := NewClientWithStore(store)
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "name", 18); err != nil {
+		t.Fatal(err)
+	}
+	user, err := c.SetPrivate(ctx, "Test@example.com", true)
+	if err != nil || !user.IsPrivate || user.PasswordHash != "" {
+		t.Errorf("SetPrivate() = %+v, %v, expected a private sanitized user", user, err)
+	}
+	saves := store.saves
+	if _, err := c.SetPrivate(ctx, "test@example.com", true); err != nil {
+		t.Fatal(err)
+	}
+	if store.saves != saves {
+		t.Errorf("SetPrivate() to the flag it had saved %d times, expected none", store.saves-saves)
+	}
+	if stored, _ := c.GetUser(ctx, "test@example.com"); !stored.IsPrivate {
+		t.Error("GetUser() after SetPrivate() isn't private")
+	}
+	if _, err := c.SetPrivate(ctx, "missing@example.com", true); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("SetPrivate() of a missing user = %v, expected ErrUserNotFound", err)
+	}
+}
+
+func TestFollowRequests(t *testing.T) {
+	c := newPrivateClient(t)
+	if _, err := c.SetPrivate(ctx, "owner@example.com", true); err != nil {
+		t.Fatal(err)
+	}
+	// asking twice is the one request
+	for i := 0; i < 2; i++ {
+		if err := c.FollowUser(ctx, "stranger@example.com", "owner@example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	requests, err := c.GetFollowRequests(ctx, "owner@example.com")
+	if err != nil || !reflect.DeepEqual(emails(requests), []string{"stranger@example.com"}) {
+		t.Errorf("GetFollowRequests() = %v, %v, expected stranger@", emails(requests), err)
+	}
+	if following, _ := c.GetFollowing(ctx, "stranger@example.com"); len(following) != 0 {
+		t.Errorf("GetFollowing() with the request pending = %v, expected none", emails(following))
+	}
+	if got := ownerPostsSeen(t, c, "stranger@example.com"); got != 0 {
+		t.Errorf("posts seen with the request pending = %d, expected 0", got)
+	}
+
+	if err := c.ApproveFollowRequest(ctx, "owner@example.com", "follower@example.com"); !errors.Is(err, ErrFollowRequestNotFound) {
+		t.Errorf("ApproveFollowRequest() without a request = %v, expected ErrFollowRequestNotFound", err)
+	}
+	if err := c.ApproveFollowRequest(ctx, "missing@example.com", "stranger@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("ApproveFollowRequest() by a missing user = %v, expected ErrUserNotFound", err)
+	}
+	if err := c.ApproveFollowRequest(ctx, "Owner@example.com", "STRANGER@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	checkFollowGraph(t, c, map[string][]string{
+		"owner@example.com":    {},
+		"follower@example.com": {"owner@example.com"},
+		"stranger@example.com": {"owner@example.com"},
+	})
+	if got := ownerPostsSeen(t, c, "stranger@example.com"); got != 1 {
+		t.Errorf("posts seen once approved = %d, expected 1", got)
+	}
+	if requests, _ := c.GetFollowRequests(ctx, "owner@example.com"); len(requests) != 0 {
+		t.Errorf("GetFollowRequests() after approving = %v, expected none", emails(requests))
+	}
+
+	// denied, and withdrawn by unfollowing
+	if err := c.UnfollowUser(ctx, "stranger@example.com", "owner@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.FollowUser(ctx, "stranger@example.com", "owner@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DenyFollowRequest(ctx, "owner@example.com", "stranger@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.DenyFollowRequest(ctx, "owner@example.com", "stranger@example.com"); !errors.Is(err, ErrFollowRequestNotFound) {
+		t.Errorf("DenyFollowRequest() twice = %v, expected ErrFollowRequestNotFound", err)
+	}
+	if err := c.FollowUser(ctx, "stranger@example.com", "owner@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UnfollowUser(ctx, "stranger@example.com", "owner@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if requests, _ := c.GetFollowRequests(ctx, "owner@example.com"); len(requests) != 0 {
+		t.Errorf("GetFollowRequests() after unfollowing = %v, expected none", emails(requests))
+	}
+	checkFollowGraph(t, c, map[string][]string{"stranger@example.com": {}})
+
+	// once public again, following takes effect at once and clears a request left over
+	if err := c.FollowUser(ctx, "stranger@example.com", "owner@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetPrivate(ctx, "owner@example.com", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.FollowUser(ctx, "stranger@example.com", "owner@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if db, _ := c.Dump(ctx); len(db.FollowRequests) != 0 || !db.Follows.has("stranger@example.com", "owner@example.com") {
+		t.Errorf("follows after following a public user = %v and requests %v, expected stranger@ following owner@", db.Follows, db.FollowRequests)
+	}
+}
+
+func TestBlockDropsFollowRequests(t *testing.T) {
+	c := newPrivateClient(t)
+	if _, err := c.SetPrivate(ctx, "owner@example.com", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.FollowUser(ctx, "stranger@example.com", "owner@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.BlockUser(ctx, "owner@example.com", "stranger@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if requests, _ := c.GetFollowRequests(ctx, "owner@example.com"); len(requests) != 0 {
+		t.Errorf("GetFollowRequests() after BlockUser() = %v, expected none", emails(requests))
+	}
+	// a follower that's blocked sees nothing either
+	if err := c.BlockUser(ctx, "follower@example.com", "owner@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ownerPostsSeen(t, c, "follower@example.com"); got != 0 {
+		t.Errorf("posts seen after blocking = %d, expected 0", got)
+	}
+}
+
+func TestDeleteUserDropsFollowRequests(t *testing.T) {
+	for name, opts := range map[string][]Option{"file": nil, "wal": {WithWAL(100)}} {
+		c := newPrivateClient(t, opts...)
+		for _, email := range []string{"owner@example.com", "stranger@example.com"} {
+			if _, err := c.SetPrivate(ctx, email, true); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, request := range [][2]string{{"stranger@example.com", "owner@example.com"}, {"follower@example.com", "stranger@example.com"}} {
+			if err := c.FollowUser(ctx, request[0], request[1]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := c.DeleteUser(ctx, "owner@example.com", DeleteUserOptions{}); err != nil {
+			t.Fatal(err)
+		}
+
+		// read back, only follower@ asking stranger@ is left and the flag stuck
+		reopened := NewClient(dbPath(c), opts...)
+		db, err := reopened.Dump(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(db.FollowRequests) != 1 || !db.FollowRequests.has("follower@example.com", "stranger@example.com") {
+			t.Errorf("%s: follow requests after DeleteUser() = %v, expected only follower@ asking stranger@", name, db.FollowRequests)
+		}
+		if !db.Users["stranger@example.com"].IsPrivate {
+			t.Errorf("%s: stranger@ isn't private after reopening", name)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
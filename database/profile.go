@@ -0,0 +1,136 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// longest Bio and Location UpdateProfile accepts, in characters (runes)
+const (
+	MaxBioLength      = 500
+	MaxLocationLength = 100
+)
+
+// schemes an AvatarURL or Website can have, anything else could run script in a profile page
+var profileURLSchemes = map[string]bool{"http": true, "https": true}
+
+// Profile -
+// the fields UpdateProfile changes, nil ones are left as they are and "" clears one
+type Profile struct {
+	// Bio is at most MaxBioLength characters
+	Bio *string
+	// AvatarURL and Website are absolute http or https URLs
+	AvatarURL *string
+	// Location is free text of at most MaxLocationLength characters
+	Location *string
+	Website  *string
+}
+
+// validate -
+// ErrInvalidUserField for the first field that can't be stored
+func (p Profile) validate() error {
+	if p.Bio != nil && utf8.RuneCountInString(*p.Bio) > MaxBioLength {
+		return fmt.Errorf("%w: bio is longer than %d characters", ErrInvalidUserField, MaxBioLength)
+	}
+	if p.Location != nil && utf8.RuneCountInString(*p.Location) > MaxLocationLength {
+		return fmt.Errorf("%w: location is longer than %d characters", ErrInvalidUserField, MaxLocationLength)
+	}
+	for _, field := range []struct {
+		name  string
+		value *string
+	}{
+		{"avatar URL", p.AvatarURL},
+		{"website", p.Website},
+	} {
+		if field.value == nil || *field.value == "" {
+			continue
+		}
+		u, err := url.Parse(*field.value)
+		if err != nil {
+			return fmt.Errorf("%w: %s %q: %v", ErrInvalidUserField, field.name, *field.value, err)
+		}
+		if !profileURLSchemes[strings.ToLower(u.Scheme)] || u.Host == "" {
+			return fmt.Errorf("%w: %s %q isn't an http or https URL", ErrInvalidUserField, field.name, *field.value)
+		}
+	}
+	return nil
+}
+
+// apply sets the fields of p on user, whether anything changed
+func (p Profile) apply(user *User) bool {
+	old := *user
+	for _, field := range []struct {
+		to   *string
+		from *string
+	}{
+		{&user.Bio, p.Bio},
+		{&user.AvatarURL, p.AvatarURL},
+		{&user.Location, p.Location},
+		{&user.Website, p.Website},
+	} {
+		if field.from != nil {
+			*field.to = *field.from
+		}
+	}
+	return !user.equal(old)
+}
+
+// UpdateProfile -
+// same as Client.UpdateProfile, inside the Tx
+func (tx *Tx) UpdateProfile(ctx context.Context, email string, profile Profile) (User, error) {
+	if err := profile.validate(); err != nil {
+		return User{}, err
+	}
+	user, _, err := tx.updateProfile(email, profile)
+	return user, err
+}
+
+// updateProfile -
+// UpdateProfile that also reports whether the user changed
+func (tx *Tx) updateProfile(email string, profile Profile) (User, bool, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return User{}, false, err
+	}
+	if email, err = NormalizeEmail(email); err != nil {
+		return User{}, false, err
+	}
+	user, ok := db.activeUser(email)
+	if !ok {
+		return User{}, false, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+	if !profile.apply(&user) {
+		return user, false, nil
+	}
+	user.UpdatedAt = tx.now()
+	db.putUser(user)
+	return user, true, nil
+}
+
+// UpdateProfile -
+// change the profile fields of the user with email that are set in profile, the password,
+// name and everything else are left alone. the email is trimmed like UpdateUser does.
+// ErrInvalidUserField for a bio or location that's too long or a URL that isn't http or https,
+// ErrUserNotFound if there's no such user. nothing is written when no field would change
+func (c *Client) UpdateProfile(ctx context.Context, email string, profile Profile) (User, error) {
+	if err := profile.validate(); err != nil {
+		return User{}, err
+	}
+	user := User{}
+	err := c.update(ctx, "UpdateProfile", email, func(db *Schema) error {
+		var changed bool
+		var err error
+		user, changed, err = c.newTx(db).updateProfile(email, profile)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return c.sanitize(user), nil
+}
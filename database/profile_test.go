@@ -0,0 +1,134 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpdateProfile(t *testing.T) {
+	store := &fakeStore{}
+	c := NewClientWithStore(store)
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	created, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bio, avatar, location, website := "hi there", "https://example.com/me.png", "Toronto", "http://example.com"
+
+	user, err := c.UpdateProfile(ctx, "test@example.com", Profile{Bio: &bio, Location: &location})
+	expected := created
+	expected.Bio, expected.Location = bio, location
+	expected.UpdatedAt = user.UpdatedAt
+	if err != nil || user != expected {
+		t.Errorf("UpdateProfile() of the bio and location = %+v, %v, expected %+v", user, err, expected)
+	}
+	// the rest is left alone
+	user, err = c.UpdateProfile(ctx, " test@example.com ", Profile{AvatarURL: &avatar, Website: &website})
+	expected.AvatarURL, expected.Website = avatar, website
+	expected.UpdatedAt = user.UpdatedAt
+	if err != nil || user != expected {
+		t.Errorf("UpdateProfile() of the URLs = %+v, %v, expected %+v", user, err, expected)
+	}
+	if stored, _ := c.GetUser(ctx, "test@example.com"); stored != expected || stored.PasswordHash != created.PasswordHash {
+		t.Errorf("GetUser() after UpdateProfile() = %+v, expected %+v", stored, expected)
+	}
+
+	// nothing to change, nothing written
+	saves := store.saves
+	if user, err := c.UpdateProfile(ctx, "test@example.com", Profile{}); err != nil || user != expected {
+		t.Errorf("UpdateProfile() with no fields = %+v, %v, expected %+v", user, err, expected)
+	}
+	if user, err := c.UpdateProfile(ctx, "test@example.com", Profile{Bio: &bio, Website: &website}); err != nil || user != expected {
+		t.Errorf("UpdateProfile() with the same values = %+v, %v, expected %+v", user, err, expected)
+	}
+	if store.saves != saves {
+		t.Errorf("UpdateProfile() without changes saved %d times, expected none", store.saves-saves)
+	}
+
+	// "" clears a field
+	cleared := ""
+	user, err = c.UpdateProfile(ctx, "test@example.com", Profile{Website: &cleared})
+	expected.Website = ""
+	expected.UpdatedAt = user.UpdatedAt
+	if err != nil || user != expected {
+		t.Errorf("UpdateProfile() clearing the website = %+v, %v, expected %+v", user, err, expected)
+	}
+}
+
+func TestUpdateProfileErrors(t *testing.T) {
+	c := newTestClient(t)
+	created, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	str := func(s string) *string { return &s }
+	var tests = []struct {
+		email    string
+		profile  Profile
+		expected error
+	}{
+		{email: "test@example.com", profile: Profile{Bio: str(strings.Repeat("a", MaxBioLength+1))}, expected: ErrInvalidUserField},
+		{email: "test@example.com", profile: Profile{Location: str(strings.Repeat("a", MaxLocationLength+1))}, expected: ErrInvalidUserField},
+		{email: "test@example.com", profile: Profile{AvatarURL: str("javascript:alert(1)")}, expected: ErrInvalidUserField},
+		{email: "test@example.com", profile: Profile{AvatarURL: str("ftp://example.com/me.png")}, expected: ErrInvalidUserField},
+		{email: "test@example.com", profile: Profile{Website: str("example.com")}, expected: ErrInvalidUserField},
+		{email: "test@example.com", profile: Profile{Website: str("https://")}, expected: ErrInvalidUserField},
+		{email: "test@example.com", profile: Profile{Website: str("http://exa mple.com")}, expected: ErrInvalidUserField},
+		// one bad field and nothing is changed
+		{email: "test@example.com", profile: Profile{Bio: str("fine"), Website: str("nope")}, expected: ErrInvalidUserField},
+		{email: "missing@example.com", profile: Profile{Bio: str("fine")}, expected: ErrUserNotFound},
+		{email: "not an email", profile: Profile{Bio: str("fine")}, expected: ErrInvalidEmail},
+	}
+	for _, test := range tests {
+		if _, err := c.UpdateProfile(ctx, test.email, test.profile); !errors.Is(err, test.expected) {
+			t.Errorf("UpdateProfile(%q, %+v) = %v, expected %v", test.email, test.profile, err, test.expected)
+		}
+	}
+	if user, _ := c.GetUser(ctx, "test@example.com"); user != created {
+		t.Errorf("failed UpdateProfile() calls changed the user to %+v", user)
+	}
+
+	// limits count characters, not bytes
+	if _, err := c.UpdateProfile(ctx, "test@example.com", Profile{Bio: str(strings.Repeat("é", MaxBioLength))}); err != nil {
+		t.Errorf("UpdateProfile() of a bio of %d two-byte characters = %v, expected nil", MaxBioLength, err)
+	}
+	if _, err := c.UpdateProfile(ctx, "test@example.com", Profile{Website: str("HTTPS://example.com/~me?x=1")}); err != nil {
+		t.Errorf("UpdateProfile() of an uppercase scheme = %v, expected nil", err)
+	}
+}
+
+func TestUpdateProfilePersists(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	bio := "héllo 世界 👋\n\"quoted\" <b>not bold</b>"
+	updated, err := c.UpdateProfile(ctx, "test@example.com", Profile{Bio: &bio})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened := NewClient(dbPath(c))
+	if user, err := reopened.GetUser(ctx, "test@example.com"); err != nil || user != updated || user.Bio != bio {
+		t.Errorf("GetUser() after reopening = %+v, %v, expected %+v", user, err, updated)
+	}
+}
+
+func TestProfileLegacy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	legacy := `{"schemaVersion": 1, "users": {"test@example.com": {"email": "test@example.com", "createdAt": "2022-01-02T03:04:05Z", "password": "hunter22", "name": "john doe"}}, "posts": {}}`
+	if err := os.WriteFile(path, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	user, err := NewClient(path).GetUser(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Bio != "" || user.AvatarURL != "" || user.Location != "" || user.Website != "" || user.Name != "john doe" {
+		t.Errorf("GetUser() of a record without a profile = %+v, expected empty profile fields", user)
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+)
+
+// quota -
+// the state of WithMaxSizeBytes
+type quota struct {
+	max int64
+	// size of the db as the store measures it after the last write, for Metrics
+	// atomic so it can be read without the write lock
+	size int64
+}
+
+func (q *quota) setSize(size int64) {
+	atomic.StoreInt64(&q.size, size)
+}
+
+func (q *quota) currentSize() int64 {
+	return atomic.LoadInt64(&q.size)
+}
+
+// checkQuota -
+// ErrDatabaseFull if saving db would take the db over the size limit while growing it, unless
+// it's for deleting. returns db's size, 0 without a limit. caller must hold the write lock
+func (c *Client) checkQuota(db Schema, deleting bool) (int64, error) {
+	if c.quota.max == 0 {
+		return 0, nil
+	}
+	size, err := c.storedSize(db)
+	if err != nil {
+		return 0, err
+	}
+	if size <= c.quota.max || deleting {
+		return size, nil
+	}
+	// over the limit already, fine as long as it doesn't get any bigger
+	if size > c.quota.currentSize() {
+		return 0, fmt.Errorf("%w: write would take it to %d bytes, the limit is %d", ErrDatabaseFull, size, c.quota.max)
+	}
+	return size, nil
+}
+
+// measure -
+// seed the quota's size with that of the in-memory copy after it was replaced by anything but a write,
+// checkQuota compares with it. caller must hold the write lock
+func (c *Client) measure() error {
+	if c.quota.max == 0 {
+		return nil
+	}
+	size, err := c.storedSize(*c.mem)
+	if err != nil {
+		return err
+	}
+	c.quota.setSize(size)
+	return nil
+}
+
+// storedSize is how big db is once the store saved it, as compact json unless it's a Sizer
+func (c *Client) storedSize(db Schema) (int64, error) {
+	if sizer, ok := c.store.(Sizer); ok {
+		return sizer.Size(db)
+	}
+	data, err := json.Marshal(db)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
@@ -0,0 +1,184 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMaxSizeBytes(t *testing.T) {
+	const limit = 2000
+	c := NewClient(filepath.Join(t.TempDir(), "db.json"), WithMaxSizeBytes(limit))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+
+	// fill it up to the brink
+	var err error
+	posts := 0
+	for ; posts < 100; posts++ {
+		if _, err = c.CreatePost(ctx, "test@example.com", strings.Repeat("x", 50)); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, ErrDatabaseFull) || posts == 0 {
+		t.Fatalf("CreatePost() after %d posts = %v, expected ErrDatabaseFull", posts, err)
+	}
+	m := c.Metrics()
+	if m.DataSize > limit || m.DataSize < limit-300 || m.MaxSize != limit {
+		t.Errorf("Metrics() DataSize %d and MaxSize %d, expected close to the %d limit", m.DataSize, m.MaxSize, limit)
+	}
+
+	// the rejected write leaves the file alone
+	before, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, "other@example.com", "123456", strings.Repeat("x", 300), 18); !errors.Is(err, ErrDatabaseFull) {
+		t.Fatalf("CreateUser() on a full db = %v, expected ErrDatabaseFull", err)
+	}
+	after, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("rejected write changed the file")
+	}
+	if _, err := c.GetUser(ctx, "other@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("rejected user is visible: GetUser() = %v", err)
+	}
+
+	// reads and deletes still work, and free up room
+	userPosts, err := c.GetPosts(ctx, "test@example.com")
+	if err != nil || len(userPosts) != posts {
+		t.Fatalf("GetPosts() = %d posts, %v, expected %d", len(userPosts), err, posts)
+	}
+	if _, err := c.DeletePost(ctx, userPosts[0].ID); err != nil {
+		t.Fatalf("DeletePost() on a full db = %v, expected nil", err)
+	}
+	if _, err := c.CreatePost(ctx, "test@example.com", "short"); err != nil {
+		t.Errorf("CreatePost() after freeing room = %v, expected nil", err)
+	}
+}
+
+func TestMaxSizeBytesSoftDelete(t *testing.T) {
+	// a soft delete grows the db by its DeletedAt but still goes through on a full one
+	path := filepath.Join(t.TempDir(), "db.json")
+	c := NewClient(path)
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	post, err := c.CreatePost(ctx, "test@example.com", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c = NewClient(path, WithMaxSizeBytes(info.Size()))
+	if _, err := c.CreatePost(ctx, "test@example.com", "x"); !errors.Is(err, ErrDatabaseFull) {
+		t.Fatalf("CreatePost() on a full db = %v, expected ErrDatabaseFull", err)
+	}
+	if _, err := c.SoftDeletePost(ctx, post.ID, "test@example.com"); err != nil {
+		t.Errorf("SoftDeletePost() on a full db = %v, expected nil", err)
+	}
+	if _, err := c.SoftDeleteUser(ctx, "test@example.com"); err != nil {
+		t.Errorf("SoftDeleteUser() on a full db = %v, expected nil", err)
+	}
+	if _, err := c.GetUser(ctx, "test@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUser() after SoftDeleteUser() = %v, expected ErrUserNotFound", err)
+	}
+}
+
+func TestMaxSizeBytesFileSize(t *testing.T) {
+	// the limit is on the file as written, so compression fits more posts and an indent fewer
+	const limit = 3000
+	fill := func(opts ...Option) (*Client, int) {
+		c := NewClient(filepath.Join(t.TempDir(), "db.json"), append([]Option{WithMaxSizeBytes(limit)}, opts...)...)
+		if err := c.EnsureDB(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); err != nil {
+			t.Fatal(err)
+		}
+		posts := 0
+		for ; posts < 1000; posts++ {
+			if _, err := c.CreatePost(ctx, "test@example.com", strings.Repeat("x", 50)); err != nil {
+				if !errors.Is(err, ErrDatabaseFull) {
+					t.Fatal(err)
+				}
+				break
+			}
+		}
+		return c, posts
+	}
+	plain, plainPosts := fill()
+	indented, indentedPosts := fill(WithIndent("  "))
+	compressed, compressedPosts := fill(WithCompression())
+	if indentedPosts >= plainPosts || compressedPosts <= plainPosts {
+		t.Errorf("posts under the limit: %d indented, %d plain, %d compressed, expected fewer indented and more compressed",
+			indentedPosts, plainPosts, compressedPosts)
+	}
+	for _, c := range []*Client{plain, indented, compressed} {
+		info, err := os.Stat(dbPath(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > limit || info.Size() != c.Metrics().DataSize {
+			t.Errorf("file of %d bytes with DataSize %d, expected the same and at most %d", info.Size(), c.Metrics().DataSize, limit)
+		}
+	}
+}
+
+func TestMaxSizeBytesAlreadyOver(t *testing.T) {
+	// a db that's over a newly configured limit can still shrink but not grow
+	db := Schema{Users: map[string]User{}, Posts: map[string]Post{}}
+	for i := 0; i < 20; i++ {
+		email := fmt.Sprintf("user%d@example.com", i)
+		db.Users[email] = User{Email: email, Name: strings.Repeat("x", 100)}
+	}
+	c := NewMemoryClient(WithMaxSizeBytes(100))
+	if err := c.Load(ctx, db); !errors.Is(err, ErrDatabaseFull) {
+		t.Fatalf("Load() over the limit = %v, expected ErrDatabaseFull", err)
+	}
+	// the records have no age, which the default minimum wouldn't let UpdateUser keep,
+	// and keeping the old names would make a rename grow the db
+	path := filepath.Join(t.TempDir(), "db.json")
+	c = NewClient(path)
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Load(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	c = NewClient(path, WithMaxSizeBytes(100), WithMinimumAge(0), WithNameHistoryLimit(0))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// measured on opening, not only after the first write
+	if size := c.Metrics().DataSize; size <= 100 {
+		t.Errorf("Metrics() DataSize after EnsureDB() = %d, expected the file's size over the limit", size)
+	}
+
+	if _, err := c.UpdateUser(ctx, "user0@example.com", "", "shorter", 0); err != nil {
+		t.Errorf("UpdateUser() that shrinks the db = %v, expected nil", err)
+	}
+	if _, err := c.UpdateUser(ctx, "user1@example.com", "", strings.Repeat("y", 200), 0); !errors.Is(err, ErrDatabaseFull) {
+		t.Errorf("UpdateUser() that grows the db = %v, expected ErrDatabaseFull", err)
+	}
+	if _, err := c.DeleteUser(ctx, "user2@example.com", DeleteUserOptions{}); err != nil {
+		t.Errorf("DeleteUser() = %v, expected nil", err)
+	}
+}
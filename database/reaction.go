@@ -0,0 +1,199 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxReactionLength -
+// the most characters a ReactionKind can have, enough for a word or an emoji sequence
+const MaxReactionLength = 32
+
+// ReactionKind -
+// what a user reacted to a post with, like "like" or "🎉". any short text without spaces will do
+type ReactionKind string
+
+// reactionKind -
+// kind trimmed, ErrInvalidReaction if nothing's left, it's longer than MaxReactionLength or
+// has spaces or control characters
+func reactionKind(kind ReactionKind) (ReactionKind, error) {
+	trimmed := strings.TrimSpace(string(kind))
+	if trimmed == "" {
+		return "", fmt.Errorf("%w: empty", ErrInvalidReaction)
+	}
+	if n := utf8.RuneCountInString(trimmed); n > MaxReactionLength {
+		return "", fmt.Errorf("%w: %d characters, at most %d", ErrInvalidReaction, n, MaxReactionLength)
+	}
+	if strings.IndexFunc(trimmed, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidReaction, trimmed)
+	}
+	return ReactionKind(trimmed), nil
+}
+
+// reactedPost -
+// the post with id that reactions to it go to, the post a repost shares for one.
+// ErrPostNotFound if there's no such post or it's soft-deleted
+func (db *Schema) reactedPost(id string) (Post, error) {
+	post, ok := db.livePost(id)
+	if ok && post.RepostOf != "" {
+		post, ok = db.livePost(post.RepostOf)
+	}
+	if !ok {
+		return Post{}, fmt.Errorf("%w: %s", ErrPostNotFound, id)
+	}
+	return post, nil
+}
+
+// AddReaction -
+// same as Client.AddReaction, inside the Tx
+func (tx *Tx) AddReaction(ctx context.Context, postID, userEmail string, kind ReactionKind) error {
+	_, err := tx.addReaction(postID, userEmail, kind)
+	return err
+}
+
+// addReaction -
+// AddReaction that also says whether anything changed
+func (tx *Tx) addReaction(postID, userEmail string, kind ReactionKind) (bool, error) {
+	if postID == "" {
+		return false, ErrEmptyPostID
+	}
+	kind, err := reactionKind(kind)
+	if err != nil {
+		return false, err
+	}
+	db, err := tx.schema()
+	if err != nil {
+		return false, err
+	}
+	userEmail = EmailKey(userEmail)
+	if err := tx.checkCanPost(db, userEmail); err != nil {
+		return false, err
+	}
+	post, err := db.sharedPost(postID, userEmail, tx.now())
+	if err != nil {
+		return false, err
+	}
+	if prev, ok := db.Reactions.get(post.ID, userEmail); ok && prev == kind {
+		return false, nil
+	}
+	db.Reactions.put(post.ID, userEmail, kind)
+	return true, nil
+}
+
+// RemoveReaction -
+// same as Client.RemoveReaction, inside the Tx
+func (tx *Tx) RemoveReaction(ctx context.Context, postID, userEmail string) error {
+	_, err := tx.removeReaction(postID, userEmail)
+	return err
+}
+
+// removeReaction -
+// RemoveReaction that also says whether anything changed
+func (tx *Tx) removeReaction(postID, userEmail string) (bool, error) {
+	if postID == "" {
+		return false, ErrEmptyPostID
+	}
+	db, err := tx.schema()
+	if err != nil {
+		return false, err
+	}
+	userEmail = EmailKey(userEmail)
+	if _, ok := db.activeUser(userEmail); !ok {
+		return false, fmt.Errorf("%w: %s", ErrUserNotFound, userEmail)
+	}
+	post, err := db.reactedPost(postID)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := db.Reactions.get(post.ID, userEmail); !ok {
+		return false, nil
+	}
+	db.Reactions.delete(post.ID, userEmail)
+	return true, nil
+}
+
+// AddReaction -
+// have the user with userEmail react to the post with postID with kind, trimmed. a user has at
+// most one reaction per post: another kind replaces the one they had, the same kind again is a
+// no-op. reacting to a repost reacts to the post it shares. the user is held to what CreatePost
+// checks and can only react to what a listing with them as the Viewer would show them.
+// ErrEmptyPostID for an empty id, ErrInvalidReaction for a kind reactionKind refuses,
+// ErrPostNotFound if there's no such post, it's soft-deleted or the user can't see it,
+// ErrPostNotPublished for their own draft or scheduled post
+func (c *Client) AddReaction(ctx context.Context, postID, userEmail string, kind ReactionKind) error {
+	return c.update(ctx, "AddReaction", postID, func(db *Schema) error {
+		changed, err := c.newTx(db).addReaction(postID, userEmail, kind)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+}
+
+// RemoveReaction -
+// take back the reaction of the user with userEmail to the post with postID, having none is a
+// no-op like UnfollowUser. ErrEmptyPostID for an empty id, ErrUserNotFound if there's no such user,
+// ErrPostNotFound if there's no such post or it's soft-deleted
+func (c *Client) RemoveReaction(ctx context.Context, postID, userEmail string) error {
+	return c.deleteUpdate(ctx, "RemoveReaction", postID, func(db *Schema) error {
+		changed, err := c.newTx(db).removeReaction(postID, userEmail)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+}
+
+// GetReactionCounts -
+// how many users reacted to the post with postID with each kind, kinds no one used left out.
+// a repost counts for the post it shares. ErrEmptyPostID for an empty id, ErrPostNotFound
+// if there's no such post or it's soft-deleted
+func (c *Client) GetReactionCounts(ctx context.Context, postID string) (map[ReactionKind]int, error) {
+	if postID == "" {
+		return map[ReactionKind]int{}, ErrEmptyPostID
+	}
+	counts := map[ReactionKind]int{}
+	err := c.view(ctx, "GetReactionCounts", postID, func(db *Schema) error {
+		post, err := db.reactedPost(postID)
+		if err != nil {
+			return err
+		}
+		for _, kind := range db.Reactions[post.ID] {
+			counts[kind]++
+		}
+		return nil
+	})
+	if err != nil {
+		return map[ReactionKind]int{}, err
+	}
+	return counts, nil
+}
+
+// GetUserReaction -
+// what the user with userEmail reacted to the post with postID with. ErrEmptyPostID for an empty id,
+// ErrPostNotFound if there's no such post or it's soft-deleted, ErrReactionNotFound if they didn't react
+func (c *Client) GetUserReaction(ctx context.Context, postID, userEmail string) (ReactionKind, error) {
+	if postID == "" {
+		return "", ErrEmptyPostID
+	}
+	userEmail = EmailKey(userEmail)
+	kind := ReactionKind("")
+	err := c.view(ctx, "GetUserReaction", postID, func(db *Schema) error {
+		post, err := db.reactedPost(postID)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if kind, ok = db.Reactions.get(post.ID, userEmail); !ok {
+			return fmt.Errorf("%w: %s on %s", ErrReactionNotFound, userEmail, post.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return kind, nil
+}
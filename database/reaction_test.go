@@ -0,0 +1,194 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newReactionClient is newBlockClient with the ID of a@'s "hello" post
+func newReactionClient(t *testing.T, opts ...Option) (*Client, string) {
+	t.Helper()
+	c := newBlockClient(t, opts...)
+	posts, err := c.GetPosts(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, posts[0].ID
+}
+
+func TestAddReaction(t *testing.T) {
+	c, id := newReactionClient(t)
+	draft, err := c.CreateDraft(ctx, "a@example.com", "draft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.BlockUser(ctx, "a@example.com", "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		id       string
+		email    string
+		kind     ReactionKind
+		expected error
+	}{
+		{id: id, email: "b@example.com", kind: " like ", expected: nil},
+		{id: id, email: "b@example.com", kind: "🎉", expected: nil},
+		{id: id, email: "a@example.com", kind: "like", expected: nil},
+		{id: id, email: "b@example.com", kind: "", expected: ErrInvalidReaction},
+		{id: id, email: "b@example.com", kind: "thumbs up", expected: ErrInvalidReaction},
+		{id: id, email: "b@example.com", kind: ReactionKind(strings.Repeat("x", MaxReactionLength+1)), expected: ErrInvalidReaction},
+		{id: "", email: "b@example.com", kind: "like", expected: ErrEmptyPostID},
+		{id: "missing", email: "b@example.com", kind: "like", expected: ErrPostNotFound},
+		{id: id, email: "missing@example.com", kind: "like", expected: ErrUserNotFound},
+		// blocked by the author, the post isn't there for them
+		{id: id, email: "c@example.com", kind: "like", expected: ErrPostNotFound},
+		{id: draft.ID, email: "b@example.com", kind: "like", expected: ErrPostNotFound},
+		{id: draft.ID, email: "a@example.com", kind: "like", expected: ErrPostNotPublished},
+	}
+	for _, test := range tests {
+		if err := c.AddReaction(ctx, test.id, test.email, test.kind); !errors.Is(err, test.expected) {
+			t.Errorf("AddReaction(%q, %q, %q) = %v, expected %v", test.id, test.email, test.kind, err, test.expected)
+		}
+	}
+	if kind, err := c.GetUserReaction(ctx, id, "B@example.com"); err != nil || kind != "🎉" {
+		t.Errorf("GetUserReaction() = %q, %v, expected the reaction that replaced the first", kind, err)
+	}
+	if _, err := c.GetUserReaction(ctx, id, "c@example.com"); !errors.Is(err, ErrReactionNotFound) {
+		t.Errorf("GetUserReaction() of no reaction = %v, expected ErrReactionNotFound", err)
+	}
+
+	// soft-deleted posts can't be reacted to, their reactions come back with them
+	if _, err := c.SoftDeletePost(ctx, id, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddReaction(ctx, id, "b@example.com", "like"); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("AddReaction() of a soft-deleted post = %v, expected ErrPostNotFound", err)
+	}
+	if _, err := c.GetReactionCounts(ctx, id); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("GetReactionCounts() of a soft-deleted post = %v, expected ErrPostNotFound", err)
+	}
+	if _, err := c.RestorePost(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if counts, err := c.GetReactionCounts(ctx, id); err != nil || !reflect.DeepEqual(counts, map[ReactionKind]int{"like": 1, "🎉": 1}) {
+		t.Errorf("GetReactionCounts() after RestorePost() = %v, %v, expected the reactions back", counts, err)
+	}
+}
+
+func TestGetReactionCounts(t *testing.T) {
+	c, id := newReactionClient(t)
+	repost, err := c.Repost(ctx, "b@example.com", id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		change   func() error
+		expected map[ReactionKind]int
+	}{
+		{change: func() error { return nil }, expected: map[ReactionKind]int{}},
+		{change: func() error { return c.AddReaction(ctx, id, "a@example.com", "like") }, expected: map[ReactionKind]int{"like": 1}},
+		{change: func() error { return c.AddReaction(ctx, id, "b@example.com", "like") }, expected: map[ReactionKind]int{"like": 2}},
+		// the same kind again is a no-op
+		{change: func() error { return c.AddReaction(ctx, id, "b@example.com", "like") }, expected: map[ReactionKind]int{"like": 2}},
+		{change: func() error { return c.AddReaction(ctx, id, "b@example.com", "love") }, expected: map[ReactionKind]int{"like": 1, "love": 1}},
+		// through the repost it's the original's
+		{change: func() error { return c.AddReaction(ctx, repost.ID, "c@example.com", "love") }, expected: map[ReactionKind]int{"like": 1, "love": 2}},
+		{change: func() error { return c.RemoveReaction(ctx, id, "a@example.com") }, expected: map[ReactionKind]int{"love": 2}},
+		// none to remove is a no-op
+		{change: func() error { return c.RemoveReaction(ctx, id, "a@example.com") }, expected: map[ReactionKind]int{"love": 2}},
+	}
+	for i, test := range tests {
+		if err := test.change(); err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		for _, postID := range []string{id, repost.ID} {
+			if counts, err := c.GetReactionCounts(ctx, postID); err != nil || !reflect.DeepEqual(counts, test.expected) {
+				t.Errorf("%d: GetReactionCounts(%q) = %v, %v, expected %v", i, postID, counts, err, test.expected)
+			}
+		}
+	}
+	if err := c.RemoveReaction(ctx, "missing", "a@example.com"); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("RemoveReaction() of a missing post = %v, expected ErrPostNotFound", err)
+	}
+
+	// the reactions are saved with the db, and the log
+	for _, opts := range [][]Option{nil, {WithWAL(100)}} {
+		if counts, err := NewClient(dbPath(c), opts...).GetReactionCounts(ctx, id); err != nil || !reflect.DeepEqual(counts, map[ReactionKind]int{"love": 2}) {
+			t.Errorf("GetReactionCounts() from the file = %v, %v, expected the reactions", counts, err)
+		}
+	}
+}
+
+func TestReactionsWAL(t *testing.T) {
+	c, id := newReactionClient(t, WithWAL(100))
+	if err := c.AddReaction(ctx, id, "b@example.com", "like"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddReaction(ctx, id, "c@example.com", "like"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RemoveReaction(ctx, id, "b@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if counts, err := NewClient(dbPath(c), WithWAL(100)).GetReactionCounts(ctx, id); err != nil || !reflect.DeepEqual(counts, map[ReactionKind]int{"like": 1}) {
+		t.Errorf("GetReactionCounts() replayed from the log = %v, %v, expected one like", counts, err)
+	}
+}
+
+func TestReactionCleanup(t *testing.T) {
+	c, id := newReactionClient(t)
+	posts, err := c.GetPosts(ctx, "b@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := posts[0].ID
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		for _, postID := range []string{id, other} {
+			if err := c.AddReaction(ctx, postID, email, "like"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := c.AddReaction(ctx, other, "a@example.com", "love"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a deleted post takes its reactions with it
+	if _, err := c.DeletePost(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if db, err := c.Dump(ctx); err != nil || db.Reactions[id] != nil {
+		t.Errorf("reactions to a deleted post = %v, %v, expected none", db.Reactions[id], err)
+	}
+
+	// a new email takes its reactions along, a merge keeps the one the primary had
+	if _, err := c.ChangeEmail(ctx, "c@example.com", "new@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if kind, err := c.GetUserReaction(ctx, other, "new@example.com"); err != nil || kind != "like" {
+		t.Errorf("GetUserReaction() after ChangeEmail() = %q, %v, expected like", kind, err)
+	}
+	if _, err := c.MergeUsers(ctx, "a@example.com", "new@example.com", MergeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if counts, err := c.GetReactionCounts(ctx, other); err != nil || !reflect.DeepEqual(counts, map[ReactionKind]int{"like": 1, "love": 1}) {
+		t.Errorf("GetReactionCounts() after MergeUsers() = %v, %v, expected a@'s love and b@'s like", counts, err)
+	}
+
+	// and a deleted user's are gone
+	if _, err := c.DeleteUser(ctx, "a@example.com", DeleteUserOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if counts, err := c.GetReactionCounts(ctx, other); err != nil || !reflect.DeepEqual(counts, map[ReactionKind]int{"like": 1}) {
+		t.Errorf("GetReactionCounts() after DeleteUser() = %v, %v, expected b@'s like only", counts, err)
+	}
+	db, err := c.Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (postVotes[ReactionKind]{other: {"b@example.com": "like"}}); !reflect.DeepEqual(db.Reactions, expected) {
+		t.Errorf("reactions after the cleanup = %v, expected %v", db.Reactions, expected)
+	}
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadOnlyClient(t *testing.T) {
+	writer := newTestClient(t)
+	if _, err := writer.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	post, err := writer.CreatePost(ctx, "test@example.com", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.Stat(dbPath(writer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewReadOnlyClient(dbPath(writer))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatalf("EnsureDB() on an existing file = %v, expected nil", err)
+	}
+
+	mutations := []struct {
+		name string
+		run  func() error
+	}{
+		{"CreateUser", func() error {
+			_, err := c.CreateUser(ctx, "other@example.com", "12345", "jane doe", 20)
+			return err
+		}},
+		{"UpsertUser", func() error {
+			_, err := c.UpsertUser(ctx, "test@example.com", "12345", "jane doe", 20)
+			return err
+		}},
+		{"UpdateUser", func() error {
+			_, err := c.UpdateUser(ctx, "test@example.com", "54321", "john doe", 19)
+			return err
+		}},
+		{"DeleteUser", func() error {
+			_, err := c.DeleteUser(ctx, "test@example.com", DeleteUserOptions{})
+			return err
+		}},
+		{"DeleteUser missing", func() error {
+			_, err := c.DeleteUser(ctx, "missing@example.com", DeleteUserOptions{IgnoreMissing: true})
+			return err
+		}},
+		{"CreatePost", func() error {
+			_, err := c.CreatePost(ctx, "test@example.com", "hello")
+			return err
+		}},
+		{"DeletePost", func() error {
+			_, err := c.DeletePost(ctx, post.ID)
+			return err
+		}},
+		{"Tx", func() error {
+			return c.Tx(ctx, func(ctx context.Context, tx *Tx) error { return nil })
+		}},
+		{"Load", func() error { return c.Load(ctx, Schema{}) }},
+		{"Restore", func() error { return c.Restore(ctx, dbPath(writer)) }},
+		{"EncryptInPlace", func() error {
+			return NewReadOnlyClient(dbPath(writer), WithEncryptionKey([]byte("0123456789abcdef"))).EncryptInPlace(ctx)
+		}},
+	}
+	for _, mutation := range mutations {
+		if err := mutation.run(); !errors.Is(err, ErrReadOnly) {
+			t.Errorf("%s() on a read-only client = %v, expected %v", mutation.name, err, ErrReadOnly)
+		}
+	}
+
+	// reads still work
+	if _, err := c.GetUser(ctx, "test@example.com"); err != nil {
+		t.Errorf("GetUser() = %v, expected nil", err)
+	}
+	if posts, err := c.GetPosts(ctx, "test@example.com"); err != nil || len(posts) != 1 {
+		t.Errorf("GetPosts() = %v, %v, expected 1 post", posts, err)
+	}
+	if err := c.Backup(ctx, filepath.Join(t.TempDir(), "backup.json")); err != nil {
+		t.Errorf("Backup() = %v, expected nil", err)
+	}
+
+	after, err := os.Stat(dbPath(writer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.ModTime().Equal(before.ModTime()) || after.Size() != before.Size() {
+		t.Errorf("db file changed under a read-only client: %v %d, expected %v %d",
+			after.ModTime(), after.Size(), before.ModTime(), before.Size())
+	}
+}
+
+func TestReadOnlyClientMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	c := NewReadOnlyClient(filepath.Join(dir, "db.json"))
+
+	if err := c.EnsureDB(ctx); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("EnsureDB() without a file = %v, expected %v", err, ErrReadOnly)
+	}
+	if _, err := c.GetUser(ctx, "test@example.com"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("GetUser() without a file = %v, expected %v", err, os.ErrNotExist)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("read-only client created %d files, expected none", len(entries))
+	}
+}
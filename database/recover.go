@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// corrupt db files are moved aside to path + corruptSuffix + timestamp
+const corruptSuffix = ".corrupt-"
+
+// RecoverResult -
+// what Recover did
+type RecoverResult struct {
+	// Corrupt is false when the db file could be read and Recover left it alone
+	Corrupt bool
+	// MovedTo is where the corrupt file was moved, its write-ahead log goes next to it
+	MovedTo string
+	// RestoredFrom is the backup that replaced the corrupt file,
+	// empty if no usable backup was found and an empty db was created instead
+	RestoredFrom string
+}
+
+// Recover -
+// if the db file is corrupt, move it aside and replace it with the most recent backup that can
+// be read (see WithBackupDir, the .pre-restore file left by Restore counts as one too),
+// or with an empty db if there isn't one. a readable or missing file is left alone
+func (c *Client) Recover(ctx context.Context) (RecoverResult, error) {
+	if err := ctx.Err(); err != nil {
+		return RecoverResult{}, err
+	}
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return RecoverResult{}, err
+	}
+	defer unlock()
+	return c.recover(ctx)
+}
+
+// recover -
+// Recover with the write lock already held
+func (c *Client) recover(ctx context.Context) (RecoverResult, error) {
+	file, ok := c.store.(*fileStore)
+	if !ok {
+		return RecoverResult{}, fmt.Errorf("%w: Recover needs a file-backed client", ErrNotSupported)
+	}
+	if c.readOnly {
+		return RecoverResult{}, ErrReadOnly
+	}
+	// batched writes were made on a good copy, they belong in the file before it's checked
+	if err := c.flushPending(ctx); err != nil {
+		return RecoverResult{}, err
+	}
+
+	db, err := file.Load(ctx)
+	if err == nil {
+		c.mem = &db
+		return RecoverResult{}, c.measure()
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return RecoverResult{}, nil
+	}
+	if !isCorrupt(err) {
+		return RecoverResult{}, err
+	}
+
+	result := RecoverResult{
+		Corrupt: true,
+		MovedTo: file.path + corruptSuffix + time.Now().UTC().Format("20060102T150405.000000000Z"),
+	}
+	if err := os.Rename(file.path, result.MovedTo); err != nil {
+		return RecoverResult{}, err
+	}
+	// the log may be what's damaged, and it doesn't apply to a backup either way
+	if err := os.Rename(file.walPath(), result.MovedTo+walSuffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return RecoverResult{}, err
+	}
+
+	for _, candidate := range c.backupCandidates(file) {
+		data, err := os.ReadFile(candidate)
+		if err != nil {
+			continue
+		}
+		db, _, err := file.decodeDB(data)
+		if err != nil {
+			continue
+		}
+		if err := file.replace(data, db); err != nil {
+			return RecoverResult{}, err
+		}
+		c.mem = &db
+		result.RestoredFrom = candidate
+		if err := c.measure(); err != nil {
+			return RecoverResult{}, err
+		}
+		return result, nil
+	}
+
+	db = newSchema()
+	data, err := file.encodeDB(db)
+	if err != nil {
+		return RecoverResult{}, err
+	}
+	if err := file.replace(data, db); err != nil {
+		return RecoverResult{}, err
+	}
+	c.mem = &db
+	if err := c.measure(); err != nil {
+		return RecoverResult{}, err
+	}
+	return result, nil
+}
+
+// isCorrupt reports whether err means the stored data is damaged, what Recover handles
+func isCorrupt(err error) bool {
+	return errors.Is(err, ErrDBCorrupt) || errors.Is(err, ErrChecksumMismatch)
+}
+
+// backupCandidates -
+// files Recover may restore from, most recently modified first
+func (c *Client) backupCandidates(file *fileStore) []string {
+	type candidate struct {
+		path    string
+		modTime time.Time
+	}
+	candidates := []candidate{}
+	add := func(path string) {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			return
+		}
+		candidates = append(candidates, candidate{path: path, modTime: info.ModTime()})
+	}
+
+	add(file.path + preRestoreSuffix)
+	if c.backupDir != "" {
+		entries, _ := os.ReadDir(c.backupDir)
+		for _, entry := range entries {
+			add(filepath.Join(c.backupDir, entry.Name()))
+		}
+	}
+
+	// ties are common on coarse filesystem clocks, timestamped names sort the right way then
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].modTime.Equal(candidates[j].modTime) {
+			return candidates[i].modTime.After(candidates[j].modTime)
+		}
+		return candidates[i].path > candidates[j].path
+	})
+	paths := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		paths = append(paths, candidate.path)
+	}
+	return paths
+}
@@ -0,0 +1,144 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// corruptContents are ways the db file gets damaged
+var corruptContents = []struct {
+	name     string
+	contents string
+}{
+	{name: "truncated json", contents: `{"schemaVersion":1,"users":{"test@exa`},
+	{name: "invalid utf-8", contents: "{\"schemaVersion\":1,\"users\":{},\"posts\":{},\"x\":\"\xff\xfe\"}"},
+	{name: "json array", contents: `[{"email":"test@example.com"}]`},
+}
+
+func TestParseCorrupt(t *testing.T) {
+	for _, test := range corruptContents {
+		if _, _, err := parseDB([]byte(test.contents)); !errors.Is(err, ErrDBCorrupt) {
+			t.Errorf("%s: parseDB() = %v, expected %v", test.name, err, ErrDBCorrupt)
+		}
+	}
+}
+
+func TestRecoverEmpty(t *testing.T) {
+	for _, test := range corruptContents {
+		c := newTestClient(t)
+		if err := os.WriteFile(dbPath(c), []byte(test.contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := NewClient(dbPath(c)).Recover(ctx)
+		if err != nil {
+			t.Fatalf("%s: Recover() = %v, expected nil", test.name, err)
+		}
+		if !result.Corrupt || result.RestoredFrom != "" {
+			t.Errorf("%s: Recover() = %+v, expected a corrupt file replaced by an empty db", test.name, result)
+		}
+		if !strings.HasPrefix(result.MovedTo, dbPath(c)+corruptSuffix) {
+			t.Errorf("%s: corrupt file moved to %s, expected %s<timestamp>", test.name, result.MovedTo, dbPath(c)+corruptSuffix)
+		}
+		assertFileContents(t, result.MovedTo, test.contents)
+
+		if err := c.Reload(ctx); err != nil {
+			t.Errorf("%s: Reload() after Recover = %v, expected nil", test.name, err)
+		}
+	}
+}
+
+func TestRecoverFromBackup(t *testing.T) {
+	backupDir := t.TempDir()
+	c := NewClient(filepath.Join(t.TempDir(), "db.json"), WithBackupDir(backupDir))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, "old@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Backup(ctx, filepath.Join(backupDir, "1.json")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, "new@example.com", "12345", "jane doe", 20); err != nil {
+		t.Fatal(err)
+	}
+	latest := filepath.Join(backupDir, "2.json")
+	if err := c.Backup(ctx, latest); err != nil {
+		t.Fatal(err)
+	}
+	// a newer file that isn't a db has to be skipped
+	notes := filepath.Join(backupDir, "notes.txt")
+	if err := os.WriteFile(notes, []byte("not a backup"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i, path := range []string{filepath.Join(backupDir, "1.json"), latest, notes} {
+		mtime := now.Add(time.Duration(i-3) * time.Minute)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(dbPath(c), []byte(corruptContents[0].contents), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.Recover(ctx)
+	if err != nil {
+		t.Fatalf("Recover() = %v, expected nil", err)
+	}
+	if result.RestoredFrom != latest {
+		t.Errorf("Recover() restored from %q, expected %q", result.RestoredFrom, latest)
+	}
+	for _, email := range []string{"old@example.com", "new@example.com"} {
+		if _, err := c.GetUser(ctx, email); err != nil {
+			t.Errorf("GetUser(%s) after Recover = %v, expected nil", email, err)
+		}
+	}
+}
+
+func TestRecoverHealthyFile(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.Recover(ctx)
+	if err != nil || result.Corrupt {
+		t.Errorf("Recover() on a healthy file = %+v, %v, expected nothing to do", result, err)
+	}
+	assertFileContents(t, dbPath(c), string(before))
+}
+
+func TestEnsureDBAutoRecover(t *testing.T) {
+	c := newTestClient(t)
+	if err := os.WriteFile(dbPath(c), []byte(corruptContents[2].contents), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewClient(dbPath(c)).EnsureDB(ctx); !errors.Is(err, ErrDBCorrupt) {
+		t.Errorf("EnsureDB() on a corrupt file = %v, expected %v", err, ErrDBCorrupt)
+	}
+	recovering := NewClient(dbPath(c), WithAutoRecover())
+	if err := recovering.EnsureDB(ctx); err != nil {
+		t.Fatalf("EnsureDB() with WithAutoRecover = %v, expected nil", err)
+	}
+	if _, err := recovering.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Errorf("CreateUser() after auto recover = %v, expected nil", err)
+	}
+	moved, err := filepath.Glob(dbPath(c) + corruptSuffix + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moved) != 1 {
+		t.Errorf("found %d moved corrupt files, expected 1", len(moved))
+	}
+}
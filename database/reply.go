@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// Thread -
+// a post and the posts it replies to, see GetThread
+type Thread struct {
+	// Ancestors are the posts above Post, the root first and Post's parent last
+	Ancestors []Post `json:"ancestors"`
+	Post      Post   `json:"post"`
+	// Orphaned is set when a post on the way up was deleted or soft-deleted, Ancestors then starts
+	// right below it instead of at the root
+	Orphaned bool `json:"orphaned,omitempty"`
+}
+
+// CreateReply -
+// same as Client.CreateReply, inside the Tx
+func (tx *Tx) CreateReply(ctx context.Context, userEmail, parentID, text string) (Post, error) {
+	if parentID == "" {
+		return Post{}, ErrEmptyPostID
+	}
+	return tx.CreatePostWithOptions(ctx, userEmail, text, CreatePostOptions{ParentID: parentID})
+}
+
+// CreateReply -
+// CreatePost of a reply to the post with parentID, which gets it as the ParentID. the user can reply
+// to what Repost would let them share and a reply to a repost replies to the post it shares.
+// deleting a post leaves its replies where they are, they keep the ParentID and GetThread
+// has them as Orphaned. ErrEmptyPostID for an empty parentID, ErrPostNotFound if there's no such
+// post or the user can't see it, ErrPostNotPublished for their own draft or scheduled post
+func (c *Client) CreateReply(ctx context.Context, userEmail, parentID, text string) (Post, error) {
+	if parentID == "" {
+		return Post{}, ErrEmptyPostID
+	}
+	return c.CreatePostWithOptions(ctx, userEmail, text, CreatePostOptions{ParentID: parentID})
+}
+
+// GetReplies -
+// the replies to the post with id, oldest first whatever opts.Order says so a conversation reads from
+// the top, one page at a time with the filters and cursors of GetPostsPage. a Limit of 0 is
+// DefaultPostPageSize replies. ErrEmptyPostID for an empty id, ErrPostNotFound if there's no such
+// post or it was soft-deleted, ErrInvalidListOptions and ErrInvalidCursor like GetAllPostsPage
+func (c *Client) GetReplies(ctx context.Context, id string, opts ListOptions) (PostPage, error) {
+	if id == "" {
+		return PostPage{Posts: []Post{}}, ErrEmptyPostID
+	}
+	if err := opts.validate(); err != nil {
+		return PostPage{Posts: []Post{}}, err
+	}
+	opts.Order = OldestFirst
+	opts.Viewer = EmailKey(opts.Viewer)
+	return c.indexedPosts(ctx, "GetReplies", id, opts, func(db *Schema) (map[string]int, error) {
+		if _, ok := db.livePost(id); !ok {
+			return nil, fmt.Errorf("%w: %s", ErrPostNotFound, id)
+		}
+		return db.Replies[id], nil
+	})
+}
+
+// GetReplyCount -
+// how many replies the post with id has, the soft-deleted ones left out, read from an index rather
+// than by listing them. replies to the replies aren't counted.
+// ErrEmptyPostID for an empty id, ErrPostNotFound if there's no such post or it was soft-deleted
+func (c *Client) GetReplyCount(ctx context.Context, id string) (int, error) {
+	if id == "" {
+		return 0, ErrEmptyPostID
+	}
+	count := 0
+	err := c.view(ctx, "GetReplyCount", id, func(db *Schema) error {
+		if _, ok := db.livePost(id); !ok {
+			return fmt.Errorf("%w: %s", ErrPostNotFound, id)
+		}
+		count = db.liveCount(db.Replies[id])
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetThread -
+// the post with id and the posts above it up to the root, for showing a reply in its conversation,
+// GetReplies goes the other way. like GetPost there are no viewer checks.
+// ErrEmptyPostID for an empty id, ErrPostNotFound if there's no such post or it was soft-deleted
+func (c *Client) GetThread(ctx context.Context, id string) (Thread, error) {
+	if id == "" {
+		return Thread{Ancestors: []Post{}}, ErrEmptyPostID
+	}
+	thread := Thread{Ancestors: []Post{}}
+	err := c.view(ctx, "GetThread", id, func(db *Schema) error {
+		post, ok := db.livePost(id)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrPostNotFound, id)
+		}
+		thread.Post = post
+		// an imported db could have a loop, seen stops the walk going round it
+		seen := map[string]bool{post.ID: true}
+		for post.ParentID != "" && !seen[post.ParentID] {
+			seen[post.ParentID] = true
+			if post, ok = db.livePost(post.ParentID); !ok {
+				thread.Orphaned = true
+				break
+			}
+			thread.Ancestors = append(thread.Ancestors, post)
+		}
+		return nil
+	})
+	if err != nil {
+		return Thread{Ancestors: []Post{}}, err
+	}
+	// walked from the post up, read from the root down
+	for i, j := 0, len(thread.Ancestors)-1; i < j; i, j = i+1, j-1 {
+		thread.Ancestors[i], thread.Ancestors[j] = thread.Ancestors[j], thread.Ancestors[i]
+	}
+	return thread, nil
+}
@@ -0,0 +1,191 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// newThreadClient is newBlockClient with a three-level thread under a@'s "hello": b@ replies to it,
+// c@ replies to b@ and then to a@ too. the posts are root, reply, nested and second
+func newThreadClient(t *testing.T) (*Client, map[string]Post) {
+	t.Helper()
+	c := newBlockClient(t, WithIDGenerator(&SequenceIDGenerator{}))
+	posts, err := c.GetPosts(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	thread := map[string]Post{"root": posts[0]}
+	for _, reply := range []struct{ name, email, parent string }{
+		{"reply", "b@example.com", "root"},
+		{"nested", "c@example.com", "reply"},
+		{"second", "c@example.com", "root"},
+	} {
+		post, err := c.CreateReply(ctx, reply.email, thread[reply.parent].ID, reply.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		thread[reply.name] = post
+	}
+	return c, thread
+}
+
+// threadIDs is the IDs of thread's ancestors then its post's
+func threadIDs(thread Thread) []string {
+	return append(postIDs(thread.Ancestors), thread.Post.ID)
+}
+
+func TestCreateReply(t *testing.T) {
+	c, thread := newThreadClient(t)
+	followers, err := c.CreatePostWithOptions(ctx, "a@example.com", "followers", CreatePostOptions{Visibility: VisibilityFollowers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	draft, err := c.CreateDraft(ctx, "a@example.com", "draft")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repost, err := c.Repost(ctx, "b@example.com", thread["root"].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		email    string
+		parentID string
+		parent   string
+		expected error
+	}{
+		{email: "c@example.com", parentID: thread["nested"].ID, parent: thread["nested"].ID, expected: nil},
+		// a reply to a repost is one to what it shares
+		{email: "c@example.com", parentID: repost.ID, parent: thread["root"].ID, expected: nil},
+		{email: "a@example.com", parentID: followers.ID, parent: followers.ID, expected: nil},
+		{email: "b@example.com", parentID: followers.ID, expected: ErrPostNotFound},
+		{email: "b@example.com", parentID: draft.ID, expected: ErrPostNotFound},
+		{email: "a@example.com", parentID: draft.ID, expected: ErrPostNotPublished},
+		{email: "b@example.com", parentID: "missing", expected: ErrPostNotFound},
+		{email: "b@example.com", parentID: "", expected: ErrEmptyPostID},
+		{email: "missing@example.com", parentID: thread["root"].ID, expected: ErrUserNotFound},
+	}
+	for _, test := range tests {
+		post, err := c.CreateReply(ctx, test.email, test.parentID, "a reply")
+		if !errors.Is(err, test.expected) {
+			t.Errorf("CreateReply(%q, %q) = %v, expected %v", test.email, test.parentID, err, test.expected)
+		}
+		if err == nil && (post.ParentID != test.parent || post.Text != "a reply") {
+			t.Errorf("CreateReply(%q, %q) = %+v, expected a reply to %s", test.email, test.parentID, post, test.parent)
+		}
+	}
+	if _, err := c.CreateReply(ctx, "b@example.com", thread["root"].ID, "  "); !errors.Is(err, ErrEmptyPost) {
+		t.Errorf("CreateReply() without text = %v, expected ErrEmptyPost", err)
+	}
+	checkPostIndex(t, c, "after the replies")
+}
+
+func TestGetThread(t *testing.T) {
+	c, thread := newThreadClient(t)
+	var tests = []struct {
+		id       string
+		expected []string
+	}{
+		{id: thread["root"].ID, expected: []string{thread["root"].ID}},
+		{id: thread["reply"].ID, expected: []string{thread["root"].ID, thread["reply"].ID}},
+		{id: thread["nested"].ID, expected: []string{thread["root"].ID, thread["reply"].ID, thread["nested"].ID}},
+		{id: thread["second"].ID, expected: []string{thread["root"].ID, thread["second"].ID}},
+	}
+	for _, test := range tests {
+		got, err := c.GetThread(ctx, test.id)
+		if err != nil || got.Orphaned || !reflect.DeepEqual(threadIDs(got), test.expected) {
+			t.Errorf("GetThread(%q) = %v, %v, %v, expected %v", test.id, threadIDs(got), got.Orphaned, err, test.expected)
+		}
+	}
+	for _, id := range []string{"", "missing"} {
+		if _, err := c.GetThread(ctx, id); err == nil {
+			t.Errorf("GetThread(%q) = nil, expected an error", id)
+		}
+	}
+}
+
+func TestGetReplies(t *testing.T) {
+	c, thread := newThreadClient(t)
+	var tests = []struct {
+		id       string
+		opts     ListOptions
+		expected []string
+	}{
+		{id: thread["root"].ID, expected: []string{thread["reply"].ID, thread["second"].ID}},
+		{id: thread["root"].ID, opts: ListOptions{Order: NewestFirst, Limit: 1}, expected: []string{thread["reply"].ID}},
+		{id: thread["root"].ID, opts: ListOptions{Offset: 1}, expected: []string{thread["second"].ID}},
+		{id: thread["root"].ID, opts: ListOptions{Authors: []string{"c@example.com"}}, expected: []string{thread["second"].ID}},
+		{id: thread["reply"].ID, expected: []string{thread["nested"].ID}},
+		{id: thread["nested"].ID, expected: []string{}},
+	}
+	for _, test := range tests {
+		page, err := c.GetReplies(ctx, test.id, test.opts)
+		if err != nil || !reflect.DeepEqual(postIDs(page.Posts), test.expected) {
+			t.Errorf("GetReplies(%q, %+v) = %v, %v, expected %v", test.id, test.opts, postIDs(page.Posts), err, test.expected)
+		}
+	}
+
+	// a page at a time with the cursor
+	page, err := c.GetReplies(ctx, thread["root"].ID, ListOptions{Limit: 1})
+	if err != nil || page.Total != 2 || page.NextCursor == "" {
+		t.Fatalf("GetReplies() first page = %+v, %v, expected a cursor and a total of 2", page, err)
+	}
+	if page, err = c.GetReplies(ctx, thread["root"].ID, ListOptions{Limit: 1, Cursor: page.NextCursor}); err != nil || !reflect.DeepEqual(postIDs(page.Posts), []string{thread["second"].ID}) {
+		t.Errorf("GetReplies() second page = %v, %v, expected %v", postIDs(page.Posts), err, []string{thread["second"].ID})
+	}
+
+	var counts = []struct {
+		id       string
+		expected int
+	}{
+		{id: thread["root"].ID, expected: 2},
+		{id: thread["reply"].ID, expected: 1},
+		{id: thread["nested"].ID, expected: 0},
+	}
+	for _, test := range counts {
+		if n, err := c.GetReplyCount(ctx, test.id); err != nil || n != test.expected {
+			t.Errorf("GetReplyCount(%q) = %d, %v, expected %d", test.id, n, err, test.expected)
+		}
+	}
+	if _, err := c.GetReplies(ctx, "missing", ListOptions{}); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("GetReplies() of a missing post = %v, expected ErrPostNotFound", err)
+	}
+	if _, err := c.GetReplyCount(ctx, ""); !errors.Is(err, ErrEmptyPostID) {
+		t.Errorf("GetReplyCount() of an empty id = %v, expected ErrEmptyPostID", err)
+	}
+}
+
+func TestDeletedParent(t *testing.T) {
+	c, thread := newThreadClient(t)
+	// the middle of the thread goes, the reply below it stays as an orphan
+	if _, err := c.DeletePost(ctx, thread["reply"].ID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.GetThread(ctx, thread["nested"].ID)
+	if err != nil || !got.Orphaned || !reflect.DeepEqual(threadIDs(got), []string{thread["nested"].ID}) || got.Post.ParentID != thread["reply"].ID {
+		t.Errorf("GetThread() below a deleted post = %v, %v, %+v, %v, expected an orphan", threadIDs(got), got.Orphaned, got.Post, err)
+	}
+	if _, err := c.GetReplies(ctx, thread["reply"].ID, ListOptions{}); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("GetReplies() of a deleted post = %v, expected ErrPostNotFound", err)
+	}
+	if n, err := c.GetReplyCount(ctx, thread["root"].ID); err != nil || n != 1 {
+		t.Errorf("GetReplyCount() after deleting a reply = %d, %v, expected 1", n, err)
+	}
+
+	// a soft-deleted root orphans its replies until it's restored
+	if _, err := c.SoftDeletePost(ctx, thread["root"].ID, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.GetThread(ctx, thread["second"].ID); err != nil || !got.Orphaned || len(got.Ancestors) != 0 {
+		t.Errorf("GetThread() under a soft-deleted root = %v, %v, %v, expected an orphan", threadIDs(got), got.Orphaned, err)
+	}
+	if _, err := c.RestorePost(ctx, thread["root"].ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.GetThread(ctx, thread["second"].ID); err != nil || got.Orphaned || !reflect.DeepEqual(threadIDs(got), []string{thread["root"].ID, thread["second"].ID}) {
+		t.Errorf("GetThread() after restoring the root = %v, %v, %v, expected the whole thread", threadIDs(got), got.Orphaned, err)
+	}
+	checkPostIndex(t, c, "after the deletes")
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// Repository -
+// the user and post operations every backend provides
+// *Client is the json implementation, see the sqlite sub-package for another
+type Repository interface {
+	EnsureDB(ctx context.Context) error
+	CreateUser(ctx context.Context, email, password, name string, age int) (User, error)
+	GetUser(ctx context.Context, email string) (User, error)
+	UpdateUser(ctx context.Context, email, password, name string, age int) (User, error)
+	// VerifyPassword checks a password against the stored hash, which the reads leave out
+	VerifyPassword(ctx context.Context, email, password string) error
+	DeleteUser(ctx context.Context, email string, opts DeleteUserOptions) (DeleteUserResult, error)
+	CreatePost(ctx context.Context, userEmail, text string) (Post, error)
+	// GetPosts lists newest first, in SortPosts order
+	GetPosts(ctx context.Context, userEmail string) ([]Post, error)
+	DeletePost(ctx context.Context, id string) (Post, error)
+}
+
+var _ Repository = (*Client)(nil)
+
+// PublicRecords -
+// the part of db a Repository that only has users and posts can hold without showing more than the
+// json Client does, at now: every user but the soft-deleted ones, and the posts GetPosts lists for
+// someone logged out. drafts, posts scheduled for later, soft-deleted posts, posts that aren't public
+// and those of private, deactivated and soft-deleted users are left out. for the importers
+func PublicRecords(db Schema, now time.Time) Schema {
+	public := Schema{
+		SchemaVersion: db.SchemaVersion,
+		Users:         make(map[string]User, len(db.Users)),
+		Posts:         make(map[string]Post, len(db.Posts)),
+	}
+	for email, user := range db.Users {
+		if user.DeletedAt == nil {
+			public.Users[email] = user
+		}
+	}
+	for id, post := range db.Posts {
+		if post.DeletedAt != nil || post.unpublished(now) || !db.canSeePost("", post) {
+			continue
+		}
+		// posts left behind by a deleted user have no author to check
+		if author, ok := db.Users[post.UserEmail]; ok && (author.DeletedAt != nil || !author.Active()) {
+			continue
+		}
+		public.Posts[id] = post
+	}
+	return public
+}
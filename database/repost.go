@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// original -
+// the post repost shares as a listing with opts shows it at now: nil once it's deleted or soft-deleted,
+// or when listedPost would leave it out for opts' viewer
+func (db *Schema) original(repost Post, opts ListOptions, now time.Time) *Post {
+	post, ok := db.livePost(repost.RepostOf)
+	list := ListOptions{Viewer: opts.Viewer, Anonymous: opts.Anonymous, IgnoreVisibility: opts.IgnoreVisibility}
+	if !ok || !db.listedPost(post, list, nil, now) {
+		return nil
+	}
+	return &post
+}
+
+// sharedPost -
+// the post with id for the user with email to repost or reply to at now, the post a repost shares for
+// one. ErrPostNotFound if there's no such post or a listing with them as the Viewer would leave it out,
+// so it isn't given away that it's there, ErrPostNotPublished for their own draft or scheduled post
+func (db *Schema) sharedPost(id, email string, now time.Time) (Post, error) {
+	post, ok := db.livePost(id)
+	if ok && post.RepostOf != "" {
+		post, ok = db.livePost(post.RepostOf)
+	}
+	if !ok {
+		return Post{}, fmt.Errorf("%w: %s", ErrPostNotFound, id)
+	}
+	if post.UserEmail == email && post.unpublished(now) {
+		return Post{}, fmt.Errorf("%w: %s", ErrPostNotPublished, post.ID)
+	}
+	if !db.listedPost(post, ListOptions{Viewer: email}, nil, now) {
+		return Post{}, fmt.Errorf("%w: %s", ErrPostNotFound, id)
+	}
+	return post, nil
+}
+
+// liveCount -
+// how many of ids, a map of an index, are posts that aren't soft-deleted
+func (db *Schema) liveCount(ids map[string]int) int {
+	count := 0
+	for id := range ids {
+		if _, ok := db.livePost(id); ok {
+			count++
+		}
+	}
+	return count
+}
+
+// withOriginals -
+// posts with Original set on the reposts, for a listing with opts.IncludeOriginals
+func (db *Schema) withOriginals(posts []Post, opts ListOptions, now time.Time) {
+	for i, post := range posts {
+		if post.RepostOf != "" {
+			posts[i].Original = db.original(post, opts, now)
+		}
+	}
+}
+
+// Repost -
+// same as Client.Repost, inside the Tx
+func (tx *Tx) Repost(ctx context.Context, userEmail, originalID string) (Post, error) {
+	if originalID == "" {
+		return Post{}, ErrEmptyPostID
+	}
+	db, err := tx.schema()
+	if err != nil {
+		return Post{}, err
+	}
+	userEmail = EmailKey(userEmail)
+	if err := tx.checkCanPost(db, userEmail); err != nil {
+		return Post{}, err
+	}
+	now := tx.now()
+	original, err := db.sharedPost(originalID, userEmail, now)
+	if err != nil {
+		return Post{}, err
+	}
+	for id := range db.Reposts[original.ID] {
+		if repost, ok := db.livePost(id); ok && repost.UserEmail == userEmail {
+			return Post{}, fmt.Errorf("%w: %s by %s", ErrAlreadyReposted, original.ID, userEmail)
+		}
+	}
+	id, err := tx.newPostID(db)
+	if err != nil {
+		return Post{}, err
+	}
+	post := Post{
+		ID:        id,
+		CreatedAt: now.UTC(),
+		UserEmail: userEmail,
+		RepostOf:  original.ID,
+	}
+	db.putPost(post)
+	return post, nil
+}
+
+// Repost -
+// share the post with originalID on the profile of the user with userEmail, as a new post of theirs
+// with RepostOf set and no text, a repost of a repost shares the post it shares. the user is held to
+// what CreatePost checks and gets to share what a listing with them as the Viewer would show them.
+// deleting the original, soft or for good, leaves its reposts where they are and unavailable: their
+// Original is nil in listings until it's restored, while deleting a repost leaves the original alone.
+// ErrEmptyPostID for an empty id, ErrPostNotFound if there's no such post or the user can't see it,
+// ErrPostNotPublished for their own draft or scheduled post, ErrAlreadyReposted if they shared it already
+func (c *Client) Repost(ctx context.Context, userEmail, originalID string) (Post, error) {
+	post := Post{}
+	err := c.update(ctx, "Repost", userEmail, func(db *Schema) error {
+		var err error
+		post, err = c.newTx(db).Repost(ctx, userEmail, originalID)
+		return err
+	})
+	if err != nil {
+		return Post{}, err
+	}
+	return post, nil
+}
+
+// GetRepostCount -
+// how many reposts the post with id has, the soft-deleted ones left out. a repost of a repost
+// counts for the post it shares. ErrEmptyPostID for an empty id, ErrPostNotFound if there's no such post
+func (c *Client) GetRepostCount(ctx context.Context, id string) (int, error) {
+	if id == "" {
+		return 0, ErrEmptyPostID
+	}
+	count := 0
+	err := c.view(ctx, "GetRepostCount", id, func(db *Schema) error {
+		if _, ok := db.livePost(id); !ok {
+			return fmt.Errorf("%w: %s", ErrPostNotFound, id)
+		}
+		count = db.liveCount(db.Reposts[id])
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,269 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newRepostClient is newBlockClient where c@ follows a@, with the ID of a@'s "hello" post and
+// a@'s followers-only, private, draft and scheduled posts
+func newRepostClient(t *testing.T) (*Client, map[string]string) {
+	t.Helper()
+	c := newBlockClient(t)
+	if err := c.FollowUser(ctx, "c@example.com", "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	posts, err := c.GetPosts(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]string{"public": posts[0].ID}
+	for name, opts := range map[string]CreatePostOptions{
+		"followers": {Visibility: VisibilityFollowers},
+		"private":   {Visibility: VisibilityPrivate},
+		"draft":     {Draft: true},
+		"scheduled": {PublishAt: time.Now().Add(time.Hour)},
+	} {
+		post, err := c.CreatePostWithOptions(ctx, "a@example.com", name, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = post.ID
+	}
+	return c, ids
+}
+
+func TestRepost(t *testing.T) {
+	c, ids := newRepostClient(t)
+	deleted, err := c.CreatePost(ctx, "a@example.com", "gone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SoftDeletePost(ctx, deleted.ID, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		email    string
+		id       string
+		expected error
+	}{
+		{email: "b@example.com", id: ids["public"], expected: nil},
+		{email: "B@Example.com", id: ids["public"], expected: ErrAlreadyReposted},
+		{email: "a@example.com", id: ids["public"], expected: nil},
+		{email: "a@example.com", id: ids["public"], expected: ErrAlreadyReposted},
+		{email: "b@example.com", id: ids["followers"], expected: ErrPostNotFound},
+		{email: "c@example.com", id: ids["followers"], expected: nil},
+		{email: "c@example.com", id: ids["private"], expected: ErrPostNotFound},
+		{email: "b@example.com", id: ids["draft"], expected: ErrPostNotFound},
+		{email: "a@example.com", id: ids["draft"], expected: ErrPostNotPublished},
+		{email: "c@example.com", id: ids["scheduled"], expected: ErrPostNotFound},
+		{email: "a@example.com", id: ids["scheduled"], expected: ErrPostNotPublished},
+		{email: "b@example.com", id: deleted.ID, expected: ErrPostNotFound},
+		{email: "b@example.com", id: "missing", expected: ErrPostNotFound},
+		{email: "b@example.com", id: "", expected: ErrEmptyPostID},
+		{email: "missing@example.com", id: ids["public"], expected: ErrUserNotFound},
+	}
+	for _, test := range tests {
+		post, err := c.Repost(ctx, test.email, test.id)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("Repost(%q, %q) = %v, expected %v", test.email, test.id, err, test.expected)
+		}
+		if err == nil && (post.RepostOf != test.id || post.Text != "" || post.UserEmail != EmailKey(test.email)) {
+			t.Errorf("Repost(%q, %q) = %+v, expected a repost of it by the user", test.email, test.id, post)
+		}
+	}
+
+	// a repost of a repost shares the original, which c@ only gets to do once
+	reposts, err := c.GetPosts(ctx, "b@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	post, err := c.Repost(ctx, "c@example.com", reposts[0].ID)
+	if err != nil || post.RepostOf != ids["public"] {
+		t.Errorf("Repost() of a repost = %+v, %v, expected a repost of %s", post, err, ids["public"])
+	}
+	if _, err := c.Repost(ctx, "c@example.com", ids["public"]); !errors.Is(err, ErrAlreadyReposted) {
+		t.Errorf("Repost() of the original after reposting the repost = %v, expected ErrAlreadyReposted", err)
+	}
+	// one that was soft-deleted doesn't count
+	if _, err := c.SoftDeletePost(ctx, post.ID, "c@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Repost(ctx, "c@example.com", ids["public"]); err != nil {
+		t.Errorf("Repost() after soft-deleting the repost = %v, expected nil", err)
+	}
+
+	if _, err := c.UpdatePost(ctx, reposts[0].ID, "b@example.com", "my words"); !errors.Is(err, ErrRepostNotEditable) {
+		t.Errorf("UpdatePost() of a repost = %v, expected ErrRepostNotEditable", err)
+	}
+	if _, err := c.UpdatePostAttachments(ctx, reposts[0].ID, "b@example.com", []Attachment{pngAttachment}); !errors.Is(err, ErrRepostNotEditable) {
+		t.Errorf("UpdatePostAttachments() of a repost = %v, expected ErrRepostNotEditable", err)
+	}
+	checkPostIndex(t, c, "after reposting")
+}
+
+func TestRepostOriginals(t *testing.T) {
+	c, ids := newRepostClient(t)
+	public, err := c.Repost(ctx, "c@example.com", ids["public"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	followers, err := c.Repost(ctx, "c@example.com", ids["followers"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	originals := func(opts ListOptions) map[string]string {
+		t.Helper()
+		page, err := c.GetPostsPage(ctx, "c@example.com", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shown := map[string]string{}
+		for _, post := range page.Posts {
+			if post.RepostOf == "" {
+				continue
+			}
+			shown[post.ID] = ""
+			if post.Original != nil {
+				shown[post.ID] = post.Original.ID
+			}
+		}
+		return shown
+	}
+
+	var tests = []struct {
+		opts     ListOptions
+		expected map[string]string
+	}{
+		{opts: ListOptions{}, expected: map[string]string{public.ID: "", followers.ID: ""}},
+		{opts: ListOptions{IncludeOriginals: true}, expected: map[string]string{public.ID: ids["public"], followers.ID: ""}},
+		{opts: ListOptions{IncludeOriginals: true, IgnoreVisibility: true}, expected: map[string]string{public.ID: ids["public"], followers.ID: ids["followers"]}},
+		{opts: ListOptions{IncludeOriginals: true, Viewer: "c@example.com"}, expected: map[string]string{public.ID: ids["public"], followers.ID: ids["followers"]}},
+		// the repost is there for anyone, the original only for who may read it
+		{opts: ListOptions{IncludeOriginals: true, Viewer: "b@example.com"}, expected: map[string]string{public.ID: ids["public"], followers.ID: ""}},
+		{opts: ListOptions{IncludeOriginals: true, Anonymous: true}, expected: map[string]string{public.ID: ids["public"], followers.ID: ""}},
+	}
+	for _, test := range tests {
+		if shown := originals(test.opts); !reflect.DeepEqual(shown, test.expected) {
+			t.Errorf("GetPostsPage(%+v) originals = %v, expected %v", test.opts, shown, test.expected)
+		}
+	}
+
+	// the reposts stay when the original goes, unavailable until it's back
+	opts := ListOptions{IncludeOriginals: true, Viewer: "c@example.com"}
+	if _, err := c.SoftDeletePost(ctx, ids["public"], "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if shown := originals(opts); !reflect.DeepEqual(shown, map[string]string{public.ID: "", followers.ID: ids["followers"]}) {
+		t.Errorf("originals after soft-deleting one = %v, expected it unavailable", shown)
+	}
+	if _, err := c.RestorePost(ctx, ids["public"]); err != nil {
+		t.Fatal(err)
+	}
+	if shown := originals(opts); shown[public.ID] != ids["public"] {
+		t.Errorf("originals after restoring one = %v, expected it back", shown)
+	}
+	if _, err := c.DeactivateUser(ctx, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if shown := originals(opts); !reflect.DeepEqual(shown, map[string]string{public.ID: "", followers.ID: ""}) {
+		t.Errorf("originals with their author deactivated = %v, expected them unavailable", shown)
+	}
+	if _, err := c.ReactivateUser(ctx, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DeletePost(ctx, ids["public"]); err != nil {
+		t.Fatal(err)
+	}
+	if shown := originals(opts); !reflect.DeepEqual(shown, map[string]string{public.ID: "", followers.ID: ids["followers"]}) {
+		t.Errorf("originals after deleting one = %v, expected it unavailable", shown)
+	}
+
+	// GetAllPostsPage hydrates the same way
+	tagged, err := c.CreatePost(ctx, "b@example.com", "#go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repost, err := c.Repost(ctx, "a@example.com", tagged.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := c.GetAllPostsPage(ctx, ListOptions{IncludeOriginals: true, Authors: []string{"a@example.com"}})
+	if err != nil || len(page.Posts) == 0 || page.Posts[0].ID != repost.ID || page.Posts[0].Original == nil || !page.Posts[0].Original.equal(tagged) {
+		t.Errorf("GetAllPostsPage() = %+v, %v, expected %s first with %+v", page.Posts, err, repost.ID, tagged)
+	}
+	checkPostIndex(t, c, "after the deletes")
+}
+
+func TestGetRepostCount(t *testing.T) {
+	c, ids := newRepostClient(t)
+	count := func(id string) int {
+		t.Helper()
+		n, err := c.GetRepostCount(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if n := count(ids["public"]); n != 0 {
+		t.Errorf("GetRepostCount() of a post no one shared = %d, expected 0", n)
+	}
+	reposts := []Post{}
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		repost, err := c.Repost(ctx, email, ids["public"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		reposts = append(reposts, repost)
+	}
+	if _, err := c.Repost(ctx, "c@example.com", reposts[1].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		change   func() error
+		id       string
+		expected int
+	}{
+		{change: func() error { return nil }, id: ids["public"], expected: 3},
+		{change: func() error { return nil }, id: reposts[1].ID, expected: 0},
+		{change: func() error {
+			_, err := c.SoftDeletePost(ctx, reposts[0].ID, "a@example.com")
+			return err
+		}, id: ids["public"], expected: 2},
+		{change: func() error {
+			_, err := c.RestorePost(ctx, reposts[0].ID)
+			return err
+		}, id: ids["public"], expected: 3},
+		{change: func() error {
+			_, err := c.DeletePost(ctx, reposts[1].ID)
+			return err
+		}, id: ids["public"], expected: 2},
+		{change: func() error {
+			_, err := c.DeleteUser(ctx, "c@example.com", DeleteUserOptions{})
+			return err
+		}, id: ids["public"], expected: 1},
+	}
+	for i, test := range tests {
+		if err := test.change(); err != nil {
+			t.Fatal(err)
+		}
+		if n := count(test.id); n != test.expected {
+			t.Errorf("%d: GetRepostCount(%q) = %d, expected %d", i, test.id, n, test.expected)
+		}
+	}
+
+	if _, err := c.GetRepostCount(ctx, ""); !errors.Is(err, ErrEmptyPostID) {
+		t.Errorf("GetRepostCount() of an empty id = %v, expected ErrEmptyPostID", err)
+	}
+	if _, err := c.DeletePost(ctx, ids["public"]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetRepostCount(ctx, ids["public"]); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("GetRepostCount() of a deleted post = %v, expected ErrPostNotFound", err)
+	}
+	checkPostIndex(t, c, "after the deletes")
+}
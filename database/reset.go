@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// DefaultResetTokenTTL -
+// how long a password reset token can be redeemed unless WithResetTokenTTL says otherwise
+const DefaultResetTokenTTL = time.Hour
+
+// random bytes in a reset or verification token, enough that guessing one is hopeless
+const tokenBytes = 32
+
+// ResetToken -
+// a password reset as stored, keyed by the sha256 of the token the user was sent.
+// the token itself is never stored, a copy of the db file is no use for resetting passwords
+type ResetToken struct {
+	UserEmail string    `json:"userEmail"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// UsedAt is zero until the token is redeemed. used tokens are kept until they expire
+	// so a second attempt gets ErrResetTokenUsed and not ErrResetTokenInvalid
+	UsedAt time.Time `json:"usedAt"`
+}
+
+// hashToken is the key a token is stored under, a plain sha256 is enough
+// since the token is random and not something a person picked
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newToken is a random token to send to a user, safe to put in a URL
+func newToken() (string, error) {
+	raw := make([]byte, tokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// putResetToken -
+// store reset under key, making the map on first use
+func (db *Schema) putResetToken(key string, reset ResetToken) {
+	if db.ResetTokens == nil {
+		db.ResetTokens = make(map[string]ResetToken)
+	}
+	db.ResetTokens[key] = reset
+}
+
+// redeemable -
+// the token stored under key if it can still be redeemed at now, the error saying why not otherwise
+func (db *Schema) redeemable(key string, now time.Time) (ResetToken, error) {
+	reset, ok := db.ResetTokens[key]
+	switch {
+	case !ok:
+		return ResetToken{}, ErrResetTokenInvalid
+	case !reset.UsedAt.IsZero():
+		return ResetToken{}, ErrResetTokenUsed
+	case !now.Before(reset.ExpiresAt):
+		return ResetToken{}, ErrResetTokenExpired
+	}
+	return reset, nil
+}
+
+// CreatePasswordResetToken -
+// a new token that lets the user with email set a password without knowing the current one,
+// for a "forgot password" link. it expires after WithResetTokenTTL and works once.
+// only its hash is stored, the token can't be looked up again. ErrUserNotFound if there's no such user
+func (c *Client) CreatePasswordResetToken(ctx context.Context, email string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	err = c.update(ctx, "CreatePasswordResetToken", email, func(db *Schema) error {
+		email, err := NormalizeEmail(email)
+		if err != nil {
+			return err
+		}
+		if _, ok := db.activeUser(email); !ok {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, email)
+		}
+		now := c.newTx(db).now()
+		db.putResetToken(hashToken(token), ResetToken{
+			UserEmail: email,
+			CreatedAt: now,
+			ExpiresAt: now.Add(c.resetTokenTTL),
+		})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RedeemPasswordResetToken -
+// set the password of the token's user to newPassword, checked against the policy like any new password.
+// ErrResetTokenInvalid for a token that was never issued (or was purged or superseded),
+// ErrResetTokenExpired and ErrResetTokenUsed for what they say. on success the user's other
+// outstanding tokens stop working, in the same write
+func (c *Client) RedeemPasswordResetToken(ctx context.Context, token, newPassword string) error {
+	key := hashToken(token)
+	// bcrypt is slow on purpose, don't spend it on a token that's no good
+	err := c.view(ctx, "RedeemPasswordResetToken", "", func(db *Schema) error {
+		_, err := db.redeemable(key, c.newTx(db).now())
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	hash, err := newPasswordHash(newPassword, c.passwordPolicy, c.passwordCost)
+	if err != nil {
+		return err
+	}
+
+	return c.update(ctx, "RedeemPasswordResetToken", "", func(db *Schema) error {
+		// checked again, another redeem may have got there first
+		now := c.newTx(db).now()
+		reset, err := db.redeemable(key, now)
+		if err != nil {
+			return err
+		}
+		user, ok := db.activeUser(reset.UserEmail)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, reset.UserEmail)
+		}
+		user.PasswordHash = hash
+		user.UpdatedAt = now
+		db.putUser(user)
+
+		for other, outstanding := range db.ResetTokens {
+			if other != key && outstanding.UserEmail == user.Email && outstanding.UsedAt.IsZero() {
+				delete(db.ResetTokens, other)
+			}
+		}
+		reset.UsedAt = now
+		db.putResetToken(key, reset)
+		return nil
+	})
+}
+
+// PurgeExpiredResetTokens -
+// remove every reset token past its expiry, used or not, in a single write
+// returns how many were removed, nothing is written if there are none
+func (c *Client) PurgeExpiredResetTokens(ctx context.Context) (int, error) {
+	purged := 0
+	err := c.deleteUpdate(ctx, "PurgeExpiredResetTokens", "", func(db *Schema) error {
+		now := c.newTx(db).now()
+		for key, reset := range db.ResetTokens {
+			if !now.Before(reset.ExpiresAt) {
+				delete(db.ResetTokens, key)
+				purged++
+			}
+		}
+		if purged == 0 {
+			return errNoop
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
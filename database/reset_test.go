@@ -0,0 +1,190 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newResetClient has one user and a clock that only moves when told to
+func newResetClient(t *testing.T, opts ...Option) (*Client, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)}
+	c := NewClient(filepath.Join(t.TempDir(), "db.json"), append([]Option{WithClock(clock)}, opts...)...)
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	return c, clock
+}
+
+func TestPasswordResetToken(t *testing.T) {
+	c, _ := newResetClient(t)
+	token, err := c.CreatePasswordResetToken(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), token) || !strings.Contains(string(data), hashToken(token)) {
+		t.Errorf("db file should have the hash of the token and not the token: %s", data)
+	}
+
+	if err := c.RedeemPasswordResetToken(ctx, token, "battery staple"); err != nil {
+		t.Fatalf("RedeemPasswordResetToken() = %v, expected nil", err)
+	}
+	if err := c.VerifyPassword(ctx, "test@example.com", "battery staple"); err != nil {
+		t.Errorf("VerifyPassword() of the new password = %v, expected nil", err)
+	}
+	if err := c.RedeemPasswordResetToken(ctx, token, "another one"); !errors.Is(err, ErrResetTokenUsed) {
+		t.Errorf("second RedeemPasswordResetToken() = %v, expected ErrResetTokenUsed", err)
+	}
+	if err := c.VerifyPassword(ctx, "test@example.com", "battery staple"); err != nil {
+		t.Errorf("VerifyPassword() after reusing the token = %v, expected the password unchanged", err)
+	}
+
+	var tests = []struct {
+		email    string
+		expected error
+	}{
+		{email: "missing@example.com", expected: ErrUserNotFound},
+		{email: "not an email", expected: ErrInvalidEmail},
+	}
+	for _, test := range tests {
+		if _, err := c.CreatePasswordResetToken(ctx, test.email); !errors.Is(err, test.expected) {
+			t.Errorf("CreatePasswordResetToken(%q) = %v, expected %v", test.email, err, test.expected)
+		}
+	}
+	if err := c.RedeemPasswordResetToken(ctx, "made up", "battery staple"); !errors.Is(err, ErrResetTokenInvalid) {
+		t.Errorf("RedeemPasswordResetToken() of an unknown token = %v, expected ErrResetTokenInvalid", err)
+	}
+}
+
+func TestPasswordResetTokenExpiry(t *testing.T) {
+	c, clock := newResetClient(t, WithResetTokenTTL(time.Hour))
+	token, err := c.CreatePasswordResetToken(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	if err := c.RedeemPasswordResetToken(ctx, token, "battery staple"); !errors.Is(err, ErrResetTokenExpired) {
+		t.Errorf("RedeemPasswordResetToken() at the expiry = %v, expected ErrResetTokenExpired", err)
+	}
+	if err := c.VerifyPassword(ctx, "test@example.com", "correct horse"); err != nil {
+		t.Errorf("VerifyPassword() after an expired token = %v, expected the password unchanged", err)
+	}
+
+	// one more that is still good
+	fresh, err := c.CreatePasswordResetToken(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour - time.Second)
+	purged, err := c.PurgeExpiredResetTokens(ctx)
+	if err != nil || purged != 1 {
+		t.Errorf("PurgeExpiredResetTokens() = %d, %v, expected 1", purged, err)
+	}
+	if err := c.RedeemPasswordResetToken(ctx, token, "battery staple"); !errors.Is(err, ErrResetTokenInvalid) {
+		t.Errorf("RedeemPasswordResetToken() of a purged token = %v, expected ErrResetTokenInvalid", err)
+	}
+	if err := c.RedeemPasswordResetToken(ctx, fresh, "battery staple"); err != nil {
+		t.Errorf("RedeemPasswordResetToken() before the expiry = %v, expected nil", err)
+	}
+	if purged, err := c.PurgeExpiredResetTokens(ctx); err != nil || purged != 0 {
+		t.Errorf("PurgeExpiredResetTokens() with nothing expired = %d, %v, expected 0", purged, err)
+	}
+}
+
+func TestPasswordResetTokenInvalidatesOthers(t *testing.T) {
+	c, _ := newResetClient(t)
+	if _, err := c.CreateUser(ctx, "other@example.com", "correct horse", "jane doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	first, err := c.CreatePasswordResetToken(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.CreatePasswordResetToken(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	others, err := c.CreatePasswordResetToken(ctx, "other@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.RedeemPasswordResetToken(ctx, second, "battery staple"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RedeemPasswordResetToken(ctx, first, "another one"); !errors.Is(err, ErrResetTokenInvalid) {
+		t.Errorf("RedeemPasswordResetToken() of a superseded token = %v, expected ErrResetTokenInvalid", err)
+	}
+	// other users' tokens are left alone
+	if err := c.RedeemPasswordResetToken(ctx, others, "battery staple"); err != nil {
+		t.Errorf("RedeemPasswordResetToken() of another user's token = %v, expected nil", err)
+	}
+}
+
+func TestPasswordResetTokenPolicy(t *testing.T) {
+	c, _ := newResetClient(t, WithPasswordPolicy(DefaultPasswordPolicy))
+	token, err := c.CreatePasswordResetToken(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RedeemPasswordResetToken(ctx, token, "password"); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("RedeemPasswordResetToken() with a weak password = %v, expected ErrWeakPassword", err)
+	}
+	// a weak password doesn't use the token up
+	if err := c.RedeemPasswordResetToken(ctx, token, "battery staple"); err != nil {
+		t.Errorf("RedeemPasswordResetToken() after a weak password = %v, expected nil", err)
+	}
+}
+
+func TestPasswordResetTokenPersists(t *testing.T) {
+	for name, opts := range map[string][]Option{"file": nil, "wal": {WithWAL(100)}} {
+		c, clock := newResetClient(t, opts...)
+		used, err := c.CreatePasswordResetToken(ctx, "test@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := c.RedeemPasswordResetToken(ctx, used, "battery staple"); err != nil {
+			t.Fatal(err)
+		}
+		token, err := c.CreatePasswordResetToken(ctx, "test@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		reopened := NewClient(dbPath(c), append([]Option{WithClock(clock)}, opts...)...)
+		if err := reopened.RedeemPasswordResetToken(ctx, used, "another one"); !errors.Is(err, ErrResetTokenUsed) {
+			t.Errorf("%s: RedeemPasswordResetToken() of a used token after reopening = %v, expected ErrResetTokenUsed", name, err)
+		}
+		if err := reopened.RedeemPasswordResetToken(ctx, token, "another one"); err != nil {
+			t.Errorf("%s: RedeemPasswordResetToken() after reopening = %v, expected nil", name, err)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDeleteUserDropsResetTokens(t *testing.T) {
+	c, _ := newResetClient(t)
+	token, err := c.CreatePasswordResetToken(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DeleteUser(ctx, "test@example.com", DeleteUserOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RedeemPasswordResetToken(ctx, token, "battery staple"); !errors.Is(err, ErrResetTokenInvalid) {
+		t.Errorf("RedeemPasswordResetToken() of a deleted user's token = %v, expected ErrResetTokenInvalid", err)
+	}
+}
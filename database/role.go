@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// Role -
+// what a user may do beyond their own records, see SetUserRole
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+// valid reports whether r is one of the roles above
+func (r Role) valid() bool {
+	return r == RoleUser || r == RoleModerator || r == RoleAdmin
+}
+
+// EffectiveRole -
+// the user's Role, RoleUser for records from before roles
+func (u User) EffectiveRole() Role {
+	if u.Role == "" {
+		return RoleUser
+	}
+	return u.Role
+}
+
+// IsAdmin -
+// whether the user is RoleAdmin
+func (u User) IsAdmin() bool {
+	return u.EffectiveRole() == RoleAdmin
+}
+
+// IsModerator -
+// whether the user can moderate, which admins can too
+func (u User) IsModerator() bool {
+	role := u.EffectiveRole()
+	return role == RoleModerator || role == RoleAdmin
+}
+
+// admins counts the users with RoleAdmin that aren't soft-deleted
+func (db *Schema) admins() int {
+	n := 0
+	for _, user := range db.Users {
+		if user.DeletedAt == nil && user.IsAdmin() {
+			n++
+		}
+	}
+	return n
+}
+
+// setRole -
+// give the user with email role, the user and whether it changed
+func (tx *Tx) setRole(email string, role Role) (User, bool, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return User{}, false, err
+	}
+	if !role.valid() {
+		return User{}, false, fmt.Errorf("%w: %q", ErrInvalidRole, role)
+	}
+	if email, err = NormalizeEmail(email); err != nil {
+		return User{}, false, err
+	}
+	user, ok := db.activeUser(email)
+	if !ok {
+		return User{}, false, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+	if user.EffectiveRole() == role {
+		return user, false, nil
+	}
+	// nobody could ever make another admin
+	if user.IsAdmin() && db.admins() == 1 {
+		return User{}, false, fmt.Errorf("%w: %s is the last admin", ErrPermissionDenied, email)
+	}
+	user.Role = role
+	user.UpdatedAt = tx.now()
+	db.putUser(user)
+	return user, true, nil
+}
+
+// setUserRole -
+// SetUserRole that also reports whether the target changed
+func (tx *Tx) setUserRole(actorEmail, targetEmail string, role Role) (User, bool, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return User{}, false, err
+	}
+	// an unknown or deactivated actor is refused the same as one that isn't an admin
+	actorEmail, _ = NormalizeEmail(actorEmail)
+	if actor, ok := db.activeUser(actorEmail); !ok || !actor.Active() || !actor.IsAdmin() {
+		return User{}, false, fmt.Errorf("%w: %s isn't an admin", ErrPermissionDenied, actorEmail)
+	}
+	return tx.setRole(targetEmail, role)
+}
+
+// SetUserRole -
+// same as Client.SetUserRole, inside the Tx
+func (tx *Tx) SetUserRole(ctx context.Context, actorEmail, targetEmail string, role Role) (User, error) {
+	user, _, err := tx.setUserRole(actorEmail, targetEmail, role)
+	return user, err
+}
+
+// SetUserRole -
+// give the user targetEmail role on behalf of actorEmail, who has to be an active admin,
+// ErrPermissionDenied otherwise. admins can change their own role too, but the last admin
+// can't stop being one. ErrInvalidRole for an unknown role, ErrUserNotFound if there's no such target.
+// nothing is written if the target already has the role
+func (c *Client) SetUserRole(ctx context.Context, actorEmail, targetEmail string, role Role) (User, error) {
+	user := User{}
+	err := c.update(ctx, "SetUserRole", targetEmail, func(db *Schema) error {
+		var changed bool
+		var err error
+		user, changed, err = c.newTx(db).setUserRole(actorEmail, targetEmail, role)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return c.sanitize(user), nil
+}
+
+// BootstrapAdmin -
+// make the user with email the first admin, for setting up a new deployment.
+// ErrPermissionDenied once there's an admin, from then on use SetUserRole
+func (c *Client) BootstrapAdmin(ctx context.Context, email string) (User, error) {
+	user := User{}
+	err := c.update(ctx, "BootstrapAdmin", email, func(db *Schema) error {
+		if db.admins() > 0 {
+			return fmt.Errorf("%w: there already is an admin", ErrPermissionDenied)
+		}
+		var err error
+		user, _, err = c.newTx(db).setRole(email, RoleAdmin)
+		return err
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return c.sanitize(user), nil
+}
+
+// IsAdmin -
+// whether the user with email is an admin, ErrUserNotFound if there's no such user
+func (c *Client) IsAdmin(ctx context.Context, email string) (bool, error) {
+	user, err := c.GetUser(ctx, email)
+	if err != nil {
+		return false, err
+	}
+	return user.IsAdmin(), nil
+}
+
+// IsModerator -
+// whether the user with email can moderate, admins included. ErrUserNotFound if there's no such user
+func (c *Client) IsModerator(ctx context.Context, email string) (bool, error) {
+	user, err := c.GetUser(ctx, email)
+	if err != nil {
+		return false, err
+	}
+	return user.IsModerator(), nil
+}
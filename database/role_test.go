@@ -0,0 +1,144 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newRoleClient has admin@example.com as the admin and two regular users
+func newRoleClient(t *testing.T) *Client {
+	t.Helper()
+	c := newTestClient(t)
+	for _, email := range []string{"admin@example.com", "a@example.com", "b@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "correct horse", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.BootstrapAdmin(ctx, "admin@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestSetUserRole(t *testing.T) {
+	c := newRoleClient(t)
+	if isAdmin, err := c.IsAdmin(ctx, "admin@example.com"); err != nil || !isAdmin {
+		t.Errorf("IsAdmin() after BootstrapAdmin() = %v, %v, expected true", isAdmin, err)
+	}
+	if _, err := c.BootstrapAdmin(ctx, "a@example.com"); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("second BootstrapAdmin() = %v, expected ErrPermissionDenied", err)
+	}
+
+	moderator, err := c.SetUserRole(ctx, "admin@example.com", "a@example.com", RoleModerator)
+	if err != nil || moderator.Role != RoleModerator {
+		t.Fatalf("SetUserRole() = %+v, %v, expected a moderator", moderator, err)
+	}
+	var checks = []struct {
+		email       string
+		isAdmin     bool
+		isModerator bool
+	}{
+		{email: "admin@example.com", isAdmin: true, isModerator: true},
+		{email: "a@example.com", isAdmin: false, isModerator: true},
+		{email: "b@example.com", isAdmin: false, isModerator: false},
+	}
+	for _, check := range checks {
+		isAdmin, err := c.IsAdmin(ctx, check.email)
+		if err != nil || isAdmin != check.isAdmin {
+			t.Errorf("IsAdmin(%q) = %v, %v, expected %v", check.email, isAdmin, err, check.isAdmin)
+		}
+		isModerator, err := c.IsModerator(ctx, check.email)
+		if err != nil || isModerator != check.isModerator {
+			t.Errorf("IsModerator(%q) = %v, %v, expected %v", check.email, isModerator, err, check.isModerator)
+		}
+	}
+	if _, err := c.IsAdmin(ctx, "missing@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("IsAdmin() of a missing user = %v, expected ErrUserNotFound", err)
+	}
+
+	// the role survives everything the user can do to their own record
+	if _, err := c.UpdateUser(ctx, "a@example.com", "battery staple", "new name", 30); err != nil {
+		t.Fatal(err)
+	}
+	name := "newer name"
+	if _, err := c.UpdateUserFields(ctx, "a@example.com", UserUpdate{Name: &name}); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := c.GetUser(ctx, "a@example.com"); err != nil || user.Role != RoleModerator {
+		t.Errorf("Role after UpdateUser() = %q, %v, expected %q", user.Role, err, RoleModerator)
+	}
+
+	// a second admin can demote the first, but the last one can't be
+	if _, err := c.SetUserRole(ctx, "admin@example.com", "b@example.com", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetUserRole(ctx, "b@example.com", "admin@example.com", RoleUser); err != nil {
+		t.Errorf("SetUserRole() demoting another admin = %v, expected nil", err)
+	}
+	if _, err := c.SetUserRole(ctx, "b@example.com", "b@example.com", RoleUser); !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("SetUserRole() demoting the last admin = %v, expected ErrPermissionDenied", err)
+	}
+}
+
+func TestSetUserRoleErrors(t *testing.T) {
+	c := newRoleClient(t)
+	if _, err := c.SetUserRole(ctx, "admin@example.com", "a@example.com", RoleModerator); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, "deactivated@example.com", "correct horse", "name", 18); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetUserRole(ctx, "admin@example.com", "deactivated@example.com", RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DeactivateUser(ctx, "deactivated@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name     string
+		actor    string
+		target   string
+		role     Role
+		expected error
+	}{
+		{name: "regular user", actor: "b@example.com", target: "b@example.com", role: RoleAdmin, expected: ErrPermissionDenied},
+		{name: "moderator", actor: "a@example.com", target: "b@example.com", role: RoleModerator, expected: ErrPermissionDenied},
+		{name: "deactivated admin", actor: "deactivated@example.com", target: "b@example.com", role: RoleModerator, expected: ErrPermissionDenied},
+		{name: "missing actor", actor: "missing@example.com", target: "b@example.com", role: RoleModerator, expected: ErrPermissionDenied},
+		{name: "unknown role", actor: "admin@example.com", target: "b@example.com", role: "superuser", expected: ErrInvalidRole},
+		{name: "empty role", actor: "admin@example.com", target: "b@example.com", role: "", expected: ErrInvalidRole},
+		{name: "missing target", actor: "admin@example.com", target: "missing@example.com", role: RoleModerator, expected: ErrUserNotFound},
+	}
+	for _, test := range tests {
+		if _, err := c.SetUserRole(ctx, test.actor, test.target, test.role); !errors.Is(err, test.expected) {
+			t.Errorf("%s: SetUserRole() = %v, expected %v", test.name, err, test.expected)
+		}
+	}
+	if user, _ := c.GetUser(ctx, "b@example.com"); user.EffectiveRole() != RoleUser {
+		t.Errorf("Role after refused SetUserRole() calls = %q, expected %q", user.EffectiveRole(), RoleUser)
+	}
+}
+
+func TestRoleLegacy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	legacy := `{"schemaVersion": 1, "users": {"test@example.com": {"email": "test@example.com", "createdAt": "2022-01-02T03:04:05Z", "password": "hunter22"}}, "posts": {}}`
+	if err := os.WriteFile(path, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(path)
+	user, err := c.GetUser(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != "" || user.EffectiveRole() != RoleUser || user.IsModerator() {
+		t.Errorf("GetUser() of a record without a role = %+v, expected RoleUser", user)
+	}
+	// and a new user isn't anything more either
+	created, err := c.CreateUser(ctx, "new@example.com", "correct horse", "name", 18)
+	if err != nil || created.EffectiveRole() != RoleUser {
+		t.Errorf("CreateUser() role = %q, %v, expected %q", created.EffectiveRole(), err, RoleUser)
+	}
+}
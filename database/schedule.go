@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// unpublished -
+// whether p isn't out at now, a draft or a post scheduled for later. only its author gets to see it
+func (p Post) unpublished(now time.Time) bool {
+	return p.IsDraft || (p.PublishAt != nil && now.Before(*p.PublishAt))
+}
+
+// SchedulePost -
+// same as Client.SchedulePost, inside the Tx
+func (tx *Tx) SchedulePost(ctx context.Context, authorEmail, text string, publishAt time.Time) (Post, error) {
+	return tx.CreatePostWithOptions(ctx, authorEmail, text, CreatePostOptions{PublishAt: publishAt})
+}
+
+// CancelScheduledPost -
+// same as Client.CancelScheduledPost, inside the Tx
+func (tx *Tx) CancelScheduledPost(ctx context.Context, id, requesterEmail string) (Post, error) {
+	if id == "" {
+		return Post{}, ErrEmptyPostID
+	}
+	db, err := tx.schema()
+	if err != nil {
+		return Post{}, err
+	}
+	post, ok := db.livePost(id)
+	if !ok {
+		return Post{}, fmt.Errorf("%w: %s", ErrPostNotFound, id)
+	}
+	if err := tx.checkCanChangePost(db, post, requesterEmail); err != nil {
+		return Post{}, err
+	}
+	if post.PublishAt == nil || !tx.now().Before(*post.PublishAt) {
+		return Post{}, fmt.Errorf("%w: %s", ErrNotScheduled, id)
+	}
+	db.deletePost(id)
+	return post, nil
+}
+
+// SchedulePost -
+// CreatePost of a post that goes out at publishAt: until then it's left out of every listing and feed
+// and GetPostAs finds it only for the author, GetScheduledPosts lists it. from then on it's like any
+// other post created at publishAt, whether or not PublishDue ran. a publishAt that isn't in the future
+// posts it right away
+func (c *Client) SchedulePost(ctx context.Context, authorEmail, text string, publishAt time.Time) (Post, error) {
+	return c.CreatePostWithOptions(ctx, authorEmail, text, CreatePostOptions{PublishAt: publishAt})
+}
+
+// GetScheduledPosts -
+// the posts of authorEmail that are scheduled and not out yet, the one going out first first
+func (c *Client) GetScheduledPosts(ctx context.Context, authorEmail string) ([]Post, error) {
+	scheduled := []Post{}
+	err := c.view(ctx, "GetScheduledPosts", authorEmail, func(db *Schema) error {
+		authorEmail, now := EmailKey(authorEmail), c.clock.Now()
+		i := 0
+		for _, post := range db.Posts {
+			// full scan, bail out if the caller gave up
+			if i++; i%cancelCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			if post.UserEmail == authorEmail && !post.IsDraft && post.DeletedAt == nil && post.unpublished(now) {
+				scheduled = append(scheduled, post)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []Post{}, err
+	}
+	// CreatedAt is PublishAt
+	SortPosts(scheduled, OldestFirst)
+	return scheduled, nil
+}
+
+// CancelScheduledPost -
+// delete the scheduled post with id before it goes out, on behalf of requesterEmail who has to be
+// its author or an admin like UpdatePost. ErrNotScheduled if it wasn't scheduled or it's out already,
+// DeletePost is for those, otherwise the errors of UpdatePost
+func (c *Client) CancelScheduledPost(ctx context.Context, id, requesterEmail string) (Post, error) {
+	post := Post{}
+	err := c.update(ctx, "CancelScheduledPost", id, func(db *Schema) error {
+		var err error
+		post, err = c.newTx(db).CancelScheduledPost(ctx, id, requesterEmail)
+		return err
+	})
+	if err != nil {
+		return Post{}, err
+	}
+	return post, nil
+}
+
+// PublishDue -
+// mark every scheduled post whose PublishAt has come published, clearing PublishAt so OnPostUpdated
+// hooks hear about each once. reads don't wait for it, see SchedulePost. returns how many were
+// published, all in one write and nothing written if there are none. WithScheduledPublishing calls it
+func (c *Client) PublishDue(ctx context.Context) (int, error) {
+	published := 0
+	err := c.update(ctx, "PublishDue", "", func(db *Schema) error {
+		now := c.clock.Now()
+		i := 0
+		for _, post := range db.Posts {
+			// full scan, bail out if the caller gave up
+			if i++; i%cancelCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			if post.PublishAt != nil && !now.Before(*post.PublishAt) {
+				post.PublishAt = nil
+				db.putPost(post)
+				published++
+			}
+		}
+		if published == 0 {
+			return errNoop
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return published, nil
+}
+
+// publishEvery -
+// call PublishDue every interval until Close, for WithScheduledPublishing
+func (c *Client) publishEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			if _, err := c.PublishDue(context.Background()); err != nil {
+				c.logError("publishing scheduled posts failed", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSchedulePost(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)}
+	c := newBlockClient(t, WithClock(clock))
+	published := []Post{}
+	c.OnPostUpdated(func(post Post) { published = append(published, post) })
+	nine := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	post, err := c.SchedulePost(ctx, "a@example.com", "announcement", nine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.PublishAt == nil || !post.PublishAt.Equal(nine) || !post.CreatedAt.Equal(nine) {
+		t.Errorf("SchedulePost() = %+v, expected it created and published at %v", post, nine)
+	}
+
+	// listed is whether every read path shows the post, scheduled whether GetScheduledPosts does
+	check := func(when string, listed, scheduled bool) {
+		t.Helper()
+		seen := map[string]bool{}
+		own, err := c.GetPosts(ctx, "a@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		all, err := c.GetAllPosts(ctx, ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		feed := []Post{}
+		if err := c.IteratePosts(ctx, IterateOptions{Viewer: "b@example.com"}, func(p Post) bool {
+			feed = append(feed, p)
+			return true
+		}); err != nil {
+			t.Fatal(err)
+		}
+		for name, posts := range map[string][]Post{"GetPosts": own, "GetAllPosts": all, "IteratePosts": feed} {
+			found := false
+			for _, p := range posts {
+				found = found || p.ID == post.ID
+			}
+			seen[name] = found
+		}
+		_, err = c.GetPostAs(ctx, "b@example.com", post.ID)
+		seen["GetPostAs"] = err == nil
+		for name, found := range seen {
+			if found != listed {
+				t.Errorf("%s: %s shows the post = %v, expected %v", when, name, found, listed)
+			}
+		}
+		if _, err := c.GetPostAs(ctx, "a@example.com", post.ID); err != nil {
+			t.Errorf("%s: GetPostAs() by the author = %v", when, err)
+		}
+		queued, err := c.GetScheduledPosts(ctx, "a@example.com")
+		if err != nil || (len(queued) == 1) != scheduled {
+			t.Errorf("%s: GetScheduledPosts() = %v, %v, expected it listed %v", when, postIDs(queued), err, scheduled)
+		}
+	}
+
+	check("an hour before", false, true)
+	if n, err := c.PublishDue(ctx); err != nil || n != 0 {
+		t.Errorf("PublishDue() before it's due = %d, %v, expected 0", n, err)
+	}
+	clock.Advance(time.Hour - time.Nanosecond)
+	check("just before", false, true)
+	clock.Advance(time.Nanosecond)
+	check("at publishAt", true, false)
+
+	// PublishDue flips it once
+	for _, expected := range []int{1, 0} {
+		if n, err := c.PublishDue(ctx); err != nil || n != expected {
+			t.Errorf("PublishDue() = %d, %v, expected %d", n, err, expected)
+		}
+	}
+	if len(published) != 1 || published[0].PublishAt != nil || !published[0].CreatedAt.Equal(nine) {
+		t.Errorf("OnPostUpdated() after PublishDue() = %+v, expected the post once, published at %v", published, nine)
+	}
+	check("after PublishDue", true, false)
+	if _, err := c.CancelScheduledPost(ctx, post.ID, "a@example.com"); !errors.Is(err, ErrNotScheduled) {
+		t.Errorf("CancelScheduledPost() of a published post = %v, expected ErrNotScheduled", err)
+	}
+}
+
+func TestSchedulePostInThePast(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)}
+	c := newBlockClient(t, WithClock(clock))
+	for _, publishAt := range []time.Time{clock.Now().Add(-time.Hour), clock.Now()} {
+		post, err := c.SchedulePost(ctx, "a@example.com", "now", publishAt)
+		if err != nil || post.PublishAt != nil || !post.CreatedAt.Equal(clock.Now()) {
+			t.Errorf("SchedulePost(%v) = %+v, %v, expected it posted now", publishAt, post, err)
+		}
+	}
+	if queued, err := c.GetScheduledPosts(ctx, "a@example.com"); err != nil || len(queued) != 0 {
+		t.Errorf("GetScheduledPosts() = %v, %v, expected none", postIDs(queued), err)
+	}
+}
+
+func TestCancelScheduledPost(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)}
+	c := newBlockClient(t, WithClock(clock))
+	later, err := c.SchedulePost(ctx, "a@example.com", "later", clock.Now().Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sooner, err := c.SchedulePost(ctx, "a@example.com", "sooner", clock.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	queued, err := c.GetScheduledPosts(ctx, "A@example.com")
+	if err != nil || !reflect.DeepEqual(postIDs(queued), []string{sooner.ID, later.ID}) {
+		t.Errorf("GetScheduledPosts() = %v, %v, expected the sooner one first", postIDs(queued), err)
+	}
+	posted, err := c.GetPosts(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		id        string
+		requester string
+		expected  error
+	}{
+		{id: later.ID, requester: "b@example.com", expected: ErrNotPostAuthor},
+		{id: posted[0].ID, requester: "a@example.com", expected: ErrNotScheduled},
+		{id: "missing", requester: "a@example.com", expected: ErrPostNotFound},
+		{id: later.ID, requester: "a@example.com", expected: nil},
+		{id: later.ID, requester: "a@example.com", expected: ErrPostNotFound},
+	}
+	for _, test := range tests {
+		if _, err := c.CancelScheduledPost(ctx, test.id, test.requester); !errors.Is(err, test.expected) {
+			t.Errorf("CancelScheduledPost(%q, %q) = %v, expected %v", test.id, test.requester, err, test.expected)
+		}
+	}
+	// it never goes out
+	clock.Advance(3 * time.Hour)
+	if posts, err := c.GetPosts(ctx, "a@example.com"); err != nil || !reflect.DeepEqual(postIDs(posts), []string{sooner.ID, posted[0].ID}) {
+		t.Errorf("GetPosts() after the cancel = %v, %v, expected %v", postIDs(posts), err, []string{sooner.ID, posted[0].ID})
+	}
+}
+
+func TestScheduledPublishing(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)}
+	c := newBlockClient(t, WithClock(clock), WithScheduledPublishing(5*time.Millisecond))
+	defer c.Close()
+	post, err := c.SchedulePost(ctx, "a@example.com", "announcement", clock.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	waitFor(t, func() bool {
+		got, err := c.GetPost(ctx, post.ID)
+		return err == nil && got.PublishAt == nil
+	})
+}
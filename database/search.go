@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// SearchOptions -
+// controls Client.SearchUsers and Client.SearchPosts
+type SearchOptions struct {
+	// Limit caps how many results are returned, 0 means every match.
+	// Offset skips that many of the best ones first, for the next page
+	Limit  int
+	Offset int
+	// IncludeDeactivated and IncludeDeleted also search deactivated and soft-deleted users,
+	// both are left out by default
+	IncludeDeactivated bool
+	IncludeDeleted     bool
+	// Viewer, when set, leaves out the users the viewer blocked and those who blocked the viewer.
+	// SearchPosts leaves out the posts the viewer may not see, someone logged out without a Viewer
+	Viewer string
+	// IgnoreVisibility has SearchPosts skip its viewer checks, for admin tooling
+	IgnoreVisibility bool
+}
+
+// validate -
+// ErrInvalidListOptions for a negative Limit or Offset
+func (opts SearchOptions) validate() error {
+	if opts.Limit < 0 || opts.Offset < 0 {
+		return fmt.Errorf("%w: limit %d, offset %d", ErrInvalidListOptions, opts.Limit, opts.Offset)
+	}
+	return nil
+}
+
+// how well a field matches a query, lower is better
+const (
+	matchExact = iota
+	matchPrefix
+	matchSubstring
+	noMatch
+)
+
+// foldRune -
+// the same rune for every case of r, the smallest of its unicode.SimpleFold orbit.
+// unlike unicode.ToLower it also maps the likes of the Kelvin sign to k
+func foldRune(r rune) rune {
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+	return min
+}
+
+// foldCase maps s through foldRune so equal-ignoring-case strings compare equal
+func foldCase(s string) string {
+	return strings.Map(foldRune, s)
+}
+
+// matchField -
+// how well the already folded field matches the folded query
+func matchField(field, query string) int {
+	switch {
+	case field == query:
+		return matchExact
+	case strings.HasPrefix(field, query):
+		return matchPrefix
+	case strings.Contains(field, query):
+		return matchSubstring
+	}
+	return noMatch
+}
+
+// matchUser is the best match of query over the user's name, username and email
+func matchUser(user User, query string) int {
+	best := noMatch
+	for _, field := range []string{user.Name, user.Username, user.Email} {
+		if match := matchField(foldCase(field), query); match < best {
+			best = match
+		}
+	}
+	return best
+}
+
+// SearchUsers -
+// the users whose name, username or email contains query, ignoring case (unicode case folding,
+// so "łuk" finds "Łukasz", accents are not ignored). exact matches come first, then prefixes,
+// then the rest, each group oldest first like GetUsers. ErrEmptySearchQuery for a blank query,
+// ErrInvalidListOptions for a negative limit or offset
+func (c *Client) SearchUsers(ctx context.Context, query string, opts SearchOptions) ([]User, error) {
+	query = foldCase(strings.TrimSpace(query))
+	if query == "" {
+		return []User{}, ErrEmptySearchQuery
+	}
+	if err := opts.validate(); err != nil {
+		return []User{}, err
+	}
+
+	type result struct {
+		user  User
+		match int
+	}
+	results := []result{}
+	viewer := EmailKey(opts.Viewer)
+	err := c.view(ctx, "SearchUsers", "", func(db *Schema) error {
+		i := 0
+		for _, user := range db.Users {
+			// full scan, bail out if the caller gave up
+			if i++; i%cancelCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			if (user.DeletedAt != nil && !opts.IncludeDeleted) || (!user.Active() && !opts.IncludeDeactivated) {
+				continue
+			}
+			if viewer != "" && db.blockedEither(viewer, user.Email) {
+				continue
+			}
+			if match := matchUser(user, query); match != noMatch {
+				results = append(results, result{user: user, match: match})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []User{}, err
+	}
+
+	// sorted outside the lock, the slice is a copy
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].match != results[j].match {
+			return results[i].match < results[j].match
+		}
+		return userLess(results[i].user, results[j].user)
+	})
+	start, end := ListOptions{Limit: opts.Limit, Offset: opts.Offset}.pageBounds(len(results))
+	users := make([]User, 0, end-start)
+	for _, r := range results[start:end] {
+		users = append(users, c.sanitize(r.user))
+	}
+	return users, nil
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newSearchClient has a user per name, created a minute apart in order
+func newSearchClient(t *testing.T, names ...string) *Client {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)}
+	c := NewClient(filepath.Join(t.TempDir(), "db.json"), WithClock(clock))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range names {
+		clock.Advance(time.Minute)
+		if _, err := c.CreateUser(ctx, fmt.Sprintf("user%d@example.com", i), "correct horse", name, 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+// searchNames is the names SearchUsers finds, in order
+func searchNames(t *testing.T, c *Client, query string, opts SearchOptions) []string {
+	t.Helper()
+	users, err := c.SearchUsers(ctx, query, opts)
+	if err != nil {
+		t.Fatalf("SearchUsers(%q) = %v", query, err)
+	}
+	names := []string{}
+	for _, user := range users {
+		names = append(names, user.Name)
+	}
+	return names
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSearchUsers(t *testing.T) {
+	c := newSearchClient(t, "Anna", "Joanna", "Ann", "Bob", "Annette", "Annabel", "someone")
+	// an exact username match counts like an exact name
+	if _, err := c.SetUsername(ctx, "user6@example.com", "ann"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DeactivateUser(ctx, "user4@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SoftDeleteUser(ctx, "user5@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		query    string
+		opts     SearchOptions
+		expected []string
+	}{
+		// exact, then prefix, then substring, each oldest first
+		{query: "ann", expected: []string{"Ann", "someone", "Anna", "Joanna"}},
+		{query: "ann", opts: SearchOptions{Limit: 2}, expected: []string{"Ann", "someone"}},
+		{query: "ann", opts: SearchOptions{Limit: 10}, expected: []string{"Ann", "someone", "Anna", "Joanna"}},
+		{query: "ann", opts: SearchOptions{Limit: 2, Offset: 2}, expected: []string{"Anna", "Joanna"}},
+		{query: "ann", opts: SearchOptions{Offset: 10}, expected: []string{}},
+		{query: "ann", opts: SearchOptions{IncludeDeactivated: true}, expected: []string{"Ann", "someone", "Anna", "Annette", "Joanna"}},
+		{query: "ann", opts: SearchOptions{IncludeDeleted: true}, expected: []string{"Ann", "someone", "Anna", "Annabel", "Joanna"}},
+		{query: "  ANN ", expected: []string{"Ann", "someone", "Anna", "Joanna"}},
+		// emails are searched too
+		{query: "user3@", expected: []string{"Bob"}},
+		{query: "user3@example.com", expected: []string{"Bob"}},
+		{query: "nobody", expected: []string{}},
+	}
+	for _, test := range tests {
+		if names := searchNames(t, c, test.query, test.opts); !equalNames(names, test.expected) {
+			t.Errorf("SearchUsers(%q, %+v) = %v, expected %v", test.query, test.opts, names, test.expected)
+		}
+	}
+}
+
+func TestSearchUsersUnicode(t *testing.T) {
+	c := newSearchClient(t, "Łukasz", "ÉLODIE", "\u212Aelvin", "Straße")
+	var tests = []struct {
+		query    string
+		expected []string
+	}{
+		{query: "łuk", expected: []string{"Łukasz"}},
+		{query: "ŁUK", expected: []string{"Łukasz"}},
+		{query: "łukasz", expected: []string{"Łukasz"}},
+		{query: "élo", expected: []string{"ÉLODIE"}},
+		// the Kelvin sign folds to k
+		{query: "kel", expected: []string{"\u212Aelvin"}},
+		{query: "STRAßE", expected: []string{"Straße"}},
+		// accents are part of the letter
+		{query: "luk", expected: []string{}},
+		{query: "elo", expected: []string{}},
+	}
+	for _, test := range tests {
+		if names := searchNames(t, c, test.query, SearchOptions{}); !equalNames(names, test.expected) {
+			t.Errorf("SearchUsers(%q) = %v, expected %v", test.query, names, test.expected)
+		}
+	}
+}
+
+func TestSearchUsersErrors(t *testing.T) {
+	c := newSearchClient(t, "Anna")
+	var tests = []struct {
+		query    string
+		opts     SearchOptions
+		expected error
+	}{
+		{query: "", expected: ErrEmptySearchQuery},
+		{query: " \t ", expected: ErrEmptySearchQuery},
+		{query: "ann", opts: SearchOptions{Limit: -1}, expected: ErrInvalidListOptions},
+		{query: "ann", opts: SearchOptions{Offset: -1}, expected: ErrInvalidListOptions},
+	}
+	for _, test := range tests {
+		if users, err := c.SearchUsers(ctx, test.query, test.opts); !errors.Is(err, test.expected) || len(users) != 0 {
+			t.Errorf("SearchUsers(%q, %+v) = %v, %v, expected %v", test.query, test.opts, users, err, test.expected)
+		}
+	}
+}
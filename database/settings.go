@@ -0,0 +1,271 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// size limits for user settings, in bytes of JSON
+const (
+	MaxSettingKeyLength = 64
+	// MaxSettingValueSize is the most a single value can take once encoded
+	MaxSettingValueSize = 1024
+	// MaxSettingsSize is the most a user's settings can take as a whole, keys included
+	MaxSettingsSize = 8192
+)
+
+// UserSettings -
+// a user's preferences, like a theme or which emails they want, as the text of a JSON object
+// of setting key to value. "" when there are none. it's a string so User stays comparable,
+// and it's always encoded the same way: keys sorted and values compacted, so equal settings
+// are equal strings. values are kept as their JSON text, a number doesn't go through float64.
+// read it with Get and Raw, change it with SetUserSetting and DeleteUserSetting
+type UserSettings string
+
+// MarshalJSON -
+// the settings as a JSON object, not a string holding one
+func (s UserSettings) MarshalJSON() ([]byte, error) {
+	if s == "" {
+		return []byte("{}"), nil
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalJSON -
+// read a JSON object of settings, encoding it like SetUserSetting does
+func (s *UserSettings) UnmarshalJSON(data []byte) error {
+	values := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	settings, err := encodeSettings(values)
+	if err != nil {
+		return err
+	}
+	*s = settings
+	return nil
+}
+
+// encodeSettings -
+// values as UserSettings, json.Marshal sorts the keys and compacts the values
+func encodeSettings(values map[string]json.RawMessage) (UserSettings, error) {
+	if len(values) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return UserSettings(data), nil
+}
+
+// values -
+// the settings decoded, empty for none. an encoding that isn't a JSON object is ErrDBCorrupt
+func (s UserSettings) values() (map[string]json.RawMessage, error) {
+	values := map[string]json.RawMessage{}
+	if s == "" {
+		return values, nil
+	}
+	if err := json.Unmarshal([]byte(s), &values); err != nil {
+		return nil, fmt.Errorf("%w: settings: %v", ErrDBCorrupt, err)
+	}
+	return values, nil
+}
+
+// Keys -
+// the keys set, sorted
+func (s UserSettings) Keys() []string {
+	values, _ := s.values()
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Raw -
+// the JSON text of the value of key, false if it isn't set
+func (s UserSettings) Raw(key string) (json.RawMessage, bool) {
+	values, _ := s.values()
+	value, ok := values[key]
+	return value, ok
+}
+
+// Get -
+// decode the value of key into v like json.Unmarshal, false and v untouched if it isn't set
+func (s UserSettings) Get(key string, v any) (bool, error) {
+	value, ok := s.Raw(key)
+	if !ok {
+		return false, nil
+	}
+	if err := json.Unmarshal(value, v); err != nil {
+		return true, fmt.Errorf("%w: %s: %v", ErrInvalidSetting, key, err)
+	}
+	return true, nil
+}
+
+// validateSettingKey -
+// ErrInvalidSetting unless key is namespaced like "notifications.email": at least two
+// dot-separated parts, each a lowercase letter followed by letters, digits and '_'.
+// the first part is the feature that owns the setting, so two features can't claim the same key
+func validateSettingKey(key string) error {
+	if len(key) > MaxSettingKeyLength {
+		return fmt.Errorf("%w: key %q is longer than %d bytes", ErrInvalidSetting, key, MaxSettingKeyLength)
+	}
+	parts := strings.Split(key, ".")
+	if len(parts) < 2 {
+		return fmt.Errorf("%w: key %q needs a namespace, like \"ui.%s\"", ErrInvalidSetting, key, key)
+	}
+	for _, part := range parts {
+		if part == "" || part[0] < 'a' || part[0] > 'z' {
+			return fmt.Errorf("%w: key %q has a part that doesn't start with a lowercase letter", ErrInvalidSetting, key)
+		}
+		for _, r := range part {
+			if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') && r != '_' {
+				return fmt.Errorf("%w: key %q can only have letters, digits and underscores between the dots", ErrInvalidSetting, key)
+			}
+		}
+	}
+	return nil
+}
+
+// encodeSettingValue -
+// value as the compact JSON a setting stores, ErrInvalidSetting if it can't be encoded
+// or is over MaxSettingValueSize
+func encodeSettingValue(key string, value any) (json.RawMessage, error) {
+	// Marshal compacts what a json.Marshaler like json.RawMessage gives it
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidSetting, key, err)
+	}
+	if len(data) > MaxSettingValueSize {
+		return nil, fmt.Errorf("%w: %s is %d bytes, more than %d", ErrInvalidSetting, key, len(data), MaxSettingValueSize)
+	}
+	return data, nil
+}
+
+// SetUserSetting -
+// same as Client.SetUserSetting, inside the Tx
+func (tx *Tx) SetUserSetting(ctx context.Context, email, key string, value any) (UserSettings, error) {
+	user, _, err := tx.setUserSetting(email, key, value)
+	return user.Settings, err
+}
+
+// setUserSetting -
+// SetUserSetting that also reports whether the user changed
+func (tx *Tx) setUserSetting(email, key string, value any) (User, bool, error) {
+	if err := validateSettingKey(key); err != nil {
+		return User{}, false, err
+	}
+	encoded, err := encodeSettingValue(key, value)
+	if err != nil {
+		return User{}, false, err
+	}
+	return tx.changeSettings(email, key, func(values map[string]json.RawMessage) {
+		values[key] = encoded
+	})
+}
+
+// DeleteUserSetting -
+// same as Client.DeleteUserSetting, inside the Tx
+func (tx *Tx) DeleteUserSetting(ctx context.Context, email, key string) (UserSettings, error) {
+	user, _, err := tx.deleteUserSetting(email, key)
+	return user.Settings, err
+}
+
+// deleteUserSetting -
+// DeleteUserSetting that also reports whether the user changed
+func (tx *Tx) deleteUserSetting(email, key string) (User, bool, error) {
+	return tx.changeSettings(email, key, func(values map[string]json.RawMessage) {
+		delete(values, key)
+	})
+}
+
+// changeSettings -
+// apply fn to the settings of the user with email and store them if they changed and still fit
+// in MaxSettingsSize, returning the user and whether it changed
+func (tx *Tx) changeSettings(email, key string, fn func(values map[string]json.RawMessage)) (User, bool, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return User{}, false, err
+	}
+	email = EmailKey(email)
+	user, ok := db.activeUser(email)
+	if !ok {
+		return User{}, false, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+	values, err := user.Settings.values()
+	if err != nil {
+		return User{}, false, err
+	}
+	fn(values)
+	settings, err := encodeSettings(values)
+	if err != nil {
+		return User{}, false, err
+	}
+	if settings == user.Settings {
+		return user, false, nil
+	}
+	if len(settings) > MaxSettingsSize {
+		return User{}, false, fmt.Errorf("%w: setting %s would take the settings of %s to %d bytes, more than %d",
+			ErrInvalidSetting, key, email, len(settings), MaxSettingsSize)
+	}
+	user.Settings = settings
+	user.UpdatedAt = tx.now()
+	db.putUser(user)
+	return user, true, nil
+}
+
+// GetUserSettings -
+// the settings of the user with email, empty if it never set any. ErrUserNotFound if there's no such user
+func (c *Client) GetUserSettings(ctx context.Context, email string) (UserSettings, error) {
+	user, err := c.GetUser(ctx, email)
+	if err != nil {
+		return "", err
+	}
+	return user.Settings, nil
+}
+
+// SetUserSetting -
+// set key of the settings of the user with email to value, anything json.Marshal can encode,
+// leaving the other keys alone. the read and write happen under the one lock so concurrent calls
+// for different keys all stick. ErrInvalidSetting for a key that isn't namespaced (see
+// validateSettingKey), a value over MaxSettingValueSize or settings that would grow past
+// MaxSettingsSize, ErrUserNotFound if there's no such user. setting the value it has writes nothing
+func (c *Client) SetUserSetting(ctx context.Context, email, key string, value any) (UserSettings, error) {
+	return c.changeSettings(ctx, "SetUserSetting", email, func(tx *Tx) (User, bool, error) {
+		return tx.setUserSetting(email, key, value)
+	})
+}
+
+// DeleteUserSetting -
+// unset key of the settings of the user with email, a no-op if it isn't set.
+// ErrUserNotFound if there's no such user
+func (c *Client) DeleteUserSetting(ctx context.Context, email, key string) (UserSettings, error) {
+	return c.changeSettings(ctx, "DeleteUserSetting", email, func(tx *Tx) (User, bool, error) {
+		return tx.deleteUserSetting(email, key)
+	})
+}
+
+// changeSettings -
+// run a settings change in a write, writing nothing when the settings stay the same
+func (c *Client) changeSettings(ctx context.Context, op, email string, fn func(tx *Tx) (User, bool, error)) (UserSettings, error) {
+	user := User{}
+	err := c.update(ctx, op, email, func(db *Schema) error {
+		var changed bool
+		var err error
+		user, changed, err = fn(c.newTx(db))
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return user.Settings, nil
+}
@@ -0,0 +1,260 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestUserSettingsRoundTrip(t *testing.T) {
+	type visibility struct {
+		Default string   `json:"default"`
+		Allowed []string `json:"allowed"`
+	}
+	var tests = []struct {
+		key      string
+		value    any
+		expected string
+	}{
+		{key: "ui.darkMode", value: true, expected: `true`},
+		// encoding/json escapes html, the same string once decoded
+		{key: "ui.theme", value: "solarized <dark>", expected: `"solarized \u003cdark\u003e"`},
+		// past float64's 2^53, kept as written
+		{key: "notifications.digestAfter", value: json.Number("9007199254740993"), expected: `9007199254740993`},
+		{key: "notifications.ratio", value: 0.25, expected: `0.25`},
+		{key: "notifications.email", value: nil, expected: `null`},
+		{key: "posts.visibility", value: visibility{Default: "friends", Allowed: []string{"public", "friends"}}, expected: `{"default":"friends","allowed":["public","friends"]}`},
+		{key: "posts.raw", value: json.RawMessage(`{ "a" : [1, 2] }`), expected: `{"a":[1,2]}`},
+	}
+	for name, opts := range map[string][]Option{"file": nil, "wal": {WithWAL(100)}} {
+		c := newTestClient(t, opts...)
+		if _, err := c.CreateUser(ctx, "test@example.com", "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+		for _, test := range tests {
+			if _, err := c.SetUserSetting(ctx, "Test@example.com", test.key, test.value); err != nil {
+				t.Fatalf("%s: SetUserSetting(%q) = %v", name, test.key, err)
+			}
+		}
+
+		reopened := NewClient(dbPath(c), opts...)
+		settings, err := reopened.GetUserSettings(ctx, "test@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(settings.Keys()) != len(tests) {
+			t.Errorf("%s: Keys() after reopening = %v, expected %d keys", name, settings.Keys(), len(tests))
+		}
+		for _, test := range tests {
+			if raw, ok := settings.Raw(test.key); !ok || string(raw) != test.expected {
+				t.Errorf("%s: Raw(%q) after reopening = %s, %v, expected %s", name, test.key, raw, ok, test.expected)
+			}
+		}
+
+		// and back into the types they were set from
+		var dark bool
+		var theme string
+		var digest json.Number
+		var posts visibility
+		for key, v := range map[string]any{"ui.darkMode": &dark, "ui.theme": &theme, "notifications.digestAfter": &digest, "posts.visibility": &posts} {
+			if ok, err := settings.Get(key, v); !ok || err != nil {
+				t.Errorf("%s: Get(%q) = %v, %v, expected it set", name, key, ok, err)
+			}
+		}
+		if !dark || theme != "solarized <dark>" || digest != "9007199254740993" || !reflect.DeepEqual(posts, tests[5].value) {
+			t.Errorf("%s: Get() = %v, %q, %v, %+v, expected what was set", name, dark, theme, digest, posts)
+		}
+		if ok, err := settings.Get("ui.missing", &dark); ok || err != nil {
+			t.Errorf("%s: Get() of a key not set = %v, %v, expected false", name, ok, err)
+		}
+		if ok, err := settings.Get("ui.theme", &dark); !ok || !errors.Is(err, ErrInvalidSetting) {
+			t.Errorf("%s: Get() into the wrong type = %v, %v, expected ErrInvalidSetting", name, ok, err)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestUserSettingsJSON(t *testing.T) {
+	user := User{Email: "test@example.com", Settings: `{"ui.darkMode":true}`}
+	data, err := json.Marshal(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"settings":{"ui.darkMode":true}`) {
+		t.Errorf("json.Marshal() = %s, expected settings as an object", data)
+	}
+	// none set leaves the key out
+	if data, _ := json.Marshal(User{Email: "test@example.com"}); strings.Contains(string(data), "settings") {
+		t.Errorf("json.Marshal() without settings = %s, expected no settings key", data)
+	}
+
+	// read back encoded the one way, whatever the spacing and key order
+	var settings UserSettings
+	if err := json.Unmarshal([]byte(`{ "ui.z": 1, "ui.a": [ true ] }`), &settings); err != nil {
+		t.Fatal(err)
+	}
+	if settings != `{"ui.a":[true],"ui.z":1}` {
+		t.Errorf("json.Unmarshal() = %s, expected keys sorted and values compacted", settings)
+	}
+	if err := json.Unmarshal([]byte(`[1]`), &settings); err == nil {
+		t.Error("json.Unmarshal() of an array = nil, expected an error")
+	}
+}
+
+func TestSetUserSettingValidation(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "name", 18); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		key      string
+		value    any
+		expected error
+	}{
+		{key: "ui.darkMode", value: true, expected: nil},
+		{key: "notifications.email.weekly_digest", value: false, expected: nil},
+		{key: "darkMode", value: true, expected: ErrInvalidSetting},
+		{key: "", value: true, expected: ErrInvalidSetting},
+		{key: "ui.", value: true, expected: ErrInvalidSetting},
+		{key: ".darkMode", value: true, expected: ErrInvalidSetting},
+		{key: "UI.darkMode", value: true, expected: ErrInvalidSetting},
+		{key: "ui.dark-mode", value: true, expected: ErrInvalidSetting},
+		{key: "ui.dark mode", value: true, expected: ErrInvalidSetting},
+		{key: "ui." + strings.Repeat("a", MaxSettingKeyLength), value: true, expected: ErrInvalidSetting},
+		{key: "ui.fn", value: func() {}, expected: ErrInvalidSetting},
+		// the quotes count
+		{key: "ui.big", value: strings.Repeat("a", MaxSettingValueSize-2), expected: nil},
+		{key: "ui.bigger", value: strings.Repeat("a", MaxSettingValueSize-1), expected: ErrInvalidSetting},
+	}
+	for _, test := range tests {
+		if _, err := c.SetUserSetting(ctx, "test@example.com", test.key, test.value); !errors.Is(err, test.expected) {
+			t.Errorf("SetUserSetting(%q) = %v, expected %v", test.key, err, test.expected)
+		}
+	}
+	if _, err := c.SetUserSetting(ctx, "missing@example.com", "ui.darkMode", true); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("SetUserSetting() of a missing user = %v, expected ErrUserNotFound", err)
+	}
+	if _, err := c.DeleteUserSetting(ctx, "missing@example.com", "ui.darkMode"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("DeleteUserSetting() of a missing user = %v, expected ErrUserNotFound", err)
+	}
+	if _, err := c.GetUserSettings(ctx, "missing@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUserSettings() of a missing user = %v, expected ErrUserNotFound", err)
+	}
+}
+
+func TestUserSettingsSizeCap(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "name", 18); err != nil {
+		t.Fatal(err)
+	}
+	value := strings.Repeat("a", MaxSettingValueSize-2)
+	var err error
+	set := 0
+	for ; set < MaxSettingsSize/MaxSettingValueSize+1; set++ {
+		if _, err = c.SetUserSetting(ctx, "test@example.com", fmt.Sprintf("ui.key%d", set), value); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, ErrInvalidSetting) || set == 0 {
+		t.Fatalf("SetUserSetting() past MaxSettingsSize = %v after %d keys, expected ErrInvalidSetting", err, set)
+	}
+	settings, _ := c.GetUserSettings(ctx, "test@example.com")
+	if len(settings) > MaxSettingsSize || len(settings.Keys()) != set {
+		t.Errorf("settings after the cap = %d bytes and %d keys, expected at most %d bytes and %d keys",
+			len(settings), len(settings.Keys()), MaxSettingsSize, set)
+	}
+
+	// shrinking one still works when full, and makes room
+	if _, err := c.SetUserSetting(ctx, "test@example.com", "ui.key0", "a"); err != nil {
+		t.Errorf("SetUserSetting() making a value smaller = %v, expected nil", err)
+	}
+	if _, err := c.SetUserSetting(ctx, "test@example.com", fmt.Sprintf("ui.key%d", set), value); err != nil {
+		t.Errorf("SetUserSetting() after making room = %v, expected nil", err)
+	}
+}
+
+func TestDeleteUserSetting(t *testing.T) {
+	store := &fakeStore{}
+	c := NewClientWithStore(store)
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	created, err := c.CreateUser(ctx, "test@example.com", "123456", "name", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"ui.darkMode", "ui.compact"} {
+		if _, err := c.SetUserSetting(ctx, "test@example.com", key, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the same value again writes nothing, and neither does deleting a key not set
+	saves := store.saves
+	if _, err := c.SetUserSetting(ctx, "test@example.com", "ui.darkMode", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DeleteUserSetting(ctx, "test@example.com", "ui.missing"); err != nil {
+		t.Errorf("DeleteUserSetting() of a key not set = %v, expected nil", err)
+	}
+	if store.saves != saves {
+		t.Errorf("settings changes that changed nothing saved %d times, expected none", store.saves-saves)
+	}
+
+	settings, err := c.DeleteUserSetting(ctx, "test@example.com", "ui.darkMode")
+	if err != nil || !reflect.DeepEqual(settings.Keys(), []string{"ui.compact"}) {
+		t.Errorf("DeleteUserSetting() = %v, %v, expected only ui.compact left", settings.Keys(), err)
+	}
+	if settings, err = c.DeleteUserSetting(ctx, "test@example.com", "ui.compact"); err != nil || settings != "" {
+		t.Errorf("DeleteUserSetting() of the last key = %q, %v, expected no settings", settings, err)
+	}
+	// the rest of the user is left alone
+	user, _ := c.GetUser(ctx, "test@example.com")
+	user.UpdatedAt = created.UpdatedAt
+	if user != c.sanitize(created) {
+		t.Errorf("GetUser() after deleting every setting = %+v, expected %+v", user, created)
+	}
+}
+
+func TestConcurrentUserSettings(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "name", 18); err != nil {
+		t.Fatal(err)
+	}
+	const workers = 20
+
+	// every worker sets its own key, none may be lost to another's read-modify-write
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := c.SetUserSetting(ctx, "test@example.com", fmt.Sprintf("ui.worker%d", i), i); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	settings, err := NewClient(dbPath(c)).GetUserSettings(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < workers; i++ {
+		var got int
+		if ok, err := settings.Get(fmt.Sprintf("ui.worker%d", i), &got); !ok || err != nil || got != i {
+			t.Errorf("ui.worker%d = %d, %v, %v, expected %d", i, got, ok, err, i)
+		}
+	}
+}
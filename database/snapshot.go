@@ -0,0 +1,41 @@
+package database
+
+import "context"
+
+// Snapshot -
+// a detached copy of every user and post, see Client.Snapshot
+type Snapshot struct {
+	Users map[string]User // key,value = email,user
+	Posts map[string]Post // key,value = id,post
+}
+
+// SnapshotOptions -
+// controls Client.Snapshot
+type SnapshotOptions struct {
+	// StripPasswords blanks every user's PasswordHash in the copy even with WithCredentials,
+	// without it they're blank anyway
+	StripPasswords bool
+}
+
+// Snapshot -
+// copy all users and posts at once, consistent with every write made before the call
+// and unaffected by the ones after it, so the caller can iterate it at leisure.
+// users come without PasswordHash like every read, unless the client has WithCredentials
+func (c *Client) Snapshot(ctx context.Context, opts SnapshotOptions) (Snapshot, error) {
+	snapshot := Snapshot{}
+	err := c.view(ctx, "Snapshot", "", func(db *Schema) error {
+		copied := db.clone()
+		snapshot.Users, snapshot.Posts = copied.Users, copied.Posts
+		return nil
+	})
+	if err != nil {
+		return Snapshot{}, err
+	}
+	for email, user := range snapshot.Users {
+		if opts.StripPasswords {
+			user.PasswordHash = ""
+		}
+		snapshot.Users[email] = c.sanitize(user)
+	}
+	return snapshot, nil
+}
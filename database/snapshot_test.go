@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSnapshotIsDetached(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	post, err := c.CreatePost(ctx, "test@example.com", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot, err := c.Snapshot(ctx, SnapshotOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UpdateUser(ctx, "test@example.com", "654321", "jane doe", 19); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DeletePost(ctx, post.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, "new@example.com", "123456", "new", 18); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(snapshot.Users) != 1 || snapshot.Users["test@example.com"].Name != "john doe" {
+		t.Errorf("snapshot users changed after later writes: %+v", snapshot.Users)
+	}
+	if got := snapshot.Posts[post.ID]; len(snapshot.Posts) != 1 || !got.equal(post) {
+		t.Errorf("snapshot posts changed after later writes: %+v", snapshot.Posts)
+	}
+
+	// and changing the snapshot doesn't touch the client
+	delete(snapshot.Users, "test@example.com")
+	if _, err := c.GetUser(ctx, "test@example.com"); err != nil {
+		t.Errorf("GetUser() after changing the snapshot = %v, expected nil", err)
+	}
+}
+
+func TestSnapshotStripPasswords(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	credentials := NewClient(dbPath(c), WithCredentials())
+	var tests = []struct {
+		client   *Client
+		opts     SnapshotOptions
+		expected bool
+	}{
+		{client: c, opts: SnapshotOptions{}, expected: false},
+		{client: c, opts: SnapshotOptions{StripPasswords: true}, expected: false},
+		{client: credentials, opts: SnapshotOptions{}, expected: true},
+		{client: credentials, opts: SnapshotOptions{StripPasswords: true}, expected: false},
+	}
+	for _, test := range tests {
+		snapshot, err := test.client.Snapshot(ctx, test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		user := snapshot.Users["test@example.com"]
+		if hasHash := CheckPassword(user.PasswordHash, "123456"); hasHash != test.expected || user.Name != "john doe" {
+			t.Errorf("Snapshot(%+v) with credentials %v has %+v, expected the password hash %v", test.opts, test.client.credentials, user, test.expected)
+		}
+	}
+	user, err := NewClient(dbPath(c), WithCredentials()).GetUser(ctx, "test@example.com")
+	if err != nil || !CheckPassword(user.PasswordHash, "123456") {
+		t.Errorf("GetUser() = %+v, %v, expected the stored password untouched", user, err)
+	}
+}
+
+func TestSnapshotInTx(t *testing.T) {
+	c := newTestClient(t)
+	err := c.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		_, err := c.Snapshot(ctx, SnapshotOptions{})
+		return err
+	})
+	if !errors.Is(err, ErrNestedTx) {
+		t.Errorf("Snapshot() inside Tx = %v, expected ErrNestedTx", err)
+	}
+}
+
+// BenchmarkSnapshot copies a db with 100k posts
+func BenchmarkSnapshot(b *testing.B) {
+	c := seedLargeDB(b, 100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Snapshot(ctx, SnapshotOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultDeletedUserRetention -
+// how long a soft-deleted user can be restored unless WithDeletedUserRetention says otherwise
+const DefaultDeletedUserRetention = 30 * 24 * time.Hour
+
+// activeUser -
+// the user with email unless there's none or it was soft-deleted,
+// what every lookup but GetUserIncludingDeleted goes through
+func (db *Schema) activeUser(email string) (User, bool) {
+	user, ok := db.Users[email]
+	if !ok || user.DeletedAt != nil {
+		return User{}, false
+	}
+	return user, true
+}
+
+// equal -
+// u == other, but comparing DeletedAt by the time it points to
+// users decoded separately never share the pointer
+func (u User) equal(other User) bool {
+	a, b := u, other
+	a.DeletedAt, b.DeletedAt = nil, nil
+	if a != b || (u.DeletedAt == nil) != (other.DeletedAt == nil) {
+		return false
+	}
+	return u.DeletedAt == nil || *u.DeletedAt == *other.DeletedAt
+}
+
+// SoftDeleteUser -
+// same as Client.SoftDeleteUser, inside the Tx
+func (tx *Tx) SoftDeleteUser(ctx context.Context, email string) (User, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return User{}, err
+	}
+	email = EmailKey(email)
+	user, ok := db.activeUser(email)
+	if !ok {
+		return User{}, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+	now := tx.now()
+	user.DeletedAt = &now
+	user.UpdatedAt = now
+	db.putUser(user)
+	// a reset link sent before shouldn't work after a restore
+	for key, reset := range db.ResetTokens {
+		if reset.UserEmail == email {
+			delete(db.ResetTokens, key)
+		}
+	}
+	return user, nil
+}
+
+// RestoreUser -
+// same as Client.RestoreUser, inside the Tx
+func (tx *Tx) RestoreUser(ctx context.Context, email string) (User, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return User{}, err
+	}
+	email = EmailKey(email)
+	user, ok := db.Users[email]
+	if !ok {
+		return User{}, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+	if user.DeletedAt == nil {
+		return user, nil
+	}
+	now := tx.now()
+	if now.Sub(*user.DeletedAt) > tx.retention {
+		return User{}, fmt.Errorf("%w: user %s was deleted at %v", ErrRestoreExpired, email, *user.DeletedAt)
+	}
+	user.DeletedAt = nil
+	user.UpdatedAt = now
+	db.putUser(user)
+	return user, nil
+}
+
+// SoftDeleteUser -
+// mark the user with email deleted and keep the record. it's left out of GetUser, GetUsers
+// and every other lookup, can't log in or post (ErrUserDeleted), and its posts stay as they are.
+// the email and username stay taken. RestoreUser undoes it within WithDeletedUserRetention,
+// PurgeDeletedUsers removes it for good. ErrUserNotFound if there's no such user or it already was
+func (c *Client) SoftDeleteUser(ctx context.Context, email string) (User, error) {
+	user := User{}
+	err := c.deleteUpdate(ctx, "SoftDeleteUser", email, func(db *Schema) error {
+		var err error
+		user, err = c.newTx(db).SoftDeleteUser(ctx, email)
+		return err
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return c.sanitize(user), nil
+}
+
+// RestoreUser -
+// bring back a user removed by SoftDeleteUser, ErrRestoreExpired if that was longer ago than
+// WithDeletedUserRetention. restoring a user that isn't deleted changes nothing
+func (c *Client) RestoreUser(ctx context.Context, email string) (User, error) {
+	user := User{}
+	err := c.update(ctx, "RestoreUser", email, func(db *Schema) error {
+		deleted := db.Users[email].DeletedAt != nil
+		var err error
+		user, err = c.newTx(db).RestoreUser(ctx, email)
+		if err == nil && !deleted {
+			return errNoop
+		}
+		return err
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return c.sanitize(user), nil
+}
+
+// GetUserIncludingDeleted -
+// GetUser that also finds soft-deleted users, check DeletedAt to tell them apart
+func (c *Client) GetUserIncludingDeleted(ctx context.Context, email string) (User, error) {
+	email = EmailKey(email)
+	user := User{}
+	err := c.view(ctx, "GetUserIncludingDeleted", email, func(db *Schema) error {
+		var ok bool
+		if user, ok = db.Users[email]; !ok {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, email)
+		}
+		return nil
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return c.sanitize(user), nil
+}
+
+// PurgeDeletedUsers -
+// permanently delete every user soft-deleted at least olderThan ago, 0 purges them all.
+// their posts are anonymized like PostsAnonymize does, so they still show as by a deleted account,
+// and their drafts and scheduled posts deleted.
+// returns how many users were purged, all in one write and nothing written if there are none
+func (c *Client) PurgeDeletedUsers(ctx context.Context, olderThan time.Duration) (int, error) {
+	purged := 0
+	err := c.deleteUpdate(ctx, "PurgeDeletedUsers", "", func(db *Schema) error {
+		now := c.newTx(db).now()
+		cutoff := now.Add(-olderThan)
+		emails := map[string]bool{}
+		for email, user := range db.Users {
+			if user.DeletedAt != nil && !user.DeletedAt.After(cutoff) {
+				emails[email] = true
+			}
+		}
+		if len(emails) == 0 {
+			return errNoop
+		}
+
+		i := 0
+		for id, post := range db.Posts {
+			// full scan, bail out if the caller gave up
+			if i++; i%cancelCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			switch {
+			case emails[post.UserEmail] && post.unpublished(now):
+				db.deletePost(id)
+			case emails[post.UserEmail]:
+				post.UserEmail = DeletedUserEmail
+				db.putPost(post)
+			}
+		}
+		for email := range emails {
+			db.deleteUser(email)
+		}
+		purged = len(emails)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newSoftDeleteClient has a@example.com with the username alice and a post, and b@example.com
+func newSoftDeleteClient(t *testing.T, opts ...Option) (*Client, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{now: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)}
+	c := NewClient(filepath.Join(t.TempDir(), "db.json"), append([]Option{WithClock(clock)}, opts...)...)
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "correct horse", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.SetUsername(ctx, "a@example.com", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreatePost(ctx, "a@example.com", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	return c, clock
+}
+
+func TestSoftDeleteUser(t *testing.T) {
+	c, clock := newSoftDeleteClient(t)
+	clock.Advance(time.Minute)
+	deleted, err := c.SoftDeleteUser(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted.DeletedAt == nil || *deleted.DeletedAt != clock.Now() {
+		t.Errorf("SoftDeleteUser() DeletedAt = %v, expected %v", deleted.DeletedAt, clock.Now())
+	}
+
+	// hidden from lookups
+	if _, err := c.GetUser(ctx, "a@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUser() of a soft-deleted user = %v, expected ErrUserNotFound", err)
+	}
+	if _, err := c.GetUserByUsername(ctx, "alice"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUserByUsername() of a soft-deleted user = %v, expected ErrUserNotFound", err)
+	}
+	if _, err := c.AuthenticateUser(ctx, "a@example.com", "correct horse"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("AuthenticateUser() of a soft-deleted user = %v, expected ErrInvalidCredentials", err)
+	}
+	if page, err := c.GetUsers(ctx, ListOptions{}); err != nil || page.Total != 1 || page.Users[0].Email != "b@example.com" {
+		t.Errorf("GetUsers() = %+v, %v, expected only b@example.com", page, err)
+	}
+	if page, err := c.GetUsers(ctx, ListOptions{IncludeDeleted: true}); err != nil || page.Total != 2 {
+		t.Errorf("GetUsers() with IncludeDeleted = %+v, %v, expected both users", page, err)
+	}
+	if got, err := c.GetUserIncludingDeleted(ctx, "a@example.com"); err != nil || !got.equal(deleted) {
+		t.Errorf("GetUserIncludingDeleted() = %+v, %v, expected %+v", got, err, deleted)
+	}
+	// but the record, its email and its posts are all still there
+	if posts, err := c.GetPosts(ctx, "a@example.com"); err != nil || len(posts) != 1 {
+		t.Errorf("GetPosts() of a soft-deleted user = %d posts, %v, expected 1", len(posts), err)
+	}
+	if _, err := c.CreateUser(ctx, "a@example.com", "correct horse", "name", 18); !errors.Is(err, ErrUserExists) {
+		t.Errorf("CreateUser() with a soft-deleted email = %v, expected ErrUserExists", err)
+	}
+	if _, err := c.SetUsername(ctx, "b@example.com", "alice"); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("SetUsername() of a soft-deleted user's username = %v, expected ErrUsernameTaken", err)
+	}
+
+	var tests = []struct {
+		name     string
+		call     func() error
+		expected error
+	}{
+		{name: "CreatePost", expected: ErrUserDeleted, call: func() error {
+			_, err := c.CreatePost(ctx, "a@example.com", "still here")
+			return err
+		}},
+		{name: "UpdateUser", expected: ErrUserNotFound, call: func() error {
+			_, err := c.UpdateUser(ctx, "a@example.com", "correct horse", "name", 18)
+			return err
+		}},
+		{name: "SoftDeleteUser", expected: ErrUserNotFound, call: func() error {
+			_, err := c.SoftDeleteUser(ctx, "a@example.com")
+			return err
+		}},
+		{name: "CreatePasswordResetToken", expected: ErrUserNotFound, call: func() error {
+			_, err := c.CreatePasswordResetToken(ctx, "a@example.com")
+			return err
+		}},
+		{name: "SoftDeleteUser of a missing user", expected: ErrUserNotFound, call: func() error {
+			_, err := c.SoftDeleteUser(ctx, "missing@example.com")
+			return err
+		}},
+	}
+	for _, test := range tests {
+		if err := test.call(); !errors.Is(err, test.expected) {
+			t.Errorf("%s() = %v, expected %v", test.name, err, test.expected)
+		}
+	}
+}
+
+func TestRestoreUser(t *testing.T) {
+	c, clock := newSoftDeleteClient(t, WithDeletedUserRetention(24*time.Hour))
+	token, err := c.CreatePasswordResetToken(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SoftDeleteUser(ctx, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(24 * time.Hour)
+	restored, err := c.RestoreUser(ctx, "a@example.com")
+	if err != nil || restored.DeletedAt != nil || restored.UpdatedAt != clock.Now() {
+		t.Fatalf("RestoreUser() at the end of the retention = %+v, %v, expected the user back", restored, err)
+	}
+	if got, err := c.GetUserByUsername(ctx, "alice"); err != nil || got != restored {
+		t.Errorf("GetUserByUsername() after RestoreUser() = %+v, %v, expected %+v", got, err, restored)
+	}
+	if _, err := c.CreatePost(ctx, "a@example.com", "back again"); err != nil {
+		t.Errorf("CreatePost() after RestoreUser() = %v, expected nil", err)
+	}
+	// the reset link from before doesn't come back with it
+	if err := c.RedeemPasswordResetToken(ctx, token, "battery staple"); !errors.Is(err, ErrResetTokenInvalid) {
+		t.Errorf("RedeemPasswordResetToken() from before the soft delete = %v, expected ErrResetTokenInvalid", err)
+	}
+	// restoring a user that isn't deleted changes nothing
+	if again, err := c.RestoreUser(ctx, "a@example.com"); err != nil || again != restored {
+		t.Errorf("second RestoreUser() = %+v, %v, expected %+v", again, err, restored)
+	}
+	if _, err := c.RestoreUser(ctx, "missing@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("RestoreUser() of a missing user = %v, expected ErrUserNotFound", err)
+	}
+
+	if _, err := c.SoftDeleteUser(ctx, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(24*time.Hour + time.Second)
+	if _, err := c.RestoreUser(ctx, "a@example.com"); !errors.Is(err, ErrRestoreExpired) {
+		t.Errorf("RestoreUser() past the retention = %v, expected ErrRestoreExpired", err)
+	}
+	if _, err := c.GetUser(ctx, "a@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUser() after a late RestoreUser() = %v, expected ErrUserNotFound", err)
+	}
+}
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	c, clock := newSoftDeleteClient(t)
+	if _, err := c.SoftDeleteUser(ctx, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	if _, err := c.SoftDeleteUser(ctx, "b@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+
+	var tests = []struct {
+		olderThan time.Duration
+		expected  int
+	}{
+		{olderThan: 3 * time.Hour, expected: 0},
+		// a was deleted exactly 2 hours ago, that's old enough
+		{olderThan: 2 * time.Hour, expected: 1},
+		{olderThan: 2 * time.Hour, expected: 0},
+		{olderThan: 0, expected: 1},
+	}
+	for _, test := range tests {
+		if purged, err := c.PurgeDeletedUsers(ctx, test.olderThan); err != nil || purged != test.expected {
+			t.Errorf("PurgeDeletedUsers(%v) = %d, %v, expected %d", test.olderThan, purged, err, test.expected)
+		}
+	}
+
+	if _, err := c.GetUserIncludingDeleted(ctx, "a@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUserIncludingDeleted() after the purge = %v, expected ErrUserNotFound", err)
+	}
+	if posts, err := c.GetPosts(ctx, DeletedUserEmail); err != nil || len(posts) != 1 {
+		t.Errorf("GetPosts(DeletedUserEmail) after the purge = %d posts, %v, expected the purged user's post", len(posts), err)
+	}
+	// the email and username are free again
+	if _, err := c.CreateUser(ctx, "a@example.com", "correct horse", "name", 18); err != nil {
+		t.Errorf("CreateUser() with a purged email = %v, expected nil", err)
+	}
+	if _, err := c.SetUsername(ctx, "a@example.com", "alice"); err != nil {
+		t.Errorf("SetUsername() of a purged user's username = %v, expected nil", err)
+	}
+}
+
+func TestSoftDeletePersists(t *testing.T) {
+	for name, opts := range map[string][]Option{"file": nil, "wal": {WithWAL(100)}} {
+		c, clock := newSoftDeleteClient(t, opts...)
+		deleted, err := c.SoftDeleteUser(ctx, "a@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		reopened := NewClient(dbPath(c), append([]Option{WithClock(clock)}, opts...)...)
+		if _, err := reopened.GetUser(ctx, "a@example.com"); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("%s: GetUser() after reopening = %v, expected ErrUserNotFound", name, err)
+		}
+		if got, err := reopened.GetUserIncludingDeleted(ctx, "a@example.com"); err != nil || !got.equal(deleted) {
+			t.Errorf("%s: GetUserIncludingDeleted() after reopening = %+v, %v, expected %+v", name, got, err, deleted)
+		}
+		if _, err := reopened.RestoreUser(ctx, "a@example.com"); err != nil {
+			t.Errorf("%s: RestoreUser() after reopening = %v, expected nil", name, err)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+// Package sqlite is a SQLite backed implementation of database.Repository
+// for databases that have outgrown the single json file
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Warren-Wang-OG/go-social-media-backend/database"
+	"github.com/google/uuid"
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// Client -
+// same operations as database.Client, stored in a SQLite file
+type Client struct {
+	db *sql.DB
+}
+
+var _ database.Repository = (*Client)(nil)
+
+// NewClient -
+// open (or create) the SQLite database at path, call EnsureDB before using it
+func NewClient(path string) (*Client, error) {
+	db, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+	return &Client{db: db}, nil
+}
+
+// Close -
+// close the underlying database handle
+func (c *Client) Close() error {
+	return c.db.Close()
+}
+
+// schema is idempotent so EnsureDB can run on every start
+// timestamps are unix nanoseconds in UTC so they round trip exactly and sort correctly
+const schema = `
+CREATE TABLE IF NOT EXISTS users (
+	email      TEXT PRIMARY KEY,
+	password   TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	age        INTEGER NOT NULL,
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS posts (
+	id         TEXT PRIMARY KEY,
+	user_email TEXT NOT NULL,
+	text       TEXT NOT NULL,
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS posts_user_email ON posts (user_email);
+`
+
+// EnsureDB -
+// create the tables and indexes if they don't exist yet
+// and add the columns that came later to tables made before them
+func (c *Client) EnsureDB(ctx context.Context) error {
+	if _, err := c.db.ExecContext(ctx, schema); err != nil {
+		return err
+	}
+	// users.updated_at, 0 in rows from before it and read as created_at
+	var found int
+	err := c.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pragma_table_info('users') WHERE name = 'updated_at'`).Scan(&found)
+	if err != nil || found > 0 {
+		return err
+	}
+	_, err = c.db.ExecContext(ctx, `ALTER TABLE users ADD COLUMN updated_at INTEGER NOT NULL DEFAULT 0`)
+	return err
+}
+
+// CreateUser -
+// email needs to be unique for each user, returns database.ErrUserExists if it's already taken
+// the password has to satisfy database.DefaultPasswordPolicy like the json Client's, a *database.PasswordError otherwise
+// and the age must be database.DefaultMinimumAge to database.MaxAge, database.ErrInvalidAge otherwise
+// the user is returned without PasswordHash, see VerifyPassword
+func (c *Client) CreateUser(ctx context.Context, email, password, name string, age int) (database.User, error) {
+	email, err := database.NormalizeEmail(email)
+	if err != nil {
+		return database.User{}, err
+	}
+	if err := database.CheckAge(age, database.DefaultMinimumAge); err != nil {
+		return database.User{}, err
+	}
+	if err := database.DefaultPasswordPolicy.Check(password); err != nil {
+		return database.User{}, err
+	}
+	hash, err := database.HashPassword(password, database.DefaultPasswordCost)
+	if err != nil {
+		return database.User{}, err
+	}
+	now := time.Now().UTC()
+	user := database.User{
+		CreatedAt:    now,
+		Email:        email,
+		PasswordHash: hash,
+		Name:         name,
+		Age:          age,
+		UpdatedAt:    now,
+	}
+	_, err = c.db.ExecContext(ctx,
+		`INSERT INTO users (email, password, name, age, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		user.Email, user.PasswordHash, user.Name, user.Age, user.CreatedAt.UnixNano(), user.UpdatedAt.UnixNano())
+	if isConstraint(err, sqlite3.ErrConstraintPrimaryKey) {
+		return database.User{}, fmt.Errorf("%w: %s", database.ErrUserExists, email)
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+	return sanitize(user), nil
+}
+
+// GetUser -
+// return user given the email, without PasswordHash
+func (c *Client) GetUser(ctx context.Context, email string) (database.User, error) {
+	email = database.EmailKey(email)
+	user, err := getUser(ctx, c.db, email)
+	if err != nil {
+		return database.User{}, err
+	}
+	return sanitize(user), nil
+}
+
+// VerifyPassword -
+// nil if password is the user's, database.ErrWrongPassword if not and database.ErrUserNotFound
+// if there's no such user, the stored hash is never returned so this is the way to check it
+func (c *Client) VerifyPassword(ctx context.Context, email, password string) error {
+	email = database.EmailKey(email)
+	user, err := getUser(ctx, c.db, email)
+	if err != nil {
+		return err
+	}
+	if !database.CheckPassword(user.PasswordHash, password) {
+		return fmt.Errorf("%w: %s", database.ErrWrongPassword, email)
+	}
+	return nil
+}
+
+// UpdateUser -
+// change password, name and age of an existing user, email and CreatedAt don't change
+// the new password and age are checked and the user returned like CreateUser's
+func (c *Client) UpdateUser(ctx context.Context, email, password, name string, age int) (database.User, error) {
+	email, err := database.NormalizeEmail(email)
+	if err != nil {
+		return database.User{}, err
+	}
+	if err := database.CheckAge(age, database.DefaultMinimumAge); err != nil {
+		return database.User{}, err
+	}
+	if err := database.DefaultPasswordPolicy.Check(password); err != nil {
+		return database.User{}, err
+	}
+	hash, err := database.HashPassword(password, database.DefaultPasswordCost)
+	if err != nil {
+		return database.User{}, err
+	}
+	user := database.User{}
+	err = c.tx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			`UPDATE users SET password = ?, name = ?, age = ?, updated_at = ? WHERE email = ?`,
+			hash, name, age, time.Now().UTC().UnixNano(), email)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("%w: %s", database.ErrUserNotFound, email)
+		}
+		user, err = getUser(ctx, tx, email)
+		return err
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return sanitize(user), nil
+}
+
+// DeleteUser -
+// delete a user and handle their posts according to opts, in one transaction
+func (c *Client) DeleteUser(ctx context.Context, email string, opts database.DeleteUserOptions) (database.DeleteUserResult, error) {
+	email = database.EmailKey(email)
+	result := database.DeleteUserResult{}
+	err := c.tx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `DELETE FROM users WHERE email = ?`, email)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			if opts.IgnoreMissing {
+				return nil
+			}
+			return fmt.Errorf("%w: %s", database.ErrUserNotFound, email)
+		}
+		result.Deleted = true
+
+		switch opts.Posts {
+		case database.PostsCascade:
+			res, err = tx.ExecContext(ctx, `DELETE FROM posts WHERE user_email = ?`, email)
+		case database.PostsAnonymize:
+			res, err = tx.ExecContext(ctx, `UPDATE posts SET user_email = ? WHERE user_email = ?`,
+				database.DeletedUserEmail, email)
+		default:
+			var count int
+			err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM posts WHERE user_email = ?`, email).Scan(&count)
+			result.Posts = count
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		result.Posts = int(n)
+		return err
+	})
+	if err != nil {
+		return database.DeleteUserResult{}, err
+	}
+	return result, nil
+}
+
+// CreatePost -
+// create a post authored by an existing user
+// the text is trimmed and checked like the json Client's, database.ErrEmptyPost or database.ErrPostTooLong otherwise
+func (c *Client) CreatePost(ctx context.Context, userEmail, text string) (database.Post, error) {
+	userEmail = database.EmailKey(userEmail)
+	text, err := database.PostText(text, database.DefaultMaxPostLength)
+	if err != nil {
+		return database.Post{}, err
+	}
+	post := database.Post{
+		ID:        uuid.New().String(),
+		CreatedAt: time.Now().UTC(),
+		UserEmail: userEmail,
+		Text:      text,
+	}
+	err = c.tx(ctx, func(tx *sql.Tx) error {
+		if _, err := getUser(ctx, tx, userEmail); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO posts (id, user_email, text, created_at) VALUES (?, ?, ?, ?)`,
+			post.ID, post.UserEmail, post.Text, post.CreatedAt.UnixNano())
+		return err
+	})
+	if err != nil {
+		return database.Post{}, err
+	}
+	return post, nil
+}
+
+// GetPosts -
+// return all posts of a specific user identified by their userEmail, newest first like database.SortPosts
+func (c *Client) GetPosts(ctx context.Context, userEmail string) ([]database.Post, error) {
+	userEmail = database.EmailKey(userEmail)
+	rows, err := c.db.QueryContext(ctx,
+		`SELECT id, user_email, text, created_at FROM posts WHERE user_email = ?`, userEmail)
+	if err != nil {
+		return []database.Post{}, err
+	}
+	defer rows.Close()
+
+	posts := []database.Post{}
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return []database.Post{}, err
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return []database.Post{}, err
+	}
+	// sorted here rather than with ORDER BY so ties come out the same as every other backend
+	database.SortPosts(posts, database.NewestFirst)
+	return posts, nil
+}
+
+// DeletePost -
+// delete a single post identified by the id, returns the removed post
+func (c *Client) DeletePost(ctx context.Context, id string) (database.Post, error) {
+	if id == "" {
+		return database.Post{}, database.ErrEmptyPostID
+	}
+	post := database.Post{}
+	err := c.tx(ctx, func(tx *sql.Tx) error {
+		row := tx.QueryRowContext(ctx,
+			`SELECT id, user_email, text, created_at FROM posts WHERE id = ?`, id)
+		var err error
+		post, err = scanPost(row)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", database.ErrPostNotFound, id)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM posts WHERE id = ?`, id)
+		return err
+	})
+	if err != nil {
+		return database.Post{}, err
+	}
+	return post, nil
+}
+
+// ImportJSON -
+// one-shot migration of an existing json db file into this database
+// opts are the ones the json file was written with (compression, encryption key)
+func (c *Client) ImportJSON(ctx context.Context, path string, opts ...database.Option) error {
+	db, err := database.LoadFile(ctx, path, opts...)
+	if err != nil {
+		return err
+	}
+	return c.ImportSchema(ctx, db)
+}
+
+// ImportSchema -
+// copy the users and posts of a json database into this one in a single transaction
+// only database.PublicRecords are copied, the posts table has no drafts or visibility to keep
+// fails without importing anything if a record already exists
+func (c *Client) ImportSchema(ctx context.Context, db database.Schema) error {
+	db = database.PublicRecords(db, time.Now().UTC())
+	return c.tx(ctx, func(tx *sql.Tx) error {
+		for _, user := range db.Users {
+			// a zero UpdatedAt is stored as 0 and read back as CreatedAt
+			updatedAt := int64(0)
+			if !user.UpdatedAt.IsZero() {
+				updatedAt = user.UpdatedAt.UnixNano()
+			}
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO users (email, password, name, age, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+				user.Email, user.PasswordHash, user.Name, user.Age, user.CreatedAt.UnixNano(), updatedAt)
+			if isConstraint(err, sqlite3.ErrConstraintPrimaryKey) {
+				return fmt.Errorf("%w: %s", database.ErrUserExists, user.Email)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		for _, post := range db.Posts {
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO posts (id, user_email, text, created_at) VALUES (?, ?, ?, ?)`,
+				post.ID, post.UserEmail, post.Text, post.CreatedAt.UnixNano())
+			if err != nil {
+				return fmt.Errorf("importing post %s: %w", post.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// tx runs fn in a transaction, committing only if it returns nil
+func (c *Client) tx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// querier is what getUser needs, satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func getUser(ctx context.Context, q querier, email string) (database.User, error) {
+	user := database.User{}
+	var createdAt, updatedAt int64
+	err := q.QueryRowContext(ctx,
+		`SELECT email, password, name, age, created_at, updated_at FROM users WHERE email = ?`, email).
+		Scan(&user.Email, &user.PasswordHash, &user.Name, &user.Age, &createdAt, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("%w: %s", database.ErrUserNotFound, email)
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+	user.CreatedAt = time.Unix(0, createdAt).UTC()
+	user.UpdatedAt = user.CreatedAt
+	if updatedAt != 0 {
+		user.UpdatedAt = time.Unix(0, updatedAt).UTC()
+	}
+	return user, nil
+}
+
+// sanitize is user as the reads return it, without PasswordHash like the json Client's
+func sanitize(user database.User) database.User {
+	user.PasswordHash = ""
+	return user
+}
+
+// scanner is a *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPost(row scanner) (database.Post, error) {
+	post := database.Post{}
+	var createdAt int64
+	if err := row.Scan(&post.ID, &post.UserEmail, &post.Text, &createdAt); err != nil {
+		return database.Post{}, err
+	}
+	post.CreatedAt = time.Unix(0, createdAt).UTC()
+	return post, nil
+}
+
+// isConstraint reports whether err is the given SQLite constraint violation
+func isConstraint(err error, code sqlite3.ErrNoExtended) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == code
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Stats -
+// aggregate numbers about the db contents, see Client.Stats
+type Stats struct {
+	Users int
+	Posts int
+	// posts per existing user, users without posts count as 0
+	// posts whose author no longer exists are in Posts but not in these
+	MinPostsPerUser  int
+	MaxPostsPerUser  int
+	MeanPostsPerUser float64
+	// CreatedAt of the oldest and newest post, zero without posts
+	OldestPost time.Time
+	NewestPost time.Time
+	// FileSize is the size in bytes of the db file and its write-ahead log, 0 without a file
+	FileSize int64
+}
+
+// Stats -
+// summarize the db in one go under the read lock, without copying any records
+// an empty db gives zero values
+func (c *Client) Stats(ctx context.Context) (Stats, error) {
+	stats := Stats{}
+	err := c.view(ctx, "Stats", "", func(db *Schema) error {
+		stats.Users = len(db.Users)
+		stats.Posts = len(db.Posts)
+
+		perUser := make(map[string]int, len(db.Users))
+		i := 0
+		for _, post := range db.Posts {
+			// full scan, bail out if the caller gave up
+			if i++; i%cancelCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			perUser[post.UserEmail]++
+			if stats.OldestPost.IsZero() || post.CreatedAt.Before(stats.OldestPost) {
+				stats.OldestPost = post.CreatedAt
+			}
+			if post.CreatedAt.After(stats.NewestPost) {
+				stats.NewestPost = post.CreatedAt
+			}
+		}
+
+		if len(db.Users) == 0 {
+			return nil
+		}
+		total := 0
+		stats.MinPostsPerUser = -1
+		for email := range db.Users {
+			n := perUser[email]
+			total += n
+			if stats.MinPostsPerUser < 0 || n < stats.MinPostsPerUser {
+				stats.MinPostsPerUser = n
+			}
+			if n > stats.MaxPostsPerUser {
+				stats.MaxPostsPerUser = n
+			}
+		}
+		stats.MeanPostsPerUser = float64(total) / float64(len(db.Users))
+		return nil
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+	stats.FileSize = c.fileSize()
+	return stats, nil
+}
+
+// fileSize is the size in bytes of the db file and its write-ahead log, 0 if the store isn't a file
+func (c *Client) fileSize() int64 {
+	file, ok := c.store.(*fileStore)
+	if !ok {
+		return 0
+	}
+	size := int64(0)
+	for _, path := range []string{file.path, file.walPath()} {
+		if info, err := os.Stat(path); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
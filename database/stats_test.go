@@ -0,0 +1,83 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	c := newTestClient(t)
+	base := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	// 3 users with 0, 1 and 3 posts, plus one whose author is gone
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "gone@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	posts := []struct {
+		email string
+		at    time.Duration
+	}{
+		{"b@example.com", 2 * time.Hour},
+		{"c@example.com", time.Hour},
+		{"c@example.com", 5 * time.Hour},
+		{"c@example.com", 3 * time.Hour},
+		{"gone@example.com", 9 * time.Hour},
+	}
+	for _, post := range posts {
+		if _, err := c.CreatePostAt(ctx, post.email, "hello", base.Add(post.at)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.DeleteUser(ctx, "gone@example.com", DeleteUserOptions{Posts: PostsAnonymize}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := c.Stats(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Stats{
+		Users:            3,
+		Posts:            5,
+		MinPostsPerUser:  0,
+		MaxPostsPerUser:  3,
+		MeanPostsPerUser: 4.0 / 3,
+		OldestPost:       base.Add(time.Hour),
+		NewestPost:       base.Add(9 * time.Hour),
+		FileSize:         stats.FileSize,
+	}
+	if stats != expected {
+		t.Errorf("Stats() = %+v, expected %+v", stats, expected)
+	}
+	if stats.FileSize != c.Metrics().FileSize {
+		t.Errorf("Stats() FileSize = %d, expected %d like Metrics", stats.FileSize, c.Metrics().FileSize)
+	}
+}
+
+func TestStatsEmpty(t *testing.T) {
+	for name, c := range map[string]*Client{"file": newTestClient(t), "memory": NewMemoryClient()} {
+		if err := c.EnsureDB(ctx); err != nil {
+			t.Fatal(err)
+		}
+		stats, err := c.Stats(ctx)
+		if err != nil {
+			t.Fatalf("%s: Stats() = %v", name, err)
+		}
+		stats.FileSize = 0
+		if stats != (Stats{}) {
+			t.Errorf("%s: Stats() on an empty db = %+v, expected zero values", name, stats)
+		}
+	}
+}
+
+// BenchmarkStats scans every post under the read lock
+func BenchmarkStats(b *testing.B) {
+	c := seedLargeDB(b, 100000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Stats(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
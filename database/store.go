@@ -0,0 +1,431 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"time"
+)
+
+// Store -
+// where a Client keeps its data, the json file by default
+// the client holds the whole Schema in memory and hands it to Save after every mutation,
+// it never calls a Store concurrently
+type Store interface {
+	// Load returns the stored data, or an error wrapping fs.ErrNotExist if nothing was saved yet
+	Load(ctx context.Context) (Schema, error)
+	// Save replaces the stored data
+	Save(ctx context.Context, db Schema) error
+}
+
+// Locker -
+// optionally implemented by a Store that is shared between processes
+// the client holds the lock around every read-modify-write cycle
+type Locker interface {
+	Lock(ctx context.Context) (unlock func(), err error)
+}
+
+// ChangeDetector -
+// optionally implemented by a Store that can be written to by someone else
+// Changed reports whether the data changed since this store last loaded or saved it,
+// the client reloads before a write when it did
+type ChangeDetector interface {
+	Changed() bool
+}
+
+// Flusher -
+// optionally implemented by a Store that buffers writes, Close calls Flush
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Sizer -
+// optionally implemented by a Store that doesn't keep db as compact json
+// Size is how many bytes db takes once saved, which is what WithMaxSizeBytes limits
+type Sizer interface {
+	Size(db Schema) (int64, error)
+}
+
+// clone -
+// copy of the db that can be modified without touching the original
+// records are values so copying the maps is enough
+func (db Schema) clone() Schema {
+	copied := Schema{
+		SchemaVersion: db.SchemaVersion,
+		Users:         make(map[string]User, len(db.Users)),
+		Posts:         make(map[string]Post, len(db.Posts)),
+	}
+	for email, user := range db.Users {
+		copied.Users[email] = user
+	}
+	for id, post := range db.Posts {
+		copied.Posts[id] = post
+	}
+	if db.ResetTokens != nil {
+		copied.ResetTokens = make(map[string]ResetToken, len(db.ResetTokens))
+		for key, reset := range db.ResetTokens {
+			copied.ResetTokens[key] = reset
+		}
+	}
+	if db.VerificationTokens != nil {
+		copied.VerificationTokens = make(map[string]VerificationToken, len(db.VerificationTokens))
+		for key, verification := range db.VerificationTokens {
+			copied.VerificationTokens[key] = verification
+		}
+	}
+	copied.Blocks = db.Blocks.clone()
+	copied.Mutes = db.Mutes.clone()
+	copied.Follows = db.Follows.clone()
+	copied.Followers = db.Followers.clone()
+	copied.FollowRequests = db.FollowRequests.clone()
+	copied.PostIndex = db.PostIndex.clone()
+	copied.Hashtags = db.Hashtags.clone()
+	copied.Mentioned = db.Mentioned.clone()
+	copied.Reposts = db.Reposts.clone()
+	copied.Replies = db.Replies.clone()
+	copied.Authored = db.Authored.clone()
+	copied.Reactions = db.Reactions.clone()
+	copied.PollVotes = db.PollVotes.clone()
+	if db.FriendRequests != nil {
+		copied.FriendRequests = make(map[string]FriendRequest, len(db.FriendRequests))
+		for key, request := range db.FriendRequests {
+			copied.FriendRequests[key] = request
+		}
+	}
+	if db.NameHistory != nil {
+		copied.NameHistory = make(map[string][]NameChange, len(db.NameHistory))
+		for email, history := range db.NameHistory {
+			copied.NameHistory[email] = append([]NameChange{}, history...)
+		}
+	}
+	if db.PostEdits != nil {
+		copied.PostEdits = make(map[string][]PostEdit, len(db.PostEdits))
+		for id, edits := range db.PostEdits {
+			copied.PostEdits[id] = append([]PostEdit{}, edits...)
+		}
+	}
+	if db.Usernames != nil {
+		copied.Usernames = make(map[string]string, len(db.Usernames))
+		for username, email := range db.Usernames {
+			copied.Usernames[username] = email
+		}
+	}
+	return copied
+}
+
+// newSchema returns an empty db at the current version
+func newSchema() Schema {
+	return Schema{
+		SchemaVersion: currentSchemaVersion,
+		Users:         make(map[string]User),
+		Posts:         make(map[string]Post),
+	}
+}
+
+// lock -
+// take the in-process write lock and then the store's lock if it has one
+// returns a func that releases both
+func (c *Client) lock(ctx context.Context) (func(), error) {
+	if err := c.checkNotInTx(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	release, err := c.lockStore(ctx)
+	if err != nil {
+		c.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		release()
+		c.mu.Unlock()
+	}, nil
+}
+
+// lockStore -
+// take the store's lock if it has one, caller must hold the in-process write lock
+func (c *Client) lockStore(ctx context.Context) (func(), error) {
+	// readers never need the store's lock, every write replaces the file as a whole
+	locker, ok := c.store.(Locker)
+	if !ok || c.readOnly {
+		return func() {}, nil
+	}
+	return locker.Lock(ctx)
+}
+
+// load -
+// read the db from the store into memory, caller must hold the write lock
+func (c *Client) load(ctx context.Context) error {
+	start := time.Now()
+	db, err := c.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+	// a Store other than the ones here knows nothing of the indexes
+	if (db.PostIndex == nil || db.Hashtags == nil || db.Mentioned == nil || db.Reposts == nil || db.Replies == nil || db.Authored == nil) && len(db.Posts) > 0 {
+		db.indexPosts()
+	}
+	c.mem = &db
+	c.logDebug("loaded db", "duration", time.Since(start), "users", len(db.Users), "posts", len(db.Posts))
+	return c.measure()
+}
+
+// save -
+// write db to the store at the current version and keep it as the in-memory copy
+// with WithBatchedWrites it's only kept in memory and saved later
+// caller must hold the write lock
+func (c *Client) save(ctx context.Context, db Schema) error {
+	if c.readOnly {
+		return ErrReadOnly
+	}
+	db.SchemaVersion = currentSchemaVersion
+	if c.batch != nil {
+		c.mem = &db
+		c.queue()
+		return nil
+	}
+	start := time.Now()
+	if err := c.store.Save(ctx, db); err != nil {
+		return err
+	}
+	c.mem = &db
+	c.logDebug("saved db", "duration", time.Since(start), "users", len(db.Users), "posts", len(db.Posts))
+	return nil
+}
+
+// stale -
+// true if there's nothing in memory yet or the store was changed by someone else
+// caller must hold the write lock
+func (c *Client) stale() bool {
+	if c.mem == nil {
+		return true
+	}
+	// reloading would throw away writes that haven't been saved yet
+	if c.batch != nil && c.batch.pending > 0 {
+		return false
+	}
+	detector, ok := c.store.(ChangeDetector)
+	return ok && detector.Changed()
+}
+
+// EnsureDB -
+// check if db exists already, if good do nothing, otherwise create an empty one
+// data from an older schema version is upgraded and saved in the current version
+// a missing or empty file is initialized, a file that isn't a database gives ErrNotDatabase
+// and a damaged one ErrDBCorrupt, both without touching it
+func (c *Client) EnsureDB(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// the load below replaces the in-memory copy, batched writes have to be saved first
+	if err := c.flushPending(ctx); err != nil {
+		return err
+	}
+
+	db, err := c.store.Load(ctx)
+	if isCorrupt(err) && c.autoRecover {
+		_, err = c.recover(ctx)
+		return err
+	}
+	// create new db if doesn't exist
+	if errors.Is(err, fs.ErrNotExist) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return c.saveNow(ctx, newSchema())
+	}
+	if err != nil {
+		return err
+	}
+
+	// already exists, save it back if it was upgraded from an older version
+	// nothing is written unless every migration succeeded
+	// a read-only client keeps the upgrade in memory only
+	if db.SchemaVersion == currentSchemaVersion || c.readOnly {
+		c.mem = &db
+		return c.measure()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.saveNow(ctx, db)
+}
+
+// saveNow -
+// save even with WithBatchedWrites, EnsureDB's errors are the ones callers check on startup
+// caller must hold the write lock
+func (c *Client) saveNow(ctx context.Context, db Schema) error {
+	if err := c.save(ctx, db); err != nil {
+		return err
+	}
+	if err := c.measure(); err != nil {
+		return err
+	}
+	return c.flushPending(ctx)
+}
+
+// Reload -
+// throw away the in-memory copy and load the db from the store again
+// needed when another process has written to the file and this client only reads
+func (c *Client) Reload(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := c.checkNotInTx(ctx); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.batch != nil && c.batch.pending > 0 {
+		release, err := c.lockStore(ctx)
+		if err != nil {
+			return err
+		}
+		defer release()
+		if err := c.flushPending(ctx); err != nil {
+			return err
+		}
+	}
+	return c.load(ctx)
+}
+
+// Close -
+// write out anything still batched or buffered by the store (see Flush) and stop watching
+// the file. the client can keep being used afterwards
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		if c.stop != nil {
+			close(c.stop)
+		}
+	})
+	return c.Flush(context.Background())
+}
+
+// update -
+// run a read-modify-write cycle on the db while holding the write locks
+// fn works on a copy, nothing is saved or kept in memory if fn returns an error
+// or ctx is done before the save. hooks for what changed run after the locks are released
+// op is the name of the calling method and key the email or post ID it's about (if any),
+// what the write is counted and logged as
+func (c *Client) update(ctx context.Context, op, key string, fn func(db *Schema) error) error {
+	return c.commit(ctx, op, key, false, fn)
+}
+
+// deleteUpdate -
+// update for a write that deletes, soft deletes or unlinks records. it goes through on a db
+// that's at WithMaxSizeBytes even if it grows it, like a soft delete's DeletedAt does
+func (c *Client) deleteUpdate(ctx context.Context, op, key string, fn func(db *Schema) error) error {
+	return c.commit(ctx, op, key, true, fn)
+}
+
+// commit -
+// update or, when deleting, deleteUpdate
+func (c *Client) commit(ctx context.Context, op, key string, deleting bool, fn func(db *Schema) error) error {
+	start := time.Now()
+	events, err := c.write(ctx, deleting, fn)
+	c.finished(op, key, true, time.Since(start), err)
+	if err != nil {
+		return err
+	}
+	c.hooks.dispatch(events)
+	return nil
+}
+
+// write -
+// commit without the hooks, returns the hook calls for the changes it saved
+func (c *Client) write(ctx context.Context, deleting bool, fn func(db *Schema) error) ([]func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if c.readOnly {
+		return nil, ErrReadOnly
+	}
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err := c.takeFlushErr(); err != nil {
+		return nil, err
+	}
+
+	// another process may have written since we last looked, start from its version
+	if c.stale() {
+		if err := c.load(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	db := c.mem.clone()
+	if err := fn(&db); err != nil {
+		if errors.Is(err, errNoop) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// last chance to back out before touching the store
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	size, err := c.checkQuota(db, deleting)
+	if err != nil {
+		return nil, err
+	}
+	events := c.hooks.events(*c.mem, db)
+	if err := c.save(ctx, db); err != nil {
+		return nil, err
+	}
+	c.quota.setSize(size)
+	return events, nil
+}
+
+// view -
+// hand the in-memory db to fn under the read lock, loading it first if needed
+// fn must not modify db, op and key are the same as for update
+func (c *Client) view(ctx context.Context, op, key string, fn func(db *Schema) error) error {
+	start := time.Now()
+	err := c.read(ctx, fn)
+	c.finished(op, key, false, time.Since(start), err)
+	return err
+}
+
+// read -
+// view without the metrics
+func (c *Client) read(ctx context.Context, fn func(db *Schema) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := c.checkNotInTx(ctx); err != nil {
+		return err
+	}
+	if err := c.ensureLoaded(ctx); err != nil {
+		return err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return fn(c.mem)
+}
+
+// ensureLoaded -
+// load the db into memory if this is the first use of the client
+func (c *Client) ensureLoaded(ctx context.Context) error {
+	c.mu.RLock()
+	loaded := c.mem != nil
+	c.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.mem != nil {
+		return nil
+	}
+	return c.load(ctx)
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+)
+
+// fakeStore keeps the db in a field and counts calls, failing saves on demand
+type fakeStore struct {
+	db      *Schema
+	loads   int
+	saves   int
+	saveErr error
+}
+
+func (s *fakeStore) Load(ctx context.Context) (Schema, error) {
+	s.loads++
+	if s.db == nil {
+		return Schema{}, fmt.Errorf("fake store: %w", fs.ErrNotExist)
+	}
+	return s.db.clone(), nil
+}
+
+func (s *fakeStore) Save(ctx context.Context, db Schema) error {
+	if s.saveErr != nil {
+		return s.saveErr
+	}
+	s.saves++
+	copied := db.clone()
+	s.db = &copied
+	return nil
+}
+
+func TestClientWithFakeStore(t *testing.T) {
+	store := &fakeStore{}
+	c := NewClientWithStore(store)
+
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatalf("EnsureDB() = %v, expected nil", err)
+	}
+	if store.db == nil || store.db.SchemaVersion != currentSchemaVersion {
+		t.Fatalf("EnsureDB() didn't save an empty db at the current version: %+v", store.db)
+	}
+	if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	post, err := c.CreatePost(ctx, "test@example.com", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := store.db.Posts[post.ID]; !got.equal(post) {
+		t.Errorf("stored post = %v, expected %v", got, post)
+	}
+	if store.saves != 3 {
+		t.Errorf("store.saves = %d, expected 3", store.saves)
+	}
+
+	// reads come from memory, not the store
+	loads := store.loads
+	if _, err := c.GetPosts(ctx, "test@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if store.loads != loads {
+		t.Errorf("GetPosts() loaded from the store, expected in-memory read")
+	}
+
+	// a failing save changes nothing
+	store.saveErr = errors.New("backend down")
+	if _, err := c.DeletePost(ctx, post.ID); !errors.Is(err, store.saveErr) {
+		t.Errorf("DeletePost() = %v, expected %v", err, store.saveErr)
+	}
+	if _, ok := store.db.Posts[post.ID]; !ok {
+		t.Errorf("post removed from store after failed save")
+	}
+	posts, err := c.GetPosts(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 {
+		t.Errorf("len(GetPosts()) after failed save = %d, expected 1", len(posts))
+	}
+
+	// file-only operations say so
+	if err := c.Backup(ctx, "unused"); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("Backup() = %v, expected %v", err, ErrNotSupported)
+	}
+}
+
+func TestEnsureDBValidatesFile(t *testing.T) {
+	var tests = []struct {
+		name        string
+		contents    string
+		expectedErr error
+	}{
+		{name: "valid file", contents: `{"schemaVersion":1,"users":{},"posts":{}}`},
+		{name: "empty file", contents: ""},
+		{name: "whitespace only", contents: " \n"},
+		{name: "missing maps", contents: `{"schemaVersion":1}`},
+		{name: "missing posts", contents: `{"schemaVersion":1,"users":{}}`},
+		{name: "hostname", contents: "my-laptop\n", expectedErr: ErrNotDatabase},
+		{name: "other json", contents: `{"name":"some other program"}`, expectedErr: ErrNotDatabase},
+		{name: "truncated db", contents: `{"schemaVersion":1,"users":{`, expectedErr: ErrDBCorrupt},
+	}
+
+	for _, test := range tests {
+		c := writeRawDB(t, test.contents)
+		err := c.EnsureDB(ctx)
+		if !errors.Is(err, test.expectedErr) {
+			t.Errorf("%s: EnsureDB() = %v, expected %v", test.name, err, test.expectedErr)
+		}
+		if test.expectedErr != nil {
+			// nothing was overwritten
+			assertFileContents(t, dbPath(c), test.contents)
+			continue
+		}
+
+		// the db is usable, maps included
+		if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+			t.Errorf("%s: CreateUser() after EnsureDB = %v, expected nil", test.name, err)
+		}
+		if _, err := c.CreatePost(ctx, "test@example.com", "hello"); err != nil {
+			t.Errorf("%s: CreatePost() after EnsureDB = %v, expected nil", test.name, err)
+		}
+	}
+}
+
+func TestEnsureDBReadError(t *testing.T) {
+	// a directory exists at the path but can't be read as a file
+	c := NewClient(t.TempDir())
+	err := c.EnsureDB(ctx)
+	if err == nil || errors.Is(err, ErrNotDatabase) || errors.Is(err, ErrDBCorrupt) || errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("EnsureDB() on a directory = %v, expected a plain read error", err)
+	}
+}
@@ -0,0 +1,412 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Tx -
+// a set of reads and writes on one in-memory copy of the db, see Client.Tx
+// not safe for concurrent use and only valid inside the Tx callback
+type Tx struct {
+	db             *Schema
+	clock          Clock
+	ids            IDGenerator
+	passwordCost   int
+	passwordPolicy PasswordPolicy
+	retention      time.Duration
+	minimumAge     int
+	verifiedOnly   bool
+	nameHistory    int
+	maxPostLength  int
+	postEdits      int
+	postRetention  time.Duration
+	pinnedFirst    bool
+}
+
+// newTx wraps db, the Client methods use one for every call
+func (c *Client) newTx(db *Schema) *Tx {
+	return &Tx{
+		db:             db,
+		clock:          c.clock,
+		ids:            c.ids,
+		passwordCost:   c.passwordCost,
+		passwordPolicy: c.passwordPolicy,
+		retention:      c.retention,
+		minimumAge:     c.minimumAge,
+		verifiedOnly:   c.verifiedOnly,
+		nameHistory:    c.nameHistory,
+		maxPostLength:  c.maxPostLength,
+		postEdits:      c.postEdits,
+		postRetention:  c.postRetention,
+		pinnedFirst:    c.pinnedFirst,
+	}
+}
+
+// now is the CreatedAt for records made in the Tx
+func (tx *Tx) now() time.Time {
+	return tx.clock.Now().UTC()
+}
+
+// txKey marks the context handed to a Tx callback with the client running it
+type txKey struct{}
+
+// Tx -
+// run fn with a Tx whose writes are saved together in one write if fn returns nil
+// if fn returns an error nothing is written and the db stays exactly as it was
+// calling the client from inside fn with the ctx it was given returns ErrNestedTx,
+// use the Tx's methods instead
+func (c *Client) Tx(ctx context.Context, fn func(ctx context.Context, tx *Tx) error) error {
+	return c.update(ctx, "Tx", "", func(db *Schema) error {
+		tx := c.newTx(db)
+		// any use after fn returns would modify the copy that's now in memory
+		defer func() { tx.db = nil }()
+		return fn(context.WithValue(ctx, txKey{}, c), tx)
+	})
+}
+
+// checkNotInTx -
+// ErrNestedTx if ctx belongs to a Tx callback of this client, taking the lock again would deadlock
+func (c *Client) checkNotInTx(ctx context.Context) error {
+	if ctx.Value(txKey{}) == c {
+		return ErrNestedTx
+	}
+	return nil
+}
+
+// schema returns the db the Tx works on or ErrTxDone once its callback has returned
+func (tx *Tx) schema() (*Schema, error) {
+	if tx.db == nil {
+		return nil, ErrTxDone
+	}
+	return tx.db, nil
+}
+
+// CreatePost -
+// same as Client.CreatePost, inside the Tx
+func (tx *Tx) CreatePost(ctx context.Context, userEmail, text string) (Post, error) {
+	return tx.CreatePostWithOptions(ctx, userEmail, text, CreatePostOptions{})
+}
+
+// CreatePostAt -
+// same as Client.CreatePostAt, inside the Tx
+func (tx *Tx) CreatePostAt(ctx context.Context, userEmail, text string, createdAt time.Time) (Post, error) {
+	return tx.CreatePostWithOptions(ctx, userEmail, text, CreatePostOptions{CreatedAt: createdAt})
+}
+
+// CreatePostWithOptions -
+// same as Client.CreatePostWithOptions, inside the Tx
+func (tx *Tx) CreatePostWithOptions(ctx context.Context, userEmail, text string, opts CreatePostOptions) (Post, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return Post{}, err
+	}
+	userEmail = EmailKey(userEmail)
+	// ensure user exists
+	if err := tx.checkCanPost(db, userEmail); err != nil {
+		return Post{}, err
+	}
+	text, err = PostText(text, tx.maxPostLength)
+	if err != nil {
+		return Post{}, err
+	}
+	visibility, err := storedVisibility(opts.Visibility)
+	if err != nil {
+		return Post{}, err
+	}
+	attachments, err := postAttachments(opts.Attachments)
+	if err != nil {
+		return Post{}, err
+	}
+	parentID := ""
+	if opts.ParentID != "" {
+		parent, err := db.sharedPost(opts.ParentID, userEmail, tx.now())
+		if err != nil {
+			return Post{}, err
+		}
+		parentID = parent.ID
+	}
+	createdAt := opts.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = tx.now()
+	}
+	var publishAt *time.Time
+	if !opts.Draft && opts.PublishAt.After(tx.now()) {
+		createdAt = opts.PublishAt.UTC()
+		publishAt = &createdAt
+	}
+	id, err := tx.newPostID(db)
+	if err != nil {
+		return Post{}, err
+	}
+
+	// create new post and add to db
+	post := Post{
+		ID:          id,
+		CreatedAt:   createdAt.UTC(),
+		UserEmail:   userEmail,
+		Text:        text,
+		Visibility:  visibility,
+		IsDraft:     opts.Draft,
+		PublishAt:   publishAt,
+		Tags:        hashtags(text),
+		Mentions:    db.mentions(text, userEmail),
+		Attachments: attachments,
+		ParentID:    parentID,
+	}
+	db.putPost(post)
+	return post, nil
+}
+
+// checkCanPost -
+// whether the user with email may write posts: ErrUserNotFound if there's no such user,
+// ErrUserDeleted, ErrAccountDeactivated or ErrEmailNotVerified if it can't post
+func (tx *Tx) checkCanPost(db *Schema, email string) error {
+	user, ok := db.Users[email]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+	if user.DeletedAt != nil {
+		return fmt.Errorf("%w: %s", ErrUserDeleted, email)
+	}
+	if !user.Active() {
+		return fmt.Errorf("%w: %s", ErrAccountDeactivated, email)
+	}
+	if tx.verifiedOnly && !user.Verified {
+		return fmt.Errorf("%w: %s", ErrEmailNotVerified, email)
+	}
+	return nil
+}
+
+// GetPosts -
+// same as Client.GetPosts, sees posts created earlier in the Tx
+func (tx *Tx) GetPosts(ctx context.Context, userEmail string) ([]Post, error) {
+	return tx.GetPostsAs(ctx, "", userEmail)
+}
+
+// GetPostsAs -
+// same as Client.GetPostsAs, sees posts created earlier in the Tx
+func (tx *Tx) GetPostsAs(ctx context.Context, viewer, userEmail string) ([]Post, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return []Post{}, err
+	}
+	viewer, userEmail = EmailKey(viewer), EmailKey(userEmail)
+	allPosts := []Post{}
+	// hidden while the account is deactivated, IteratePosts with IncludeDeactivated still has them
+	if user, ok := db.Users[userEmail]; ok && !user.Active() {
+		return allPosts, nil
+	}
+	now := tx.now()
+	i := 0
+	for _, post := range db.Posts {
+		// full scan, bail out if the caller gave up
+		if i++; i%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return []Post{}, err
+			}
+		}
+		if post.UserEmail == userEmail && post.DeletedAt == nil && !post.unpublished(now) && db.canSeePost(viewer, post) {
+			allPosts = append(allPosts, post)
+		}
+	}
+	SortPosts(allPosts, NewestFirst)
+	if tx.pinnedFirst {
+		allPosts = pinnedFirst(allPosts, db.Users[userEmail].PinnedPostID)
+	}
+	return allPosts, nil
+}
+
+// DeletePost -
+// same as Client.DeletePost, inside the Tx
+func (tx *Tx) DeletePost(ctx context.Context, id string) (Post, error) {
+	if id == "" {
+		return Post{}, ErrEmptyPostID
+	}
+	db, err := tx.schema()
+	if err != nil {
+		return Post{}, err
+	}
+	post, ok := db.Posts[id]
+	if !ok {
+		return Post{}, fmt.Errorf("%w: %s", ErrPostNotFound, id)
+	}
+	db.deletePost(id)
+	return post, nil
+}
+
+// CreateUser -
+// same as Client.CreateUser, inside the Tx
+func (tx *Tx) CreateUser(ctx context.Context, email, password, name string, age int) (User, error) {
+	return tx.hashAndPutUser(email, password, name, age, time.Time{}, false)
+}
+
+// CreateUserAt -
+// same as Client.CreateUserAt, inside the Tx
+func (tx *Tx) CreateUserAt(ctx context.Context, email, password, name string, age int, createdAt time.Time) (User, error) {
+	return tx.hashAndPutUser(email, password, name, age, createdAt, false)
+}
+
+// UpsertUser -
+// same as Client.UpsertUser, inside the Tx
+func (tx *Tx) UpsertUser(ctx context.Context, email, password, name string, age int) (User, error) {
+	return tx.hashAndPutUser(email, password, name, age, time.Time{}, true)
+}
+
+// hashAndPutUser is putUser for a plaintext password
+func (tx *Tx) hashAndPutUser(email, password, name string, age int, createdAt time.Time, overwrite bool) (User, error) {
+	if err := CheckAge(age, tx.minimumAge); err != nil {
+		return User{}, err
+	}
+	hash, err := newPasswordHash(password, tx.passwordPolicy, tx.passwordCost)
+	if err != nil {
+		return User{}, err
+	}
+	return tx.putUser(email, hash, name, age, createdAt, overwrite)
+}
+
+// putUser -
+// store a new user with an already hashed password, created at createdAt (now if zero),
+// only replacing the password, name and age of an existing one when overwrite is set. that one keeps
+// its CreatedAt unless createdAt is given and is active again, not soft-deleted or deactivated
+func (tx *Tx) putUser(email, passwordHash, name string, age int, createdAt time.Time, overwrite bool) (User, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return User{}, err
+	}
+	if email, err = NormalizeEmail(email); err != nil {
+		return User{}, err
+	}
+	if err := CheckAge(age, tx.minimumAge); err != nil {
+		return User{}, err
+	}
+	old, exists := db.Users[email]
+	if exists && !overwrite {
+		return User{}, fmt.Errorf("%w: %s", ErrUserExists, email)
+	}
+	updatedAt := tx.now()
+	switch {
+	case !createdAt.IsZero():
+		updatedAt = createdAt
+	case exists:
+		createdAt = old.CreatedAt
+	default:
+		createdAt = updatedAt
+	}
+
+	// an overwritten user keeps everything but what was given, its username, role, settings and the rest.
+	// whoever overwrote it meant it to be usable, a tombstone or deactivation would hide it instead
+	newUser := old
+	newUser.CreatedAt = createdAt.UTC()
+	newUser.Email = email
+	newUser.PasswordHash = passwordHash
+	newUser.Name = name
+	newUser.Age = age
+	newUser.UpdatedAt = updatedAt.UTC()
+	newUser.DeletedAt = nil
+	if !newUser.Active() {
+		newUser.Status = UserActive
+	}
+	db.putUser(newUser)
+	if exists {
+		tx.recordNameChanges(old, newUser)
+	}
+	return newUser, nil
+}
+
+// UpdateUser -
+// same as Client.UpdateUser, inside the Tx
+func (tx *Tx) UpdateUser(ctx context.Context, email, password, name string, age int) (User, error) {
+	if err := CheckAge(age, tx.minimumAge); err != nil {
+		return User{}, err
+	}
+	hash, err := newPasswordHash(password, tx.passwordPolicy, tx.passwordCost)
+	if err != nil {
+		return User{}, err
+	}
+	return tx.updateUser(email, hash, name, age)
+}
+
+// updateUser -
+// UpdateUser with an already hashed password
+func (tx *Tx) updateUser(email, passwordHash, name string, age int) (User, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return User{}, err
+	}
+	if email, err = NormalizeEmail(email); err != nil {
+		return User{}, err
+	}
+	if err := CheckAge(age, tx.minimumAge); err != nil {
+		return User{}, err
+	}
+	// check if email is a key in db.Users
+	old, ok := db.activeUser(email)
+	if !ok {
+		return User{}, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+
+	// user does exist, we will update (email and CreatedAt fields won't change)
+	user := old
+	user.PasswordHash = passwordHash
+	user.Name = name
+	user.Age = age
+	user.UpdatedAt = tx.now()
+	db.putUser(user)
+	tx.recordNameChanges(old, user)
+	return user, nil
+}
+
+// GetUser -
+// same as Client.GetUser, sees users created or changed earlier in the Tx
+func (tx *Tx) GetUser(ctx context.Context, email string) (User, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return User{}, err
+	}
+	email = EmailKey(email)
+	user, ok := db.activeUser(email)
+	if !ok {
+		return User{}, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+	return user, nil
+}
+
+// DeleteUser -
+// same as Client.DeleteUser, inside the Tx
+func (tx *Tx) DeleteUser(ctx context.Context, email string, opts DeleteUserOptions) (DeleteUserResult, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return DeleteUserResult{}, err
+	}
+	email = EmailKey(email)
+	result := DeleteUserResult{}
+	if _, ok := db.Users[email]; !ok {
+		if opts.IgnoreMissing {
+			return result, nil
+		}
+		return DeleteUserResult{}, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+
+	// handle the user's posts in the same write as the user so it's all or nothing
+	result.Deleted = true
+	now := tx.now()
+	for id, post := range db.Posts {
+		if post.UserEmail != email {
+			continue
+		}
+		result.Posts++
+		// drafts and scheduled posts were never posted, there's nothing to keep
+		switch {
+		case post.unpublished(now) || opts.Posts == PostsCascade:
+			db.deletePost(id)
+		case opts.Posts == PostsAnonymize:
+			post.UserEmail = DeletedUserEmail
+			db.putPost(post)
+		}
+	}
+
+	db.deleteUser(email)
+	return result, nil
+}
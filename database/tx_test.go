@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestTxCommit(t *testing.T) {
+	store := &fakeStore{}
+	c := NewClientWithStore(store)
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	saves := store.saves
+
+	var posts []Post
+	err := c.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		if _, err := tx.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+			return err
+		}
+		for _, text := range []string{"first", "second", "third"} {
+			post, err := tx.CreatePost(ctx, "test@example.com", text)
+			if err != nil {
+				return err
+			}
+			posts = append(posts, post)
+		}
+		_, err := tx.DeletePost(ctx, posts[0].ID)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Tx() = %v, expected nil", err)
+	}
+	if store.saves != saves+1 {
+		t.Errorf("Tx() saved %d times, expected once", store.saves-saves)
+	}
+
+	got, err := c.GetPosts(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Errorf("GetPosts() after Tx = %v, expected %d posts", got, 2)
+	}
+	if _, ok := store.db.Posts[posts[0].ID]; ok {
+		t.Errorf("post deleted in the Tx was saved")
+	}
+}
+
+func TestTxRollback(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed := errors.New("changed my mind")
+	err = c.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		if _, err := tx.UpdateUser(ctx, "test@example.com", "54321", "jane doe", 20); err != nil {
+			return err
+		}
+		if _, err := tx.CreatePost(ctx, "test@example.com", "hello"); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("Tx() = %v, expected %v", err, failed)
+	}
+
+	assertFileContents(t, dbPath(c), string(before))
+	user, err := c.GetUser(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "john doe" {
+		t.Errorf("user.Name after rollback = %s, expected john doe", user.Name)
+	}
+	if posts, _ := c.GetPosts(ctx, "test@example.com"); len(posts) != 0 {
+		t.Errorf("GetPosts() after rollback = %v, expected none", posts)
+	}
+}
+
+func TestTxReadsOwnWrites(t *testing.T) {
+	c := newTestClient(t)
+	err := c.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		if _, err := tx.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+			return err
+		}
+		if _, err := tx.UpdateUser(ctx, "test@example.com", "12345", "john doe", 19); err != nil {
+			return err
+		}
+		user, err := tx.GetUser(ctx, "test@example.com")
+		if err != nil {
+			t.Errorf("GetUser() inside Tx = %v, expected the user created in it", err)
+		} else if user.Age != 19 {
+			t.Errorf("user.Age inside Tx = %d, expected 19", user.Age)
+		}
+
+		post, err := tx.CreatePost(ctx, "test@example.com", "hello")
+		if err != nil {
+			return err
+		}
+		posts, err := tx.GetPosts(ctx, "test@example.com")
+		if err != nil {
+			return err
+		}
+		if len(posts) != 1 || !posts[0].equal(post) {
+			t.Errorf("GetPosts() inside Tx = %v, expected [%v]", posts, post)
+		}
+
+		// the client itself still sees the db from before the Tx
+		if _, err := NewClient(dbPath(c)).GetUser(ctx, "test@example.com"); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("GetUser() from another client during Tx = %v, expected %v", err, ErrUserNotFound)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Tx() = %v, expected nil", err)
+	}
+}
+
+func TestTxNested(t *testing.T) {
+	c := newTestClient(t)
+	var leaked *Tx
+	err := c.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		leaked = tx
+		nested := c.Tx(ctx, func(ctx context.Context, tx *Tx) error { return nil })
+		if !errors.Is(nested, ErrNestedTx) {
+			t.Errorf("nested Tx() = %v, expected %v", nested, ErrNestedTx)
+		}
+		if _, err := c.GetUser(ctx, "test@example.com"); !errors.Is(err, ErrNestedTx) {
+			t.Errorf("GetUser() on the client inside Tx = %v, expected %v", err, ErrNestedTx)
+		}
+		if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); !errors.Is(err, ErrNestedTx) {
+			t.Errorf("CreateUser() on the client inside Tx = %v, expected %v", err, ErrNestedTx)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Tx() = %v, expected nil", err)
+	}
+
+	if _, err := leaked.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); !errors.Is(err, ErrTxDone) {
+		t.Errorf("CreateUser() on a finished Tx = %v, expected %v", err, ErrTxDone)
+	}
+}
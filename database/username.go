@@ -0,0 +1,209 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// length limits for usernames, in bytes (they're ascii)
+const (
+	minUsernameLen = 3
+	maxUsernameLen = 30
+)
+
+// validateUsername -
+// ErrInvalidUsername unless username is minUsernameLen to maxUsernameLen of a-z, 0-9, '.' and '_'
+func validateUsername(username string) error {
+	if len(username) < minUsernameLen || len(username) > maxUsernameLen {
+		return fmt.Errorf("%w: %q must be %d to %d characters", ErrInvalidUsername, username, minUsernameLen, maxUsernameLen)
+	}
+	for _, r := range username {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '.' && r != '_' {
+			return fmt.Errorf("%w: %q can only have lowercase letters, digits, dots and underscores", ErrInvalidUsername, username)
+		}
+	}
+	return nil
+}
+
+// putUser -
+// store user under its email, keeping the username index in step with the change
+// a user from before UpdatedAt gets its CreatedAt there
+func (db *Schema) putUser(user User) {
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = user.CreatedAt
+	}
+	if old, ok := db.Users[user.Email]; ok && old.Username != user.Username && db.Usernames[old.Username] == user.Email {
+		delete(db.Usernames, old.Username)
+	}
+	if user.Username != "" {
+		if db.Usernames == nil {
+			db.Usernames = make(map[string]string)
+		}
+		db.Usernames[user.Username] = user.Email
+	}
+	db.Users[user.Email] = user
+}
+
+// deleteUser -
+// remove the user with email, its username from the index, its reset and verification tokens
+// and the blocks, mutes, follows, follow requests and friend requests made by and against it,
+// its name history, the mentions of it and its reactions and poll votes
+func (db *Schema) deleteUser(email string) {
+	db.moveMentions(email, "")
+	db.Reactions.move(email, "")
+	db.PollVotes.move(email, "")
+	db.Blocks.drop(email)
+	db.Mutes.drop(email)
+	db.dropFollows(email)
+	db.FollowRequests.drop(email)
+	db.dropFriendRequests(email)
+	for key, reset := range db.ResetTokens {
+		if reset.UserEmail == email {
+			delete(db.ResetTokens, key)
+		}
+	}
+	db.dropVerificationTokens(email)
+	delete(db.NameHistory, email)
+	if user, ok := db.Users[email]; ok && db.Usernames[user.Username] == email {
+		delete(db.Usernames, user.Username)
+	}
+	delete(db.Users, email)
+}
+
+// indexUsernames -
+// rebuild the username index from Users, for a db that was just read
+// if hand edits gave two users the same username the first email in order gets it, Validate reports the rest
+func (db *Schema) indexUsernames() {
+	db.Usernames = nil
+	for _, email := range sortedKeys(db.Users) {
+		username := db.Users[email].Username
+		if username == "" {
+			continue
+		}
+		if db.Usernames == nil {
+			db.Usernames = make(map[string]string)
+		}
+		if _, taken := db.Usernames[username]; !taken {
+			db.Usernames[username] = email
+		}
+	}
+}
+
+// SetUsername -
+// same as Client.SetUsername, inside the Tx
+func (tx *Tx) SetUsername(ctx context.Context, email, username string) (User, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return User{}, err
+	}
+	if email, err = NormalizeEmail(email); err != nil {
+		return User{}, err
+	}
+	user, ok := db.activeUser(email)
+	if !ok {
+		return User{}, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+	if username != "" {
+		if err := validateUsername(username); err != nil {
+			return User{}, err
+		}
+		if owner, taken := db.Usernames[username]; taken && owner != email {
+			return User{}, fmt.Errorf("%w: %s", ErrUsernameTaken, username)
+		}
+	}
+	if user.Username != username {
+		old := user
+		user.Username = username
+		user.UpdatedAt = tx.now()
+		tx.recordNameChanges(old, user)
+	}
+	db.putUser(user)
+	return user, nil
+}
+
+// GetUserByUsername -
+// same as Client.GetUserByUsername, sees usernames set earlier in the Tx
+func (tx *Tx) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return User{}, err
+	}
+	// a soft-deleted user keeps the username until purged, but can't be found by it
+	user, ok := db.activeUser(db.Usernames[username])
+	if !ok {
+		return User{}, fmt.Errorf("%w: username %s", ErrUserNotFound, username)
+	}
+	return user, nil
+}
+
+// SetUsername -
+// give the user with email a username, replacing any previous one which is then free for others.
+// an empty username clears it. the email stays the key, only the username index changes,
+// in the same write. ErrInvalidUsername if it breaks the policy (3 to 30 lowercase letters,
+// digits, dots and underscores), ErrUsernameTaken if another user has it
+func (c *Client) SetUsername(ctx context.Context, email, username string) (User, error) {
+	user := User{}
+	err := c.update(ctx, "SetUsername", email, func(db *Schema) error {
+		var err error
+		user, err = c.newTx(db).SetUsername(ctx, email, username)
+		return err
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return c.sanitize(user), nil
+}
+
+// GetUserByUsername -
+// return the user with the given username from the index, ErrUserNotFound if nobody has it
+func (c *Client) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	user := User{}
+	err := c.view(ctx, "GetUserByUsername", username, func(db *Schema) error {
+		var err error
+		user, err = c.newTx(db).GetUserByUsername(ctx, username)
+		return err
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return c.sanitize(user), nil
+}
+
+// PublicPost -
+// a post as shown to other users, with the author's username instead of their email
+type PublicPost struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Author is the username of the author, empty if they have none or were deleted
+	Author string `json:"author"`
+	Text   string `json:"text"`
+}
+
+// GetPublicPosts -
+// GetPosts for showing to other users, each post carries the author's username and not the email.
+// whoever asks is taken to be logged out, so there are none of a private user and only public posts,
+// see GetPostsAs
+func (c *Client) GetPublicPosts(ctx context.Context, userEmail string) ([]PublicPost, error) {
+	public := []PublicPost{}
+	err := c.view(ctx, "GetPublicPosts", userEmail, func(db *Schema) error {
+		posts, err := c.newTx(db).GetPosts(ctx, userEmail)
+		if err != nil {
+			return err
+		}
+		for _, post := range posts {
+			author, _ := db.activeUser(post.UserEmail)
+			public = append(public, PublicPost{
+				ID:        post.ID,
+				CreatedAt: post.CreatedAt,
+				Author:    author.Username,
+				Text:      post.Text,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return []PublicPost{}, err
+	}
+	return public, nil
+}
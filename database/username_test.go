@@ -0,0 +1,278 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestValidateUsername(t *testing.T) {
+	var tests = []struct {
+		username string
+		expected bool // true means valid
+	}{
+		{username: "john", expected: true},
+		{username: "john.doe_99", expected: true},
+		{username: "abc", expected: true},
+		{username: strings.Repeat("a", maxUsernameLen), expected: true},
+		{username: "ab"},
+		{username: strings.Repeat("a", maxUsernameLen+1)},
+		{username: "John"},
+		{username: "john doe"},
+		{username: "john-doe"},
+		{username: "jöhn"},
+		{username: "john@example.com"},
+	}
+
+	for _, test := range tests {
+		err := validateUsername(test.username)
+		if test.expected && err != nil {
+			t.Errorf("validateUsername(%q) = %v, expected nil", test.username, err)
+		}
+		if !test.expected && !errors.Is(err, ErrInvalidUsername) {
+			t.Errorf("validateUsername(%q) = %v, expected ErrInvalidUsername", test.username, err)
+		}
+	}
+}
+
+// newUsernameClient has two users without usernames
+func newUsernameClient(t *testing.T, opts ...Option) *Client {
+	t.Helper()
+	c := NewClient(filepath.Join(t.TempDir(), "db.json"), opts...)
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c
+}
+
+func TestSetUsername(t *testing.T) {
+	c := newUsernameClient(t)
+	user, err := c.SetUsername(ctx, "a@example.com", "alice")
+	if err != nil || user.Username != "alice" || user.Email != "a@example.com" {
+		t.Fatalf("SetUsername() = %+v, %v, expected alice", user, err)
+	}
+	if got, err := c.GetUserByUsername(ctx, "alice"); err != nil || got != user {
+		t.Errorf("GetUserByUsername() = %+v, %v, expected %+v", got, err, user)
+	}
+
+	var tests = []struct {
+		email    string
+		username string
+		expected error
+	}{
+		{email: "b@example.com", username: "alice", expected: ErrUsernameTaken},
+		{email: "b@example.com", username: "Bob", expected: ErrInvalidUsername},
+		{email: "missing@example.com", username: "carol", expected: ErrUserNotFound},
+		{email: "not an email", username: "carol", expected: ErrInvalidEmail},
+		// setting the username a user already has is fine
+		{email: "a@example.com", username: "alice", expected: nil},
+	}
+	for _, test := range tests {
+		if _, err := c.SetUsername(ctx, test.email, test.username); !errors.Is(err, test.expected) {
+			t.Errorf("SetUsername(%s, %s) = %v, expected %v", test.email, test.username, err, test.expected)
+		}
+	}
+	if _, err := c.GetUserByUsername(ctx, "nobody"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUserByUsername() of unused username = %v, expected ErrUserNotFound", err)
+	}
+
+	// UpdateUser keeps it
+	if _, err := c.UpdateUser(ctx, "a@example.com", "654321", "alice doe", 20); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.GetUserByUsername(ctx, "alice"); err != nil || got.Name != "alice doe" {
+		t.Errorf("GetUserByUsername() after UpdateUser() = %+v, %v", got, err)
+	}
+}
+
+func TestRenameUsername(t *testing.T) {
+	c := newUsernameClient(t)
+	if _, err := c.SetUsername(ctx, "a@example.com", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetUsername(ctx, "a@example.com", "alice2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetUserByUsername(ctx, "alice"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUserByUsername() of the old name = %v, expected ErrUserNotFound", err)
+	}
+	if got, err := c.GetUserByUsername(ctx, "alice2"); err != nil || got.Email != "a@example.com" {
+		t.Errorf("GetUserByUsername() of the new name = %+v, %v, expected the same email", got, err)
+	}
+	// the old name is free again
+	if _, err := c.SetUsername(ctx, "b@example.com", "alice"); err != nil {
+		t.Errorf("SetUsername() of a released name = %v, expected nil", err)
+	}
+
+	// clearing and deleting the user release it too
+	if _, err := c.SetUsername(ctx, "a@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetUserByUsername(ctx, "alice2"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUserByUsername() after clearing = %v, expected ErrUserNotFound", err)
+	}
+	if _, err := c.DeleteUser(ctx, "b@example.com", DeleteUserOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetUsername(ctx, "a@example.com", "alice"); err != nil {
+		t.Errorf("SetUsername() of a deleted user's name = %v, expected nil", err)
+	}
+	// UpsertUser overwrites the name and password only, the user keeps its username
+	if _, err := c.UpsertUser(ctx, "a@example.com", "123456", "replaced", 18); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.GetUserByUsername(ctx, "alice"); err != nil || got.Name != "replaced" {
+		t.Errorf("GetUserByUsername() after UpsertUser() = %+v, %v, expected the upserted user", got, err)
+	}
+}
+
+func TestUsernameIndexPersists(t *testing.T) {
+	for name, opts := range map[string][]Option{"file": nil, "wal": {WithWAL(100)}} {
+		c := newUsernameClient(t, opts...)
+		if _, err := c.SetUsername(ctx, "a@example.com", "alice"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.SetUsername(ctx, "a@example.com", "alice2"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.SetUsername(ctx, "b@example.com", "alice"); err != nil {
+			t.Fatal(err)
+		}
+
+		// a fresh client builds the index from what's on disk, the log included
+		reopened := NewClient(dbPath(c), opts...)
+		for username, email := range map[string]string{"alice": "b@example.com", "alice2": "a@example.com"} {
+			if got, err := reopened.GetUserByUsername(ctx, username); err != nil || got.Email != email {
+				t.Errorf("%s: GetUserByUsername(%s) after reopening = %+v, %v, expected %s", name, username, got, err, email)
+			}
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSetUsernameRace(t *testing.T) {
+	c := newTestClient(t)
+	const users = 20
+	for i := 0; i < users; i++ {
+		if _, err := c.CreateUser(ctx, fmt.Sprintf("user%d@example.com", i), "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// everyone goes for the same name, then renames around it
+	var wg sync.WaitGroup
+	errs := make(chan error, users)
+	for i := 0; i < users; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			email := fmt.Sprintf("user%d@example.com", i)
+			_, err := c.SetUsername(ctx, email, "popular")
+			errs <- err
+			if _, err := c.SetUsername(ctx, email, fmt.Sprintf("user_%d", i)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	won := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			won++
+		case !errors.Is(err, ErrUsernameTaken):
+			t.Errorf("SetUsername() = %v, expected nil or ErrUsernameTaken", err)
+		}
+	}
+	// whoever won may have renamed before the others tried, so at least one, never two at once
+	if won < 1 {
+		t.Errorf("%d users got the username, expected at least 1", won)
+	}
+
+	db, err := c.Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.Usernames) != users {
+		t.Errorf("index has %d usernames, expected %d: %v", len(db.Usernames), users, db.Usernames)
+	}
+	for username, email := range db.Usernames {
+		if db.Users[email].Username != username {
+			t.Errorf("index has %s for %s, the user has %q", username, email, db.Users[email].Username)
+		}
+	}
+}
+
+func TestSetUsernameTx(t *testing.T) {
+	c := newUsernameClient(t)
+	// both renames or neither
+	err := c.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		if _, err := tx.SetUsername(ctx, "a@example.com", "alice"); err != nil {
+			return err
+		}
+		if got, err := tx.GetUserByUsername(ctx, "alice"); err != nil || got.Email != "a@example.com" {
+			t.Errorf("Tx GetUserByUsername() = %+v, %v, expected the rename inside the Tx", got, err)
+		}
+		_, err := tx.SetUsername(ctx, "b@example.com", "alice")
+		return err
+	})
+	if !errors.Is(err, ErrUsernameTaken) {
+		t.Fatalf("Tx() = %v, expected ErrUsernameTaken", err)
+	}
+	if _, err := c.GetUserByUsername(ctx, "alice"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUserByUsername() after a failed Tx = %v, expected ErrUserNotFound", err)
+	}
+}
+
+func TestImportUsernameConflict(t *testing.T) {
+	c := newUsernameClient(t)
+	if _, err := c.SetUsername(ctx, "a@example.com", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	dump := `{"schemaVersion": 1, "users": {"x@example.com": {"email": "x@example.com", "username": "alice"}}, "posts": {}}`
+	if _, err := c.Import(ctx, strings.NewReader(dump), ImportOptions{}); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("Import() with a taken username = %v, expected ErrUsernameTaken", err)
+	}
+	if _, err := c.GetUser(ctx, "x@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUser() after the failed import = %v, expected ErrUserNotFound", err)
+	}
+}
+
+func TestGetPublicPosts(t *testing.T) {
+	c := newUsernameClient(t)
+	if _, err := c.SetUsername(ctx, "a@example.com", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	post, err := c.CreatePost(ctx, "a@example.com", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := c.GetPublicPosts(ctx, "a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := PublicPost{ID: post.ID, CreatedAt: post.CreatedAt, Author: "alice", Text: "hello"}
+	if len(public) != 1 || public[0] != expected {
+		t.Fatalf("GetPublicPosts() = %+v, expected [%+v]", public, expected)
+	}
+	data, err := json.Marshal(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "a@example.com") {
+		t.Errorf("GetPublicPosts() json leaks the email: %s", data)
+	}
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// UserUpdate -
+// the fields UpdateUserFields changes, nil ones are left as they are
+type UserUpdate struct {
+	// Password is the new plaintext password, checked against the policy and hashed
+	Password *string
+	// Name can't be blank
+	Name *string
+	// Age must be WithMinimumAge to MaxAge
+	Age *int
+}
+
+// empty reports whether the update wouldn't change any field
+func (u UserUpdate) empty() bool {
+	return u.Password == nil && u.Name == nil && u.Age == nil
+}
+
+// validate -
+// ErrInvalidUserField for a blank name and ErrInvalidAge for an age under minimumAge or over MaxAge,
+// the password is checked by the policy
+func (u UserUpdate) validate(minimumAge int) error {
+	if u.Name != nil && strings.TrimSpace(*u.Name) == "" {
+		return fmt.Errorf("%w: name can't be blank", ErrInvalidUserField)
+	}
+	if u.Age != nil {
+		return CheckAge(*u.Age, minimumAge)
+	}
+	return nil
+}
+
+// UpdateUserFields -
+// same as Client.UpdateUserFields, inside the Tx
+func (tx *Tx) UpdateUserFields(ctx context.Context, email string, fields UserUpdate) (User, error) {
+	if err := fields.validate(tx.minimumAge); err != nil {
+		return User{}, err
+	}
+	hash := ""
+	if fields.Password != nil {
+		var err error
+		if hash, err = newPasswordHash(*fields.Password, tx.passwordPolicy, tx.passwordCost); err != nil {
+			return User{}, err
+		}
+	}
+	user, _, err := tx.updateUserFields(email, fields, hash)
+	return user, err
+}
+
+// updateUserFields -
+// UpdateUserFields with the new password, if any, already hashed. also reports whether the user changed
+func (tx *Tx) updateUserFields(email string, fields UserUpdate, passwordHash string) (User, bool, error) {
+	db, err := tx.schema()
+	if err != nil {
+		return User{}, false, err
+	}
+	if email, err = NormalizeEmail(email); err != nil {
+		return User{}, false, err
+	}
+	old, ok := db.activeUser(email)
+	if !ok {
+		return User{}, false, fmt.Errorf("%w: %s", ErrUserNotFound, email)
+	}
+	user := old
+	if fields.Password != nil {
+		user.PasswordHash = passwordHash
+	}
+	if fields.Name != nil {
+		user.Name = *fields.Name
+	}
+	if fields.Age != nil {
+		user.Age = *fields.Age
+	}
+	if user == old {
+		return user, false, nil
+	}
+	user.UpdatedAt = tx.now()
+	db.putUser(user)
+	tx.recordNameChanges(old, user)
+	return user, true, nil
+}
+
+// UpdateUserFields -
+// change only the fields of the user with email that are set in fields, unlike UpdateUser
+// there's no need to send the rest again. the email is trimmed like UpdateUser does. ErrInvalidUserField for a blank name, ErrInvalidAge for an age out of range,
+// a *PasswordError for a password the policy refuses and ErrUserNotFound if there's no such user.
+// nothing is written when no field is set or none would change
+func (c *Client) UpdateUserFields(ctx context.Context, email string, fields UserUpdate) (User, error) {
+	if err := fields.validate(c.minimumAge); err != nil {
+		return User{}, err
+	}
+	if fields.empty() {
+		email, err := NormalizeEmail(email)
+		if err != nil {
+			return User{}, err
+		}
+		return c.GetUser(ctx, email)
+	}
+	hash := ""
+	if fields.Password != nil {
+		// bcrypt is slow on purpose, hash before taking the lock
+		var err error
+		if hash, err = newPasswordHash(*fields.Password, c.passwordPolicy, c.passwordCost); err != nil {
+			return User{}, err
+		}
+	}
+
+	user := User{}
+	err := c.update(ctx, "UpdateUserFields", email, func(db *Schema) error {
+		var changed bool
+		var err error
+		user, changed, err = c.newTx(db).updateUserFields(email, fields, hash)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return c.sanitize(user), nil
+}
@@ -0,0 +1,94 @@
+package database
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUpdateUserFields(t *testing.T) {
+	store := &fakeStore{}
+	c := NewClientWithStore(store, WithPasswordPolicy(PasswordPolicy{MinLength: 8}), WithCredentials())
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	created, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, age, password := "jane doe", 30, "battery staple"
+
+	user, err := c.UpdateUserFields(ctx, "test@example.com", UserUpdate{Name: &name})
+	expected := created
+	expected.Name = name
+	expected.UpdatedAt = user.UpdatedAt
+	if err != nil || user != expected {
+		t.Errorf("UpdateUserFields() of the name = %+v, %v, expected %+v", user, err, expected)
+	}
+
+	user, err = c.UpdateUserFields(ctx, "test@example.com", UserUpdate{Age: &age})
+	expected.Age = age
+	expected.UpdatedAt = user.UpdatedAt
+	if err != nil || user != expected {
+		t.Errorf("UpdateUserFields() of the age = %+v, %v, expected %+v", user, err, expected)
+	}
+
+	user, err = c.UpdateUserFields(ctx, " test@example.com ", UserUpdate{Password: &password})
+	if err != nil || !CheckPassword(user.PasswordHash, password) {
+		t.Errorf("UpdateUserFields() of the password = %+v, %v, expected a hash of %q", user, err, password)
+	}
+	expected.PasswordHash = user.PasswordHash
+	expected.UpdatedAt = user.UpdatedAt
+	if user != expected {
+		t.Errorf("UpdateUserFields() of the password = %+v, expected only the password changed", user)
+	}
+	if stored, _ := c.GetUser(ctx, "test@example.com"); stored != expected {
+		t.Errorf("GetUser() after UpdateUserFields() = %+v, expected %+v", stored, expected)
+	}
+
+	// nothing to change, nothing written
+	saves := store.saves
+	if user, err := c.UpdateUserFields(ctx, "test@example.com", UserUpdate{}); err != nil || user != expected {
+		t.Errorf("UpdateUserFields() with no fields = %+v, %v, expected %+v", user, err, expected)
+	}
+	if user, err := c.UpdateUserFields(ctx, "test@example.com", UserUpdate{Name: &name, Age: &age}); err != nil || user != expected {
+		t.Errorf("UpdateUserFields() with the same values = %+v, %v, expected %+v", user, err, expected)
+	}
+	if store.saves != saves {
+		t.Errorf("UpdateUserFields() without changes saved %d times, expected none", store.saves-saves)
+	}
+}
+
+func TestUpdateUserFieldsErrors(t *testing.T) {
+	c := NewClientWithStore(&fakeStore{}, WithPasswordPolicy(PasswordPolicy{MinLength: 8}))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	created, err := c.CreateUser(ctx, "test@example.com", "correct horse", "john doe", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blank, negative, short, name := "  ", -1, "short", "jane doe"
+
+	var tests = []struct {
+		email    string
+		fields   UserUpdate
+		expected error
+	}{
+		{email: "test@example.com", fields: UserUpdate{Name: &blank}, expected: ErrInvalidUserField},
+		{email: "test@example.com", fields: UserUpdate{Age: &negative}, expected: ErrInvalidAge},
+		{email: "test@example.com", fields: UserUpdate{Password: &short}, expected: ErrWeakPassword},
+		// one bad field and nothing changes
+		{email: "test@example.com", fields: UserUpdate{Name: &name, Age: &negative}, expected: ErrInvalidAge},
+		{email: "missing@example.com", fields: UserUpdate{Name: &name}, expected: ErrUserNotFound},
+		{email: "missing@example.com", fields: UserUpdate{}, expected: ErrUserNotFound},
+		{email: "not an email", fields: UserUpdate{Name: &name}, expected: ErrInvalidEmail},
+	}
+	for _, test := range tests {
+		if _, err := c.UpdateUserFields(ctx, test.email, test.fields); !errors.Is(err, test.expected) {
+			t.Errorf("UpdateUserFields(%q, %+v) = %v, expected %v", test.email, test.fields, err, test.expected)
+		}
+	}
+	if user, _ := c.GetUser(ctx, "test@example.com"); user != created {
+		t.Errorf("failed UpdateUserFields() calls changed the user to %+v", user)
+	}
+}
@@ -0,0 +1,49 @@
+package database
+
+import "context"
+
+// VacuumOptions -
+// controls Client.Vacuum
+type VacuumOptions struct {
+	// DryRun reports what would be purged without writing anything
+	DryRun bool
+}
+
+// VacuumReport -
+// how many dangling records of each kind Vacuum purged, or would have with DryRun
+type VacuumReport struct {
+	// OrphanedPosts are posts whose UserEmail matches no user,
+	// e.g. left behind by PostsKeep or by hand edits. anonymized posts are not orphans
+	OrphanedPosts int
+}
+
+// Vacuum -
+// remove every record that references something that no longer exists, in a single write
+// nothing is written if there's nothing to purge
+func (c *Client) Vacuum(ctx context.Context, opts VacuumOptions) (VacuumReport, error) {
+	report := VacuumReport{}
+	err := c.update(ctx, "Vacuum", "", func(db *Schema) error {
+		i := 0
+		for id, post := range db.Posts {
+			// full scan, bail out if the caller gave up
+			if i++; i%cancelCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			if !orphaned(*db, post) {
+				continue
+			}
+			report.OrphanedPosts++
+			db.deletePost(id)
+		}
+		if opts.DryRun || report == (VacuumReport{}) {
+			return errNoop
+		}
+		return nil
+	})
+	if err != nil {
+		return VacuumReport{}, err
+	}
+	return report, nil
+}
@@ -0,0 +1,101 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+// orphanedDB has two posts by missing users next to a live and an anonymized one
+func orphanedDB() Schema {
+	return Schema{
+		Users: map[string]User{"test@example.com": {Email: "test@example.com"}},
+		Posts: map[string]Post{
+			"live":      {ID: "live", UserEmail: "test@example.com"},
+			"anonymous": {ID: "anonymous", UserEmail: DeletedUserEmail},
+			"orphan-1":  {ID: "orphan-1", UserEmail: "gone@example.com"},
+			"orphan-2":  {ID: "orphan-2", UserEmail: "other@example.com"},
+		},
+	}
+}
+
+func TestVacuum(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          VacuumOptions
+		expectedPosts []string
+	}{
+		{name: "purge", expectedPosts: []string{"live", "anonymous"}},
+		{name: "dry run", opts: VacuumOptions{DryRun: true}, expectedPosts: []string{"live", "anonymous", "orphan-1", "orphan-2"}},
+	}
+
+	for _, test := range tests {
+		c := newTestClient(t)
+		if err := c.Load(ctx, orphanedDB()); err != nil {
+			t.Fatal(err)
+		}
+		before, err := os.ReadFile(dbPath(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := c.Vacuum(ctx, test.opts)
+		if err != nil {
+			t.Fatalf("%s: Vacuum() = %v", test.name, err)
+		}
+		if expected := (VacuumReport{OrphanedPosts: 2}); report != expected {
+			t.Errorf("%s: Vacuum() = %+v, expected %+v", test.name, report, expected)
+		}
+
+		// read back from disk so the write (or its absence) is what's checked
+		db, err := LoadFile(ctx, dbPath(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(db.Posts) != len(test.expectedPosts) {
+			t.Errorf("%s: %d posts left, expected %v", test.name, len(db.Posts), test.expectedPosts)
+		}
+		for _, id := range test.expectedPosts {
+			if _, ok := db.Posts[id]; !ok {
+				t.Errorf("%s: post %s was purged", test.name, id)
+			}
+		}
+		if test.opts.DryRun {
+			after, err := os.ReadFile(dbPath(c))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(before) != string(after) {
+				t.Errorf("%s: dry run changed the file", test.name)
+			}
+		}
+	}
+}
+
+func TestVacuumClean(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreatePost(ctx, "test@example.com", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	report, err := c.Vacuum(ctx, VacuumOptions{})
+	if err != nil || report != (VacuumReport{}) {
+		t.Errorf("Vacuum() on a clean db = %+v, %v, expected an empty report", report, err)
+	}
+	if posts, _ := c.GetPosts(ctx, "test@example.com"); len(posts) != 1 {
+		t.Errorf("Vacuum() on a clean db left %d posts, expected 1", len(posts))
+	}
+}
+
+func TestVacuumReadOnly(t *testing.T) {
+	c := newTestClient(t)
+	if err := c.Load(ctx, orphanedDB()); err != nil {
+		t.Fatal(err)
+	}
+	ro := NewClient(dbPath(c), WithReadOnly())
+	if _, err := ro.Vacuum(ctx, VacuumOptions{}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Vacuum() on a read-only client = %v, expected ErrReadOnly", err)
+	}
+}
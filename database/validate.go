@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// IssueKind -
+// the class of problem a ValidationIssue reports
+type IssueKind string
+
+const (
+	// IssueOrphanedPost is a post whose UserEmail matches no user, see Vacuum
+	IssueOrphanedPost IssueKind = "orphaned post"
+	// IssueKeyMismatch is a record stored under a key other than its own Email or ID
+	IssueKeyMismatch IssueKind = "key mismatch"
+	// IssueZeroCreatedAt is a record without a CreatedAt
+	IssueZeroCreatedAt IssueKind = "zero created at"
+	// IssueDuplicatePostID is a post whose ID is also the ID of a post stored under another key
+	IssueDuplicatePostID IssueKind = "duplicate post id"
+	// IssueEmptyField is a record missing its Email, ID or UserEmail
+	IssueEmptyField IssueKind = "empty field"
+	// IssueDuplicateUsername is a user whose Username another user already has
+	IssueDuplicateUsername IssueKind = "duplicate username"
+	// IssueInvalidUsername is a user whose Username breaks the policy of SetUsername
+	IssueInvalidUsername IssueKind = "invalid username"
+)
+
+// ValidationIssue -
+// one problem found by Validate
+type ValidationIssue struct {
+	Kind IssueKind
+	// Key is the map key of the offending record, an email for users and an id for posts
+	Key     string
+	Message string
+}
+
+// Validate -
+// check the db is internally consistent without changing anything
+// returns every problem found, users first then posts, each in key order. empty if there are none
+func (c *Client) Validate(ctx context.Context) ([]ValidationIssue, error) {
+	issues := []ValidationIssue{}
+	err := c.view(ctx, "Validate", "", func(db *Schema) error {
+		issues = validateSchema(*db)
+		return ctx.Err()
+	})
+	if err != nil {
+		return []ValidationIssue{}, err
+	}
+	return issues, nil
+}
+
+// validateSchema -
+// every ValidationIssue in db, see Validate
+func validateSchema(db Schema) []ValidationIssue {
+	issues := []ValidationIssue{}
+	report := func(kind IssueKind, key, format string, args ...interface{}) {
+		issues = append(issues, ValidationIssue{Kind: kind, Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, key := range sortedKeys(db.Users) {
+		user := db.Users[key]
+		switch {
+		case user.Email == "":
+			report(IssueEmptyField, key, "user %q has no email", key)
+		case user.Email != key:
+			report(IssueKeyMismatch, key, "user %q is stored under %q", user.Email, key)
+		}
+		if user.CreatedAt.IsZero() {
+			report(IssueZeroCreatedAt, key, "user %q has no creation time", key)
+		}
+		if user.Username == "" {
+			continue
+		}
+		if err := validateUsername(user.Username); err != nil {
+			report(IssueInvalidUsername, key, "user %q has username %q that isn't allowed", key, user.Username)
+		}
+		// the index goes to the first email in order, see indexUsernames
+		if owner := db.Usernames[user.Username]; owner != key {
+			report(IssueDuplicateUsername, key, "user %q has username %q which belongs to %q", key, user.Username, owner)
+		}
+	}
+
+	// keys of the posts carrying each ID, more than one is a duplicate
+	byID := map[string][]string{}
+	for key, post := range db.Posts {
+		if post.ID != "" {
+			byID[post.ID] = append(byID[post.ID], key)
+		}
+	}
+	for _, key := range sortedKeys(db.Posts) {
+		post := db.Posts[key]
+		switch {
+		case post.ID == "":
+			report(IssueEmptyField, key, "post %q has no id", key)
+		case post.ID != key:
+			report(IssueKeyMismatch, key, "post %q is stored under %q", post.ID, key)
+		}
+		if keys := byID[post.ID]; len(keys) > 1 {
+			report(IssueDuplicatePostID, key, "post id %q is used by %d posts", post.ID, len(keys))
+		}
+		if post.UserEmail == "" {
+			report(IssueEmptyField, key, "post %q has no author", key)
+		} else if orphaned(db, post) {
+			report(IssueOrphanedPost, key, "post %q is by %q who doesn't exist", key, post.UserEmail)
+		}
+		if post.CreatedAt.IsZero() {
+			report(IssueZeroCreatedAt, key, "post %q has no creation time", key)
+		}
+	}
+	return issues
+}
+
+// orphaned reports whether post's author is missing from db, anonymized posts have no author to miss
+func orphaned(db Schema, post Post) bool {
+	if post.UserEmail == DeletedUserEmail {
+		return false
+	}
+	_, ok := db.Users[post.UserEmail]
+	return !ok
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	at := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	user := User{Email: "test@example.com", CreatedAt: at}
+	post := Post{ID: "post-1", UserEmail: "test@example.com", CreatedAt: at}
+	users := map[string]User{"test@example.com": user}
+
+	tests := []struct {
+		name     string
+		db       Schema
+		expected []ValidationIssue
+	}{
+		{
+			name:     "clean",
+			db:       Schema{Users: users, Posts: map[string]Post{"post-1": post, "post-2": {ID: "post-2", UserEmail: DeletedUserEmail, CreatedAt: at}}},
+			expected: []ValidationIssue{},
+		},
+		{
+			name: "orphaned post",
+			db:   Schema{Users: users, Posts: map[string]Post{"post-1": {ID: "post-1", UserEmail: "gone@example.com", CreatedAt: at}}},
+			expected: []ValidationIssue{
+				{Kind: IssueOrphanedPost, Key: "post-1", Message: `post "post-1" is by "gone@example.com" who doesn't exist`},
+			},
+		},
+		{
+			name: "key mismatch",
+			db: Schema{
+				Users: map[string]User{"old@example.com": user},
+				Posts: map[string]Post{"post-9": {ID: "post-1", UserEmail: "old@example.com", CreatedAt: at}},
+			},
+			expected: []ValidationIssue{
+				{Kind: IssueKeyMismatch, Key: "old@example.com", Message: `user "test@example.com" is stored under "old@example.com"`},
+				{Kind: IssueKeyMismatch, Key: "post-9", Message: `post "post-1" is stored under "post-9"`},
+			},
+		},
+		{
+			name: "zero created at",
+			db: Schema{
+				Users: map[string]User{"test@example.com": {Email: "test@example.com"}},
+				Posts: map[string]Post{"post-1": {ID: "post-1", UserEmail: "test@example.com"}},
+			},
+			expected: []ValidationIssue{
+				{Kind: IssueZeroCreatedAt, Key: "test@example.com", Message: `user "test@example.com" has no creation time`},
+				{Kind: IssueZeroCreatedAt, Key: "post-1", Message: `post "post-1" has no creation time`},
+			},
+		},
+		{
+			name: "duplicate post id",
+			db:   Schema{Users: users, Posts: map[string]Post{"post-1": post, "copy": post}},
+			expected: []ValidationIssue{
+				{Kind: IssueKeyMismatch, Key: "copy", Message: `post "post-1" is stored under "copy"`},
+				{Kind: IssueDuplicatePostID, Key: "copy", Message: `post id "post-1" is used by 2 posts`},
+				{Kind: IssueDuplicatePostID, Key: "post-1", Message: `post id "post-1" is used by 2 posts`},
+			},
+		},
+		{
+			name: "usernames",
+			db: Schema{
+				Users: map[string]User{
+					"a@example.com": {Email: "a@example.com", Username: "alice", CreatedAt: at},
+					"b@example.com": {Email: "b@example.com", Username: "alice", CreatedAt: at},
+					"c@example.com": {Email: "c@example.com", Username: "Carol", CreatedAt: at},
+				},
+				Posts: map[string]Post{},
+			},
+			expected: []ValidationIssue{
+				{Kind: IssueDuplicateUsername, Key: "b@example.com", Message: `user "b@example.com" has username "alice" which belongs to "a@example.com"`},
+				{Kind: IssueInvalidUsername, Key: "c@example.com", Message: `user "c@example.com" has username "Carol" that isn't allowed`},
+			},
+		},
+		{
+			name: "empty fields",
+			db: Schema{
+				Users: map[string]User{"test@example.com": {CreatedAt: at}},
+				Posts: map[string]Post{"post-1": {CreatedAt: at}},
+			},
+			expected: []ValidationIssue{
+				{Kind: IssueEmptyField, Key: "test@example.com", Message: `user "test@example.com" has no email`},
+				{Kind: IssueEmptyField, Key: "post-1", Message: `post "post-1" has no id`},
+				{Kind: IssueEmptyField, Key: "post-1", Message: `post "post-1" has no author`},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		c := NewMemoryClient()
+		if err := c.Load(ctx, test.db); err != nil {
+			t.Fatal(err)
+		}
+		before, err := c.Dump(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		issues, err := c.Validate(ctx)
+		if err != nil {
+			t.Fatalf("%s: Validate() = %v", test.name, err)
+		}
+		if !reflect.DeepEqual(issues, test.expected) {
+			t.Errorf("%s: Validate() = %+v, expected %+v", test.name, issues, test.expected)
+		}
+		// read-only, nothing gets fixed
+		after, err := c.Dump(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(before, after) {
+			t.Errorf("%s: Validate() changed the db", test.name)
+		}
+	}
+}
+
+func TestValidateReadOnlyClient(t *testing.T) {
+	c := newTestClient(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "123456", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	issues, err := NewClient(dbPath(c), WithReadOnly()).Validate(ctx)
+	if err != nil || len(issues) != 0 {
+		t.Errorf("Validate() on a read-only client = %+v, %v, expected no issues", issues, err)
+	}
+}
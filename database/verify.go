@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultVerificationTokenTTL -
+// how long an email verification token can be redeemed unless WithVerificationTokenTTL says otherwise
+const DefaultVerificationTokenTTL = 24 * time.Hour
+
+// VerificationToken -
+// an email verification as stored, keyed by the sha256 of the token the user was sent like ResetToken.
+// it's for the address the user had when it was made, ChangeEmail drops it
+type VerificationToken struct {
+	UserEmail string    `json:"userEmail"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// UsedAt is zero until the token is redeemed, used tokens are kept until they expire
+	UsedAt time.Time `json:"usedAt"`
+}
+
+// putVerificationToken -
+// store verification under key, making the map on first use
+func (db *Schema) putVerificationToken(key string, verification VerificationToken) {
+	if db.VerificationTokens == nil {
+		db.VerificationTokens = make(map[string]VerificationToken)
+	}
+	db.VerificationTokens[key] = verification
+}
+
+// dropVerificationTokens removes every verification token of the user with email
+func (db *Schema) dropVerificationTokens(email string) {
+	for key, verification := range db.VerificationTokens {
+		if verification.UserEmail == email {
+			delete(db.VerificationTokens, key)
+		}
+	}
+}
+
+// CreateEmailVerificationToken -
+// a new token that proves the user with email can read mail sent there, for a "verify your email" link.
+// it expires after WithVerificationTokenTTL and works once, only its hash is stored.
+// ErrUserNotFound if there's no such user
+func (c *Client) CreateEmailVerificationToken(ctx context.Context, email string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	err = c.update(ctx, "CreateEmailVerificationToken", email, func(db *Schema) error {
+		email, err := NormalizeEmail(email)
+		if err != nil {
+			return err
+		}
+		if _, ok := db.activeUser(email); !ok {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, email)
+		}
+		now := c.newTx(db).now()
+		db.putVerificationToken(hashToken(token), VerificationToken{
+			UserEmail: email,
+			CreatedAt: now,
+			ExpiresAt: now.Add(c.verifyTokenTTL),
+		})
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// VerifyEmail -
+// mark the token's user Verified, returns the user. ErrVerificationTokenInvalid for a token that was
+// never issued (or was purged, or the email changed since), ErrVerificationTokenExpired and
+// ErrVerificationTokenUsed for what they say. the user's other outstanding tokens stop working
+func (c *Client) VerifyEmail(ctx context.Context, token string) (User, error) {
+	key := hashToken(token)
+	user := User{}
+	err := c.update(ctx, "VerifyEmail", "", func(db *Schema) error {
+		now := c.newTx(db).now()
+		verification, ok := db.VerificationTokens[key]
+		switch {
+		case !ok:
+			return ErrVerificationTokenInvalid
+		case !verification.UsedAt.IsZero():
+			return ErrVerificationTokenUsed
+		case !now.Before(verification.ExpiresAt):
+			return ErrVerificationTokenExpired
+		}
+		var found bool
+		if user, found = db.activeUser(verification.UserEmail); !found {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, verification.UserEmail)
+		}
+		if !user.Verified {
+			user.Verified = true
+			user.UpdatedAt = now
+			db.putUser(user)
+		}
+
+		db.dropVerificationTokens(user.Email)
+		verification.UsedAt = now
+		db.putVerificationToken(key, verification)
+		return nil
+	})
+	if err != nil {
+		return User{}, err
+	}
+	return c.sanitize(user), nil
+}
+
+// PurgeExpiredVerificationTokens -
+// remove every verification token past its expiry, used or not, in a single write
+// returns how many were removed, nothing is written if there are none
+func (c *Client) PurgeExpiredVerificationTokens(ctx context.Context) (int, error) {
+	purged := 0
+	err := c.deleteUpdate(ctx, "PurgeExpiredVerificationTokens", "", func(db *Schema) error {
+		now := c.newTx(db).now()
+		for key, verification := range db.VerificationTokens {
+			if !now.Before(verification.ExpiresAt) {
+				delete(db.VerificationTokens, key)
+				purged++
+			}
+		}
+		if purged == 0 {
+			return errNoop
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
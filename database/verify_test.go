@@ -0,0 +1,196 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyEmail(t *testing.T) {
+	c, clock := newResetClient(t)
+	token, err := c.CreateEmailVerificationToken(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), token) || !strings.Contains(string(data), hashToken(token)) {
+		t.Errorf("db file should have the hash of the token and not the token: %s", data)
+	}
+	if user, _ := c.GetUser(ctx, "test@example.com"); user.Verified {
+		t.Errorf("Verified before VerifyEmail() = true, expected false")
+	}
+
+	clock.Advance(time.Minute)
+	user, err := c.VerifyEmail(ctx, token)
+	if err != nil || !user.Verified || user.UpdatedAt != clock.Now() {
+		t.Fatalf("VerifyEmail() = %+v, %v, expected a verified user updated at %v", user, err, clock.Now())
+	}
+	if stored, _ := c.GetUser(ctx, "test@example.com"); stored != user {
+		t.Errorf("GetUser() after VerifyEmail() = %+v, expected %+v", stored, user)
+	}
+	if _, err := c.VerifyEmail(ctx, token); !errors.Is(err, ErrVerificationTokenUsed) {
+		t.Errorf("second VerifyEmail() = %v, expected ErrVerificationTokenUsed", err)
+	}
+
+	var tests = []struct {
+		email    string
+		expected error
+	}{
+		{email: "missing@example.com", expected: ErrUserNotFound},
+		{email: "not an email", expected: ErrInvalidEmail},
+	}
+	for _, test := range tests {
+		if _, err := c.CreateEmailVerificationToken(ctx, test.email); !errors.Is(err, test.expected) {
+			t.Errorf("CreateEmailVerificationToken(%q) = %v, expected %v", test.email, err, test.expected)
+		}
+	}
+	if _, err := c.VerifyEmail(ctx, "made up"); !errors.Is(err, ErrVerificationTokenInvalid) {
+		t.Errorf("VerifyEmail() of an unknown token = %v, expected ErrVerificationTokenInvalid", err)
+	}
+	// a password reset token isn't a verification token
+	reset, err := c.CreatePasswordResetToken(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.VerifyEmail(ctx, reset); !errors.Is(err, ErrVerificationTokenInvalid) {
+		t.Errorf("VerifyEmail() of a reset token = %v, expected ErrVerificationTokenInvalid", err)
+	}
+}
+
+func TestVerifyEmailExpiry(t *testing.T) {
+	c, clock := newResetClient(t, WithVerificationTokenTTL(time.Hour))
+	token, err := c.CreateEmailVerificationToken(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	if _, err := c.VerifyEmail(ctx, token); !errors.Is(err, ErrVerificationTokenExpired) {
+		t.Errorf("VerifyEmail() at the expiry = %v, expected ErrVerificationTokenExpired", err)
+	}
+	if user, _ := c.GetUser(ctx, "test@example.com"); user.Verified {
+		t.Errorf("Verified after an expired token = true, expected false")
+	}
+
+	fresh, err := c.CreateEmailVerificationToken(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour - time.Second)
+	if purged, err := c.PurgeExpiredVerificationTokens(ctx); err != nil || purged != 1 {
+		t.Errorf("PurgeExpiredVerificationTokens() = %d, %v, expected 1", purged, err)
+	}
+	if _, err := c.VerifyEmail(ctx, token); !errors.Is(err, ErrVerificationTokenInvalid) {
+		t.Errorf("VerifyEmail() of a purged token = %v, expected ErrVerificationTokenInvalid", err)
+	}
+	if _, err := c.VerifyEmail(ctx, fresh); err != nil {
+		t.Errorf("VerifyEmail() before the expiry = %v, expected nil", err)
+	}
+}
+
+func TestVerifyEmailChangeEmail(t *testing.T) {
+	c, _ := newResetClient(t)
+	token, err := c.CreateEmailVerificationToken(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := c.CreateEmailVerificationToken(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.VerifyEmail(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+	// the user's other tokens stop working once one is used
+	if _, err := c.VerifyEmail(ctx, pending); !errors.Is(err, ErrVerificationTokenInvalid) {
+		t.Errorf("VerifyEmail() of a superseded token = %v, expected ErrVerificationTokenInvalid", err)
+	}
+
+	stale, err := c.CreateEmailVerificationToken(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved, err := c.ChangeEmail(ctx, "test@example.com", "new@example.com")
+	if err != nil || moved.Verified {
+		t.Fatalf("ChangeEmail() = %+v, %v, expected the user unverified", moved, err)
+	}
+	// a token for the old address doesn't verify the new one
+	if _, err := c.VerifyEmail(ctx, stale); !errors.Is(err, ErrVerificationTokenInvalid) {
+		t.Errorf("VerifyEmail() of a token for the old email = %v, expected ErrVerificationTokenInvalid", err)
+	}
+	token, err = c.CreateEmailVerificationToken(ctx, "new@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, err := c.VerifyEmail(ctx, token); err != nil || !user.Verified || user.Email != "new@example.com" {
+		t.Errorf("VerifyEmail() of the new email = %+v, %v, expected new@example.com verified", user, err)
+	}
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	var tests = []struct {
+		name     string
+		opts     []Option
+		expected error
+	}{
+		{name: "disabled", opts: nil, expected: nil},
+		{name: "enabled", opts: []Option{WithRequireVerifiedEmail()}, expected: ErrEmailNotVerified},
+	}
+	for _, test := range tests {
+		c, _ := newResetClient(t, test.opts...)
+		if _, err := c.CreatePost(ctx, "test@example.com", "hello"); !errors.Is(err, test.expected) {
+			t.Errorf("%s: CreatePost() by an unverified user = %v, expected %v", test.name, err, test.expected)
+		}
+		token, err := c.CreateEmailVerificationToken(ctx, "test@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.VerifyEmail(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.CreatePost(ctx, "test@example.com", "hello"); err != nil {
+			t.Errorf("%s: CreatePost() by a verified user = %v, expected nil", test.name, err)
+		}
+	}
+}
+
+func TestVerifyEmailPersists(t *testing.T) {
+	for name, opts := range map[string][]Option{"file": nil, "wal": {WithWAL(100)}} {
+		c, clock := newResetClient(t, opts...)
+		used, err := c.CreateEmailVerificationToken(ctx, "test@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.VerifyEmail(ctx, used); err != nil {
+			t.Fatal(err)
+		}
+		reopened := NewClient(dbPath(c), append([]Option{WithClock(clock)}, opts...)...)
+		if user, err := reopened.GetUser(ctx, "test@example.com"); err != nil || !user.Verified {
+			t.Errorf("%s: GetUser() after reopening = %+v, %v, expected a verified user", name, user, err)
+		}
+		if _, err := reopened.VerifyEmail(ctx, used); !errors.Is(err, ErrVerificationTokenUsed) {
+			t.Errorf("%s: VerifyEmail() of a used token after reopening = %v, expected ErrVerificationTokenUsed", name, err)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDeleteUserDropsVerificationTokens(t *testing.T) {
+	c, _ := newResetClient(t)
+	token, err := c.CreateEmailVerificationToken(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DeleteUser(ctx, "test@example.com", DeleteUserOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.VerifyEmail(ctx, token); !errors.Is(err, ErrVerificationTokenInvalid) {
+		t.Errorf("VerifyEmail() of a deleted user's token = %v, expected ErrVerificationTokenInvalid", err)
+	}
+}
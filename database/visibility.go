@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// Visibility -
+// who can read a post, on top of the blocks and private accounts canSeePosts checks
+type Visibility string
+
+// the visibility levels of a post
+const (
+	// VisibilityPublic posts can be read by anyone, someone logged out included
+	VisibilityPublic Visibility = "public"
+	// VisibilityFollowers posts can be read by the author and the users following it
+	VisibilityFollowers Visibility = "followers"
+	// VisibilityPrivate posts can only be read by the author
+	VisibilityPrivate Visibility = "private"
+)
+
+// storedVisibility -
+// what Post.Visibility holds for v, empty for VisibilityPublic and empty alike.
+// ErrInvalidVisibility unless v is one of the levels
+func storedVisibility(v Visibility) (Visibility, error) {
+	switch v {
+	case "", VisibilityPublic:
+		return "", nil
+	case VisibilityFollowers, VisibilityPrivate:
+		return v, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidVisibility, v)
+}
+
+// canSeePost -
+// whether viewer, "" for someone logged out, may read post: the author always can, anyone else
+// only if canSeePosts lets them read the author's posts at all and the post's Visibility lets them
+// read this one
+func (db *Schema) canSeePost(viewer string, post Post) bool {
+	if viewer != "" && viewer == post.UserEmail {
+		return true
+	}
+	if !db.canSeePosts(viewer, post.UserEmail) {
+		return false
+	}
+	switch post.Visibility {
+	case VisibilityPrivate:
+		return false
+	case VisibilityFollowers:
+		return viewer != "" && db.Follows.has(viewer, post.UserEmail)
+	}
+	return true
+}
+
+// SetPostVisibility -
+// same as Client.SetPostVisibility, inside the Tx
+func (tx *Tx) SetPostVisibility(ctx context.Context, id, requesterEmail string, visibility Visibility) (Post, error) {
+	post, _, err := tx.setPostVisibility(id, requesterEmail, visibility)
+	return post, err
+}
+
+// setPostVisibility -
+// SetPostVisibility that also reports whether the post changed
+func (tx *Tx) setPostVisibility(id, requesterEmail string, visibility Visibility) (Post, bool, error) {
+	if id == "" {
+		return Post{}, false, ErrEmptyPostID
+	}
+	visibility, err := storedVisibility(visibility)
+	if err != nil {
+		return Post{}, false, err
+	}
+	db, err := tx.schema()
+	if err != nil {
+		return Post{}, false, err
+	}
+	post, ok := db.livePost(id)
+	if !ok {
+		return Post{}, false, fmt.Errorf("%w: %s", ErrPostNotFound, id)
+	}
+	if err := tx.checkCanChangePost(db, post, requesterEmail); err != nil {
+		return Post{}, false, err
+	}
+	if post.Visibility == visibility {
+		return post, false, nil
+	}
+	post.Visibility = visibility
+	db.putPost(post)
+	return post, true, nil
+}
+
+// SetPostVisibility -
+// change who can read the post with id on behalf of requesterEmail, its author or an admin like
+// UpdatePost. it isn't an edit, EditedAt and the edit history stay as they are, and the same
+// visibility again writes nothing. ErrInvalidVisibility for a level that doesn't exist, otherwise
+// the errors of UpdatePost
+func (c *Client) SetPostVisibility(ctx context.Context, id, requesterEmail string, visibility Visibility) (Post, error) {
+	post := Post{}
+	err := c.update(ctx, "SetPostVisibility", id, func(db *Schema) error {
+		var changed bool
+		var err error
+		post, changed, err = c.newTx(db).setPostVisibility(id, requesterEmail, visibility)
+		if err == nil && !changed {
+			return errNoop
+		}
+		return err
+	})
+	if err != nil {
+		return Post{}, err
+	}
+	return post, nil
+}
+
+// GetPostAs -
+// GetPost as seen by viewer, "" for someone logged out. a post viewer may not read is
+// ErrPostNotFound like a missing one, so its existence isn't given away
+func (c *Client) GetPostAs(ctx context.Context, viewer, id string) (Post, error) {
+	post := Post{}
+	err := c.view(ctx, "GetPostAs", id, func(db *Schema) error {
+		var err error
+		if post, err = c.newTx(db).GetPost(ctx, id); err != nil {
+			return err
+		}
+		viewer := EmailKey(viewer)
+		// drafts and scheduled posts are the author's until they're out
+		if !db.canSeePost(viewer, post) || (viewer != post.UserEmail && post.unpublished(c.clock.Now())) {
+			return fmt.Errorf("%w: %s", ErrPostNotFound, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return Post{}, err
+	}
+	return post, nil
+}
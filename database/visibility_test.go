@@ -0,0 +1,155 @@
+package database
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// newVisibilityClient has author@ with a public, a followers-only and a private post, in that
+// order, follower@ following it and stranger@ not
+func newVisibilityClient(t *testing.T) (*Client, map[Visibility]string) {
+	t.Helper()
+	c := newTestClient(t, WithIDGenerator(&SequenceIDGenerator{}))
+	for _, email := range []string{"author@example.com", "follower@example.com", "stranger@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "123456", "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.FollowUser(ctx, "follower@example.com", "author@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	ids := map[Visibility]string{}
+	for _, visibility := range []Visibility{VisibilityPublic, VisibilityFollowers, VisibilityPrivate} {
+		post, err := c.CreatePostWithOptions(ctx, "author@example.com", string(visibility), CreatePostOptions{Visibility: visibility})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[visibility] = post.ID
+	}
+	return c, ids
+}
+
+func TestPostVisibility(t *testing.T) {
+	c, ids := newVisibilityClient(t)
+	all := []Visibility{VisibilityPublic, VisibilityFollowers, VisibilityPrivate}
+
+	var tests = []struct {
+		viewer   string
+		expected []Visibility
+	}{
+		{viewer: "author@example.com", expected: all},
+		{viewer: " Follower@example.com", expected: []Visibility{VisibilityPublic, VisibilityFollowers}},
+		{viewer: "stranger@example.com", expected: []Visibility{VisibilityPublic}},
+		{viewer: "", expected: []Visibility{VisibilityPublic}},
+	}
+	for _, test := range tests {
+		expected := []string{}
+		for _, visibility := range test.expected {
+			expected = append(expected, ids[visibility])
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(expected)))
+
+		for _, visibility := range all {
+			visible := false
+			for _, v := range test.expected {
+				visible = visible || v == visibility
+			}
+			post, err := c.GetPostAs(ctx, test.viewer, ids[visibility])
+			switch {
+			case visible && (err != nil || post.ID != ids[visibility]):
+				t.Errorf("GetPostAs(%q) of the %s post = %+v, %v, expected it", test.viewer, visibility, post, err)
+			case !visible && !errors.Is(err, ErrPostNotFound):
+				t.Errorf("GetPostAs(%q) of the %s post = %+v, %v, expected ErrPostNotFound", test.viewer, visibility, post, err)
+			}
+		}
+		if posts, err := c.GetPostsAs(ctx, test.viewer, "author@example.com"); err != nil || !reflect.DeepEqual(postIDs(posts), expected) {
+			t.Errorf("GetPostsAs(%q) = %v, %v, expected %v", test.viewer, postIDs(posts), err, expected)
+		}
+		// GetPosts is the listing for someone logged out
+		if posts, err := c.GetPosts(ctx, "author@example.com"); test.viewer == "" && (err != nil || !reflect.DeepEqual(postIDs(posts), expected)) {
+			t.Errorf("GetPosts() = %v, %v, expected %v", postIDs(posts), err, expected)
+		}
+		// an empty Viewer is someone logged out, Anonymous or not
+		for _, opts := range []ListOptions{{Viewer: test.viewer}, {Viewer: test.viewer, Anonymous: test.viewer == ""}} {
+			if posts, err := c.GetAllPosts(ctx, opts); err != nil || !reflect.DeepEqual(postIDs(posts), expected) {
+				t.Errorf("GetAllPosts(%+v) = %v, %v, expected %v", opts, postIDs(posts), err, expected)
+			}
+			if page, err := c.GetPostsPage(ctx, "author@example.com", opts); err != nil || !reflect.DeepEqual(postIDs(page.Posts), expected) || page.Total != len(expected) {
+				t.Errorf("GetPostsPage(%+v) = %v of %d, %v, expected %v", opts, postIDs(page.Posts), page.Total, err, expected)
+			}
+		}
+		got := []string{}
+		iterate := IterateOptions{Viewer: test.viewer, Anonymous: test.viewer == "", UserEmail: "author@example.com"}
+		if err := c.IteratePosts(ctx, iterate, func(post Post) bool {
+			got = append(got, post.ID)
+			return true
+		}); err != nil {
+			t.Fatal(err)
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(got)))
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("IteratePosts(%+v) = %v, expected %v", iterate, got, expected)
+		}
+	}
+
+	// only IgnoreVisibility skips the checks
+	for _, opts := range []ListOptions{{IgnoreVisibility: true}, {IgnoreVisibility: true, Viewer: "stranger@example.com"}} {
+		if posts, err := c.GetAllPosts(ctx, opts); err != nil || len(posts) != 3 {
+			t.Errorf("GetAllPosts(%+v) = %v, %v, expected all 3 posts", opts, postIDs(posts), err)
+		}
+		if page, err := c.GetPostsPage(ctx, "author@example.com", opts); err != nil || len(page.Posts) != 3 {
+			t.Errorf("GetPostsPage(%+v) = %v, %v, expected all 3 posts", opts, postIDs(page.Posts), err)
+		}
+	}
+	if posts, err := c.SearchPosts(ctx, "private", SearchOptions{}); err != nil || len(posts) != 0 {
+		t.Errorf("SearchPosts() of the private post without a viewer = %v, %v, expected none", postIDs(posts), err)
+	}
+	if posts, err := c.SearchPosts(ctx, "private", SearchOptions{IgnoreVisibility: true}); err != nil || len(posts) != 1 {
+		t.Errorf("SearchPosts() of the private post with IgnoreVisibility = %v, %v, expected it", postIDs(posts), err)
+	}
+	if public, err := c.GetPublicPosts(ctx, "author@example.com"); err != nil || len(public) != 1 || public[0].ID != ids[VisibilityPublic] {
+		t.Errorf("GetPublicPosts() = %+v, %v, expected only the public post", public, err)
+	}
+	// public posts are stored as they were before there was a choice
+	if post, _ := c.GetPost(ctx, ids[VisibilityPublic]); post.Visibility != "" {
+		t.Errorf("public post stored with Visibility %q, expected it empty", post.Visibility)
+	}
+	if _, err := c.CreatePostWithOptions(ctx, "author@example.com", "hi", CreatePostOptions{Visibility: "friends"}); !errors.Is(err, ErrInvalidVisibility) {
+		t.Errorf("CreatePostWithOptions() with an unknown visibility = %v, expected ErrInvalidVisibility", err)
+	}
+}
+
+func TestSetPostVisibility(t *testing.T) {
+	c, ids := newVisibilityClient(t)
+	id := ids[VisibilityPublic]
+	var tests = []struct {
+		requester  string
+		visibility Visibility
+		expected   error
+		stored     Visibility
+	}{
+		{requester: "author@example.com", visibility: VisibilityPrivate, expected: nil, stored: VisibilityPrivate},
+		{requester: "stranger@example.com", visibility: VisibilityPublic, expected: ErrNotPostAuthor, stored: VisibilityPrivate},
+		{requester: "author@example.com", visibility: "everyone", expected: ErrInvalidVisibility, stored: VisibilityPrivate},
+		{requester: "author@example.com", visibility: VisibilityFollowers, expected: nil, stored: VisibilityFollowers},
+		{requester: "author@example.com", visibility: VisibilityPublic, expected: nil, stored: ""},
+	}
+	for _, test := range tests {
+		if _, err := c.SetPostVisibility(ctx, id, test.requester, test.visibility); !errors.Is(err, test.expected) {
+			t.Errorf("SetPostVisibility(%q, %q) = %v, expected %v", test.requester, test.visibility, err, test.expected)
+		}
+		post, err := NewClient(dbPath(c)).GetPost(ctx, id)
+		if err != nil || post.Visibility != test.stored {
+			t.Errorf("after SetPostVisibility(%q, %q) = %q, %v, expected %q", test.requester, test.visibility, post.Visibility, err, test.stored)
+		}
+		// it isn't an edit
+		if post.EditedAt != nil {
+			t.Errorf("SetPostVisibility(%q, %q) set EditedAt", test.requester, test.visibility)
+		}
+	}
+	if _, err := c.GetPostAs(ctx, "stranger@example.com", id); err != nil {
+		t.Errorf("GetPostAs() of the post made public again = %v", err)
+	}
+}
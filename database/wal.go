@@ -0,0 +1,412 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// suffix of the write-ahead log kept next to the db file
+const walSuffix = ".wal"
+
+// operations recorded in the write-ahead log
+// every entry carries the full record so replaying one twice gives the same result
+const (
+	walPutUser    = "putUser"
+	walDeleteUser = "deleteUser"
+	walPutPost    = "putPost"
+	walDeletePost = "deletePost"
+
+	walPutResetToken    = "putResetToken"
+	walDeleteResetToken = "deleteResetToken"
+
+	walPutVerificationToken    = "putVerificationToken"
+	walDeleteVerificationToken = "deleteVerificationToken"
+
+	walPutBlock    = "putBlock"
+	walDeleteBlock = "deleteBlock"
+
+	walPutMute    = "putMute"
+	walDeleteMute = "deleteMute"
+
+	walPutFollow    = "putFollow"
+	walDeleteFollow = "deleteFollow"
+
+	walPutFollowRequest    = "putFollowRequest"
+	walDeleteFollowRequest = "deleteFollowRequest"
+
+	walPutFriendRequest    = "putFriendRequest"
+	walDeleteFriendRequest = "deleteFriendRequest"
+
+	walPutNameHistory    = "putNameHistory"
+	walDeleteNameHistory = "deleteNameHistory"
+
+	walPutPostEdits    = "putPostEdits"
+	walDeletePostEdits = "deletePostEdits"
+
+	walPutReactions    = "putReactions"
+	walDeleteReactions = "deleteReactions"
+
+	walPutPollVotes    = "putPollVotes"
+	walDeletePollVotes = "deletePollVotes"
+)
+
+// walEntry -
+// one line of the write-ahead log
+type walEntry struct {
+	Op    string      `json:"op"`
+	Email string      `json:"email,omitempty"`
+	ID    string      `json:"id,omitempty"`
+	User  *storedUser `json:"user,omitempty"`
+	Post  *Post       `json:"post,omitempty"`
+	// ID is the key of the reset or verification token
+	ResetToken        *ResetToken        `json:"resetToken,omitempty"`
+	VerificationToken *VerificationToken `json:"verificationToken,omitempty"`
+	// ID is the friendKey of the pair
+	FriendRequest *FriendRequest `json:"friendRequest,omitempty"`
+	// the whole history of the user with Email
+	NameHistory []NameChange `json:"nameHistory,omitempty"`
+	// the whole edit history of the post with ID
+	PostEdits []PostEdit `json:"postEdits,omitempty"`
+	// every reaction to the post with ID
+	Reactions map[string]ReactionKind `json:"reactions,omitempty"`
+	// every vote in the poll of the post with ID
+	PollVotes map[string]int `json:"pollVotes,omitempty"`
+	// for blocks, mutes, follows and follow requests, Email points at ID since At
+	At *time.Time `json:"at,omitempty"`
+}
+
+// walPath is the log of writes made since the db file was last rewritten
+func (s *fileStore) walPath() string {
+	return s.path + walSuffix
+}
+
+// diffSchema -
+// the log entries that turn old into db
+func diffSchema(old, db Schema) []walEntry {
+	entries := []walEntry{}
+	for email, user := range db.Users {
+		if prev, ok := old.Users[email]; !ok || !prev.equal(user) {
+			user := storedUser(user)
+			entries = append(entries, walEntry{Op: walPutUser, User: &user})
+		}
+	}
+	for email := range old.Users {
+		if _, ok := db.Users[email]; !ok {
+			entries = append(entries, walEntry{Op: walDeleteUser, Email: email})
+		}
+	}
+	for id, post := range db.Posts {
+		if prev, ok := old.Posts[id]; !ok || !prev.equal(post) {
+			post := post
+			entries = append(entries, walEntry{Op: walPutPost, Post: &post})
+		}
+	}
+	for id := range old.Posts {
+		if _, ok := db.Posts[id]; !ok {
+			entries = append(entries, walEntry{Op: walDeletePost, ID: id})
+		}
+	}
+	for key, reset := range db.ResetTokens {
+		if prev, ok := old.ResetTokens[key]; !ok || prev != reset {
+			reset := reset
+			entries = append(entries, walEntry{Op: walPutResetToken, ID: key, ResetToken: &reset})
+		}
+	}
+	for key := range old.ResetTokens {
+		if _, ok := db.ResetTokens[key]; !ok {
+			entries = append(entries, walEntry{Op: walDeleteResetToken, ID: key})
+		}
+	}
+	for key, verification := range db.VerificationTokens {
+		if prev, ok := old.VerificationTokens[key]; !ok || prev != verification {
+			verification := verification
+			entries = append(entries, walEntry{Op: walPutVerificationToken, ID: key, VerificationToken: &verification})
+		}
+	}
+	for key := range old.VerificationTokens {
+		if _, ok := db.VerificationTokens[key]; !ok {
+			entries = append(entries, walEntry{Op: walDeleteVerificationToken, ID: key})
+		}
+	}
+	entries = append(entries, diffEdges(old.Blocks, db.Blocks, walPutBlock, walDeleteBlock)...)
+	entries = append(entries, diffEdges(old.Mutes, db.Mutes, walPutMute, walDeleteMute)...)
+	entries = append(entries, diffEdges(old.Follows, db.Follows, walPutFollow, walDeleteFollow)...)
+	entries = append(entries, diffEdges(old.FollowRequests, db.FollowRequests, walPutFollowRequest, walDeleteFollowRequest)...)
+	for key, request := range db.FriendRequests {
+		if prev, ok := old.FriendRequests[key]; !ok || prev != request {
+			request := request
+			entries = append(entries, walEntry{Op: walPutFriendRequest, ID: key, FriendRequest: &request})
+		}
+	}
+	for key := range old.FriendRequests {
+		if _, ok := db.FriendRequests[key]; !ok {
+			entries = append(entries, walEntry{Op: walDeleteFriendRequest, ID: key})
+		}
+	}
+	for email, history := range db.NameHistory {
+		if !equalSlices(old.NameHistory[email], history) {
+			entries = append(entries, walEntry{Op: walPutNameHistory, Email: email, NameHistory: history})
+		}
+	}
+	for email := range old.NameHistory {
+		if _, ok := db.NameHistory[email]; !ok {
+			entries = append(entries, walEntry{Op: walDeleteNameHistory, Email: email})
+		}
+	}
+	for id, edits := range db.PostEdits {
+		if !equalEdits(old.PostEdits[id], edits) {
+			entries = append(entries, walEntry{Op: walPutPostEdits, ID: id, PostEdits: edits})
+		}
+	}
+	for id := range old.PostEdits {
+		if _, ok := db.PostEdits[id]; !ok {
+			entries = append(entries, walEntry{Op: walDeletePostEdits, ID: id})
+		}
+	}
+	for id, reactions := range db.Reactions {
+		if !equalMaps(old.Reactions[id], reactions) {
+			entries = append(entries, walEntry{Op: walPutReactions, ID: id, Reactions: reactions})
+		}
+	}
+	for id := range old.Reactions {
+		if _, ok := db.Reactions[id]; !ok {
+			entries = append(entries, walEntry{Op: walDeleteReactions, ID: id})
+		}
+	}
+	for id, votes := range db.PollVotes {
+		if !equalMaps(old.PollVotes[id], votes) {
+			entries = append(entries, walEntry{Op: walPutPollVotes, ID: id, PollVotes: votes})
+		}
+	}
+	for id := range old.PollVotes {
+		if _, ok := db.PollVotes[id]; !ok {
+			entries = append(entries, walEntry{Op: walDeletePollVotes, ID: id})
+		}
+	}
+	return entries
+}
+
+// apply -
+// replay a single log entry onto db
+func (e walEntry) apply(db *Schema) error {
+	switch {
+	case e.Op == walPutUser && e.User != nil:
+		db.putUser(User(*e.User))
+	case e.Op == walDeleteUser:
+		db.deleteUser(e.Email)
+	case e.Op == walPutPost && e.Post != nil:
+		db.putPost(*e.Post)
+	case e.Op == walDeletePost:
+		db.deletePost(e.ID)
+	case e.Op == walPutResetToken && e.ResetToken != nil:
+		db.putResetToken(e.ID, *e.ResetToken)
+	case e.Op == walDeleteResetToken:
+		delete(db.ResetTokens, e.ID)
+	case e.Op == walPutVerificationToken && e.VerificationToken != nil:
+		db.putVerificationToken(e.ID, *e.VerificationToken)
+	case e.Op == walDeleteVerificationToken:
+		delete(db.VerificationTokens, e.ID)
+	case e.Op == walPutBlock && e.At != nil:
+		db.Blocks.put(e.Email, e.ID, *e.At)
+	case e.Op == walDeleteBlock:
+		db.Blocks.delete(e.Email, e.ID)
+	case e.Op == walPutMute && e.At != nil:
+		db.Mutes.put(e.Email, e.ID, *e.At)
+	case e.Op == walDeleteMute:
+		db.Mutes.delete(e.Email, e.ID)
+	case e.Op == walPutFollow && e.At != nil:
+		db.putFollow(e.Email, e.ID, *e.At)
+	case e.Op == walDeleteFollow:
+		db.deleteFollow(e.Email, e.ID)
+	case e.Op == walPutFollowRequest && e.At != nil:
+		db.FollowRequests.put(e.Email, e.ID, *e.At)
+	case e.Op == walDeleteFollowRequest:
+		db.FollowRequests.delete(e.Email, e.ID)
+	case e.Op == walPutFriendRequest && e.FriendRequest != nil:
+		db.putFriendRequest(*e.FriendRequest)
+	case e.Op == walDeleteFriendRequest:
+		delete(db.FriendRequests, e.ID)
+	case e.Op == walPutNameHistory && len(e.NameHistory) > 0:
+		db.putNameHistory(e.Email, e.NameHistory, len(e.NameHistory))
+	case e.Op == walDeleteNameHistory:
+		delete(db.NameHistory, e.Email)
+	case e.Op == walPutPostEdits && len(e.PostEdits) > 0:
+		db.putPostEdits(e.ID, e.PostEdits, len(e.PostEdits))
+	case e.Op == walDeletePostEdits:
+		delete(db.PostEdits, e.ID)
+	case e.Op == walPutReactions && len(e.Reactions) > 0:
+		db.Reactions.replace(e.ID, e.Reactions)
+	case e.Op == walDeleteReactions:
+		delete(db.Reactions, e.ID)
+	case e.Op == walPutPollVotes && len(e.PollVotes) > 0:
+		db.PollVotes.replace(e.ID, e.PollVotes)
+	case e.Op == walDeletePollVotes:
+		delete(db.PollVotes, e.ID)
+	default:
+		return fmt.Errorf("%w: unknown log entry %q", ErrDBCorrupt, e.Op)
+	}
+	return nil
+}
+
+// appendWAL -
+// record the changes from the last saved db to db in the log and fsync it,
+// the db file itself is only rewritten every snapshotEvery appends
+func (s *fileStore) appendWAL(db Schema) error {
+	var buf bytes.Buffer
+	for _, entry := range diffSchema(*s.base, db) {
+		line, err := s.encodeWALEntry(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if buf.Len() == 0 {
+		s.base = &db
+		return nil
+	}
+
+	f, err := os.OpenFile(s.walPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, s.fileMode)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	s.base = &db
+	s.pending++
+	s.lastWAL, _ = os.Stat(s.walPath())
+
+	if s.pending < s.snapshotEvery {
+		return nil
+	}
+	// the write is already durable in the log, a failed snapshot is retried on the next write
+	_ = s.snapshot(db)
+	return nil
+}
+
+// replayWAL -
+// apply every entry in the log to db, returns how many there were
+// a torn last line from a crash in the middle of an append is ignored
+func (s *fileStore) replayWAL(db *Schema) (int, error) {
+	// stat before reading like Load does, a concurrent append just means another reload later
+	info, err := os.Stat(s.walPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		s.lastWAL = nil
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	data, err := os.ReadFile(s.walPath())
+	if err != nil {
+		return 0, err
+	}
+
+	// an unterminated last line never finished its fsync, so its write was never acknowledged
+	if i := bytes.LastIndexByte(data, '\n'); i != len(data)-1 {
+		data = data[:i+1]
+	}
+	count := 0
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		entry, err := s.decodeWALEntry(line)
+		if err != nil {
+			return 0, err
+		}
+		if err := entry.apply(db); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	s.lastWAL = info
+	return count, nil
+}
+
+// encodeWALEntry turns an entry into a log line, encrypted like the db file if there's a key
+func (s *fileStore) encodeWALEntry(entry walEntry) ([]byte, error) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	if s.encryptionKey == nil {
+		return line, nil
+	}
+	sealed, err := encrypt(s.encryptionKey, line)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(sealed)), nil
+}
+
+// decodeWALEntry reverses encodeWALEntry, plain lines are json objects and anything else is encrypted
+func (s *fileStore) decodeWALEntry(line []byte) (walEntry, error) {
+	if line[0] != '{' {
+		sealed, err := base64.StdEncoding.DecodeString(string(line))
+		if err != nil || !bytes.HasPrefix(sealed, encryptedMagic) {
+			return walEntry{}, fmt.Errorf("%w: unreadable log entry", ErrDBCorrupt)
+		}
+		if s.encryptionKey == nil {
+			return walEntry{}, fmt.Errorf("%w: log is encrypted and no key is configured", ErrDecryptFailed)
+		}
+		line, err = decrypt(s.encryptionKey, sealed)
+		if err != nil {
+			return walEntry{}, err
+		}
+	}
+	entry := walEntry{}
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return walEntry{}, fmt.Errorf("%w: %v", ErrDBCorrupt, err)
+	}
+	return entry, nil
+}
+
+// snapshot -
+// rewrite the db file with db and drop the log, everything in it is part of db now
+// if the log can't be removed that's returned, and the next load replays it over a db that
+// already has its writes in it. the log has no sequence number to tell, so don't count on that being harmless
+func (s *fileStore) snapshot(db Schema) error {
+	payload, err := s.encodeDB(db)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, payload, s.fileMode); err != nil {
+		return err
+	}
+	s.last, _ = os.Stat(s.path)
+	s.base = &db
+	if s.pending > 0 || s.lastWAL != nil {
+		if err := os.Remove(s.walPath()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		s.pending = 0
+		s.lastWAL = nil
+	}
+	return nil
+}
+
+// Flush -
+// rewrite the db file if the log has writes that aren't in it yet
+func (s *fileStore) Flush(ctx context.Context) error {
+	if s.pending == 0 || s.base == nil {
+		return nil
+	}
+	return s.snapshot(*s.base)
+}
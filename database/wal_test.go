@@ -0,0 +1,201 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newWALClient creates a client in write-ahead log mode backed by a fresh db file
+func newWALClient(t *testing.T, snapshotEvery int, opts ...Option) *Client {
+	t.Helper()
+	opts = append(opts, WithWAL(snapshotEvery))
+	c := NewClient(filepath.Join(t.TempDir(), "db.json"), opts...)
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatalf("EnsureDB() = %v, expected nil", err)
+	}
+	return c
+}
+
+// failSnapshots makes every rewrite of the db file fail, like a writer killed before its snapshot
+func failSnapshots(t *testing.T) {
+	orig := createTemp
+	createTemp = func(dir, pattern string) (tempFile, error) {
+		return nil, errors.New("killed")
+	}
+	t.Cleanup(func() { createTemp = orig })
+}
+
+// readSnapshot parses the db file at path and ignores the log next to it
+func readSnapshot(path string) (Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Schema{}, err
+	}
+	db, _, err := newFileStore(path, newOptions(nil)).decodeDB(data)
+	return db, err
+}
+
+func TestWALReplay(t *testing.T) {
+	c := newWALClient(t, 1)
+	snapshot, err := os.ReadFile(dbPath(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failSnapshots(t)
+	if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatalf("CreateUser() = %v, expected the log to make it durable", err)
+	}
+	post, err := c.CreatePost(ctx, "test@example.com", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UpdateUser(ctx, "test@example.com", "54321", "john doe", 19); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContents(t, dbPath(c), string(snapshot))
+
+	// a new process only has the stale snapshot and the log
+	restarted := NewClient(dbPath(c))
+	if err := restarted.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	user, err := restarted.GetUser(ctx, "test@example.com")
+	if err != nil {
+		t.Fatalf("GetUser() after replay = %v, expected nil", err)
+	}
+	if user.Age != 19 {
+		t.Errorf("user.Age after replay = %d, expected 19", user.Age)
+	}
+	posts, err := restarted.GetPosts(ctx, "test@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 1 || !posts[0].equal(post) {
+		t.Errorf("GetPosts() after replay = %v, expected [%v]", posts, post)
+	}
+}
+
+func TestWALSnapshotTruncates(t *testing.T) {
+	c := newWALClient(t, 3)
+	walPath := dbPath(c) + walSuffix
+
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if _, err := c.CreateUser(ctx, email, "12345", "john doe", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(walPath); err != nil {
+		t.Fatalf("log after 2 writes: %v, expected it to exist", err)
+	}
+	if db, err := readSnapshot(dbPath(c)); err != nil || len(db.Users) != 0 {
+		t.Fatalf("db file after 2 writes has %d users (err %v), expected 0", len(db.Users), err)
+	}
+
+	// the third write rewrites the file and drops the log
+	if _, err := c.CreateUser(ctx, "c@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(walPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("log after snapshot: %v, expected it to be removed", err)
+	}
+	if db, err := readSnapshot(dbPath(c)); err != nil || len(db.Users) != 3 {
+		t.Errorf("db file after snapshot has %d users (err %v), expected 3", len(db.Users), err)
+	}
+
+	// Close writes out whatever is still only in the log
+	if _, err := c.DeleteUser(ctx, "a@example.com", DeleteUserOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close() = %v, expected nil", err)
+	}
+	if _, err := os.Stat(walPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("log after Close: %v, expected it to be removed", err)
+	}
+	if db, err := readSnapshot(dbPath(c)); err != nil || len(db.Users) != 2 {
+		t.Errorf("db file after Close has %d users (err %v), expected 2", len(db.Users), err)
+	}
+}
+
+func TestWALTornEntry(t *testing.T) {
+	var tests = []struct {
+		name        string
+		tail        string
+		expectedErr error
+	}{
+		{name: "unterminated last line", tail: `{"op":"putUs`, expectedErr: nil},
+		{name: "garbage line", tail: "garbage\n", expectedErr: ErrDBCorrupt},
+		{name: "unknown op", tail: `{"op":"dropTable"}` + "\n", expectedErr: ErrDBCorrupt},
+	}
+
+	for _, test := range tests {
+		c := newWALClient(t, 100)
+		if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.OpenFile(dbPath(c)+walSuffix, os.O_WRONLY|os.O_APPEND, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteString(test.tail); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		_, err = NewClient(dbPath(c)).GetUser(ctx, "test@example.com")
+		if !errors.Is(err, test.expectedErr) {
+			t.Errorf("%s: GetUser() = %v, expected %v", test.name, err, test.expectedErr)
+		}
+	}
+}
+
+func TestWALEncrypted(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	c := newWALClient(t, 100, WithEncryptionKey(key))
+	if _, err := c.CreateUser(ctx, "test@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(dbPath(c) + walSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "test@example.com") {
+		t.Errorf("log contains plaintext: %s", data)
+	}
+
+	if _, err := NewClient(dbPath(c)).GetUser(ctx, "test@example.com"); !errors.Is(err, ErrDecryptFailed) {
+		t.Errorf("GetUser() without key = %v, expected %v", err, ErrDecryptFailed)
+	}
+	if _, err := NewClient(dbPath(c), WithEncryptionKey(key)).GetUser(ctx, "test@example.com"); err != nil {
+		t.Errorf("GetUser() with key = %v, expected nil", err)
+	}
+}
+
+func TestWALSharedBetweenClients(t *testing.T) {
+	a := newWALClient(t, 100)
+	b := NewClient(dbPath(a), WithWAL(100))
+
+	if _, err := a.CreateUser(ctx, "a@example.com", "12345", "john doe", 18); err != nil {
+		t.Fatal(err)
+	}
+	// b has to pick up a's write from the log before making its own
+	if _, err := b.CreateUser(ctx, "b@example.com", "12345", "jane doe", 20); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.CreatePost(ctx, "b@example.com", "hello"); err != nil {
+		t.Errorf("CreatePost() for a user created by the other client = %v, expected nil", err)
+	}
+
+	db, err := NewClient(dbPath(a)).Dump(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.Users) != 2 || len(db.Posts) != 1 {
+		t.Errorf("db after shared writes has %d users and %d posts, expected 2 and 1", len(db.Users), len(db.Posts))
+	}
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// OnExternalChange -
+// call fn after WithFileWatch reloaded the db because another process changed the file
+func (c *Client) OnExternalChange(fn func()) {
+	c.hooks.mu.Lock()
+	defer c.hooks.mu.Unlock()
+	c.hooks.externalChange = append(c.hooks.externalChange, fn)
+}
+
+// watch -
+// poll the store for changes made by others until Close
+func (c *Client) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	conflict := false
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			conflict = c.reloadIfChanged(conflict)
+		}
+	}
+}
+
+// reloadIfChanged -
+// reload the db if the store changed underneath the client, returns whether that couldn't be done
+// because of batched writes that haven't been saved yet. those win when they are, the other
+// process's write is lost, so it's logged as an error (once, warned is what the last call returned)
+func (c *Client) reloadIfChanged(warned bool) bool {
+	ctx := context.Background()
+	c.mu.Lock()
+	detector := c.store.(ChangeDetector)
+	if c.mem == nil || !detector.Changed() {
+		c.mu.Unlock()
+		return false
+	}
+	if c.batch != nil && c.batch.pending > 0 {
+		pending := c.batch.pending
+		c.mu.Unlock()
+		if !warned {
+			c.logError("db file changed by another process while batched writes are pending, "+
+				"saving them will overwrite that change", "writes", pending)
+		}
+		return true
+	}
+	err := c.load(ctx)
+	c.mu.Unlock()
+	if err != nil {
+		c.logError("reloading db changed by another process failed", "error", err)
+		return false
+	}
+	c.logInfo("reloaded db changed by another process")
+
+	c.hooks.mu.RLock()
+	fns := append([]func(){}, c.hooks.externalChange...)
+	c.hooks.mu.RUnlock()
+	for _, fn := range fns {
+		runHook(fn)
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileWatchReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	c := NewClient(path, WithFileWatch(5*time.Millisecond))
+	defer c.Close()
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan struct{}, 10)
+	c.OnExternalChange(func() { changes <- struct{}{} })
+
+	// another client writing through the lock
+	if _, err := NewClient(path).CreateUser(ctx, "other@example.com", "123456", "other", 18); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		_, err := c.GetUser(ctx, "other@example.com")
+		return err == nil
+	})
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("OnExternalChange wasn't called")
+	}
+
+	// and someone replacing the file by hand
+	raw := `{"schemaVersion": 1, "users": {"raw@example.com": {"email": "raw@example.com"}}, "posts": {}}`
+	if err := os.WriteFile(path, []byte(raw), 0600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		_, err := c.GetUser(ctx, "raw@example.com")
+		return err == nil
+	})
+}
+
+func TestFileWatchStopsOnClose(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	c := NewClient(path, WithFileWatch(time.Millisecond))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("second Close() = %v, expected nil", err)
+	}
+	if _, err := NewClient(path).CreateUser(ctx, "other@example.com", "123456", "other", 18); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	c.mu.RLock()
+	_, reloaded := c.mem.Users["other@example.com"]
+	c.mu.RUnlock()
+	if reloaded {
+		t.Errorf("client reloaded after Close()")
+	}
+}
+
+func TestFileWatchConflictIsLogged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	logger := &captureLogger{}
+	c := NewClient(path, WithFileWatch(5*time.Millisecond), WithBatchedWrites(time.Hour, 0), WithLogger(logger))
+	defer c.Close()
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, "mine@example.com", "123456", "mine", 18); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// same contents, new mtime
+	time.Sleep(10 * time.Millisecond)
+	if err := writeFileAtomic(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool {
+		logger.mu.Lock()
+		defer logger.mu.Unlock()
+		for _, entry := range logger.entries {
+			if entry.level == "error" && entry.attrs["writes"] == 1 {
+				return true
+			}
+		}
+		return false
+	})
+	// the pending write wasn't thrown away by a reload
+	if _, err := c.GetUser(ctx, "mine@example.com"); err != nil {
+		t.Errorf("GetUser() = %v, expected the batched write to survive", err)
+	}
+}
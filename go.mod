@@ -4,4 +4,13 @@ go 1.19
 
 replace github.com/Warren-Wang-OG/go-social-media-backend/database => ./database/database.go
 
-require github.com/google/uuid v1.3.0 // indirect
+require github.com/google/uuid v1.3.0
+
+require (
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.22
+	go.etcd.io/bbolt v1.3.7
+	golang.org/x/crypto v0.17.0
+)
+
+require golang.org/x/sys v0.15.0 // indirect
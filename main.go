@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,7 +18,7 @@ type errorBody struct {
 }
 
 type apiConfig struct {
-	dbClient database.Client
+	dbClient *database.Client
 }
 
 func testHandler(w http.ResponseWriter, r *http.Request) {
@@ -43,8 +44,45 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	w.Write(response)
 	w.WriteHeader(code)
+	w.Write(response)
+}
+
+// pick the http status code for an error returned by the database client
+func dbErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrInvalidCredentials):
+		return http.StatusUnauthorized
+	case errors.Is(err, database.ErrUserNotFound), errors.Is(err, database.ErrPostNotFound),
+		errors.Is(err, database.ErrFriendRequestNotFound), errors.Is(err, database.ErrFollowRequestNotFound),
+		errors.Is(err, database.ErrReactionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrUserExists), errors.Is(err, database.ErrNotScheduled),
+		errors.Is(err, database.ErrPostNotPublished), errors.Is(err, database.ErrAlreadyReposted),
+		errors.Is(err, database.ErrPollClosed):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrUserDeleted), errors.Is(err, database.ErrAccountDeactivated),
+		errors.Is(err, database.ErrPermissionDenied), errors.Is(err, database.ErrEmailNotVerified),
+		errors.Is(err, database.ErrNotPostAuthor):
+		return http.StatusForbidden
+	case errors.Is(err, database.ErrEmptyPostID), errors.Is(err, database.ErrInvalidEmail),
+		errors.Is(err, database.ErrWeakPassword), errors.Is(err, database.ErrPasswordReused),
+		errors.Is(err, database.ErrInvalidUserField), errors.Is(err, database.ErrInvalidAge),
+		errors.Is(err, database.ErrInvalidSetting),
+		errors.Is(err, database.ErrInvalidRole), errors.Is(err, database.ErrEmptySearchQuery),
+		errors.Is(err, database.ErrInvalidHashtag),
+		errors.Is(err, database.ErrSelfBlock), errors.Is(err, database.ErrSelfFollow),
+		errors.Is(err, database.ErrSelfFriendRequest), errors.Is(err, database.ErrSelfMute),
+		errors.Is(err, database.ErrInvalidMerge), errors.Is(err, database.ErrInvalidCursor),
+		errors.Is(err, database.ErrEmptyPost), errors.Is(err, database.ErrPostTooLong),
+		errors.Is(err, database.ErrInvalidVisibility), errors.Is(err, database.ErrInvalidAttachment),
+		errors.Is(err, database.ErrRepostNotEditable), errors.Is(err, database.ErrInvalidReaction),
+		errors.Is(err, database.ErrInvalidPoll), errors.Is(err, database.ErrInvalidPollOption),
+		errors.Is(err, database.ErrNotPoll):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
 }
 
 // TODO: this function is not used by any other func, except the test function as of right now
@@ -92,9 +130,9 @@ func (apiCfg apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request
 	}
 
 	// update user
-	_, err = apiCfg.dbClient.UpdateUser(email, params.Password, params.Name, params.Age)
+	_, err = apiCfg.dbClient.UpdateUser(r.Context(), email, params.Password, params.Name, params.Age)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err)
+		respondWithError(w, dbErrorStatus(err), err)
 		return
 	}
 
@@ -107,12 +145,11 @@ func (apiCfg apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request
 func (apiCfg apiConfig) handlerGetUser(w http.ResponseWriter, r *http.Request) {
 	// get email from path
 	email := r.URL.Path[len("/users/"):]
-	user, err := apiCfg.dbClient.GetUser(email)
+	user, err := apiCfg.dbClient.GetUser(r.Context(), email)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err)
+		respondWithError(w, dbErrorStatus(err), err)
 		return
 	}
-
 	// good, return 200 status code with the user info
 	respondWithJSON(w, http.StatusOK, user)
 }
@@ -125,9 +162,9 @@ func (apiCfg apiConfig) handlerDeleteUser(w http.ResponseWriter, r *http.Request
 	email := r.URL.Path[len("/users/"):]
 
 	// delete user
-	err := apiCfg.dbClient.DeleteUser(email)
+	_, err := apiCfg.dbClient.DeleteUser(r.Context(), email, database.DeleteUserOptions{})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err)
+		respondWithError(w, dbErrorStatus(err), err)
 		return
 	}
 
@@ -155,9 +192,9 @@ func (apiCfg apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request
 	}
 
 	// create the new user from params
-	_, err = apiCfg.dbClient.CreateUser(params.Email, params.Password, params.Name, params.Age)
+	_, err = apiCfg.dbClient.CreateUser(r.Context(), params.Email, params.Password, params.Name, params.Age)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err)
+		respondWithError(w, dbErrorStatus(err), err)
 		return
 	}
 
@@ -195,9 +232,9 @@ func (apiCfg apiConfig) handlerDeletePost(w http.ResponseWriter, r *http.Request
 	uuid := r.URL.Path[len("/posts/"):]
 
 	// delete post
-	err := apiCfg.dbClient.DeletePost(uuid)
+	_, err := apiCfg.dbClient.DeletePost(r.Context(), uuid)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err)
+		respondWithError(w, dbErrorStatus(err), err)
 		return
 	}
 
@@ -207,11 +244,12 @@ func (apiCfg apiConfig) handlerDeletePost(w http.ResponseWriter, r *http.Request
 
 // create a post when a POST request is made to /posts
 // this takes an input of a json object with the following fields:
-// email, text
+// email, text and optionally attachments, a list of {url, mimeType, altText, width, height}
 func (apiCfg apiConfig) handlerCreatePost(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		UserEmail string `json:"email"`
-		Text      string `json:"text"`
+		UserEmail   string                `json:"email"`
+		Text        string                `json:"text"`
+		Attachments []database.Attachment `json:"attachments"`
 	}
 
 	// convert json object to parameters struct
@@ -224,9 +262,10 @@ func (apiCfg apiConfig) handlerCreatePost(w http.ResponseWriter, r *http.Request
 	}
 
 	// create the new post from params
-	_, err = apiCfg.dbClient.CreatePost(params.UserEmail, params.Text)
+	opts := database.CreatePostOptions{Attachments: params.Attachments}
+	_, err = apiCfg.dbClient.CreatePostWithOptions(r.Context(), params.UserEmail, params.Text, opts)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err)
+		respondWithError(w, dbErrorStatus(err), err)
 		return
 	}
 
@@ -234,14 +273,32 @@ func (apiCfg apiConfig) handlerCreatePost(w http.ResponseWriter, r *http.Request
 	respondWithJSON(w, http.StatusCreated, struct{}{})
 }
 
+// the email of the user a request is signed with through basic auth, "" for someone logged out
+// ErrInvalidCredentials if the email and password don't match, ErrAccountDeactivated for a deactivated account
+func (apiCfg apiConfig) viewer(r *http.Request) (string, error) {
+	email, password, ok := r.BasicAuth()
+	if !ok {
+		return "", nil
+	}
+	if _, err := apiCfg.dbClient.CheckCredentials(r.Context(), email, password); err != nil {
+		return "", err
+	}
+	return email, nil
+}
+
 // return list of all posts when a GET request is made to /posts/EMAIL
-// for a specific user based on the EMAIL given
+// for a specific user based on the EMAIL given, only the ones the viewer may see
 func (apiCfg apiConfig) handlerRetrievePosts(w http.ResponseWriter, r *http.Request) {
 	// get email from path
 	email := r.URL.Path[len("/posts/"):]
-	posts, err := apiCfg.dbClient.GetPosts(email)
+	viewer, err := apiCfg.viewer(r)
+	if err != nil {
+		respondWithError(w, dbErrorStatus(err), err)
+		return
+	}
+	posts, err := apiCfg.dbClient.GetPostsAs(r.Context(), viewer, email)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err)
+		respondWithError(w, dbErrorStatus(err), err)
 		return
 	}
 
@@ -269,7 +326,7 @@ func (apiCfg apiConfig) endpointPostsHandler(w http.ResponseWriter, r *http.Requ
 func main() {
 	// create a new database
 	c := database.NewClient("db.json")
-	err := c.EnsureDB()
+	err := c.EnsureDB(context.Background())
 	if err != nil {
 		log.Fatal(err)
 	}
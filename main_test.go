@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Warren-Wang-OG/go-social-media-backend/database"
+)
+
+const testPassword = "correct horse battery"
+
+// newTestAPI has author@ with a public, a followers-only and a private post, private@ with a private
+// account and a post, and follower@ following both of them while stranger@ follows no one
+func newTestAPI(t *testing.T) apiConfig {
+	t.Helper()
+	ctx := context.Background()
+	c := database.NewClient(filepath.Join(t.TempDir(), "db.json"), database.WithPasswordCost(4))
+	if err := c.EnsureDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{"author@example.com", "private@example.com", "follower@example.com", "stranger@example.com"} {
+		if _, err := c.CreateUser(ctx, email, testPassword, "name", 18); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, visibility := range []database.Visibility{database.VisibilityPublic, database.VisibilityFollowers, database.VisibilityPrivate} {
+		if _, err := c.CreatePostWithOptions(ctx, "author@example.com", string(visibility), database.CreatePostOptions{Visibility: visibility}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.CreatePost(ctx, "private@example.com", "locked"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.SetPrivate(ctx, "private@example.com", true); err != nil {
+		t.Fatal(err)
+	}
+	for _, followee := range []string{"author@example.com", "private@example.com"} {
+		if err := c.FollowUser(ctx, "follower@example.com", followee); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.ApproveFollowRequest(ctx, "private@example.com", "follower@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	return apiConfig{dbClient: c}
+}
+
+// getPosts is the status and the texts of the posts a GET of /posts/email signed as viewer gets, "" for no one
+func getPosts(t *testing.T, api apiConfig, viewer, password, email string) (int, []string) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/posts/"+email, nil)
+	if viewer != "" {
+		r.SetBasicAuth(viewer, password)
+	}
+	w := httptest.NewRecorder()
+	api.endpointPostsHandler(w, r)
+	if w.Code != http.StatusOK {
+		return w.Code, nil
+	}
+	posts := []database.Post{}
+	if err := json.Unmarshal(w.Body.Bytes(), &posts); err != nil {
+		t.Fatal(err)
+	}
+	texts := []string{}
+	for _, post := range posts {
+		texts = append(texts, post.Text)
+	}
+	sort.Strings(texts)
+	return w.Code, texts
+}
+
+func TestHandlerRetrievePostsVisibility(t *testing.T) {
+	api := newTestAPI(t)
+	var tests = []struct {
+		viewer   string
+		expected []string
+	}{
+		{viewer: "author@example.com", expected: []string{"followers", "private", "public"}},
+		{viewer: "follower@example.com", expected: []string{"followers", "public"}},
+		{viewer: "stranger@example.com", expected: []string{"public"}},
+		{viewer: "", expected: []string{"public"}},
+	}
+	for _, test := range tests {
+		if code, texts := getPosts(t, api, test.viewer, testPassword, "author@example.com"); code != http.StatusOK || !reflect.DeepEqual(texts, test.expected) {
+			t.Errorf("GET /posts/author@ as %q = %d %q, expected %q", test.viewer, code, texts, test.expected)
+		}
+	}
+}
+
+func TestHandlerRetrievePostsPrivateAccount(t *testing.T) {
+	api := newTestAPI(t)
+	var tests = []struct {
+		viewer   string
+		expected []string
+	}{
+		{viewer: "private@example.com", expected: []string{"locked"}},
+		{viewer: "follower@example.com", expected: []string{"locked"}},
+		{viewer: "stranger@example.com", expected: []string{}},
+		{viewer: "", expected: []string{}},
+	}
+	for _, test := range tests {
+		if code, texts := getPosts(t, api, test.viewer, testPassword, "private@example.com"); code != http.StatusOK || !reflect.DeepEqual(texts, test.expected) {
+			t.Errorf("GET /posts/private@ as %q = %d %q, expected %q", test.viewer, code, texts, test.expected)
+		}
+	}
+
+	// a wrong password doesn't fall back to logged out
+	for _, viewer := range []string{"follower@example.com", "missing@example.com"} {
+		if code, _ := getPosts(t, api, viewer, "wrong password", "private@example.com"); code != http.StatusUnauthorized {
+			t.Errorf("GET /posts/private@ as %q with a wrong password = %d, expected %d", viewer, code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestHandlerRetrievePostsDeactivatedViewer(t *testing.T) {
+	api := newTestAPI(t)
+	if _, err := api.dbClient.DeactivateUser(context.Background(), "follower@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := getPosts(t, api, "follower@example.com", testPassword, "author@example.com"); code != http.StatusForbidden {
+		t.Errorf("GET /posts/author@ as a deactivated follower@ = %d, expected %d", code, http.StatusForbidden)
+	}
+	user, err := api.dbClient.GetUser(context.Background(), "author@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// signing a request isn't a login
+	if code, _ := getPosts(t, api, "author@example.com", testPassword, "author@example.com"); code != http.StatusOK {
+		t.Fatalf("GET /posts/author@ as author@ = %d, expected %d", code, http.StatusOK)
+	}
+	after, err := api.dbClient.GetUser(context.Background(), "author@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.LoginCount != user.LoginCount {
+		t.Errorf("LoginCount after a signed GET = %d, expected %d", after.LoginCount, user.LoginCount)
+	}
+}
+
+func TestHandlerGetUserNoPassword(t *testing.T) {
+	api := newTestAPI(t)
+	r := httptest.NewRequest(http.MethodGet, "/users/author@example.com", nil)
+	w := httptest.NewRecorder()
+	api.handlerGetUser(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "author@example.com") {
+		t.Fatalf("GET /users/author@ = %d %s, expected the user", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "password") || strings.Contains(w.Body.String(), "$2a$") {
+		t.Errorf("GET /users/author@ = %s, expected no password hash", w.Body)
+	}
+}
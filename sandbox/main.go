@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 
@@ -8,92 +9,93 @@ import (
 )
 
 func main() {
+	ctx := context.Background()
 	c := database.NewClient("db.json")
-	err := c.EnsureDB()
+	err := c.EnsureDB(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("database created!")
 
-	user, err := c.CreateUser("test@example.com", "password", "john doe", 18)
+	user, err := c.CreateUser(ctx, "test@example.com", "correct horse battery", "john doe", 18)
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("user created", user)
 
-	updatedUser, err := c.UpdateUser("test@example.com", "new password", "JOE MAMA", 18)
+	updatedUser, err := c.UpdateUser(ctx, "test@example.com", "correct horse battery staple", "JOE MAMA", 18)
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("user updated", updatedUser)
 
-	gotUser, err := c.GetUser("test@example.com")
+	gotUser, err := c.GetUser(ctx, "test@example.com")
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("user got", gotUser)
 
-	err = c.DeleteUser("test@example.com")
+	_, err = c.DeleteUser(ctx, "test@example.com", database.DeleteUserOptions{})
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("user deleted")
 
-	_, err = c.GetUser("test@example.com")
+	_, err = c.GetUser(ctx, "test@example.com")
 	if err == nil {
 		log.Fatal("shouldn't be able to get user that was deleted")
 	}
 	fmt.Println("user confirmed deleted")
 
-	user, err = c.CreateUser("test@example.com", "password", "john doe", 18)
+	user, err = c.CreateUser(ctx, "test@example.com", "correct horse battery", "john doe", 18)
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("user recreated", user)
 
-	post, err := c.CreatePost("test@example.com", "my cat is way too fat")
+	post, err := c.CreatePost(ctx, "test@example.com", "my cat is way too fat")
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("post created", post)
 
-	secondPost, err := c.CreatePost("test@example.com", "my cat is getting skinny now")
+	secondPost, err := c.CreatePost(ctx, "test@example.com", "my cat is getting skinny now")
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("another post created", secondPost)
 
-	posts, err := c.GetPosts("test@example.com")
+	posts, err := c.GetPosts(ctx, "test@example.com")
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("got posts", posts)
 
-	err = c.DeletePost(post.ID)
+	_, err = c.DeletePost(ctx, post.ID)
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("deleted first post", posts[0])
 
-	posts, err = c.GetPosts("test@example.com")
+	posts, err = c.GetPosts(ctx, "test@example.com")
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("got posts", posts)
 
-	err = c.DeletePost(secondPost.ID)
+	_, err = c.DeletePost(ctx, secondPost.ID)
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("deleted second post", posts)
 
-	posts, err = c.GetPosts("test@example.com")
+	posts, err = c.GetPosts(ctx, "test@example.com")
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("got posts", posts)
 
-	err = c.DeleteUser("test@example.com")
+	_, err = c.DeleteUser(ctx, "test@example.com", database.DeleteUserOptions{})
 	if err != nil {
 		log.Fatal(err)
 	}